@@ -0,0 +1,172 @@
+package quic
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+const (
+	// networkChangeCheckInterval is the interval at which we check if the local address used to reach the peer changed.
+	networkChangeCheckInterval = time.Second
+	// autoMigrationRetryInterval is the time we wait after a failed migration before migrating again.
+	autoMigrationRetryInterval = time.Second
+)
+
+// The autoMigrator migrates a client connection to a new UDP socket when the network changes.
+// It detects network changes in two ways:
+// 1. by periodically checking which local address the operating system uses to reach the peer
+// 2. by errors that occur when sending packets
+type autoMigrator struct {
+	conn       Connection
+	onMigrated func(Connection, MigrationEvent)
+	logger     utils.Logger
+
+	started  int32 // accessed atomically
+	triggers chan MigrationReason
+
+	// ownedConn is the packet conn created by the last successful migration.
+	// It is closed when migrating again, and when the connection is closed.
+	ownedConn net.PacketConn
+
+	// used for testing
+	listenUDP        func() (net.PacketConn, error)
+	preferredLocalIP func(net.Addr) (net.IP, error)
+}
+
+func newAutoMigrator(conn Connection, onMigrated func(Connection, MigrationEvent), logger utils.Logger) *autoMigrator {
+	return &autoMigrator{
+		conn:       conn,
+		onMigrated: onMigrated,
+		logger:     logger,
+		triggers:   make(chan MigrationReason, 1),
+		listenUDP: func() (net.PacketConn, error) {
+			return net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+		},
+		preferredLocalIP: preferredLocalIP,
+	}
+}
+
+// Start starts monitoring the network.
+// It must be called once the handshake is confirmed, since a connection can't be migrated before that.
+func (m *autoMigrator) Start() {
+	if atomic.CompareAndSwapInt32(&m.started, 0, 1) {
+		go m.run()
+	}
+}
+
+// Trigger triggers a migration.
+// It is a no-op if the autoMigrator wasn't started yet, or if a migration is already pending.
+func (m *autoMigrator) Trigger(reason MigrationReason) {
+	if atomic.LoadInt32(&m.started) == 0 {
+		return
+	}
+	select {
+	case m.triggers <- reason:
+	default:
+	}
+}
+
+// SendConn wraps the connection's sendConn.
+// Once the autoMigrator is started, errors that occur when sending packets trigger a migration,
+// instead of being returned (which would close the connection).
+func (m *autoMigrator) SendConn(c sendConn) sendConn {
+	return &autoMigratingSendConn{sendConn: c, migrator: m}
+}
+
+func (m *autoMigrator) run() {
+	ctx := m.conn.Context()
+	ticker := time.NewTicker(networkChangeCheckInterval)
+	defer ticker.Stop()
+
+	localIP, _ := m.preferredLocalIP(m.conn.RemoteAddr())
+	for {
+		var reason MigrationReason
+		select {
+		case <-ctx.Done():
+			if m.ownedConn != nil {
+				m.ownedConn.Close()
+			}
+			return
+		case reason = <-m.triggers:
+		case <-ticker.C:
+			ip, err := m.preferredLocalIP(m.conn.RemoteAddr())
+			if err != nil || ip.Equal(localIP) {
+				continue
+			}
+			reason = MigrationReasonNetworkChange
+		}
+		if err := m.migrate(ctx, reason); err != nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(autoMigrationRetryInterval):
+			}
+		} else {
+			localIP, _ = m.preferredLocalIP(m.conn.RemoteAddr())
+		}
+		// Drop triggers caused by packets sent on the old path while we were migrating.
+		select {
+		case <-m.triggers:
+		default:
+		}
+	}
+}
+
+func (m *autoMigrator) migrate(ctx context.Context, reason MigrationReason) error {
+	ev := MigrationEvent{Reason: reason, OldLocalAddr: m.conn.LocalAddr()}
+	m.logger.Debugf("Migrating connection (%s)", reason)
+	pconn, err := m.listenUDP()
+	if err == nil {
+		if err = m.conn.Migrate(ctx, pconn); err != nil {
+			pconn.Close()
+		}
+	}
+	if err != nil {
+		m.logger.Debugf("Migrating connection failed: %s", err)
+		ev.Err = err
+	} else {
+		m.logger.Debugf("Migrated connection from %s to %s", ev.OldLocalAddr, pconn.LocalAddr())
+		if m.ownedConn != nil {
+			m.ownedConn.Close()
+		}
+		m.ownedConn = pconn
+		ev.NewLocalAddr = pconn.LocalAddr()
+	}
+	if m.onMigrated != nil {
+		m.onMigrated(m.conn, ev)
+	}
+	return err
+}
+
+type autoMigratingSendConn struct {
+	sendConn
+	migrator *autoMigrator
+}
+
+func (c *autoMigratingSendConn) Write(p []byte) error {
+	err := c.sendConn.Write(p)
+	if err == nil || isMsgSizeErr(err) || atomic.LoadInt32(&c.migrator.started) == 0 {
+		return err
+	}
+	c.migrator.logger.Debugf("Sending packet failed, triggering migration: %s", err)
+	c.migrator.Trigger(MigrationReasonSendError)
+	return nil
+}
+
+// preferredLocalIP returns the local IP address that the operating system uses to send packets to addr.
+// Connecting a UDP socket doesn't send any packets, it only performs the route lookup.
+func preferredLocalIP(addr net.Addr) (net.IP, error) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return nil, &net.AddrError{Err: "not a UDP address", Addr: addr.String()}
+	}
+	c, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -0,0 +1,176 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Automatic Migration", func() {
+	var (
+		m          *autoMigrator
+		conn       *MockQuicConn
+		ctx        context.Context
+		cancel     context.CancelFunc
+		events     chan MigrationEvent
+		localIPMx  sync.Mutex
+		localIP    net.IP
+		newPconns  chan net.PacketConn
+		remoteAddr = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
+		localAddr  = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 4242}
+	)
+
+	setLocalIP := func(ip net.IP) {
+		localIPMx.Lock()
+		defer localIPMx.Unlock()
+		localIP = ip
+	}
+
+	newMockPacketConn := func(port int) *MockPacketConn {
+		pconn := NewMockPacketConn(mockCtrl)
+		pconn.EXPECT().LocalAddr().Return(&net.UDPAddr{IP: net.IPv4zero, Port: port}).AnyTimes()
+		return pconn
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		conn = NewMockQuicConn(mockCtrl)
+		conn.EXPECT().Context().Return(ctx).AnyTimes()
+		conn.EXPECT().RemoteAddr().Return(remoteAddr).AnyTimes()
+		conn.EXPECT().LocalAddr().Return(localAddr).AnyTimes()
+		events = make(chan MigrationEvent, 10)
+		newPconns = make(chan net.PacketConn, 10)
+		setLocalIP(localAddr.IP)
+		m = newAutoMigrator(conn, func(c Connection, ev MigrationEvent) {
+			defer GinkgoRecover()
+			Expect(c).To(Equal(conn))
+			events <- ev
+		}, utils.DefaultLogger)
+		m.listenUDP = func() (net.PacketConn, error) {
+			select {
+			case pconn := <-newPconns:
+				return pconn, nil
+			default:
+				return nil, errors.New("no packet conn")
+			}
+		}
+		m.preferredLocalIP = func(addr net.Addr) (net.IP, error) {
+			Expect(addr).To(Equal(remoteAddr))
+			localIPMx.Lock()
+			defer localIPMx.Unlock()
+			return localIP, nil
+		}
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Context("sending packets", func() {
+		It("returns errors before it is started", func() {
+			c := NewMockSendConn(mockCtrl)
+			testErr := errors.New("test error")
+			c.EXPECT().Write([]byte("foobar")).Return(testErr)
+			Expect(m.SendConn(c).Write([]byte("foobar"))).To(MatchError(testErr))
+			Expect(m.triggers).To(BeEmpty())
+		})
+
+		It("triggers a migration instead of returning errors", func() {
+			atomic.StoreInt32(&m.started, 1)
+			c := NewMockSendConn(mockCtrl)
+			c.EXPECT().Write([]byte("foobar")).Return(errors.New("test error"))
+			Expect(m.SendConn(c).Write([]byte("foobar"))).To(Succeed())
+			Expect(m.triggers).To(Receive(Equal(MigrationReasonSendError)))
+		})
+	})
+
+	It("migrates when sending fails", func() {
+		pconn := newMockPacketConn(1234)
+		newPconns <- pconn
+		conn.EXPECT().Migrate(gomock.Any(), pconn)
+		m.Start()
+		m.Trigger(MigrationReasonSendError)
+		var ev MigrationEvent
+		Eventually(events).Should(Receive(&ev))
+		Expect(ev.Reason).To(Equal(MigrationReasonSendError))
+		Expect(ev.OldLocalAddr).To(Equal(localAddr))
+		Expect(ev.NewLocalAddr).To(Equal(&net.UDPAddr{IP: net.IPv4zero, Port: 1234}))
+		Expect(ev.Err).ToNot(HaveOccurred())
+		// the packet conn is closed when the connection is closed
+		closed := make(chan struct{})
+		pconn.EXPECT().Close().Do(func() error { close(closed); return nil })
+		cancel()
+		Eventually(closed).Should(BeClosed())
+	})
+
+	It("migrates when the local address changes", func() {
+		pconn := newMockPacketConn(1234)
+		newPconns <- pconn
+		conn.EXPECT().Migrate(gomock.Any(), pconn)
+		m.Start()
+		Consistently(events, scaleDuration(20*time.Millisecond)).ShouldNot(Receive())
+		setLocalIP(net.IPv4(10, 0, 0, 1))
+		var ev MigrationEvent
+		Eventually(events, 2*networkChangeCheckInterval).Should(Receive(&ev))
+		Expect(ev.Reason).To(Equal(MigrationReasonNetworkChange))
+		Expect(ev.Err).ToNot(HaveOccurred())
+		Expect(ev.NewLocalAddr).To(Equal(&net.UDPAddr{IP: net.IPv4zero, Port: 1234}))
+		pconn.EXPECT().Close().AnyTimes()
+	})
+
+	It("closes the old packet conn when migrating again", func() {
+		pconn1 := newMockPacketConn(1234)
+		pconn2 := newMockPacketConn(5678)
+		newPconns <- pconn1
+		newPconns <- pconn2
+		conn.EXPECT().Migrate(gomock.Any(), pconn1)
+		conn.EXPECT().Migrate(gomock.Any(), pconn2)
+		m.Start()
+		m.Trigger(MigrationReasonSendError)
+		Eventually(events).Should(Receive())
+		closed := make(chan struct{})
+		pconn1.EXPECT().Close().Do(func() error { close(closed); return nil })
+		m.Trigger(MigrationReasonSendError)
+		Eventually(events).Should(Receive())
+		Eventually(closed).Should(BeClosed())
+		pconn2.EXPECT().Close().AnyTimes()
+	})
+
+	It("reports failed migrations", func() {
+		pconn := newMockPacketConn(1234)
+		newPconns <- pconn
+		testErr := errors.New("path validation failed")
+		conn.EXPECT().Migrate(gomock.Any(), pconn).Return(testErr)
+		pconn.EXPECT().Close()
+		m.Start()
+		m.Trigger(MigrationReasonSendError)
+		var ev MigrationEvent
+		Eventually(events).Should(Receive(&ev))
+		Expect(ev.Err).To(MatchError(testErr))
+		Expect(ev.OldLocalAddr).To(Equal(localAddr))
+		Expect(ev.NewLocalAddr).To(BeNil())
+	})
+
+	It("reports errors when creating the new packet conn", func() {
+		m.Start()
+		m.Trigger(MigrationReasonSendError)
+		var ev MigrationEvent
+		Eventually(events).Should(Receive(&ev))
+		Expect(ev.Err).To(MatchError("no packet conn"))
+	})
+
+	It("determines the preferred local IP", func() {
+		ip, err := preferredLocalIP(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip.Equal(net.IPv4(127, 0, 0, 1))).To(BeTrue())
+	})
+})
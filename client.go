@@ -84,7 +84,11 @@ func DialAddrEarlyContext(
 	if err != nil {
 		return nil, err
 	}
-	utils.Logger.WithPrefix(utils.DefaultLogger, "client").Debugf("Returning early connection")
+	var logger logging.Logger
+	if config != nil {
+		logger = config.Logger
+	}
+	utils.GetLogger(logger).WithPrefix("client").Debugf("Returning early connection")
 	return conn, nil
 }
 
@@ -201,6 +205,10 @@ func dialContext(
 	if err != nil {
 		return nil, err
 	}
+	// Packets for a connection using a zero-length connection ID on a shared packet conn are demultiplexed by the remote address.
+	if config.ConnectionIDLength == 0 && !createdPacketConn {
+		packetHandlers = newRemoteAddrConnRunner(packetHandlers, remoteAddr)
+	}
 	c.packetHandlers = packetHandlers
 
 	c.tracingID = nextConnTracingID()
@@ -254,7 +262,7 @@ func newClient(
 		}
 	}
 
-	srcConnID, err := generateConnectionID(config.ConnectionIDLength)
+	srcConnID, err := config.ConnectionIDGenerator.GenerateConnectionID()
 	if err != nil {
 		return nil, err
 	}
@@ -272,7 +280,7 @@ func newClient(
 		config:            config,
 		version:           config.Versions[0],
 		handshakeChan:     make(chan struct{}),
-		logger:            utils.DefaultLogger.WithPrefix("client"),
+		logger:            utils.GetLogger(config.Logger).WithPrefix("client"),
 	}
 	return c, nil
 }
@@ -295,7 +303,9 @@ func (c *client) dial(ctx context.Context) error {
 		c.logger,
 		c.version,
 	)
-	c.packetHandlers.Add(c.srcConnID, c.conn)
+	if !c.packetHandlers.Add(c.srcConnID, c.conn) {
+		return fmt.Errorf("quic: a connection to %s already exists on this packet conn", c.sconn.RemoteAddr())
+	}
 
 	errorChan := make(chan error, 1)
 	go func() {
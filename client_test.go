@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 	"os"
 	"time"
@@ -113,7 +114,7 @@ var _ = Describe("Client", func() {
 			}
 
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 			manager.EXPECT().Destroy()
 			mockMultiplexer.EXPECT().AddConn(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
@@ -146,7 +147,7 @@ var _ = Describe("Client", func() {
 
 		It("uses the tls.Config.ServerName as the hostname, if present", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 			manager.EXPECT().Destroy()
 			mockMultiplexer.EXPECT().AddConn(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
@@ -180,7 +181,7 @@ var _ = Describe("Client", func() {
 
 		It("allows passing host without port as server name", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 			mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
 			hostnameChan := make(chan string, 1)
@@ -217,9 +218,121 @@ var _ = Describe("Client", func() {
 			Eventually(hostnameChan).Should(Receive(Equal("test.com")))
 		})
 
+		It("uses the remote address to identify connections using zero-length connection IDs on a packet conn", func() {
+			manager := NewMockPacketHandlerManager(mockCtrl)
+			manager.EXPECT().AddWithRemoteAddr(addr, gomock.Any()).Return(true)
+			mockMultiplexer.EXPECT().AddConn(packetConn, 0, gomock.Any(), gomock.Any()).Return(manager, nil)
+
+			runnerChan := make(chan connRunner, 1)
+			newClientConnection = func(
+				_ sendConn,
+				runner connRunner,
+				_ protocol.ConnectionID,
+				srcConnID protocol.ConnectionID,
+				_ *Config,
+				_ *tls.Config,
+				_ protocol.PacketNumber,
+				_ bool,
+				_ bool,
+				_ logging.ConnectionTracer,
+				_ uint64,
+				_ utils.Logger,
+				_ protocol.VersionNumber,
+			) quicConn {
+				Expect(srcConnID).To(BeEmpty())
+				runnerChan <- runner
+				conn := NewMockQuicConn(mockCtrl)
+				conn.EXPECT().HandshakeComplete().Return(context.Background())
+				conn.EXPECT().run()
+				return conn
+			}
+			tracer.EXPECT().StartedConnection(packetConn.LocalAddr(), addr, gomock.Any(), gomock.Any())
+			generateConnectionID = protocol.GenerateConnectionID
+			conf := config.Clone()
+			conf.ZeroLengthConnectionIDs = true
+			_, err := Dial(packetConn, addr, "localhost:1337", tlsConf, conf)
+			Expect(err).ToNot(HaveOccurred())
+			var runner connRunner
+			Eventually(runnerChan).Should(Receive(&runner))
+			Expect(runner).To(BeAssignableToTypeOf(&remoteAddrConnRunner{}))
+		})
+
+		It("refuses to dial a second connection to the same remote address using zero-length connection IDs", func() {
+			manager := NewMockPacketHandlerManager(mockCtrl)
+			manager.EXPECT().AddWithRemoteAddr(addr, gomock.Any()).Return(false)
+			mockMultiplexer.EXPECT().AddConn(packetConn, 0, gomock.Any(), gomock.Any()).Return(manager, nil)
+
+			newClientConnection = func(
+				_ sendConn,
+				_ connRunner,
+				_ protocol.ConnectionID,
+				_ protocol.ConnectionID,
+				_ *Config,
+				_ *tls.Config,
+				_ protocol.PacketNumber,
+				_ bool,
+				_ bool,
+				_ logging.ConnectionTracer,
+				_ uint64,
+				_ utils.Logger,
+				_ protocol.VersionNumber,
+			) quicConn {
+				return NewMockQuicConn(mockCtrl)
+			}
+			tracer.EXPECT().StartedConnection(packetConn.LocalAddr(), addr, gomock.Any(), gomock.Any())
+			generateConnectionID = protocol.GenerateConnectionID
+			conf := config.Clone()
+			conf.ZeroLengthConnectionIDs = true
+			_, err := Dial(packetConn, addr, "localhost:1337", tlsConf, conf)
+			Expect(err).To(MatchError(fmt.Sprintf("quic: a connection to %s already exists on this packet conn", addr)))
+		})
+
+		It("uses the logger from the config", func() {
+			manager := NewMockPacketHandlerManager(mockCtrl)
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
+			mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
+
+			logger := mocklogging.NewMockLogger(mockCtrl)
+			clientLogger := mocklogging.NewMockLogger(mockCtrl)
+			logger.EXPECT().With("scope", "client").Return(clientLogger)
+			clientLogger.EXPECT().Enabled(logging.LogLevelError).Return(true)
+			clientLogger.EXPECT().Log(logging.LogLevelError, "foobar")
+			clientLogger.EXPECT().Enabled(gomock.Any()).Return(false).AnyTimes()
+			config.Logger = logger
+
+			run := make(chan struct{})
+			newClientConnection = func(
+				_ sendConn,
+				_ connRunner,
+				_ protocol.ConnectionID,
+				_ protocol.ConnectionID,
+				_ *Config,
+				_ *tls.Config,
+				_ protocol.PacketNumber,
+				_ bool,
+				_ bool,
+				_ logging.ConnectionTracer,
+				_ uint64,
+				l utils.Logger,
+				_ protocol.VersionNumber,
+			) quicConn {
+				l.Errorf("foo%s", "bar")
+				conn := NewMockQuicConn(mockCtrl)
+				conn.EXPECT().run().Do(func() { close(run) })
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				conn.EXPECT().HandshakeComplete().Return(ctx)
+				return conn
+			}
+			tracer.EXPECT().StartedConnection(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			_, err := Dial(packetConn, addr, "localhost:1337", tlsConf, config)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(run).Should(BeClosed())
+		})
+
 		It("returns after the handshake is complete", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 			mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
 			run := make(chan struct{})
@@ -261,7 +374,7 @@ var _ = Describe("Client", func() {
 
 		It("returns early connections", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 			mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
 			readyChan := make(chan struct{})
@@ -310,7 +423,7 @@ var _ = Describe("Client", func() {
 
 		It("returns an error that occurs while waiting for the handshake to complete", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 			mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
 			testErr := errors.New("early handshake error")
@@ -347,7 +460,7 @@ var _ = Describe("Client", func() {
 
 		It("closes the connection when the context is canceled", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 			mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
 			connRunning := make(chan struct{})
@@ -403,7 +516,7 @@ var _ = Describe("Client", func() {
 
 			manager := NewMockPacketHandlerManager(mockCtrl)
 			mockMultiplexer.EXPECT().AddConn(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
-			manager.EXPECT().Add(gomock.Any(), gomock.Any())
+			manager.EXPECT().Add(gomock.Any(), gomock.Any()).Return(true)
 
 			var sconn sendConn
 			run := make(chan struct{})
@@ -521,7 +634,7 @@ var _ = Describe("Client", func() {
 
 		It("creates new connections with the right parameters", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(connID, gomock.Any())
+			manager.EXPECT().Add(connID, gomock.Any()).Return(true)
 			mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
 			config := &Config{Versions: []protocol.VersionNumber{protocol.VersionTLS}}
@@ -564,7 +677,7 @@ var _ = Describe("Client", func() {
 
 		It("creates a new connections after version negotiation", func() {
 			manager := NewMockPacketHandlerManager(mockCtrl)
-			manager.EXPECT().Add(connID, gomock.Any()).Times(2)
+			manager.EXPECT().Add(connID, gomock.Any()).Return(true).Times(2)
 			manager.EXPECT().Destroy()
 			mockMultiplexer.EXPECT().AddConn(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(manager, nil)
 
@@ -2,8 +2,10 @@ package quic
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -29,6 +31,53 @@ func validateConfig(config *Config) error {
 	if config.MaxIncomingUniStreams > 1<<60 {
 		return errors.New("invalid value for Config.MaxIncomingUniStreams")
 	}
+	if config.ConnectionIDGenerator != nil {
+		if l := config.ConnectionIDGenerator.ConnectionIDLen(); l < 4 || l > 18 {
+			return fmt.Errorf("invalid connection ID length for Config.ConnectionIDGenerator: %d", l)
+		}
+	}
+	if config.ZeroLengthConnectionIDs && (config.ConnectionIDLength != 0 || config.ConnectionIDGenerator != nil || config.EnableMultipath) {
+		return errors.New("Config.ZeroLengthConnectionIDs can't be combined with Config.ConnectionIDLength, Config.ConnectionIDGenerator or Config.EnableMultipath")
+	}
+	if config.ActiveConnectionIDLimit < 0 || config.ActiveConnectionIDLimit == 1 {
+		return errors.New("invalid value for Config.ActiveConnectionIDLimit")
+	}
+	if config.PacketsPerConnectionID > 1<<30 {
+		return errors.New("invalid value for Config.PacketsPerConnectionID")
+	}
+	if config.CongestionControl > CongestionControlCustom {
+		return errors.New("invalid value for Config.CongestionControl")
+	}
+	if (config.CongestionControl == CongestionControlCustom) != (config.NewCongestionController != nil) {
+		return errors.New("Config.NewCongestionController must be set if and only if Config.CongestionControl is CongestionControlCustom")
+	}
+	if config.MultipathScheduling > MultipathSchedulingCustom {
+		return errors.New("invalid value for Config.MultipathScheduling")
+	}
+	if (config.MultipathScheduling == MultipathSchedulingCustom) != (config.NewMultipathScheduler != nil) {
+		return errors.New("Config.NewMultipathScheduler must be set if and only if Config.MultipathScheduling is MultipathSchedulingCustom")
+	}
+	if config.InitialCongestionWindow < 0 || config.InitialCongestionWindow > protocol.MaxCongestionWindowPackets {
+		return errors.New("invalid value for Config.InitialCongestionWindow")
+	}
+	if config.MinCongestionWindow < 0 || config.MinCongestionWindow > protocol.MaxCongestionWindowPackets {
+		return errors.New("invalid value for Config.MinCongestionWindow")
+	}
+	if config.InitialCongestionWindow > 0 && config.MinCongestionWindow > config.InitialCongestionWindow {
+		return errors.New("Config.MinCongestionWindow must not be larger than Config.InitialCongestionWindow")
+	}
+	if config.DatagramSendQueueLen < 0 {
+		return errors.New("invalid value for Config.DatagramSendQueueLen")
+	}
+	if config.DatagramReceiveQueueLen < 0 {
+		return errors.New("invalid value for Config.DatagramReceiveQueueLen")
+	}
+	if config.DatagramDropPolicy > DatagramDropOldest {
+		return errors.New("invalid value for Config.DatagramDropPolicy")
+	}
+	if config.DatagramPriority > DatagramPriorityBackground {
+		return errors.New("invalid value for Config.DatagramPriority")
+	}
 	return nil
 }
 
@@ -42,6 +91,7 @@ func populateServerConfig(config *Config) *Config {
 	if config.AcceptToken == nil {
 		config.AcceptToken = defaultAcceptToken
 	}
+	populateConnIDGenerator(config)
 	return config
 }
 
@@ -49,12 +99,21 @@ func populateServerConfig(config *Config) *Config {
 // it may be called with nil
 func populateClientConfig(config *Config, createdPacketConn bool) *Config {
 	config = populateConfig(config)
-	if config.ConnectionIDLength == 0 && !createdPacketConn {
+	// Multipath uses connection IDs to identify paths.
+	if config.ConnectionIDLength == 0 && !config.ZeroLengthConnectionIDs && (!createdPacketConn || config.EnableMultipath) {
 		config.ConnectionIDLength = protocol.DefaultConnectionIDLength
 	}
+	populateConnIDGenerator(config)
 	return config
 }
 
+// populateConnIDGenerator sets a generator for random connection IDs, if no ConnectionIDGenerator is set.
+func populateConnIDGenerator(config *Config) {
+	if config.ConnectionIDGenerator == nil {
+		config.ConnectionIDGenerator = &randomConnIDGenerator{connIDLen: config.ConnectionIDLength}
+	}
+}
+
 func populateConfig(config *Config) *Config {
 	if config == nil {
 		config = &Config{}
@@ -93,18 +152,40 @@ func populateConfig(config *Config) *Config {
 	} else if maxIncomingStreams < 0 {
 		maxIncomingStreams = 0
 	}
+	connIDLen := config.ConnectionIDLength
+	if config.ConnectionIDGenerator != nil {
+		connIDLen = config.ConnectionIDGenerator.ConnectionIDLen()
+	}
+	activeConnIDLimit := config.ActiveConnectionIDLimit
+	if activeConnIDLimit == 0 {
+		activeConnIDLimit = protocol.DefaultActiveConnectionIDLimit
+	}
+	packetsPerConnID := config.PacketsPerConnectionID
+	if packetsPerConnID == 0 {
+		packetsPerConnID = protocol.DefaultPacketsPerConnectionID
+	}
 	maxIncomingUniStreams := config.MaxIncomingUniStreams
 	if maxIncomingUniStreams == 0 {
 		maxIncomingUniStreams = protocol.DefaultMaxIncomingUniStreams
 	} else if maxIncomingUniStreams < 0 {
 		maxIncomingUniStreams = 0
 	}
+	datagramSendQueueLen := config.DatagramSendQueueLen
+	if datagramSendQueueLen == 0 {
+		datagramSendQueueLen = protocol.DatagramSendQueueLen
+	}
+	datagramRcvQueueLen := config.DatagramReceiveQueueLen
+	if datagramRcvQueueLen == 0 {
+		datagramRcvQueueLen = protocol.DatagramRcvQueueLen
+	}
 
 	return &Config{
 		Versions:                         versions,
 		HandshakeIdleTimeout:             handshakeIdleTimeout,
 		MaxIdleTimeout:                   idleTimeout,
 		AcceptToken:                      config.AcceptToken,
+		RequireAddressValidation:         config.RequireAddressValidation,
+		TokenGenerator:                   config.TokenGenerator,
 		KeepAlive:                        config.KeepAlive,
 		InitialStreamReceiveWindow:       initialStreamReceiveWindow,
 		MaxStreamReceiveWindow:           maxStreamReceiveWindow,
@@ -113,12 +194,45 @@ func populateConfig(config *Config) *Config {
 		AllowConnectionWindowIncrease:    config.AllowConnectionWindowIncrease,
 		MaxIncomingStreams:               maxIncomingStreams,
 		MaxIncomingUniStreams:            maxIncomingUniStreams,
-		ConnectionIDLength:               config.ConnectionIDLength,
+		ConnectionIDLength:               connIDLen,
+		ConnectionIDGenerator:            config.ConnectionIDGenerator,
+		ZeroLengthConnectionIDs:          config.ZeroLengthConnectionIDs,
+		ActiveConnectionIDLimit:          activeConnIDLimit,
+		PacketsPerConnectionID:           packetsPerConnID,
 		StatelessResetKey:                config.StatelessResetKey,
 		TokenStore:                       config.TokenStore,
 		EnableDatagrams:                  config.EnableDatagrams,
+		DatagramSendQueueLen:             datagramSendQueueLen,
+		DatagramReceiveQueueLen:          datagramRcvQueueLen,
+		DatagramDropPolicy:               config.DatagramDropPolicy,
+		DatagramPriority:                 config.DatagramPriority,
+		EnableAckFrequency:               config.EnableAckFrequency,
+		EnableAutomaticMigration:         config.EnableAutomaticMigration,
+		ConnectionMigrated:               config.ConnectionMigrated,
+		EnableActiveMigration:            config.EnableActiveMigration,
+		EnableMultipath:                  config.EnableMultipath,
+		EnablePartialReliability:         config.EnablePartialReliability,
+		EnableReliableStreamReset:        config.EnableReliableStreamReset,
+		MultipathScheduling:              config.MultipathScheduling,
+		NewMultipathScheduler:            config.NewMultipathScheduler,
 		DisablePathMTUDiscovery:          config.DisablePathMTUDiscovery,
+		CongestionControl:                config.CongestionControl,
+		NewCongestionController:          config.NewCongestionController,
+		InitialCongestionWindow:          config.InitialCongestionWindow,
+		MinCongestionWindow:              config.MinCongestionWindow,
 		DisableVersionNegotiationPackets: config.DisableVersionNegotiationPackets,
+		TLSBackend:                       config.TLSBackend,
 		Tracer:                           config.Tracer,
+		Logger:                           config.Logger,
+	}
+}
+
+// congestionConfig returns the configuration of the congestion controller.
+func congestionConfig(config *Config) congestion.Config {
+	return congestion.Config{
+		Algorithm:            config.CongestionControl,
+		InitialWindowPackets: protocol.ByteCount(config.InitialCongestionWindow),
+		MinWindowPackets:     protocol.ByteCount(config.MinCongestionWindow),
+		NewCustom:            config.NewCongestionController,
 	}
 }
@@ -8,6 +8,7 @@ import (
 
 	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/logging"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -30,6 +31,77 @@ var _ = Describe("Config", func() {
 		It("errors on too large values for MaxIncomingUniStreams", func() {
 			Expect(validateConfig(&Config{MaxIncomingUniStreams: 1<<60 + 1})).To(MatchError("invalid value for Config.MaxIncomingUniStreams"))
 		})
+
+		It("validates the length of generated connection IDs", func() {
+			Expect(validateConfig(&Config{ConnectionIDGenerator: &randomConnIDGenerator{connIDLen: 8}})).To(Succeed())
+			Expect(validateConfig(&Config{ConnectionIDGenerator: &randomConnIDGenerator{connIDLen: 3}})).To(MatchError("invalid connection ID length for Config.ConnectionIDGenerator: 3"))
+			Expect(validateConfig(&Config{ConnectionIDGenerator: &randomConnIDGenerator{connIDLen: 19}})).To(MatchError("invalid connection ID length for Config.ConnectionIDGenerator: 19"))
+		})
+
+		It("doesn't allow combining zero-length connection IDs with other connection ID settings", func() {
+			Expect(validateConfig(&Config{ZeroLengthConnectionIDs: true})).To(Succeed())
+			const errMsg = "Config.ZeroLengthConnectionIDs can't be combined with Config.ConnectionIDLength, Config.ConnectionIDGenerator or Config.EnableMultipath"
+			Expect(validateConfig(&Config{ZeroLengthConnectionIDs: true, ConnectionIDLength: 4})).To(MatchError(errMsg))
+			Expect(validateConfig(&Config{ZeroLengthConnectionIDs: true, ConnectionIDGenerator: &randomConnIDGenerator{connIDLen: 8}})).To(MatchError(errMsg))
+			Expect(validateConfig(&Config{ZeroLengthConnectionIDs: true, EnableMultipath: true})).To(MatchError(errMsg))
+		})
+
+		It("validates the active connection ID limit", func() {
+			Expect(validateConfig(&Config{ActiveConnectionIDLimit: 2})).To(Succeed())
+			Expect(validateConfig(&Config{ActiveConnectionIDLimit: 1})).To(MatchError("invalid value for Config.ActiveConnectionIDLimit"))
+			Expect(validateConfig(&Config{ActiveConnectionIDLimit: -1})).To(MatchError("invalid value for Config.ActiveConnectionIDLimit"))
+		})
+
+		It("errors on too large values for PacketsPerConnectionID", func() {
+			Expect(validateConfig(&Config{PacketsPerConnectionID: -1})).To(Succeed())
+			Expect(validateConfig(&Config{PacketsPerConnectionID: 1<<30 + 1})).To(MatchError("invalid value for Config.PacketsPerConnectionID"))
+		})
+
+		It("validates the congestion control algorithm", func() {
+			Expect(validateConfig(&Config{CongestionControl: CongestionControlBBR})).To(Succeed())
+			Expect(validateConfig(&Config{CongestionControl: CongestionControlCubic})).To(Succeed())
+			Expect(validateConfig(&Config{CongestionControl: 42})).To(MatchError("invalid value for Config.CongestionControl"))
+		})
+
+		It("requires a constructor for custom congestion controllers", func() {
+			newCC := func(*logging.RTTStats, logging.ByteCount) CongestionController { return nil }
+			Expect(validateConfig(&Config{CongestionControl: CongestionControlCustom, NewCongestionController: newCC})).To(Succeed())
+			Expect(validateConfig(&Config{CongestionControl: CongestionControlCustom})).To(MatchError("Config.NewCongestionController must be set if and only if Config.CongestionControl is CongestionControlCustom"))
+			Expect(validateConfig(&Config{CongestionControl: CongestionControlBBR, NewCongestionController: newCC})).To(MatchError("Config.NewCongestionController must be set if and only if Config.CongestionControl is CongestionControlCustom"))
+		})
+
+		It("validates the multipath scheduling policy", func() {
+			Expect(validateConfig(&Config{MultipathScheduling: MultipathSchedulingRoundRobin})).To(Succeed())
+			Expect(validateConfig(&Config{MultipathScheduling: 42})).To(MatchError("invalid value for Config.MultipathScheduling"))
+		})
+
+		It("requires a constructor for custom multipath schedulers", func() {
+			newScheduler := func() MultipathScheduler { return nil }
+			Expect(validateConfig(&Config{MultipathScheduling: MultipathSchedulingCustom, NewMultipathScheduler: newScheduler})).To(Succeed())
+			Expect(validateConfig(&Config{MultipathScheduling: MultipathSchedulingCustom})).To(MatchError("Config.NewMultipathScheduler must be set if and only if Config.MultipathScheduling is MultipathSchedulingCustom"))
+			Expect(validateConfig(&Config{NewMultipathScheduler: newScheduler})).To(MatchError("Config.NewMultipathScheduler must be set if and only if Config.MultipathScheduling is MultipathSchedulingCustom"))
+		})
+
+		It("validates the congestion windows", func() {
+			Expect(validateConfig(&Config{InitialCongestionWindow: 10, MinCongestionWindow: 10})).To(Succeed())
+			Expect(validateConfig(&Config{MinCongestionWindow: 40})).To(Succeed())
+			Expect(validateConfig(&Config{InitialCongestionWindow: -1})).To(MatchError("invalid value for Config.InitialCongestionWindow"))
+			Expect(validateConfig(&Config{InitialCongestionWindow: protocol.MaxCongestionWindowPackets + 1})).To(MatchError("invalid value for Config.InitialCongestionWindow"))
+			Expect(validateConfig(&Config{MinCongestionWindow: -1})).To(MatchError("invalid value for Config.MinCongestionWindow"))
+			Expect(validateConfig(&Config{InitialCongestionWindow: 10, MinCongestionWindow: 11})).To(MatchError("Config.MinCongestionWindow must not be larger than Config.InitialCongestionWindow"))
+		})
+
+		It("validates the datagram queues", func() {
+			Expect(validateConfig(&Config{DatagramSendQueueLen: 10, DatagramReceiveQueueLen: 10, DatagramDropPolicy: DatagramDropOldest})).To(Succeed())
+			Expect(validateConfig(&Config{DatagramSendQueueLen: -1})).To(MatchError("invalid value for Config.DatagramSendQueueLen"))
+			Expect(validateConfig(&Config{DatagramReceiveQueueLen: -1})).To(MatchError("invalid value for Config.DatagramReceiveQueueLen"))
+			Expect(validateConfig(&Config{DatagramDropPolicy: 42})).To(MatchError("invalid value for Config.DatagramDropPolicy"))
+		})
+
+		It("validates the datagram priority", func() {
+			Expect(validateConfig(&Config{DatagramPriority: DatagramPriorityBackground})).To(Succeed())
+			Expect(validateConfig(&Config{DatagramPriority: 42})).To(MatchError("invalid value for Config.DatagramPriority"))
+		})
 	})
 
 	configWithNonZeroNonFunctionFields := func() *Config {
@@ -45,12 +117,16 @@ var _ = Describe("Config", func() {
 			}
 
 			switch fn := typ.Field(i).Name; fn {
-			case "AcceptToken", "GetLogWriter", "AllowConnectionWindowIncrease":
+			case "AcceptToken", "RequireAddressValidation", "GetLogWriter", "AllowConnectionWindowIncrease", "NewCongestionController", "ConnectionMigrated", "NewMultipathScheduler":
 				// Can't compare functions.
 			case "Versions":
 				f.Set(reflect.ValueOf([]VersionNumber{1, 2, 3}))
 			case "ConnectionIDLength":
 				f.Set(reflect.ValueOf(8))
+			case "TokenGenerator":
+				f.Set(reflect.ValueOf(&tokenGenerator{}))
+			case "ConnectionIDGenerator":
+				f.Set(reflect.ValueOf(&randomConnIDGenerator{connIDLen: 8}))
 			case "HandshakeIdleTimeout":
 				f.Set(reflect.ValueOf(time.Second))
 			case "MaxIdleTimeout":
@@ -75,12 +151,50 @@ var _ = Describe("Config", func() {
 				f.Set(reflect.ValueOf(true))
 			case "EnableDatagrams":
 				f.Set(reflect.ValueOf(true))
+			case "DatagramSendQueueLen":
+				f.Set(reflect.ValueOf(10))
+			case "DatagramReceiveQueueLen":
+				f.Set(reflect.ValueOf(20))
+			case "DatagramDropPolicy":
+				f.Set(reflect.ValueOf(DatagramDropOldest))
+			case "DatagramPriority":
+				f.Set(reflect.ValueOf(DatagramPriorityFairShare))
+			case "EnableAckFrequency":
+				f.Set(reflect.ValueOf(true))
+			case "EnableAutomaticMigration":
+				f.Set(reflect.ValueOf(true))
+			case "EnableActiveMigration":
+				f.Set(reflect.ValueOf(true))
+			case "ZeroLengthConnectionIDs":
+				f.Set(reflect.ValueOf(true))
+			case "ActiveConnectionIDLimit":
+				f.Set(reflect.ValueOf(8))
+			case "PacketsPerConnectionID":
+				f.Set(reflect.ValueOf(1000))
+			case "EnableMultipath":
+				f.Set(reflect.ValueOf(true))
+			case "EnablePartialReliability":
+				f.Set(reflect.ValueOf(true))
+			case "EnableReliableStreamReset":
+				f.Set(reflect.ValueOf(true))
+			case "MultipathScheduling":
+				f.Set(reflect.ValueOf(MultipathSchedulingRoundRobin))
 			case "DisableVersionNegotiationPackets":
 				f.Set(reflect.ValueOf(true))
 			case "DisablePathMTUDiscovery":
 				f.Set(reflect.ValueOf(true))
+			case "CongestionControl":
+				f.Set(reflect.ValueOf(CongestionControlBBR))
+			case "InitialCongestionWindow":
+				f.Set(reflect.ValueOf(20))
+			case "MinCongestionWindow":
+				f.Set(reflect.ValueOf(5))
+			case "TLSBackend":
+				f.Set(reflect.ValueOf(DefaultTLSBackend))
 			case "Tracer":
 				f.Set(reflect.ValueOf(mocklogging.NewMockTracer(mockCtrl)))
+			case "Logger":
+				f.Set(reflect.ValueOf(mocklogging.NewMockLogger(mockCtrl)))
 			default:
 				Fail(fmt.Sprintf("all fields must be accounted for, but saw unknown field %q", fn))
 			}
@@ -100,16 +214,25 @@ var _ = Describe("Config", func() {
 
 	Context("cloning", func() {
 		It("clones function fields", func() {
-			var calledAcceptToken, calledAllowConnectionWindowIncrease bool
+			var calledAcceptToken, calledRequireAddressValidation, calledAllowConnectionWindowIncrease, calledConnectionMigrated, calledNewMultipathScheduler bool
 			c1 := &Config{
 				AcceptToken:                   func(_ net.Addr, _ *Token) bool { calledAcceptToken = true; return true },
+				RequireAddressValidation:      func(net.Addr) bool { calledRequireAddressValidation = true; return true },
 				AllowConnectionWindowIncrease: func(Connection, uint64) bool { calledAllowConnectionWindowIncrease = true; return true },
+				ConnectionMigrated:            func(Connection, MigrationEvent) { calledConnectionMigrated = true },
+				NewMultipathScheduler:         func() MultipathScheduler { calledNewMultipathScheduler = true; return nil },
 			}
 			c2 := c1.Clone()
 			c2.AcceptToken(&net.UDPAddr{}, &Token{})
 			Expect(calledAcceptToken).To(BeTrue())
+			c2.RequireAddressValidation(&net.UDPAddr{})
+			Expect(calledRequireAddressValidation).To(BeTrue())
 			c2.AllowConnectionWindowIncrease(nil, 1234)
 			Expect(calledAllowConnectionWindowIncrease).To(BeTrue())
+			c2.ConnectionMigrated(nil, MigrationEvent{})
+			Expect(calledConnectionMigrated).To(BeTrue())
+			c2.NewMultipathScheduler()
+			Expect(calledNewMultipathScheduler).To(BeTrue())
 		})
 
 		It("clones non-function fields", func() {
@@ -142,6 +265,21 @@ var _ = Describe("Config", func() {
 			Expect(calledAcceptToken).To(BeTrue())
 		})
 
+		It("populates the congestion controller constructor", func() {
+			var called bool
+			c := populateConfig(&Config{
+				CongestionControl: CongestionControlCustom,
+				NewCongestionController: func(*logging.RTTStats, logging.ByteCount) CongestionController {
+					called = true
+					return nil
+				},
+			})
+			conf := congestionConfig(c)
+			Expect(conf.Algorithm).To(Equal(CongestionControlCustom))
+			conf.NewCustom(nil, 1234)
+			Expect(called).To(BeTrue())
+		})
+
 		It("copies non-function fields", func() {
 			c := configWithNonZeroNonFunctionFields()
 			Expect(populateConfig(c)).To(Equal(c))
@@ -157,8 +295,17 @@ var _ = Describe("Config", func() {
 			Expect(c.MaxConnectionReceiveWindow).To(BeEquivalentTo(protocol.DefaultMaxReceiveConnectionFlowControlWindow))
 			Expect(c.MaxIncomingStreams).To(BeEquivalentTo(protocol.DefaultMaxIncomingStreams))
 			Expect(c.MaxIncomingUniStreams).To(BeEquivalentTo(protocol.DefaultMaxIncomingUniStreams))
+			Expect(c.ActiveConnectionIDLimit).To(Equal(protocol.DefaultActiveConnectionIDLimit))
+			Expect(c.PacketsPerConnectionID).To(Equal(protocol.DefaultPacketsPerConnectionID))
 			Expect(c.DisableVersionNegotiationPackets).To(BeFalse())
 			Expect(c.DisablePathMTUDiscovery).To(BeFalse())
+			Expect(c.CongestionControl).To(Equal(CongestionControlNewReno))
+			Expect(c.InitialCongestionWindow).To(BeZero())
+			Expect(c.MinCongestionWindow).To(BeZero())
+			Expect(c.DatagramSendQueueLen).To(Equal(protocol.DatagramSendQueueLen))
+			Expect(c.DatagramReceiveQueueLen).To(Equal(protocol.DatagramRcvQueueLen))
+			Expect(c.DatagramDropPolicy).To(Equal(DatagramDropNewest))
+			Expect(c.DatagramPriority).To(Equal(DatagramPriorityAlwaysFirst))
 		})
 
 		It("populates empty fields with default values, for the server", func() {
@@ -176,5 +323,31 @@ var _ = Describe("Config", func() {
 			c := populateClientConfig(&Config{}, true)
 			Expect(c.ConnectionIDLength).To(BeZero())
 		})
+
+		It("doesn't set a default connection ID length if zero-length connection IDs are used, for the client", func() {
+			c := populateClientConfig(&Config{ZeroLengthConnectionIDs: true}, false)
+			Expect(c.ConnectionIDLength).To(BeZero())
+		})
+
+		It("sets a default connection ID length if multipath is enabled, for the client", func() {
+			c := populateClientConfig(&Config{EnableMultipath: true}, true)
+			Expect(c.ConnectionIDLength).To(Equal(protocol.DefaultConnectionIDLength))
+		})
+
+		It("uses the connection ID length of the connection ID generator", func() {
+			gen := &mockConnIDGenerator{connIDLen: 12}
+			c := populateServerConfig(&Config{ConnectionIDLength: 8, ConnectionIDGenerator: gen})
+			Expect(c.ConnectionIDLength).To(Equal(12))
+			Expect(c.ConnectionIDGenerator).To(Equal(gen))
+			c = populateClientConfig(&Config{ConnectionIDGenerator: gen}, true)
+			Expect(c.ConnectionIDLength).To(Equal(12))
+		})
+
+		It("uses random connection IDs if no connection ID generator is set", func() {
+			c := populateServerConfig(&Config{})
+			Expect(c.ConnectionIDGenerator).To(Equal(&randomConnIDGenerator{connIDLen: protocol.DefaultConnectionIDLength}))
+			c = populateClientConfig(&Config{}, true)
+			Expect(c.ConnectionIDGenerator).To(Equal(&randomConnIDGenerator{connIDLen: 0}))
+		})
 	})
 })
@@ -9,7 +9,22 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
 
+// The randomConnIDGenerator generates random connection IDs.
+// It is used if no Config.ConnectionIDGenerator is set.
+type randomConnIDGenerator struct {
+	connIDLen int
+}
+
+var _ ConnectionIDGenerator = &randomConnIDGenerator{}
+
+func (g *randomConnIDGenerator) GenerateConnectionID() (ConnectionID, error) {
+	return generateConnectionID(g.connIDLen)
+}
+
+func (g *randomConnIDGenerator) ConnectionIDLen() int { return g.connIDLen }
+
 type connIDGenerator struct {
+	generator  ConnectionIDGenerator
 	connIDLen  int
 	highestSeq uint64
 
@@ -29,6 +44,7 @@ type connIDGenerator struct {
 func newConnIDGenerator(
 	initialConnectionID protocol.ConnectionID,
 	initialClientDestConnID protocol.ConnectionID, // nil for the client
+	generator ConnectionIDGenerator,
 	addConnectionID func(protocol.ConnectionID),
 	getStatelessResetToken func(protocol.ConnectionID) protocol.StatelessResetToken,
 	removeConnectionID func(protocol.ConnectionID),
@@ -38,6 +54,7 @@ func newConnIDGenerator(
 	version protocol.VersionNumber,
 ) *connIDGenerator {
 	m := &connIDGenerator{
+		generator:              generator,
 		connIDLen:              initialConnectionID.Len(),
 		activeSrcConnIDs:       make(map[uint64]protocol.ConnectionID),
 		addConnectionID:        addConnectionID,
@@ -99,10 +116,13 @@ func (m *connIDGenerator) Retire(seq uint64, sentWithDestConnID protocol.Connect
 }
 
 func (m *connIDGenerator) issueNewConnID() error {
-	connID, err := protocol.GenerateConnectionID(m.connIDLen)
+	connID, err := m.generator.GenerateConnectionID()
 	if err != nil {
 		return err
 	}
+	if connID.Len() != m.connIDLen {
+		return fmt.Errorf("generated connection ID has invalid length %d (expected %d)", connID.Len(), m.connIDLen)
+	}
 	m.activeSrcConnIDs[m.highestSeq+1] = connID
 	m.addConnectionID(connID)
 	m.queueControlFrame(&wire.NewConnectionIDFrame{
@@ -121,6 +141,34 @@ func (m *connIDGenerator) SetHandshakeComplete() {
 	}
 }
 
+// ActiveConnIDs returns all connection IDs that the peer might use to send packets to us.
+// It is used when migrating the connection to a new path.
+func (m *connIDGenerator) ActiveConnIDs() []protocol.ConnectionID {
+	connIDs := make([]protocol.ConnectionID, 0, len(m.activeSrcConnIDs)+1)
+	if m.initialClientDestConnID != nil {
+		connIDs = append(connIDs, m.initialClientDestConnID)
+	}
+	for _, connID := range m.activeSrcConnIDs {
+		connIDs = append(connIDs, connID)
+	}
+	return connIDs
+}
+
+// SequenceNumber returns the sequence number of one of our connection IDs.
+// When using multipath, the sequence number identifies the path that a packet was received on.
+// The connection ID chosen by the client for the first Initial is treated as sequence number 0.
+func (m *connIDGenerator) SequenceNumber(connID protocol.ConnectionID) (uint64, bool) {
+	if m.initialClientDestConnID != nil && m.initialClientDestConnID.Equal(connID) {
+		return 0, true
+	}
+	for seq, c := range m.activeSrcConnIDs {
+		if c.Equal(connID) {
+			return seq, true
+		}
+	}
+	return 0, false
+}
+
 func (m *connIDGenerator) RemoveAll() {
 	if m.initialClientDestConnID != nil {
 		m.removeConnectionID(m.initialClientDestConnID)
@@ -11,6 +11,14 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+type mockConnIDGenerator struct {
+	connIDLen int
+	generate  func() (ConnectionID, error)
+}
+
+func (g *mockConnIDGenerator) GenerateConnectionID() (ConnectionID, error) { return g.generate() }
+func (g *mockConnIDGenerator) ConnectionIDLen() int                        { return g.connIDLen }
+
 var _ = Describe("Connection ID Generator", func() {
 	var (
 		addedConnIDs       []protocol.ConnectionID
@@ -36,6 +44,7 @@ var _ = Describe("Connection ID Generator", func() {
 		g = newConnIDGenerator(
 			initialConnID,
 			initialClientDestConnID,
+			&randomConnIDGenerator{connIDLen: initialConnID.Len()},
 			func(c protocol.ConnectionID) { addedConnIDs = append(addedConnIDs, c) },
 			connIDToToken,
 			func(c protocol.ConnectionID) { removedConnIDs = append(removedConnIDs, c) },
@@ -64,6 +73,32 @@ var _ = Describe("Connection ID Generator", func() {
 		}
 	})
 
+	It("uses the connection ID generator", func() {
+		var counter byte
+		g.generator = &mockConnIDGenerator{
+			connIDLen: 7,
+			generate: func() (ConnectionID, error) {
+				counter++
+				return protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef, 0, 0, counter}, nil
+			},
+		}
+		Expect(g.SetMaxActiveConnIDs(3)).To(Succeed())
+		Expect(addedConnIDs).To(Equal([]protocol.ConnectionID{
+			{0xde, 0xad, 0xbe, 0xef, 0, 0, 1},
+			{0xde, 0xad, 0xbe, 0xef, 0, 0, 2},
+		}))
+		Expect(queuedFrames).To(HaveLen(2))
+		Expect(queuedFrames[1].(*wire.NewConnectionIDFrame).ConnectionID).To(Equal(protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef, 0, 0, 2}))
+	})
+
+	It("errors if the connection ID generator returns a connection ID of the wrong length", func() {
+		g.generator = &mockConnIDGenerator{
+			connIDLen: 7,
+			generate:  func() (ConnectionID, error) { return protocol.ConnectionID{1, 2, 3, 4}, nil },
+		}
+		Expect(g.SetMaxActiveConnIDs(3)).To(MatchError("generated connection ID has invalid length 4 (expected 7)"))
+	})
+
 	It("limits the number of connection IDs that it issues", func() {
 		Expect(g.SetMaxActiveConnIDs(9999999)).To(Succeed())
 		Expect(retiredConnIDs).To(BeEmpty())
@@ -171,6 +206,37 @@ var _ = Describe("Connection ID Generator", func() {
 		}
 	})
 
+	It("returns all active connection IDs", func() {
+		Expect(g.SetMaxActiveConnIDs(5)).To(Succeed())
+		Expect(queuedFrames).To(HaveLen(4))
+		connIDs := g.ActiveConnIDs()
+		Expect(connIDs).To(HaveLen(6)) // initial conn ID, initial client dest conn id, and newly issued ones
+		Expect(connIDs).To(ContainElement(initialConnID))
+		Expect(connIDs).To(ContainElement(initialClientDestConnID))
+		for _, f := range queuedFrames {
+			Expect(connIDs).To(ContainElement(f.(*wire.NewConnectionIDFrame).ConnectionID))
+		}
+	})
+
+	It("returns the sequence number of a connection ID", func() {
+		Expect(g.SetMaxActiveConnIDs(5)).To(Succeed())
+		Expect(queuedFrames).To(HaveLen(4))
+		seq, ok := g.SequenceNumber(initialConnID)
+		Expect(ok).To(BeTrue())
+		Expect(seq).To(BeZero())
+		seq, ok = g.SequenceNumber(initialClientDestConnID)
+		Expect(ok).To(BeTrue())
+		Expect(seq).To(BeZero())
+		for _, f := range queuedFrames {
+			nf := f.(*wire.NewConnectionIDFrame)
+			seq, ok := g.SequenceNumber(nf.ConnectionID)
+			Expect(ok).To(BeTrue())
+			Expect(seq).To(Equal(nf.SequenceNumber))
+		}
+		_, ok = g.SequenceNumber(protocol.ConnectionID{0xde, 0xca, 0xfb, 0xad})
+		Expect(ok).To(BeFalse())
+	})
+
 	It("replaces with a closed connection for all connection IDs", func() {
 		Expect(g.SetMaxActiveConnIDs(5)).To(Succeed())
 		Expect(queuedFrames).To(HaveLen(4))
@@ -18,10 +18,18 @@ type connIDManager struct {
 	activeConnectionID        protocol.ConnectionID
 	activeStatelessResetToken *protocol.StatelessResetToken
 
+	// connection IDs used on additional paths, when using multipath
+	claimed map[uint64]protocol.StatelessResetToken
+
+	// the maximum number of connection IDs we store, as sent in the active_connection_id_limit transport parameter
+	activeConnIDLimit int
+
 	// We change the connection ID after sending on average
-	// protocol.PacketsPerConnectionID packets. The actual value is randomized
+	// avgPacketsPerConnID packets. The actual value is randomized
 	// hide the packet loss rate from on-path observers.
+	// If avgPacketsPerConnID is negative, we don't change the connection ID on our own.
 	rand                   utils.Rand
+	avgPacketsPerConnID    int
 	packetsSinceLastChange uint32
 	packetsPerConnectionID uint32
 
@@ -32,12 +40,16 @@ type connIDManager struct {
 
 func newConnIDManager(
 	initialDestConnID protocol.ConnectionID,
+	activeConnIDLimit int,
+	avgPacketsPerConnID int,
 	addStatelessResetToken func(protocol.StatelessResetToken),
 	removeStatelessResetToken func(protocol.StatelessResetToken),
 	queueControlFrame func(wire.Frame),
 ) *connIDManager {
 	return &connIDManager{
 		activeConnectionID:        initialDestConnID,
+		activeConnIDLimit:         activeConnIDLimit,
+		avgPacketsPerConnID:       avgPacketsPerConnID,
 		addStatelessResetToken:    addStatelessResetToken,
 		removeStatelessResetToken: removeStatelessResetToken,
 		queueControlFrame:         queueControlFrame,
@@ -52,7 +64,7 @@ func (h *connIDManager) Add(f *wire.NewConnectionIDFrame) error {
 	if err := h.add(f); err != nil {
 		return err
 	}
-	if h.queue.Len() >= protocol.MaxActiveConnectionIDs {
+	if h.queue.Len() >= h.activeConnIDLimit {
 		return &qerr.TransportError{ErrorCode: qerr.ConnectionIDLimitError}
 	}
 	return nil
@@ -148,14 +160,73 @@ func (h *connIDManager) updateConnectionID() {
 	h.activeConnectionID = front.ConnectionID
 	h.activeStatelessResetToken = &front.StatelessResetToken
 	h.packetsSinceLastChange = 0
-	h.packetsPerConnectionID = protocol.PacketsPerConnectionID/2 + uint32(h.rand.Int31n(protocol.PacketsPerConnectionID))
+	if h.avgPacketsPerConnID > 0 {
+		h.packetsPerConnectionID = uint32(h.avgPacketsPerConnID/2) + uint32(h.rand.Int31n(int32(h.avgPacketsPerConnID)))
+	}
 	h.addStatelessResetToken(*h.activeStatelessResetToken)
 }
 
+// PeekNext returns the connection ID that will be used after the next call to SwitchToNext.
+// When migrating to a new path, a connection ID that wasn't used on the old path has to be used.
+// It returns false if the peer hasn't provided an unused connection ID (yet).
+// If the peer uses a zero-length connection ID, the active (zero-length) connection ID is returned.
+func (h *connIDManager) PeekNext() (protocol.ConnectionID, bool) {
+	if h.activeConnectionID.Len() == 0 {
+		return h.activeConnectionID, true
+	}
+	if h.queue.Len() == 0 {
+		return nil, false
+	}
+	return h.queue.Front().Value.ConnectionID, true
+}
+
+// SwitchToNext is called when the connection is migrated to a new path.
+// It switches to the connection ID returned by PeekNext, and adds its stateless reset token.
+func (h *connIDManager) SwitchToNext() {
+	if h.activeConnectionID.Len() > 0 && h.queue.Len() > 0 {
+		h.updateConnectionID()
+		return
+	}
+	if h.activeStatelessResetToken != nil {
+		h.addStatelessResetToken(*h.activeStatelessResetToken)
+	}
+}
+
+// Claim removes the next unused connection ID from the queue, such that it can be used on an additional path,
+// when using multipath. It adds the stateless reset token of the connection ID.
+// It returns false if the peer hasn't provided an unused connection ID (yet).
+func (h *connIDManager) Claim() (utils.NewConnectionID, bool) {
+	if h.activeConnectionID.Len() == 0 || h.queue.Len() == 0 {
+		return utils.NewConnectionID{}, false
+	}
+	c := h.queue.Remove(h.queue.Front())
+	if h.claimed == nil {
+		h.claimed = make(map[uint64]protocol.StatelessResetToken)
+	}
+	h.claimed[c.SequenceNumber] = c.StatelessResetToken
+	h.addStatelessResetToken(c.StatelessResetToken)
+	return c, true
+}
+
+// Release retires a connection ID returned by Claim.
+// It is called when the path that the connection ID was used on is abandoned.
+func (h *connIDManager) Release(seq uint64) {
+	token, ok := h.claimed[seq]
+	if !ok {
+		return
+	}
+	delete(h.claimed, seq)
+	h.queueControlFrame(&wire.RetireConnectionIDFrame{SequenceNumber: seq})
+	h.removeStatelessResetToken(token)
+}
+
 func (h *connIDManager) Close() {
 	if h.activeStatelessResetToken != nil {
 		h.removeStatelessResetToken(*h.activeStatelessResetToken)
 	}
+	for _, token := range h.claimed {
+		h.removeStatelessResetToken(token)
+	}
 }
 
 // is called when the server performs a Retry
@@ -181,7 +252,7 @@ func (h *connIDManager) SentPacket() {
 }
 
 func (h *connIDManager) shouldUpdateConnID() bool {
-	if !h.handshakeComplete {
+	if !h.handshakeComplete || h.avgPacketsPerConnID < 0 {
 		return false
 	}
 	// initiate the first change as early as possible (after handshake completion)
@@ -190,8 +261,8 @@ func (h *connIDManager) shouldUpdateConnID() bool {
 	}
 	// For later changes, only change if
 	// 1. The queue of connection IDs is filled more than 50%.
-	// 2. We sent at least PacketsPerConnectionID packets
-	return 2*h.queue.Len() >= protocol.MaxActiveConnectionIDs &&
+	// 2. We sent at least packetsPerConnectionID packets
+	return 2*h.queue.Len() >= h.activeConnIDLimit &&
 		h.packetsSinceLastChange >= h.packetsPerConnectionID
 }
 
@@ -24,6 +24,8 @@ var _ = Describe("Connection ID Manager", func() {
 		removedTokens = nil
 		m = newConnIDManager(
 			initialConnID,
+			protocol.DefaultActiveConnectionIDLimit,
+			protocol.DefaultPacketsPerConnectionID,
 			func(token protocol.StatelessResetToken) { tokenAdded = &token },
 			func(token protocol.StatelessResetToken) { removedTokens = append(removedTokens, token) },
 			func(f wire.Frame,
@@ -222,7 +224,23 @@ var _ = Describe("Connection ID Manager", func() {
 	})
 
 	It("errors when the peer sends too connection IDs", func() {
-		for i := uint8(1); i < protocol.MaxActiveConnectionIDs; i++ {
+		for i := uint8(1); i < protocol.DefaultActiveConnectionIDLimit; i++ {
+			Expect(m.Add(&wire.NewConnectionIDFrame{
+				SequenceNumber:      uint64(i),
+				ConnectionID:        protocol.ConnectionID{i, i, i, i},
+				StatelessResetToken: protocol.StatelessResetToken{i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i},
+			})).To(Succeed())
+		}
+		Expect(m.Add(&wire.NewConnectionIDFrame{
+			SequenceNumber:      uint64(9999),
+			ConnectionID:        protocol.ConnectionID{1, 2, 3, 4},
+			StatelessResetToken: protocol.StatelessResetToken{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		})).To(MatchError(&qerr.TransportError{ErrorCode: qerr.ConnectionIDLimitError}))
+	})
+
+	It("uses the configured active connection ID limit", func() {
+		m.activeConnIDLimit = 8
+		for i := uint8(1); i < 8; i++ {
 			Expect(m.Add(&wire.NewConnectionIDFrame{
 				SequenceNumber:      uint64(i),
 				ConnectionID:        protocol.ConnectionID{i, i, i, i},
@@ -261,7 +279,7 @@ var _ = Describe("Connection ID Manager", func() {
 
 	It("initiates subsequent updates when enough packets are sent", func() {
 		var s uint8
-		for s = uint8(1); s < protocol.MaxActiveConnectionIDs; s++ {
+		for s = uint8(1); s < protocol.DefaultActiveConnectionIDLimit; s++ {
 			Expect(m.Add(&wire.NewConnectionIDFrame{
 				SequenceNumber:      uint64(s),
 				ConnectionID:        protocol.ConnectionID{s, s, s, s},
@@ -274,7 +292,7 @@ var _ = Describe("Connection ID Manager", func() {
 		Expect(lastConnID).To(Equal(protocol.ConnectionID{1, 1, 1, 1}))
 
 		var counter int
-		for i := 0; i < 50*protocol.PacketsPerConnectionID; i++ {
+		for i := 0; i < 50*protocol.DefaultPacketsPerConnectionID; i++ {
 			m.SentPacket()
 
 			connID := m.Get()
@@ -294,8 +312,59 @@ var _ = Describe("Connection ID Manager", func() {
 		Expect(counter).To(BeNumerically("~", 50, 10))
 	})
 
+	It("uses the configured number of packets per connection ID", func() {
+		m.avgPacketsPerConnID = 100
+		var s uint8
+		for s = uint8(1); s < protocol.DefaultActiveConnectionIDLimit; s++ {
+			Expect(m.Add(&wire.NewConnectionIDFrame{
+				SequenceNumber:      uint64(s),
+				ConnectionID:        protocol.ConnectionID{s, s, s, s},
+				StatelessResetToken: protocol.StatelessResetToken{s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s},
+			})).To(Succeed())
+		}
+
+		m.SetHandshakeComplete()
+		lastConnID := m.Get()
+		var counter int
+		for i := 0; i < 50*100; i++ {
+			m.SentPacket()
+
+			connID := m.Get()
+			if !connID.Equal(lastConnID) {
+				counter++
+				lastConnID = connID
+				Expect(m.Add(&wire.NewConnectionIDFrame{
+					SequenceNumber:      uint64(s),
+					ConnectionID:        protocol.ConnectionID{s, s, s, s},
+					StatelessResetToken: protocol.StatelessResetToken{s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s},
+				})).To(Succeed())
+				s++
+			}
+		}
+		Expect(counter).To(BeNumerically("~", 50, 10))
+	})
+
+	It("doesn't initiate connection ID updates if rotation is disabled", func() {
+		m.avgPacketsPerConnID = -1
+		for i := uint8(1); i < protocol.DefaultActiveConnectionIDLimit; i++ {
+			Expect(m.Add(&wire.NewConnectionIDFrame{
+				SequenceNumber:      uint64(i),
+				ConnectionID:        protocol.ConnectionID{i, i, i, i},
+				StatelessResetToken: protocol.StatelessResetToken{i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i},
+			})).To(Succeed())
+		}
+		m.SetHandshakeComplete()
+		for i := 0; i < 2*protocol.DefaultPacketsPerConnectionID; i++ {
+			m.SentPacket()
+			Expect(m.Get()).To(Equal(initialConnID))
+		}
+		// connection IDs are still switched when migrating
+		m.SwitchToNext()
+		Expect(m.Get()).To(Equal(protocol.ConnectionID{1, 1, 1, 1}))
+	})
+
 	It("retires delayed connection IDs that arrive after a higher connection ID was already retired", func() {
-		for s := uint8(10); s <= 10+protocol.MaxActiveConnectionIDs/2; s++ {
+		for s := uint8(10); s <= 10+protocol.DefaultActiveConnectionIDLimit/2; s++ {
 			Expect(m.Add(&wire.NewConnectionIDFrame{
 				SequenceNumber:      uint64(s),
 				ConnectionID:        protocol.ConnectionID{s, s, s, s},
@@ -325,7 +394,7 @@ var _ = Describe("Connection ID Manager", func() {
 	})
 
 	It("only initiates subsequent updates when enough if enough connection IDs are queued", func() {
-		for i := uint8(1); i <= protocol.MaxActiveConnectionIDs/2; i++ {
+		for i := uint8(1); i <= protocol.DefaultActiveConnectionIDLimit/2; i++ {
 			Expect(m.Add(&wire.NewConnectionIDFrame{
 				SequenceNumber:      uint64(i),
 				ConnectionID:        protocol.ConnectionID{i, i, i, i},
@@ -334,7 +403,7 @@ var _ = Describe("Connection ID Manager", func() {
 		}
 		m.SetHandshakeComplete()
 		Expect(m.Get()).To(Equal(protocol.ConnectionID{1, 1, 1, 1}))
-		for i := 0; i < 2*protocol.PacketsPerConnectionID; i++ {
+		for i := 0; i < 2*protocol.DefaultPacketsPerConnectionID; i++ {
 			m.SentPacket()
 		}
 		Expect(m.Get()).To(Equal(protocol.ConnectionID{1, 1, 1, 1}))
@@ -347,6 +416,97 @@ var _ = Describe("Connection ID Manager", func() {
 		Expect(removedTokens[0]).To(Equal(protocol.StatelessResetToken{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}))
 	})
 
+	It("switches to the next connection ID when migrating", func() {
+		_, ok := m.PeekNext()
+		Expect(ok).To(BeFalse())
+		Expect(m.Add(&wire.NewConnectionIDFrame{
+			SequenceNumber:      1,
+			ConnectionID:        protocol.ConnectionID{1, 2, 3, 4},
+			StatelessResetToken: protocol.StatelessResetToken{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		})).To(Succeed())
+		connID, ok := m.PeekNext()
+		Expect(ok).To(BeTrue())
+		Expect(connID).To(Equal(protocol.ConnectionID{1, 2, 3, 4}))
+		Expect(m.Get()).To(Equal(initialConnID)) // PeekNext doesn't change the connection ID
+		m.SwitchToNext()
+		Expect(m.Get()).To(Equal(protocol.ConnectionID{1, 2, 3, 4}))
+		Expect(frameQueue).To(ContainElement(&wire.RetireConnectionIDFrame{SequenceNumber: 0}))
+		Expect(*tokenAdded).To(Equal(protocol.StatelessResetToken{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+		_, ok = m.PeekNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("keeps using a zero-length connection ID when migrating", func() {
+		m = newConnIDManager(
+			protocol.ConnectionID{},
+			protocol.DefaultActiveConnectionIDLimit,
+			protocol.DefaultPacketsPerConnectionID,
+			func(token protocol.StatelessResetToken) { tokenAdded = &token },
+			func(token protocol.StatelessResetToken) { removedTokens = append(removedTokens, token) },
+			func(f wire.Frame) { frameQueue = append(frameQueue, f) },
+		)
+		token := protocol.StatelessResetToken{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+		m.SetStatelessResetToken(token)
+		tokenAdded = nil
+		connID, ok := m.PeekNext()
+		Expect(ok).To(BeTrue())
+		Expect(connID.Len()).To(BeZero())
+		m.SwitchToNext()
+		Expect(m.Get().Len()).To(BeZero())
+		Expect(frameQueue).To(BeEmpty())
+		Expect(*tokenAdded).To(Equal(token))
+	})
+
+	It("claims connection IDs for additional paths", func() {
+		_, ok := m.Claim()
+		Expect(ok).To(BeFalse())
+		Expect(m.Add(&wire.NewConnectionIDFrame{
+			SequenceNumber:      1,
+			ConnectionID:        protocol.ConnectionID{1, 2, 3, 4},
+			StatelessResetToken: protocol.StatelessResetToken{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		})).To(Succeed())
+		c, ok := m.Claim()
+		Expect(ok).To(BeTrue())
+		Expect(c.SequenceNumber).To(BeEquivalentTo(1))
+		Expect(c.ConnectionID).To(Equal(protocol.ConnectionID{1, 2, 3, 4}))
+		Expect(*tokenAdded).To(Equal(protocol.StatelessResetToken{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+		// the active connection ID is not changed
+		Expect(m.Get()).To(Equal(initialConnID))
+		_, ok = m.PeekNext()
+		Expect(ok).To(BeFalse())
+		// releasing the connection ID retires it
+		m.Release(1)
+		Expect(frameQueue).To(Equal([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 1}}))
+		Expect(removedTokens).To(Equal([]protocol.StatelessResetToken{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}}))
+		m.Release(1)
+		Expect(frameQueue).To(HaveLen(1))
+	})
+
+	It("doesn't claim zero-length connection IDs", func() {
+		m = newConnIDManager(
+			protocol.ConnectionID{},
+			protocol.DefaultActiveConnectionIDLimit,
+			protocol.DefaultPacketsPerConnectionID,
+			func(token protocol.StatelessResetToken) { tokenAdded = &token },
+			func(token protocol.StatelessResetToken) { removedTokens = append(removedTokens, token) },
+			func(f wire.Frame) { frameQueue = append(frameQueue, f) },
+		)
+		_, ok := m.Claim()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("removes the stateless reset tokens of claimed connection IDs when it is closed", func() {
+		Expect(m.Add(&wire.NewConnectionIDFrame{
+			SequenceNumber:      1,
+			ConnectionID:        protocol.ConnectionID{1, 2, 3, 4},
+			StatelessResetToken: protocol.StatelessResetToken{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+		})).To(Succeed())
+		_, ok := m.Claim()
+		Expect(ok).To(BeTrue())
+		m.Close()
+		Expect(removedTokens).To(Equal([]protocol.StatelessResetToken{{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}}))
+	})
+
 	It("removes the currently active stateless reset token when it is closed", func() {
 		m.Close()
 		Expect(removedTokens).To(BeEmpty())
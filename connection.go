@@ -9,6 +9,7 @@ import (
 	"io"
 	"net"
 	"reflect"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,6 +27,7 @@ import (
 
 type unpacker interface {
 	Unpack(hdr *wire.Header, rcvTime time.Time, data []byte) (*unpackedPacket, error)
+	UnpackOnPath(hdr *wire.Header, rcvTime time.Time, data []byte, pathID uint64, largestRcvd protocol.PacketNumber) (*unpackedPacket, error)
 }
 
 type streamGetter interface {
@@ -58,6 +60,8 @@ type cryptoStreamHandler interface {
 	GetSessionTicket() ([]byte, error)
 	io.Closer
 	ConnectionState() handshake.ConnectionState
+	RequestKeyUpdate()
+	CurrentKeyPhase() protocol.KeyPhase
 }
 
 type packetInfo struct {
@@ -98,6 +102,7 @@ type connRunner interface {
 	ReplaceWithClosed(protocol.ConnectionID, packetHandler)
 	AddResetToken(protocol.StatelessResetToken, packetHandler)
 	RemoveResetToken(protocol.StatelessResetToken)
+	RemoveHandler(packetHandler)
 }
 
 type handshakeRunner struct {
@@ -132,6 +137,13 @@ func nextConnTracingID() uint64 { return atomic.AddUint64(&connTracingID, 1) }
 
 // A Connection is a QUIC connection
 type connection struct {
+	// packetsDropped is incremented from handlePacket, so it must be accessed atomically.
+	// It is the first field of the struct to guarantee 64-bit alignment on 32-bit platforms.
+	packetsDropped uint64
+	// packetsSent and packetsReceived are only accessed from the run loop.
+	packetsSent     uint64
+	packetsReceived uint64
+
 	// Destination connection ID used during the handshake.
 	// Used to check source connection ID on incoming packets.
 	handshakeDestConnID protocol.ConnectionID
@@ -145,8 +157,9 @@ type connection struct {
 	version     protocol.VersionNumber
 	config      *Config
 
-	conn      sendConn
+	conn      *switchableConn
 	sendQueue sender
+	runner    connRunner
 
 	streamsMap      streamManager
 	connIDManager   *connIDManager
@@ -161,14 +174,32 @@ type connection struct {
 	framer                framer
 	windowUpdateQueue     *windowUpdateQueue
 	connFlowController    flowcontrol.ConnectionFlowController
-	tokenStoreKey         string                    // only set for the client
-	tokenGenerator        *handshake.TokenGenerator // only set for the server
+	tokenStoreKey         string         // only set for the client
+	tokenGenerator        TokenGenerator // only set for the server
 
 	unpacker      unpacker
 	frameParser   wire.FrameParser
 	packer        packer
 	mtuDiscoverer mtuDiscoverer // initialized when the handshake completes
 
+	pathProbeRequests chan *pathProbe
+	pathProbe         *pathProbe     // the path that is currently being validated, only set for the client
+	autoMigrator      *autoMigrator  // only set for the client, if automatic migration is enabled
+	peerMigration     *peerMigration // the new address of the client that is currently being validated, only set for the server
+	// the largest 1-RTT packet number received, used to detect migrations of the peer
+	largestRcvdPacketNumber protocol.PacketNumber
+
+	sealingManager     sealingManager // used to create the packers for additional paths
+	addPathRequests    chan *pathProbe
+	removePathRequests chan *pathRemovalRequest
+	keyUpdateRequests  chan chan error
+	statsRequests      chan chan ConnectionStats
+	// Only set when using multipath, once the handshake is confirmed.
+	// The initial path is also contained in this map.
+	paths         map[PathID]*path
+	nextPathID    PathID
+	pathScheduler MultipathScheduler
+
 	oneRTTStream        cryptoStream // only set for the server
 	cryptoStreamHandler cryptoStreamHandler
 
@@ -239,7 +270,7 @@ var newConnection = func(
 	statelessResetToken protocol.StatelessResetToken,
 	conf *Config,
 	tlsConf *tls.Config,
-	tokenGenerator *handshake.TokenGenerator,
+	tokenGenerator TokenGenerator,
 	enable0RTT bool,
 	tracer logging.ConnectionTracer,
 	tracingID uint64,
@@ -247,7 +278,8 @@ var newConnection = func(
 	v protocol.VersionNumber,
 ) quicConn {
 	s := &connection{
-		conn:                  conn,
+		conn:                  newSwitchableConn(conn),
+		runner:                runner,
 		config:                conf,
 		handshakeDestConnID:   destConnID,
 		srcConnIDLen:          srcConnID.Len(),
@@ -264,20 +296,26 @@ var newConnection = func(
 	} else {
 		s.logID = destConnID.String()
 	}
+	s.logger = logger.With("odcid", s.logID)
 	s.connIDManager = newConnIDManager(
 		destConnID,
-		func(token protocol.StatelessResetToken) { runner.AddResetToken(token, s) },
-		runner.RemoveResetToken,
+		s.config.ActiveConnectionIDLimit,
+		s.config.PacketsPerConnectionID,
+		func(token protocol.StatelessResetToken) { s.runner.AddResetToken(token, s) },
+		func(token protocol.StatelessResetToken) { s.runner.RemoveResetToken(token) },
 		s.queueControlFrame,
 	)
 	s.connIDGenerator = newConnIDGenerator(
 		srcConnID,
 		clientDestConnID,
-		func(connID protocol.ConnectionID) { runner.Add(connID, s) },
-		runner.GetStatelessResetToken,
-		runner.Remove,
-		runner.Retire,
-		runner.ReplaceWithClosed,
+		s.config.ConnectionIDGenerator,
+		s.addConnectionID,
+		func(connID protocol.ConnectionID) protocol.StatelessResetToken {
+			return s.runner.GetStatelessResetToken(connID)
+		},
+		func(connID protocol.ConnectionID) { s.runner.Remove(connID) },
+		func(connID protocol.ConnectionID) { s.runner.Retire(connID) },
+		func(connID protocol.ConnectionID, h packetHandler) { s.runner.ReplaceWithClosed(connID, h) },
 		s.queueControlFrame,
 		s.version,
 	)
@@ -286,6 +324,7 @@ var newConnection = func(
 	s.sentPacketHandler, s.receivedPacketHandler = ackhandler.NewAckHandler(
 		0,
 		getMaxPacketSize(s.conn.RemoteAddr()),
+		congestionConfig(s.config),
 		s.rttStats,
 		s.perspective,
 		s.tracer,
@@ -304,16 +343,29 @@ var newConnection = func(
 		MaxUniStreamNum:                 protocol.StreamNum(s.config.MaxIncomingUniStreams),
 		MaxAckDelay:                     protocol.MaxAckDelayInclGranularity,
 		AckDelayExponent:                protocol.AckDelayExponent,
-		DisableActiveMigration:          true,
 		StatelessResetToken:             &statelessResetToken,
 		OriginalDestinationConnectionID: origDestConnID,
-		ActiveConnectionIDLimit:         protocol.MaxActiveConnectionIDs,
+		DisableActiveMigration:          !s.config.EnableActiveMigration,
+		ActiveConnectionIDLimit:         uint64(s.config.ActiveConnectionIDLimit),
 		InitialSourceConnectionID:       srcConnID,
 		RetrySourceConnectionID:         retrySrcConnID,
 	}
 	if s.config.EnableDatagrams {
 		params.MaxDatagramFrameSize = protocol.MaxDatagramFrameSize
 	}
+	if s.config.EnableAckFrequency {
+		minAckDelay := protocol.MinAckDelay
+		params.MinAckDelay = &minAckDelay
+	}
+	if s.config.EnableMultipath {
+		params.EnableMultipath = true
+	}
+	if s.config.EnablePartialReliability {
+		params.EnablePartialReliability = true
+	}
+	if s.config.EnableReliableStreamReset {
+		params.EnableReliableStreamReset = true
+	}
 	if s.tracer != nil {
 		s.tracer.SentTransportParameters(params)
 	}
@@ -334,6 +386,7 @@ var newConnection = func(
 			},
 		},
 		tlsConf,
+		s.config.TLSBackend,
 		enable0RTT,
 		s.rttStats,
 		tracer,
@@ -341,6 +394,7 @@ var newConnection = func(
 		s.version,
 	)
 	s.cryptoStreamHandler = cs
+	s.sealingManager = cs
 	s.packer = newPacketPacker(
 		srcConnID,
 		s.connIDManager.Get,
@@ -353,6 +407,7 @@ var newConnection = func(
 		s.framer,
 		s.receivedPacketHandler,
 		s.datagramQueue,
+		s.config.DatagramPriority,
 		s.perspective,
 		s.version,
 	)
@@ -378,7 +433,8 @@ var newClientConnection = func(
 	v protocol.VersionNumber,
 ) quicConn {
 	s := &connection{
-		conn:                  conn,
+		conn:                  newSwitchableConn(conn),
+		runner:                runner,
 		config:                conf,
 		origDestConnID:        destConnID,
 		handshakeDestConnID:   destConnID,
@@ -386,25 +442,30 @@ var newClientConnection = func(
 		perspective:           protocol.PerspectiveClient,
 		handshakeCompleteChan: make(chan struct{}),
 		logID:                 destConnID.String(),
-		logger:                logger,
+		logger:                logger.With("odcid", destConnID.String()),
 		tracer:                tracer,
 		versionNegotiated:     hasNegotiatedVersion,
 		version:               v,
 	}
 	s.connIDManager = newConnIDManager(
 		destConnID,
-		func(token protocol.StatelessResetToken) { runner.AddResetToken(token, s) },
-		runner.RemoveResetToken,
+		s.config.ActiveConnectionIDLimit,
+		s.config.PacketsPerConnectionID,
+		func(token protocol.StatelessResetToken) { s.runner.AddResetToken(token, s) },
+		func(token protocol.StatelessResetToken) { s.runner.RemoveResetToken(token) },
 		s.queueControlFrame,
 	)
 	s.connIDGenerator = newConnIDGenerator(
 		srcConnID,
 		nil,
-		func(connID protocol.ConnectionID) { runner.Add(connID, s) },
-		runner.GetStatelessResetToken,
-		runner.Remove,
-		runner.Retire,
-		runner.ReplaceWithClosed,
+		s.config.ConnectionIDGenerator,
+		s.addConnectionID,
+		func(connID protocol.ConnectionID) protocol.StatelessResetToken {
+			return s.runner.GetStatelessResetToken(connID)
+		},
+		func(connID protocol.ConnectionID) { s.runner.Remove(connID) },
+		func(connID protocol.ConnectionID) { s.runner.Retire(connID) },
+		func(connID protocol.ConnectionID, h packetHandler) { s.runner.ReplaceWithClosed(connID, h) },
 		s.queueControlFrame,
 		s.version,
 	)
@@ -413,6 +474,7 @@ var newClientConnection = func(
 	s.sentPacketHandler, s.receivedPacketHandler = ackhandler.NewAckHandler(
 		initialPacketNumber,
 		getMaxPacketSize(s.conn.RemoteAddr()),
+		congestionConfig(s.config),
 		s.rttStats,
 		s.perspective,
 		s.tracer,
@@ -432,12 +494,25 @@ var newClientConnection = func(
 		MaxAckDelay:                    protocol.MaxAckDelayInclGranularity,
 		AckDelayExponent:               protocol.AckDelayExponent,
 		DisableActiveMigration:         true,
-		ActiveConnectionIDLimit:        protocol.MaxActiveConnectionIDs,
+		ActiveConnectionIDLimit:        uint64(s.config.ActiveConnectionIDLimit),
 		InitialSourceConnectionID:      srcConnID,
 	}
 	if s.config.EnableDatagrams {
 		params.MaxDatagramFrameSize = protocol.MaxDatagramFrameSize
 	}
+	if s.config.EnableAckFrequency {
+		minAckDelay := protocol.MinAckDelay
+		params.MinAckDelay = &minAckDelay
+	}
+	if s.config.EnableMultipath {
+		params.EnableMultipath = true
+	}
+	if s.config.EnablePartialReliability {
+		params.EnablePartialReliability = true
+	}
+	if s.config.EnableReliableStreamReset {
+		params.EnableReliableStreamReset = true
+	}
 	if s.tracer != nil {
 		s.tracer.SentTransportParameters(params)
 	}
@@ -455,6 +530,7 @@ var newClientConnection = func(
 			onHandshakeComplete: func() { close(s.handshakeCompleteChan) },
 		},
 		tlsConf,
+		s.config.TLSBackend,
 		enable0RTT,
 		s.rttStats,
 		tracer,
@@ -463,6 +539,7 @@ var newClientConnection = func(
 	)
 	s.clientHelloWritten = clientHelloWritten
 	s.cryptoStreamHandler = cs
+	s.sealingManager = cs
 	s.cryptoStreamManager = newCryptoStreamManager(cs, initialStream, handshakeStream, newCryptoStream())
 	s.unpacker = newPacketUnpacker(cs, s.version)
 	s.packer = newPacketPacker(
@@ -477,6 +554,7 @@ var newClientConnection = func(
 		s.framer,
 		s.receivedPacketHandler,
 		s.datagramQueue,
+		s.config.DatagramPriority,
 		s.perspective,
 		s.version,
 	)
@@ -494,9 +572,14 @@ var newClientConnection = func(
 }
 
 func (s *connection) preSetup() {
-	s.sendQueue = newSendQueue(s.conn)
+	var conn sendConn = s.conn
+	if s.perspective == protocol.PerspectiveClient && s.config.EnableAutomaticMigration {
+		s.autoMigrator = newAutoMigrator(s, s.config.ConnectionMigrated, s.logger)
+		conn = s.autoMigrator.SendConn(s.conn)
+	}
+	s.sendQueue = newSendQueue(conn)
 	s.retransmissionQueue = newRetransmissionQueue(s.version)
-	s.frameParser = wire.NewFrameParser(s.config.EnableDatagrams, s.version)
+	s.frameParser = wire.NewFrameParser(s.config.EnableDatagrams, s.config.EnableAckFrequency, s.config.EnableMultipath, s.config.EnablePartialReliability, s.config.EnableReliableStreamReset, s.version)
 	s.rttStats = &utils.RTTStats{}
 	s.connFlowController = flowcontrol.NewConnectionFlowController(
 		protocol.ByteCount(s.config.InitialConnectionReceiveWindow),
@@ -524,6 +607,12 @@ func (s *connection) preSetup() {
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxConnUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
 	s.sendingScheduled = make(chan struct{}, 1)
+	s.pathProbeRequests = make(chan *pathProbe)
+	s.addPathRequests = make(chan *pathProbe)
+	s.removePathRequests = make(chan *pathRemovalRequest)
+	s.keyUpdateRequests = make(chan chan error)
+	s.statsRequests = make(chan chan ConnectionStats)
+	s.largestRcvdPacketNumber = protocol.InvalidPacketNumber
 	s.handshakeCtx, s.handshakeCtxCancel = context.WithCancel(context.Background())
 
 	now := time.Now()
@@ -532,7 +621,13 @@ func (s *connection) preSetup() {
 
 	s.windowUpdateQueue = newWindowUpdateQueue(s.streamsMap, s.connFlowController, s.framer.QueueControlFrame)
 	if s.config.EnableDatagrams {
-		s.datagramQueue = newDatagramQueue(s.scheduleSending, s.logger)
+		s.datagramQueue = newDatagramQueue(
+			s.scheduleSending,
+			s.config.DatagramSendQueueLen,
+			s.config.DatagramReceiveQueueLen,
+			s.config.DatagramDropPolicy,
+			s.logger,
+		)
 	}
 }
 
@@ -610,6 +705,16 @@ runLoop:
 				// We do all the interesting stuff after the switch statement, so
 				// nothing to see here.
 			case <-sendQueueAvailable:
+			case probe := <-s.pathProbeRequests:
+				s.startPathProbe(probe)
+			case probe := <-s.addPathRequests:
+				s.startAddingPath(probe)
+			case req := <-s.removePathRequests:
+				req.errChan <- s.removePath(req.id)
+			case errChan := <-s.keyUpdateRequests:
+				errChan <- s.requestKeyUpdate()
+			case statsChan := <-s.statsRequests:
+				statsChan <- s.getStats()
 			case firstPacket := <-s.receivedPackets:
 				wasProcessed := s.handlePacketImpl(firstPacket)
 				// Don't set timers and send packets if the packet made us close the connection.
@@ -660,6 +765,24 @@ runLoop:
 			}
 		}
 
+		if s.pathProbe != nil {
+			if err := s.maybeSendPathChallenge(now); err != nil {
+				s.closeLocal(err)
+			}
+		}
+
+		if s.peerMigration != nil {
+			if err := s.maybeSendPeerMigrationChallenge(now); err != nil {
+				s.closeLocal(err)
+			}
+		}
+
+		if len(s.paths) > 1 {
+			if err := s.handlePathTimeouts(now); err != nil {
+				s.closeLocal(err)
+			}
+		}
+
 		if keepAliveTime := s.nextKeepAliveTime(); !keepAliveTime.IsZero() && !now.Before(keepAliveTime) {
 			// send a PING frame since there is no activity in the connection
 			s.logger.Debugf("Sending a keep-alive PING to keep the connection alive.")
@@ -694,6 +817,10 @@ runLoop:
 	}
 
 	s.handleCloseError(&closeErr)
+	if s.pathProbe != nil {
+		s.failPathProbe(closeErr.err)
+	}
+	s.closePaths(closeErr.err)
 	if e := (&errCloseForRecreating{}); !errors.As(closeErr.err, &e) && s.tracer != nil {
 		s.tracer.Close()
 	}
@@ -721,11 +848,43 @@ func (s *connection) supportsDatagrams() bool {
 	return s.peerParams.MaxDatagramFrameSize != protocol.InvalidByteCount
 }
 
+// supportsAckFrequency says if both endpoints support the ACK frequency extension.
+func (s *connection) supportsAckFrequency() bool {
+	return s.config.EnableAckFrequency && s.peerParams.MinAckDelay != nil
+}
+
+// supportsMultipath says if both endpoints support the multipath extension.
+func (s *connection) supportsMultipath() bool {
+	return s.config.EnableMultipath && s.peerParams != nil && s.peerParams.EnableMultipath
+}
+
+// supportsPartialReliability says if both endpoints support the partial reliability extension.
+func (s *connection) supportsPartialReliability() bool {
+	return s.config.EnablePartialReliability && s.peerParams != nil && s.peerParams.EnablePartialReliability
+}
+
+// supportsReliableStreamReset says if both endpoints support the reliable stream reset extension.
+func (s *connection) supportsReliableStreamReset() bool {
+	return s.config.EnableReliableStreamReset && s.peerParams != nil && s.peerParams.EnableReliableStreamReset
+}
+
+// maxDatagramPayloadSize is the maximum size of a message that can be sent in a DATAGRAM frame.
+func (s *connection) maxDatagramPayloadSize() protocol.ByteCount {
+	f := &wire.DatagramFrame{DataLenPresent: true}
+	return f.MaxDataLen(s.peerParams.MaxDatagramFrameSize, s.version)
+}
+
 func (s *connection) ConnectionState() ConnectionState {
-	return ConnectionState{
+	cs := ConnectionState{
 		TLS:               s.cryptoStreamHandler.ConnectionState(),
 		SupportsDatagrams: s.supportsDatagrams(),
+		Version:           s.version,
+		KeyPhase:          uint64(s.cryptoStreamHandler.CurrentKeyPhase()),
+	}
+	if cs.SupportsDatagrams {
+		cs.MaxDatagramPayloadSize = int(s.maxDatagramPayloadSize())
 	}
+	return cs
 }
 
 // Time when the next keep-alive packet should be sent.
@@ -751,13 +910,36 @@ func (s *connection) maybeResetTimer() {
 			deadline = s.idleTimeoutStartTime().Add(s.idleTimeout)
 		}
 	}
-	if s.handshakeConfirmed && !s.config.DisablePathMTUDiscovery {
+	// When amplification limited, neither MTU probes nor ACKs can be sent until more data is received.
+	amplificationLimited := s.amplificationLimited()
+	if s.handshakeConfirmed && !s.config.DisablePathMTUDiscovery && !amplificationLimited {
 		if probeTime := s.mtuDiscoverer.NextProbeTime(); !probeTime.IsZero() {
 			deadline = utils.MinTime(deadline, probeTime)
 		}
 	}
 
-	if ackAlarm := s.receivedPacketHandler.GetAlarmTimeout(); !ackAlarm.IsZero() {
+	if s.pathProbe != nil {
+		deadline = utils.MinTime(deadline, s.pathProbe.NextTimeout())
+	}
+	if s.peerMigration != nil {
+		deadline = utils.MinTime(deadline, s.peerMigration.probe.NextTimeout())
+	}
+	for _, p := range s.paths {
+		if p.id == initialPathID {
+			continue
+		}
+		if p.probe != nil {
+			deadline = utils.MinTime(deadline, p.probe.NextTimeout())
+		}
+		if ackAlarm := p.receivedPacketHandler.GetAlarmTimeout(); !ackAlarm.IsZero() {
+			deadline = utils.MinTime(deadline, ackAlarm)
+		}
+		if lossTime := p.sentPacketHandler.GetLossDetectionTimeout(); !lossTime.IsZero() {
+			deadline = utils.MinTime(deadline, lossTime)
+		}
+	}
+
+	if ackAlarm := s.receivedPacketHandler.GetAlarmTimeout(); !ackAlarm.IsZero() && !amplificationLimited {
 		deadline = utils.MinTime(deadline, ackAlarm)
 	}
 	if lossTime := s.sentPacketHandler.GetLossDetectionTimeout(); !lossTime.IsZero() {
@@ -782,7 +964,11 @@ func (s *connection) handleHandshakeComplete() {
 	// There's no point in queueing undecryptable packets for later decryption any more.
 	s.undecryptablePackets = nil
 
-	s.connIDManager.SetHandshakeComplete()
+	// When using multipath, the connection IDs provided by the peer are used for additional paths,
+	// so we don't switch to a new connection ID on the initial path.
+	if !s.supportsMultipath() {
+		s.connIDManager.SetHandshakeComplete()
+	}
 	s.connIDGenerator.SetHandshakeComplete()
 
 	if s.perspective == protocol.PerspectiveClient {
@@ -816,25 +1002,38 @@ func (s *connection) handleHandshakeConfirmed() {
 	s.cryptoStreamHandler.SetHandshakeConfirmed()
 
 	if !s.config.DisablePathMTUDiscovery {
-		maxPacketSize := s.peerParams.MaxUDPPayloadSize
-		if maxPacketSize == 0 {
-			maxPacketSize = protocol.MaxByteCount
-		}
-		maxPacketSize = utils.MinByteCount(maxPacketSize, protocol.MaxPacketBufferSize)
-		s.mtuDiscoverer = newMTUDiscoverer(
-			s.rttStats,
-			getMaxPacketSize(s.conn.RemoteAddr()),
-			maxPacketSize,
-			func(size protocol.ByteCount) {
-				s.sentPacketHandler.SetMaxDatagramSize(size)
-				s.packer.SetMaxPacketSize(size)
-			},
-		)
+		s.startMTUDiscovery()
+	}
+	if s.supportsMultipath() {
+		s.startMultipath()
+	}
+	if s.autoMigrator != nil && !s.peerParams.DisableActiveMigration && s.paths == nil {
+		s.autoMigrator.Start()
+	}
+}
+
+func (s *connection) startMTUDiscovery() {
+	maxPacketSize := s.peerParams.MaxUDPPayloadSize
+	if maxPacketSize == 0 {
+		maxPacketSize = protocol.MaxByteCount
 	}
+	maxPacketSize = utils.MinByteCount(maxPacketSize, protocol.MaxPacketBufferSize)
+	s.mtuDiscoverer = newMTUDiscoverer(
+		s.rttStats,
+		getMaxPacketSize(s.conn.RemoteAddr()),
+		maxPacketSize,
+		func(size protocol.ByteCount) {
+			s.sentPacketHandler.SetMaxDatagramSize(size)
+			s.packer.SetMaxPacketSize(size)
+		},
+	)
 }
 
 func (s *connection) handlePacketImpl(rp *receivedPacket) bool {
 	s.sentPacketHandler.ReceivedBytes(rp.Size())
+	if s.peerMigration != nil && rp.remoteAddr != nil && isSameAddr(rp.remoteAddr, s.peerMigration.probe.conn.RemoteAddr(), false) {
+		s.peerMigration.ReceivedBytes(rp.Size())
+	}
 
 	if wire.IsVersionNegotiationPacket(rp.data) {
 		s.handleVersionNegotiationPacket(rp)
@@ -854,29 +1053,23 @@ func (s *connection) handlePacketImpl(rp *receivedPacket) bool {
 
 		hdr, packetData, rest, err := wire.ParsePacket(p.data, s.srcConnIDLen)
 		if err != nil {
-			if s.tracer != nil {
-				dropReason := logging.PacketDropHeaderParseError
-				if err == wire.ErrUnsupportedVersion {
-					dropReason = logging.PacketDropUnsupportedVersion
-				}
-				s.tracer.DroppedPacket(logging.PacketTypeNotDetermined, protocol.ByteCount(len(data)), dropReason)
+			dropReason := logging.PacketDropHeaderParseError
+			if err == wire.ErrUnsupportedVersion {
+				dropReason = logging.PacketDropUnsupportedVersion
 			}
+			s.droppedPacket(logging.PacketTypeNotDetermined, protocol.ByteCount(len(data)), dropReason)
 			s.logger.Debugf("error parsing packet: %s", err)
 			break
 		}
 
 		if hdr.IsLongHeader && hdr.Version != s.version {
-			if s.tracer != nil {
-				s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(data)), logging.PacketDropUnexpectedVersion)
-			}
+			s.droppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(data)), logging.PacketDropUnexpectedVersion)
 			s.logger.Debugf("Dropping packet with version %x. Expected %x.", hdr.Version, s.version)
 			break
 		}
 
 		if counter > 0 && !hdr.DestConnectionID.Equal(lastConnID) {
-			if s.tracer != nil {
-				s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(data)), logging.PacketDropUnknownConnectionID)
-			}
+			s.droppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(data)), logging.PacketDropUnknownConnectionID)
 			s.logger.Debugf("coalesced packet has different destination connection ID: %s, expected %s", hdr.DestConnectionID, lastConnID)
 			break
 		}
@@ -918,27 +1111,39 @@ func (s *connection) handleSinglePacket(p *receivedPacket, hdr *wire.Header) boo
 	// The server can change the source connection ID with the first Handshake packet.
 	// After this, all packets with a different source connection have to be ignored.
 	if s.receivedFirstPacket && hdr.IsLongHeader && hdr.Type == protocol.PacketTypeInitial && !hdr.SrcConnectionID.Equal(s.handshakeDestConnID) {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeInitial, p.Size(), logging.PacketDropUnknownConnectionID)
-		}
+		s.droppedPacket(logging.PacketTypeInitial, p.Size(), logging.PacketDropUnknownConnectionID)
 		s.logger.Debugf("Dropping Initial packet (%d bytes) with unexpected source connection ID: %s (expected %s)", p.Size(), hdr.SrcConnectionID, s.handshakeDestConnID)
 		return false
 	}
 	// drop 0-RTT packets, if we are a client
 	if s.perspective == protocol.PerspectiveClient && hdr.Type == protocol.PacketType0RTT {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketType0RTT, p.Size(), logging.PacketDropKeyUnavailable)
-		}
+		s.droppedPacket(logging.PacketType0RTT, p.Size(), logging.PacketDropKeyUnavailable)
 		return false
 	}
 
-	packet, err := s.unpacker.Unpack(hdr, p.rcvTime, p.data)
+	// When using multipath, packets sent to any connection ID other than the initial one
+	// belong to an additional path, and are protected using the sequence number of the connection ID.
+	var rcvSeq uint64
+	var onPath bool
+	if s.paths != nil && !hdr.IsLongHeader {
+		rcvSeq, onPath = s.connIDGenerator.SequenceNumber(hdr.DestConnectionID)
+		onPath = onPath && rcvSeq != 0
+	}
+	var packet *unpackedPacket
+	var err error
+	if onPath {
+		largestRcvd := protocol.InvalidPacketNumber
+		if path := s.pathByRcvSeq(rcvSeq); path != nil {
+			largestRcvd = path.largestRcvdPacketNumber
+		}
+		packet, err = s.unpacker.UnpackOnPath(hdr, p.rcvTime, p.data, rcvSeq, largestRcvd)
+	} else {
+		packet, err = s.unpacker.Unpack(hdr, p.rcvTime, p.data)
+	}
 	if err != nil {
 		switch err {
 		case handshake.ErrKeysDropped:
-			if s.tracer != nil {
-				s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropKeyUnavailable)
-			}
+			s.droppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropKeyUnavailable)
 			s.logger.Debugf("Dropping %s packet (%d bytes) because we already dropped the keys.", hdr.PacketType(), p.Size())
 		case handshake.ErrKeysNotYetAvailable:
 			// Sealer for this encryption level not yet available.
@@ -952,17 +1157,13 @@ func (s *connection) handleSinglePacket(p *receivedPacket, hdr *wire.Header) boo
 			})
 		case handshake.ErrDecryptionFailed:
 			// This might be a packet injected by an attacker. Drop it.
-			if s.tracer != nil {
-				s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropPayloadDecryptError)
-			}
+			s.droppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropPayloadDecryptError)
 			s.logger.Debugf("Dropping %s packet (%d bytes) that could not be unpacked. Error: %s", hdr.PacketType(), p.Size(), err)
 		default:
 			var headerErr *headerParseError
 			if errors.As(err, &headerErr) {
 				// This might be a packet injected by an attacker. Drop it.
-				if s.tracer != nil {
-					s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropHeaderParseError)
-				}
+				s.droppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropHeaderParseError)
 				s.logger.Debugf("Dropping %s packet (%d bytes) for which we couldn't unpack the header. Error: %s", hdr.PacketType(), p.Size(), err)
 			} else {
 				// This is an error returned by the AEAD (other than ErrDecryptionFailed).
@@ -978,15 +1179,29 @@ func (s *connection) handleSinglePacket(p *receivedPacket, hdr *wire.Header) boo
 		packet.hdr.Log(s.logger)
 	}
 
+	if onPath {
+		processed, err := s.handlePathPacket(packet, rcvSeq, p)
+		if err != nil {
+			s.closeLocal(err)
+			return false
+		}
+		return processed
+	}
+
 	if s.receivedPacketHandler.IsPotentiallyDuplicate(packet.packetNumber, packet.encryptionLevel) {
 		s.logger.Debugf("Dropping (potentially) duplicate packet.")
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropDuplicate)
-		}
+		s.droppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropDuplicate)
 		return false
 	}
 
-	if err := s.handleUnpackedPacket(packet, p.ecn, p.rcvTime, p.Size()); err != nil {
+	// The peer might have migrated to a new address.
+	// Only the client can initiate a migration, and only after the handshake has been confirmed.
+	var newPath sendConn
+	if s.perspective == protocol.PerspectiveServer && s.handshakeConfirmed && p.remoteAddr != nil && !isSameAddr(p.remoteAddr, s.conn.RemoteAddr(), false) {
+		newPath = sendConnWithRemoteAddr(s.conn, p.remoteAddr, p.info)
+	}
+
+	if err := s.handleUnpackedPacket(packet, newPath, p.ecn, p.rcvTime, p.Size()); err != nil {
 		s.closeLocal(err)
 		return false
 	}
@@ -995,24 +1210,18 @@ func (s *connection) handleSinglePacket(p *receivedPacket, hdr *wire.Header) boo
 
 func (s *connection) handleRetryPacket(hdr *wire.Header, data []byte) bool /* was this a valid Retry */ {
 	if s.perspective == protocol.PerspectiveServer {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropUnexpectedPacket)
-		}
+		s.droppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropUnexpectedPacket)
 		s.logger.Debugf("Ignoring Retry.")
 		return false
 	}
 	if s.receivedFirstPacket {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropUnexpectedPacket)
-		}
+		s.droppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropUnexpectedPacket)
 		s.logger.Debugf("Ignoring Retry, since we already received a packet.")
 		return false
 	}
 	destConnID := s.connIDManager.Get()
 	if hdr.SrcConnectionID.Equal(destConnID) {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropUnexpectedPacket)
-		}
+		s.droppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropUnexpectedPacket)
 		s.logger.Debugf("Ignoring Retry, since the server didn't change the Source Connection ID.")
 		return false
 	}
@@ -1025,9 +1234,7 @@ func (s *connection) handleRetryPacket(hdr *wire.Header, data []byte) bool /* wa
 
 	tag := handshake.GetRetryIntegrityTag(data[:len(data)-16], destConnID, hdr.Version)
 	if !bytes.Equal(data[len(data)-16:], tag[:]) {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropPayloadDecryptError)
-		}
+		s.droppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(data)), logging.PacketDropPayloadDecryptError)
 		s.logger.Debugf("Ignoring spoofed Retry. Integrity Tag doesn't match.")
 		return false
 	}
@@ -1058,26 +1265,20 @@ func (s *connection) handleRetryPacket(hdr *wire.Header, data []byte) bool /* wa
 func (s *connection) handleVersionNegotiationPacket(p *receivedPacket) {
 	if s.perspective == protocol.PerspectiveServer || // servers never receive version negotiation packets
 		s.receivedFirstPacket || s.versionNegotiated { // ignore delayed / duplicated version negotiation packets
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeVersionNegotiation, p.Size(), logging.PacketDropUnexpectedPacket)
-		}
+		s.droppedPacket(logging.PacketTypeVersionNegotiation, p.Size(), logging.PacketDropUnexpectedPacket)
 		return
 	}
 
 	hdr, supportedVersions, err := wire.ParseVersionNegotiationPacket(bytes.NewReader(p.data))
 	if err != nil {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeVersionNegotiation, p.Size(), logging.PacketDropHeaderParseError)
-		}
+		s.droppedPacket(logging.PacketTypeVersionNegotiation, p.Size(), logging.PacketDropHeaderParseError)
 		s.logger.Debugf("Error parsing Version Negotiation packet: %s", err)
 		return
 	}
 
 	for _, v := range supportedVersions {
 		if v == s.version {
-			if s.tracer != nil {
-				s.tracer.DroppedPacket(logging.PacketTypeVersionNegotiation, p.Size(), logging.PacketDropUnexpectedVersion)
-			}
+			s.droppedPacket(logging.PacketTypeVersionNegotiation, p.Size(), logging.PacketDropUnexpectedVersion)
 			// The Version Negotiation packet contains the version that we offered.
 			// This might be a packet sent by an attacker, or it was corrupted.
 			return
@@ -1111,9 +1312,10 @@ func (s *connection) handleVersionNegotiationPacket(p *receivedPacket) {
 
 func (s *connection) handleUnpackedPacket(
 	packet *unpackedPacket,
+	newPath sendConn, // only set if the packet was received from a new address
 	ecn protocol.ECN,
 	rcvTime time.Time,
-	packetSize protocol.ByteCount, // only for logging
+	packetSize protocol.ByteCount,
 ) error {
 	if len(packet.data) == 0 {
 		return &qerr.TransportError{
@@ -1165,12 +1367,13 @@ func (s *connection) handleUnpackedPacket(
 	s.lastPacketReceivedTime = rcvTime
 	s.firstAckElicitingPacketAfterIdleSentTime = time.Time{}
 	s.keepAlivePingSent = false
+	s.packetsReceived++
 
 	// Only used for tracing.
 	// If we're not tracing, this slice will always remain empty.
 	var frames []wire.Frame
 	r := bytes.NewReader(packet.data)
-	var isAckEliciting bool
+	var isAckEliciting, isNonProbing bool
 	for {
 		frame, err := s.frameParser.ParseNext(r, packet.encryptionLevel)
 		if err != nil {
@@ -1182,10 +1385,13 @@ func (s *connection) handleUnpackedPacket(
 		if ackhandler.IsFrameAckEliciting(frame) {
 			isAckEliciting = true
 		}
+		if !isProbingFrame(frame) {
+			isNonProbing = true
+		}
 		// Only process frames now if we're not logging.
 		// If we're logging, we need to make sure that the packet_received event is logged first.
 		if s.tracer == nil {
-			if err := s.handleFrame(frame, packet.encryptionLevel, packet.hdr.DestConnectionID); err != nil {
+			if err := s.handleFrameOnPath(frame, packet.encryptionLevel, packet.hdr.DestConnectionID, newPath, packetSize); err != nil {
 				return err
 			}
 		} else {
@@ -1200,13 +1406,45 @@ func (s *connection) handleUnpackedPacket(
 		}
 		s.tracer.ReceivedPacket(packet.hdr, packetSize, fs)
 		for _, frame := range frames {
-			if err := s.handleFrame(frame, packet.encryptionLevel, packet.hdr.DestConnectionID); err != nil {
+			if err := s.handleFrameOnPath(frame, packet.encryptionLevel, packet.hdr.DestConnectionID, newPath, packetSize); err != nil {
 				return err
 			}
 		}
 	}
 
-	return s.receivedPacketHandler.ReceivedPacket(packet.packetNumber, ecn, packet.encryptionLevel, rcvTime, isAckEliciting)
+	if err := s.receivedPacketHandler.ReceivedPacket(packet.packetNumber, ecn, packet.encryptionLevel, rcvTime, isAckEliciting); err != nil {
+		return err
+	}
+	if packet.encryptionLevel == protocol.Encryption1RTT && packet.packetNumber > s.largestRcvdPacketNumber {
+		s.largestRcvdPacketNumber = packet.packetNumber
+		// Only non-probing packets with the highest packet number cause us to switch to the new path.
+		if newPath != nil && isNonProbing {
+			s.handlePeerMigration(newPath, rcvTime, packetSize)
+		}
+	}
+	return nil
+}
+
+// handleFrameOnPath handles a frame.
+// PATH_CHALLENGE frames received from a new address are answered on that path.
+// Since that address hasn't been validated, the PATH_RESPONSE is at most 3 times as large as the packet received.
+func (s *connection) handleFrameOnPath(f wire.Frame, encLevel protocol.EncryptionLevel, destConnID protocol.ConnectionID, path sendConn, packetSize protocol.ByteCount) error {
+	if frame, ok := f.(*wire.PathChallengeFrame); ok && path != nil {
+		wire.LogFrame(s.logger, f, false)
+		return s.sendPathResponse(path, frame, utils.MinByteCount(migrationAmplificationFactor*packetSize, protocol.MinInitialPacketSize))
+	}
+	return s.handleFrame(f, encLevel, destConnID)
+}
+
+// isProbingFrame says if a frame is a probing frame, as defined in section 9.1 of RFC 9000.
+// PADDING frames are also probing frames, but they are never returned by the frame parser.
+func isProbingFrame(f wire.Frame) bool {
+	switch f.(type) {
+	case *wire.PathChallengeFrame, *wire.PathResponseFrame, *wire.NewConnectionIDFrame:
+		return true
+	default:
+		return false
+	}
 }
 
 func (s *connection) handleFrame(f wire.Frame, encLevel protocol.EncryptionLevel, destConnID protocol.ConnectionID) error {
@@ -1238,8 +1476,7 @@ func (s *connection) handleFrame(f wire.Frame, encLevel protocol.EncryptionLevel
 	case *wire.PathChallengeFrame:
 		s.handlePathChallengeFrame(frame)
 	case *wire.PathResponseFrame:
-		// since we don't send PATH_CHALLENGEs, we don't expect PATH_RESPONSEs
-		err = errors.New("unexpected PATH_RESPONSE frame")
+		s.handlePathResponseFrame(frame)
 	case *wire.NewTokenFrame:
 		err = s.handleNewTokenFrame(frame)
 	case *wire.NewConnectionIDFrame:
@@ -1250,6 +1487,14 @@ func (s *connection) handleFrame(f wire.Frame, encLevel protocol.EncryptionLevel
 		err = s.handleHandshakeDoneFrame()
 	case *wire.DatagramFrame:
 		err = s.handleDatagramFrame(frame)
+	case *wire.AckFrequencyFrame:
+		err = s.handleAckFrequencyFrame(frame)
+	case *wire.ImmediateAckFrame:
+		s.receivedPacketHandler.ReceivedImmediateAckFrame()
+	case *wire.PathAbandonFrame:
+		s.handlePathAbandonFrame(frame)
+	case *wire.ExpiredStreamDataFrame:
+		err = s.handleExpiredStreamDataFrame(frame)
 	default:
 		err = fmt.Errorf("unexpected frame type: %s", reflect.ValueOf(&frame).Elem().Type().Name())
 	}
@@ -1263,9 +1508,7 @@ func (s *connection) handlePacket(p *receivedPacket) {
 	select {
 	case s.receivedPackets <- p:
 	default:
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeNotDetermined, p.Size(), logging.PacketDropDOSPrevention)
-		}
+		s.droppedPacket(logging.PacketTypeNotDetermined, p.Size(), logging.PacketDropDOSPrevention)
 	}
 }
 
@@ -1345,6 +1588,18 @@ func (s *connection) handleResetStreamFrame(frame *wire.ResetStreamFrame) error
 	return str.handleResetStreamFrame(frame)
 }
 
+func (s *connection) handleExpiredStreamDataFrame(frame *wire.ExpiredStreamDataFrame) error {
+	str, err := s.streamsMap.GetOrOpenReceiveStream(frame.StreamID)
+	if err != nil {
+		return err
+	}
+	if str == nil {
+		// stream is closed and already garbage collected
+		return nil
+	}
+	return str.handleExpiredStreamDataFrame(frame)
+}
+
 func (s *connection) handleStopSendingFrame(frame *wire.StopSendingFrame) error {
 	str, err := s.streamsMap.GetOrOpenSendStream(frame.StreamID)
 	if err != nil {
@@ -1362,6 +1617,30 @@ func (s *connection) handlePathChallengeFrame(frame *wire.PathChallengeFrame) {
 	s.queueControlFrame(&wire.PathResponseFrame{Data: frame.Data})
 }
 
+func (s *connection) handlePathResponseFrame(frame *wire.PathResponseFrame) {
+	// PATH_RESPONSEs that don't match any of the PATH_CHALLENGEs we sent are ignored.
+	// This happens when a PATH_RESPONSE arrives after path validation already completed.
+	if s.peerMigration != nil {
+		if _, ok := s.peerMigration.probe.ReceivedResponse(frame.Data, time.Now()); ok {
+			s.logger.Infof("Validated new peer address %s", s.peerMigration.probe.conn.RemoteAddr())
+			s.peerMigration = nil
+		}
+		return
+	}
+	if s.pathProbe == nil {
+		return
+	}
+	rtt, ok := s.pathProbe.ReceivedResponse(frame.Data, time.Now())
+	if !ok || s.pathProbe.isAborted() {
+		return
+	}
+	if s.pathProbe.probeOnly {
+		s.completePathProbe(rtt)
+		return
+	}
+	s.switchToProbedPath(rtt)
+}
+
 func (s *connection) handleNewTokenFrame(frame *wire.NewTokenFrame) error {
 	if s.perspective == protocol.PerspectiveServer {
 		return &qerr.TransportError{
@@ -1421,6 +1700,17 @@ func (s *connection) handleDatagramFrame(f *wire.DatagramFrame) error {
 	return nil
 }
 
+func (s *connection) handleAckFrequencyFrame(f *wire.AckFrequencyFrame) error {
+	if f.UpdateMaxAckDelay < protocol.MinAckDelay {
+		return &qerr.TransportError{
+			ErrorCode:    qerr.ProtocolViolation,
+			ErrorMessage: "ACK_FREQUENCY frame with a max ack delay smaller than the min_ack_delay",
+		}
+	}
+	s.receivedPacketHandler.ReceivedAckFrequencyFrame(f)
+	return nil
+}
+
 // closeLocal closes the connection and send a CONNECTION_CLOSE containing the error
 func (s *connection) closeLocal(e error) {
 	s.closeOnce.Do(func() {
@@ -1622,6 +1912,9 @@ func (s *connection) applyTransportParameters() {
 	s.frameParser.SetAckDelayExponent(params.AckDelayExponent)
 	s.connFlowController.UpdateSendWindow(params.InitialMaxData)
 	s.rttStats.SetMaxAckDelay(params.MaxAckDelay)
+	if s.supportsAckFrequency() {
+		s.sentPacketHandler.EnableAckFrequency(*params.MinAckDelay)
+	}
 	s.connIDGenerator.SetMaxActiveConnIDs(params.ActiveConnectionIDLimit)
 	if params.StatelessResetToken != nil {
 		s.connIDManager.SetStatelessResetToken(*params.StatelessResetToken)
@@ -1635,9 +1928,15 @@ func (s *connection) applyTransportParameters() {
 
 func (s *connection) sendPackets() error {
 	s.pacingDeadline = time.Time{}
+	if len(s.paths) > 1 {
+		return s.sendPacketsMultipath(time.Now())
+	}
 
 	var sentPacket bool // only used in for packets sent in send mode SendAny
 	for {
+		if s.amplificationLimited() {
+			return nil
+		}
 		sendMode := s.sentPacketHandler.SendMode()
 		if sendMode == ackhandler.SendAny && s.handshakeComplete && !s.sentPacketHandler.HasPacingBudget() {
 			deadline := s.sentPacketHandler.TimeUntilSend()
@@ -1776,6 +2075,11 @@ func (s *connection) sendPacket() (bool, error) {
 		s.sendQueue.Send(packet.buffer)
 		return true, nil
 	}
+	if s.supportsAckFrequency() {
+		if f := s.sentPacketHandler.GetAckFrequencyFrame(now); f != nil {
+			s.framer.QueueControlFrame(f)
+		}
+	}
 	if !s.config.DisablePathMTUDiscovery && s.mtuDiscoverer.ShouldSendProbe(now) {
 		packet, err := s.packer.PackMTUProbePacket(s.mtuDiscoverer.GetPing())
 		if err != nil {
@@ -1799,90 +2103,898 @@ func (s *connection) sendPackedPacket(packet *packedPacket, now time.Time) {
 	s.logPacket(packet)
 	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket(now, s.retransmissionQueue))
 	s.connIDManager.SentPacket()
+	if s.peerMigration != nil {
+		s.peerMigration.SentBytes(packet.buffer.Len())
+	}
 	s.sendQueue.Send(packet.buffer)
 }
 
-func (s *connection) sendConnectionClose(e error) ([]byte, error) {
-	var packet *coalescedPacket
-	var err error
-	var transportErr *qerr.TransportError
-	var applicationErr *qerr.ApplicationError
-	if errors.As(e, &transportErr) {
-		packet, err = s.packer.PackConnectionClose(transportErr)
-	} else if errors.As(e, &applicationErr) {
-		packet, err = s.packer.PackApplicationClose(applicationErr)
-	} else {
-		packet, err = s.packer.PackConnectionClose(&qerr.TransportError{
-			ErrorCode:    qerr.InternalError,
-			ErrorMessage: fmt.Sprintf("connection BUG: unspecified error type (msg: %s)", e.Error()),
-		})
+func (s *connection) addConnectionID(connID protocol.ConnectionID) {
+	s.runner.Add(connID, s)
+	// Packets sent on the path that's currently being validated might use the new connection ID as well.
+	if s.pathProbe != nil && s.pathProbe.runner != s.runner && s.pathProbe.runner.Add(connID, s) {
+		s.pathProbe.connIDs = append(s.pathProbe.connIDs, connID)
 	}
-	if err != nil {
-		return nil, err
+	// The peer might use the new connection ID on any of the additional paths.
+	for _, p := range s.paths {
+		if p.runner != s.runner {
+			p.runner.Add(connID, s)
+		}
 	}
-	s.logCoalescedPacket(packet)
-	return packet.buffer.Data, s.conn.Write(packet.buffer.Data)
 }
 
-func (s *connection) logPacketContents(p *packetContents) {
-	// tracing
-	if s.tracer != nil {
-		frames := make([]logging.Frame, 0, len(p.frames))
-		for _, f := range p.frames {
-			frames = append(frames, logutils.ConvertFrame(f.Frame))
+func (s *connection) startPathProbe(probe *pathProbe) {
+	action := "migrate"
+	if probe.probeOnly {
+		action = "probe a path"
+	}
+	var err error
+	if s.pathProbe != nil {
+		err = errors.New("path validation already in progress")
+	} else if !s.handshakeConfirmed {
+		err = fmt.Errorf("cannot %s before the handshake is confirmed", action)
+	} else if s.peerParams.DisableActiveMigration {
+		err = errors.New("peer disabled active migration")
+	} else if s.paths != nil {
+		err = fmt.Errorf("cannot %s on a multipath connection", action)
+	} else if _, ok := s.connIDManager.PeekNext(); !ok {
+		err = errors.New("no unused connection ID available")
+	}
+	if err != nil {
+		probe.complete(err)
+		return
+	}
+	if probe.runner != s.runner {
+		for _, connID := range s.connIDGenerator.ActiveConnIDs() {
+			if probe.runner.Add(connID, s) {
+				probe.connIDs = append(probe.connIDs, connID)
+			}
 		}
-		s.tracer.SentPacket(p.header, p.length, p.ack, frames)
 	}
+	s.logger.Debugf("Starting validation of path %s -> %s", probe.conn.LocalAddr(), probe.conn.RemoteAddr())
+	s.pathProbe = probe
+}
 
-	// quic-go logging
-	if !s.logger.Debug() {
-		return
+func (s *connection) maybeSendPathChallenge(now time.Time) error {
+	probe := s.pathProbe
+	if probe.isAborted() {
+		s.failPathProbe(context.Canceled)
+		return nil
 	}
-	p.header.Log(s.logger)
-	if p.ack != nil {
-		wire.LogFrame(s.logger, p.ack, true)
+	if probe.TimedOut(now) {
+		s.logger.Debugf("Validation of path %s -> %s failed", probe.conn.LocalAddr(), probe.conn.RemoteAddr())
+		s.failPathProbe(errPathValidationFailed)
+		return nil
 	}
-	for _, frame := range p.frames {
-		wire.LogFrame(s.logger, frame.Frame, true)
+	if !probe.ShouldSendChallenge(now) {
+		return nil
 	}
+	data, err := probe.NewChallenge(now, s.rttStats)
+	if err != nil {
+		return err
+	}
+	connID, _ := s.connIDManager.PeekNext()
+	packet, err := s.packer.PackPathProbePacket(connID, ackhandler.Frame{
+		Frame:  &wire.PathChallengeFrame{Data: data},
+		OnLost: func(wire.Frame) {}, // PATH_CHALLENGEs are not retransmitted, new PATH_CHALLENGEs are sent instead
+	}, protocol.MinInitialPacketSize)
+	if err != nil {
+		return err
+	}
+	if err := s.writePacket(probe.conn, s.sentPacketHandler, packet, now); err != nil {
+		s.logger.Debugf("Sending PATH_CHALLENGE on path %s -> %s failed: %s", probe.conn.LocalAddr(), probe.conn.RemoteAddr(), err)
+		s.failPathProbe(err)
+	}
+	return nil
 }
 
-func (s *connection) logCoalescedPacket(packet *coalescedPacket) {
-	if s.logger.Debug() {
-		if len(packet.packets) > 1 {
-			s.logger.Debugf("-> Sending coalesced packet (%d parts, %d bytes) for connection %s", len(packet.packets), packet.buffer.Len(), s.logID)
-		} else {
-			s.logger.Debugf("-> Sending packet %d (%d bytes) for connection %s, %s", packet.packets[0].header.PacketNumber, packet.buffer.Len(), s.logID, packet.packets[0].EncryptionLevel())
+func (s *connection) failPathProbe(e error) {
+	probe := s.pathProbe
+	s.pathProbe = nil
+	if probe.runner != s.runner {
+		probe.runner.RemoveHandler(s)
+	}
+	if e == nil {
+		e = &qerr.ApplicationError{}
+	}
+	probe.complete(e)
+}
+
+// completePathProbe is called when a path that was only probed was successfully validated.
+// The connection keeps using the current path.
+func (s *connection) completePathProbe(rtt time.Duration) {
+	probe := s.pathProbe
+	s.pathProbe = nil
+	if probe.runner != s.runner {
+		probe.runner.RemoveHandler(s)
+	}
+	s.logger.Infof("Validated path %s -> %s (RTT: %s)", probe.conn.LocalAddr(), probe.conn.RemoteAddr(), rtt)
+	probe.rtt = rtt
+	probe.complete(nil)
+}
+
+// switchToProbedPath is called when the path that is currently being validated was successfully validated.
+func (s *connection) switchToProbedPath(rtt time.Duration) {
+	probe := s.pathProbe
+	s.pathProbe = nil
+	if probe.runner != s.runner {
+		// This also removes connection IDs that were retired, but not yet deleted.
+		// Otherwise, closing the old packet conn would close this connection.
+		s.runner.RemoveHandler(s)
+		s.runner = probe.runner
+		active := make(map[string]struct{})
+		for _, connID := range s.connIDGenerator.ActiveConnIDs() {
+			active[string(connID)] = struct{}{}
+			s.runner.Add(connID, s)
+		}
+		// connection IDs that were retired while path validation was in progress
+		for _, connID := range probe.connIDs {
+			if _, ok := active[string(connID)]; !ok {
+				s.runner.Remove(connID)
+			}
 		}
 	}
-	for _, p := range packet.packets {
-		s.logPacketContents(p)
+	s.connIDManager.SwitchToNext()
+	s.logger.Infof("Migrating connection to path %s -> %s (RTT: %s)", probe.conn.LocalAddr(), probe.conn.RemoteAddr(), rtt)
+	s.conn.Switch(probe.conn)
+	s.resetPathState(time.Now(), rtt)
+	probe.rtt = rtt
+	probe.complete(nil)
+}
+
+// handlePeerMigration is called when the peer sends a non-probing packet from a new address.
+// The new address is validated, see section 9.3 of RFC 9000.
+// Until validation succeeds, at most 3 times the amount of data received from that address is sent there.
+func (s *connection) handlePeerMigration(path sendConn, now time.Time, packetSize protocol.ByteCount) {
+	oldRemoteAddr := s.conn.RemoteAddr()
+	s.logger.Infof("Peer migrated from %s to %s", oldRemoteAddr, path.RemoteAddr())
+	if s.paths == nil {
+		s.connIDManager.SwitchToNext()
+	}
+	lastValidated := s.conn.get()
+	if s.peerMigration != nil {
+		lastValidated = s.peerMigration.lastValidated
+	}
+	s.conn.Switch(path)
+	if isSameAddr(path.RemoteAddr(), lastValidated.RemoteAddr(), false) {
+		// The peer migrated back to the last validated address before validation of the new address completed.
+		s.peerMigration = nil
+	} else {
+		s.peerMigration = newPeerMigration(path, lastValidated, s.runner, packetSize)
+	}
+	// If only the port changed, this is most likely a NAT rebinding.
+	// The congestion controller state and the RTT estimate can then be kept.
+	if !isSameAddr(oldRemoteAddr, path.RemoteAddr(), true) {
+		s.resetPathState(now, 0)
 	}
 }
 
-func (s *connection) logPacket(packet *packedPacket) {
-	if s.logger.Debug() {
-		s.logger.Debugf("-> Sending packet %d (%d bytes) for connection %s, %s", packet.header.PacketNumber, packet.buffer.Len(), s.logID, packet.EncryptionLevel())
+func (s *connection) maybeSendPeerMigrationChallenge(now time.Time) error {
+	m := s.peerMigration
+	if m.probe.TimedOut(now) {
+		s.failPeerMigration(now)
+		return nil
 	}
-	s.logPacketContents(packet.packetContents)
+	if !m.probe.ShouldSendChallenge(now) {
+		return nil
+	}
+	data, err := m.probe.NewChallenge(now, s.rttStats)
+	if err != nil {
+		return err
+	}
+	packet, err := s.packer.PackPathProbePacket(s.connIDManager.Get(), ackhandler.Frame{
+		Frame:  &wire.PathChallengeFrame{Data: data},
+		OnLost: func(wire.Frame) {}, // PATH_CHALLENGEs are not retransmitted, new PATH_CHALLENGEs are sent instead
+	}, utils.MinByteCount(m.SendBudget(), protocol.MinInitialPacketSize))
+	if err != nil {
+		return err
+	}
+	// Even without any padding, the packet might be too large.
+	// The PATH_CHALLENGE will be sent with the next one, if more data was received from the peer by then.
+	if packet.buffer.Len() > m.SendBudget() {
+		s.logger.Debugf("Not sending PATH_CHALLENGE to %s, amplification limited.", m.probe.conn.RemoteAddr())
+		packet.buffer.Release()
+		return nil
+	}
+	m.SentBytes(packet.buffer.Len())
+	if err := s.writePacket(m.probe.conn, s.sentPacketHandler, packet, now); err != nil {
+		s.logger.Debugf("Sending PATH_CHALLENGE to %s failed: %s", m.probe.conn.RemoteAddr(), err)
+	}
+	return nil
 }
 
-// AcceptStream returns the next stream openend by the peer
-func (s *connection) AcceptStream(ctx context.Context) (Stream, error) {
-	return s.streamsMap.AcceptStream(ctx)
+// failPeerMigration is called when validation of the new address of the peer failed.
+// The connection then continues using the last validated address, see section 9.3.2 of RFC 9000.
+func (s *connection) failPeerMigration(now time.Time) {
+	m := s.peerMigration
+	s.peerMigration = nil
+	newRemoteAddr := s.conn.RemoteAddr()
+	s.logger.Infof("Validation of new peer address %s failed. Switching back to %s.", newRemoteAddr, m.lastValidated.RemoteAddr())
+	s.conn.Switch(m.lastValidated)
+	if !isSameAddr(newRemoteAddr, m.lastValidated.RemoteAddr(), true) {
+		s.resetPathState(now, 0)
+	}
 }
 
-func (s *connection) AcceptUniStream(ctx context.Context) (ReceiveStream, error) {
-	return s.streamsMap.AcceptUniStream(ctx)
+// amplificationLimited says if sending a packet to the peer might exceed the anti-amplification limit,
+// because the peer migrated to an address that hasn't been validated yet.
+func (s *connection) amplificationLimited() bool {
+	return s.peerMigration != nil && s.peerMigration.SendBudget() < protocol.MaxPacketBufferSize
 }
 
-// OpenStream opens a stream
-func (s *connection) OpenStream() (Stream, error) {
-	return s.streamsMap.OpenStream()
+// resetPathState resets the congestion controller and the RTT estimate, and restarts Path MTU discovery.
+// It is called after the connection migrated to a new path.
+func (s *connection) resetPathState(now time.Time, rtt time.Duration) {
+	maxPacketSize := getMaxPacketSize(s.conn.RemoteAddr())
+	if s.peerParams.MaxUDPPayloadSize != 0 {
+		maxPacketSize = utils.MinByteCount(maxPacketSize, s.peerParams.MaxUDPPayloadSize)
+	}
+	s.sentPacketHandler.MigratedPath(now, rtt, maxPacketSize)
+	s.packer.SetMaxPacketSize(maxPacketSize)
+	if !s.config.DisablePathMTUDiscovery {
+		s.startMTUDiscovery()
+	}
 }
 
-func (s *connection) OpenStreamSync(ctx context.Context) (Stream, error) {
-	return s.streamsMap.OpenStreamSync(ctx)
+func (s *connection) sendPathResponse(path sendConn, f *wire.PathChallengeFrame, size protocol.ByteCount) error {
+	// Use a new connection ID on the new path, if the peer provided one.
+	// When using multipath, unused connection IDs are reserved for additional paths.
+	connID, ok := s.connIDManager.PeekNext()
+	if !ok || s.paths != nil {
+		connID = s.connIDManager.Get()
+	}
+	packet, err := s.packer.PackPathProbePacket(connID, ackhandler.Frame{
+		Frame:  &wire.PathResponseFrame{Data: f.Data},
+		OnLost: func(wire.Frame) {}, // PATH_RESPONSEs are never retransmitted
+	}, size)
+	if err != nil {
+		return err
+	}
+	if err := s.writePacket(path, s.sentPacketHandler, packet, time.Now()); err != nil {
+		s.logger.Debugf("Sending PATH_RESPONSE to %s failed: %s", path.RemoteAddr(), err)
+	}
+	return nil
+}
+
+// writePacket sends a packet on a path other than the one used by the send queue.
+// These packets bypass the send queue.
+func (s *connection) writePacket(path sendConn, sph ackhandler.SentPacketHandler, packet *packedPacket, now time.Time) error {
+	s.logPacket(packet)
+	sph.SentPacket(packet.ToAckHandlerPacket(now, s.retransmissionQueue))
+	err := path.Write(packet.buffer.Data)
+	packet.buffer.Release()
+	return err
+}
+
+// startMultipath is called when the handshake is confirmed, if both endpoints support multipath.
+func (s *connection) startMultipath() {
+	s.paths = map[PathID]*path{
+		initialPathID: {
+			id:                      initialPathID,
+			conn:                    s.conn,
+			runner:                  s.runner,
+			rcvSeqKnown:             true,
+			rttStats:                s.rttStats,
+			sentPacketHandler:       s.sentPacketHandler,
+			receivedPacketHandler:   s.receivedPacketHandler,
+			packer:                  s.packer,
+			largestRcvdPacketNumber: protocol.InvalidPacketNumber,
+			validated:               true,
+		},
+	}
+	s.nextPathID = initialPathID + 1
+	s.pathScheduler = newMultipathScheduler(s.config)
+}
+
+// newPath creates an additional path, which sends packets to the given connection ID.
+func (s *connection) newPath(conn sendConn, runner connRunner, connID utils.NewConnectionID) *path {
+	rttStats := &utils.RTTStats{}
+	rttStats.SetMaxAckDelay(s.peerParams.MaxAckDelay)
+	sph, rph := ackhandler.NewPathAckHandler(
+		getMaxPacketSize(conn.RemoteAddr()),
+		congestionConfig(s.config),
+		rttStats,
+		s.perspective,
+		s.logger,
+		s.version,
+	)
+	p := &path{
+		id:                      s.nextPathID,
+		conn:                    conn,
+		runner:                  runner,
+		destConnID:              connID.ConnectionID,
+		destSeq:                 connID.SequenceNumber,
+		rttStats:                rttStats,
+		sentPacketHandler:       sph,
+		receivedPacketHandler:   rph,
+		largestRcvdPacketNumber: protocol.InvalidPacketNumber,
+	}
+	s.nextPathID++
+	packer := newPacketPacker(
+		nil,
+		func() protocol.ConnectionID { return p.destConnID },
+		nil,
+		nil,
+		sph,
+		s.retransmissionQueue,
+		conn.RemoteAddr(),
+		&pathSealingManager{sealingManager: s.sealingManager, pathID: p.destSeq},
+		s.framer,
+		rph,
+		s.datagramQueue,
+		s.config.DatagramPriority,
+		s.perspective,
+		s.version,
+	)
+	packer.HandleTransportParameters(s.peerParams)
+	p.packer = packer
+	return p
+}
+
+func (s *connection) startAddingPath(probe *pathProbe) {
+	var err error
+	var connID utils.NewConnectionID
+	if !s.handshakeConfirmed {
+		err = errors.New("cannot add a path before the handshake is confirmed")
+	} else if s.paths == nil {
+		err = errors.New("peer doesn't support multipath")
+	} else if c, ok := s.connIDManager.Claim(); !ok {
+		err = errors.New("no unused connection ID available")
+	} else {
+		connID = c
+	}
+	if err != nil {
+		probe.complete(err)
+		return
+	}
+	if probe.runner != s.runner {
+		for _, c := range s.connIDGenerator.ActiveConnIDs() {
+			probe.runner.Add(c, s)
+		}
+	}
+	p := s.newPath(probe.conn, probe.runner, connID)
+	p.probe = probe
+	probe.pathID = p.id
+	s.paths[p.id] = p
+	s.logger.Debugf("Starting validation of path %d: %s -> %s", p.id, p.conn.LocalAddr(), p.conn.RemoteAddr())
+}
+
+// newPathFromPacket is called when a packet is received on a path that we don't know yet.
+// For the server, this is a new path opened by the client.
+// For the client, this is a path it is currently validating, and the packet contains the PATH_RESPONSE to one of its PATH_CHALLENGEs.
+func (s *connection) newPathFromPacket(rcvSeq uint64, frames []wire.Frame, rp *receivedPacket) *path {
+	if s.perspective == protocol.PerspectiveClient {
+		for _, p := range s.paths {
+			if p.rcvSeqKnown || p.probe == nil {
+				continue
+			}
+			for _, f := range frames {
+				if frame, ok := f.(*wire.PathResponseFrame); ok {
+					if _, ok := p.probe.ReceivedResponse(frame.Data, rp.rcvTime); ok {
+						p.rcvSeq = rcvSeq
+						p.rcvSeqKnown = true
+						return p
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	conn := sendConnWithRemoteAddr(s.conn, rp.remoteAddr, rp.info)
+	if conn == nil {
+		return nil
+	}
+	connID, ok := s.connIDManager.Claim()
+	if !ok {
+		s.logger.Debugf("No unused connection ID available for the new path from %s", rp.remoteAddr)
+		return nil
+	}
+	p := s.newPath(conn, s.runner, connID)
+	p.rcvSeq = rcvSeq
+	p.rcvSeqKnown = true
+	p.probe = newPathProbe(conn, s.runner)
+	s.paths[p.id] = p
+	s.logger.Infof("Peer opened path %d: %s -> %s", p.id, conn.LocalAddr(), conn.RemoteAddr())
+	return p
+}
+
+// handlePathPacket handles a packet received on an additional path, when using multipath.
+// rcvSeq is the sequence number of the connection ID that the packet was sent to.
+func (s *connection) handlePathPacket(packet *unpackedPacket, rcvSeq uint64, rp *receivedPacket) (bool /* was the packet successfully processed */, error) {
+	if len(packet.data) == 0 {
+		return false, &qerr.TransportError{
+			ErrorCode:    qerr.ProtocolViolation,
+			ErrorMessage: "empty packet",
+		}
+	}
+
+	p := s.pathByRcvSeq(rcvSeq)
+	if p != nil && p.receivedPacketHandler.IsPotentiallyDuplicate(packet.packetNumber, protocol.Encryption1RTT) {
+		s.logger.Debugf("Dropping (potentially) duplicate packet.")
+		s.droppedPacket(logging.PacketType1RTT, rp.Size(), logging.PacketDropDuplicate)
+		return false, nil
+	}
+
+	// Parse all frames first: the client needs the PATH_RESPONSE to find out which path the packet belongs to.
+	var frames []wire.Frame
+	r := bytes.NewReader(packet.data)
+	for {
+		frame, err := s.frameParser.ParseNext(r, protocol.Encryption1RTT)
+		if err != nil {
+			return false, err
+		}
+		if frame == nil {
+			break
+		}
+		frames = append(frames, frame)
+	}
+	if p == nil {
+		if p = s.newPathFromPacket(rcvSeq, frames, rp); p == nil {
+			s.droppedPacket(logging.PacketType1RTT, rp.Size(), logging.PacketDropUnknownConnectionID)
+			s.logger.Debugf("Dropping packet (%d bytes) for an unknown path (connection ID sequence number %d)", rp.Size(), rcvSeq)
+			return false, nil
+		}
+	}
+
+	s.lastPacketReceivedTime = rp.rcvTime
+	s.firstAckElicitingPacketAfterIdleSentTime = time.Time{}
+	s.keepAlivePingSent = false
+	s.packetsReceived++
+
+	if s.tracer != nil {
+		fs := make([]logging.Frame, len(frames))
+		for i, frame := range frames {
+			fs[i] = logutils.ConvertFrame(frame)
+		}
+		s.tracer.ReceivedPacket(packet.hdr, rp.Size(), fs)
+	}
+	var isAckEliciting bool
+	for _, frame := range frames {
+		if ackhandler.IsFrameAckEliciting(frame) {
+			isAckEliciting = true
+		}
+		if err := s.handlePathFrame(p, frame, packet.hdr.DestConnectionID, rp.rcvTime); err != nil {
+			return false, err
+		}
+	}
+	if err := p.receivedPacketHandler.ReceivedPacket(packet.packetNumber, rp.ecn, protocol.Encryption1RTT, rp.rcvTime, isAckEliciting); err != nil {
+		return false, err
+	}
+	if packet.packetNumber > p.largestRcvdPacketNumber {
+		p.largestRcvdPacketNumber = packet.packetNumber
+	}
+	return true, nil
+}
+
+// handlePathFrame handles a frame received on an additional path.
+// ACK frames acknowledge packets sent on the same path, and PATH_CHALLENGEs are answered on that path.
+func (s *connection) handlePathFrame(p *path, f wire.Frame, destConnID protocol.ConnectionID, rcvTime time.Time) error {
+	switch frame := f.(type) {
+	case *wire.AckFrame:
+		wire.LogFrame(s.logger, f, false)
+		_, err := p.sentPacketHandler.ReceivedAck(frame, protocol.Encryption1RTT, rcvTime)
+		return err
+	case *wire.PathChallengeFrame:
+		wire.LogFrame(s.logger, f, false)
+		packet, err := p.packer.PackPathProbePacket(p.destConnID, ackhandler.Frame{
+			Frame:  &wire.PathResponseFrame{Data: frame.Data},
+			OnLost: func(wire.Frame) {}, // PATH_RESPONSEs are never retransmitted
+		}, protocol.MinInitialPacketSize)
+		if err != nil {
+			return err
+		}
+		if err := s.writePacket(p.conn, p.sentPacketHandler, packet, rcvTime); err != nil {
+			s.logger.Debugf("Sending PATH_RESPONSE on path %d failed: %s", p.id, err)
+		}
+		return nil
+	case *wire.PathResponseFrame:
+		wire.LogFrame(s.logger, f, false)
+		s.handlePathResponseFrameOnPath(p, frame, rcvTime)
+		return nil
+	default:
+		return s.handleFrame(f, protocol.Encryption1RTT, destConnID)
+	}
+}
+
+func (s *connection) handlePathResponseFrameOnPath(p *path, frame *wire.PathResponseFrame, now time.Time) {
+	if p.probe == nil {
+		return
+	}
+	rtt, ok := p.probe.ReceivedResponse(frame.Data, now)
+	if !ok || p.probe.isAborted() {
+		return
+	}
+	p.rttStats.UpdateRTT(rtt, 0, now)
+	p.validated = true
+	s.logger.Infof("Validated path %d: %s -> %s (RTT: %s)", p.id, p.conn.LocalAddr(), p.conn.RemoteAddr(), rtt)
+	probe := p.probe
+	p.probe = nil
+	probe.complete(nil)
+}
+
+func (s *connection) handlePathAbandonFrame(frame *wire.PathAbandonFrame) {
+	// The path identifier is the sequence number of the connection ID that the peer sends to on that path.
+	for _, p := range s.paths {
+		if p.id != initialPathID && p.rcvSeqKnown && p.rcvSeq == frame.PathIdentifier {
+			s.logger.Infof("Peer abandoned path %d", p.id)
+			s.abandonPath(p, errPathAbandoned, false)
+			return
+		}
+	}
+}
+
+// handlePathTimeouts handles the loss detection timers of the additional paths,
+// and sends PATH_CHALLENGEs on paths that are currently being validated.
+func (s *connection) handlePathTimeouts(now time.Time) error {
+	for _, p := range s.paths {
+		if p.id == initialPathID {
+			continue
+		}
+		if timeout := p.sentPacketHandler.GetLossDetectionTimeout(); !timeout.IsZero() && timeout.Before(now) {
+			if err := p.sentPacketHandler.OnLossDetectionTimeout(); err != nil {
+				return err
+			}
+		}
+		if p.probe != nil {
+			if err := s.maybeSendPathChallengeOnPath(p, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *connection) maybeSendPathChallengeOnPath(p *path, now time.Time) error {
+	probe := p.probe
+	if probe.isAborted() {
+		s.abandonPath(p, context.Canceled, true)
+		return nil
+	}
+	if probe.TimedOut(now) {
+		s.logger.Debugf("Validation of path %d (%s -> %s) failed", p.id, p.conn.LocalAddr(), p.conn.RemoteAddr())
+		s.abandonPath(p, errPathValidationFailed, true)
+		return nil
+	}
+	if !probe.ShouldSendChallenge(now) {
+		return nil
+	}
+	data, err := probe.NewChallenge(now, p.rttStats)
+	if err != nil {
+		return err
+	}
+	packet, err := p.packer.PackPathProbePacket(p.destConnID, ackhandler.Frame{
+		Frame:  &wire.PathChallengeFrame{Data: data},
+		OnLost: func(wire.Frame) {}, // PATH_CHALLENGEs are not retransmitted, new PATH_CHALLENGEs are sent instead
+	}, protocol.MinInitialPacketSize)
+	if err != nil {
+		return err
+	}
+	if err := s.writePacket(p.conn, p.sentPacketHandler, packet, now); err != nil {
+		s.logger.Debugf("Sending PATH_CHALLENGE on path %d failed: %s", p.id, err)
+		s.abandonPath(p, err, true)
+	}
+	return nil
+}
+
+func (s *connection) removePath(id PathID) error {
+	if id == initialPathID {
+		return errors.New("cannot remove the initial path")
+	}
+	p, ok := s.paths[id]
+	if !ok {
+		return fmt.Errorf("unknown path: %d", id)
+	}
+	s.logger.Infof("Removing path %d", id)
+	s.abandonPath(p, errPathRemoved, true)
+	return nil
+}
+
+// abandonPath stops using an additional path.
+// All packets in flight on that path are declared lost, such that their frames are retransmitted on the remaining paths.
+func (s *connection) abandonPath(p *path, e error, sendPathAbandon bool) {
+	delete(s.paths, p.id)
+	if sendPathAbandon {
+		s.queueControlFrame(&wire.PathAbandonFrame{PathIdentifier: p.destSeq})
+	}
+	p.sentPacketHandler.MigratedPath(time.Now(), 0, getMaxPacketSize(p.conn.RemoteAddr()))
+	s.connIDManager.Release(p.destSeq)
+	if p.runner != s.runner {
+		p.runner.RemoveHandler(s)
+	}
+	if p.probe != nil {
+		p.probe.complete(e)
+		p.probe = nil
+	}
+}
+
+// closePaths is called when the connection is closed.
+func (s *connection) closePaths(e error) {
+	if e == nil {
+		e = &qerr.ApplicationError{}
+	}
+	for _, p := range s.paths {
+		if p.id == initialPathID {
+			continue
+		}
+		if p.runner != s.runner {
+			p.runner.RemoveHandler(s)
+		}
+		if p.probe != nil {
+			p.probe.complete(e)
+			p.probe = nil
+		}
+	}
+}
+
+func (s *connection) pathByRcvSeq(seq uint64) *path {
+	for _, p := range s.paths {
+		if p.rcvSeqKnown && p.rcvSeq == seq {
+			return p
+		}
+	}
+	return nil
+}
+
+// sortedPaths returns all paths, sorted by their ID.
+func (s *connection) sortedPaths() []*path {
+	paths := make([]*path, 0, len(s.paths))
+	for _, p := range s.paths {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].id < paths[j].id })
+	return paths
+}
+
+// sendPacketsMultipath sends packets when using multiple paths.
+// The multipath scheduler decides which path a packet is sent on.
+// ACKs are always sent on the path that the acknowledged packets were received on.
+func (s *connection) sendPacketsMultipath(now time.Time) error {
+	sentOnPath := make(map[PathID]bool, len(s.paths))
+	for _, p := range s.sortedPaths() {
+		if !p.validated || p.sentPacketHandler.SendMode() != ackhandler.SendPTOAppData {
+			continue
+		}
+		if p.id == initialPathID && (s.sendQueue.WouldBlock() || s.amplificationLimited()) {
+			continue
+		}
+		if err := s.sendProbePacketOnPath(p, now); err != nil {
+			return err
+		}
+		sentOnPath[p.id] = true
+	}
+	for {
+		paths := s.sendablePaths()
+		if len(paths) == 0 {
+			break
+		}
+		p := s.selectPath(paths)
+		sent, err := s.sendPacketOnPath(p, now)
+		if err != nil {
+			return err
+		}
+		if !sent {
+			break
+		}
+		sentOnPath[p.id] = true
+		// Prioritize receiving of packets over sending out more packets.
+		if len(s.receivedPackets) > 0 {
+			s.pacingDeadline = deadlineSendImmediately
+			return nil
+		}
+	}
+	// Paths that didn't send any packet might still need to send an ACK.
+	for _, p := range s.sortedPaths() {
+		if sentOnPath[p.id] || p.sentPacketHandler.SendMode() == ackhandler.SendNone {
+			continue
+		}
+		if p.id == initialPathID && (s.sendQueue.WouldBlock() || s.amplificationLimited()) {
+			continue
+		}
+		if err := s.maybeSendAckOnlyPacketOnPath(p, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendablePaths returns the validated paths that are currently allowed to send a packet.
+// It sets the pacing deadline for paths that are limited by the pacer.
+func (s *connection) sendablePaths() []*path {
+	var paths []*path
+	for _, p := range s.sortedPaths() {
+		if !p.validated || p.sentPacketHandler.SendMode() != ackhandler.SendAny {
+			continue
+		}
+		if p.id == initialPathID && (s.sendQueue.WouldBlock() || s.amplificationLimited()) {
+			continue
+		}
+		if !p.sentPacketHandler.HasPacingBudget() {
+			deadline := p.sentPacketHandler.TimeUntilSend()
+			if deadline.IsZero() {
+				deadline = deadlineSendImmediately
+			}
+			if s.pacingDeadline.IsZero() || deadline.Before(s.pacingDeadline) {
+				s.pacingDeadline = deadline
+			}
+			continue
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func (s *connection) selectPath(paths []*path) *path {
+	infos := make([]PathInfo, 0, len(paths))
+	for _, p := range paths {
+		infos = append(infos, p.Info())
+	}
+	id := s.pathScheduler.SelectPath(infos)
+	for _, p := range paths {
+		if p.id == id {
+			return p
+		}
+	}
+	return paths[0]
+}
+
+func (s *connection) sendPacketOnPath(p *path, now time.Time) (bool, error) {
+	if p.id == initialPathID {
+		return s.sendPacket()
+	}
+	if isBlocked, offset := s.connFlowController.IsNewlyBlocked(); isBlocked {
+		s.framer.QueueControlFrame(&wire.DataBlockedFrame{MaximumData: offset})
+	}
+	s.windowUpdateQueue.QueueAll()
+	packet, err := p.packer.PackPacket()
+	if err != nil || packet == nil {
+		return false, err
+	}
+	s.sendPackedPacketOnPath(p, packet, now)
+	return true, nil
+}
+
+func (s *connection) sendProbePacketOnPath(p *path, now time.Time) error {
+	if p.id == initialPathID {
+		return s.sendProbePacket(protocol.Encryption1RTT)
+	}
+	// Queue probe packets until we actually send out a packet,
+	// or until there are no more packets to queue.
+	var packet *packedPacket
+	for {
+		if wasQueued := p.sentPacketHandler.QueueProbePacket(protocol.Encryption1RTT); !wasQueued {
+			break
+		}
+		var err error
+		packet, err = p.packer.MaybePackProbePacket(protocol.Encryption1RTT)
+		if err != nil {
+			return err
+		}
+		if packet != nil {
+			break
+		}
+	}
+	if packet == nil {
+		s.retransmissionQueue.AddAppData(&wire.PingFrame{})
+		var err error
+		packet, err = p.packer.MaybePackProbePacket(protocol.Encryption1RTT)
+		if err != nil {
+			return err
+		}
+	}
+	if packet == nil || packet.packetContents == nil {
+		return fmt.Errorf("connection BUG: couldn't pack probe packet on path %d", p.id)
+	}
+	s.sendPackedPacketOnPath(p, packet, now)
+	return nil
+}
+
+func (s *connection) maybeSendAckOnlyPacketOnPath(p *path, now time.Time) error {
+	if p.id == initialPathID {
+		return s.maybeSendAckOnlyPacket()
+	}
+	packet, err := p.packer.MaybePackAckPacket(true)
+	if err != nil || packet == nil {
+		return err
+	}
+	s.sendPackedPacketOnPath(p, packet, now)
+	return nil
+}
+
+// sendPackedPacketOnPath sends a packet on an additional path.
+// If sending fails, the path is abandoned.
+func (s *connection) sendPackedPacketOnPath(p *path, packet *packedPacket, now time.Time) {
+	if s.firstAckElicitingPacketAfterIdleSentTime.IsZero() && packet.IsAckEliciting() {
+		s.firstAckElicitingPacketAfterIdleSentTime = now
+	}
+	if err := s.writePacket(p.conn, p.sentPacketHandler, packet, now); err != nil {
+		s.logger.Debugf("Sending packet on path %d failed: %s", p.id, err)
+		s.abandonPath(p, err, true)
+	}
+}
+
+func (s *connection) sendConnectionClose(e error) ([]byte, error) {
+	var packet *coalescedPacket
+	var err error
+	var transportErr *qerr.TransportError
+	var applicationErr *qerr.ApplicationError
+	if errors.As(e, &transportErr) {
+		packet, err = s.packer.PackConnectionClose(transportErr)
+	} else if errors.As(e, &applicationErr) {
+		packet, err = s.packer.PackApplicationClose(applicationErr)
+	} else {
+		packet, err = s.packer.PackConnectionClose(&qerr.TransportError{
+			ErrorCode:    qerr.InternalError,
+			ErrorMessage: fmt.Sprintf("connection BUG: unspecified error type (msg: %s)", e.Error()),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.logCoalescedPacket(packet)
+	return packet.buffer.Data, s.conn.Write(packet.buffer.Data)
+}
+
+func (s *connection) logPacketContents(p *packetContents) {
+	s.packetsSent++
+	// tracing
+	if s.tracer != nil {
+		frames := make([]logging.Frame, 0, len(p.frames))
+		for _, f := range p.frames {
+			frames = append(frames, logutils.ConvertFrame(f.Frame))
+		}
+		s.tracer.SentPacket(p.header, p.length, p.ack, frames)
+	}
+
+	// quic-go logging
+	if !s.logger.Debug() {
+		return
+	}
+	p.header.Log(s.logger)
+	if p.ack != nil {
+		wire.LogFrame(s.logger, p.ack, true)
+	}
+	for _, frame := range p.frames {
+		wire.LogFrame(s.logger, frame.Frame, true)
+	}
+}
+
+func (s *connection) logCoalescedPacket(packet *coalescedPacket) {
+	if s.logger.Debug() {
+		if len(packet.packets) > 1 {
+			s.logger.Debugf("-> Sending coalesced packet (%d parts, %d bytes) for connection %s", len(packet.packets), packet.buffer.Len(), s.logID)
+		} else {
+			s.logger.Debugf("-> Sending packet %d (%d bytes) for connection %s, %s", packet.packets[0].header.PacketNumber, packet.buffer.Len(), s.logID, packet.packets[0].EncryptionLevel())
+		}
+	}
+	for _, p := range packet.packets {
+		s.logPacketContents(p)
+	}
+}
+
+func (s *connection) logPacket(packet *packedPacket) {
+	if s.logger.Debug() {
+		s.logger.Debugf("-> Sending packet %d (%d bytes) for connection %s, %s", packet.header.PacketNumber, packet.buffer.Len(), s.logID, packet.EncryptionLevel())
+	}
+	s.logPacketContents(packet.packetContents)
+}
+
+// AcceptStream returns the next stream openend by the peer
+func (s *connection) AcceptStream(ctx context.Context) (Stream, error) {
+	return s.streamsMap.AcceptStream(ctx)
+}
+
+func (s *connection) AcceptUniStream(ctx context.Context) (ReceiveStream, error) {
+	return s.streamsMap.AcceptUniStream(ctx)
+}
+
+// OpenStream opens a stream
+func (s *connection) OpenStream() (Stream, error) {
+	return s.streamsMap.OpenStream()
+}
+
+func (s *connection) OpenStreamSync(ctx context.Context) (Stream, error) {
+	return s.streamsMap.OpenStreamSync(ctx)
 }
 
 func (s *connection) OpenUniStream() (SendStream, error) {
@@ -1914,6 +3026,15 @@ func (s *connection) newFlowController(id protocol.StreamID) flowcontrol.StreamF
 	)
 }
 
+// droppedPacket is called when a received packet is dropped.
+// It might be called concurrently with the run loop, see handlePacket.
+func (s *connection) droppedPacket(pt logging.PacketType, size protocol.ByteCount, reason logging.PacketDropReason) {
+	atomic.AddUint64(&s.packetsDropped, 1)
+	if s.tracer != nil {
+		s.tracer.DroppedPacket(pt, size, reason)
+	}
+}
+
 // scheduleSending signals that we have data for sending
 func (s *connection) scheduleSending() {
 	select {
@@ -1927,9 +3048,7 @@ func (s *connection) tryQueueingUndecryptablePacket(p *receivedPacket, hdr *wire
 		panic("shouldn't queue undecryptable packets after handshake completion")
 	}
 	if len(s.undecryptablePackets)+1 > protocol.MaxUndecryptablePackets {
-		if s.tracer != nil {
-			s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropDOSPrevention)
-		}
+		s.droppedPacket(logging.PacketTypeFromHeader(hdr), p.Size(), logging.PacketDropDOSPrevention)
 		s.logger.Infof("Dropping undecryptable packet (%d bytes). Undecryptable packet queue full.", p.Size())
 		return
 	}
@@ -1960,20 +3079,32 @@ func (s *connection) onHasStreamData(id protocol.StreamID) {
 	s.scheduleSending()
 }
 
+func (s *connection) setStreamPriority(id protocol.StreamID, p StreamPriority) {
+	s.framer.SetStreamPriority(id, p)
+}
+
 func (s *connection) onStreamCompleted(id protocol.StreamID) {
 	if err := s.streamsMap.DeleteStream(id); err != nil {
 		s.closeLocal(err)
 	}
+	s.framer.RemoveStream(id)
 }
 
 func (s *connection) SendMessage(p []byte) error {
-	f := &wire.DatagramFrame{DataLenPresent: true}
-	if protocol.ByteCount(len(p)) > f.MaxDataLen(s.peerParams.MaxDatagramFrameSize, s.version) {
+	return s.SendMessageWithCallback(p, nil)
+}
+
+func (s *connection) SendMessageWithCallback(p []byte, onDelivery func(acked bool)) error {
+	if protocol.ByteCount(len(p)) > s.maxDatagramPayloadSize() {
 		return errors.New("message too large")
 	}
+	f := &wire.DatagramFrame{DataLenPresent: true}
 	f.Data = make([]byte, len(p))
 	copy(f.Data, p)
-	return s.datagramQueue.AddAndWait(f)
+	if s.config.DatagramDropPolicy == DatagramDropOldest {
+		return s.datagramQueue.Add(f, onDelivery)
+	}
+	return s.datagramQueue.AddAndWait(f, onDelivery)
 }
 
 func (s *connection) ReceiveMessage() ([]byte, error) {
@@ -1988,6 +3119,159 @@ func (s *connection) RemoteAddr() net.Addr {
 	return s.conn.RemoteAddr()
 }
 
+func (s *connection) Migrate(ctx context.Context, conn net.PacketConn) error {
+	if s.perspective == protocol.PerspectiveServer {
+		return errors.New("only the client can migrate a connection")
+	}
+	runner, err := getMultiplexer().AddConn(conn, s.config.ConnectionIDLength, s.config.StatelessResetKey, s.config.Tracer)
+	if err != nil {
+		return err
+	}
+	probe := newPathProbe(newSendPconn(conn, s.RemoteAddr()), runner)
+	select {
+	case s.pathProbeRequests <- probe:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return errors.New("connection closed")
+	}
+	select {
+	case <-probe.Done():
+		return probe.Err()
+	case <-ctx.Done():
+		probe.abort()
+		s.scheduleSending()
+		return ctx.Err()
+	}
+}
+
+func (s *connection) ProbePath(ctx context.Context, conn net.PacketConn) (time.Duration, error) {
+	if s.perspective == protocol.PerspectiveServer {
+		return 0, errors.New("only the client can probe paths")
+	}
+	runner, err := getMultiplexer().AddConn(conn, s.config.ConnectionIDLength, s.config.StatelessResetKey, s.config.Tracer)
+	if err != nil {
+		return 0, err
+	}
+	probe := newPathProbe(newSendPconn(conn, s.RemoteAddr()), runner)
+	probe.probeOnly = true
+	select {
+	case s.pathProbeRequests <- probe:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-s.ctx.Done():
+		return 0, errors.New("connection closed")
+	}
+	select {
+	case <-probe.Done():
+		if err := probe.Err(); err != nil {
+			return 0, err
+		}
+		return probe.RTT(), nil
+	case <-ctx.Done():
+		probe.abort()
+		s.scheduleSending()
+		return 0, ctx.Err()
+	}
+}
+
+func (s *connection) AddPath(ctx context.Context, conn net.PacketConn) (PathID, error) {
+	if s.perspective == protocol.PerspectiveServer {
+		return 0, errors.New("only the client can add paths")
+	}
+	if !s.config.EnableMultipath {
+		return 0, errors.New("multipath not enabled")
+	}
+	runner, err := getMultiplexer().AddConn(conn, s.config.ConnectionIDLength, s.config.StatelessResetKey, s.config.Tracer)
+	if err != nil {
+		return 0, err
+	}
+	probe := newPathProbe(newSendPconn(conn, s.RemoteAddr()), runner)
+	select {
+	case s.addPathRequests <- probe:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-s.ctx.Done():
+		return 0, errors.New("connection closed")
+	}
+	select {
+	case <-probe.Done():
+		if err := probe.Err(); err != nil {
+			return 0, err
+		}
+		return probe.pathID, nil
+	case <-ctx.Done():
+		probe.abort()
+		s.scheduleSending()
+		return 0, ctx.Err()
+	}
+}
+
+func (s *connection) RemovePath(id PathID) error {
+	req := &pathRemovalRequest{id: id, errChan: make(chan error, 1)}
+	select {
+	case s.removePathRequests <- req:
+	case <-s.ctx.Done():
+		return errors.New("connection closed")
+	}
+	return <-req.errChan
+}
+
+func (s *connection) UpdateKeys() error {
+	errChan := make(chan error, 1)
+	select {
+	case s.keyUpdateRequests <- errChan:
+	case <-s.ctx.Done():
+		return errors.New("connection closed")
+	}
+	return <-errChan
+}
+
+func (s *connection) Stats() ConnectionStats {
+	statsChan := make(chan ConnectionStats, 1)
+	select {
+	case s.statsRequests <- statsChan:
+		return <-statsChan
+	case <-s.ctx.Done():
+		// The run loop has exited, so the state of the connection doesn't change any more.
+		return s.getStats()
+	}
+}
+
+// getStats is called from the run loop, or after the run loop has exited.
+func (s *connection) getStats() ConnectionStats {
+	sentStats := s.sentPacketHandler.GetStats()
+	stats := ConnectionStats{
+		MinRTT:            s.rttStats.MinRTT(),
+		LatestRTT:         s.rttStats.LatestRTT(),
+		SmoothedRTT:       s.rttStats.SmoothedRTT(),
+		MeanDeviation:     s.rttStats.MeanDeviation(),
+		CongestionWindow:  sentStats.CongestionWindow,
+		BytesInFlight:     sentStats.BytesInFlight,
+		BandwidthEstimate: sentStats.BandwidthEstimate,
+		PacketsSent:       s.packetsSent,
+		PacketsReceived:   s.packetsReceived,
+		PacketsLost:       sentStats.PacketsLost,
+		PacketsDropped:    atomic.LoadUint64(&s.packetsDropped),
+	}
+	if s.datagramQueue != nil {
+		stats.DroppedSendDatagrams, stats.DroppedReceivedDatagrams = s.datagramQueue.DroppedDatagrams()
+	}
+	return stats
+}
+
+// requestKeyUpdate is called from the run loop.
+func (s *connection) requestKeyUpdate() error {
+	if !s.handshakeConfirmed {
+		return errors.New("quic: can't update keys before the handshake is confirmed")
+	}
+	s.cryptoStreamHandler.RequestKeyUpdate()
+	// The key update is initiated when the next 1-RTT packet is sent.
+	// Make sure that there's a packet to send.
+	s.framer.QueueControlFrame(&wire.PingFrame{})
+	return nil
+}
+
 func (s *connection) getPerspective() protocol.Perspective {
 	return s.perspective
 }
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	mockackhandler "github.com/lucas-clemente/quic-go/internal/mocks/ackhandler"
@@ -87,7 +88,7 @@ var _ = Describe("Connection", func() {
 		mconn = NewMockSendConn(mockCtrl)
 		mconn.EXPECT().RemoteAddr().Return(remoteAddr).AnyTimes()
 		mconn.EXPECT().LocalAddr().Return(localAddr).AnyTimes()
-		tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
+		tokenGenerator, err := newRandomTokenGenerator()
 		Expect(err).ToNot(HaveOccurred())
 		tracer = mocklogging.NewMockConnectionTracer(mockCtrl)
 		tracer.EXPECT().NegotiatedVersion(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(1)
@@ -103,7 +104,7 @@ var _ = Describe("Connection", func() {
 			destConnID,
 			srcConnID,
 			protocol.StatelessResetToken{},
-			populateServerConfig(&Config{DisablePathMTUDiscovery: true}),
+			populateServerConfig(&Config{ConnectionIDLength: srcConnID.Len(), DisablePathMTUDiscovery: true}),
 			nil, // tls.Config
 			tokenGenerator,
 			false,
@@ -207,6 +208,24 @@ var _ = Describe("Connection", func() {
 			})
 		})
 
+		Context("handling EXPIRED_STREAM_DATA frames", func() {
+			It("passes the frame to the stream", func() {
+				f := &wire.ExpiredStreamDataFrame{
+					StreamID: 555,
+					Offset:   0x1337,
+				}
+				str := NewMockReceiveStreamI(mockCtrl)
+				streamManager.EXPECT().GetOrOpenReceiveStream(protocol.StreamID(555)).Return(str, nil)
+				str.EXPECT().handleExpiredStreamDataFrame(f)
+				Expect(conn.handleFrame(f, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+			})
+
+			It("ignores EXPIRED_STREAM_DATA frames for closed streams", func() {
+				streamManager.EXPECT().GetOrOpenReceiveStream(protocol.StreamID(3)).Return(nil, nil)
+				Expect(conn.handleFrame(&wire.ExpiredStreamDataFrame{StreamID: 3}, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+			})
+		})
+
 		Context("handling MAX_DATA and MAX_STREAM_DATA frames", func() {
 			var connFC *mocks.MockConnectionFlowController
 
@@ -287,9 +306,9 @@ var _ = Describe("Connection", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("rejects PATH_RESPONSE frames", func() {
+		It("ignores unexpected PATH_RESPONSE frames", func() {
 			err := conn.handleFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}, protocol.Encryption1RTT, protocol.ConnectionID{})
-			Expect(err).To(MatchError("unexpected PATH_RESPONSE frame"))
+			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("handles PATH_CHALLENGE frames", func() {
@@ -399,6 +418,151 @@ var _ = Describe("Connection", func() {
 		Expect(conn.GetVersion()).To(Equal(protocol.VersionNumber(4242)))
 	})
 
+	Context("key updates", func() {
+		It("refuses to update keys before the handshake is confirmed", func() {
+			Expect(conn.requestKeyUpdate()).To(MatchError("quic: can't update keys before the handshake is confirmed"))
+		})
+
+		It("requests a key update and queues a PING frame", func() {
+			conn.handshakeConfirmed = true
+			cryptoSetup.EXPECT().RequestKeyUpdate()
+			Expect(conn.requestKeyUpdate()).To(Succeed())
+			frames, _ := conn.framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(Equal([]ackhandler.Frame{{Frame: &wire.PingFrame{}}}))
+		})
+
+		It("reports the key phase", func() {
+			cryptoSetup.EXPECT().ConnectionState()
+			cryptoSetup.EXPECT().CurrentKeyPhase().Return(protocol.KeyPhase(3))
+			conn.peerParams = &wire.TransportParameters{}
+			Expect(conn.ConnectionState().KeyPhase).To(BeEquivalentTo(3))
+		})
+	})
+
+	It("reports the maximum datagram payload size", func() {
+		cryptoSetup.EXPECT().ConnectionState().Times(2)
+		cryptoSetup.EXPECT().CurrentKeyPhase().Times(2)
+		conn.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: protocol.InvalidByteCount}
+		Expect(conn.ConnectionState().MaxDatagramPayloadSize).To(BeZero())
+		conn.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: 1000}
+		cs := conn.ConnectionState()
+		Expect(cs.SupportsDatagrams).To(BeTrue())
+		Expect(cs.MaxDatagramPayloadSize).To(BeNumerically("~", 1000, 5))
+		Expect(cs.MaxDatagramPayloadSize).To(BeNumerically("<", 1000))
+	})
+
+	Context("sending datagrams", func() {
+		var queued chan struct{}
+
+		setDropPolicy := func(policy DatagramDropPolicy) {
+			queued = make(chan struct{}, 10)
+			conn.config.DatagramDropPolicy = policy
+			conn.datagramQueue = newDatagramQueue(func() { queued <- struct{}{} }, 1, 1, policy, utils.DefaultLogger)
+			conn.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: 1000}
+		}
+
+		sendMessage := func(msg string) <-chan error {
+			errChan := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				errChan <- conn.SendMessage([]byte(msg))
+			}()
+			return errChan
+		}
+
+		getDatagram := func() []byte {
+			f, _ := conn.datagramQueue.Get()
+			ExpectWithOffset(1, f).ToNot(BeNil())
+			return f.Data
+		}
+
+		It("refuses to send messages that are too large", func() {
+			setDropPolicy(DatagramDropNewest)
+			Expect(conn.SendMessage(make([]byte, 1000))).To(MatchError("message too large"))
+		})
+
+		It("blocks until the datagram is dequeued", func() {
+			setDropPolicy(DatagramDropNewest)
+			errChan1 := sendMessage("foo")
+			Eventually(queued).Should(Receive())
+			Consistently(errChan1).ShouldNot(Receive())
+			// the send queue is full
+			errChan2 := sendMessage("bar")
+			Consistently(errChan2).ShouldNot(Receive())
+			Expect(getDatagram()).To(Equal([]byte("foo")))
+			Eventually(errChan1).Should(Receive(BeNil()))
+			Eventually(queued).Should(Receive())
+			Consistently(errChan2).ShouldNot(Receive())
+			Expect(getDatagram()).To(Equal([]byte("bar")))
+			Eventually(errChan2).Should(Receive(BeNil()))
+			sent, _ := conn.datagramQueue.DroppedDatagrams()
+			Expect(sent).To(BeZero())
+		})
+
+		It("drops the oldest datagram when the send queue is full, when using DatagramDropOldest", func() {
+			setDropPolicy(DatagramDropOldest)
+			Expect(conn.SendMessage([]byte("foo"))).To(Succeed())
+			Expect(conn.SendMessage([]byte("bar"))).To(Succeed())
+			Expect(getDatagram()).To(Equal([]byte("bar")))
+			f, _ := conn.datagramQueue.Get()
+			Expect(f).To(BeNil())
+			sent, _ := conn.datagramQueue.DroppedDatagrams()
+			Expect(sent).To(BeEquivalentTo(1))
+		})
+
+		It("unblocks when the connection is closed", func() {
+			setDropPolicy(DatagramDropNewest)
+			errChan := sendMessage("foo")
+			Eventually(queued).Should(Receive())
+			Consistently(errChan).ShouldNot(Receive())
+			conn.datagramQueue.CloseWithError(errors.New("test error"))
+			Eventually(errChan).Should(Receive(MatchError("test error")))
+		})
+	})
+
+	Context("stats", func() {
+		It("reports the stats", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			conn.sentPacketHandler = sph
+			sph.EXPECT().GetStats().Return(ackhandler.SentPacketStats{
+				CongestionWindow:  12345,
+				BytesInFlight:     1234,
+				BandwidthEstimate: 5 * congestion.BytesPerSecond,
+				PacketsLost:       3,
+			})
+			conn.rttStats.UpdateRTT(100*time.Millisecond, 0, time.Now())
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+			conn.logPacket(getPacket(1))
+			conn.logPacket(getPacket(2))
+			tracer.EXPECT().DroppedPacket(logging.PacketTypeNotDetermined, logging.ByteCount(6), logging.PacketDropHeaderParseError)
+			conn.droppedPacket(logging.PacketTypeNotDetermined, 6, logging.PacketDropHeaderParseError)
+			conn.packetsReceived = 5
+			stats := conn.getStats()
+			Expect(stats.MinRTT).To(Equal(100 * time.Millisecond))
+			Expect(stats.LatestRTT).To(Equal(100 * time.Millisecond))
+			Expect(stats.SmoothedRTT).To(Equal(100 * time.Millisecond))
+			Expect(stats.MeanDeviation).To(Equal(50 * time.Millisecond))
+			Expect(stats.CongestionWindow).To(BeEquivalentTo(12345))
+			Expect(stats.BytesInFlight).To(BeEquivalentTo(1234))
+			Expect(stats.BandwidthEstimate).To(Equal(5 * congestion.BytesPerSecond))
+			Expect(stats.PacketsSent).To(BeEquivalentTo(2))
+			Expect(stats.PacketsReceived).To(BeEquivalentTo(5))
+			Expect(stats.PacketsLost).To(BeEquivalentTo(3))
+			Expect(stats.PacketsDropped).To(BeEquivalentTo(1))
+		})
+
+		It("reports the stats after the connection was closed", func() {
+			conn.packetsSent = 42
+			conn.ctxCancel()
+			Expect(conn.Stats().PacketsSent).To(BeEquivalentTo(42))
+		})
+	})
+
+	It("refuses to export keying material before the handshake completes", func() {
+		_, err := ConnectionState{}.ExportKeyingMaterial("EXPORTER-foobar", nil, 32)
+		Expect(err).To(MatchError("quic: handshake not complete"))
+	})
+
 	Context("closing", func() {
 		var (
 			runErr         chan error
@@ -2084,6 +2248,45 @@ var _ = Describe("Connection", func() {
 			conn.handleTransportParameters(params)
 			Expect(conn.earlyConnReady()).To(BeClosed())
 		})
+
+		sentTransportParameters := func(conf *Config) *wire.TransportParameters {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			tracer.EXPECT().NegotiatedVersion(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(1)
+			var params *wire.TransportParameters
+			tracer.EXPECT().SentTransportParameters(gomock.Any()).Do(func(p *wire.TransportParameters) { params = p })
+			tracer.EXPECT().UpdatedKeyFromTLS(gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().UpdatedCongestionState(gomock.Any())
+			tokenGenerator, err := newRandomTokenGenerator()
+			Expect(err).ToNot(HaveOccurred())
+			newConnection(
+				mconn,
+				connRunner,
+				nil,
+				nil,
+				clientDestConnID,
+				destConnID,
+				srcConnID,
+				protocol.StatelessResetToken{},
+				populateServerConfig(conf),
+				nil, // tls.Config
+				tokenGenerator,
+				false,
+				tracer,
+				1234,
+				utils.DefaultLogger,
+				protocol.VersionTLS,
+			)
+			Expect(params).ToNot(BeNil())
+			return params
+		}
+
+		It("disables active migration by default", func() {
+			Expect(sentTransportParameters(&Config{}).DisableActiveMigration).To(BeTrue())
+		})
+
+		It("allows active migration, if enabled", func() {
+			Expect(sentTransportParameters(&Config{EnableActiveMigration: true}).DisableActiveMigration).To(BeFalse())
+		})
 	})
 
 	Context("keep-alives", func() {
@@ -2350,6 +2553,157 @@ var _ = Describe("Connection", func() {
 		Eventually(done).Should(BeClosed())
 	})
 
+	Context("connection migration", func() {
+		var sph *mockackhandler.MockSentPacketHandler
+
+		BeforeEach(func() {
+			sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			conn.sentPacketHandler = sph
+			conn.peerParams = &wire.TransportParameters{}
+		})
+
+		It("refuses to migrate", func() {
+			Expect(conn.Migrate(context.Background(), nil)).To(MatchError("only the client can migrate a connection"))
+		})
+
+		It("answers PATH_CHALLENGEs received from a new address on that path", func() {
+			path := NewMockSendConn(mockCtrl)
+			data := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+			packer.EXPECT().PackPathProbePacket(destConnID, gomock.Any(), protocol.ByteCount(protocol.MinInitialPacketSize)).DoAndReturn(func(_ protocol.ConnectionID, f ackhandler.Frame, _ protocol.ByteCount) (*packedPacket, error) {
+				Expect(f.Frame).To(Equal(&wire.PathResponseFrame{Data: data}))
+				return getPacket(10), nil
+			})
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			sph.EXPECT().SentPacket(gomock.Any())
+			path.EXPECT().Write([]byte("foobar"))
+			Expect(conn.handleFrameOnPath(&wire.PathChallengeFrame{Data: data}, protocol.Encryption1RTT, srcConnID, path, 1300)).To(Succeed())
+			// the PATH_RESPONSE is not sent on the current path
+			frames, _ := conn.framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(BeEmpty())
+		})
+
+		It("doesn't pad PATH_RESPONSEs to more than 3 times the size of the packet received", func() {
+			path := NewMockSendConn(mockCtrl)
+			packer.EXPECT().PackPathProbePacket(destConnID, gomock.Any(), protocol.ByteCount(300)).Return(getPacket(10), nil)
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			sph.EXPECT().SentPacket(gomock.Any())
+			path.EXPECT().Write([]byte("foobar"))
+			Expect(conn.handleFrameOnPath(&wire.PathChallengeFrame{}, protocol.Encryption1RTT, srcConnID, path, 100)).To(Succeed())
+		})
+
+		It("switches to the new path when the peer migrates", func() {
+			newAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 4242}
+			path := NewMockSendConn(mockCtrl)
+			path.EXPECT().RemoteAddr().Return(newAddr).AnyTimes()
+			now := time.Now()
+			sph.EXPECT().MigratedPath(now, time.Duration(0), getMaxPacketSize(newAddr))
+			packer.EXPECT().SetMaxPacketSize(getMaxPacketSize(newAddr))
+			conn.handlePeerMigration(path, now, 1000)
+			Expect(conn.RemoteAddr()).To(Equal(newAddr))
+			Expect(conn.peerMigration).ToNot(BeNil())
+		})
+
+		It("keeps the congestion state on NAT rebindings", func() {
+			newAddr := &net.UDPAddr{IP: remoteAddr.IP, Port: remoteAddr.Port + 1}
+			path := NewMockSendConn(mockCtrl)
+			path.EXPECT().RemoteAddr().Return(newAddr).AnyTimes()
+			conn.handlePeerMigration(path, time.Now(), 1000)
+			Expect(conn.RemoteAddr()).To(Equal(newAddr))
+		})
+
+		Context("validating the new address", func() {
+			var (
+				newAddr *net.UDPAddr
+				path    *MockSendConn
+				now     time.Time
+			)
+
+			BeforeEach(func() {
+				newAddr = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 4242}
+				path = NewMockSendConn(mockCtrl)
+				path.EXPECT().RemoteAddr().Return(newAddr).AnyTimes()
+				now = time.Now()
+				sph.EXPECT().MigratedPath(now, time.Duration(0), getMaxPacketSize(newAddr))
+				packer.EXPECT().SetMaxPacketSize(getMaxPacketSize(newAddr))
+				conn.handlePeerMigration(path, now, 100)
+			})
+
+			It("sends a PATH_CHALLENGE, and completes validation when the PATH_RESPONSE is received", func() {
+				var data [8]byte
+				packer.EXPECT().PackPathProbePacket(gomock.Any(), gomock.Any(), protocol.ByteCount(300)).DoAndReturn(func(_ protocol.ConnectionID, f ackhandler.Frame, _ protocol.ByteCount) (*packedPacket, error) {
+					Expect(f.Frame).To(BeAssignableToTypeOf(&wire.PathChallengeFrame{}))
+					data = f.Frame.(*wire.PathChallengeFrame).Data
+					return getPacket(10), nil
+				})
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				sph.EXPECT().SentPacket(gomock.Any())
+				path.EXPECT().Write([]byte("foobar"))
+				Expect(conn.maybeSendPeerMigrationChallenge(now)).To(Succeed())
+				Expect(conn.peerMigration.SendBudget()).To(BeEquivalentTo(300 - 6))
+				// no new PATH_CHALLENGE is sent until the PTO expires
+				Expect(conn.maybeSendPeerMigrationChallenge(now)).To(Succeed())
+				conn.handlePathResponseFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})
+				Expect(conn.peerMigration).ToNot(BeNil())
+				conn.handlePathResponseFrame(&wire.PathResponseFrame{Data: data})
+				Expect(conn.peerMigration).To(BeNil())
+				Expect(conn.RemoteAddr()).To(Equal(newAddr))
+			})
+
+			It("doesn't send the PATH_CHALLENGE if the packet exceeds the anti-amplification limit", func() {
+				conn.peerMigration.SentBytes(298)
+				packer.EXPECT().PackPathProbePacket(gomock.Any(), gomock.Any(), protocol.ByteCount(2)).Return(getPacket(10), nil)
+				Expect(conn.maybeSendPeerMigrationChallenge(now)).To(Succeed())
+				Expect(conn.peerMigration.SendBudget()).To(BeEquivalentTo(2))
+			})
+
+			It("doesn't send other packets when amplification limited", func() {
+				Expect(conn.amplificationLimited()).To(BeTrue())
+				// no calls to the sent packet handler or the packer expected
+				Expect(conn.sendPackets()).To(Succeed())
+				conn.peerMigration.ReceivedBytes(1000)
+				Expect(conn.amplificationLimited()).To(BeFalse())
+			})
+
+			It("counts packets sent to the new address", func() {
+				conn.peerMigration.ReceivedBytes(1000)
+				budget := conn.peerMigration.SendBudget()
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				sph.EXPECT().SentPacket(gomock.Any())
+				sender := NewMockSender(mockCtrl)
+				sender.EXPECT().Send(gomock.Any())
+				conn.sendQueue = sender
+				conn.sendPackedPacket(getPacket(10), now)
+				Expect(conn.peerMigration.SendBudget()).To(Equal(budget - 6))
+			})
+
+			It("switches back to the last validated address if validation fails", func() {
+				packer.EXPECT().PackPathProbePacket(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(protocol.ConnectionID, ackhandler.Frame, protocol.ByteCount) (*packedPacket, error) {
+					return getPacket(10), nil
+				}).Times(maxPathChallenges)
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(maxPathChallenges)
+				sph.EXPECT().SentPacket(gomock.Any()).Times(maxPathChallenges)
+				path.EXPECT().Write([]byte("foobar")).Times(maxPathChallenges)
+				for i := 0; i < maxPathChallenges; i++ {
+					Expect(conn.maybeSendPeerMigrationChallenge(now)).To(Succeed())
+					now = conn.peerMigration.probe.NextTimeout()
+				}
+				sph.EXPECT().MigratedPath(now, time.Duration(0), getMaxPacketSize(remoteAddr))
+				packer.EXPECT().SetMaxPacketSize(getMaxPacketSize(remoteAddr))
+				Expect(conn.maybeSendPeerMigrationChallenge(now)).To(Succeed())
+				Expect(conn.peerMigration).To(BeNil())
+				Expect(conn.RemoteAddr()).To(Equal(remoteAddr))
+			})
+
+			It("stops validating when the peer migrates back to the last validated address", func() {
+				sph.EXPECT().MigratedPath(now, time.Duration(0), getMaxPacketSize(remoteAddr))
+				packer.EXPECT().SetMaxPacketSize(getMaxPacketSize(remoteAddr))
+				conn.handlePeerMigration(mconn, now, 100)
+				Expect(conn.peerMigration).To(BeNil())
+				Expect(conn.RemoteAddr()).To(Equal(remoteAddr))
+			})
+		})
+	})
+
 	Context("getting streams", func() {
 		It("opens streams", func() {
 			mstr := NewMockStreamI(mockCtrl)
@@ -2573,6 +2927,506 @@ var _ = Describe("Client Connection", func() {
 		Expect(conn.handleAckFrame(ack, protocol.Encryption1RTT)).To(Succeed())
 	})
 
+	Context("migrating", func() {
+		var (
+			newRunner *MockConnRunner
+			newConn   *MockSendConn
+			sph       *mockackhandler.MockSentPacketHandler
+			probe     *pathProbe
+		)
+		newAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 4242}
+		newDestConnID := protocol.ConnectionID{0xde, 0xca, 0xfb, 0xad}
+
+		JustBeforeEach(func() {
+			newRunner = NewMockConnRunner(mockCtrl)
+			newConn = NewMockSendConn(mockCtrl)
+			newConn.EXPECT().RemoteAddr().Return(newAddr).AnyTimes()
+			newConn.EXPECT().LocalAddr().Return(&net.UDPAddr{}).AnyTimes()
+			sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			conn.sentPacketHandler = sph
+			conn.peerParams = &wire.TransportParameters{}
+			conn.handshakeConfirmed = true
+			conn.connIDManager.SetHandshakeComplete()
+			probe = newPathProbe(newConn, newRunner)
+		})
+
+		addConnID := func() {
+			Expect(conn.handleNewConnectionIDFrame(&wire.NewConnectionIDFrame{
+				SequenceNumber: 1,
+				ConnectionID:   newDestConnID,
+			})).To(Succeed())
+		}
+
+		expectPathChallenge := func() (data chan [8]byte) {
+			data = make(chan [8]byte, 1)
+			packer.EXPECT().PackPathProbePacket(newDestConnID, gomock.Any(), protocol.ByteCount(protocol.MinInitialPacketSize)).DoAndReturn(func(_ protocol.ConnectionID, f ackhandler.Frame, _ protocol.ByteCount) (*packedPacket, error) {
+				data <- f.Frame.(*wire.PathChallengeFrame).Data
+				buffer := getPacketBuffer()
+				buffer.Data = append(buffer.Data, []byte("foobar")...)
+				return &packedPacket{
+					buffer:         buffer,
+					packetContents: &packetContents{header: &wire.ExtendedHeader{PacketNumber: 10}, length: 6},
+				}, nil
+			})
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			sph.EXPECT().SentPacket(gomock.Any())
+			newConn.EXPECT().Write([]byte("foobar"))
+			return data
+		}
+
+		It("refuses to migrate before the handshake is confirmed", func() {
+			conn.handshakeConfirmed = false
+			addConnID()
+			conn.startPathProbe(probe)
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError("cannot migrate before the handshake is confirmed"))
+			Expect(conn.pathProbe).To(BeNil())
+		})
+
+		It("refuses to migrate if the server disabled active migration", func() {
+			conn.peerParams.DisableActiveMigration = true
+			addConnID()
+			conn.startPathProbe(probe)
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError("peer disabled active migration"))
+		})
+
+		It("refuses to migrate if there's no unused connection ID", func() {
+			conn.startPathProbe(probe)
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError("no unused connection ID available"))
+		})
+
+		It("migrates after validating the new path", func() {
+			addConnID()
+			newRunner.EXPECT().Add(srcConnID, conn).Return(true)
+			conn.startPathProbe(probe)
+			Expect(conn.pathProbe).To(Equal(probe))
+			data := expectPathChallenge()
+			Expect(conn.maybeSendPathChallenge(time.Now())).To(Succeed())
+			Expect(probe.Done()).ToNot(BeClosed())
+
+			connRunner.EXPECT().RemoveHandler(conn)
+			newRunner.EXPECT().Add(srcConnID, conn).Return(false)
+			newRunner.EXPECT().AddResetToken(gomock.Any(), conn)
+			sph.EXPECT().MigratedPath(gomock.Any(), gomock.Any(), getMaxPacketSize(newAddr))
+			packer.EXPECT().SetMaxPacketSize(getMaxPacketSize(newAddr))
+			// a PATH_RESPONSE that doesn't match is ignored
+			conn.handlePathResponseFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})
+			Expect(probe.Done()).ToNot(BeClosed())
+			conn.handlePathResponseFrame(&wire.PathResponseFrame{Data: <-data})
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).ToNot(HaveOccurred())
+			Expect(conn.pathProbe).To(BeNil())
+			Expect(conn.RemoteAddr()).To(Equal(newAddr))
+			Expect(conn.connIDManager.Get()).To(Equal(newDestConnID))
+			// the old connection ID is retired
+			frames, _ := conn.framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(Equal([]ackhandler.Frame{{Frame: &wire.RetireConnectionIDFrame{SequenceNumber: 0}}}))
+		})
+
+		It("doesn't migrate if the path is only probed", func() {
+			addConnID()
+			newRunner.EXPECT().Add(srcConnID, conn).Return(true)
+			probe.probeOnly = true
+			conn.startPathProbe(probe)
+			data := expectPathChallenge()
+			Expect(conn.maybeSendPathChallenge(time.Now())).To(Succeed())
+
+			newRunner.EXPECT().RemoveHandler(conn)
+			conn.handlePathResponseFrame(&wire.PathResponseFrame{Data: <-data})
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).ToNot(HaveOccurred())
+			Expect(probe.RTT()).To(BeNumerically(">", 0))
+			Expect(conn.pathProbe).To(BeNil())
+			Expect(conn.RemoteAddr()).ToNot(Equal(newAddr))
+			// the connection ID used for probing is still available for a migration
+			connID, ok := conn.connIDManager.PeekNext()
+			Expect(ok).To(BeTrue())
+			Expect(connID).To(Equal(newDestConnID))
+			frames, _ := conn.framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(BeEmpty())
+		})
+
+		It("refuses to probe a path before the handshake is confirmed", func() {
+			conn.handshakeConfirmed = false
+			addConnID()
+			probe.probeOnly = true
+			conn.startPathProbe(probe)
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError("cannot probe a path before the handshake is confirmed"))
+		})
+
+		It("gives up if path validation times out", func() {
+			addConnID()
+			newRunner.EXPECT().Add(srcConnID, conn).Return(true)
+			conn.startPathProbe(probe)
+			now := time.Now()
+			for i := 0; i < maxPathChallenges; i++ {
+				expectPathChallenge()
+				Expect(conn.maybeSendPathChallenge(now)).To(Succeed())
+				now = probe.NextTimeout()
+			}
+			Expect(probe.Done()).ToNot(BeClosed())
+			newRunner.EXPECT().RemoveHandler(conn)
+			Expect(conn.maybeSendPathChallenge(now)).To(Succeed())
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError(errPathValidationFailed))
+			Expect(conn.pathProbe).To(BeNil())
+			Expect(conn.RemoteAddr()).ToNot(Equal(newAddr))
+		})
+
+		It("stops path validation when aborted", func() {
+			addConnID()
+			newRunner.EXPECT().Add(srcConnID, conn).Return(true)
+			conn.startPathProbe(probe)
+			probe.abort()
+			newRunner.EXPECT().RemoveHandler(conn)
+			Expect(conn.maybeSendPathChallenge(time.Now())).To(Succeed())
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError(context.Canceled))
+		})
+	})
+
+	Context("multipath", func() {
+		var (
+			newRunner  *MockConnRunner
+			newConn    *MockSendConn
+			sph        *mockackhandler.MockSentPacketHandler
+			pathSPH    *mockackhandler.MockSentPacketHandler
+			pathRPH    *mockackhandler.MockReceivedPacketHandler
+			pathPacker *MockPacker
+			probe      *pathProbe
+		)
+		newAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 4242}
+		newDestConnID := protocol.ConnectionID{0xde, 0xca, 0xfb, 0xad}
+
+		BeforeEach(func() {
+			quicConf.EnableMultipath = true
+		})
+
+		JustBeforeEach(func() {
+			newRunner = NewMockConnRunner(mockCtrl)
+			newConn = NewMockSendConn(mockCtrl)
+			newConn.EXPECT().RemoteAddr().Return(newAddr).AnyTimes()
+			newConn.EXPECT().LocalAddr().Return(&net.UDPAddr{}).AnyTimes()
+			sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			conn.sentPacketHandler = sph
+			pathSPH = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			pathRPH = mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
+			pathPacker = NewMockPacker(mockCtrl)
+			conn.peerParams = &wire.TransportParameters{EnableMultipath: true}
+			conn.handshakeConfirmed = true
+			conn.connIDManager.SetHandshakeComplete()
+			conn.startMultipath()
+			probe = newPathProbe(newConn, newRunner)
+		})
+
+		addConnID := func() {
+			Expect(conn.handleNewConnectionIDFrame(&wire.NewConnectionIDFrame{
+				SequenceNumber: 1,
+				ConnectionID:   newDestConnID,
+			})).To(Succeed())
+		}
+
+		// addPath starts adding a path, and replaces its ack handlers and packer with mocks
+		addPath := func() *path {
+			addConnID()
+			newRunner.EXPECT().Add(srcConnID, conn).Return(true)
+			connRunner.EXPECT().AddResetToken(gomock.Any(), conn)
+			conn.startAddingPath(probe)
+			Expect(probe.Done()).ToNot(BeClosed())
+			p, ok := conn.paths[probe.pathID]
+			Expect(ok).To(BeTrue())
+			Expect(p.validated).To(BeFalse())
+			p.sentPacketHandler = pathSPH
+			p.receivedPacketHandler = pathRPH
+			p.packer = pathPacker
+			return p
+		}
+
+		expectPacketOnPath := func() {
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			pathSPH.EXPECT().SentPacket(gomock.Any())
+			newConn.EXPECT().Write([]byte("foobar"))
+		}
+
+		getPathPacket := func(pn protocol.PacketNumber) *packedPacket {
+			buffer := getPacketBuffer()
+			buffer.Data = append(buffer.Data, []byte("foobar")...)
+			return &packedPacket{
+				buffer:         buffer,
+				packetContents: &packetContents{header: &wire.ExtendedHeader{PacketNumber: pn}, length: 6},
+			}
+		}
+
+		// receivePathPacket passes a packet containing the frames to handlePathPacket
+		receivePathPacket := func(rcvSeq uint64, pn protocol.PacketNumber, frames ...wire.Frame) (bool, error) {
+			b := &bytes.Buffer{}
+			for _, f := range frames {
+				Expect(f.Write(b, conn.version)).To(Succeed())
+			}
+			return conn.handlePathPacket(&unpackedPacket{
+				packetNumber:    pn,
+				hdr:             &wire.ExtendedHeader{Header: wire.Header{DestConnectionID: srcConnID}, PacketNumber: pn},
+				encryptionLevel: protocol.Encryption1RTT,
+				data:            b.Bytes(),
+			}, rcvSeq, &receivedPacket{rcvTime: time.Now(), buffer: getPacketBuffer()})
+		}
+
+		// validatePath sends a PATH_CHALLENGE on the path, and receives the PATH_RESPONSE on the connection ID with sequence number 3
+		validatePath := func(p *path) {
+			data := make(chan [8]byte, 1)
+			pathSPH.EXPECT().GetLossDetectionTimeout().AnyTimes()
+			pathPacker.EXPECT().PackPathProbePacket(newDestConnID, gomock.Any(), protocol.ByteCount(protocol.MinInitialPacketSize)).DoAndReturn(func(_ protocol.ConnectionID, f ackhandler.Frame, _ protocol.ByteCount) (*packedPacket, error) {
+				data <- f.Frame.(*wire.PathChallengeFrame).Data
+				return getPathPacket(10), nil
+			})
+			expectPacketOnPath()
+			Expect(conn.handlePathTimeouts(time.Now())).To(Succeed())
+			Expect(probe.Done()).ToNot(BeClosed())
+
+			tracer.EXPECT().ReceivedPacket(gomock.Any(), gomock.Any(), gomock.Any())
+			pathRPH.EXPECT().ReceivedPacket(protocol.PacketNumber(1), gomock.Any(), protocol.Encryption1RTT, gomock.Any(), true)
+			processed, err := receivePathPacket(3, 1, &wire.PathResponseFrame{Data: <-data})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processed).To(BeTrue())
+			Expect(p.validated).To(BeTrue())
+			Expect(p.rcvSeqKnown).To(BeTrue())
+			Expect(p.rcvSeq).To(BeEquivalentTo(3))
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).ToNot(HaveOccurred())
+		}
+
+		expectAbandonPath := func() {
+			pathSPH.EXPECT().MigratedPath(gomock.Any(), time.Duration(0), getMaxPacketSize(newAddr))
+			newRunner.EXPECT().RemoveHandler(conn)
+			connRunner.EXPECT().RemoveResetToken(gomock.Any())
+		}
+
+		It("refuses to add a path if the peer doesn't support multipath", func() {
+			conn.paths = nil
+			addConnID()
+			conn.startAddingPath(probe)
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError("peer doesn't support multipath"))
+		})
+
+		It("refuses to add a path if there's no unused connection ID", func() {
+			conn.startAddingPath(probe)
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError("no unused connection ID available"))
+			Expect(conn.paths).To(HaveLen(1))
+		})
+
+		It("validates a new path", func() {
+			p := addPath()
+			Expect(p.id).To(Equal(PathID(1)))
+			Expect(p.destConnID).To(Equal(newDestConnID))
+			Expect(conn.paths).To(HaveLen(2))
+			validatePath(p)
+			Expect(p.rttStats.SmoothedRTT()).To(BeNumerically(">", 0))
+			// the initial path is still used
+			Expect(conn.connIDManager.Get()).To(Equal(destConnID))
+		})
+
+		It("ignores PATH_RESPONSEs that don't match a PATH_CHALLENGE", func() {
+			p := addPath()
+			tracer.EXPECT().DroppedPacket(logging.PacketType1RTT, gomock.Any(), logging.PacketDropUnknownConnectionID)
+			processed, err := receivePathPacket(3, 1, &wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processed).To(BeFalse())
+			Expect(p.validated).To(BeFalse())
+			Expect(probe.Done()).ToNot(BeClosed())
+		})
+
+		It("abandons a path if validation times out", func() {
+			p := addPath()
+			pathSPH.EXPECT().GetLossDetectionTimeout().AnyTimes()
+			now := time.Now()
+			for i := 0; i < maxPathChallenges; i++ {
+				pathPacker.EXPECT().PackPathProbePacket(newDestConnID, gomock.Any(), protocol.ByteCount(protocol.MinInitialPacketSize)).Return(getPathPacket(protocol.PacketNumber(i)), nil)
+				expectPacketOnPath()
+				Expect(conn.handlePathTimeouts(now)).To(Succeed())
+				now = probe.NextTimeout()
+			}
+			Expect(probe.Done()).ToNot(BeClosed())
+			expectAbandonPath()
+			Expect(conn.handlePathTimeouts(now)).To(Succeed())
+			Expect(probe.Done()).To(BeClosed())
+			Expect(probe.Err()).To(MatchError(errPathValidationFailed))
+			Expect(conn.paths).ToNot(HaveKey(p.id))
+		})
+
+		Context("using a validated path", func() {
+			var p *path
+
+			JustBeforeEach(func() {
+				p = addPath()
+				validatePath(p)
+			})
+
+			It("uses a separate packet number space for every path", func() {
+				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
+				// ACKs received on the path acknowledge packets sent on that path
+				pathRPH.EXPECT().IsPotentiallyDuplicate(protocol.PacketNumber(2), protocol.Encryption1RTT)
+				tracer.EXPECT().ReceivedPacket(gomock.Any(), gomock.Any(), gomock.Any())
+				pathSPH.EXPECT().ReceivedAck(ack, protocol.Encryption1RTT, gomock.Any())
+				pathRPH.EXPECT().ReceivedPacket(protocol.PacketNumber(2), gomock.Any(), protocol.Encryption1RTT, gomock.Any(), false)
+				processed, err := receivePathPacket(3, 2, ack)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(processed).To(BeTrue())
+				Expect(p.largestRcvdPacketNumber).To(Equal(protocol.PacketNumber(2)))
+				// duplicate packets are detected per path
+				pathRPH.EXPECT().IsPotentiallyDuplicate(protocol.PacketNumber(2), protocol.Encryption1RTT).Return(true)
+				tracer.EXPECT().DroppedPacket(logging.PacketType1RTT, gomock.Any(), logging.PacketDropDuplicate)
+				processed, err = receivePathPacket(3, 2, ack)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(processed).To(BeFalse())
+			})
+
+			It("sends ACKs on the path that the packets were received on", func() {
+				sph.EXPECT().SendMode().Return(ackhandler.SendNone).AnyTimes()
+				pathSPH.EXPECT().SendMode().Return(ackhandler.SendAck).AnyTimes()
+				pathPacker.EXPECT().MaybePackAckPacket(true).Return(getPathPacket(11), nil)
+				expectPacketOnPath()
+				Expect(conn.sendPacketsMultipath(time.Now())).To(Succeed())
+			})
+
+			It("responds to PATH_CHALLENGEs on the same path", func() {
+				pathRPH.EXPECT().IsPotentiallyDuplicate(gomock.Any(), gomock.Any())
+				tracer.EXPECT().ReceivedPacket(gomock.Any(), gomock.Any(), gomock.Any())
+				pathPacker.EXPECT().PackPathProbePacket(newDestConnID, gomock.Any(), protocol.ByteCount(protocol.MinInitialPacketSize)).DoAndReturn(func(_ protocol.ConnectionID, f ackhandler.Frame, _ protocol.ByteCount) (*packedPacket, error) {
+					Expect(f.Frame).To(Equal(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}))
+					return getPathPacket(11), nil
+				})
+				expectPacketOnPath()
+				pathRPH.EXPECT().ReceivedPacket(protocol.PacketNumber(2), gomock.Any(), protocol.Encryption1RTT, gomock.Any(), true)
+				processed, err := receivePathPacket(3, 2, &wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(processed).To(BeTrue())
+			})
+
+			It("abandons a path when the peer sends a PATH_ABANDON", func() {
+				expectAbandonPath()
+				// the path identifier is the sequence number of the connection ID the peer sends to
+				conn.handlePathAbandonFrame(&wire.PathAbandonFrame{PathIdentifier: 1})
+				Expect(conn.paths).To(HaveKey(p.id))
+				Expect(conn.handleFrame(&wire.PathAbandonFrame{PathIdentifier: 3}, protocol.Encryption1RTT, srcConnID)).To(Succeed())
+				Expect(conn.paths).ToNot(HaveKey(p.id))
+				// the connection ID is retired, and no PATH_ABANDON is sent
+				frames, _ := conn.framer.AppendControlFrames(nil, 1000)
+				Expect(frames).To(Equal([]ackhandler.Frame{{Frame: &wire.RetireConnectionIDFrame{SequenceNumber: 1}}}))
+			})
+
+			It("removes a path", func() {
+				Expect(conn.removePath(initialPathID)).To(MatchError("cannot remove the initial path"))
+				Expect(conn.removePath(42)).To(MatchError("unknown path: 42"))
+				expectAbandonPath()
+				Expect(conn.removePath(p.id)).To(Succeed())
+				Expect(conn.paths).ToNot(HaveKey(p.id))
+				Expect(conn.paths).To(HaveKey(initialPathID))
+				frames, _ := conn.framer.AppendControlFrames(nil, 1000)
+				Expect(frames).To(ConsistOf(
+					ackhandler.Frame{Frame: &wire.PathAbandonFrame{PathIdentifier: 1}},
+					ackhandler.Frame{Frame: &wire.RetireConnectionIDFrame{SequenceNumber: 1}},
+				))
+			})
+
+			It("only offers paths that are allowed to send to the scheduler", func() {
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
+				sph.EXPECT().HasPacingBudget().Return(true).AnyTimes()
+				pathSPH.EXPECT().SendMode().Return(ackhandler.SendAny)
+				pathSPH.EXPECT().HasPacingBudget().Return(true)
+				Expect(conn.sendablePaths()).To(Equal([]*path{conn.paths[initialPathID], p}))
+				// a path that is limited by the pacer
+				deadline := time.Now().Add(time.Hour)
+				pathSPH.EXPECT().SendMode().Return(ackhandler.SendAny)
+				pathSPH.EXPECT().HasPacingBudget().Return(false)
+				pathSPH.EXPECT().TimeUntilSend().Return(deadline)
+				Expect(conn.sendablePaths()).To(Equal([]*path{conn.paths[initialPathID]}))
+				Expect(conn.pacingDeadline).To(Equal(deadline))
+				// a path that is congestion limited
+				pathSPH.EXPECT().SendMode().Return(ackhandler.SendAck)
+				Expect(conn.sendablePaths()).To(Equal([]*path{conn.paths[initialPathID]}))
+				// a path that is not yet validated
+				p.validated = false
+				Expect(conn.sendablePaths()).To(Equal([]*path{conn.paths[initialPathID]}))
+			})
+
+			It("sends on the path with the lowest RTT", func() {
+				Expect(conn.pathScheduler).To(BeAssignableToTypeOf(&minRTTScheduler{}))
+				conn.rttStats.UpdateRTT(time.Hour, 0, time.Now())
+				Expect(conn.selectPath(conn.sortedPaths())).To(Equal(p))
+				p.rttStats = &utils.RTTStats{}
+				p.rttStats.UpdateRTT(2*time.Hour, 0, time.Now())
+				Expect(conn.selectPath(conn.sortedPaths())).To(Equal(conn.paths[initialPathID]))
+			})
+
+			It("stops sending on a path that failed", func() {
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
+				sph.EXPECT().HasPacingBudget().Return(true).AnyTimes()
+				pathSPH.EXPECT().SendMode().Return(ackhandler.SendAny)
+				pathSPH.EXPECT().HasPacingBudget().Return(true)
+				conn.rttStats.UpdateRTT(time.Hour, 0, time.Now())
+				Expect(conn.selectPath(conn.sendablePaths())).To(Equal(p))
+				// sending on the path fails
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				pathSPH.EXPECT().SentPacket(gomock.Any())
+				newConn.EXPECT().Write(gomock.Any()).Return(errors.New("send failed"))
+				expectAbandonPath()
+				conn.sendPackedPacketOnPath(p, getPathPacket(11), time.Now())
+				Expect(conn.paths).ToNot(HaveKey(p.id))
+				Expect(conn.selectPath(conn.sendablePaths())).To(Equal(conn.paths[initialPathID]))
+			})
+
+			Context("round robin", func() {
+				BeforeEach(func() {
+					quicConf.MultipathScheduling = MultipathSchedulingRoundRobin
+				})
+
+				It("sends on all paths in turn", func() {
+					Expect(conn.pathScheduler).To(BeAssignableToTypeOf(&roundRobinScheduler{}))
+					initialPath := conn.paths[initialPathID]
+					Expect(conn.selectPath(conn.sortedPaths())).To(Equal(initialPath))
+					Expect(conn.selectPath(conn.sortedPaths())).To(Equal(p))
+					Expect(conn.selectPath(conn.sortedPaths())).To(Equal(initialPath))
+					// after the path is removed, only the initial path is used
+					expectAbandonPath()
+					Expect(conn.removePath(p.id)).To(Succeed())
+					Expect(conn.selectPath(conn.sortedPaths())).To(Equal(initialPath))
+					Expect(conn.selectPath(conn.sortedPaths())).To(Equal(initialPath))
+				})
+			})
+
+			Context("using a custom scheduler", func() {
+				var scheduler *mockMultipathScheduler
+
+				BeforeEach(func() {
+					scheduler = &mockMultipathScheduler{}
+					quicConf.MultipathScheduling = MultipathSchedulingCustom
+					quicConf.NewMultipathScheduler = func() MultipathScheduler { return scheduler }
+				})
+
+				It("uses the scheduler returned by Config.NewMultipathScheduler", func() {
+					Expect(conn.pathScheduler).To(Equal(scheduler))
+					Expect(conn.selectPath(conn.sortedPaths())).To(Equal(p))
+					Expect(scheduler.offered).To(HaveLen(1))
+					Expect(scheduler.offered[0]).To(HaveLen(2))
+					Expect(scheduler.offered[0][0].ID).To(Equal(initialPathID))
+					Expect(scheduler.offered[0][1].ID).To(Equal(p.id))
+					Expect(scheduler.offered[0][1].RemoteAddr).To(Equal(newAddr))
+					Expect(scheduler.offered[0][1].SmoothedRTT).To(Equal(p.rttStats.SmoothedRTT()))
+				})
+
+				It("falls back to the first path if the scheduler selects an unknown path", func() {
+					scheduler.selected = 42
+					Expect(conn.selectPath(conn.sortedPaths())).To(Equal(conn.paths[initialPathID]))
+				})
+			})
+		})
+	})
+
 	Context("handling tokens", func() {
 		var mockTokenStore *MockTokenStore
 
@@ -1,87 +1,259 @@
 package quic
 
 import (
-	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"sync"
+
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
 
+type queuedDatagram struct {
+	frame      *wire.DatagramFrame
+	onDelivery func(acked bool) // might be nil
+	// dequeued is closed when the frame is dequeued (or dropped from the queue).
+	// It is only set when using AddAndWait.
+	dequeued chan struct{}
+}
+
 type datagramQueue struct {
-	sendQueue chan *wire.DatagramFrame
-	rcvQueue  chan []byte
+	mutex sync.Mutex
+
+	sendQueue       []queuedDatagram
+	maxSendQueueLen int
+	sendDropped     uint64
+	// sendQueueAvailable is signaled when a DATAGRAM frame is dequeued for sending
+	sendQueueAvailable chan struct{}
+	// inFlight holds the delivery callbacks of dequeued DATAGRAM frames,
+	// until the frame is acknowledged or lost.
+	inFlight       map[uint64]func(acked bool)
+	nextInFlightID uint64
+
+	rcvQueue       [][]byte
+	maxRcvQueueLen int
+	rcvDropped     uint64
+	// rcvd is signaled when a DATAGRAM frame is received
+	rcvd chan struct{}
+
+	dropPolicy DatagramDropPolicy
 
 	closeErr error
 	closed   chan struct{}
 
 	hasData func()
 
-	dequeued chan struct{}
-
 	logger utils.Logger
 }
 
-func newDatagramQueue(hasData func(), logger utils.Logger) *datagramQueue {
+func newDatagramQueue(hasData func(), sendQueueLen, rcvQueueLen int, dropPolicy DatagramDropPolicy, logger utils.Logger) *datagramQueue {
 	return &datagramQueue{
-		hasData:   hasData,
-		sendQueue: make(chan *wire.DatagramFrame, 1),
-		rcvQueue:  make(chan []byte, protocol.DatagramRcvQueueLen),
-		dequeued:  make(chan struct{}),
-		closed:    make(chan struct{}),
-		logger:    logger,
+		hasData:            hasData,
+		maxSendQueueLen:    sendQueueLen,
+		maxRcvQueueLen:     rcvQueueLen,
+		dropPolicy:         dropPolicy,
+		sendQueueAvailable: make(chan struct{}, 1),
+		rcvd:               make(chan struct{}, 1),
+		closed:             make(chan struct{}),
+		logger:             logger,
 	}
 }
 
-// AddAndWait queues a new DATAGRAM frame for sending.
-// It blocks until the frame has been dequeued.
-func (h *datagramQueue) AddAndWait(f *wire.DatagramFrame) error {
-	select {
-	case h.sendQueue <- f:
-		h.hasData()
-	case <-h.closed:
-		return h.closeErr
-	}
+// Add queues a new DATAGRAM frame for sending.
+// If the send queue is full, it blocks until a frame has been dequeued.
+// When using DatagramDropOldest, it drops the oldest queued frame instead.
+// onDelivery is called when the frame is acknowledged or lost, or if it is dropped from the queue.
+func (h *datagramQueue) Add(f *wire.DatagramFrame, onDelivery func(acked bool)) error {
+	return h.add(queuedDatagram{frame: f, onDelivery: onDelivery})
+}
 
+// AddAndWait queues a new DATAGRAM frame for sending, like Add.
+// It then blocks until the frame has been dequeued.
+func (h *datagramQueue) AddAndWait(f *wire.DatagramFrame, onDelivery func(acked bool)) error {
+	dequeued := make(chan struct{})
+	if err := h.add(queuedDatagram{frame: f, onDelivery: onDelivery, dequeued: dequeued}); err != nil {
+		return err
+	}
 	select {
-	case <-h.dequeued:
+	case <-dequeued:
 		return nil
 	case <-h.closed:
 		return h.closeErr
 	}
 }
 
+func (h *datagramQueue) add(d queuedDatagram) error {
+	for {
+		h.mutex.Lock()
+		// Check under the mutex, such that the frame is not queued after CloseWithError emptied the queue.
+		select {
+		case <-h.closed:
+			h.mutex.Unlock()
+			return h.closeErr
+		default:
+		}
+		var dropped queuedDatagram
+		if len(h.sendQueue) >= h.maxSendQueueLen && h.dropPolicy == DatagramDropOldest {
+			dropped = h.sendQueue[0]
+			h.logger.Debugf("Discarding queued DATAGRAM frame (%d bytes payload)", len(dropped.frame.Data))
+			h.sendQueue[0] = queuedDatagram{}
+			h.sendQueue = h.sendQueue[1:]
+			h.sendDropped++
+		}
+		if len(h.sendQueue) < h.maxSendQueueLen {
+			h.sendQueue = append(h.sendQueue, d)
+			hasSpace := len(h.sendQueue) < h.maxSendQueueLen
+			h.mutex.Unlock()
+			if dropped.dequeued != nil {
+				close(dropped.dequeued)
+			}
+			if dropped.onDelivery != nil {
+				dropped.onDelivery(false)
+			}
+			if hasSpace {
+				// There might be another caller waiting for space in the queue.
+				h.signal(h.sendQueueAvailable)
+			}
+			h.hasData()
+			return nil
+		}
+		h.mutex.Unlock()
+
+		select {
+		case <-h.sendQueueAvailable:
+		case <-h.closed:
+			return h.closeErr
+		}
+	}
+}
+
 // Get dequeues a DATAGRAM frame for sending.
-func (h *datagramQueue) Get() *wire.DatagramFrame {
-	select {
-	case f := <-h.sendQueue:
-		h.dequeued <- struct{}{}
-		return f
-	default:
-		return nil
+// The delivery callback that was passed to Add is returned along with the frame.
+// If the queue is closed before the returned callback is called, it is called with acked set to false.
+func (h *datagramQueue) Get() (*wire.DatagramFrame, func(acked bool)) {
+	h.mutex.Lock()
+	if len(h.sendQueue) == 0 {
+		h.mutex.Unlock()
+		return nil, nil
+	}
+	d := h.sendQueue[0]
+	h.sendQueue[0] = queuedDatagram{}
+	h.sendQueue = h.sendQueue[1:]
+	var onDelivery func(acked bool)
+	if d.onDelivery != nil {
+		onDelivery = h.trackInFlight(d.onDelivery)
+	}
+	h.mutex.Unlock()
+
+	if d.dequeued != nil {
+		close(d.dequeued)
+	}
+	h.signal(h.sendQueueAvailable)
+	return d.frame, onDelivery
+}
+
+// trackInFlight must be called with the mutex held.
+// The returned callback calls onDelivery, unless the queue was closed in the meantime.
+func (h *datagramQueue) trackInFlight(onDelivery func(acked bool)) func(acked bool) {
+	if h.inFlight == nil {
+		h.inFlight = make(map[uint64]func(acked bool))
+	}
+	id := h.nextInFlightID
+	h.nextInFlightID++
+	h.inFlight[id] = onDelivery
+	return func(acked bool) {
+		h.mutex.Lock()
+		_, ok := h.inFlight[id]
+		delete(h.inFlight, id)
+		h.mutex.Unlock()
+		if ok {
+			onDelivery(acked)
+		}
 	}
 }
 
 // HandleDatagramFrame handles a received DATAGRAM frame.
+// If the receive queue is full, either this frame or the oldest queued frame is dropped,
+// depending on the drop policy.
 func (h *datagramQueue) HandleDatagramFrame(f *wire.DatagramFrame) {
 	data := make([]byte, len(f.Data))
 	copy(data, f.Data)
-	select {
-	case h.rcvQueue <- data:
-	default:
-		h.logger.Debugf("Discarding DATAGRAM frame (%d bytes payload)", len(f.Data))
+
+	h.mutex.Lock()
+	if len(h.rcvQueue) >= h.maxRcvQueueLen {
+		h.rcvDropped++
+		if h.dropPolicy != DatagramDropOldest {
+			h.mutex.Unlock()
+			h.logger.Debugf("Discarding DATAGRAM frame (%d bytes payload)", len(f.Data))
+			return
+		}
+		h.logger.Debugf("Discarding queued DATAGRAM frame (%d bytes payload)", len(h.rcvQueue[0]))
+		h.rcvQueue[0] = nil
+		h.rcvQueue = h.rcvQueue[1:]
 	}
+	h.rcvQueue = append(h.rcvQueue, data)
+	h.mutex.Unlock()
+
+	h.signal(h.rcvd)
 }
 
 // Receive gets a received DATAGRAM frame.
 func (h *datagramQueue) Receive() ([]byte, error) {
+	for {
+		h.mutex.Lock()
+		if len(h.rcvQueue) > 0 {
+			data := h.rcvQueue[0]
+			h.rcvQueue[0] = nil
+			h.rcvQueue = h.rcvQueue[1:]
+			hasMore := len(h.rcvQueue) > 0
+			h.mutex.Unlock()
+			if hasMore {
+				// There might be another caller waiting for a frame.
+				h.signal(h.rcvd)
+			}
+			return data, nil
+		}
+		h.mutex.Unlock()
+
+		select {
+		case <-h.rcvd:
+		case <-h.closed:
+			return nil, h.closeErr
+		}
+	}
+}
+
+// DroppedDatagrams returns the number of DATAGRAM frames dropped
+// because the send or the receive queue was full.
+func (h *datagramQueue) DroppedDatagrams() (send, rcv uint64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.sendDropped, h.rcvDropped
+}
+
+func (h *datagramQueue) signal(c chan<- struct{}) {
 	select {
-	case data := <-h.rcvQueue:
-		return data, nil
-	case <-h.closed:
-		return nil, h.closeErr
+	case c <- struct{}{}:
+	default:
 	}
 }
 
+// CloseWithError closes the queue.
+// The delivery callbacks of all queued and in-flight DATAGRAM frames are called with acked set to false.
 func (h *datagramQueue) CloseWithError(e error) {
+	h.mutex.Lock()
 	h.closeErr = e
 	close(h.closed)
+	queued := h.sendQueue
+	h.sendQueue = nil
+	inFlight := h.inFlight
+	h.inFlight = nil
+	h.mutex.Unlock()
+
+	for _, d := range queued {
+		if d.onDelivery != nil {
+			d.onDelivery(false)
+		}
+	}
+	for _, onDelivery := range inFlight {
+		onDelivery(false)
+	}
 }
@@ -18,38 +18,162 @@ var _ = Describe("Datagram Queue", func() {
 		queued = make(chan struct{}, 100)
 		queue = newDatagramQueue(func() {
 			queued <- struct{}{}
-		}, utils.DefaultLogger)
+		}, 1, 2, DatagramDropNewest, utils.DefaultLogger)
 	})
 
 	Context("sending", func() {
+		getData := func() []byte {
+			f, _ := queue.Get()
+			ExpectWithOffset(1, f).ToNot(BeNil())
+			return f.Data
+		}
+
 		It("returns nil when there's no datagram to send", func() {
-			Expect(queue.Get()).To(BeNil())
+			f, onDelivery := queue.Get()
+			Expect(f).To(BeNil())
+			Expect(onDelivery).To(BeNil())
 		})
 
 		It("queues a datagram", func() {
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foobar")}, nil)).To(Succeed())
+			Expect(queued).To(HaveLen(1))
+			Expect(getData()).To(Equal([]byte("foobar")))
+			f, _ := queue.Get()
+			Expect(f).To(BeNil())
+		})
+
+		It("returns the delivery callback", func() {
+			var acked []bool
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foobar")}, func(a bool) { acked = append(acked, a) })).To(Succeed())
+			f, onDelivery := queue.Get()
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			Expect(onDelivery).ToNot(BeNil())
+			onDelivery(true)
+			Expect(acked).To(Equal([]bool{true}))
+		})
+
+		It("blocks until there's space in the queue", func() {
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foo")}, nil)).To(Succeed())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(queue.Add(&wire.DatagramFrame{Data: []byte("bar")}, nil)).To(Succeed())
+			}()
+
+			Consistently(done).ShouldNot(BeClosed())
+			Expect(getData()).To(Equal([]byte("foo")))
+			Eventually(done).Should(BeClosed())
+			Expect(getData()).To(Equal([]byte("bar")))
+			sent, _ := queue.DroppedDatagrams()
+			Expect(sent).To(BeZero())
+		})
+
+		It("waits until the datagram is dequeued", func() {
 			done := make(chan struct{})
 			go func() {
 				defer GinkgoRecover()
 				defer close(done)
-				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")})).To(Succeed())
+				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")}, nil)).To(Succeed())
 			}()
 
 			Eventually(queued).Should(HaveLen(1))
 			Consistently(done).ShouldNot(BeClosed())
-			f := queue.Get()
-			Expect(f).ToNot(BeNil())
-			Expect(f.Data).To(Equal([]byte("foobar")))
+			Expect(getData()).To(Equal([]byte("foobar")))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("stops waiting when the datagram is dropped", func() {
+			queue = newDatagramQueue(func() { queued <- struct{}{} }, 1, 2, DatagramDropOldest, utils.DefaultLogger)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foo")}, nil)).To(Succeed())
+			}()
+
+			Eventually(queued).Should(HaveLen(1))
+			Consistently(done).ShouldNot(BeClosed())
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("bar")}, nil)).To(Succeed())
 			Eventually(done).Should(BeClosed())
-			Expect(queue.Get()).To(BeNil())
+			Expect(getData()).To(Equal([]byte("bar")))
+		})
+
+		It("drops the oldest datagram when the queue is full", func() {
+			queue = newDatagramQueue(func() {}, 2, 2, DatagramDropOldest, utils.DefaultLogger)
+			var acked []bool
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foo")}, func(a bool) { acked = append(acked, a) })).To(Succeed())
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("bar")}, nil)).To(Succeed())
+			Expect(acked).To(BeEmpty())
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("baz")}, nil)).To(Succeed())
+			Expect(acked).To(Equal([]bool{false}))
+			Expect(getData()).To(Equal([]byte("bar")))
+			Expect(getData()).To(Equal([]byte("baz")))
+			f, _ := queue.Get()
+			Expect(f).To(BeNil())
+			sent, _ := queue.DroppedDatagrams()
+			Expect(sent).To(BeEquivalentTo(1))
 		})
 
 		It("closes", func() {
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foo")}, nil)).To(Succeed())
+			errChan := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				errChan <- queue.Add(&wire.DatagramFrame{Data: []byte("bar")}, nil)
+			}()
+
+			Consistently(errChan).ShouldNot(Receive())
+			queue.CloseWithError(errors.New("test error"))
+			Eventually(errChan).Should(Receive(MatchError("test error")))
+		})
+
+		It("reports queued datagrams as lost when closed", func() {
+			queue = newDatagramQueue(func() {}, 2, 2, DatagramDropNewest, utils.DefaultLogger)
+			var acked []bool
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foo")}, func(a bool) { acked = append(acked, a) })).To(Succeed())
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("bar")}, func(a bool) { acked = append(acked, a) })).To(Succeed())
+			Expect(acked).To(BeEmpty())
+			queue.CloseWithError(errors.New("test error"))
+			Expect(acked).To(Equal([]bool{false, false}))
+			// datagrams can't be queued after closing, and the callback is not called
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("baz")}, func(a bool) { acked = append(acked, a) })).To(MatchError("test error"))
+			Expect(acked).To(HaveLen(2))
+			f, _ := queue.Get()
+			Expect(f).To(BeNil())
+		})
+
+		It("reports in-flight datagrams as lost when closed", func() {
+			var acked []bool
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foo")}, func(a bool) { acked = append(acked, a) })).To(Succeed())
+			_, onDelivery := queue.Get()
+			Expect(onDelivery).ToNot(BeNil())
+			queue.CloseWithError(errors.New("test error"))
+			Expect(acked).To(Equal([]bool{false}))
+			// the callback is only called once
+			onDelivery(true)
+			Expect(acked).To(Equal([]bool{false}))
+		})
+
+		It("doesn't report acknowledged datagrams when closed", func() {
+			var acked []bool
+			Expect(queue.Add(&wire.DatagramFrame{Data: []byte("foo")}, func(a bool) { acked = append(acked, a) })).To(Succeed())
+			_, onDelivery := queue.Get()
+			onDelivery(true)
+			Expect(acked).To(Equal([]bool{true}))
+			onDelivery(false)
+			queue.CloseWithError(errors.New("test error"))
+			Expect(acked).To(Equal([]bool{true}))
+		})
+
+		It("stops waiting for the datagram to be dequeued when closed", func() {
 			errChan := make(chan error, 1)
 			go func() {
 				defer GinkgoRecover()
-				errChan <- queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")})
+				errChan <- queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foo")}, nil)
 			}()
 
+			Eventually(queued).Should(HaveLen(1))
 			Consistently(errChan).ShouldNot(Receive())
 			queue.CloseWithError(errors.New("test error"))
 			Eventually(errChan).Should(Receive(MatchError("test error")))
@@ -82,6 +206,44 @@ var _ = Describe("Datagram Queue", func() {
 			Eventually(c).Should(Receive(Equal([]byte("foobar"))))
 		})
 
+		It("unblocks multiple receivers", func() {
+			c := make(chan []byte, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					defer GinkgoRecover()
+					data, err := queue.Receive()
+					Expect(err).ToNot(HaveOccurred())
+					c <- data
+				}()
+			}
+
+			Consistently(c).ShouldNot(Receive())
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foo")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("bar")})
+			Eventually(c).Should(HaveLen(2))
+		})
+
+		It("drops new DATAGRAM frames when the queue is full", func() {
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foo")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("bar")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("baz")})
+			_, rcvd := queue.DroppedDatagrams()
+			Expect(rcvd).To(BeEquivalentTo(1))
+			Expect(queue.Receive()).To(Equal([]byte("foo")))
+			Expect(queue.Receive()).To(Equal([]byte("bar")))
+		})
+
+		It("drops the oldest DATAGRAM frame when the queue is full", func() {
+			queue = newDatagramQueue(func() {}, 1, 2, DatagramDropOldest, utils.DefaultLogger)
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foo")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("bar")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("baz")})
+			_, rcvd := queue.DroppedDatagrams()
+			Expect(rcvd).To(BeEquivalentTo(1))
+			Expect(queue.Receive()).To(Equal([]byte("bar")))
+			Expect(queue.Receive()).To(Equal([]byte("baz")))
+		})
+
 		It("closes", func() {
 			errChan := make(chan error, 1)
 			go func() {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/lucas-clemente/quic-go/internal/qerr"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 type (
@@ -56,3 +57,14 @@ func (e *StreamError) Is(target error) bool {
 func (e *StreamError) Error() string {
 	return fmt.Sprintf("stream %d canceled with error code %d", e.StreamID, e.ErrorCode)
 }
+
+// A StreamDataExpiredError is returned from Stream.Read if the peer abandoned stream data, see SendStream.SetDataExpiry.
+// The data up to Offset was skipped. This error is not fatal: the next call to Read returns the data starting at Offset.
+type StreamDataExpiredError struct {
+	StreamID StreamID
+	Offset   logging.ByteCount
+}
+
+func (e *StreamDataExpiredError) Error() string {
+	return fmt.Sprintf("stream %d: data up to offset %d expired", e.StreamID, e.Offset)
+}
@@ -0,0 +1,212 @@
+// Package fragmentation sends messages that are larger than the maximum datagram size using QUIC datagrams.
+//
+// Messages are split into fragments that fit into a single DATAGRAM frame, and reassembled by the receiver.
+// Both endpoints need to use this package, since every datagram carries a small header.
+// Datagrams are not retransmitted: if one of the fragments is lost, the whole message is lost.
+//
+//	conn := fragmentation.NewConn(quicConn, nil)
+//	err := conn.SendMessage(msg)
+//	msg, err := conn.ReceiveMessage()
+package fragmentation
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+const (
+	defaultMaxMessageSize        = 1 << 16
+	defaultMaxIncompleteMessages = 16
+)
+
+// Config configures the fragmentation.
+type Config struct {
+	// MaxMessageSize is the maximum size of a message.
+	// Larger messages can't be sent, and are dropped by the receiver.
+	// If zero, a default of 64 KB is used.
+	MaxMessageSize int
+	// MaxIncompleteMessages is the maximum number of messages that are reassembled at the same time.
+	// When a fragment of a new message is received and this limit is reached, the oldest incomplete message is dropped,
+	// and fragments of older messages received later are dropped as well.
+	// If zero, a default of 16 is used.
+	MaxIncompleteMessages int
+}
+
+type partialMessage struct {
+	numFragments uint64
+	// Fragments are stored in a map, such that the memory allocated doesn't depend on the number of fragments claimed by the peer.
+	fragments map[uint64][]byte
+	size      int
+}
+
+// A Conn sends and receives messages that might be larger than a single datagram.
+// It is safe for concurrent use.
+type Conn struct {
+	nextMessageID uint64 // accessed atomically
+
+	conn quic.Connection
+
+	maxMessageSize        int
+	maxIncompleteMessages int
+
+	mutex      sync.Mutex
+	incomplete map[uint64]*partialMessage
+	// Fragments of messages with smaller IDs are dropped.
+	// Message IDs are assigned in increasing order by the sender,
+	// so these messages are older than a message that was dropped.
+	minMessageID uint64
+}
+
+// NewConn creates a new Conn.
+// Datagram support needs to be enabled using quic.Config.EnableDatagrams.
+// The config may be nil.
+func NewConn(conn quic.Connection, conf *Config) *Conn {
+	c := &Conn{
+		conn:                  conn,
+		maxMessageSize:        defaultMaxMessageSize,
+		maxIncompleteMessages: defaultMaxIncompleteMessages,
+		incomplete:            make(map[uint64]*partialMessage),
+	}
+	if conf != nil {
+		if conf.MaxMessageSize > 0 {
+			c.maxMessageSize = conf.MaxMessageSize
+		}
+		if conf.MaxIncompleteMessages > 0 {
+			c.maxIncompleteMessages = conf.MaxIncompleteMessages
+		}
+	}
+	return c
+}
+
+// SendMessage sends a message.
+// If the message is larger than the maximum datagram payload size, it is sent in multiple datagrams.
+func (c *Conn) SendMessage(msg []byte) error {
+	if len(msg) > c.maxMessageSize {
+		return errors.New("message too large")
+	}
+	maxPayloadSize := c.conn.ConnectionState().MaxDatagramPayloadSize
+	if maxPayloadSize == 0 {
+		return errors.New("datagrams not supported by the peer")
+	}
+	id := atomic.AddUint64(&c.nextMessageID, 1) - 1
+	// The number of fragments is not known yet.
+	// The message length is an upper bound for it.
+	maxHdrLen := int(quicvarint.Len(id) + 2*quicvarint.Len(uint64(len(msg))))
+	fragmentSize := maxPayloadSize - maxHdrLen
+	if fragmentSize <= 0 {
+		return errors.New("maximum datagram payload size too small")
+	}
+	numFragments := (len(msg) + fragmentSize - 1) / fragmentSize
+	if numFragments == 0 {
+		numFragments = 1
+	}
+	for i := 0; i < numFragments; i++ {
+		end := (i + 1) * fragmentSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		b := &bytes.Buffer{}
+		quicvarint.Write(b, id)
+		quicvarint.Write(b, uint64(i))
+		quicvarint.Write(b, uint64(numFragments))
+		b.Write(msg[i*fragmentSize : end])
+		if err := c.conn.SendMessage(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReceiveMessage receives a message.
+// It blocks until all fragments of a message have been received.
+func (c *Conn) ReceiveMessage() ([]byte, error) {
+	for {
+		data, err := c.conn.ReceiveMessage()
+		if err != nil {
+			return nil, err
+		}
+		if msg := c.handleFragment(data); msg != nil {
+			return msg, nil
+		}
+	}
+}
+
+// handleFragment handles a received fragment.
+// It returns the message, if this was the last missing fragment.
+// Invalid fragments are dropped.
+func (c *Conn) handleFragment(data []byte) []byte {
+	r := bytes.NewReader(data)
+	id, err := quicvarint.Read(r)
+	if err != nil {
+		return nil
+	}
+	index, err := quicvarint.Read(r)
+	if err != nil {
+		return nil
+	}
+	numFragments, err := quicvarint.Read(r)
+	if err != nil {
+		return nil
+	}
+	if numFragments == 0 || index >= numFragments || numFragments > uint64(c.maxMessageSize) {
+		return nil
+	}
+	payload := data[len(data)-r.Len():]
+	if numFragments == 1 {
+		if len(payload) > c.maxMessageSize {
+			return nil
+		}
+		return payload
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if id < c.minMessageID {
+		return nil
+	}
+	m, ok := c.incomplete[id]
+	if !ok {
+		if len(c.incomplete) >= c.maxIncompleteMessages {
+			oldest := id
+			for i := range c.incomplete {
+				if i < oldest {
+					oldest = i
+				}
+			}
+			c.minMessageID = oldest + 1
+			if oldest == id {
+				return nil
+			}
+			delete(c.incomplete, oldest)
+		}
+		m = &partialMessage{numFragments: numFragments, fragments: make(map[uint64][]byte)}
+		c.incomplete[id] = m
+	}
+	if m.numFragments != numFragments {
+		return nil
+	}
+	if _, ok := m.fragments[index]; ok {
+		return nil
+	}
+	m.fragments[index] = payload
+	m.size += len(payload)
+	if m.size > c.maxMessageSize {
+		delete(c.incomplete, id)
+		return nil
+	}
+	if uint64(len(m.fragments)) < m.numFragments {
+		return nil
+	}
+	delete(c.incomplete, id)
+	msg := make([]byte, 0, m.size)
+	for i := uint64(0); i < m.numFragments; i++ {
+		msg = append(msg, m.fragments[i]...)
+	}
+	return msg
+}
@@ -0,0 +1,176 @@
+package fragmentation
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fragmentation", func() {
+	const maxPayloadSize = 100
+
+	var (
+		sender, receiver     *Conn
+		senderConn, rcvConn  *mockquic.MockEarlyConnection
+		datagrams            [][]byte
+		expectReceiveMessage func()
+	)
+
+	BeforeEach(func() {
+		datagrams = nil
+		senderConn = mockquic.NewMockEarlyConnection(mockCtrl)
+		senderConn.EXPECT().ConnectionState().Return(quic.ConnectionState{SupportsDatagrams: true, MaxDatagramPayloadSize: maxPayloadSize}).AnyTimes()
+		senderConn.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(b []byte) error {
+			Expect(len(b)).To(BeNumerically("<=", maxPayloadSize))
+			datagrams = append(datagrams, b)
+			return nil
+		}).AnyTimes()
+		sender = NewConn(senderConn, nil)
+		rcvConn = mockquic.NewMockEarlyConnection(mockCtrl)
+		receiver = NewConn(rcvConn, nil)
+		expectReceiveMessage = func() {
+			rcvConn.EXPECT().ReceiveMessage().DoAndReturn(func() ([]byte, error) {
+				if len(datagrams) == 0 {
+					return nil, errors.New("no more datagrams")
+				}
+				d := datagrams[0]
+				datagrams = datagrams[1:]
+				return d, nil
+			}).AnyTimes()
+		}
+	})
+
+	It("sends small messages in a single datagram", func() {
+		Expect(sender.SendMessage([]byte("foobar"))).To(Succeed())
+		Expect(datagrams).To(HaveLen(1))
+		expectReceiveMessage()
+		Expect(receiver.ReceiveMessage()).To(Equal([]byte("foobar")))
+	})
+
+	It("sends empty messages", func() {
+		Expect(sender.SendMessage(nil)).To(Succeed())
+		Expect(datagrams).To(HaveLen(1))
+		expectReceiveMessage()
+		msg, err := receiver.ReceiveMessage()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(msg).To(BeEmpty())
+	})
+
+	It("fragments large messages", func() {
+		msg := make([]byte, 1000)
+		rand.Read(msg)
+		Expect(sender.SendMessage(msg)).To(Succeed())
+		Expect(len(datagrams)).To(BeNumerically(">", 10))
+		expectReceiveMessage()
+		Expect(receiver.ReceiveMessage()).To(Equal(msg))
+	})
+
+	It("reassembles fragments received out of order", func() {
+		msg1 := bytes.Repeat([]byte{'a'}, 500)
+		msg2 := bytes.Repeat([]byte{'b'}, 300)
+		Expect(sender.SendMessage(msg1)).To(Succeed())
+		Expect(sender.SendMessage(msg2)).To(Succeed())
+		rand.Shuffle(len(datagrams), func(i, j int) { datagrams[i], datagrams[j] = datagrams[j], datagrams[i] })
+		expectReceiveMessage()
+		var msgs [][]byte
+		for i := 0; i < 2; i++ {
+			msg, err := receiver.ReceiveMessage()
+			Expect(err).ToNot(HaveOccurred())
+			msgs = append(msgs, msg)
+		}
+		Expect(msgs).To(ConsistOf(msg1, msg2))
+	})
+
+	It("doesn't deliver messages if a fragment is lost", func() {
+		msg := make([]byte, 500)
+		Expect(sender.SendMessage(msg)).To(Succeed())
+		Expect(sender.SendMessage([]byte("foobar"))).To(Succeed())
+		datagrams = datagrams[1:]
+		expectReceiveMessage()
+		Expect(receiver.ReceiveMessage()).To(Equal([]byte("foobar")))
+		_, err := receiver.ReceiveMessage()
+		Expect(err).To(MatchError("no more datagrams"))
+	})
+
+	It("ignores duplicate fragments", func() {
+		msg := make([]byte, 500)
+		rand.Read(msg)
+		Expect(sender.SendMessage(msg)).To(Succeed())
+		datagrams = append([][]byte{datagrams[0]}, datagrams...)
+		expectReceiveMessage()
+		Expect(receiver.ReceiveMessage()).To(Equal(msg))
+	})
+
+	It("refuses to send messages larger than the maximum message size", func() {
+		sender = NewConn(senderConn, &Config{MaxMessageSize: 100})
+		Expect(sender.SendMessage(make([]byte, 101))).To(MatchError("message too large"))
+		Expect(sender.SendMessage(make([]byte, 100))).To(Succeed())
+	})
+
+	It("errors if the peer doesn't support datagrams", func() {
+		conn := mockquic.NewMockEarlyConnection(mockCtrl)
+		conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+		Expect(NewConn(conn, nil).SendMessage([]byte("foobar"))).To(MatchError("datagrams not supported by the peer"))
+	})
+
+	It("returns errors when sending", func() {
+		conn := mockquic.NewMockEarlyConnection(mockCtrl)
+		conn.EXPECT().ConnectionState().Return(quic.ConnectionState{SupportsDatagrams: true, MaxDatagramPayloadSize: maxPayloadSize})
+		conn.EXPECT().SendMessage(gomock.Any()).Return(errors.New("test error"))
+		Expect(NewConn(conn, nil).SendMessage([]byte("foobar"))).To(MatchError("test error"))
+	})
+
+	It("drops messages that are larger than the maximum message size", func() {
+		receiver = NewConn(rcvConn, &Config{MaxMessageSize: 400})
+		Expect(sender.SendMessage(make([]byte, 401))).To(Succeed())
+		Expect(sender.SendMessage([]byte("foobar"))).To(Succeed())
+		expectReceiveMessage()
+		Expect(receiver.ReceiveMessage()).To(Equal([]byte("foobar")))
+		Expect(receiver.incomplete).To(BeEmpty())
+	})
+
+	It("drops the oldest incomplete message", func() {
+		receiver = NewConn(rcvConn, &Config{MaxIncompleteMessages: 2})
+		msg := bytes.Repeat([]byte{'a'}, 200)
+		for i := 0; i < 3; i++ {
+			Expect(sender.SendMessage(msg)).To(Succeed())
+		}
+		// deliver the first fragment of every message, then the remaining fragments
+		numFragments := len(datagrams) / 3
+		var reordered [][]byte
+		for i := 0; i < 3; i++ {
+			reordered = append(reordered, datagrams[i*numFragments])
+		}
+		for i := 0; i < 3; i++ {
+			reordered = append(reordered, datagrams[i*numFragments+1:(i+1)*numFragments]...)
+		}
+		datagrams = reordered
+		expectReceiveMessage()
+		Expect(receiver.ReceiveMessage()).To(Equal(msg))
+		Expect(receiver.ReceiveMessage()).To(Equal(msg))
+		_, err := receiver.ReceiveMessage()
+		Expect(err).To(MatchError("no more datagrams"))
+		// the remaining fragments of the first message were dropped
+		Expect(receiver.incomplete).To(BeEmpty())
+	})
+
+	It("drops invalid fragments", func() {
+		b := &bytes.Buffer{}
+		quicvarint.Write(b, 0)
+		quicvarint.Write(b, 2) // fragment index
+		quicvarint.Write(b, 2) // number of fragments
+		b.WriteString("foobar")
+		Expect(receiver.handleFragment(b.Bytes())).To(BeNil())
+		Expect(receiver.handleFragment([]byte{0x40})).To(BeNil())
+		Expect(receiver.incomplete).To(BeEmpty())
+	})
+})
@@ -204,6 +204,36 @@ func (s *frameSorter) deleteConsecutive(pos protocol.ByteCount) {
 	}
 }
 
+// Skip discards all data below offset.
+func (s *frameSorter) Skip(offset protocol.ByteCount) {
+	if offset <= s.readPos {
+		return
+	}
+	for pos, entry := range s.queue {
+		if pos >= offset {
+			continue
+		}
+		delete(s.queue, pos)
+		if pos+protocol.ByteCount(len(entry.Data)) <= offset {
+			if entry.DoneCb != nil {
+				entry.DoneCb()
+			}
+			continue
+		}
+		s.queue[offset] = frameSorterEntry{Data: entry.Data[offset-pos:], DoneCb: entry.DoneCb}
+	}
+	for gap := s.gaps.Front(); gap != nil && gap.Value.Start < offset; {
+		next := gap.Next()
+		if gap.Value.End <= offset {
+			s.gaps.Remove(gap)
+		} else {
+			gap.Value.Start = offset
+		}
+		gap = next
+	}
+	s.readPos = offset
+}
+
 func (s *frameSorter) Pop() (protocol.ByteCount, []byte, func()) {
 	entry, ok := s.queue[s.readPos]
 	if !ok {
@@ -218,6 +248,30 @@ func (s *frameSorter) Pop() (protocol.ByteCount, []byte, func()) {
 	return offset, entry.Data, entry.DoneCb
 }
 
+// ContiguousLen returns the number of bytes that can be popped before hitting a gap.
+func (s *frameSorter) ContiguousLen() protocol.ByteCount {
+	return s.gaps.Front().Value.Start - s.readPos
+}
+
+// Peek appends up to n bytes of the data starting at the read position to b, without popping it.
+func (s *frameSorter) Peek(b []byte, n int) []byte {
+	pos := s.readPos
+	for n > 0 {
+		entry, ok := s.queue[pos]
+		if !ok {
+			break
+		}
+		data := entry.Data
+		if len(data) > n {
+			data = data[:n]
+		}
+		b = append(b, data...)
+		n -= len(data)
+		pos += protocol.ByteCount(len(entry.Data))
+	}
+	return b
+}
+
 // HasMoreData says if there is any more data queued at *any* offset.
 func (s *frameSorter) HasMoreData() bool {
 	return len(s.queue) > 0
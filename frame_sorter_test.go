@@ -123,6 +123,101 @@ var _ = Describe("frame sorter", func() {
 		Expect(s.HasMoreData()).To(BeFalse())
 	})
 
+	It("says how much data can be popped without hitting a gap", func() {
+		Expect(s.ContiguousLen()).To(BeZero())
+		Expect(s.Push([]byte("bar"), 3, nil)).To(Succeed())
+		Expect(s.ContiguousLen()).To(BeZero())
+		Expect(s.Push([]byte("foo"), 0, nil)).To(Succeed())
+		Expect(s.Push([]byte("baz"), 10, nil)).To(Succeed())
+		Expect(s.ContiguousLen()).To(Equal(protocol.ByteCount(6)))
+		s.Pop()
+		Expect(s.ContiguousLen()).To(Equal(protocol.ByteCount(3)))
+	})
+
+	It("peeks at the data without popping it", func() {
+		Expect(s.Peek(nil, 5)).To(BeEmpty())
+		Expect(s.Push([]byte("foo"), 0, nil)).To(Succeed())
+		Expect(s.Push([]byte("bar"), 3, nil)).To(Succeed())
+		Expect(s.Push([]byte("baz"), 10, nil)).To(Succeed())
+		Expect(s.Peek(nil, 5)).To(Equal([]byte("fooba")))
+		Expect(s.Peek([]byte("x"), 100)).To(Equal([]byte("xfoobar")))
+		_, data, _ := s.Pop()
+		Expect(data).To(Equal([]byte("foo")))
+		Expect(s.Peek(nil, 2)).To(Equal([]byte("ba")))
+	})
+
+	Context("skipping data", func() {
+		It("skips data that hasn't been received", func() {
+			cb, t := getCallback()
+			Expect(s.Push([]byte("foobar"), 10, cb)).To(Succeed())
+			s.Skip(10)
+			checkGaps([]utils.ByteInterval{{Start: 16, End: protocol.MaxByteCount}})
+			offset, data, doneCb := s.Pop()
+			Expect(offset).To(Equal(protocol.ByteCount(10)))
+			Expect(data).To(Equal([]byte("foobar")))
+			Expect(doneCb).ToNot(BeNil())
+			checkCallbackNotCalled(t)
+		})
+
+		It("drops received data below the offset", func() {
+			cb1, t1 := getCallback()
+			cb2, t2 := getCallback()
+			Expect(s.Push([]byte("foo"), 0, cb1)).To(Succeed())
+			Expect(s.Push([]byte("bar"), 5, cb2)).To(Succeed())
+			s.Skip(8)
+			checkCallbackCalled(t1)
+			checkCallbackCalled(t2)
+			checkGaps([]utils.ByteInterval{{Start: 8, End: protocol.MaxByteCount}})
+			_, data, _ := s.Pop()
+			Expect(data).To(BeNil())
+			Expect(s.HasMoreData()).To(BeFalse())
+		})
+
+		It("cuts a frame that contains the offset", func() {
+			cb, t := getCallback()
+			Expect(s.Push([]byte("foobar"), 2, cb)).To(Succeed())
+			s.Skip(5)
+			checkGaps([]utils.ByteInterval{{Start: 8, End: protocol.MaxByteCount}})
+			offset, data, doneCb := s.Pop()
+			Expect(offset).To(Equal(protocol.ByteCount(5)))
+			Expect(data).To(Equal([]byte("bar")))
+			Expect(doneCb).ToNot(BeNil())
+			checkCallbackNotCalled(t)
+		})
+
+		It("keeps gaps above the offset", func() {
+			Expect(s.Push([]byte("foo"), 5, nil)).To(Succeed())
+			Expect(s.Push([]byte("bar"), 10, nil)).To(Succeed())
+			s.Skip(6)
+			checkGaps([]utils.ByteInterval{
+				{Start: 8, End: 10},
+				{Start: 13, End: protocol.MaxByteCount},
+			})
+			offset, data, _ := s.Pop()
+			Expect(offset).To(Equal(protocol.ByteCount(6)))
+			Expect(data).To(Equal([]byte("oo")))
+			_, data, _ = s.Pop()
+			Expect(data).To(BeNil())
+		})
+
+		It("ignores data below the offset received later", func() {
+			s.Skip(10)
+			Expect(s.Push([]byte("foobar"), 0, nil)).To(Succeed())
+			Expect(s.HasMoreData()).To(BeFalse())
+			Expect(s.Push([]byte("foobar"), 6, nil)).To(Succeed())
+			offset, data, _ := s.Pop()
+			Expect(offset).To(Equal(protocol.ByteCount(10)))
+			Expect(data).To(Equal([]byte("ar")))
+		})
+
+		It("doesn't skip data that was already read", func() {
+			Expect(s.Push([]byte("foobar"), 0, nil)).To(Succeed())
+			s.Pop()
+			s.Skip(3)
+			checkGaps([]utils.ByteInterval{{Start: 6, End: protocol.MaxByteCount}})
+		})
+	})
+
 	Context("Gap handling", func() {
 		var dataCounter uint8
 
@@ -2,6 +2,7 @@ package quic
 
 import (
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/lucas-clemente/quic-go/internal/ackhandler"
@@ -17,6 +18,8 @@ type framer interface {
 	AppendControlFrames([]ackhandler.Frame, protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount)
 
 	AddActiveStream(protocol.StreamID)
+	SetStreamPriority(protocol.StreamID, StreamPriority)
+	RemoveStream(protocol.StreamID)
 	AppendStreamFrames([]ackhandler.Frame, protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount)
 
 	Handle0RTTRejection() error
@@ -28,8 +31,11 @@ type framerI struct {
 	streamGetter streamGetter
 	version      protocol.VersionNumber
 
-	activeStreams map[protocol.StreamID]struct{}
-	streamQueue   []protocol.StreamID
+	activeStreams map[protocol.StreamID]*activeStream
+	// the queues of the active streams, one for every urgency, sorted by urgency
+	streamQueues []*streamQueue
+	// the priorities of the streams that don't use the default priority
+	priorities map[protocol.StreamID]StreamPriority
 
 	controlFrameMutex sync.Mutex
 	controlFrames     []wire.Frame
@@ -37,20 +43,34 @@ type framerI struct {
 
 var _ framer = &framerI{}
 
+type activeStream struct {
+	urgency uint8
+	// the number of packets the stream can still fill in its current turn
+	packetsLeft uint8
+}
+
+type streamQueue struct {
+	urgency uint8
+	ids     []protocol.StreamID
+}
+
+var defaultStreamPriority = StreamPriority{Urgency: DefaultStreamUrgency, Weight: 1}
+
 func newFramer(
 	streamGetter streamGetter,
 	v protocol.VersionNumber,
 ) framer {
 	return &framerI{
 		streamGetter:  streamGetter,
-		activeStreams: make(map[protocol.StreamID]struct{}),
+		activeStreams: make(map[protocol.StreamID]*activeStream),
+		priorities:    make(map[protocol.StreamID]StreamPriority),
 		version:       v,
 	}
 }
 
 func (f *framerI) HasData() bool {
 	f.mutex.Lock()
-	hasData := len(f.streamQueue) > 0
+	hasData := len(f.activeStreams) > 0
 	f.mutex.Unlock()
 	if hasData {
 		return true
@@ -87,53 +107,146 @@ func (f *framerI) AppendControlFrames(frames []ackhandler.Frame, maxLen protocol
 func (f *framerI) AddActiveStream(id protocol.StreamID) {
 	f.mutex.Lock()
 	if _, ok := f.activeStreams[id]; !ok {
-		f.streamQueue = append(f.streamQueue, id)
-		f.activeStreams[id] = struct{}{}
+		urgency := f.priority(id).Urgency
+		f.activeStreams[id] = &activeStream{urgency: urgency}
+		q := f.getStreamQueue(urgency)
+		q.ids = append(q.ids, id)
 	}
 	f.mutex.Unlock()
 }
 
-func (f *framerI) AppendStreamFrames(frames []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
-	var length protocol.ByteCount
-	var lastFrame *ackhandler.Frame
+func (f *framerI) SetStreamPriority(id protocol.StreamID, p StreamPriority) {
+	if p.Weight == 0 {
+		p.Weight = 1
+	}
+
 	f.mutex.Lock()
-	// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
-	numActiveStreams := len(f.streamQueue)
-	for i := 0; i < numActiveStreams; i++ {
-		if protocol.MinStreamFrameSize+length > maxLen {
+	defer f.mutex.Unlock()
+
+	if p == defaultStreamPriority {
+		delete(f.priorities, id)
+	} else {
+		f.priorities[id] = p
+	}
+	str, ok := f.activeStreams[id]
+	if !ok || str.urgency == p.Urgency {
+		return
+	}
+	// move the stream to the end of the queue for its new urgency
+	q := f.getStreamQueue(str.urgency)
+	for i, qid := range q.ids {
+		if qid == id {
+			q.ids = append(q.ids[:i], q.ids[i+1:]...)
 			break
 		}
-		id := f.streamQueue[0]
-		f.streamQueue = f.streamQueue[1:]
-		// This should never return an error. Better check it anyway.
-		// The stream will only be in the streamQueue, if it enqueued itself there.
-		str, err := f.streamGetter.GetOrOpenSendStream(id)
-		// The stream can be nil if it completed after it said it had data.
-		if str == nil || err != nil {
-			delete(f.activeStreams, id)
-			continue
-		}
-		remainingLen := maxLen - length
-		// For the last STREAM frame, we'll remove the DataLen field later.
-		// Therefore, we can pretend to have more bytes available when popping
-		// the STREAM frame (which will always have the DataLen set).
-		remainingLen += quicvarint.Len(uint64(remainingLen))
-		frame, hasMoreData := str.popStreamFrame(remainingLen)
-		if hasMoreData { // put the stream back in the queue (at the end)
-			f.streamQueue = append(f.streamQueue, id)
-		} else { // no more data to send. Stream is not active any more
-			delete(f.activeStreams, id)
+	}
+	str.urgency = p.Urgency
+	str.packetsLeft = 0
+	newQueue := f.getStreamQueue(p.Urgency)
+	newQueue.ids = append(newQueue.ids, id)
+	f.removeEmptyStreamQueues()
+}
+
+// RemoveStream is called when a stream is completed.
+func (f *framerI) RemoveStream(id protocol.StreamID) {
+	f.mutex.Lock()
+	delete(f.priorities, id)
+	f.mutex.Unlock()
+}
+
+func (f *framerI) priority(id protocol.StreamID) StreamPriority {
+	if p, ok := f.priorities[id]; ok {
+		return p
+	}
+	return defaultStreamPriority
+}
+
+// getStreamQueue gets the queue for an urgency.
+// If there's no queue for this urgency yet, it is created.
+func (f *framerI) getStreamQueue(urgency uint8) *streamQueue {
+	i := sort.Search(len(f.streamQueues), func(i int) bool { return f.streamQueues[i].urgency >= urgency })
+	if i < len(f.streamQueues) && f.streamQueues[i].urgency == urgency {
+		return f.streamQueues[i]
+	}
+	q := &streamQueue{urgency: urgency}
+	f.streamQueues = append(f.streamQueues, nil)
+	copy(f.streamQueues[i+1:], f.streamQueues[i:])
+	f.streamQueues[i] = q
+	return q
+}
+
+func (f *framerI) removeEmptyStreamQueues() {
+	var j int
+	for _, q := range f.streamQueues {
+		if len(q.ids) > 0 {
+			f.streamQueues[j] = q
+			j++
 		}
-		// The frame can be nil
-		// * if the receiveStream was canceled after it said it had data
-		// * the remaining size doesn't allow us to add another STREAM frame
-		if frame == nil {
-			continue
+	}
+	for i := j; i < len(f.streamQueues); i++ {
+		f.streamQueues[i] = nil
+	}
+	f.streamQueues = f.streamQueues[:j]
+}
+
+// AppendStreamFrames appends STREAM frames, according to the priorities of the streams.
+// Streams with a lower urgency are served first.
+// Streams of the same urgency take turns, and in its turn, a stream fills up to weight packets.
+// Every stream is asked for data at most once per packet.
+func (f *framerI) AppendStreamFrames(frames []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
+	var length protocol.ByteCount
+	var lastFrame *ackhandler.Frame
+	f.mutex.Lock()
+queueLoop:
+	for _, q := range f.streamQueues {
+		// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
+		numActiveStreams := len(q.ids)
+		for i := 0; i < numActiveStreams; i++ {
+			if protocol.MinStreamFrameSize+length > maxLen {
+				break queueLoop
+			}
+			id := q.ids[0]
+			// This should never return an error. Better check it anyway.
+			// The stream will only be in the streamQueue, if it enqueued itself there.
+			str, err := f.streamGetter.GetOrOpenSendStream(id)
+			// The stream can be nil if it completed after it said it had data.
+			if str == nil || err != nil {
+				q.ids = q.ids[1:]
+				delete(f.activeStreams, id)
+				continue
+			}
+			as := f.activeStreams[id]
+			if as.packetsLeft == 0 { // start a new turn
+				as.packetsLeft = f.priority(id).Weight
+			}
+			as.packetsLeft--
+			remainingLen := maxLen - length
+			// For the last STREAM frame, we'll remove the DataLen field later.
+			// Therefore, we can pretend to have more bytes available when popping
+			// the STREAM frame (which will always have the DataLen set).
+			remainingLen += quicvarint.Len(uint64(remainingLen))
+			frame, hasMoreData := str.popStreamFrame(remainingLen)
+			if !hasMoreData { // no more data to send. Stream is not active any more
+				q.ids = q.ids[1:]
+				delete(f.activeStreams, id)
+			} else if as.packetsLeft == 0 { // the stream's turn is over, put it back in the queue (at the end)
+				q.ids = append(q.ids[1:], id)
+			}
+			// The frame can be nil
+			// * if the receiveStream was canceled after it said it had data
+			// * the remaining size doesn't allow us to add another STREAM frame
+			if frame != nil {
+				frames = append(frames, *frame)
+				length += frame.Length(f.version)
+				lastFrame = frame
+			}
+			// The stream keeps its place at the head of the queue, and fills the next packet.
+			if hasMoreData && as.packetsLeft > 0 {
+				break queueLoop
+			}
 		}
-		frames = append(frames, *frame)
-		length += frame.Length(f.version)
-		lastFrame = frame
 	}
+	f.removeEmptyStreamQueues()
 	f.mutex.Unlock()
 	if lastFrame != nil {
 		lastFrameLen := lastFrame.Length(f.version)
@@ -149,10 +262,13 @@ func (f *framerI) Handle0RTTRejection() error {
 	defer f.mutex.Unlock()
 
 	f.controlFrameMutex.Lock()
-	f.streamQueue = f.streamQueue[:0]
+	f.streamQueues = f.streamQueues[:0]
 	for id := range f.activeStreams {
 		delete(f.activeStreams, id)
 	}
+	for id := range f.priorities {
+		delete(f.priorities, id)
+	}
 	var j int
 	for i, frame := range f.controlFrames {
 		switch frame.(type) {
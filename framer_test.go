@@ -374,6 +374,85 @@ var _ = Describe("Framer", func() {
 			Expect(length).To(Equal(f.Length(version)))
 		})
 
+		Context("priorities", func() {
+			const id3 = protocol.StreamID(12)
+
+			It("sends data of streams with a lower urgency first", func() {
+				streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
+				streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil)
+				f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+				f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+				stream1.EXPECT().popStreamFrame(gomock.Any()).Return(&ackhandler.Frame{Frame: f1}, false)
+				stream2.EXPECT().popStreamFrame(gomock.Any()).Return(&ackhandler.Frame{Frame: f2}, false)
+				framer.SetStreamPriority(id2, StreamPriority{Urgency: 1})
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				frames, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount)
+				Expect(frames).To(HaveLen(2))
+				Expect(frames[0].Frame).To(Equal(f2))
+				Expect(frames[1].Frame).To(Equal(f1))
+			})
+
+			It("moves an active stream when its urgency changes", func() {
+				streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
+				streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil)
+				f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+				f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+				stream1.EXPECT().popStreamFrame(gomock.Any()).Return(&ackhandler.Frame{Frame: f1}, false)
+				stream2.EXPECT().popStreamFrame(gomock.Any()).Return(&ackhandler.Frame{Frame: f2}, false)
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: DefaultStreamUrgency + 1})
+				frames, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount)
+				Expect(frames).To(HaveLen(2))
+				Expect(frames[0].Frame).To(Equal(f2))
+				Expect(frames[1].Frame).To(Equal(f1))
+			})
+
+			It("lets a stream fill as many packets as its weight, before the next stream's turn", func() {
+				stream3 := NewMockSendStreamI(mockCtrl)
+				streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).AnyTimes()
+				streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).AnyTimes()
+				streamGetter.EXPECT().GetOrOpenSendStream(id3).Return(stream3, nil).AnyTimes()
+				newFrame := func(id protocol.StreamID) func(protocol.ByteCount) (*ackhandler.Frame, bool) {
+					return func(size protocol.ByteCount) (*ackhandler.Frame, bool) {
+						f := &wire.StreamFrame{StreamID: id, DataLenPresent: true}
+						f.Data = make([]byte, f.MaxDataLen(size, version))
+						return &ackhandler.Frame{Frame: f}, true
+					}
+				}
+				stream1.EXPECT().popStreamFrame(gomock.Any()).DoAndReturn(newFrame(id1)).AnyTimes()
+				stream2.EXPECT().popStreamFrame(gomock.Any()).DoAndReturn(newFrame(id2)).AnyTimes()
+				stream3.EXPECT().popStreamFrame(gomock.Any()).DoAndReturn(newFrame(id3)).AnyTimes()
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: DefaultStreamUrgency, Weight: 3})
+				framer.SetStreamPriority(id2, StreamPriority{Urgency: DefaultStreamUrgency, Weight: 2})
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				framer.AddActiveStream(id3)
+				var ids []protocol.StreamID
+				for i := 0; i < 12; i++ {
+					frames, _ := framer.AppendStreamFrames(nil, 1000)
+					Expect(frames).To(HaveLen(1))
+					ids = append(ids, frames[0].Frame.(*wire.StreamFrame).StreamID)
+				}
+				Expect(ids).To(Equal([]protocol.StreamID{id1, id1, id1, id2, id2, id3, id1, id1, id1, id2, id2, id3}))
+			})
+
+			It("forgets the priority of a stream when it is removed", func() {
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: 1})
+				Expect(framer.(*framerI).priorities).To(HaveKey(id1))
+				framer.RemoveStream(id1)
+				Expect(framer.(*framerI).priorities).To(BeEmpty())
+			})
+
+			It("doesn't store the default priority", func() {
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: 1})
+				Expect(framer.(*framerI).priorities).To(HaveKey(id1))
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: DefaultStreamUrgency})
+				Expect(framer.(*framerI).priorities).To(BeEmpty())
+			})
+		})
+
 		It("drops all STREAM frames when 0-RTT is rejected", func() {
 			framer.AddActiveStream(id1)
 			Expect(framer.Handle0RTTRejection()).To(Succeed())
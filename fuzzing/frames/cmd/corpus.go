@@ -132,6 +132,12 @@ func getFrames() []wire.Frame {
 			ErrorCode: quic.StreamErrorCode(getRandomNumber()),
 			FinalSize: protocol.MaxByteCount,
 		},
+		&wire.ResetStreamFrame{ // RESET_STREAM_AT
+			StreamID:     protocol.StreamID(getRandomNumber()),
+			ErrorCode:    quic.StreamErrorCode(getRandomNumber()),
+			FinalSize:    protocol.ByteCount(getRandomNumber()) + 1000,
+			ReliableSize: 1000,
+		},
 		&wire.StopSendingFrame{
 			StreamID:  protocol.StreamID(getRandomNumber()),
 			ErrorCode: quic.StreamErrorCode(getRandomNumber()),
@@ -248,6 +254,25 @@ func getFrames() []wire.Frame {
 		Data: data2,
 	})
 
+	frames = append(frames, []wire.Frame{
+		&wire.AckFrequencyFrame{
+			SequenceNumber:    uint64(rand.Intn(100)),
+			PacketTolerance:   uint64(rand.Intn(50)) + 1,
+			UpdateMaxAckDelay: time.Duration(rand.Intn(100)) * time.Millisecond,
+			IgnoreOrder:       rand.Intn(2) == 0,
+		},
+		&wire.ImmediateAckFrame{},
+		&wire.PathAbandonFrame{
+			PathIdentifier: uint64(rand.Intn(10)),
+			ErrorCode:      getRandomNumber(),
+			ReasonPhrase:   string(getRandomData(50)),
+		},
+		&wire.ExpiredStreamDataFrame{
+			StreamID: protocol.StreamID(getRandomNumber()),
+			Offset:   protocol.ByteCount(getRandomNumber()),
+		},
+	}...)
+
 	return frames
 }
 
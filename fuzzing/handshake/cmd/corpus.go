@@ -86,6 +86,7 @@ func main() {
 			RootCAs:            testdata.GetRootCA(),
 			ClientSessionCache: tls.NewLRUClientSessionCache(1),
 		},
+		nil,
 		false,
 		utils.NewRTTStats(),
 		nil,
@@ -105,6 +106,7 @@ func main() {
 		&wire.TransportParameters{},
 		runner,
 		config,
+		nil,
 		false,
 		utils.NewRTTStats(),
 		nil,
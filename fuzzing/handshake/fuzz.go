@@ -382,6 +382,7 @@ func runHandshake(runConfig [confLen]byte, messageConfig uint8, clientConf *tls.
 		clientTP,
 		runner,
 		clientConf,
+		nil,
 		enable0RTTClient,
 		utils.NewRTTStats(),
 		nil,
@@ -399,6 +400,7 @@ func runHandshake(runConfig [confLen]byte, messageConfig uint8, clientConf *tls.
 		serverTP,
 		runner,
 		serverConf,
+		nil,
 		enable0RTTServer,
 		utils.NewRTTStats(),
 		nil,
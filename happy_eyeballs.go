@@ -0,0 +1,199 @@
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// resolutionDelay is the time to wait for the AAAA query to complete after the A query completed (RFC 8305, Section 3).
+	resolutionDelay = 50 * time.Millisecond
+	// connectionAttemptDelay is the time to wait for a connection attempt before starting the next one (RFC 8305, Section 5).
+	connectionAttemptDelay = 250 * time.Millisecond
+)
+
+// lookupIP resolves a host name. It can be replaced in the tests.
+var lookupIP = net.DefaultResolver.LookupIP
+
+// DialAddrHappyEyeballs establishes a new QUIC connection to a server,
+// racing connection attempts to its IPv6 and IPv4 addresses (RFC 8305).
+// See DialAddrHappyEyeballsContext for details.
+func DialAddrHappyEyeballs(
+	addr string,
+	tlsConf *tls.Config,
+	config *Config,
+) (Connection, error) {
+	return DialAddrHappyEyeballsContext(context.Background(), addr, tlsConf, config)
+}
+
+// DialAddrHappyEyeballsContext establishes a new QUIC connection to a server,
+// racing connection attempts to its IPv6 and IPv4 addresses (RFC 8305).
+// The A and AAAA records are queried concurrently. If the A query completes first,
+// the AAAA query is given another 50ms to complete.
+// The addresses are then dialed alternating between the address families, starting with IPv6.
+// A new connection attempt is started every 250ms, or as soon as the previous attempt failed.
+// The first connection whose handshake completes is returned, all other attempts are canceled.
+// Every connection attempt uses a new UDP connection, which is closed when the QUIC connection is closed.
+// The hostname for SNI is taken from the given address.
+func DialAddrHappyEyeballsContext(
+	ctx context.Context,
+	addr string,
+	tlsConf *tls.Config,
+	config *Config,
+) (Connection, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := net.LookupPort("udp", portStr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := resolveHappyEyeballs(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return raceHappyEyeballs(ctx, ips, connectionAttemptDelay, func(ctx context.Context, ip net.IP) (quicConn, error) {
+		udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+		if err != nil {
+			return nil, err
+		}
+		return dialContext(ctx, udpConn, &net.UDPAddr{IP: ip, Port: port}, addr, tlsConf, config, false, true)
+	})
+}
+
+// resolveHappyEyeballs resolves the host, and returns its addresses in the order in which they should be dialed.
+func resolveHappyEyeballs(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	type result struct {
+		ips []net.IP
+		err error
+	}
+	lookup := lookupIP
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	v6Chan := make(chan result, 1)
+	v4Chan := make(chan result, 1)
+	go func() {
+		ips, err := lookup(ctx, "ip6", host)
+		v6Chan <- result{ips: ips, err: err}
+	}()
+	go func() {
+		ips, err := lookup(ctx, "ip4", host)
+		v4Chan <- result{ips: ips, err: err}
+	}()
+
+	var v6, v4 result
+	select {
+	case v6 = <-v6Chan:
+		v4 = <-v4Chan
+	case v4 = <-v4Chan:
+		timer := time.NewTimer(resolutionDelay)
+		defer timer.Stop()
+		select {
+		case v6 = <-v6Chan:
+		case <-timer.C:
+			v6.err = fmt.Errorf("quic: AAAA query for %s didn't complete within the resolution delay", host)
+		}
+	}
+	ips := interleaveAddrs(v6.ips, v4.ips)
+	if len(ips) == 0 {
+		if v4.err != nil {
+			return nil, v4.err
+		}
+		if v6.err != nil {
+			return nil, v6.err
+		}
+		return nil, fmt.Errorf("quic: no addresses found for %s", host)
+	}
+	return ips, nil
+}
+
+// interleaveAddrs alternates between IPv6 and IPv4 addresses, starting with IPv6 (RFC 8305, Section 4).
+func interleaveAddrs(v6, v4 []net.IP) []net.IP {
+	ips := make([]net.IP, 0, len(v6)+len(v4))
+	for i := 0; i < len(v6) || i < len(v4); i++ {
+		if i < len(v6) {
+			ips = append(ips, v6[i])
+		}
+		if i < len(v4) {
+			ips = append(ips, v4[i])
+		}
+	}
+	return ips
+}
+
+// raceHappyEyeballs dials the addresses in order, starting a new attempt every delay,
+// or as soon as the previous attempt failed.
+// It returns the first connection that was established, and cancels all other attempts.
+// If all attempts fail, the error of the first attempt is returned.
+func raceHappyEyeballs(ctx context.Context, ips []net.IP, delay time.Duration, dial func(context.Context, net.IP) (quicConn, error)) (quicConn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn quicConn
+		err  error
+	}
+	results := make(chan result, len(ips))
+	var next, running int
+	startAttempt := func() {
+		ip := ips[next]
+		next++
+		running++
+		go func() {
+			conn, err := dial(ctx, ip)
+			results <- result{conn: conn, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var firstErr error
+	startAttempt()
+	for running > 0 {
+		select {
+		case <-timer.C:
+			if next < len(ips) && ctx.Err() == nil {
+				startAttempt()
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			running--
+			if r.err == nil {
+				// Attempts that are still running are canceled.
+				// Close the connections of attempts that completed nevertheless.
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if r := <-results; r.conn != nil {
+							r.conn.CloseWithError(0, "")
+						}
+					}
+				}(running)
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(ips) && ctx.Err() == nil {
+				startAttempt()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(delay)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,211 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Happy Eyeballs", func() {
+	var (
+		v6 = []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")}
+		v4 = []net.IP{net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 2), net.IPv4(192, 0, 2, 3)}
+	)
+
+	Context("resolving", func() {
+		var origLookupIP func(context.Context, string, string) ([]net.IP, error)
+
+		BeforeEach(func() { origLookupIP = lookupIP })
+		AfterEach(func() { lookupIP = origLookupIP })
+
+		It("interleaves the address families, starting with IPv6", func() {
+			Expect(interleaveAddrs(v6, v4)).To(Equal([]net.IP{v6[0], v4[0], v6[1], v4[1], v4[2]}))
+			Expect(interleaveAddrs(nil, v4)).To(Equal(v4))
+			Expect(interleaveAddrs(v6, nil)).To(Equal(v6))
+		})
+
+		It("doesn't resolve IP addresses", func() {
+			lookupIP = func(context.Context, string, string) ([]net.IP, error) {
+				Fail("didn't expect a lookup")
+				return nil, nil
+			}
+			ips, err := resolveHappyEyeballs(context.Background(), "192.0.2.1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(Equal([]net.IP{net.ParseIP("192.0.2.1")}))
+		})
+
+		It("waits for the A query if the AAAA query completes first", func() {
+			unblock := make(chan struct{})
+			lookupIP = func(_ context.Context, network, host string) ([]net.IP, error) {
+				Expect(host).To(Equal("example.org"))
+				if network == "ip6" {
+					return v6, nil
+				}
+				<-unblock
+				return v4, nil
+			}
+			time.AfterFunc(scaleDuration(100*time.Millisecond), func() { close(unblock) })
+			ips, err := resolveHappyEyeballs(context.Background(), "example.org")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(Equal([]net.IP{v6[0], v4[0], v6[1], v4[1], v4[2]}))
+		})
+
+		It("waits for the AAAA query for the resolution delay", func() {
+			lookupIP = func(ctx context.Context, network, _ string) ([]net.IP, error) {
+				if network == "ip4" {
+					return v4, nil
+				}
+				time.Sleep(resolutionDelay / 2)
+				return v6, nil
+			}
+			ips, err := resolveHappyEyeballs(context.Background(), "example.org")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(HaveLen(5))
+		})
+
+		It("only uses the IPv4 addresses if the AAAA query takes too long", func() {
+			lookupIP = func(ctx context.Context, network, _ string) ([]net.IP, error) {
+				if network == "ip4" {
+					return v4, nil
+				}
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			start := time.Now()
+			ips, err := resolveHappyEyeballs(context.Background(), "example.org")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", resolutionDelay))
+			Expect(ips).To(Equal(v4))
+		})
+
+		It("uses the addresses of one family if the other query fails", func() {
+			lookupIP = func(_ context.Context, network, _ string) ([]net.IP, error) {
+				if network == "ip4" {
+					return nil, errors.New("no A records")
+				}
+				return v6, nil
+			}
+			ips, err := resolveHappyEyeballs(context.Background(), "example.org")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(Equal(v6))
+		})
+
+		It("returns the error if both queries fail", func() {
+			lookupIP = func(_ context.Context, network, _ string) ([]net.IP, error) {
+				return nil, errors.New("no " + network + " records")
+			}
+			_, err := resolveHappyEyeballs(context.Background(), "example.org")
+			Expect(err).To(MatchError("no ip4 records"))
+		})
+	})
+
+	Context("racing", func() {
+		type dialAttempt struct {
+			ip     net.IP
+			ctx    context.Context
+			result chan error
+		}
+
+		var (
+			attempts chan *dialAttempt
+			conn     *MockQuicConn
+			dial     func(context.Context, net.IP) (quicConn, error)
+		)
+
+		BeforeEach(func() {
+			attempts = make(chan *dialAttempt, 10)
+			conn = NewMockQuicConn(mockCtrl)
+			attempts := attempts
+			conn := conn
+			// dial blocks until an error (or nil, for success) is sent on the attempt's result channel,
+			// or until the attempt is canceled
+			dial = func(ctx context.Context, ip net.IP) (quicConn, error) {
+				a := &dialAttempt{ip: ip, ctx: ctx, result: make(chan error, 1)}
+				attempts <- a
+				select {
+				case err := <-a.result:
+					if err != nil {
+						return nil, err
+					}
+					return conn, nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		})
+
+		race := func(ctx context.Context, delay time.Duration, dial func(context.Context, net.IP) (quicConn, error)) (<-chan quicConn, <-chan error) {
+			connChan := make(chan quicConn, 1)
+			errChan := make(chan error, 1)
+			go func() {
+				c, err := raceHappyEyeballs(ctx, interleaveAddrs(v6, v4), delay, dial)
+				if err != nil {
+					errChan <- err
+					return
+				}
+				connChan <- c
+			}()
+			return connChan, errChan
+		}
+
+		It("starts a new attempt after the connection attempt delay, and cancels the loser", func() {
+			delay := scaleDuration(25 * time.Millisecond)
+			start := time.Now()
+			connChan, _ := race(context.Background(), delay, dial)
+			var a1, a2 *dialAttempt
+			Eventually(attempts).Should(Receive(&a1))
+			Expect(a1.ip).To(Equal(v6[0]))
+			Eventually(attempts).Should(Receive(&a2))
+			Expect(a2.ip).To(Equal(v4[0]))
+			Expect(time.Since(start)).To(BeNumerically(">=", delay))
+			a2.result <- nil
+			Eventually(connChan).Should(Receive(Equal(conn)))
+			Eventually(a1.ctx.Done()).Should(BeClosed())
+		})
+
+		It("starts the next attempt as soon as an attempt fails", func() {
+			_, errChan := race(context.Background(), time.Hour, dial)
+			for i := 0; i < len(v6)+len(v4); i++ {
+				var a *dialAttempt
+				Eventually(attempts).Should(Receive(&a))
+				a.result <- errors.New("handshake failed")
+			}
+			Eventually(errChan).Should(Receive(MatchError("handshake failed")))
+		})
+
+		It("closes connections that complete after the race was decided", func() {
+			results := make(chan error, 2)
+			// this dial function doesn't return when the attempt is canceled
+			dial := func(context.Context, net.IP) (quicConn, error) {
+				if err := <-results; err != nil {
+					return nil, err
+				}
+				return conn, nil
+			}
+			connChan, _ := race(context.Background(), scaleDuration(10*time.Millisecond), dial)
+			time.Sleep(scaleDuration(15 * time.Millisecond)) // wait for the second attempt to start
+			results <- nil
+			Eventually(connChan).Should(Receive())
+			closed := make(chan struct{})
+			conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(ApplicationErrorCode, string) { close(closed) })
+			results <- nil
+			Eventually(closed).Should(BeClosed())
+		})
+
+		It("returns when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			_, errChan := race(ctx, time.Hour, dial)
+			var a *dialAttempt
+			Eventually(attempts).Should(Receive(&a))
+			cancel()
+			Eventually(errChan).Should(Receive(Equal(context.Canceled)))
+			Expect(attempts).To(BeEmpty())
+		})
+	})
+})
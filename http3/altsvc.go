@@ -0,0 +1,360 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+const (
+	// defaultAltSvcMaxAge is the freshness lifetime of an alternative service without a ma parameter (RFC 7838, Section 3.1).
+	defaultAltSvcMaxAge = 24 * time.Hour
+	// brokenAltSvcTimeout is the time during which HTTP/3 isn't used for an origin after dialing the alternative service failed.
+	brokenAltSvcTimeout = 5 * time.Minute
+)
+
+// An altSvc is an alternative service, as advertised in the Alt-Svc header field (RFC 7838).
+type altSvc struct {
+	protocol string // the ALPN protocol ID
+	host     string // empty if the alternative service is on the same host as the origin
+	port     string
+	expires  time.Time
+	persist  bool
+}
+
+// parseAltSvc parses the values of the Alt-Svc header fields.
+// Malformed alternatives are ignored.
+// It returns true if the alternative services for the origin are cleared.
+func parseAltSvc(values []string, now time.Time) ([]altSvc, bool) {
+	var alts []altSvc
+	for _, value := range values {
+		if strings.TrimSpace(value) == "clear" {
+			return nil, true
+		}
+		for _, elem := range splitQuoted(value, ',') {
+			if alt, ok := parseAlternative(elem, now); ok {
+				alts = append(alts, alt)
+			}
+		}
+	}
+	return alts, false
+}
+
+// parseAlternative parses a single alternative, e.g. h3=":443"; ma=3600; persist=1
+func parseAlternative(s string, now time.Time) (altSvc, bool) {
+	params := splitQuoted(s, ';')
+	protocol, authority, ok := parseAltSvcParam(params[0])
+	if !ok {
+		return altSvc{}, false
+	}
+	protocol, err := url.PathUnescape(protocol)
+	if err != nil || protocol == "" {
+		return altSvc{}, false
+	}
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return altSvc{}, false
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return altSvc{}, false
+	}
+	alt := altSvc{
+		protocol: protocol,
+		host:     host,
+		port:     port,
+		expires:  now.Add(defaultAltSvcMaxAge),
+	}
+	for _, param := range params[1:] {
+		name, value, ok := parseAltSvcParam(param)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "ma":
+			if ma, err := strconv.ParseUint(value, 10, 32); err == nil {
+				alt.expires = now.Add(time.Duration(ma) * time.Second)
+			}
+		case "persist":
+			alt.persist = value == "1"
+		}
+	}
+	return alt, true
+}
+
+// parseAltSvcParam parses name=value, where value is either a token or a quoted string.
+func parseAltSvcParam(s string) (name, value string, ok bool) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return "", "", false
+	}
+	name = strings.TrimSpace(s[:i])
+	value = strings.TrimSpace(s[i+1:])
+	if strings.HasPrefix(value, `"`) {
+		if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+			return "", "", false
+		}
+		value = unescapeQuoted(value[1 : len(value)-1])
+	}
+	return name, value, true
+}
+
+// splitQuoted splits s at every occurrence of sep that is not part of a quoted string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeQuoted(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// altSvcCache caches the alternative services advertised by origins.
+// Origins are identified by their authority (host:port).
+type altSvcCache struct {
+	mutex  sync.Mutex
+	alts   map[string][]altSvc
+	broken map[string]time.Time // the time until which HTTP/3 isn't used for an origin
+}
+
+// update processes the Alt-Svc header field values received from origin.
+// A new Alt-Svc header field replaces all alternative services cached for the origin.
+func (c *altSvcCache) update(origin string, values []string, now time.Time) {
+	if len(values) == 0 {
+		return
+	}
+	alts, clear := parseAltSvc(values, now)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if clear || len(alts) == 0 {
+		delete(c.alts, origin)
+		return
+	}
+	if c.alts == nil {
+		c.alts = make(map[string][]altSvc)
+	}
+	c.alts[origin] = alts
+}
+
+// get returns the first fresh alternative service for origin that uses the protocol.
+func (c *altSvcCache) get(origin, protocol string, now time.Time) (altSvc, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if until, ok := c.broken[origin]; ok {
+		if now.Before(until) {
+			return altSvc{}, false
+		}
+		delete(c.broken, origin)
+	}
+	for _, alt := range c.alts[origin] {
+		if alt.protocol == protocol && now.Before(alt.expires) {
+			return alt, true
+		}
+	}
+	return altSvc{}, false
+}
+
+// markBroken stops using alternative services for origin for a while.
+func (c *altSvcCache) markBroken(origin string, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.broken == nil {
+		c.broken = make(map[string]time.Time)
+	}
+	c.broken[origin] = now.Add(brokenAltSvcTimeout)
+}
+
+// clearNonPersistent removes all alternative services that were not advertised with persist=1.
+func (c *altSvcCache) clearNonPersistent() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for origin, alts := range c.alts {
+		persistent := alts[:0]
+		for _, alt := range alts {
+			if alt.persist {
+				persistent = append(persistent, alt)
+			}
+		}
+		if len(persistent) == 0 {
+			delete(c.alts, origin)
+		} else {
+			c.alts[origin] = persistent
+		}
+	}
+	c.broken = nil
+}
+
+// altSvcDialError is returned when dialing an alternative service failed.
+// The request was not sent, and can be sent over TCP instead.
+type altSvcDialError struct {
+	err error
+}
+
+func (e *altSvcDialError) Error() string { return e.err.Error() }
+func (e *altSvcDialError) Unwrap() error { return e.err }
+
+// AltSvcRoundTripper implements the http.RoundTripper interface.
+// It sends requests over TCP (using HTTP/1.1 or HTTP/2), and upgrades to HTTP/3
+// once the server advertised HTTP/3 support in an Alt-Svc header field (RFC 7838).
+// If the HTTP/3 connection can't be established, requests are sent over TCP,
+// and HTTP/3 isn't used for that origin for a while.
+type AltSvcRoundTripper struct {
+	// Fallback is used to send requests over TCP.
+	// If nil, http.DefaultTransport is used.
+	Fallback http.RoundTripper
+
+	// H3 is used to send requests over HTTP/3.
+	// If nil, a RoundTripper with the default configuration is used.
+	// Its Dial function is wrapped on first use, in order to connect to the alternative service.
+	H3 *RoundTripper
+
+	initOnce sync.Once
+	dial     dialFunc
+	protocol string // the ALPN used by H3
+	cache    altSvcCache
+}
+
+var _ http.RoundTripper = &AltSvcRoundTripper{}
+
+func (t *AltSvcRoundTripper) init() {
+	if t.H3 == nil {
+		t.H3 = &RoundTripper{}
+	}
+	t.dial = t.H3.Dial
+	t.H3.Dial = t.dialAltSvc
+	version := defaultQuicConfig.Versions[0]
+	if t.H3.QuicConfig != nil && len(t.H3.QuicConfig.Versions) > 0 {
+		version = t.H3.QuicConfig.Versions[0]
+	}
+	t.protocol = versionToALPN(version)
+}
+
+func (t *AltSvcRoundTripper) fallback() http.RoundTripper {
+	if t.Fallback != nil {
+		return t.Fallback
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip does a round trip.
+func (t *AltSvcRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.initOnce.Do(t.init)
+
+	if req.URL == nil || req.URL.Scheme != "https" {
+		return t.fallback().RoundTrip(req)
+	}
+	origin := authorityAddr("https", hostnameFromRequest(req))
+	if _, ok := t.cache.get(origin, t.protocol, time.Now()); ok {
+		rsp, err := t.H3.RoundTrip(req)
+		if err == nil {
+			t.cache.update(origin, rsp.Header.Values("Alt-Svc"), time.Now())
+			return rsp, nil
+		}
+		var derr *altSvcDialError
+		if !errors.As(err, &derr) {
+			return nil, err
+		}
+	}
+	rsp, err := t.fallback().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// Alt-Svc header fields are only trusted when received over a secure connection.
+	if rsp.TLS != nil {
+		t.cache.update(origin, rsp.Header.Values("Alt-Svc"), time.Now())
+	}
+	return rsp, nil
+}
+
+// dialAltSvc dials the alternative service cached for the origin at addr.
+func (t *AltSvcRoundTripper) dialAltSvc(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+	alt, ok := t.cache.get(addr, t.protocol, time.Now())
+	if !ok {
+		return nil, &altSvcDialError{err: errors.New("http3: no alternative service")}
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	// The certificate is validated for the origin, not for the alternative service.
+	if tlsConf.ServerName == "" {
+		tlsConf = tlsConf.Clone()
+		tlsConf.ServerName = host
+	}
+	altHost := alt.host
+	if altHost == "" {
+		altHost = host
+	}
+	dial := t.dial
+	if dial == nil {
+		dial = dialAddr
+	}
+	conn, err := dial(ctx, net.JoinHostPort(altHost, alt.port), tlsConf, conf)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		t.cache.markBroken(addr, time.Now())
+		return nil, &altSvcDialError{err: err}
+	}
+	return conn, nil
+}
+
+// NetworkChanged should be called when the network configuration changed.
+// It removes all alternative services that were not advertised with persist=1.
+func (t *AltSvcRoundTripper) NetworkChanged() {
+	t.cache.clearNonPersistent()
+}
+
+// CloseIdleConnections closes the idle connections of both the HTTP/3 and the fallback RoundTripper.
+func (t *AltSvcRoundTripper) CloseIdleConnections() {
+	t.initOnce.Do(t.init)
+	t.H3.CloseIdleConnections()
+	if c, ok := t.fallback().(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// Close closes the QUIC connections used by the HTTP/3 RoundTripper.
+func (t *AltSvcRoundTripper) Close() error {
+	t.initOnce.Do(t.init)
+	return t.H3.Close()
+}
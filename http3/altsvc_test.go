@@ -0,0 +1,285 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+var _ = Describe("Alt-Svc", func() {
+	Context("parsing", func() {
+		now := time.Now()
+
+		It("parses a single alternative", func() {
+			alts, clear := parseAltSvc([]string{`h3=":443"`}, now)
+			Expect(clear).To(BeFalse())
+			Expect(alts).To(Equal([]altSvc{{
+				protocol: "h3",
+				port:     "443",
+				expires:  now.Add(defaultAltSvcMaxAge),
+			}}))
+		})
+
+		It("parses the parameters", func() {
+			alts, _ := parseAltSvc([]string{`h3="alt.example.org:8443"; ma=3600; persist=1`}, now)
+			Expect(alts).To(Equal([]altSvc{{
+				protocol: "h3",
+				host:     "alt.example.org",
+				port:     "8443",
+				expires:  now.Add(time.Hour),
+				persist:  true,
+			}}))
+		})
+
+		It("parses multiple alternatives in multiple header fields", func() {
+			alts, _ := parseAltSvc([]string{`h3=":443"; ma=60,h3-29=":443"`, `h2="[::1]:8443"`}, now)
+			Expect(alts).To(HaveLen(3))
+			Expect(alts[0].protocol).To(Equal("h3"))
+			Expect(alts[1].protocol).To(Equal("h3-29"))
+			Expect(alts[2].protocol).To(Equal("h2"))
+			Expect(alts[2].host).To(Equal("::1"))
+		})
+
+		It("handles separators in quoted strings", func() {
+			alts, _ := parseAltSvc([]string{`h3="a\"b,c;d:443"; foo="x,y;z"; ma=10`}, now)
+			Expect(alts).To(HaveLen(1))
+			Expect(alts[0].host).To(Equal(`a"b,c;d`))
+			Expect(alts[0].expires).To(Equal(now.Add(10 * time.Second)))
+		})
+
+		It("unescapes the protocol ID", func() {
+			alts, _ := parseAltSvc([]string{`w%3Dx%3Ay=":443"`}, now)
+			Expect(alts).To(HaveLen(1))
+			Expect(alts[0].protocol).To(Equal("w=x:y"))
+		})
+
+		It("ignores malformed alternatives", func() {
+			alts, _ := parseAltSvc([]string{`h3, h3=443, h3=":foo", h3=":0", h3="foo", h3=":443`, `h3=":443"; ma=foo`}, now)
+			Expect(alts).To(Equal([]altSvc{{
+				protocol: "h3",
+				port:     "443",
+				expires:  now.Add(defaultAltSvcMaxAge),
+			}}))
+		})
+
+		It("parses clear", func() {
+			alts, clear := parseAltSvc([]string{"clear"}, now)
+			Expect(clear).To(BeTrue())
+			Expect(alts).To(BeEmpty())
+		})
+	})
+
+	Context("cache", func() {
+		var cache *altSvcCache
+		now := time.Now()
+
+		BeforeEach(func() {
+			cache = &altSvcCache{}
+		})
+
+		It("returns alternatives for the protocol", func() {
+			cache.update("example.org:443", []string{`h3-29=":1234",h3=":443"`}, now)
+			alt, ok := cache.get("example.org:443", "h3", now)
+			Expect(ok).To(BeTrue())
+			Expect(alt.port).To(Equal("443"))
+			alt, ok = cache.get("example.org:443", "h3-29", now)
+			Expect(ok).To(BeTrue())
+			Expect(alt.port).To(Equal("1234"))
+			_, ok = cache.get("example.org:443", "h2", now)
+			Expect(ok).To(BeFalse())
+			_, ok = cache.get("example.com:443", "h3", now)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("expires alternatives", func() {
+			cache.update("example.org:443", []string{`h3=":443"; ma=60`}, now)
+			_, ok := cache.get("example.org:443", "h3", now.Add(59*time.Second))
+			Expect(ok).To(BeTrue())
+			_, ok = cache.get("example.org:443", "h3", now.Add(60*time.Second))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("replaces alternatives", func() {
+			cache.update("example.org:443", []string{`h3=":443"`}, now)
+			cache.update("example.org:443", []string{`h3=":1234"`}, now)
+			alt, ok := cache.get("example.org:443", "h3", now)
+			Expect(ok).To(BeTrue())
+			Expect(alt.port).To(Equal("1234"))
+		})
+
+		It("keeps alternatives if the response doesn't contain an Alt-Svc header field", func() {
+			cache.update("example.org:443", []string{`h3=":443"`}, now)
+			cache.update("example.org:443", nil, now)
+			_, ok := cache.get("example.org:443", "h3", now)
+			Expect(ok).To(BeTrue())
+		})
+
+		It("clears alternatives", func() {
+			cache.update("example.org:443", []string{`h3=":443"`}, now)
+			cache.update("example.org:443", []string{"clear"}, now)
+			_, ok := cache.get("example.org:443", "h3", now)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("doesn't return alternatives for a broken origin", func() {
+			cache.update("example.org:443", []string{`h3=":443"`}, now)
+			cache.markBroken("example.org:443", now)
+			_, ok := cache.get("example.org:443", "h3", now.Add(brokenAltSvcTimeout-time.Second))
+			Expect(ok).To(BeFalse())
+			_, ok = cache.get("example.org:443", "h3", now.Add(brokenAltSvcTimeout))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("only keeps persistent alternatives when the network changes", func() {
+			cache.update("example.org:443", []string{`h3=":443"; persist=1, h3-29=":443"`}, now)
+			cache.update("example.com:443", []string{`h3=":443"`}, now)
+			cache.clearNonPersistent()
+			_, ok := cache.get("example.org:443", "h3", now)
+			Expect(ok).To(BeTrue())
+			_, ok = cache.get("example.org:443", "h3-29", now)
+			Expect(ok).To(BeFalse())
+			_, ok = cache.get("example.com:443", "h3", now)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("RoundTripper", func() {
+		var (
+			rt           *AltSvcRoundTripper
+			fallbackReqs []*http.Request
+			altSvcHeader []string
+			insecure     bool
+			dialErr      error
+			dialedAddrs  []string
+			serverNames  []string
+		)
+
+		BeforeEach(func() {
+			fallbackReqs = nil
+			altSvcHeader = nil
+			insecure = false
+			dialErr = errors.New("dial failed")
+			dialedAddrs = nil
+			serverNames = nil
+			rt = &AltSvcRoundTripper{
+				Fallback: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					fallbackReqs = append(fallbackReqs, req)
+					rsp := &http.Response{Request: req, Header: http.Header{}}
+					if !insecure {
+						rsp.TLS = &tls.ConnectionState{}
+					}
+					for _, v := range altSvcHeader {
+						rsp.Header.Add("Alt-Svc", v)
+					}
+					return rsp, nil
+				}),
+				H3: &RoundTripper{
+					QuicConfig: &quic.Config{Versions: []quic.VersionNumber{quic.Version1}},
+					Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, _ *quic.Config) (quic.EarlyConnection, error) {
+						dialedAddrs = append(dialedAddrs, addr)
+						serverNames = append(serverNames, tlsCfg.ServerName)
+						return nil, dialErr
+					},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(rt.Close()).To(Succeed())
+		})
+
+		newRequest := func(url string) *http.Request {
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			Expect(err).ToNot(HaveOccurred())
+			return req
+		}
+
+		It("uses the fallback if no alternative service is known", func() {
+			_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fallbackReqs).To(HaveLen(2))
+			Expect(dialedAddrs).To(BeEmpty())
+		})
+
+		It("dials the alternative service, and falls back if that fails", func() {
+			altSvcHeader = []string{`h2=":8443", h3="alt.example.org:1234"`}
+			_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialedAddrs).To(BeEmpty())
+			_, err = rt.RoundTrip(newRequest("https://example.org/bar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialedAddrs).To(Equal([]string{"alt.example.org:1234"}))
+			Expect(serverNames).To(Equal([]string{"example.org"}))
+			Expect(fallbackReqs).To(HaveLen(2))
+			Expect(fallbackReqs[1].URL.Path).To(Equal("/bar"))
+			// HTTP/3 is now considered broken for this origin
+			_, err = rt.RoundTrip(newRequest("https://example.org/baz"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialedAddrs).To(HaveLen(1))
+			Expect(fallbackReqs).To(HaveLen(3))
+		})
+
+		It("uses the origin's host if the alternative service doesn't specify one", func() {
+			altSvcHeader = []string{`h3=":1234"`}
+			_, err := rt.RoundTrip(newRequest("https://example.org:8443/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(newRequest("https://example.org:8443/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialedAddrs).To(Equal([]string{"example.org:1234"}))
+		})
+
+		It("only uses alternative services for the QUIC version used", func() {
+			altSvcHeader = []string{`h3-29=":443"`}
+			_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialedAddrs).To(BeEmpty())
+		})
+
+		It("doesn't learn alternative services from insecure responses", func() {
+			altSvcHeader = []string{`h3=":443"`}
+			insecure = true
+			_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialedAddrs).To(BeEmpty())
+		})
+
+		It("doesn't fall back if the request is canceled while dialing", func() {
+			altSvcHeader = []string{`h3=":443"`}
+			_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			dialErr = context.Canceled
+			_, err = rt.RoundTrip(newRequest("https://example.org/foo").WithContext(ctx))
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(fallbackReqs).To(HaveLen(1))
+		})
+
+		It("stops using non-persistent alternative services when the network changes", func() {
+			altSvcHeader = []string{`h3=":443"`}
+			_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			rt.NetworkChanged()
+			_, err = rt.RoundTrip(newRequest("https://example.org/foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialedAddrs).To(BeEmpty())
+		})
+	})
+})
@@ -2,9 +2,11 @@ package http3
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync"
 
 	"github.com/lucas-clemente/quic-go"
 )
@@ -28,15 +30,35 @@ type Hijacker interface {
 
 // The body of a http.Request or http.Response.
 type body struct {
-	str quic.Stream
+	str quic.ReceiveStream
 
 	// only set for the http.Response
 	// The channel is closed when the user is done with this response:
 	// either when Read() errors, or when Close() is called.
 	reqDone       chan<- struct{}
 	reqDoneClosed bool
+	// onRequestDone, if set, is called when reqDone is closed.
+	onRequestDone func()
 
 	onFrameError func()
+	// rejectReservedFrames is set in strict mode.
+	// Frame types reserved for HTTP/2 are then treated as unexpected frames.
+	rejectReservedFrames bool
+	// extensionFrames passes the frames of the types registered using RegisterFrameType to their handler.
+	extensionFrames extensionFrameHandlerFunc
+	// onPushPromise is called for PUSH_PROMISE frames.
+	// It is only set for the http.Response, since only servers can push.
+	onPushPromise func(*pushPromiseFrame) error
+	// onTrailers is called for a HEADERS frame carrying the trailers.
+	// It must read the frame payload from the stream.
+	// If it is nil, the trailers are discarded.
+	onTrailers       func(*headersFrame) error
+	receivedTrailers bool
+
+	// contentLength is the value of the Content-Length header field, or -1 if it wasn't sent.
+	// A body that doesn't match the Content-Length is malformed (RFC 9114, Section 4.1.2).
+	contentLength int64
+	bytesRead     int64
 
 	bytesRemainingInFrame uint64
 }
@@ -45,24 +67,48 @@ var _ io.ReadCloser = &body{}
 
 type hijackableBody struct {
 	body
-	conn quic.Connection // only needed to implement Hijacker
+	conn     quic.Connection // only needed to implement Hijacker
+	settings Settingser      // only needed to implement Settingser
+
+	// onPriorityUpdate sends a PRIORITY_UPDATE frame for the request.
+	// It is only set for responses to requests, not for pushed responses.
+	onPriorityUpdate func(Priority) error
+
+	timing RequestTiming // only set for responses received by the client
+
+	// datagrams is used to send and receive the HTTP datagrams associated with the request stream.
+	// It is nil if HTTP datagrams are disabled, and for pushed responses.
+	datagrams       *datagramDemuxer
+	datagramsMutex  sync.Mutex
+	streamDatagrams *streamDatagrams // created on first use
+	datagramsClosed bool             // set once the request is done
 }
 
-var _ Hijacker = &hijackableBody{}
+var (
+	_ Hijacker           = &hijackableBody{}
+	_ Settingser         = &hijackableBody{}
+	_ priorityUpdater    = &hijackableBody{}
+	_ earlyDataReporter  = &hijackableBody{}
+	_ timingReporter     = &hijackableBody{}
+	_ connectionReporter = &hijackableBody{}
+	_ datagramsReporter  = &hijackableBody{}
+)
 
 func newRequestBody(str quic.Stream, onFrameError func()) *body {
 	return &body{
-		str:          str,
-		onFrameError: onFrameError,
+		str:           str,
+		onFrameError:  onFrameError,
+		contentLength: -1,
 	}
 }
 
-func newResponseBody(str quic.Stream, conn quic.Connection, done chan<- struct{}, onFrameError func()) *hijackableBody {
+func newResponseBody(str quic.ReceiveStream, conn quic.Connection, done chan<- struct{}, onFrameError func()) *hijackableBody {
 	return &hijackableBody{
 		body: body{
-			str:          str,
-			onFrameError: onFrameError,
-			reqDone:      done,
+			str:           str,
+			onFrameError:  onFrameError,
+			reqDone:       done,
+			contentLength: -1,
 		},
 		conn: conn,
 	}
@@ -72,8 +118,65 @@ func (r *hijackableBody) StreamCreator() StreamCreator {
 	return r.conn
 }
 
+func (r *hijackableBody) ReceivedSettings() <-chan struct{} {
+	return r.settings.ReceivedSettings()
+}
+
+func (r *hijackableBody) Settings() *Settings {
+	return r.settings.Settings()
+}
+
+func (r *hijackableBody) updatePriority(p Priority) error {
+	if r.onPriorityUpdate == nil {
+		return errors.New("http3: priority updates not supported for this response")
+	}
+	return r.onPriorityUpdate(p)
+}
+
+func (r *hijackableBody) earlyDataInfo() EarlyDataInfo {
+	return r.timing.EarlyData
+}
+
+func (r *hijackableBody) timingInfo() RequestTiming {
+	return r.timing
+}
+
+func (r *hijackableBody) connectionInfo() (quic.Connection, quic.StreamID) {
+	return r.conn, r.str.StreamID()
+}
+
+func (r *hijackableBody) getDatagrams() (Datagrammer, error) {
+	if r.datagrams == nil {
+		return nil, errors.New("http3: HTTP datagrams not enabled")
+	}
+	r.datagramsMutex.Lock()
+	defer r.datagramsMutex.Unlock()
+
+	if r.datagramsClosed {
+		return nil, ErrDatagramStreamClosed
+	}
+	if r.streamDatagrams == nil {
+		r.streamDatagrams = newStreamDatagrams(r.str.StreamID(), r.datagrams)
+	}
+	return r.streamDatagrams, nil
+}
+
+// closeDatagrams is called when the request is done.
+func (r *hijackableBody) closeDatagrams() {
+	r.datagramsMutex.Lock()
+	defer r.datagramsMutex.Unlock()
+
+	r.datagramsClosed = true
+	if r.streamDatagrams != nil {
+		r.streamDatagrams.close()
+	}
+}
+
 func (r *body) Read(b []byte) (int, error) {
 	n, err := r.readImpl(b)
+	if err == io.EOF && r.contentLength >= 0 && r.bytesRead != r.contentLength {
+		err = r.contentLengthMismatch()
+	}
 	if err != nil {
 		r.requestDone()
 	}
@@ -84,17 +187,49 @@ func (r *body) readImpl(b []byte) (int, error) {
 	if r.bytesRemainingInFrame == 0 {
 	parseLoop:
 		for {
-			frame, err := parseNextFrame(r.str, nil)
+			frame, err := parseFrameWithExtensions(r.str, nil, r.extensionFrames, r.rejectReservedFrames)
 			if err != nil {
+				if err == errReservedFrameType {
+					r.onFrameError()
+				}
 				return 0, err
 			}
 			switch f := frame.(type) {
 			case *headersFrame:
-				// skip HEADERS frames
-				continue
+				// A HEADERS frame following the header section carries the trailers.
+				// It must be the last frame on the stream (RFC 9114, Section 4.1).
+				if r.receivedTrailers {
+					r.onFrameError()
+					return 0, errors.New("peer sent a HEADERS frame after the trailers")
+				}
+				r.receivedTrailers = true
+				if r.onTrailers == nil {
+					if _, err := io.CopyN(io.Discard, r.str, int64(f.Length)); err != nil {
+						return 0, err
+					}
+					continue
+				}
+				if err := r.onTrailers(f); err != nil {
+					return 0, err
+				}
 			case *dataFrame:
+				if r.receivedTrailers {
+					r.onFrameError()
+					return 0, errors.New("peer sent a DATA frame after the trailers")
+				}
+				if r.contentLength >= 0 && f.Length > uint64(r.contentLength-r.bytesRead) {
+					return 0, r.contentLengthMismatch()
+				}
 				r.bytesRemainingInFrame = f.Length
 				break parseLoop
+			case *pushPromiseFrame:
+				if r.onPushPromise == nil {
+					r.onFrameError()
+					return 0, fmt.Errorf("peer sent an unexpected frame: %T", f)
+				}
+				if err := r.onPushPromise(f); err != nil {
+					return 0, err
+				}
 			default:
 				r.onFrameError()
 				// parseNextFrame skips over unknown frame types
@@ -112,15 +247,26 @@ func (r *body) readImpl(b []byte) (int, error) {
 		n, err = r.str.Read(b)
 	}
 	r.bytesRemainingInFrame -= uint64(n)
+	r.bytesRead += int64(n)
 	return n, err
 }
 
+// contentLengthMismatch is called when the body doesn't match the Content-Length.
+// The stream is then reset with H3_MESSAGE_ERROR.
+func (r *body) contentLengthMismatch() error {
+	r.str.CancelRead(quic.StreamErrorCode(errorMessageError))
+	return fmt.Errorf("http3: body doesn't match the Content-Length of %d bytes", r.contentLength)
+}
+
 func (r *body) requestDone() {
 	if r.reqDoneClosed || r.reqDone == nil {
 		return
 	}
 	close(r.reqDone)
 	r.reqDoneClosed = true
+	if r.onRequestDone != nil {
+		r.onRequestDone()
+	}
 }
 
 func (r *body) StreamID() quic.StreamID {
@@ -2,8 +2,10 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go"
@@ -30,6 +32,7 @@ func (t bodyType) String() string {
 var _ = Describe("Body", func() {
 	var (
 		rb            io.ReadCloser
+		b             *body // the body underlying rb
 		str           *mockquic.MockStream
 		buf           *bytes.Buffer
 		reqDone       chan struct{}
@@ -65,10 +68,13 @@ var _ = Describe("Body", func() {
 
 				switch bodyType {
 				case bodyTypeRequest:
-					rb = newRequestBody(str, errorCb)
+					b = newRequestBody(str, errorCb)
+					rb = b
 				case bodyTypeResponse:
 					reqDone = make(chan struct{})
-					rb = newResponseBody(str, nil, reqDone, errorCb)
+					hb := newResponseBody(str, nil, reqDone, errorCb)
+					b = &hb.body
+					rb = hb
 				}
 			})
 
@@ -130,16 +136,57 @@ var _ = Describe("Body", func() {
 				Expect(b[:n]).To(Equal([]byte("bar")))
 			})
 
-			It("skips HEADERS frames", func() {
+			It("skips the trailers", func() {
+				buf.Write(getDataFrame([]byte("foobar")))
+				(&headersFrame{Length: 10}).Write(buf)
+				buf.Write(make([]byte, 10))
+				data, err := ioutil.ReadAll(rb)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+			})
+
+			It("passes the trailers to the callback", func() {
+				buf.Write(getDataFrame([]byte("foobar")))
+				(&headersFrame{Length: 6}).Write(buf)
+				buf.WriteString("foobaz")
+				var trailers []byte
+				b.onTrailers = func(f *headersFrame) error {
+					trailers = make([]byte, f.Length)
+					_, err := io.ReadFull(str, trailers)
+					return err
+				}
+				data, err := ioutil.ReadAll(rb)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+				Expect(trailers).To(Equal([]byte("foobaz")))
+			})
+
+			It("returns the error returned by the trailers callback", func() {
+				(&headersFrame{Length: 6}).Write(buf)
+				buf.WriteString("foobaz")
+				b.onTrailers = func(*headersFrame) error { return errors.New("malformed trailers") }
+				_, err := rb.Read([]byte{0})
+				Expect(err).To(MatchError("malformed trailers"))
+			})
+
+			It("errors on DATA frames after the trailers", func() {
 				buf.Write(getDataFrame([]byte("foo")))
 				(&headersFrame{Length: 10}).Write(buf)
 				buf.Write(make([]byte, 10))
 				buf.Write(getDataFrame([]byte("bar")))
-				b := make([]byte, 6)
-				n, err := io.ReadFull(rb, b)
-				Expect(err).ToNot(HaveOccurred())
-				Expect(n).To(Equal(6))
-				Expect(b).To(Equal([]byte("foobar")))
+				_, err := ioutil.ReadAll(rb)
+				Expect(err).To(MatchError("peer sent a DATA frame after the trailers"))
+				Expect(errorCbCalled).To(BeTrue())
+			})
+
+			It("errors on HEADERS frames after the trailers", func() {
+				(&headersFrame{Length: 10}).Write(buf)
+				buf.Write(make([]byte, 10))
+				(&headersFrame{Length: 10}).Write(buf)
+				buf.Write(make([]byte, 10))
+				_, err := ioutil.ReadAll(rb)
+				Expect(err).To(MatchError("peer sent a HEADERS frame after the trailers"))
+				Expect(errorCbCalled).To(BeTrue())
 			})
 
 			It("errors when it can't parse the frame", func() {
@@ -155,6 +202,43 @@ var _ = Describe("Body", func() {
 				Expect(errorCbCalled).To(BeTrue())
 			})
 
+			Context("checking the Content-Length", func() {
+				It("reads a body matching the Content-Length", func() {
+					b.contentLength = 6
+					buf.Write(getDataFrame([]byte("foo")))
+					buf.Write(getDataFrame([]byte("bar")))
+					data, err := ioutil.ReadAll(rb)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(data).To(Equal([]byte("foobar")))
+				})
+
+				It("errors if the body is longer than the Content-Length", func() {
+					b.contentLength = 4
+					buf.Write(getDataFrame([]byte("foo")))
+					buf.Write(getDataFrame([]byte("bar")))
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+					data, err := ioutil.ReadAll(rb)
+					Expect(err).To(MatchError("http3: body doesn't match the Content-Length of 4 bytes"))
+					Expect(data).To(Equal([]byte("foo")))
+				})
+
+				It("errors if the body is shorter than the Content-Length", func() {
+					b.contentLength = 10
+					buf.Write(getDataFrame([]byte("foobar")))
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+					data, err := ioutil.ReadAll(rb)
+					Expect(err).To(MatchError("http3: body doesn't match the Content-Length of 10 bytes"))
+					Expect(data).To(Equal([]byte("foobar")))
+				})
+
+				It("errors if the body is empty, but the Content-Length isn't 0", func() {
+					b.contentLength = 3
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+					_, err := rb.Read([]byte{0})
+					Expect(err).To(MatchError("http3: body doesn't match the Content-Length of 3 bytes"))
+				})
+			})
+
 			if bodyType == bodyTypeResponse {
 				It("closes the reqDone channel when Read errors", func() {
 					buf.Write([]byte("invalid"))
@@ -0,0 +1,111 @@
+package http3
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// CapsuleType is the type of a capsule, as defined in RFC 9297, Section 3.2.
+type CapsuleType uint64
+
+// CapsuleTypeDatagram is the type of the DATAGRAM capsule (RFC 9297, Section 3.5).
+const CapsuleTypeDatagram CapsuleType = 0x00
+
+// ParseCapsule parses the header of the next capsule.
+// The capsule value needs to be read from the returned io.Reader, before the next capsule can be parsed.
+func ParseCapsule(r quicvarint.Reader) (CapsuleType, io.Reader, error) {
+	t, err := quicvarint.Read(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	l, err := quicvarint.Read(r)
+	if err != nil {
+		if err == io.EOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return CapsuleType(t), &exactReader{R: &io.LimitedReader{R: r, N: int64(l)}}, nil
+}
+
+// WriteCapsule writes a capsule.
+// The capsule is written using a single call to Write.
+func WriteCapsule(w io.Writer, t CapsuleType, value []byte) error {
+	buf := &bytes.Buffer{}
+	quicvarint.Write(buf, uint64(t))
+	quicvarint.Write(buf, uint64(len(value)))
+	buf.Write(value)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// A CapsuleHandler handles the value of a capsule.
+// Bytes of the value not read by the handler are discarded.
+type CapsuleHandler func(value io.Reader) error
+
+// A CapsuleReader reads capsules from a stream, usually a request stream obtained from DataStream,
+// and passes them to the handlers registered for their types.
+type CapsuleReader struct {
+	r quicvarint.Reader
+
+	mutex    sync.Mutex
+	handlers map[CapsuleType]CapsuleHandler
+}
+
+// NewCapsuleReader creates a new CapsuleReader.
+func NewCapsuleReader(r io.Reader) *CapsuleReader {
+	return &CapsuleReader{
+		r:        quicvarint.NewReader(r),
+		handlers: make(map[CapsuleType]CapsuleHandler),
+	}
+}
+
+// Handle registers the handler for a capsule type.
+// Registering a nil handler removes the handler.
+func (r *CapsuleReader) Handle(t CapsuleType, h CapsuleHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if h == nil {
+		delete(r.handlers, t)
+		return
+	}
+	r.handlers[t] = h
+}
+
+// ReadCapsule reads the next capsule, and passes it to the handler registered for its type.
+// Capsules of types without a handler are skipped, as required by RFC 9297, Section 3.2.
+// If the handler returns an error, ReadCapsule returns that error.
+func (r *CapsuleReader) ReadCapsule() (CapsuleType, error) {
+	t, cr, err := ParseCapsule(r.r)
+	if err != nil {
+		return 0, err
+	}
+	r.mutex.Lock()
+	h, ok := r.handlers[t]
+	r.mutex.Unlock()
+	if ok {
+		if err := h(cr); err != nil {
+			return t, err
+		}
+	}
+	_, err = io.Copy(ioutil.Discard, cr)
+	return t, err
+}
+
+// exactReader returns io.ErrUnexpectedEOF if the underlying io.LimitedReader
+// ends before the limit is reached.
+type exactReader struct {
+	R *io.LimitedReader
+}
+
+func (r *exactReader) Read(b []byte) (int, error) {
+	n, err := r.R.Read(b)
+	if err == io.EOF && r.R.N > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
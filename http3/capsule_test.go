@@ -0,0 +1,117 @@
+package http3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capsules", func() {
+	It("writes and parses capsules", func() {
+		buf := &bytes.Buffer{}
+		Expect(WriteCapsule(buf, 0x1337, []byte("foobar"))).To(Succeed())
+		Expect(WriteCapsule(buf, CapsuleTypeDatagram, []byte("raboof"))).To(Succeed())
+		r := quicvarint.NewReader(buf)
+		t, cr, err := ParseCapsule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t).To(Equal(CapsuleType(0x1337)))
+		value, err := ioutil.ReadAll(cr)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal([]byte("foobar")))
+		t, cr, err = ParseCapsule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t).To(Equal(CapsuleTypeDatagram))
+		value, err = ioutil.ReadAll(cr)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal([]byte("raboof")))
+		_, _, err = ParseCapsule(r)
+		Expect(err).To(MatchError(io.EOF))
+	})
+
+	It("errors on a truncated capsule header", func() {
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, 0x1337)
+		_, _, err := ParseCapsule(quicvarint.NewReader(buf))
+		Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+	})
+
+	It("errors on a truncated capsule value", func() {
+		buf := &bytes.Buffer{}
+		Expect(WriteCapsule(buf, 0x1337, []byte("foobar"))).To(Succeed())
+		data := buf.Bytes()
+		_, cr, err := ParseCapsule(quicvarint.NewReader(bytes.NewReader(data[:len(data)-1])))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ioutil.ReadAll(cr)
+		Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+	})
+
+	Context("reader", func() {
+		It("passes capsules to the registered handlers", func() {
+			buf := &bytes.Buffer{}
+			Expect(WriteCapsule(buf, 0x1337, []byte("foobar"))).To(Succeed())
+			Expect(WriteCapsule(buf, 0x42, []byte("raboof"))).To(Succeed())
+			r := NewCapsuleReader(buf)
+			var values []string
+			r.Handle(0x1337, func(v io.Reader) error {
+				b, err := ioutil.ReadAll(v)
+				values = append(values, string(b))
+				return err
+			})
+			t, err := r.ReadCapsule()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t).To(Equal(CapsuleType(0x1337)))
+			// capsules of unknown types are skipped
+			t, err = r.ReadCapsule()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t).To(Equal(CapsuleType(0x42)))
+			Expect(values).To(Equal([]string{"foobar"}))
+			_, err = r.ReadCapsule()
+			Expect(err).To(MatchError(io.EOF))
+		})
+
+		It("discards the part of the value not read by the handler", func() {
+			buf := &bytes.Buffer{}
+			Expect(WriteCapsule(buf, 0x1337, []byte("foobar"))).To(Succeed())
+			Expect(WriteCapsule(buf, 0x1337, []byte("raboof"))).To(Succeed())
+			r := NewCapsuleReader(buf)
+			var values []string
+			r.Handle(0x1337, func(v io.Reader) error {
+				b := make([]byte, 3)
+				_, err := io.ReadFull(v, b)
+				values = append(values, string(b))
+				return err
+			})
+			_, err := r.ReadCapsule()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = r.ReadCapsule()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(values).To(Equal([]string{"foo", "rab"}))
+		})
+
+		It("returns the error returned by the handler", func() {
+			buf := &bytes.Buffer{}
+			Expect(WriteCapsule(buf, 0x1337, []byte("foobar"))).To(Succeed())
+			r := NewCapsuleReader(buf)
+			r.Handle(0x1337, func(io.Reader) error { return errors.New("malformed") })
+			t, err := r.ReadCapsule()
+			Expect(err).To(MatchError("malformed"))
+			Expect(t).To(Equal(CapsuleType(0x1337)))
+		})
+
+		It("removes handlers", func() {
+			buf := &bytes.Buffer{}
+			Expect(WriteCapsule(buf, 0x1337, []byte("foobar"))).To(Succeed())
+			r := NewCapsuleReader(buf)
+			r.Handle(0x1337, func(io.Reader) error { return errors.New("malformed") })
+			r.Handle(0x1337, nil)
+			_, err := r.ReadCapsule()
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
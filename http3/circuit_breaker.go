@@ -0,0 +1,163 @@
+package http3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+const (
+	// defaultFailureThreshold is the default value of CircuitBreaker.FailureThreshold.
+	defaultFailureThreshold = 5
+	// defaultBreakDuration is the default value of CircuitBreaker.BreakDuration.
+	defaultBreakDuration = 30 * time.Second
+)
+
+// A CircuitBreaker tracks the health of the origins that a RoundTripper sends requests to,
+// and temporarily stops dialing origins that can't be reached, e.g. because a middlebox drops UDP packets.
+//
+// An origin is considered broken after FailureThreshold consecutive requests failed because
+// the QUIC connection couldn't be established (e.g. because the handshake timed out),
+// or because the connection timed out.
+// Requests that fail for other reasons (e.g. because the request context was canceled) are not counted.
+// While an origin is broken, requests fail with an *OriginUnavailableError, or are sent using the Fallback.
+// After BreakDuration, a single probe request is sent over HTTP/3.
+// If it succeeds, the origin is considered healthy again, otherwise it is considered broken for another BreakDuration.
+//
+// Requests sent through a proxy are not tracked.
+// A CircuitBreaker must not be shared between RoundTrippers.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures after which an origin is considered broken.
+	// If zero, a default of 5 is used.
+	FailureThreshold int
+	// BreakDuration is the time during which no requests are sent to a broken origin over HTTP/3.
+	// If zero, a default of 30 seconds is used.
+	BreakDuration time.Duration
+	// Fallback, if set, is used to send the requests to broken origins, e.g. an http.Transport using TCP.
+	Fallback http.RoundTripper
+	// OnStateChange, if set, is called when an origin is considered broken, and when it recovers.
+	// The origin is the host:port of the requests.
+	OnStateChange func(origin string, broken bool)
+
+	mutex   sync.Mutex
+	origins map[string]*originHealth
+}
+
+type originHealth struct {
+	failures    int
+	lastErr     error
+	brokenUntil time.Time // zero if the origin is not broken
+	probing     bool      // set while a probe request is in flight
+}
+
+// An OriginUnavailableError is returned for requests to an origin that the CircuitBreaker considers broken.
+type OriginUnavailableError struct {
+	// Origin is the host:port of the request.
+	Origin string
+	// RetryAfter is the time after which the next request is sent to the origin.
+	RetryAfter time.Time
+	// Err is the error that made the origin be considered broken.
+	Err error
+}
+
+func (e *OriginUnavailableError) Error() string {
+	return fmt.Sprintf("http3: %s temporarily unavailable: %s", e.Origin, e.Err)
+}
+
+func (e *OriginUnavailableError) Unwrap() error { return e.Err }
+
+func (b *CircuitBreaker) failureThreshold() int {
+	if b.FailureThreshold > 0 {
+		return b.FailureThreshold
+	}
+	return defaultFailureThreshold
+}
+
+func (b *CircuitBreaker) breakDuration() time.Duration {
+	if b.BreakDuration > 0 {
+		return b.BreakDuration
+	}
+	return defaultBreakDuration
+}
+
+// allow is called before a request is sent to origin.
+// It returns an *OriginUnavailableError if the origin is broken.
+// Once the BreakDuration has passed, it allows a single request, which is used as a probe.
+func (b *CircuitBreaker) allow(origin string, now time.Time) (probe bool, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	h, ok := b.origins[origin]
+	if !ok || h.brokenUntil.IsZero() {
+		return false, nil
+	}
+	if now.Before(h.brokenUntil) || h.probing {
+		return false, &OriginUnavailableError{Origin: origin, RetryAfter: h.brokenUntil, Err: h.lastErr}
+	}
+	h.probing = true
+	return true, nil
+}
+
+// report is called with the result of a request that was allowed by allow.
+func (b *CircuitBreaker) report(origin string, probe bool, err error, now time.Time) {
+	b.mutex.Lock()
+	h, ok := b.origins[origin]
+	if !ok {
+		h = &originHealth{}
+	}
+	if probe {
+		h.probing = false
+	}
+	var changed, broken bool
+	switch {
+	case err != nil && isOriginFailure(err):
+		h.failures++
+		h.lastErr = err
+		if probe || h.failures >= b.failureThreshold() {
+			changed = h.brokenUntil.IsZero()
+			broken = true
+			h.brokenUntil = now.Add(b.breakDuration())
+		}
+		if !ok {
+			if b.origins == nil {
+				b.origins = make(map[string]*originHealth)
+			}
+			b.origins[origin] = h
+		}
+	case err != nil && isContextError(err):
+		// The request was canceled. This doesn't say anything about the health of the origin.
+	default:
+		// The origin was reached.
+		changed = ok && !h.brokenUntil.IsZero()
+		delete(b.origins, origin)
+	}
+	b.mutex.Unlock()
+
+	if changed && b.OnStateChange != nil {
+		b.OnStateChange(origin, broken)
+	}
+}
+
+// isOriginFailure says if err indicates that the origin can't be reached over QUIC.
+func isOriginFailure(err error) bool {
+	var handshakeTimeoutErr *quic.HandshakeTimeoutError
+	var idleTimeoutErr *quic.IdleTimeoutError
+	var dialErr *DialError
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &handshakeTimeoutErr) ||
+		errors.As(err, &idleTimeoutErr) ||
+		errors.As(err, &dialErr) ||
+		errors.As(err, &opErr) ||
+		errors.As(err, &dnsErr)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,131 @@
+package http3
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Circuit Breaker", func() {
+	const origin = "quic.clemente.io:443"
+	failure := &quic.HandshakeTimeoutError{}
+
+	It("classifies errors", func() {
+		Expect(isOriginFailure(&quic.HandshakeTimeoutError{})).To(BeTrue())
+		Expect(isOriginFailure(&quic.IdleTimeoutError{})).To(BeTrue())
+		Expect(isOriginFailure(&DialError{Host: "quic.clemente.io"})).To(BeTrue())
+		Expect(isOriginFailure(&net.OpError{Op: "read", Err: errors.New("connection refused")})).To(BeTrue())
+		Expect(isOriginFailure(&net.DNSError{Name: "quic.clemente.io", IsNotFound: true})).To(BeTrue())
+		Expect(isOriginFailure(&quic.ApplicationError{Remote: true})).To(BeFalse())
+		Expect(isOriginFailure(errors.New("test error"))).To(BeFalse())
+	})
+
+	It("allows requests to unknown origins", func() {
+		b := &CircuitBreaker{}
+		probe, err := b.allow(origin, time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(probe).To(BeFalse())
+	})
+
+	It("considers an origin broken after consecutive failures", func() {
+		var states []bool
+		b := &CircuitBreaker{
+			FailureThreshold: 3,
+			BreakDuration:    time.Minute,
+			OnStateChange:    func(_ string, broken bool) { states = append(states, broken) },
+		}
+		now := time.Now()
+		b.report(origin, false, failure, now)
+		b.report(origin, false, failure, now)
+		_, err := b.allow(origin, now)
+		Expect(err).ToNot(HaveOccurred())
+		b.report(origin, false, failure, now)
+		Expect(states).To(Equal([]bool{true}))
+		_, err = b.allow(origin, now.Add(time.Minute-time.Second))
+		Expect(err).To(MatchError(&OriginUnavailableError{Origin: origin, RetryAfter: now.Add(time.Minute), Err: failure}))
+		Expect(err).To(MatchError(ContainSubstring("http3: quic.clemente.io:443 temporarily unavailable")))
+	})
+
+	It("resets the failure count when a request succeeds", func() {
+		b := &CircuitBreaker{FailureThreshold: 2}
+		now := time.Now()
+		b.report(origin, false, failure, now)
+		b.report(origin, false, nil, now)
+		b.report(origin, false, failure, now)
+		_, err := b.allow(origin, now)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("ignores canceled requests", func() {
+		b := &CircuitBreaker{FailureThreshold: 2}
+		now := time.Now()
+		b.report(origin, false, failure, now)
+		b.report(origin, false, context.Canceled, now)
+		b.report(origin, false, failure, now)
+		_, err := b.allow(origin, now)
+		Expect(err).To(BeAssignableToTypeOf(&OriginUnavailableError{}))
+	})
+
+	Context("probing", func() {
+		var (
+			b      *CircuitBreaker
+			states []bool
+			now    time.Time
+		)
+
+		BeforeEach(func() {
+			states = nil
+			b = &CircuitBreaker{
+				FailureThreshold: 1,
+				BreakDuration:    time.Minute,
+				OnStateChange:    func(_ string, broken bool) { states = append(states, broken) },
+			}
+			now = time.Now()
+			b.report(origin, false, failure, now)
+			now = now.Add(time.Minute)
+		})
+
+		It("sends a single probe request once the break duration has passed", func() {
+			probe, err := b.allow(origin, now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probe).To(BeTrue())
+			// only a single probe is in flight at a time
+			_, err = b.allow(origin, now)
+			Expect(err).To(BeAssignableToTypeOf(&OriginUnavailableError{}))
+			b.report(origin, true, nil, now)
+			Expect(states).To(Equal([]bool{true, false}))
+			probe, err = b.allow(origin, now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probe).To(BeFalse())
+		})
+
+		It("considers the origin broken again if the probe fails", func() {
+			probe, err := b.allow(origin, now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probe).To(BeTrue())
+			b.report(origin, true, failure, now)
+			Expect(states).To(Equal([]bool{true}))
+			_, err = b.allow(origin, now.Add(time.Minute-time.Second))
+			Expect(err).To(BeAssignableToTypeOf(&OriginUnavailableError{}))
+			probe, err = b.allow(origin, now.Add(time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probe).To(BeTrue())
+		})
+
+		It("sends another probe if the probe was canceled", func() {
+			probe, err := b.allow(origin, now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probe).To(BeTrue())
+			b.report(origin, true, context.Canceled, now)
+			probe, err = b.allow(origin, now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probe).To(BeTrue())
+		})
+	})
+})
@@ -7,30 +7,154 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"strconv"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qtls"
 	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
 	"github.com/lucas-clemente/quic-go/quicvarint"
 	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http/httpguts"
 )
 
 // MethodGet0RTT allows a GET request to be sent using 0-RTT.
 // Note that 0-RTT data doesn't provide replay protection.
+//
+// Deprecated: Use WithEarlyData instead.
 const MethodGet0RTT = "GET_0RTT"
 
+var earlyDataContextKey = &contextKey{"early-data"}
+
+// WithEarlyData returns a context that allows requests using it to be sent in 0-RTT data,
+// without waiting for the handshake to complete.
+// This only applies to requests using a safe method (GET, HEAD, OPTIONS and TRACE),
+// requests using other methods are only sent after completion of the handshake.
+// Note that 0-RTT data doesn't provide replay protection.
+func WithEarlyData(ctx context.Context) context.Context {
+	return context.WithValue(ctx, earlyDataContextKey, true)
+}
+
+// allowsEarlyData says if req may be sent in 0-RTT data.
+func allowsEarlyData(req *http.Request) bool {
+	if early, _ := req.Context().Value(earlyDataContextKey).(bool); !early {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// EarlyDataInfo describes the use of 0-RTT for a request.
+type EarlyDataInfo struct {
+	// Sent is set if the request was sent before completion of the handshake, i.e. in 0-RTT data.
+	// An attacker can replay such requests.
+	Sent bool
+	// Accepted is set if the server accepted 0-RTT data on the connection.
+	Accepted bool
+}
+
+type earlyDataReporter interface {
+	earlyDataInfo() EarlyDataInfo
+}
+
+// ResponseEarlyData says if the request that rsp is the response to was sent in 0-RTT data,
+// and if the server accepted 0-RTT.
+// rsp must be a response returned by the RoundTripper.
+func ResponseEarlyData(rsp *http.Response) (EarlyDataInfo, error) {
+	r, ok := rsp.Body.(earlyDataReporter)
+	if !ok {
+		return EarlyDataInfo{}, errors.New("http3: response doesn't contain 0-RTT information")
+	}
+	return r.earlyDataInfo(), nil
+}
+
+type connectionReporter interface {
+	connectionInfo() (quic.Connection, quic.StreamID)
+}
+
+// ResponseConnection returns the QUIC connection that rsp was received on,
+// as well as the ID of the request stream (or of the push stream, for pushed responses).
+// rsp must be a response returned by the RoundTripper.
+func ResponseConnection(rsp *http.Response) (quic.Connection, quic.StreamID, error) {
+	r, ok := rsp.Body.(connectionReporter)
+	if !ok {
+		return nil, 0, errors.New("http3: response doesn't contain connection information")
+	}
+	conn, id := r.connectionInfo()
+	return conn, id, nil
+}
+
+type datagramsReporter interface {
+	getDatagrams() (Datagrammer, error)
+}
+
+// ResponseDatagrams returns a Datagrammer to send and receive the HTTP datagrams (RFC 9297)
+// associated with the request that rsp is the response to.
+// rsp must be a response returned by the RoundTripper, and HTTP datagrams must be enabled.
+// Datagrams are only received after the first call to ResponseDatagrams, earlier datagrams are dropped.
+// They can be exchanged until the response body is closed or read to the end.
+// In contrast to OpenDatagramStream, the response body can be read as usual.
+func ResponseDatagrams(rsp *http.Response) (Datagrammer, error) {
+	r, ok := rsp.Body.(datagramsReporter)
+	if !ok {
+		return nil, errors.New("http3: response doesn't support HTTP datagrams")
+	}
+	return r.getDatagrams()
+}
+
+// RequestTiming contains timing information about a request sent by the RoundTripper.
+// It can be used to compare the latency of HTTP/3 to other HTTP versions.
+type RequestTiming struct {
+	// ConnReused is set if the request was sent on a connection that was dialed for a previous request.
+	ConnReused bool
+	// DialDuration is the time it took to resolve the server's address and to establish the QUIC connection.
+	// When using 0-RTT, the connection is established as soon as 0-RTT data can be sent.
+	// Like HandshakeDuration, it describes the connection, and is also set if the connection was reused.
+	DialDuration time.Duration
+	// HandshakeDuration is the time from starting to dial until the completion of the QUIC handshake.
+	HandshakeDuration time.Duration
+	// TimeToFirstByte is the time from starting to send the request until the response header was received.
+	TimeToFirstByte time.Duration
+	// EarlyData says if the request was sent in 0-RTT data, see ResponseEarlyData.
+	EarlyData EarlyDataInfo
+}
+
+type timingReporter interface {
+	timingInfo() RequestTiming
+}
+
+// ResponseTiming returns timing information about the request that rsp is the response to.
+// rsp must be a response returned by the RoundTripper or a ClientConn.
+// For a ClientConn, the durations of dialing and the handshake are measured from the call to NewClientConn.
+func ResponseTiming(rsp *http.Response) (RequestTiming, error) {
+	r, ok := rsp.Body.(timingReporter)
+	if !ok {
+		return RequestTiming{}, errors.New("http3: response doesn't contain timing information")
+	}
+	return r.timingInfo(), nil
+}
+
 const (
 	defaultUserAgent              = "quic-go HTTP/3"
 	defaultMaxResponseHeaderBytes = 10 * 1 << 20 // 10 MB
+	defaultExpectContinueTimeout  = time.Second
+	// max1xxResponses is the maximum number of informational responses accepted before the final response.
+	max1xxResponses = 5
 )
 
 var defaultQuicConfig = &quic.Config{
 	MaxIncomingStreams: -1, // don't allow the server to create bidirectional streams
-	KeepAlive:          true,
 	Versions:           []protocol.VersionNumber{protocol.VersionTLS},
 }
 
@@ -39,11 +163,108 @@ type dialFunc func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *qu
 var dialAddr = quic.DialAddrEarlyContext
 
 type roundTripperOpts struct {
-	DisableCompression bool
-	EnableDatagram     bool
-	MaxHeaderBytes     int64
-	AdditionalSettings map[uint64]uint64
-	StreamHijacker     func(FrameType, quic.Connection, quic.Stream) (hijacked bool, err error)
+	DisableCompression    bool
+	CompressRequestBodies bool
+	EnableDatagram        bool
+	MaxHeaderBytes        int64
+	// MaxHeaderListSize, QPACKMaxTableCapacity and QPACKBlockedStreams limit the decoding of response headers,
+	// see the RoundTripper fields of the same name.
+	MaxHeaderListSize     uint64
+	QPACKMaxTableCapacity uint64
+	QPACKBlockedStreams   uint64
+	AdditionalSettings    map[uint64]uint64
+	StreamHijacker        func(FrameType, quic.Connection, quic.Stream) (hijacked bool, err error)
+	UniStreamHijacker     func(StreamType, quic.Connection, quic.ReceiveStream) (hijacked bool)
+	uniStreamHandlers     *uniStreamHandlers // nil if no stream types were registered
+	frameHandlers         *frameHandlers     // nil if no frame types were registered
+	PushHandler           func(*http.Request, *http.Response)
+	EnableWebTransport    bool
+	// ExpectContinueTimeout is the time to wait for a 100 Continue response,
+	// before sending the body of a request with an "Expect: 100-continue" header.
+	ExpectContinueTimeout time.Duration
+	// KeepAlivePeriod and KeepAliveIdleGrace configure the keep-alives, see the RoundTripper fields of the same name.
+	KeepAlivePeriod    time.Duration
+	KeepAliveIdleGrace time.Duration
+	// RequestBodyFrameSize and RequestBodyFlushThreshold configure how request bodies are sent,
+	// see the RoundTripper fields of the same name.
+	RequestBodyFrameSize      int
+	RequestBodyFlushThreshold int
+	Logger                    logging.Logger
+	// onIdle is called when the last active request on the connection completes.
+	onIdle func()
+	// onRequestDone is called every time a request on the connection completes.
+	onRequestDone func()
+	// Strict enables strict mode, see RoundTripper.Strict.
+	Strict                   bool
+	ProtocolViolationHandler func(quic.Connection, *ProtocolViolation)
+}
+
+var errGoAway = errors.New("http3: server sent GOAWAY")
+
+var errClientClosed = errors.New("http3: client closed")
+
+// unprocessedRequestError is returned for requests that were not processed by the server,
+// either because the server sent a GOAWAY frame, or because it rejected the request stream.
+// These requests can safely be retried on a new connection.
+type unprocessedRequestError struct {
+	err error
+}
+
+func (e *unprocessedRequestError) Error() string { return e.err.Error() }
+func (e *unprocessedRequestError) Unwrap() error { return e.err }
+
+// connLostError is returned for requests that failed because the connection was lost
+// before any bytes of the response were received.
+// Like http2.Transport, the RoundTripper retries these requests on a new connection,
+// if the request body can be rewound.
+type connLostError struct {
+	err error
+}
+
+func (e *connLostError) Error() string { return e.err.Error() }
+func (e *connLostError) Unwrap() error { return e.err }
+
+// isConnectionLoss says if err was caused by the connection being closed by the peer, or timing out.
+// Connections closed by us are not considered lost.
+func isConnectionLoss(err error) bool {
+	var appErr *quic.ApplicationError
+	var transportErr *quic.TransportError
+	var idleTimeoutErr *quic.IdleTimeoutError
+	var statelessResetErr *quic.StatelessResetError
+	switch {
+	case errors.As(err, &appErr):
+		return appErr.Remote
+	case errors.As(err, &transportErr):
+		return transportErr.Remote
+	case errors.As(err, &idleTimeoutErr), errors.As(err, &statelessResetErr):
+		return true
+	default:
+		return isNetworkChange(err)
+	}
+}
+
+// isNetworkChange says if err was caused by the network path to the server going away,
+// e.g. because the network interface went down, or the host switched networks.
+// A new connection might succeed on the new path.
+func isNetworkChange(err error) bool {
+	return errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETDOWN) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL)
+}
+
+// responseStartStream records if any data was read from the stream.
+type responseStartStream struct {
+	quic.ReceiveStream
+	receivedData bool
+}
+
+func (s *responseStartStream) Read(b []byte) (int, error) {
+	n, err := s.ReceiveStream.Read(b)
+	if n > 0 {
+		s.receivedData = true
+	}
+	return n, err
 }
 
 // client is a HTTP3 client doing requests
@@ -55,20 +276,58 @@ type client struct {
 	dialOnce     sync.Once
 	dialer       dialFunc
 	handshakeErr error
+	dialStart    time.Time // set before dialing, and never modified afterwards
+
+	setupDone     chan struct{} // closed once the control stream was set up after dialing
+	rejectionOnce sync.Once
+	rejectionErr  error // set if setting up the connection after the rejection of 0-RTT failed
 
 	requestWriter *requestWriter
 
-	decoder *qpack.Decoder
+	decoder    *qpackDecoder
+	uniStreams openedUniStreams // the unique streams of registered types opened by the server
 
-	hostname string
+	hostname string // empty if the connection wasn't dialed by the client, see ClientConn
 	conn     quic.EarlyConnection
 
+	controlStrMutex sync.Mutex
+	controlStr      quic.SendStream
+
+	keepAlive *keepAlive // nil if keep-alives are disabled
+
+	push *clientPushState // nil if server push is disabled
+
+	datagrams    *datagramDemuxer     // nil if HTTP datagrams are disabled
+	webTransport *webTransportManager // nil if WebTransport is disabled
+
+	settingsOnce     sync.Once
+	receivedSettings chan struct{} // closed once the server's SETTINGS frame was received
+	settings         *settingsFrame
+
+	strict *strictMode
+
+	mutex          sync.Mutex // protects the following fields
+	receivedGoAway bool
+	goAwayID       quic.StreamID // requests on streams with this or a higher ID are not processed by the server
+	connClosed     bool          // dialing failed, or the connection was closed
+	activeRequests int
+	idleSince      time.Time
+	// If a request stream was taken over by the application (e.g. for a WebTransport session),
+	// we can't tell when the connection becomes idle.
+	hasHijackedStreams bool
+	dialDuration       time.Duration
+	handshakeDuration  time.Duration // zero until the completion of the handshake was observed
+
 	logger utils.Logger
 }
 
 func newClient(hostname string, tlsConf *tls.Config, opts *roundTripperOpts, conf *quic.Config, dialer dialFunc) (*client, error) {
 	if conf == nil {
 		conf = defaultQuicConfig.Clone()
+		if opts.EnableWebTransport {
+			// use the QUIC default, the server opens streams for WebTransport sessions
+			conf.MaxIncomingStreams = 0
+		}
 	} else if len(conf.Versions) == 0 {
 		conf = conf.Clone()
 		conf.Versions = []quic.VersionNumber{defaultQuicConfig.Versions[0]}
@@ -76,11 +335,15 @@ func newClient(hostname string, tlsConf *tls.Config, opts *roundTripperOpts, con
 	if len(conf.Versions) != 1 {
 		return nil, errors.New("can only use a single QUIC version for dialing a HTTP/3 connection")
 	}
-	if conf.MaxIncomingStreams == 0 {
+	if conf.MaxIncomingStreams == 0 && !opts.EnableWebTransport {
 		conf.MaxIncomingStreams = -1 // don't allow any bidirectional streams
 	}
-	conf.EnableDatagrams = opts.EnableDatagram
-	logger := utils.DefaultLogger.WithPrefix("h3 client")
+	conf.EnableDatagrams = opts.EnableDatagram || opts.EnableWebTransport
+	l := opts.Logger
+	if l == nil {
+		l = conf.Logger
+	}
+	logger := utils.GetLogger(l).WithPrefix("h3 client")
 
 	if tlsConf == nil {
 		tlsConf = &tls.Config{}
@@ -90,44 +353,117 @@ func newClient(hostname string, tlsConf *tls.Config, opts *roundTripperOpts, con
 	// Replace existing ALPNs by H3
 	tlsConf.NextProtos = []string{versionToALPN(conf.Versions[0])}
 
-	return &client{
-		hostname:      authorityAddr("https", hostname),
+	if hostname != "" {
+		hostname = authorityAddr("https", hostname)
+	}
+	requestWriter := newRequestWriter(logger)
+	requestWriter.frameSize = opts.RequestBodyFrameSize
+	requestWriter.flushThreshold = opts.RequestBodyFlushThreshold
+	c := &client{
+		hostname:      hostname,
 		tlsConf:       tlsConf,
-		requestWriter: newRequestWriter(logger),
-		decoder:       qpack.NewDecoder(func(hf qpack.HeaderField) {}),
+		requestWriter: requestWriter,
 		config:        conf,
 		opts:          opts,
 		dialer:        dialer,
 		logger:        logger,
-	}, nil
+
+		receivedSettings: make(chan struct{}),
+		setupDone:        make(chan struct{}),
+		idleSince:        time.Now(),
+		strict:           newStrictMode(opts.Strict, opts.ProtocolViolationHandler),
+	}
+	c.decoder = newQPACKDecoder(func() (quic.SendStream, error) { return c.conn.OpenUniStream() })
+	c.decoder.maxTableCapacity = opts.QPACKMaxTableCapacity
+	c.decoder.maxBlockedStreams = opts.QPACKBlockedStreams
+	c.decoder.maxHeaderListSize = opts.MaxHeaderListSize
+	if opts.PushHandler != nil {
+		c.push = newClientPushState()
+	}
+	return c, nil
 }
 
 func (c *client) dial(ctx context.Context) error {
+	var conn quic.EarlyConnection
 	var err error
 	if c.dialer != nil {
-		c.conn, err = c.dialer(ctx, c.hostname, c.tlsConf, c.config)
+		conn, err = c.dialer(ctx, c.hostname, c.tlsConf, c.config)
 	} else {
-		c.conn, err = dialAddr(ctx, c.hostname, c.tlsConf, c.config)
+		conn, err = dialAddr(ctx, c.hostname, c.tlsConf, c.config)
 	}
 	if err != nil {
 		return err
 	}
+	if period := c.keepAlivePeriod(); period > 0 {
+		// Keep-alives stop once sending fails, i.e. when the connection is closed.
+		c.keepAlive = newKeepAlive(period, c.opts.KeepAliveIdleGrace, c.sendKeepAlive)
+	}
+	// The client might have been closed while dialing.
+	c.mutex.Lock()
+	if c.connClosed {
+		c.mutex.Unlock()
+		conn.CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+		return errClientClosed
+	}
+	c.conn = conn
+	c.mutex.Unlock()
+	if c.opts.EnableDatagram || c.opts.EnableWebTransport {
+		c.datagrams = newDatagramDemuxer(c.conn, c.logger)
+	}
+	if c.opts.EnableWebTransport {
+		c.webTransport = newWebTransportManager(c.conn, c.datagrams)
+	}
 
 	// send the SETTINGs frame, using 0-RTT data, if possible
 	go func() {
+		defer close(c.setupDone)
 		if err := c.setupConn(); err != nil {
+			// If 0-RTT is rejected, the connection is set up again once the handshake completes.
+			if errors.Is(err, quic.Err0RTTRejected) {
+				return
+			}
 			c.logger.Debugf("Setting up connection failed: %s", err)
 			c.conn.CloseWithError(quic.ApplicationErrorCode(errorInternalError), "")
+			return
 		}
+		c.keepAlive.start()
 	}()
 
-	if c.opts.StreamHijacker != nil {
+	if c.opts.StreamHijacker != nil || c.webTransport != nil {
 		go c.handleBidirectionalStreams()
 	}
 	go c.handleUnidirectionalStreams()
 	return nil
 }
 
+// handle0RTTRejection is called when a request fails because the server rejected 0-RTT.
+// All streams opened in 0-RTT (including the control stream) were reset,
+// so the connection is set up again once the handshake completes.
+func (c *client) handle0RTTRejection() error {
+	c.rejectionOnce.Do(func() {
+		<-c.setupDone
+		// NextConnection blocks until the handshake completes.
+		c.conn.NextConnection()
+		if err := c.conn.Context().Err(); err != nil {
+			c.rejectionErr = err
+			return
+		}
+		c.logger.Debugf("0-RTT rejected, setting up the connection again")
+		c.strict.reset()
+		c.decoder.reset()
+		if err := c.setupConn(); err != nil {
+			c.rejectionErr = err
+			c.conn.CloseWithError(quic.ApplicationErrorCode(errorInternalError), "")
+			return
+		}
+		if c.opts.StreamHijacker != nil || c.webTransport != nil {
+			go c.handleBidirectionalStreams()
+		}
+		go c.handleUnidirectionalStreams()
+	})
+	return c.rejectionErr
+}
+
 func (c *client) setupConn() error {
 	// open the control stream
 	str, err := c.conn.OpenUniStream()
@@ -137,11 +473,55 @@ func (c *client) setupConn() error {
 	buf := &bytes.Buffer{}
 	quicvarint.Write(buf, streamTypeControlStream)
 	// send the SETTINGS frame
-	(&settingsFrame{Datagram: c.opts.EnableDatagram, Other: c.opts.AdditionalSettings}).Write(buf)
+	(&settingsFrame{Datagram: c.opts.EnableDatagram || c.opts.EnableWebTransport, Other: c.settingsToSend()}).Write(buf)
+	// enable server push
+	if c.push != nil {
+		(&maxPushIDFrame{PushID: c.push.maxPushID}).Write(buf)
+	}
+	c.controlStrMutex.Lock()
+	defer c.controlStrMutex.Unlock()
+	c.controlStr = str
 	_, err = str.Write(buf.Bytes())
 	return err
 }
 
+// settingsToSend returns the additional settings sent in the SETTINGS frame.
+func (c *client) settingsToSend() map[uint64]uint64 {
+	settings := limitSettings(c.opts.AdditionalSettings, c.opts.QPACKMaxTableCapacity, c.opts.QPACKBlockedStreams, c.opts.MaxHeaderListSize)
+	if !c.opts.EnableWebTransport {
+		return settings
+	}
+	settings = copySettings(settings)
+	settings[settingEnableWebTransport] = 1
+	return settings
+}
+
+// writeControlStream writes a frame to the control stream.
+func (c *client) writeControlStream(b []byte) error {
+	c.controlStrMutex.Lock()
+	defer c.controlStrMutex.Unlock()
+
+	if c.controlStr == nil {
+		return errors.New("control stream not opened yet")
+	}
+	_, err := c.controlStr.Write(b)
+	return err
+}
+
+func (c *client) keepAlivePeriod() time.Duration {
+	if c.opts.KeepAlivePeriod == 0 {
+		return defaultKeepAlivePeriod
+	}
+	return c.opts.KeepAlivePeriod
+}
+
+// sendKeepAlive sends a keep-alive on the control stream.
+func (c *client) sendKeepAlive() error {
+	buf := &bytes.Buffer{}
+	appendKeepAliveFrame(buf)
+	return c.writeControlStream(buf.Bytes())
+}
+
 func (c *client) handleBidirectionalStreams() {
 	for {
 		str, err := c.conn.AcceptStream(context.Background())
@@ -152,6 +532,17 @@ func (c *client) handleBidirectionalStreams() {
 		go func(str quic.Stream) {
 			for {
 				_, err := parseNextFrame(str, func(ft FrameType) (processed bool, err error) {
+					if ft == frameTypeWebTransportStream && c.webTransport != nil {
+						if err := c.webTransport.handleBidiStream(str); err != nil {
+							c.logger.Debugf("reading the session ID on stream %d failed: %s", str.StreamID(), err)
+							str.CancelRead(quic.StreamErrorCode(errorGeneralProtocolError))
+							str.CancelWrite(quic.StreamErrorCode(errorGeneralProtocolError))
+						}
+						return true, nil
+					}
+					if c.opts.StreamHijacker == nil {
+						return false, nil
+					}
 					return c.opts.StreamHijacker(ft, c.conn, str)
 				})
 				if err == errHijacked {
@@ -171,6 +562,10 @@ func (c *client) handleUnidirectionalStreams() {
 		str, err := c.conn.AcceptUniStream(context.Background())
 		if err != nil {
 			c.logger.Debugf("accepting unidirectional stream failed: %s", err)
+			// If 0-RTT was rejected, the decoder is reset, and this function is called again.
+			if !errors.Is(err, quic.Err0RTTRejected) {
+				c.decoder.close(err)
+			}
 			return
 		}
 
@@ -183,46 +578,196 @@ func (c *client) handleUnidirectionalStreams() {
 			// We're only interested in the control stream here.
 			switch streamType {
 			case streamTypeControlStream:
-			case streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream:
-				// Our QPACK implementation doesn't use the dynamic table yet.
-				// TODO: check that only one stream of each type is opened.
+				if !c.strict.openCriticalStream(c.conn, str, streamType) {
+					return
+				}
+			case streamTypeQPACKEncoderStream:
+				if c.strict.openCriticalStream(c.conn, str, streamType) {
+					handleEncoderStream(c.conn, str, c.decoder, c.strict)
+				}
+				return
+			case streamTypeQPACKDecoderStream:
+				// Our QPACK encoder doesn't use the dynamic table.
+				if c.strict.openCriticalStream(c.conn, str, streamType) {
+					c.strict.readCriticalStream(c.conn, str)
+				}
 				return
 			case streamTypePushStream:
-				// We never increased the Push ID, so we don't expect any push streams.
-				c.conn.CloseWithError(quic.ApplicationErrorCode(errorIDError), "")
+				c.handlePushStream(str)
 				return
+			case streamTypeWebTransportStream:
+				if c.webTransport != nil {
+					if err := c.webTransport.handleUniStream(str); err != nil {
+						c.logger.Debugf("reading the session ID on stream %d failed: %s", str.StreamID(), err)
+						str.CancelRead(quic.StreamErrorCode(errorGeneralProtocolError))
+					}
+					return
+				}
+				fallthrough
 			default:
+				if handleRegisteredUniStream(c.opts.uniStreamHandlers, &c.uniStreams, c.conn, str, StreamType(streamType)) {
+					return
+				}
+				if c.opts.UniStreamHijacker != nil && c.opts.UniStreamHijacker(StreamType(streamType), c.conn, str) {
+					return
+				}
 				str.CancelRead(quic.StreamErrorCode(errorStreamCreationError))
 				return
 			}
-			f, err := parseNextFrame(str, nil)
+			f, err := c.strict.parseFrame(str, nil, c.opts.frameHandlers.forStream(c.conn, str))
 			if err != nil {
-				c.conn.CloseWithError(quic.ApplicationErrorCode(errorFrameError), "")
+				if !c.strict.criticalStreamFailed(c.conn, str, err) {
+					c.conn.CloseWithError(quic.ApplicationErrorCode(errorFrameError), "")
+				}
 				return
 			}
 			sf, ok := f.(*settingsFrame)
 			if !ok {
-				c.conn.CloseWithError(quic.ApplicationErrorCode(errorMissingSettings), "")
+				c.strict.connectionError(c.conn, str, errorMissingSettings, "")
 				return
 			}
-			if !sf.Datagram {
-				return
+			if c.datagrams != nil {
+				c.datagrams.setPeerEnabled(sf.Datagram)
 			}
+			c.settingsOnce.Do(func() {
+				c.settings = sf
+				close(c.receivedSettings)
+			})
 			// If datagram support was enabled on our side as well as on the server side,
 			// we can expect it to have been negotiated both on the transport and on the HTTP/3 layer.
 			// Note: ConnectionState() will block until the handshake is complete (relevant when using 0-RTT).
-			if c.opts.EnableDatagram && !c.conn.ConnectionState().SupportsDatagrams {
+			if sf.Datagram && (c.opts.EnableDatagram || c.opts.EnableWebTransport) && !c.conn.ConnectionState().SupportsDatagrams {
 				c.conn.CloseWithError(quic.ApplicationErrorCode(errorSettingsError), "missing QUIC Datagram support")
+				return
 			}
+			c.handleControlStream(str)
 		}()
 	}
 }
 
+// ReceivedSettings returns a channel that is closed once the server's SETTINGS frame was received.
+func (c *client) ReceivedSettings() <-chan struct{} {
+	return c.receivedSettings
+}
+
+// Settings returns the settings received from the server.
+func (c *client) Settings() *Settings {
+	return newSettings(c.settings)
+}
+
+// handleControlStream handles the frames sent on the server's control stream after the SETTINGS frame.
+func (c *client) handleControlStream(str quic.ReceiveStream) {
+	extensionFrames := c.opts.frameHandlers.forStream(c.conn, str)
+	for {
+		f, err := c.strict.parseFrame(str, nil, extensionFrames)
+		if err != nil {
+			if !c.strict.criticalStreamFailed(c.conn, str, err) {
+				c.logger.Debugf("reading from the control stream failed: %s", err)
+			}
+			return
+		}
+		switch f := f.(type) {
+		case *goAwayFrame:
+			if err := c.handleGoAway(f.StreamID); err != nil {
+				c.strict.connectionError(c.conn, str, errorIDError, err.Error())
+				return
+			}
+		case *cancelPushFrame:
+			// The server resets the push stream, if it already opened it.
+		default:
+			c.strict.connectionError(c.conn, str, errorFrameUnexpected, fmt.Sprintf("unexpected frame on the control stream: %T", f))
+			return
+		}
+	}
+}
+
+// handleGoAway handles a GOAWAY frame sent by the server (RFC 9114, Section 5.2).
+// No new requests are sent on this connection afterwards.
+func (c *client) handleGoAway(id uint64) error {
+	streamID := quic.StreamID(id)
+	if streamID.InitiatedBy() != protocol.PerspectiveClient || streamID.Type() != protocol.StreamTypeBidi {
+		return fmt.Errorf("GOAWAY for invalid stream %d", id)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.receivedGoAway && streamID > c.goAwayID {
+		return fmt.Errorf("GOAWAY increased the stream ID (from %d to %d)", c.goAwayID, streamID)
+	}
+	c.receivedGoAway = true
+	c.goAwayID = streamID
+	return nil
+}
+
+// isGoingAway says if the server sent a GOAWAY frame.
+func (c *client) isGoingAway() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.receivedGoAway
+}
+
+// canTakeNewRequest says if new requests can be sent on this connection.
+func (c *client) canTakeNewRequest() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return !c.receivedGoAway && !c.connClosed
+}
+
+// getActiveRequests returns the number of requests in flight,
+// and the time when the last request completed.
+func (c *client) getActiveRequests() (int, time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.hasHijackedStreams {
+		return c.activeRequests + 1, time.Time{}
+	}
+	return c.activeRequests, c.idleSince
+}
+
+func (c *client) requestStarted() {
+	c.mutex.Lock()
+	c.activeRequests++
+	c.mutex.Unlock()
+	c.keepAlive.requestStarted()
+}
+
+func (c *client) requestDone() {
+	c.mutex.Lock()
+	c.activeRequests--
+	idle := c.activeRequests == 0
+	if idle {
+		c.idleSince = time.Now()
+	}
+	c.mutex.Unlock()
+	c.keepAlive.requestDone()
+
+	if c.opts.onRequestDone != nil {
+		c.opts.onRequestDone()
+	}
+	if idle && c.opts.onIdle != nil {
+		c.opts.onIdle()
+	}
+}
+
+// isUnprocessed says if the request sent on str won't be processed by the server,
+// since the stream ID is not lower than the one sent in the GOAWAY frame.
+func (c *client) isUnprocessed(str quic.Stream) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.receivedGoAway && str.StreamID() >= c.goAwayID
+}
+
 func (c *client) Close() error {
-	if c.conn == nil {
+	c.mutex.Lock()
+	c.connClosed = true
+	conn := c.conn
+	c.mutex.Unlock()
+	if conn == nil {
 		return nil
 	}
-	return c.conn.CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+	c.keepAlive.close()
+	return conn.CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
 }
 
 func (c *client) maxHeaderBytes() uint64 {
@@ -232,35 +777,236 @@ func (c *client) maxHeaderBytes() uint64 {
 	return uint64(c.opts.MaxHeaderBytes)
 }
 
+func (c *client) expectContinueTimeout() time.Duration {
+	if c.opts.ExpectContinueTimeout <= 0 {
+		return defaultExpectContinueTimeout
+	}
+	return c.opts.ExpectContinueTimeout
+}
+
 // RoundTrip executes a request and returns a response
 func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
-	if authorityAddr("https", hostnameFromRequest(req)) != c.hostname {
-		return nil, fmt.Errorf("http3 client BUG: RoundTrip called for the wrong client (expected %s, got %s)", c.hostname, req.Host)
+	return c.RoundTripOpt(req, RoundTripOpt{})
+}
+
+// RoundTripOpt is like RoundTrip, but takes options.
+func (c *client) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Response, error) {
+	rsp, _, err := c.roundTrip(req, opt)
+	return rsp, err
+}
+
+// dialWebTransport establishes a WebTransport session using an Extended CONNECT request.
+func (c *client) dialWebTransport(req *http.Request) (*http.Response, *WebTransportSession, error) {
+	if !c.opts.EnableWebTransport {
+		return nil, nil, errors.New("http3: WebTransport not enabled")
+	}
+	rsp, str, err := c.roundTrip(req, RoundTripOpt{DontCloseRequestStream: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.settings.Other[settingEnableWebTransport] != 1 {
+		str.CancelRead(quic.StreamErrorCode(errorRequestCanceled))
+		str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+		return nil, nil, errors.New("http3: server didn't enable WebTransport")
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return rsp, nil, fmt.Errorf("http3: WebTransport session establishment failed: %s", rsp.Status)
+	}
+	// The stream is now owned by the session.
+	rsp.Body = http.NoBody
+	return rsp, c.webTransport.addSession(str), nil
+}
+
+// dialConnectUDP establishes a UDP proxying tunnel using an Extended CONNECT request.
+func (c *client) dialConnectUDP(req *http.Request, target net.Addr) (*http.Response, net.PacketConn, error) {
+	rsp, str, err := c.dialDatagramTunnel(req)
+	if err != nil {
+		return rsp, nil, err
+	}
+	return rsp, newConnectUDPConn(str, c.datagrams, c.conn.LocalAddr(), target), nil
+}
+
+// dialConnectIP establishes an IP proxying tunnel using an Extended CONNECT request.
+func (c *client) dialConnectIP(req *http.Request) (*http.Response, *ConnectIPConn, error) {
+	rsp, str, err := c.dialDatagramTunnel(req)
+	if err != nil {
+		return rsp, nil, err
+	}
+	return rsp, newConnectIPConn(str, c.datagrams), nil
+}
+
+// openDatagramStream sends a request, and returns the request stream together with the HTTP datagrams associated with it.
+func (c *client) openDatagramStream(req *http.Request) (*http.Response, *DatagramStream, error) {
+	rsp, str, err := c.dialDatagramTunnel(req)
+	if err != nil {
+		return rsp, nil, err
+	}
+	return rsp, newDatagramStream(str, c.datagrams), nil
+}
+
+// dialDatagramTunnel sends a request for a tunnel that uses HTTP datagrams, usually an Extended CONNECT request.
+// On success, the request stream is owned by the tunnel.
+func (c *client) dialDatagramTunnel(req *http.Request) (*http.Response, quic.Stream, error) {
+	if !c.opts.EnableDatagram {
+		return nil, nil, errors.New("http3: HTTP datagrams not enabled")
+	}
+	rsp, str, err := c.roundTrip(req, RoundTripOpt{DontCloseRequestStream: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !c.settings.Datagram {
+		str.CancelRead(quic.StreamErrorCode(errorRequestCanceled))
+		str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+		return nil, nil, errors.New("http3: server didn't enable HTTP datagrams")
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return rsp, nil, fmt.Errorf("http3: request failed: %s", rsp.Status)
 	}
+	rsp.Body = http.NoBody
+	return rsp, str, nil
+}
 
+// connect establishes the QUIC connection, unless that already happened.
+// It returns true if the connection was dialed by this call.
+func (c *client) connect(ctx context.Context) (bool, error) {
+	var dialed bool
 	c.dialOnce.Do(func() {
-		c.handshakeErr = c.dial(req.Context())
+		dialed = true
+		trace := httptrace.ContextClientTrace(ctx)
+		traceConnectStart(trace, c.hostname)
+		traceTLSHandshakeStart(trace)
+		c.dialStart = time.Now()
+		c.handshakeErr = c.dial(ctx)
+		traceConnectDone(trace, c.hostname, c.handshakeErr)
+		c.mutex.Lock()
+		if c.handshakeErr != nil {
+			c.connClosed = true
+		} else {
+			c.dialDuration = time.Since(c.dialStart)
+		}
+		c.mutex.Unlock()
+		if c.handshakeErr != nil {
+			traceTLSHandshakeDone(trace, tls.ConnectionState{}, c.handshakeErr)
+		}
 	})
+	return dialed, c.handshakeErr
+}
+
+// roundTrip sends a request.
+// Requests that fail because the server rejected 0-RTT are sent again after completion of the handshake,
+// if the request body can be rewound.
+func (c *client) roundTrip(req *http.Request, opt RoundTripOpt) (*http.Response, quic.Stream, error) {
+	rsp, str, err := c.roundTripOnce(req, opt)
+	if err == nil || !errors.Is(err, quic.Err0RTTRejected) {
+		return rsp, str, err
+	}
+	if rerr := c.handle0RTTRejection(); rerr != nil {
+		return nil, nil, err
+	}
+	req, rerr := rewindRequestBody(req)
+	if rerr != nil {
+		return nil, nil, err
+	}
+	return c.roundTripOnce(req, opt)
+}
 
-	if c.handshakeErr != nil {
-		return nil, c.handshakeErr
+func (c *client) roundTripOnce(req *http.Request, opt RoundTripOpt) (*http.Response, quic.Stream, error) {
+	hostname := authorityAddr("https", hostnameFromRequest(req))
+	if c.hostname != "" && hostname != c.hostname {
+		return nil, nil, fmt.Errorf("http3 client BUG: RoundTrip called for the wrong client (expected %s, got %s)", c.hostname, req.Host)
+	}
+
+	trace := httptrace.ContextClientTrace(req.Context())
+	traceGetConn(trace, hostname)
+	dialed, err := c.connect(req.Context())
+	if err != nil {
+		// The network changed while dialing (or while the dial error was cached).
+		// Dialing a new connection might succeed.
+		if isNetworkChange(err) {
+			return nil, nil, &unprocessedRequestError{err: err}
+		}
+		return nil, nil, err
+	}
+	if c.isGoingAway() {
+		return nil, nil, &unprocessedRequestError{err: errGoAway}
 	}
 
 	// Immediately send out this request, if this is a 0-RTT request.
+	early := allowsEarlyData(req)
 	if req.Method == MethodGet0RTT {
 		req.Method = http.MethodGet
-	} else {
+		early = true
+	}
+	if !early {
 		// wait for the handshake to complete
 		select {
 		case <-c.conn.HandshakeComplete().Done():
 		case <-req.Context().Done():
-			return nil, req.Context().Err()
+			return nil, nil, req.Context().Err()
+		}
+		c.handshakeCompleted()
+		if dialed && trace != nil && trace.TLSHandshakeDone != nil {
+			traceTLSHandshakeDone(trace, qtls.ToTLSConnectionState(c.conn.ConnectionState().TLS), nil)
 		}
 	}
+	traceGotConn(trace, c.conn, !dialed)
 
+	// Extended CONNECT can only be used if the server enabled it in its SETTINGS.
+	if isExtendedConnectRequest(req) {
+		select {
+		case <-c.receivedSettings:
+		case <-c.conn.Context().Done():
+			return nil, nil, errors.New("http3: connection closed before receiving the server's SETTINGS")
+		case <-req.Context().Done():
+			return nil, nil, req.Context().Err()
+		}
+		if !c.settings.ExtendedConnect {
+			return nil, nil, errors.New("http3: server didn't enable Extended CONNECT")
+		}
+	}
+
+	if opt.Priority != nil {
+		if err := opt.Priority.validate(); err != nil {
+			return nil, nil, err
+		}
+		// don't modify the caller's request
+		r := *req
+		r.Header = req.Header.Clone()
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+		r.Header.Set("Priority", opt.Priority.String())
+		req = &r
+	}
+
+	var sentEarly bool
+	if early {
+		select {
+		case <-c.conn.HandshakeComplete().Done():
+		default:
+			sentEarly = true
+		}
+	}
+
+	c.requestStarted()
 	str, err := c.conn.OpenStreamSync(req.Context())
 	if err != nil {
-		return nil, err
+		c.requestDone()
+		if req.Context().Err() != nil || errors.Is(err, quic.Err0RTTRejected) {
+			return nil, nil, err
+		}
+		// The connection was closed (possibly before we received the GOAWAY frame).
+		// The request wasn't sent, so it can be retried on a new connection.
+		c.mutex.Lock()
+		c.connClosed = true
+		c.mutex.Unlock()
+		return nil, nil, &unprocessedRequestError{err: err}
+	}
+	if c.isUnprocessed(str) {
+		c.requestDone()
+		str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+		str.CancelRead(quic.StreamErrorCode(errorRequestCanceled))
+		return nil, nil, &unprocessedRequestError{err: errGoAway}
 	}
 
 	// Request Cancellation:
@@ -268,6 +1014,7 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 	// It is shut down when the application is done processing the body.
 	reqDone := make(chan struct{})
 	go func() {
+		defer c.requestDone()
 		select {
 		case <-req.Context().Done():
 			str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
@@ -276,7 +1023,15 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 	}()
 
-	rsp, rerr := c.doRequest(req, str, reqDone)
+	// If the request stream is not closed, the body doesn't signal when the request is done.
+	bodyDone := reqDone
+	if opt.DontCloseRequestStream {
+		bodyDone = nil
+	}
+	rsp, rerr := c.doRequest(req, str, opt, bodyDone, RequestTiming{
+		ConnReused: !dialed,
+		EarlyData:  EarlyDataInfo{Sent: sentEarly},
+	})
 	if rerr.err != nil { // if any error occurred
 		close(reqDone)
 		if rerr.streamErr != 0 { // if it was a stream error
@@ -289,30 +1044,143 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 			}
 			c.conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), reason)
 		}
+		var strErr *quic.StreamError
+		if c.isUnprocessed(str) || (errors.As(rerr.err, &strErr) && strErr.ErrorCode == quic.StreamErrorCode(errorRequestRejected)) {
+			return nil, nil, &unprocessedRequestError{err: rerr.err}
+		}
+		var lerr *connLostError
+		if errors.As(rerr.err, &lerr) {
+			c.mutex.Lock()
+			c.connClosed = true
+			c.mutex.Unlock()
+			return nil, nil, lerr
+		}
+	} else if opt.DontCloseRequestStream {
+		c.mutex.Lock()
+		c.hasHijackedStreams = true
+		c.mutex.Unlock()
+		close(reqDone)
 	}
-	return rsp, rerr.err
+	return rsp, str, rerr.err
 }
 
 func (c *client) doRequest(
 	req *http.Request,
 	str quic.Stream,
+	opt RoundTripOpt,
 	reqDone chan struct{},
+	timing RequestTiming,
 ) (*http.Response, requestError) {
 	var requestGzip bool
 	if !c.opts.DisableCompression && req.Method != "HEAD" && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
 		requestGzip = true
 	}
-	if err := c.requestWriter.WriteRequest(str, req, requestGzip); err != nil {
+	// For requests with an "Expect: 100-continue" header, the body is only sent
+	// after receiving a 100 Continue response, or after the ExpectContinueTimeout.
+	// It is not sent at all if the server responds with a final status code first.
+	var continueCh chan bool
+	if req.Body != nil && req.ContentLength != 0 && httpguts.HeaderValuesContainsToken(req.Header["Expect"], "100-continue") {
+		continueCh = make(chan bool, 1)
+		timer := time.AfterFunc(c.expectContinueTimeout(), func() {
+			select {
+			case continueCh <- true:
+			default:
+			}
+		})
+		defer func() {
+			timer.Stop()
+			select {
+			case continueCh <- false:
+			default:
+			}
+		}()
+	}
+	wreq := req
+	if c.opts.CompressRequestBodies && !opt.DontCloseRequestStream && shouldGzipRequestBody(req) {
+		r, err := gzipRequest(req)
+		if err != nil {
+			return nil, newStreamError(errorRequestCanceled, err)
+		}
+		wreq = r
+	}
+	start := time.Now()
+	if err := c.requestWriter.WriteRequest(str, wreq, opt.DontCloseRequestStream, requestGzip, opt.LowLatencyRequestBody, continueCh); err != nil {
+		if isConnectionLoss(err) {
+			return nil, newStreamError(errorInternalError, &connLostError{err: err})
+		}
 		return nil, newStreamError(errorInternalError, err)
 	}
 
-	frame, err := parseNextFrame(str, nil)
-	if err != nil {
-		return nil, newStreamError(errorFrameError, err)
+	res, rerr := c.readResponse(req, str, reqDone, continueCh)
+	if rerr.err != nil {
+		return nil, rerr
 	}
-	hf, ok := frame.(*headersFrame)
-	if !ok {
-		return nil, newConnError(errorFrameUnexpected, errors.New("expected first frame to be a HEADERS frame"))
+	timing.TimeToFirstByte = time.Since(start)
+	respBody := res.Body.(*hijackableBody)
+	respBody.onPriorityUpdate = func(p Priority) error {
+		return c.sendPriorityUpdate(str.StreamID(), p)
+	}
+	timing.DialDuration, timing.HandshakeDuration = c.connTiming()
+	timing.EarlyData.Accepted = respBody.timing.EarlyData.Accepted
+	respBody.timing = timing
+
+	if requestGzip && res.Header.Get("Content-Encoding") == "gzip" {
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Body = newGzipReader(res.Body)
+		res.Uncompressed = true
+	}
+
+	return res, requestError{}
+}
+
+// handshakeCompleted records the duration of the handshake, the first time its completion is observed.
+func (c *client) handshakeCompleted() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.handshakeDuration == 0 {
+		c.handshakeDuration = time.Since(c.dialStart)
+	}
+}
+
+func (c *client) connTiming() (dial, handshake time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.dialDuration, c.handshakeDuration
+}
+
+// sendPriorityUpdate sends a PRIORITY_UPDATE frame for the request stream id on the control stream.
+func (c *client) sendPriorityUpdate(id quic.StreamID, p Priority) error {
+	buf := &bytes.Buffer{}
+	(&priorityUpdateFrame{ElementID: uint64(id), PriorityFieldValue: p.String()}).Write(buf)
+	return c.writeControlStream(buf.Bytes())
+}
+
+// readHeaderSection reads and decodes the next header section from str.
+// PUSH_PROMISE frames received before the HEADERS frame are processed.
+// If the header section is blocked on the QPACK encoder stream, decoding is aborted once ctx is done.
+func (c *client) readHeaderSection(ctx context.Context, str quic.ReceiveStream) ([]qpack.HeaderField, requestError) {
+	var hf *headersFrame
+	extensionFrames := c.opts.frameHandlers.forStream(c.conn, str)
+	for hf == nil {
+		frame, err := c.strict.parseFrame(str, nil, extensionFrames)
+		if err == errReservedFrameType {
+			return nil, c.strict.reportRequestError(c.conn, str, newConnError(errorFrameUnexpected, err))
+		}
+		if err != nil {
+			return nil, newStreamError(errorFrameError, err)
+		}
+		switch f := frame.(type) {
+		case *headersFrame:
+			hf = f
+		case *pushPromiseFrame:
+			if err := c.handlePushPromise(ctx, str, f); err != nil {
+				return nil, newStreamError(errorFrameError, err)
+			}
+		default:
+			return nil, c.strict.reportRequestError(c.conn, str, newConnError(errorFrameUnexpected, errors.New("expected first frame to be a HEADERS frame")))
+		}
 	}
 	if hf.Length > c.maxHeaderBytes() {
 		return nil, newStreamError(errorFrameError, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, c.maxHeaderBytes()))
@@ -321,59 +1189,119 @@ func (c *client) doRequest(
 	if _, err := io.ReadFull(str, headerBlock); err != nil {
 		return nil, newStreamError(errorRequestIncomplete, err)
 	}
-	hfs, err := c.decoder.DecodeFull(headerBlock)
+	hfs, err := c.decoder.decode(ctx, str, headerBlock)
 	if err != nil {
-		// TODO: use the right error code
-		return nil, newConnError(errorGeneralProtocolError, err)
+		if err == errHeaderListTooLarge {
+			str.CancelRead(quic.StreamErrorCode(errorExcessiveLoad))
+		}
+		return nil, decodingRequestError(err)
 	}
-
-	connState := qtls.ToTLSConnectionState(c.conn.ConnectionState().TLS)
-	res := &http.Response{
-		Proto:      "HTTP/3",
-		ProtoMajor: 3,
-		Header:     http.Header{},
-		TLS:        &connState,
+	if err := validateHeaderFields(hfs, false, c.strict.enabled); err != nil {
+		str.CancelRead(quic.StreamErrorCode(errorMessageError))
+		return nil, c.strict.reportRequestError(c.conn, str, newStreamError(errorMessageError, err))
 	}
-	for _, hf := range hfs {
-		switch hf.Name {
-		case ":status":
-			status, err := strconv.Atoi(hf.Value)
-			if err != nil {
-				return nil, newStreamError(errorGeneralProtocolError, errors.New("malformed non-numeric status pseudo header"))
+	return hfs, requestError{}
+}
+
+// readResponse reads the response (or the pushed response) to req from str.
+// Informational (1xx) responses are reported to the httptrace.ClientTrace of the request.
+// If continueCh is not nil, true is sent on it when a 100 Continue response is received.
+func (c *client) readResponse(req *http.Request, str quic.ReceiveStream, reqDone chan struct{}, continueCh chan<- bool) (*http.Response, requestError) {
+	trace := httptrace.ContextClientTrace(req.Context())
+	var res *http.Response
+	var num1xx int
+	rstr := &responseStartStream{ReceiveStream: str}
+	for {
+		hfs, rerr := c.readHeaderSection(req.Context(), rstr)
+		if rerr.err != nil {
+			// If the connection was lost before the server started sending the response,
+			// the request can be retried on a new connection.
+			if !rstr.receivedData && rerr.connErr == 0 && isConnectionLoss(rerr.err) {
+				rerr.err = &connLostError{err: rerr.err}
 			}
-			res.StatusCode = status
-			res.Status = hf.Value + " " + http.StatusText(status)
-		default:
-			res.Header.Add(hf.Name, hf.Value)
+			return nil, rerr
+		}
+		if num1xx == 0 {
+			traceFirstResponseByte(trace)
+		}
+		res = &http.Response{
+			Proto:      "HTTP/3",
+			ProtoMajor: 3,
+			Header:     http.Header{},
+		}
+		for _, hf := range hfs {
+			switch hf.Name {
+			case ":status":
+				status, err := strconv.Atoi(hf.Value)
+				if err != nil {
+					str.CancelRead(quic.StreamErrorCode(errorMessageError))
+					return nil, c.strict.reportRequestError(c.conn, str, newStreamError(errorMessageError, errors.New("malformed non-numeric status pseudo header")))
+				}
+				res.StatusCode = status
+				res.Status = hf.Value + " " + http.StatusText(status)
+			default:
+				res.Header.Add(hf.Name, hf.Value)
+			}
+		}
+		if res.StatusCode < 100 || res.StatusCode > 199 {
+			break
+		}
+		num1xx++
+		if num1xx > max1xxResponses {
+			return nil, newStreamError(errorExcessiveLoad, errors.New("http3: too many 1xx informational responses"))
+		}
+		if res.StatusCode == http.StatusContinue && continueCh != nil {
+			traceGot100Continue(trace)
+			select {
+			case continueCh <- true:
+			default:
+			}
+		}
+		if err := traceGot1xxResponse(trace, res.StatusCode, textproto.MIMEHeader(res.Header)); err != nil {
+			return nil, newStreamError(errorRequestCanceled, err)
 		}
 	}
+	// ConnectionState blocks until the handshake completes.
+	quicState := c.conn.ConnectionState()
+	c.handshakeCompleted()
+	connState := qtls.ToTLSConnectionState(quicState.TLS)
+	res.TLS = &connState
+	res.Trailer = parseAnnouncedTrailers(res.Header)
 	respBody := newResponseBody(str, c.conn, reqDone, func() {
-		c.conn.CloseWithError(quic.ApplicationErrorCode(errorFrameUnexpected), "")
+		c.strict.connectionError(c.conn, str, errorFrameUnexpected, "unexpected frame on the request stream")
 	})
+	respBody.rejectReservedFrames = c.strict.enabled
+	respBody.extensionFrames = c.opts.frameHandlers.forStream(c.conn, str)
+	respBody.settings = c
+	respBody.timing.EarlyData.Accepted = quicState.TLS.Used0RTT
+	if c.datagrams != nil {
+		respBody.datagrams = c.datagrams
+		respBody.onRequestDone = respBody.closeDatagrams
+	}
+	respBody.onPushPromise = func(f *pushPromiseFrame) error { return c.handlePushPromise(req.Context(), str, f) }
+	respBody.onTrailers = func(f *headersFrame) error {
+		if res.Trailer == nil {
+			res.Trailer = make(http.Header)
+		}
+		return readTrailers(req.Context(), c.conn, str, c.decoder, c.maxHeaderBytes(), f, res.Trailer)
+	}
 
 	// Rules for when to set Content-Length are defined in https://tools.ietf.org/html/rfc7230#section-3.3.2.
 	_, hasTransferEncoding := res.Header["Transfer-Encoding"]
-	isInformational := res.StatusCode >= 100 && res.StatusCode < 200
 	isNoContent := res.StatusCode == 204
 	isSuccessfulConnect := req.Method == http.MethodConnect && res.StatusCode >= 200 && res.StatusCode < 300
-	if !hasTransferEncoding && !isInformational && !isNoContent && !isSuccessfulConnect {
+	if !hasTransferEncoding && !isNoContent && !isSuccessfulConnect {
 		res.ContentLength = -1
 		if clens, ok := res.Header["Content-Length"]; ok && len(clens) == 1 {
 			if clen64, err := strconv.ParseInt(clens[0], 10, 64); err == nil {
 				res.ContentLength = clen64
+				// Responses to HEAD requests and 304 responses carry the Content-Length of the representation, but no body.
+				if req.Method != http.MethodHead && res.StatusCode != http.StatusNotModified {
+					respBody.contentLength = clen64
+				}
 			}
 		}
 	}
-
-	if requestGzip && res.Header.Get("Content-Encoding") == "gzip" {
-		res.Header.Del("Content-Encoding")
-		res.Header.Del("Content-Length")
-		res.ContentLength = -1
-		res.Body = newGzipReader(respBody)
-		res.Uncompressed = true
-	} else {
-		res.Body = respBody
-	}
-
+	res.Body = respBody
 	return res, requestError{}
 }
@@ -0,0 +1,200 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// The number of pushes the client allows at the same time.
+// The maximum push ID is increased whenever a pushed response was consumed.
+const maxConcurrentPushes = 100
+
+// clientPushState tracks promised requests and push streams on a single connection.
+// A pushed response can only be processed once both the PUSH_PROMISE and the push stream were received,
+// and they might arrive in any order.
+type clientPushState struct {
+	mutex     sync.Mutex
+	maxPushID uint64
+	promises  map[uint64]*http.Request
+	streams   map[uint64]quic.ReceiveStream
+	handled   map[uint64]struct{}
+}
+
+func newClientPushState() *clientPushState {
+	return &clientPushState{
+		maxPushID: maxConcurrentPushes - 1,
+		promises:  make(map[uint64]*http.Request),
+		streams:   make(map[uint64]quic.ReceiveStream),
+		handled:   make(map[uint64]struct{}),
+	}
+}
+
+func (p *clientPushState) checkPushID(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if id > p.maxPushID {
+		return fmt.Errorf("push ID %d exceeds the maximum push ID (%d)", id, p.maxPushID)
+	}
+	return nil
+}
+
+// addPromise adds a promised request.
+// If the push stream was already received, it is returned.
+func (p *clientPushState) addPromise(id uint64, req *http.Request) (quic.ReceiveStream, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// The same push ID can be promised on multiple request streams.
+	if _, ok := p.handled[id]; ok {
+		return nil, false
+	}
+	if _, ok := p.promises[id]; ok {
+		return nil, false
+	}
+	if str, ok := p.streams[id]; ok {
+		delete(p.streams, id)
+		p.handled[id] = struct{}{}
+		return str, true
+	}
+	p.promises[id] = req
+	return nil, false
+}
+
+// addStream adds a push stream.
+// If the PUSH_PROMISE for this stream was already received, the promised request is returned.
+func (p *clientPushState) addStream(id uint64, str quic.ReceiveStream) (*http.Request, bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.handled[id]; ok {
+		return nil, false, fmt.Errorf("received a second push stream for push ID %d", id)
+	}
+	if _, ok := p.streams[id]; ok {
+		return nil, false, fmt.Errorf("received a second push stream for push ID %d", id)
+	}
+	if req, ok := p.promises[id]; ok {
+		delete(p.promises, id)
+		p.handled[id] = struct{}{}
+		return req, true, nil
+	}
+	p.streams[id] = str
+	return nil, false, nil
+}
+
+// pushDone is called when the pushed response was consumed.
+// It returns the new maximum push ID.
+func (p *clientPushState) pushDone() uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.maxPushID++
+	return p.maxPushID
+}
+
+// handlePushPromise handles a PUSH_PROMISE frame received on a request stream.
+// The header block of the frame is read from str.
+// ctx is the context of the request.
+func (c *client) handlePushPromise(ctx context.Context, str quic.ReceiveStream, f *pushPromiseFrame) error {
+	if c.push == nil {
+		err := errors.New("received a PUSH_PROMISE, but server push is disabled")
+		c.conn.CloseWithError(quic.ApplicationErrorCode(errorIDError), err.Error())
+		return err
+	}
+	if err := c.push.checkPushID(f.PushID); err != nil {
+		c.conn.CloseWithError(quic.ApplicationErrorCode(errorIDError), err.Error())
+		return err
+	}
+	if f.Length > c.maxHeaderBytes() {
+		return fmt.Errorf("PUSH_PROMISE frame too large: %d bytes (max: %d)", f.Length, c.maxHeaderBytes())
+	}
+	headerBlock := make([]byte, f.Length)
+	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		return err
+	}
+	hfs, err := c.decoder.decode(ctx, str, headerBlock)
+	if err != nil {
+		if rerr := decodingRequestError(err); rerr.connErr != 0 {
+			c.conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), err.Error())
+		}
+		return err
+	}
+	req, err := requestFromHeaders(hfs)
+	if err != nil {
+		return err
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return fmt.Errorf("invalid method for a promised request: %s", req.Method)
+	}
+	req.URL.Scheme = "https"
+	req.URL.Host = req.Host
+	req.RequestURI = ""
+	req.TLS = nil
+
+	if pushStr, ok := c.push.addPromise(f.PushID, req); ok {
+		go c.handlePushedResponse(req, pushStr)
+	}
+	return nil
+}
+
+// handlePushStream handles a push stream.
+// The stream type was already read from the stream.
+func (c *client) handlePushStream(str quic.ReceiveStream) {
+	if c.push == nil {
+		// We never increased the Push ID, so we don't expect any push streams.
+		c.conn.CloseWithError(quic.ApplicationErrorCode(errorIDError), "")
+		return
+	}
+	pushID, err := quicvarint.Read(quicvarint.NewReader(str))
+	if err != nil {
+		c.logger.Debugf("reading the push ID on stream %d failed: %s", str.StreamID(), err)
+		return
+	}
+	if err := c.push.checkPushID(pushID); err != nil {
+		c.conn.CloseWithError(quic.ApplicationErrorCode(errorIDError), err.Error())
+		return
+	}
+	req, ok, err := c.push.addStream(pushID, str)
+	if err != nil {
+		c.conn.CloseWithError(quic.ApplicationErrorCode(errorIDError), err.Error())
+		return
+	}
+	if ok {
+		c.handlePushedResponse(req, str)
+	}
+}
+
+func (c *client) handlePushedResponse(req *http.Request, str quic.ReceiveStream) {
+	done := make(chan struct{})
+	rsp, rerr := c.readResponse(req, str, done, nil)
+	if rerr.err != nil {
+		c.logger.Debugf("reading pushed response for %s failed: %s", req.URL, rerr.err)
+		if rerr.streamErr != 0 {
+			str.CancelRead(quic.StreamErrorCode(rerr.streamErr))
+		}
+		if rerr.connErr != 0 {
+			c.conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+			return
+		}
+		close(done)
+	} else {
+		rsp.Request = req
+		go c.opts.PushHandler(req, rsp)
+	}
+
+	<-done
+	maxPushID := c.push.pushDone()
+	buf := &bytes.Buffer{}
+	(&maxPushIDFrame{PushID: maxPushID}).Write(buf)
+	if err := c.writeControlStream(buf.Bytes()); err != nil {
+		c.logger.Debugf("sending MAX_PUSH_ID failed: %s", err)
+	}
+}
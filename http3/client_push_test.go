@@ -0,0 +1,223 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newReaderStream returns a stream that reads from r
+func newReaderStream(r io.Reader) *mockquic.MockStream {
+	str := mockquic.NewMockStream(mockCtrl)
+	str.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+	return str
+}
+
+var _ = Describe("Client Push", func() {
+	Context("push state", func() {
+		var p *clientPushState
+
+		BeforeEach(func() {
+			p = newClientPushState()
+		})
+
+		It("allows a limited number of pushes", func() {
+			Expect(p.checkPushID(maxConcurrentPushes - 1)).To(Succeed())
+			Expect(p.checkPushID(maxConcurrentPushes)).To(MatchError("push ID 100 exceeds the maximum push ID (99)"))
+			Expect(p.pushDone()).To(BeEquivalentTo(maxConcurrentPushes))
+			Expect(p.checkPushID(maxConcurrentPushes)).To(Succeed())
+		})
+
+		It("matches the promise to the push stream, if the promise arrives first", func() {
+			req := &http.Request{}
+			str := mockquic.NewMockStream(mockCtrl)
+			_, ok := p.addPromise(3, req)
+			Expect(ok).To(BeFalse())
+			// promises can be repeated on other request streams
+			_, ok = p.addPromise(3, req)
+			Expect(ok).To(BeFalse())
+			r, ok, err := p.addStream(3, str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(r).To(Equal(req))
+			// promises arriving after the push was handled are ignored
+			_, ok = p.addPromise(3, req)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("matches the promise to the push stream, if the push stream arrives first", func() {
+			req := &http.Request{}
+			str := mockquic.NewMockStream(mockCtrl)
+			_, ok, err := p.addStream(3, str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			s, ok := p.addPromise(3, req)
+			Expect(ok).To(BeTrue())
+			Expect(s).To(Equal(str))
+		})
+
+		It("rejects duplicate push streams", func() {
+			_, _, err := p.addStream(3, mockquic.NewMockStream(mockCtrl))
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = p.addStream(3, mockquic.NewMockStream(mockCtrl))
+			Expect(err).To(MatchError("received a second push stream for push ID 3"))
+		})
+	})
+
+	Context("receiving pushes", func() {
+		var (
+			cl         *client
+			conn       *mockquic.MockEarlyConnection
+			controlBuf *bytes.Buffer
+			// protects controlBuf, which is written to when a push is done
+			controlMutex sync.Mutex
+			pushes       chan *http.Response
+		)
+
+		getPushPromise := func(pushID uint64, path string) []byte {
+			headerBuf := &bytes.Buffer{}
+			enc := qpack.NewEncoder(headerBuf)
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":method", Value: "GET"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":scheme", Value: "https"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":authority", Value: "quic.clemente.io"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":path", Value: path})).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			buf := &bytes.Buffer{}
+			(&pushPromiseFrame{PushID: pushID, Length: uint64(headerBuf.Len())}).Write(buf)
+			buf.Write(headerBuf.Bytes())
+			return buf.Bytes()
+		}
+
+		getPushStream := func(pushID uint64, data string) []byte {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypePushStream)
+			quicvarint.Write(buf, pushID)
+			rstr := mockquic.NewMockStream(mockCtrl)
+			rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
+			rw := newResponseWriter(rstr, nil, utils.DefaultLogger)
+			rw.Write([]byte(data))
+			rw.Flush()
+			return buf.Bytes()
+		}
+
+		BeforeEach(func() {
+			pushes = make(chan *http.Response, 1)
+			var err error
+			cl, err = newClient("quic.clemente.io:443", nil, &roundTripperOpts{
+				PushHandler: func(req *http.Request, rsp *http.Response) { pushes <- rsp },
+			}, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}).AnyTimes()
+			cl.conn = conn
+			controlBuf = &bytes.Buffer{}
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				controlMutex.Lock()
+				defer controlMutex.Unlock()
+				return controlBuf.Write(b)
+			}).AnyTimes()
+			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
+			Expect(cl.setupConn()).To(Succeed())
+		})
+
+		It("advertises the maximum push ID", func() {
+			r := quicvarint.NewReader(controlBuf)
+			_, err := quicvarint.Read(r) // stream type
+			Expect(err).ToNot(HaveOccurred())
+			f, err := parseNextFrame(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+			f, err = parseNextFrame(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(&maxPushIDFrame{PushID: maxConcurrentPushes - 1}))
+		})
+
+		It("calls the push handler and increases the maximum push ID when the push is done", func() {
+			b := getPushPromise(0, "/style.css")
+			r := bytes.NewReader(b)
+			f, err := parseNextFrame(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cl.handlePushPromise(context.Background(), newReaderStream(r), f.(*pushPromiseFrame))).To(Succeed())
+
+			pushStr := mockquic.NewMockStream(mockCtrl)
+			pushBuf := bytes.NewBuffer(getPushStream(0, "foobar"))
+			pushStr.EXPECT().Read(gomock.Any()).DoAndReturn(pushBuf.Read).AnyTimes()
+			quicvarint.Read(quicvarint.NewReader(pushBuf)) // the stream type is consumed by handleUnidirectionalStreams
+			go cl.handlePushStream(pushStr)
+
+			var rsp *http.Response
+			Eventually(pushes).Should(Receive(&rsp))
+			Expect(rsp.Request.URL.String()).To(Equal("https://quic.clemente.io/style.css"))
+			// skip the stream type, the SETTINGS frame and the initial MAX_PUSH_ID frame
+			controlBuf.Next(controlBuf.Len())
+			body, err := io.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("foobar"))
+			Eventually(func() int {
+				controlMutex.Lock()
+				defer controlMutex.Unlock()
+				return controlBuf.Len()
+			}).ShouldNot(BeZero())
+			f, err = parseNextFrame(controlBuf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(&maxPushIDFrame{PushID: maxConcurrentPushes}))
+		})
+
+		It("closes the connection when the push ID exceeds the maximum push ID", func() {
+			b := getPushPromise(maxConcurrentPushes, "/style.css")
+			r := bytes.NewReader(b)
+			f, err := parseNextFrame(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorIDError), gomock.Any())
+			Expect(cl.handlePushPromise(context.Background(), newReaderStream(r), f.(*pushPromiseFrame))).ToNot(Succeed())
+		})
+
+		It("closes the connection when a push stream exceeds the maximum push ID", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, maxConcurrentPushes)
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorIDError), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) { close(done) })
+			cl.handlePushStream(str)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("rejects promised requests with unsafe methods", func() {
+			headerBuf := &bytes.Buffer{}
+			enc := qpack.NewEncoder(headerBuf)
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":method", Value: "POST"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":scheme", Value: "https"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":authority", Value: "quic.clemente.io"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":path", Value: "/"})).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			err := cl.handlePushPromise(context.Background(), newReaderStream(headerBuf), &pushPromiseFrame{PushID: 0, Length: uint64(headerBuf.Len())})
+			Expect(err).To(MatchError("invalid method for a promised request: POST"))
+		})
+	})
+
+	It("closes the connection when receiving a PUSH_PROMISE when push is disabled", func() {
+		cl, err := newClient("quic.clemente.io:443", nil, &roundTripperOpts{}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		conn := mockquic.NewMockEarlyConnection(mockCtrl)
+		cl.conn = conn
+		conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorIDError), gomock.Any())
+		err = cl.handlePushPromise(context.Background(), mockquic.NewMockStream(mockCtrl), &pushPromiseFrame{})
+		Expect(err).To(MatchError(errors.New("received a PUSH_PROMISE, but server push is disabled")))
+	})
+})
@@ -9,13 +9,23 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/lucas-clemente/quic-go"
+	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
 	"github.com/lucas-clemente/quic-go/quicvarint"
 
 	"github.com/golang/mock/gomock"
@@ -61,6 +71,23 @@ var _ = Describe("Client", func() {
 		Expect(err).To(MatchError("can only use a single QUIC version for dialing a HTTP/3 connection"))
 	})
 
+	It("uses the configured logger", func() {
+		logger := mocklogging.NewMockLogger(mockCtrl)
+		logger.EXPECT().With("scope", "h3 client").Return(logger)
+		client, err := newClient("localhost:1337", nil, &roundTripperOpts{Logger: logger}, &quic.Config{Logger: mocklogging.NewMockLogger(mockCtrl)}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		logger.EXPECT().Enabled(logging.LogLevelError).Return(true)
+		logger.EXPECT().Log(logging.LogLevelError, "foobar")
+		client.logger.Errorf("foobar")
+	})
+
+	It("uses the logger of the QUIC config", func() {
+		logger := mocklogging.NewMockLogger(mockCtrl)
+		logger.EXPECT().With("scope", "h3 client").Return(logger)
+		_, err := newClient("localhost:1337", nil, &roundTripperOpts{}, &quic.Config{Logger: logger}, nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
 	It("uses the default QUIC and TLS config if none is give", func() {
 		client, err := newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
 		Expect(err).ToNot(HaveOccurred())
@@ -76,6 +103,19 @@ var _ = Describe("Client", func() {
 		Expect(dialAddrCalled).To(BeTrue())
 	})
 
+	It("sends keep-alives instead of using QUIC keep-alives", func() {
+		Expect(defaultQuicConfig.KeepAlive).To(BeFalse())
+		client, err := newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.keepAlivePeriod()).To(Equal(defaultKeepAlivePeriod))
+		client, err = newClient("localhost:1337", nil, &roundTripperOpts{KeepAlivePeriod: time.Second}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.keepAlivePeriod()).To(Equal(time.Second))
+		client, err = newClient("localhost:1337", nil, &roundTripperOpts{KeepAlivePeriod: -1}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.keepAlivePeriod()).To(BeNumerically("<", 0))
+	})
+
 	It("adds the port to the hostname, if none is given", func() {
 		client, err := newClient("quic.clemente.io", nil, &roundTripperOpts{}, nil, nil)
 		Expect(err).ToNot(HaveOccurred())
@@ -159,12 +199,50 @@ var _ = Describe("Client", func() {
 		Expect(err).To(MatchError(testErr))
 	})
 
+	It("reports requests as unprocessed if the network changed while dialing", func() {
+		netErr := &net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("sendmsg", syscall.ENETUNREACH)}
+		client, err := newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		dialAddr = func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+			return nil, netErr
+		}
+		_, err = client.RoundTrip(req)
+		Expect(err).To(MatchError(netErr))
+		var uerr *unprocessedRequestError
+		Expect(errors.As(err, &uerr)).To(BeTrue())
+		Expect(client.canTakeNewRequest()).To(BeFalse())
+	})
+
+	It("configures how request bodies are sent", func() {
+		client, err := newClient("localhost:1337", nil, &roundTripperOpts{RequestBodyFrameSize: 1000, RequestBodyFlushThreshold: 100}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.requestWriter.bodyFrameSize()).To(Equal(1000))
+		Expect(client.requestWriter.flushThreshold).To(Equal(100))
+	})
+
 	It("closes correctly if connection was not created", func() {
 		client, err := newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(client.Close()).To(Succeed())
 	})
 
+	It("closes the connection if the client is closed while dialing", func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		defer mockCtrl.Finish()
+		conn := mockquic.NewMockEarlyConnection(mockCtrl)
+		conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+		client, err := newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		dialAddr = func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+			Expect(client.Close()).To(Succeed())
+			return conn, nil
+		}
+		dialed, err := client.connect(context.Background())
+		Expect(dialed).To(BeTrue())
+		Expect(err).To(MatchError(errClientClosed))
+		Expect(client.canTakeNewRequest()).To(BeFalse())
+	})
+
 	Context("validating the address", func() {
 		It("refuses to do requests for the wrong host", func() {
 			req, err := http.NewRequest("https", "https://quic.clemente.io:1336/foobar.html", nil)
@@ -220,7 +298,7 @@ var _ = Describe("Client", func() {
 		It("parses the SETTINGS frame", func() {
 			buf := &bytes.Buffer{}
 			quicvarint.Write(buf, streamTypeControlStream)
-			(&settingsFrame{}).Write(buf)
+			(&settingsFrame{ExtendedConnect: true, Other: map[uint64]uint64{0x1337: 42}}).Write(buf)
 			controlStr := mockquic.NewMockStream(mockCtrl)
 			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
 			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
@@ -232,6 +310,11 @@ var _ = Describe("Client", func() {
 			})
 			_, err := client.RoundTrip(request)
 			Expect(err).To(MatchError("done"))
+			Eventually(client.ReceivedSettings()).Should(BeClosed())
+			settings := client.Settings()
+			Expect(settings.EnableDatagrams).To(BeFalse())
+			Expect(settings.EnableExtendedConnect).To(BeTrue())
+			Expect(settings.Other).To(Equal(map[uint64]uint64{0x1337: 42}))
 			time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to conn.CloseWithError
 		})
 
@@ -283,6 +366,40 @@ var _ = Describe("Client", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("passes streams of registered types to the handler", func() {
+			handled := make(chan []byte, 1)
+			handlers := &uniStreamHandlers{}
+			Expect(handlers.register(1337, UniStreamHandler{
+				HandleStream: func(c quic.Connection, str quic.ReceiveStream) {
+					defer GinkgoRecover()
+					Expect(c).To(Equal(conn))
+					data, err := io.ReadAll(str)
+					Expect(err).ToNot(HaveOccurred())
+					handled <- data
+				},
+			})).To(Succeed())
+			client.opts.uniStreamHandlers = handlers
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, 1337)
+			buf.WriteString("foobar")
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			done := make(chan struct{})
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError)).Do(func(quic.StreamErrorCode) { close(done) })
+
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return str, nil
+			})
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(handled).Should(Receive(Equal([]byte("foobar"))))
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("errors when the first frame on the control stream is not a SETTINGS frame", func() {
 			buf := &bytes.Buffer{}
 			quicvarint.Write(buf, streamTypeControlStream)
@@ -384,6 +501,126 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Context("GOAWAY handling", func() {
+		var (
+			request              *http.Request
+			conn                 *mockquic.MockEarlyConnection
+			settingsFrameWritten chan struct{}
+			roundTripped         chan struct{}
+		)
+		testDone := make(chan struct{})
+
+		BeforeEach(func() {
+			settingsFrameWritten = make(chan struct{})
+			roundTripped = make(chan struct{})
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).Do(func(b []byte) {
+				defer GinkgoRecover()
+				close(settingsFrameWritten)
+			})
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, errors.New("done"))
+			dialAddr = func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+				return conn, nil
+			}
+			var err error
+			request, err = http.NewRequest("GET", "https://quic.clemente.io:1337/file1.dat", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			testDone <- struct{}{}
+			Eventually(settingsFrameWritten).Should(BeClosed())
+		})
+
+		// receiveControlStream makes the server send a control stream containing the frames written to buf.
+		// The stream is only read after the request has been sent.
+		receiveControlStream := func(buf *bytes.Buffer) {
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			roundTripped := roundTripped
+			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				<-roundTripped
+				return buf.Read(b)
+			}).AnyTimes()
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return controlStr, nil
+			})
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			close(roundTripped)
+		}
+
+		It("parses the GOAWAY frame", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&goAwayFrame{StreamID: 8}).Write(buf)
+			(&goAwayFrame{StreamID: 4}).Write(buf)
+			receiveControlStream(buf)
+			Eventually(client.isGoingAway).Should(BeTrue())
+			Eventually(func() quic.StreamID {
+				client.mutex.Lock()
+				defer client.mutex.Unlock()
+				return client.goAwayID
+			}).Should(Equal(quic.StreamID(4)))
+			time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to conn.CloseWithError
+		})
+
+		It("errors when the GOAWAY frame contains an invalid stream ID", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&goAwayFrame{StreamID: 3}).Write(buf)
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, reason string) {
+				defer GinkgoRecover()
+				Expect(code).To(BeEquivalentTo(errorIDError))
+				Expect(reason).To(Equal("GOAWAY for invalid stream 3"))
+				close(done)
+			})
+			receiveControlStream(buf)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("errors when the server increases the stream ID in the GOAWAY frame", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&goAwayFrame{StreamID: 4}).Write(buf)
+			(&goAwayFrame{StreamID: 8}).Write(buf)
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, reason string) {
+				defer GinkgoRecover()
+				Expect(code).To(BeEquivalentTo(errorIDError))
+				Expect(reason).To(Equal("GOAWAY increased the stream ID (from 4 to 8)"))
+				close(done)
+			})
+			receiveControlStream(buf)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("errors when the server sends an unexpected frame on the control stream", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&maxPushIDFrame{PushID: 10}).Write(buf)
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, _ string) {
+				defer GinkgoRecover()
+				Expect(code).To(BeEquivalentTo(errorFrameUnexpected))
+				close(done)
+			})
+			receiveControlStream(buf)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
 	Context("Doing requests", func() {
 		var (
 			request              *http.Request
@@ -397,8 +634,16 @@ var _ = Describe("Client", func() {
 			buf := &bytes.Buffer{}
 			headerBuf := &bytes.Buffer{}
 			enc := qpack.NewEncoder(headerBuf)
+			// pseudo-header fields must be sent before the regular header fields
 			for name, value := range headers {
-				Expect(enc.WriteField(qpack.HeaderField{Name: name, Value: value})).To(Succeed())
+				if strings.HasPrefix(name, ":") {
+					Expect(enc.WriteField(qpack.HeaderField{Name: name, Value: value})).To(Succeed())
+				}
+			}
+			for name, value := range headers {
+				if !strings.HasPrefix(name, ":") {
+					Expect(enc.WriteField(qpack.HeaderField{Name: name, Value: value})).To(Succeed())
+				}
 			}
 			Expect(enc.Close()).To(Succeed())
 			(&headersFrame{Length: uint64(headerBuf.Len())}).Write(buf)
@@ -475,10 +720,188 @@ var _ = Describe("Client", func() {
 			Expect(err).To(MatchError(testErr))
 		})
 
+		It("doesn't send requests after receiving a GOAWAY frame", func() {
+			Expect(client.handleGoAway(4)).To(Succeed())
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(errGoAway))
+			var uerr *unprocessedRequestError
+			Expect(errors.As(err, &uerr)).To(BeTrue())
+		})
+
+		It("cancels requests on streams that the server won't process", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).DoAndReturn(func(context.Context) (quic.Stream, error) {
+				Expect(client.handleGoAway(4)).To(Succeed())
+				return str, nil
+			})
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorRequestCanceled))
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(errGoAway))
+			var uerr *unprocessedRequestError
+			Expect(errors.As(err, &uerr)).To(BeTrue())
+		})
+
+		It("doesn't send requests after the connection was closed", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(nil, &quic.ApplicationError{Remote: true, ErrorCode: quic.ApplicationErrorCode(errorNoError)})
+			Expect(client.canTakeNewRequest()).To(BeTrue())
+			_, err := client.RoundTrip(request)
+			var uerr *unprocessedRequestError
+			Expect(errors.As(err, &uerr)).To(BeTrue())
+			Expect(client.canTakeNewRequest()).To(BeFalse())
+			n, _ := client.getActiveRequests()
+			Expect(n).To(BeZero())
+		})
+
+		It("tracks active requests", func() {
+			idle := make(chan struct{}, 1)
+			client.opts.onIdle = func() { idle <- struct{}{} }
+			done := make(chan struct{}, 1)
+			client.opts.onRequestDone = func() { done <- struct{}{} }
+			rspBuf := bytes.NewBuffer(getResponse(200))
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any()).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			n, _ := client.getActiveRequests()
+			Expect(n).To(Equal(1))
+			Expect(done).ToNot(Receive())
+			Expect(rsp.Body.Close()).To(Succeed())
+			Eventually(done).Should(Receive())
+			Eventually(idle).Should(Receive())
+			n, idleSince := client.getActiveRequests()
+			Expect(n).To(BeZero())
+			Expect(idleSince).To(BeTemporally("~", time.Now(), scaleDuration(100*time.Millisecond)))
+		})
+
+		It("reports requests rejected by the server as unprocessed", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(gomock.Any())
+			str.EXPECT().Read(gomock.Any()).Return(0, &quic.StreamError{ErrorCode: quic.StreamErrorCode(errorRequestRejected)})
+			_, err := client.RoundTrip(request)
+			var uerr *unprocessedRequestError
+			Expect(errors.As(err, &uerr)).To(BeTrue())
+		})
+
+		It("reports requests as failed due to connection loss if no response bytes were received", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(gomock.Any())
+			str.EXPECT().Read(gomock.Any()).Return(0, &quic.IdleTimeoutError{})
+			Expect(client.canTakeNewRequest()).To(BeTrue())
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(&quic.IdleTimeoutError{}))
+			var lerr *connLostError
+			Expect(errors.As(err, &lerr)).To(BeTrue())
+			Expect(client.canTakeNewRequest()).To(BeFalse())
+		})
+
+		It("reports requests as failed due to connection loss if writing the request fails", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).Return(0, &quic.StatelessResetError{})
+			str.EXPECT().CancelWrite(gomock.Any())
+			_, err := client.RoundTrip(request)
+			var lerr *connLostError
+			Expect(errors.As(err, &lerr)).To(BeTrue())
+		})
+
+		It("reports requests as failed due to connection loss if the network changed", func() {
+			netErr := &net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("sendmsg", syscall.ENETDOWN)}
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(gomock.Any())
+			str.EXPECT().Read(gomock.Any()).Return(0, netErr)
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(netErr))
+			var lerr *connLostError
+			Expect(errors.As(err, &lerr)).To(BeTrue())
+			Expect(client.canTakeNewRequest()).To(BeFalse())
+		})
+
+		It("doesn't report requests as failed due to connection loss if response bytes were received", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(gomock.Any())
+			rsp := getResponse(200)
+			rspBuf := bytes.NewBuffer(rsp[:len(rsp)-2])
+			connErr := &quic.ApplicationError{Remote: true, ErrorCode: quic.ApplicationErrorCode(errorNoError)}
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				if rspBuf.Len() == 0 {
+					return 0, connErr
+				}
+				return rspBuf.Read(b)
+			}).AnyTimes()
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(connErr))
+			var lerr *connLostError
+			Expect(errors.As(err, &lerr)).To(BeFalse())
+		})
+
+		It("doesn't report requests as failed due to connection loss if the connection was closed locally", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(gomock.Any())
+			str.EXPECT().Read(gomock.Any()).Return(0, &quic.ApplicationError{ErrorCode: quic.ApplicationErrorCode(errorNoError)})
+			_, err := client.RoundTrip(request)
+			Expect(err).To(HaveOccurred())
+			var lerr *connLostError
+			Expect(errors.As(err, &lerr)).To(BeFalse())
+		})
+
 		It("performs a 0-RTT request", func() {
+			testErr := errors.New("stream open error")
+			ctx := WithEarlyData(context.Background())
+			request = request.WithContext(ctx)
+			// the handshake is still in progress
+			conn.EXPECT().HandshakeComplete().Return(context.Background())
+			conn.EXPECT().OpenStreamSync(ctx).Return(str, nil)
+			buf := &bytes.Buffer{}
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(gomock.Any())
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+				return 0, testErr
+			})
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(testErr))
+			Expect(decodeHeader(buf)).To(HaveKeyWithValue(":method", "GET"))
+		})
+
+		It("doesn't send requests using unsafe methods in 0-RTT", func() {
+			testErr := errors.New("stream open error")
+			ctx := WithEarlyData(context.Background())
+			request = request.WithContext(ctx)
+			request.Method = http.MethodPost
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(ctx).Return(nil, testErr)
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(testErr))
+		})
+
+		It("performs a 0-RTT request using MethodGet0RTT", func() {
 			testErr := errors.New("stream open error")
 			request.Method = MethodGet0RTT
-			// don't EXPECT any calls to HandshakeComplete()
+			// the handshake is still in progress
+			conn.EXPECT().HandshakeComplete().Return(context.Background())
 			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
 			buf := &bytes.Buffer{}
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
@@ -492,6 +915,79 @@ var _ = Describe("Client", func() {
 			Expect(decodeHeader(buf)).To(HaveKeyWithValue(":method", "GET"))
 		})
 
+		Context("0-RTT rejection", func() {
+			expectSetupAfterRejection := func() {
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Write(gomock.Any())
+				conn.EXPECT().NextConnection().Return(conn)
+				conn.EXPECT().Context().Return(context.Background()).AnyTimes()
+				conn.EXPECT().OpenUniStream().Return(controlStr, nil)
+				conn.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("test done")).MaxTimes(1)
+			}
+
+			It("sends the request again after the handshake completes", func() {
+				testErr := errors.New("stream read error")
+				ctx := WithEarlyData(context.Background())
+				request = request.WithContext(ctx)
+				expectSetupAfterRejection()
+				conn.EXPECT().HandshakeComplete().Return(context.Background()).Times(2)
+				gomock.InOrder(
+					conn.EXPECT().OpenStreamSync(ctx).Return(nil, quic.Err0RTTRejected),
+					conn.EXPECT().OpenStreamSync(ctx).Return(str, nil),
+				)
+				buf := &bytes.Buffer{}
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().CancelWrite(gomock.Any())
+				str.EXPECT().Read(gomock.Any()).Return(0, testErr)
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(testErr))
+				Expect(decodeHeader(buf)).To(HaveKeyWithValue(":method", "GET"))
+			})
+
+			It("doesn't send the request again if the body can't be rewound", func() {
+				req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io:1337/upload", strings.NewReader("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				req.GetBody = nil
+				expectSetupAfterRejection()
+				conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				conn.EXPECT().OpenStreamSync(context.Background()).Return(nil, quic.Err0RTTRejected)
+				_, err = client.RoundTrip(req)
+				Expect(err).To(MatchError(quic.Err0RTTRejected))
+			})
+		})
+
+		It("refuses Extended CONNECT requests if the server didn't enable Extended CONNECT", func() {
+			client.settingsOnce.Do(func() {
+				client.settings = &settingsFrame{}
+				close(client.receivedSettings)
+			})
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().Context().Return(context.Background())
+			req, err := http.NewRequest(http.MethodConnect, "https://quic.clemente.io:1337/chat", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Proto = "websocket"
+			_, err = client.RoundTrip(req)
+			Expect(err).To(MatchError("http3: server didn't enable Extended CONNECT"))
+		})
+
+		It("waits for the server's SETTINGS before sending Extended CONNECT requests", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().Context().Return(context.Background())
+			ctx, cancel := context.WithCancel(context.Background())
+			req, err := http.NewRequestWithContext(ctx, http.MethodConnect, "https://quic.clemente.io:1337/chat", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Proto = "websocket"
+			errChan := make(chan error)
+			go func() {
+				_, err := client.RoundTrip(req)
+				errChan <- err
+			}()
+			Consistently(errChan).ShouldNot(Receive())
+			cancel()
+			Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+		})
+
 		It("returns a response", func() {
 			rspBuf := bytes.NewBuffer(getResponse(418))
 			gomock.InOrder(
@@ -507,6 +1003,496 @@ var _ = Describe("Client", func() {
 			Expect(rsp.Proto).To(Equal("HTTP/3"))
 			Expect(rsp.ProtoMajor).To(Equal(3))
 			Expect(rsp.StatusCode).To(Equal(418))
+			info, err := ResponseEarlyData(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(EarlyDataInfo{}))
+			str.EXPECT().StreamID().Return(quic.StreamID(8))
+			c, id, err := ResponseConnection(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c).To(Equal(conn))
+			Expect(id).To(Equal(quic.StreamID(8)))
+		})
+
+		It("reports the use of 0-RTT", func() {
+			ctx := WithEarlyData(context.Background())
+			request = request.WithContext(ctx)
+			rspBuf := bytes.NewBuffer(getResponse(200))
+			var connState quic.ConnectionState
+			connState.TLS.Used0RTT = true
+			gomock.InOrder(
+				// the handshake is still in progress
+				conn.EXPECT().HandshakeComplete().Return(context.Background()),
+				conn.EXPECT().OpenStreamSync(ctx).Return(str, nil),
+				conn.EXPECT().ConnectionState().Return(connState),
+			)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			info, err := ResponseEarlyData(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(EarlyDataInfo{Sent: true, Accepted: true}))
+		})
+
+		It("reports timing information", func() {
+			dialAddr = func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+				time.Sleep(scaleDuration(10 * time.Millisecond))
+				return conn, nil
+			}
+			rspBuf := bytes.NewBuffer(getResponse(200))
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			var delayed bool
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				if !delayed {
+					delayed = true
+					time.Sleep(scaleDuration(10 * time.Millisecond))
+				}
+				return rspBuf.Read(b)
+			}).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			timing, err := ResponseTiming(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timing.ConnReused).To(BeFalse())
+			Expect(timing.DialDuration).To(BeNumerically(">=", scaleDuration(10*time.Millisecond)))
+			Expect(timing.HandshakeDuration).To(BeNumerically(">=", timing.DialDuration))
+			Expect(timing.TimeToFirstByte).To(BeNumerically(">=", scaleDuration(10*time.Millisecond)))
+			Expect(timing.EarlyData).To(Equal(EarlyDataInfo{}))
+
+			// send another request on the same connection
+			str2 := mockquic.NewMockStream(mockCtrl)
+			rspBuf2 := bytes.NewBuffer(getResponse(200))
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str2, nil)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str2.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str2.EXPECT().Close()
+			str2.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf2.Read).AnyTimes()
+			rsp, err = client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			timing2, err := ResponseTiming(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timing2.ConnReused).To(BeTrue())
+			Expect(timing2.DialDuration).To(Equal(timing.DialDuration))
+			Expect(timing2.HandshakeDuration).To(Equal(timing.HandshakeDuration))
+			Expect(timing2.TimeToFirstByte).To(BeNumerically("<", scaleDuration(10*time.Millisecond)))
+		})
+
+		It("passes frames of registered types on the request stream to the handler", func() {
+			var payloads []string
+			handlers := &frameHandlers{}
+			Expect(handlers.register(0x1337, func(c quic.Connection, id quic.StreamID, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				payloads = append(payloads, fmt.Sprintf("%d: %s", id, data))
+				return err
+			})).To(Succeed())
+			client.opts.frameHandlers = handlers
+			writeFrame := func(b *bytes.Buffer, payload string) {
+				quicvarint.Write(b, 0x1337)
+				quicvarint.Write(b, uint64(len(payload)))
+				b.WriteString(payload)
+			}
+			rspBuf := &bytes.Buffer{}
+			writeFrame(rspBuf, "foo")
+			rspBuf.Write(getResponse(200))
+			(&dataFrame{Length: 6}).Write(rspBuf)
+			rspBuf.WriteString("foobar")
+			writeFrame(rspBuf, "bar")
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			data, err := ioutil.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal("foobar"))
+			Expect(payloads).To(Equal([]string{"4: foo", "4: bar"}))
+		})
+
+		It("reads the trailers", func() {
+			rspBuf := &bytes.Buffer{}
+			rspBuf.Write(getHeadersFrame(map[string]string{":status": "200", "trailer": "Foo, Bar"}))
+			(&dataFrame{Length: 6}).Write(rspBuf)
+			rspBuf.WriteString("foobar")
+			rspBuf.Write(getHeadersFrame(map[string]string{"foo": "1", "bar": "2", "baz": "3"}))
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Header).ToNot(HaveKey("Trailer"))
+			Expect(rsp.Trailer).To(Equal(http.Header{"Foo": nil, "Bar": nil}))
+			data, err := ioutil.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal("foobar"))
+			Expect(rsp.Trailer).To(Equal(http.Header{
+				"Foo": []string{"1"},
+				"Bar": []string{"2"},
+				"Baz": []string{"3"},
+			}))
+		})
+
+		It("errors on pseudo headers in the trailers", func() {
+			rspBuf := &bytes.Buffer{}
+			rspBuf.Write(getHeadersFrame(map[string]string{":status": "200"}))
+			rspBuf.Write(getHeadersFrame(map[string]string{":status": "200"}))
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = ioutil.ReadAll(rsp.Body)
+			Expect(err).To(MatchError("invalid pseudo header in trailers: :status"))
+		})
+
+		Context("malformed responses", func() {
+			BeforeEach(func() {
+				conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close()
+			})
+
+			It("rejects responses with connection-specific header fields", func() {
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "200", "connection": "close"}))
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(errorMessageError))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("connection-specific header field: connection"))
+			})
+
+			It("rejects responses with a non-numeric status", func() {
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "foo"}))
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(errorMessageError))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("malformed non-numeric status pseudo header"))
+			})
+
+			It("rejects responses with header lists that are too large", func() {
+				client.decoder.maxHeaderListSize = 100
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "200", "foo": strings.Repeat("a", 100)}))
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorExcessiveLoad))
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(errorExcessiveLoad))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(errHeaderListTooLarge))
+			})
+
+			It("resets the stream if the body doesn't match the Content-Length", func() {
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "200", "content-length": "3"}))
+				(&dataFrame{Length: 6}).Write(rspBuf)
+				rspBuf.WriteString("foobar")
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.ContentLength).To(BeEquivalentTo(3))
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				_, err = io.ReadAll(rsp.Body)
+				Expect(err).To(MatchError("http3: body doesn't match the Content-Length of 3 bytes"))
+			})
+
+			It("doesn't expect a body for responses to HEAD requests", func() {
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "200", "content-length": "1337"}))
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				req, err := http.NewRequest(http.MethodHead, "https://quic.clemente.io:1337/file1.dat", nil)
+				Expect(err).ToNot(HaveOccurred())
+				rsp, err := client.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.ContentLength).To(BeEquivalentTo(1337))
+				data, err := io.ReadAll(rsp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(BeEmpty())
+			})
+		})
+
+		Context("strict mode", func() {
+			var violations chan *ProtocolViolation
+
+			BeforeEach(func() {
+				v := make(chan *ProtocolViolation, 1)
+				violations = v
+				client.strict = newStrictMode(true, func(_ quic.Connection, violation *ProtocolViolation) { v <- violation })
+				str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			})
+
+			It("rejects malformed responses", func() {
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "200", "transfer-encoding": "chunked"}))
+				conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(errorMessageError))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("connection-specific header field: transfer-encoding"))
+				var violation *ProtocolViolation
+				Expect(violations).To(Receive(&violation))
+				Expect(violation.ErrorCode).To(BeEquivalentTo(errorMessageError))
+				Expect(violation.StreamID).To(Equal(quic.StreamID(4)))
+				Expect(violation.IsStreamError).To(BeTrue())
+			})
+
+			It("rejects frame types reserved for HTTP/2 in the response body", func() {
+				rspBuf := bytes.NewBuffer(getResponse(200))
+				rspBuf.Write([]byte{0x9, 0x0}) // HTTP/2 CONTINUATION frame
+				conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorFrameUnexpected), gomock.Any())
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = io.ReadAll(rsp.Body)
+				Expect(err).To(MatchError(errReservedFrameType))
+				var violation *ProtocolViolation
+				Expect(violations).To(Receive(&violation))
+				Expect(violation.ErrorCode).To(BeEquivalentTo(errorFrameUnexpected))
+				Expect(violation.IsStreamError).To(BeFalse())
+			})
+
+			It("accepts well-formed responses", func() {
+				rspBuf := bytes.NewBuffer(getResponse(200))
+				conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(200))
+				Expect(violations).ToNot(Receive())
+			})
+		})
+
+		It("calls the httptrace hooks", func() {
+			rspBuf := bytes.NewBuffer(getResponse(200))
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx).Times(2)
+			conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil).Times(2)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}).AnyTimes()
+			conn.EXPECT().RemoteAddr().Return(&net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1337}).AnyTimes()
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close().Times(2)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			var events []string
+			newTrace := func() *httptrace.ClientTrace {
+				return &httptrace.ClientTrace{
+					GetConn:           func(hostPort string) { events = append(events, "GetConn "+hostPort) },
+					ConnectStart:      func(network, addr string) { events = append(events, "ConnectStart "+network) },
+					ConnectDone:       func(network, addr string, err error) { events = append(events, "ConnectDone") },
+					TLSHandshakeStart: func() { events = append(events, "TLSHandshakeStart") },
+					TLSHandshakeDone:  func(tls.ConnectionState, error) { events = append(events, "TLSHandshakeDone") },
+					GotConn: func(info httptrace.GotConnInfo) {
+						events = append(events, fmt.Sprintf("GotConn %s, reused: %t", info.Conn.RemoteAddr(), info.Reused))
+					},
+					WroteHeaderField: func(key string, value []string) {
+						if key == ":method" {
+							events = append(events, "WroteHeaderField "+key)
+						}
+					},
+					WroteHeaders:         func() { events = append(events, "WroteHeaders") },
+					WroteRequest:         func(httptrace.WroteRequestInfo) { events = append(events, "WroteRequest") },
+					GotFirstResponseByte: func() { events = append(events, "GotFirstResponseByte") },
+				}
+			}
+			_, err := client.RoundTrip(request.WithContext(httptrace.WithClientTrace(context.Background(), newTrace())))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(events).To(Equal([]string{
+				"GetConn quic.clemente.io:1337",
+				"ConnectStart udp",
+				"TLSHandshakeStart",
+				"ConnectDone",
+				"TLSHandshakeDone",
+				"GotConn 1.2.3.4:1337, reused: false",
+				"WroteHeaderField :method",
+				"WroteHeaders",
+				"WroteRequest",
+				"GotFirstResponseByte",
+			}))
+			events = nil
+			rspBuf.Write(getResponse(200))
+			_, err = client.RoundTrip(request.WithContext(httptrace.WithClientTrace(context.Background(), newTrace())))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(events).To(Equal([]string{
+				"GetConn quic.clemente.io:1337",
+				"GotConn 1.2.3.4:1337, reused: true",
+				"WroteHeaderField :method",
+				"WroteHeaders",
+				"WroteRequest",
+				"GotFirstResponseByte",
+			}))
+		})
+
+		It("reports informational responses", func() {
+			rspBuf := &bytes.Buffer{}
+			rspBuf.Write(getHeadersFrame(map[string]string{":status": "103", "link": "</style.css>; rel=preload"}))
+			rspBuf.Write(getHeadersFrame(map[string]string{":status": "200"}))
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			type informational struct {
+				code   int
+				header textproto.MIMEHeader
+			}
+			var responses []informational
+			req := request.WithContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					responses = append(responses, informational{code: code, header: header})
+					return nil
+				},
+			}))
+			rsp, err := client.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.StatusCode).To(Equal(200))
+			Expect(responses).To(Equal([]informational{{
+				code:   103,
+				header: textproto.MIMEHeader{"Link": []string{"</style.css>; rel=preload"}},
+			}}))
+		})
+
+		It("errors when receiving too many informational responses", func() {
+			rspBuf := &bytes.Buffer{}
+			for i := 0; i <= max1xxResponses; i++ {
+				rspBuf.Write(getHeadersFrame(map[string]string{":status": "103"}))
+			}
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(errorExcessiveLoad))
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("http3: too many 1xx informational responses"))
+		})
+
+		Context("Expect: 100-continue", func() {
+			var reqBuf *bytes.Buffer
+
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "https://quic.clemente.io:1337/upload", bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				request.Header.Set("Expect", "100-continue")
+				buf := &bytes.Buffer{}
+				reqBuf = buf
+				var mutex sync.Mutex
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					return buf.Write(p)
+				}).AnyTimes()
+				conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			})
+
+			It("sends the body after receiving a 100 Continue response", func() {
+				client.opts.ExpectContinueTimeout = time.Hour
+				rspBuf := &bytes.Buffer{}
+				rspBuf.Write(getHeadersFrame(map[string]string{":status": "100"}))
+				rspBuf.Write(getHeadersFrame(map[string]string{":status": "200"}))
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				closed := make(chan struct{})
+				str.EXPECT().Close().Do(func() { close(closed) })
+				var got100Continue bool
+				waiting := make(chan struct{})
+				req := request.WithContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+					Wait100Continue: func() { close(waiting) },
+					Got100Continue:  func() { got100Continue = true },
+				}))
+				rsp, err := client.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(200))
+				Expect(got100Continue).To(BeTrue())
+				Eventually(waiting).Should(BeClosed())
+				Eventually(closed).Should(BeClosed())
+				decodeHeader(reqBuf)
+				frame, err := parseNextFrame(reqBuf, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(&dataFrame{Length: 6}))
+			})
+
+			It("sends the body when the timeout expires", func() {
+				client.opts.ExpectContinueTimeout = 50 * time.Millisecond
+				closed := make(chan struct{})
+				str.EXPECT().Close().Do(func() { close(closed) })
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "200"}))
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					<-closed
+					return rspBuf.Read(b)
+				}).AnyTimes()
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(200))
+				decodeHeader(reqBuf)
+				frame, err := parseNextFrame(reqBuf, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(&dataFrame{Length: 6}))
+			})
+
+			It("doesn't send the body if the server sends a final response right away", func() {
+				client.opts.ExpectContinueTimeout = time.Hour
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "417"}))
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				canceled := make(chan struct{})
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(errorNoError)).Do(func(quic.StreamErrorCode) { close(canceled) })
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(417))
+				Eventually(canceled).Should(BeClosed())
+				decodeHeader(reqBuf)
+				Expect(reqBuf.Len()).To(BeZero())
+			})
+		})
+
+		It("sends the priority", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			buf := &bytes.Buffer{}
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write)
+			gomock.InOrder(
+				str.EXPECT().Close(),
+				str.EXPECT().CancelWrite(gomock.Any()).MaxTimes(1), // when the Read errors
+			)
+			str.EXPECT().Read(gomock.Any()).Return(0, errors.New("test done"))
+			_, err := client.RoundTripOpt(request, RoundTripOpt{Priority: &Priority{Urgency: 1, Incremental: true}})
+			Expect(err).To(MatchError("test done"))
+			Expect(decodeHeader(buf)).To(HaveKeyWithValue("priority", "u=1, i"))
+			// the caller's request is not modified
+			Expect(request.Header).ToNot(HaveKey("Priority"))
+		})
+
+		It("refuses invalid priorities", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			_, err := client.RoundTripOpt(request, RoundTripOpt{Priority: &Priority{Urgency: 8}})
+			Expect(err).To(MatchError("http3: invalid urgency: 8"))
 		})
 
 		Context("requests containing a Body", func() {
@@ -651,6 +1637,50 @@ var _ = Describe("Client", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("cancels a request while the response headers are blocked on the QPACK encoder stream", func() {
+				client.decoder.maxTableCapacity = 100
+				client.decoder.maxBlockedStreams = 1
+				// a header block referencing the first entry of the dynamic table, which is never inserted
+				headerBlock := []byte{2, 0, 0x80}
+				rspBuf := &bytes.Buffer{}
+				(&headersFrame{Length: uint64(len(headerBlock))}).Write(rspBuf)
+				rspBuf.Write(headerBlock)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				req := request.WithContext(ctx)
+				conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				conn.EXPECT().OpenStreamSync(ctx).Return(str, nil)
+				decoderStrBuf := &bytes.Buffer{}
+				decoderStr := mockquic.NewMockStream(mockCtrl)
+				decoderStr.EXPECT().Write(gomock.Any()).DoAndReturn(decoderStrBuf.Write)
+				conn.EXPECT().OpenUniStream().Return(decoderStr, nil)
+				str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				done := make(chan struct{})
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(errorRequestCanceled)).Times(2)
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorRequestCanceled)).Do(func(quic.StreamErrorCode) { close(done) })
+
+				errChan := make(chan error, 1)
+				go func() {
+					_, err := client.RoundTrip(req)
+					errChan <- err
+				}()
+				Consistently(errChan).ShouldNot(Receive())
+				cancel()
+				Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+				Eventually(done).Should(BeClosed())
+				// Stream Cancellation for stream 4
+				r := bytes.NewReader(decoderStrBuf.Bytes())
+				streamType, err := quicvarint.Read(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(streamType).To(BeEquivalentTo(streamTypeQPACKDecoderStream))
+				b, err := io.ReadAll(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal(appendPrefixedInt(nil, 0x40, 6, 4)))
+			})
+
 			It("cancels a request after the response arrived", func() {
 				rspBuf := bytes.NewBuffer(getResponse(404))
 
@@ -711,9 +1741,45 @@ var _ = Describe("Client", func() {
 				Expect(hfs).ToNot(HaveKey("accept-encoding"))
 			})
 
-			It("decompresses the response", func() {
+			It("compresses request bodies", func() {
+				client, err := newClient("quic.clemente.io:1337", nil, &roundTripperOpts{CompressRequestBodies: true}, nil, nil)
+				Expect(err).ToNot(HaveOccurred())
 				conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
 				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				reqBuf := &bytes.Buffer{}
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(reqBuf.Write).AnyTimes()
+				closed := make(chan struct{})
+				str.EXPECT().Close().Do(func() { close(closed) })
+				rspBuf := bytes.NewBuffer(getHeadersFrame(map[string]string{":status": "200"}))
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					<-closed
+					return rspBuf.Read(b)
+				}).AnyTimes()
+				req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io:1337/upload", strings.NewReader(strings.Repeat("foobar", 100)))
+				Expect(err).ToNot(HaveOccurred())
+				rsp, err := client.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(200))
+				Expect(req.Header).ToNot(HaveKey("Content-Encoding"))
+				hfs := decodeHeader(reqBuf)
+				Expect(hfs).To(HaveKeyWithValue("content-encoding", "gzip"))
+				Expect(hfs).To(HaveKey("content-length"))
+				frame, err := parseNextFrame(reqBuf, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(BeAssignableToTypeOf(&dataFrame{}))
+				Expect(strconv.FormatUint(frame.(*dataFrame).Length, 10)).To(Equal(hfs["content-length"]))
+				zr, err := gzip.NewReader(io.LimitReader(reqBuf, int64(frame.(*dataFrame).Length)))
+				Expect(err).ToNot(HaveOccurred())
+				data, err := ioutil.ReadAll(zr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(data)).To(Equal(strings.Repeat("foobar", 100)))
+			})
+
+			It("decompresses the response", func() {
+				conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+				var connState quic.ConnectionState
+				connState.TLS.Used0RTT = true
+				conn.EXPECT().ConnectionState().Return(connState)
 				buf := &bytes.Buffer{}
 				rstr := mockquic.NewMockStream(mockCtrl)
 				rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
@@ -735,6 +1801,12 @@ var _ = Describe("Client", func() {
 				Expect(string(data)).To(Equal("gzipped response"))
 				Expect(rsp.Header.Get("Content-Encoding")).To(BeEmpty())
 				Expect(rsp.Uncompressed).To(BeTrue())
+				info, err := ResponseEarlyData(rsp)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Accepted).To(BeTrue())
+				timing, err := ResponseTiming(rsp)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(timing.EarlyData.Accepted).To(BeTrue())
 			})
 
 			It("only decompresses the response if the response contains the right content-encoding header", func() {
@@ -0,0 +1,196 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+var errClientConnClosing = errors.New("http3: client connection is shutting down")
+
+// A ClientConn is a single HTTP/3 connection to a server.
+// Unlike the RoundTripper, it doesn't dial new connections, and it doesn't retry requests,
+// such that connection management can be implemented by the application.
+// It is safe for concurrent use.
+type ClientConn struct {
+	conn          quic.EarlyConnection
+	client        *client
+	maxConcurrent int
+
+	idleChan chan struct{} // receives a value when the last active request completes
+
+	mutex    sync.Mutex
+	reserved int
+	closing  bool
+}
+
+var _ http.RoundTripper = &ClientConn{}
+
+// ClientConnState describes the state of a ClientConn.
+type ClientConnState struct {
+	// Closed is set when the QUIC connection was closed, e.g. because it timed out.
+	Closed bool
+	// Closing is set when no new requests can be sent on the connection,
+	// either because Shutdown was called, or because the server sent a GOAWAY frame.
+	Closing bool
+	// ActiveRequests is the number of requests in flight.
+	ActiveRequests int
+	// ReservedRequests is the number of requests reserved using ReserveNewRequest that weren't sent yet.
+	ReservedRequests int
+	// LastIdle is the time when the connection last transitioned to idle.
+	// It is zero while requests are in flight.
+	LastIdle time.Time
+}
+
+// NewClientConn creates a ClientConn that sends requests on conn, using the RoundTripper's configuration.
+// The connection must have been established with the ALPN for HTTP/3 ("h3", or "h3-29" for draft-29), and with datagram support
+// enabled if EnableDatagrams or EnableWebTransport is set.
+// The ClientConn doesn't use the RoundTripper's connection pool, and is not closed when the RoundTripper is closed.
+// Connections established through a ClientConn can be used for requests to any host covered by the server's certificate.
+func (r *RoundTripper) NewClientConn(conn quic.EarlyConnection) (*ClientConn, error) {
+	cc := &ClientConn{
+		conn:          conn,
+		maxConcurrent: r.MaxConcurrentRequestsPerConn,
+		idleChan:      make(chan struct{}, 1),
+	}
+	dial := func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+		return conn, nil
+	}
+	c, err := newClient("", nil, r.clientOpts(cc.handleIdle), nil, dial)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.connect(context.Background()); err != nil {
+		return nil, err
+	}
+	cc.client = c
+	return cc, nil
+}
+
+func (cc *ClientConn) handleIdle() {
+	select {
+	case cc.idleChan <- struct{}{}:
+	default:
+	}
+}
+
+// RoundTrip sends a request on the connection.
+func (cc *ClientConn) RoundTrip(req *http.Request) (*http.Response, error) {
+	return cc.RoundTripOpt(req, RoundTripOpt{})
+}
+
+// RoundTripOpt is like RoundTrip, but takes options.
+// OnlyCachedConn has no effect.
+// If the server sent a GOAWAY frame, requests fail, and can be retried on a different connection.
+func (cc *ClientConn) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Response, error) {
+	if err := validateRequest(req); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	cc.mutex.Lock()
+	if cc.closing {
+		cc.mutex.Unlock()
+		closeRequestBody(req)
+		return nil, errClientConnClosing
+	}
+	if cc.reserved > 0 {
+		cc.reserved--
+	}
+	cc.mutex.Unlock()
+	return cc.client.RoundTripOpt(req, opt)
+}
+
+// CanTakeNewRequest says if a new request can be sent on the connection.
+// This is the case unless the connection is closing or closed,
+// or if RoundTripper.MaxConcurrentRequestsPerConn requests are in flight (or reserved).
+func (cc *ClientConn) CanTakeNewRequest() bool {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	return cc.canTakeNewRequestLocked()
+}
+
+func (cc *ClientConn) canTakeNewRequestLocked() bool {
+	if cc.closing || cc.isClosed() || !cc.client.canTakeNewRequest() {
+		return false
+	}
+	if n, _ := cc.client.getActiveRequests(); cc.maxConcurrent > 0 && n+cc.reserved >= cc.maxConcurrent {
+		return false
+	}
+	return true
+}
+
+// ReserveNewRequest is like CanTakeNewRequest, but if it returns true,
+// the request counts towards the limit of concurrent requests until it is sent using RoundTrip.
+func (cc *ClientConn) ReserveNewRequest() bool {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	if !cc.canTakeNewRequestLocked() {
+		return false
+	}
+	cc.reserved++
+	return true
+}
+
+// State returns the state of the connection.
+func (cc *ClientConn) State() ClientConnState {
+	active, lastIdle := cc.client.getActiveRequests()
+	if active > 0 {
+		lastIdle = time.Time{}
+	}
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	return ClientConnState{
+		Closed:           cc.isClosed(),
+		Closing:          cc.closing || cc.client.isGoingAway(),
+		ActiveRequests:   active,
+		ReservedRequests: cc.reserved,
+		LastIdle:         lastIdle,
+	}
+}
+
+func (cc *ClientConn) isClosed() bool {
+	select {
+	case <-cc.conn.Context().Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown gracefully closes the connection:
+// New requests are rejected, and the connection is closed once all active requests have completed.
+// If the context is canceled before, Shutdown returns the context's error, and the connection is not closed.
+// Requests on streams that were taken over by the application (e.g. WebTransport sessions)
+// prevent the connection from becoming idle.
+func (cc *ClientConn) Shutdown(ctx context.Context) error {
+	cc.mutex.Lock()
+	cc.closing = true
+	cc.mutex.Unlock()
+
+	for {
+		if n, _ := cc.client.getActiveRequests(); n == 0 {
+			return cc.Close()
+		}
+		select {
+		case <-cc.idleChan:
+		case <-cc.conn.Context().Done():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close closes the connection, interrupting all requests in flight.
+func (cc *ClientConn) Close() error {
+	cc.mutex.Lock()
+	cc.closing = true
+	cc.mutex.Unlock()
+	return cc.client.Close()
+}
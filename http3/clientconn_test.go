@@ -0,0 +1,176 @@
+package http3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClientConn", func() {
+	var (
+		rt         *RoundTripper
+		conn       *mockquic.MockEarlyConnection
+		connCtx    context.Context
+		connCancel context.CancelFunc
+		testDone   chan struct{}
+	)
+
+	BeforeEach(func() {
+		rt = &RoundTripper{}
+		testDone = make(chan struct{})
+		connCtx, connCancel = context.WithCancel(context.Background())
+		conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		controlStr := mockquic.NewMockStream(mockCtrl)
+		controlStr.EXPECT().Write(gomock.Any()).AnyTimes()
+		conn.EXPECT().OpenUniStream().Return(controlStr, nil).MaxTimes(1)
+		done := testDone
+		conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+			<-done
+			return nil, errors.New("test done")
+		}).MaxTimes(1)
+		conn.EXPECT().Context().Return(connCtx).AnyTimes()
+	})
+
+	AfterEach(func() {
+		connCancel()
+		close(testDone)
+	})
+
+	newRequest := func(url string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		Expect(err).ToNot(HaveOccurred())
+		return req
+	}
+
+	It("sends requests on the connection, for any host", func() {
+		cc, err := rt.NewClientConn(conn)
+		Expect(err).ToNot(HaveOccurred())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		testErr := errors.New("test error")
+		conn.EXPECT().HandshakeComplete().Return(ctx).Times(2)
+		conn.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, testErr).Times(2)
+		_, err = cc.RoundTrip(newRequest("https://www.example.org/foo"))
+		Expect(err).To(MatchError(testErr))
+		_, err = cc.RoundTrip(newRequest("https://quic.clemente.io:1337/bar"))
+		Expect(err).To(MatchError(testErr))
+	})
+
+	It("rejects invalid requests", func() {
+		cc, err := rt.NewClientConn(conn)
+		Expect(err).ToNot(HaveOccurred())
+		body := &mockBody{}
+		req, err := http.NewRequest(http.MethodPost, "http://www.example.org", body)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = cc.RoundTrip(req)
+		Expect(err).To(MatchError("http3: unsupported protocol scheme: http"))
+		Expect(body.closed).To(BeTrue())
+	})
+
+	It("reserves requests", func() {
+		rt.MaxConcurrentRequestsPerConn = 2
+		cc, err := rt.NewClientConn(conn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cc.CanTakeNewRequest()).To(BeTrue())
+		Expect(cc.ReserveNewRequest()).To(BeTrue())
+		cc.client.requestStarted()
+		Expect(cc.CanTakeNewRequest()).To(BeFalse())
+		Expect(cc.ReserveNewRequest()).To(BeFalse())
+		Expect(cc.State().ReservedRequests).To(Equal(1))
+		Expect(cc.State().ActiveRequests).To(Equal(1))
+		cc.client.requestDone()
+		Expect(cc.CanTakeNewRequest()).To(BeTrue())
+	})
+
+	It("uses a reservation when sending a request", func() {
+		cc, err := rt.NewClientConn(conn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cc.ReserveNewRequest()).To(BeTrue())
+		Expect(cc.State().ReservedRequests).To(Equal(1))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		conn.EXPECT().HandshakeComplete().Return(ctx)
+		conn.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, errors.New("test error"))
+		_, err = cc.RoundTrip(newRequest("https://www.example.org"))
+		Expect(err).To(HaveOccurred())
+		Expect(cc.State().ReservedRequests).To(BeZero())
+	})
+
+	It("reports when the connection is closed", func() {
+		cc, err := rt.NewClientConn(conn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cc.State().Closed).To(BeFalse())
+		Expect(cc.State().LastIdle).ToNot(BeZero())
+		connCancel()
+		Expect(cc.State().Closed).To(BeTrue())
+		Expect(cc.CanTakeNewRequest()).To(BeFalse())
+	})
+
+	It("reports when the server sent a GOAWAY frame", func() {
+		cc, err := rt.NewClientConn(conn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cc.client.handleGoAway(8)).To(Succeed())
+		Expect(cc.State().Closing).To(BeTrue())
+		Expect(cc.CanTakeNewRequest()).To(BeFalse())
+	})
+
+	It("closes", func() {
+		cc, err := rt.NewClientConn(conn)
+		Expect(err).ToNot(HaveOccurred())
+		conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+		Expect(cc.Close()).To(Succeed())
+		body := &mockBody{}
+		req, err := http.NewRequest(http.MethodPost, "https://www.example.org", body)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = cc.RoundTrip(req)
+		Expect(err).To(MatchError(errClientConnClosing))
+		Expect(body.closed).To(BeTrue())
+	})
+
+	Context("shutting down", func() {
+		It("closes the connection right away if no requests are active", func() {
+			cc, err := rt.NewClientConn(conn)
+			Expect(err).ToNot(HaveOccurred())
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+			Expect(cc.Shutdown(context.Background())).To(Succeed())
+		})
+
+		It("waits for active requests to complete", func() {
+			cc, err := rt.NewClientConn(conn)
+			Expect(err).ToNot(HaveOccurred())
+			cc.client.requestStarted()
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(cc.Shutdown(context.Background())).To(Succeed())
+			}()
+			Eventually(func() bool { return cc.State().Closing }).Should(BeTrue())
+			Expect(cc.CanTakeNewRequest()).To(BeFalse())
+			_, err = cc.RoundTrip(newRequest("https://www.example.org"))
+			Expect(err).To(MatchError(errClientConnClosing))
+			Consistently(done).ShouldNot(BeClosed())
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+			cc.client.requestDone()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("returns when the context is canceled", func() {
+			cc, err := rt.NewClientConn(conn)
+			Expect(err).ToNot(HaveOccurred())
+			cc.client.requestStarted()
+			ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(10*time.Millisecond))
+			defer cancel()
+			Expect(cc.Shutdown(ctx)).To(MatchError(context.DeadlineExceeded))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package http3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// A ConnectStream is the byte stream of a tunnel established using the CONNECT method (RFC 9114, Section 4.4).
+// The tunneled data is sent in DATA frames on the request stream.
+type ConnectStream struct {
+	str  quic.Stream
+	body io.Reader
+}
+
+var _ io.ReadWriteCloser = &ConnectStream{}
+
+// UpgradeConnect accepts a CONNECT request by sending a 200 response,
+// and returns the byte stream of the tunnel, e.g. to proxy a TCP connection.
+// Header fields can be added to the response before calling UpgradeConnect.
+// The caller takes over the request stream, and is responsible for closing the ConnectStream.
+// The Request.Body must not be used after calling UpgradeConnect.
+func UpgradeConnect(w http.ResponseWriter, r *http.Request) (*ConnectStream, error) {
+	if r.Method != http.MethodConnect || r.Proto != "HTTP/3" {
+		return nil, errors.New("http3: not a CONNECT request")
+	}
+	rw, ok := findResponseWriter(w)
+	if !ok {
+		return nil, errors.New("http3: CONNECT requires the http3 ResponseWriter")
+	}
+	rw.WriteHeader(http.StatusOK)
+	return &ConnectStream{str: rw.DataStream(), body: r.Body}, nil
+}
+
+// Read reads the data sent by the client.
+// It returns io.EOF when the client closed its side of the tunnel.
+func (s *ConnectStream) Read(b []byte) (int, error) {
+	return s.body.Read(b)
+}
+
+// Write sends data to the client.
+func (s *ConnectStream) Write(b []byte) (int, error) {
+	buf := &bytes.Buffer{}
+	(&dataFrame{Length: uint64(len(b))}).Write(buf)
+	buf.Write(b)
+	if _, err := s.str.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// CloseWrite closes the sending side of the tunnel.
+// Data sent by the client can still be read.
+func (s *ConnectStream) CloseWrite() error {
+	return s.str.Close()
+}
+
+// Close closes the tunnel.
+func (s *ConnectStream) Close() error {
+	s.str.CancelRead(quic.StreamErrorCode(errorNoError))
+	return s.str.Close()
+}
+
+// SetDeadline sets the read and write deadlines.
+func (s *ConnectStream) SetDeadline(t time.Time) error {
+	return s.str.SetDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for future Read calls.
+func (s *ConnectStream) SetReadDeadline(t time.Time) error {
+	return s.str.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future Write calls.
+func (s *ConnectStream) SetWriteDeadline(t time.Time) error {
+	return s.str.SetWriteDeadline(t)
+}
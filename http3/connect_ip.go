@@ -0,0 +1,498 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// IP proxying, as defined in RFC 9484.
+const (
+	// DefaultConnectIPTemplate is the default URI template path for IP proxying (RFC 9484, Section 3).
+	DefaultConnectIPTemplate = "/.well-known/masque/ip/{target}/{ipproto}/"
+
+	// The :protocol used for IP proxying requests.
+	protocolConnectIP = "connect-ip"
+
+	// The context ID of HTTP datagrams carrying IP packets.
+	connectIPContextID = 0
+
+	capsuleTypeAddressAssign      CapsuleType = 0x01
+	capsuleTypeAddressRequest     CapsuleType = 0x02
+	capsuleTypeRouteAdvertisement CapsuleType = 0x03
+
+	// the maximum number of IP packets waiting to be read
+	maxConnectIPQueueLen = 128
+	// the maximum size of the capsules that are read into memory
+	maxConnectIPCapsuleLen = 1 << 16
+)
+
+// ErrConnectIPClosed is returned when using an IP proxying tunnel that was closed.
+var ErrConnectIPClosed = errors.New("http3: IP proxying tunnel closed")
+
+// A ConnectIPRequest describes the scope of an IP proxying request.
+type ConnectIPRequest struct {
+	// Target is the target of the tunnel: a hostname, an IP address or an IP prefix (e.g. 192.0.2.0/24).
+	// If empty, the tunnel is not limited to a target.
+	Target string
+	// IPProtocol is the IP protocol number the tunnel is limited to (e.g. 6 for TCP).
+	// If 0, the tunnel is not limited to an IP protocol.
+	IPProtocol uint8
+}
+
+func (r *ConnectIPRequest) templateVars() map[string]string {
+	vars := map[string]string{"target": "*", "ipproto": "*"}
+	if r.Target != "" {
+		vars["target"] = r.Target
+	}
+	if r.IPProtocol != 0 {
+		vars["ipproto"] = strconv.Itoa(int(r.IPProtocol))
+	}
+	return vars
+}
+
+func matchConnectIPTemplate(template string, u *url.URL) (*ConnectIPRequest, error) {
+	vars, err := matchURITemplate(template, u)
+	if err != nil {
+		return nil, err
+	}
+	r := &ConnectIPRequest{}
+	if target, ok := vars["target"]; ok && target != "*" {
+		if target == "" {
+			return nil, errors.New("missing target")
+		}
+		r.Target = target
+	}
+	if proto, ok := vars["ipproto"]; ok && proto != "*" {
+		p, err := strconv.ParseUint(proto, 10, 8)
+		if err != nil || p == 0 {
+			return nil, fmt.Errorf("invalid IP protocol: %q", proto)
+		}
+		r.IPProtocol = uint8(p)
+	}
+	return r, nil
+}
+
+// ParseConnectIPRequest parses an IP proxying request.
+// The request URL is matched against the path (and query) of the URI template.
+// If template is empty, DefaultConnectIPTemplate is used.
+func ParseConnectIPRequest(r *http.Request, template string) (*ConnectIPRequest, error) {
+	if r.Method != http.MethodConnect || r.Proto != protocolConnectIP {
+		return nil, errors.New("http3: not an IP proxying request")
+	}
+	if template == "" {
+		template = DefaultConnectIPTemplate
+	}
+	return matchConnectIPTemplate(template, r.URL)
+}
+
+// UpgradeConnectIP establishes an IP proxying tunnel for a request.
+// It sends a 200 response, after which the request stream is owned by the tunnel.
+// The http.ResponseWriter must be the one passed to the handler by a Server with EnableDatagrams set.
+func UpgradeConnectIP(w http.ResponseWriter, r *http.Request) (*ConnectIPConn, error) {
+	if r.Method != http.MethodConnect || r.Proto != protocolConnectIP {
+		return nil, errors.New("http3: not an IP proxying request")
+	}
+	rw, ok := findResponseWriter(w)
+	if !ok {
+		return nil, errors.New("http3: IP proxying requires the http3 ResponseWriter")
+	}
+	if rw.datagrams == nil {
+		return nil, errors.New("http3: HTTP datagrams not enabled")
+	}
+	rw.Header().Set("Capsule-Protocol", "?1")
+	rw.WriteHeader(http.StatusOK)
+	return newConnectIPConn(rw.DataStream(), rw.datagrams), nil
+}
+
+// An IPPrefix is an IP prefix assigned to or requested by an endpoint,
+// sent in ADDRESS_ASSIGN and ADDRESS_REQUEST capsules.
+type IPPrefix struct {
+	// RequestID is the ID of the address request.
+	// It is 0 for unsolicited address assignments.
+	RequestID uint64
+	Prefix    net.IPNet
+}
+
+// An IPRoute is an IP address range reachable through the tunnel,
+// sent in ROUTE_ADVERTISEMENT capsules.
+type IPRoute struct {
+	StartIP net.IP
+	EndIP   net.IP
+	// IPProtocol is the IP protocol number that can be sent to this range.
+	// If 0, all protocols can be sent.
+	IPProtocol uint8
+}
+
+func writeIP(b *bytes.Buffer, ip net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		b.Write(ip4)
+	} else {
+		b.Write(ip.To16())
+	}
+}
+
+func ipVersion(ip net.IP) uint8 {
+	if ip.To4() != nil {
+		return 4
+	}
+	return 6
+}
+
+func readIP(r io.Reader, version uint8) (net.IP, error) {
+	var l int
+	switch version {
+	case 4:
+		l = net.IPv4len
+	case 6:
+		l = net.IPv6len
+	default:
+		return nil, fmt.Errorf("invalid IP version: %d", version)
+	}
+	ip := make(net.IP, l)
+	if _, err := io.ReadFull(r, ip); err != nil {
+		return nil, err
+	}
+	return ip, nil
+}
+
+func appendIPPrefixes(b *bytes.Buffer, prefixes []IPPrefix) {
+	for _, p := range prefixes {
+		quicvarint.Write(b, p.RequestID)
+		b.WriteByte(ipVersion(p.Prefix.IP))
+		writeIP(b, p.Prefix.IP)
+		ones, _ := p.Prefix.Mask.Size()
+		b.WriteByte(uint8(ones))
+	}
+}
+
+func parseIPPrefixes(b []byte) ([]IPPrefix, error) {
+	r := bytes.NewReader(b)
+	prefixes := []IPPrefix{} // non-nil, even if the capsule is empty
+	for r.Len() > 0 {
+		id, err := quicvarint.Read(r)
+		if err != nil {
+			return nil, err
+		}
+		version, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		ip, err := readIP(r, version)
+		if err != nil {
+			return nil, err
+		}
+		prefixLen, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if int(prefixLen) > 8*len(ip) {
+			return nil, fmt.Errorf("invalid prefix length: %d", prefixLen)
+		}
+		mask := net.CIDRMask(int(prefixLen), 8*len(ip))
+		prefixes = append(prefixes, IPPrefix{
+			RequestID: id,
+			Prefix:    net.IPNet{IP: ip.Mask(mask), Mask: mask},
+		})
+	}
+	return prefixes, nil
+}
+
+func appendIPRoutes(b *bytes.Buffer, routes []IPRoute) {
+	for _, r := range routes {
+		b.WriteByte(ipVersion(r.StartIP))
+		writeIP(b, r.StartIP)
+		writeIP(b, r.EndIP)
+		b.WriteByte(r.IPProtocol)
+	}
+}
+
+func parseIPRoutes(b []byte) ([]IPRoute, error) {
+	r := bytes.NewReader(b)
+	routes := []IPRoute{} // non-nil, even if the capsule is empty
+	for r.Len() > 0 {
+		version, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		start, err := readIP(r, version)
+		if err != nil {
+			return nil, err
+		}
+		end, err := readIP(r, version)
+		if err != nil {
+			return nil, err
+		}
+		proto, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Compare(start, end) > 0 {
+			return nil, fmt.Errorf("invalid route: start IP %s is larger than end IP %s", start, end)
+		}
+		routes = append(routes, IPRoute{StartIP: start, EndIP: end, IPProtocol: proto})
+	}
+	return routes, nil
+}
+
+// A ConnectIPConn is an IP proxying tunnel.
+// IP packets are sent in HTTP datagrams, and the address and route configuration in capsules on the request stream.
+type ConnectIPConn struct {
+	str       quic.Stream
+	datagrams *datagramDemuxer
+
+	writeMutex sync.Mutex // capsules are written from multiple Go routines
+
+	received  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mutex     sync.Mutex
+	assigned  []IPPrefix
+	requested []IPPrefix
+	routes    []IPRoute
+	updated   chan struct{} // closed (and replaced) when a capsule updated the configuration
+}
+
+func newConnectIPConn(str quic.Stream, datagrams *datagramDemuxer) *ConnectIPConn {
+	c := &ConnectIPConn{
+		str:       str,
+		datagrams: datagrams,
+		received:  make(chan []byte, maxConnectIPQueueLen),
+		closed:    make(chan struct{}),
+		updated:   make(chan struct{}),
+	}
+	datagrams.register(str.StreamID(), c.handleDatagram)
+	go c.readCapsules()
+	return c
+}
+
+func (c *ConnectIPConn) handleDatagram(b []byte) {
+	r := bytes.NewReader(b)
+	contextID, err := quicvarint.Read(r)
+	if err != nil || contextID != connectIPContextID {
+		return
+	}
+	select {
+	case c.received <- b[len(b)-r.Len():]:
+	default: // drop the packet
+	}
+}
+
+func (c *ConnectIPConn) readCapsules() {
+	defer c.close()
+
+	r := NewCapsuleReader(c.str)
+	c.handleCapsule(r, CapsuleTypeDatagram, func(value []byte) error {
+		c.handleDatagram(value)
+		return nil
+	})
+	c.handleCapsule(r, capsuleTypeAddressAssign, func(value []byte) error {
+		prefixes, err := parseIPPrefixes(value)
+		if err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		c.assigned = prefixes
+		c.notifyUpdated()
+		c.mutex.Unlock()
+		return nil
+	})
+	c.handleCapsule(r, capsuleTypeAddressRequest, func(value []byte) error {
+		prefixes, err := parseIPPrefixes(value)
+		if err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		c.requested = prefixes
+		c.notifyUpdated()
+		c.mutex.Unlock()
+		return nil
+	})
+	c.handleCapsule(r, capsuleTypeRouteAdvertisement, func(value []byte) error {
+		routes, err := parseIPRoutes(value)
+		if err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		c.routes = routes
+		c.notifyUpdated()
+		c.mutex.Unlock()
+		return nil
+	})
+
+	for {
+		if _, err := r.ReadCapsule(); err != nil {
+			if _, ok := err.(*malformedCapsuleError); ok {
+				c.abort()
+			}
+			return
+		}
+	}
+}
+
+// malformedCapsuleError is returned by the capsule handlers when a capsule couldn't be parsed.
+type malformedCapsuleError struct{ err error }
+
+func (e *malformedCapsuleError) Error() string { return e.err.Error() }
+
+// handleCapsule registers a handler for a capsule type, reading the capsule value into memory.
+func (c *ConnectIPConn) handleCapsule(r *CapsuleReader, t CapsuleType, handle func([]byte) error) {
+	r.Handle(t, func(v io.Reader) error {
+		value, err := ioutil.ReadAll(io.LimitReader(v, maxConnectIPCapsuleLen+1))
+		if err != nil {
+			return err
+		}
+		if len(value) > maxConnectIPCapsuleLen {
+			return &malformedCapsuleError{err: fmt.Errorf("capsule too large: %d bytes", len(value))}
+		}
+		if err := handle(value); err != nil {
+			return &malformedCapsuleError{err: err}
+		}
+		return nil
+	})
+}
+
+// notifyUpdated must be called with the mutex held.
+func (c *ConnectIPConn) notifyUpdated() {
+	close(c.updated)
+	c.updated = make(chan struct{})
+}
+
+// abort closes the tunnel after receiving a malformed capsule.
+func (c *ConnectIPConn) abort() {
+	c.str.CancelRead(quic.StreamErrorCode(errorDatagramError))
+	c.str.CancelWrite(quic.StreamErrorCode(errorDatagramError))
+}
+
+func (c *ConnectIPConn) close() {
+	c.closeOnce.Do(func() {
+		c.datagrams.unregister(c.str.StreamID())
+		close(c.closed)
+	})
+}
+
+// ReadPacket reads the next IP packet received through the tunnel.
+func (c *ConnectIPConn) ReadPacket(b []byte) (int, error) {
+	select {
+	case p := <-c.received:
+		return copy(b, p), nil
+	case <-c.closed:
+		return 0, ErrConnectIPClosed
+	}
+}
+
+// WritePacket sends an IP packet through the tunnel.
+func (c *ConnectIPConn) WritePacket(b []byte) error {
+	select {
+	case <-c.closed:
+		return ErrConnectIPClosed
+	default:
+	}
+	buf := &bytes.Buffer{}
+	quicvarint.Write(buf, connectIPContextID)
+	buf.Write(b)
+	return c.datagrams.send(c.str.StreamID(), buf.Bytes())
+}
+
+func (c *ConnectIPConn) writeCapsule(t CapsuleType, value []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return WriteCapsule(c.str, t, value)
+}
+
+// AssignAddresses sends an ADDRESS_ASSIGN capsule.
+// It replaces all addresses previously assigned to the peer.
+func (c *ConnectIPConn) AssignAddresses(prefixes []IPPrefix) error {
+	buf := &bytes.Buffer{}
+	appendIPPrefixes(buf, prefixes)
+	return c.writeCapsule(capsuleTypeAddressAssign, buf.Bytes())
+}
+
+// RequestAddresses sends an ADDRESS_REQUEST capsule.
+func (c *ConnectIPConn) RequestAddresses(prefixes []IPPrefix) error {
+	buf := &bytes.Buffer{}
+	appendIPPrefixes(buf, prefixes)
+	return c.writeCapsule(capsuleTypeAddressRequest, buf.Bytes())
+}
+
+// AdvertiseRoutes sends a ROUTE_ADVERTISEMENT capsule.
+// It replaces all routes previously advertised to the peer.
+// Routes must be ordered by IP version and start IP, and must not overlap.
+func (c *ConnectIPConn) AdvertiseRoutes(routes []IPRoute) error {
+	buf := &bytes.Buffer{}
+	appendIPRoutes(buf, routes)
+	return c.writeCapsule(capsuleTypeRouteAdvertisement, buf.Bytes())
+}
+
+// waitForConfig waits until has returns true, or the configuration is updated.
+func (c *ConnectIPConn) waitForConfig(ctx context.Context, has func() bool) error {
+	for {
+		c.mutex.Lock()
+		ok := has()
+		updated := c.updated
+		c.mutex.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-updated:
+		case <-c.closed:
+			return ErrConnectIPClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// AssignedAddresses returns the addresses assigned by the peer.
+// It blocks until the peer assigned addresses.
+func (c *ConnectIPConn) AssignedAddresses(ctx context.Context) ([]IPPrefix, error) {
+	if err := c.waitForConfig(ctx, func() bool { return c.assigned != nil }); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.assigned, nil
+}
+
+// RequestedAddresses returns the addresses requested by the peer.
+// It blocks until the peer requested addresses.
+func (c *ConnectIPConn) RequestedAddresses(ctx context.Context) ([]IPPrefix, error) {
+	if err := c.waitForConfig(ctx, func() bool { return c.requested != nil }); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.requested, nil
+}
+
+// Routes returns the routes advertised by the peer.
+// It blocks until the peer advertised routes.
+func (c *ConnectIPConn) Routes(ctx context.Context) ([]IPRoute, error) {
+	if err := c.waitForConfig(ctx, func() bool { return c.routes != nil }); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.routes, nil
+}
+
+// Close closes the tunnel, by closing the request stream.
+func (c *ConnectIPConn) Close() error {
+	c.str.CancelRead(quic.StreamErrorCode(errorNoError))
+	err := c.str.Close()
+	c.close()
+	return err
+}
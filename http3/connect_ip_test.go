@@ -0,0 +1,263 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CONNECT-IP", func() {
+	Context("URI templates", func() {
+		It("expands the default template", func() {
+			r := ConnectIPRequest{Target: "192.0.2.0/24", IPProtocol: 17}
+			Expect(expandURITemplate("https://proxy.example.org"+DefaultConnectIPTemplate, r.templateVars())).
+				To(Equal("https://proxy.example.org/.well-known/masque/ip/192.0.2.0%2F24/17/"))
+		})
+
+		It("uses wildcards for unscoped requests", func() {
+			Expect(expandURITemplate("https://proxy.example.org"+DefaultConnectIPTemplate, (&ConnectIPRequest{}).templateVars())).
+				To(Equal("https://proxy.example.org/.well-known/masque/ip/%2A/%2A/"))
+		})
+
+		It("parses requests", func() {
+			req, err := http.NewRequest(http.MethodConnect, "https://proxy.example.org/.well-known/masque/ip/192.0.2.0%2F24/17/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Proto = protocolConnectIP
+			r, err := ParseConnectIPRequest(req, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r).To(Equal(&ConnectIPRequest{Target: "192.0.2.0/24", IPProtocol: 17}))
+		})
+
+		It("parses unscoped requests", func() {
+			u, err := url.Parse("https://proxy.example.org/.well-known/masque/ip/%2A/%2A/")
+			Expect(err).ToNot(HaveOccurred())
+			r, err := matchConnectIPTemplate(DefaultConnectIPTemplate, u)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r).To(Equal(&ConnectIPRequest{}))
+		})
+
+		It("rejects invalid IP protocols", func() {
+			u, err := url.Parse("https://proxy.example.org/.well-known/masque/ip/192.0.2.1/256/")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = matchConnectIPTemplate(DefaultConnectIPTemplate, u)
+			Expect(err).To(MatchError(`invalid IP protocol: "256"`))
+		})
+
+		It("rejects requests that are not IP proxying requests", func() {
+			req, err := http.NewRequest(http.MethodGet, "https://proxy.example.org/.well-known/masque/ip/192.0.2.1/6/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = ParseConnectIPRequest(req, "")
+			Expect(err).To(MatchError("http3: not an IP proxying request"))
+		})
+	})
+
+	Context("capsule encoding", func() {
+		It("encodes and parses IP prefixes", func() {
+			_, p4, err := net.ParseCIDR("192.0.2.0/24")
+			Expect(err).ToNot(HaveOccurred())
+			_, p6, err := net.ParseCIDR("2001:db8::/32")
+			Expect(err).ToNot(HaveOccurred())
+			prefixes := []IPPrefix{
+				{RequestID: 1, Prefix: *p4},
+				{RequestID: 1337, Prefix: *p6},
+			}
+			buf := &bytes.Buffer{}
+			appendIPPrefixes(buf, prefixes)
+			Expect(buf.Len()).To(Equal(1 + 1 + 4 + 1 + 2 + 1 + 16 + 1))
+			parsed, err := parseIPPrefixes(buf.Bytes())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(HaveLen(2))
+			Expect(parsed[0].RequestID).To(BeEquivalentTo(1))
+			Expect(parsed[0].Prefix.String()).To(Equal("192.0.2.0/24"))
+			Expect(parsed[1].RequestID).To(BeEquivalentTo(1337))
+			Expect(parsed[1].Prefix.String()).To(Equal("2001:db8::/32"))
+		})
+
+		It("rejects invalid prefix lengths", func() {
+			_, err := parseIPPrefixes([]byte{0, 4, 192, 0, 2, 1, 33})
+			Expect(err).To(MatchError("invalid prefix length: 33"))
+		})
+
+		It("rejects invalid IP versions", func() {
+			_, err := parseIPPrefixes([]byte{0, 5, 192, 0, 2, 1, 32})
+			Expect(err).To(MatchError("invalid IP version: 5"))
+		})
+
+		It("encodes and parses routes", func() {
+			routes := []IPRoute{
+				{StartIP: net.ParseIP("192.0.2.0"), EndIP: net.ParseIP("192.0.2.255"), IPProtocol: 6},
+				{StartIP: net.ParseIP("2001:db8::"), EndIP: net.ParseIP("2001:db8::ffff")},
+			}
+			buf := &bytes.Buffer{}
+			appendIPRoutes(buf, routes)
+			parsed, err := parseIPRoutes(buf.Bytes())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(HaveLen(2))
+			Expect(parsed[0].StartIP.String()).To(Equal("192.0.2.0"))
+			Expect(parsed[0].EndIP.String()).To(Equal("192.0.2.255"))
+			Expect(parsed[0].IPProtocol).To(BeEquivalentTo(6))
+			Expect(parsed[1].StartIP.String()).To(Equal("2001:db8::"))
+			Expect(parsed[1].EndIP.String()).To(Equal("2001:db8::ffff"))
+			Expect(parsed[1].IPProtocol).To(BeZero())
+		})
+
+		It("rejects routes with the start IP larger than the end IP", func() {
+			buf := &bytes.Buffer{}
+			appendIPRoutes(buf, []IPRoute{{StartIP: net.ParseIP("192.0.2.2"), EndIP: net.ParseIP("192.0.2.1")}})
+			_, err := parseIPRoutes(buf.Bytes())
+			Expect(err).To(MatchError("invalid route: start IP 192.0.2.2 is larger than end IP 192.0.2.1"))
+		})
+	})
+
+	Context("tunnel", func() {
+		var (
+			conn    *mockquic.MockEarlyConnection
+			str     *mockquic.MockStream
+			strIn   *io.PipeWriter
+			strOut  *bytes.Buffer
+			tunnel  *ConnectIPConn
+			prefix4 net.IPNet
+		)
+
+		BeforeEach(func() {
+			_, p, err := net.ParseCIDR("192.0.2.1/32")
+			Expect(err).ToNot(HaveOccurred())
+			prefix4 = *p
+
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}).AnyTimes()
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			var pr *io.PipeReader
+			pr, strIn = io.Pipe()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(pr.Read).AnyTimes()
+			strOut = &bytes.Buffer{}
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(strOut.Write).AnyTimes()
+			datagrams := newDatagramDemuxer(conn, utils.DefaultLogger)
+			datagrams.setPeerEnabled(true)
+			tunnel = newConnectIPConn(str, datagrams)
+		})
+
+		AfterEach(func() { strIn.Close() })
+
+		It("sends IP packets", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, 4/4)
+			quicvarint.Write(buf, connectIPContextID)
+			buf.WriteString("foobar")
+			conn.EXPECT().SendMessage(buf.Bytes())
+			Expect(tunnel.WritePacket([]byte("foobar"))).To(Succeed())
+		})
+
+		It("receives IP packets", func() {
+			tunnel.handleDatagram([]byte{0x1, 'f', 'o', 'o'}) // unknown context ID
+			tunnel.handleDatagram([]byte{0x0, 'b', 'a', 'r'})
+			b := make([]byte, 10)
+			n, err := tunnel.ReadPacket(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(b[:n])).To(Equal("bar"))
+		})
+
+		It("receives IP packets in DATAGRAM capsules", func() {
+			go func() {
+				defer GinkgoRecover()
+				Expect(WriteCapsule(strIn, CapsuleTypeDatagram, []byte{0x0, 'f', 'o', 'o'})).To(Succeed())
+			}()
+			b := make([]byte, 10)
+			n, err := tunnel.ReadPacket(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(b[:n])).To(Equal("foo"))
+		})
+
+		It("assigns addresses", func() {
+			Expect(tunnel.AssignAddresses([]IPPrefix{{Prefix: prefix4}})).To(Succeed())
+			t, cr, err := ParseCapsule(quicvarint.NewReader(strOut))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t).To(Equal(capsuleTypeAddressAssign))
+			value, err := ioutil.ReadAll(cr)
+			Expect(err).ToNot(HaveOccurred())
+			prefixes, err := parseIPPrefixes(value)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(prefixes).To(HaveLen(1))
+			Expect(prefixes[0].Prefix.String()).To(Equal("192.0.2.1/32"))
+		})
+
+		It("waits for address assignments", func() {
+			prefixChan := make(chan []IPPrefix, 1)
+			go func() {
+				defer GinkgoRecover()
+				prefixes, err := tunnel.AssignedAddresses(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				prefixChan <- prefixes
+			}()
+			Consistently(prefixChan).ShouldNot(Receive())
+			buf := &bytes.Buffer{}
+			appendIPPrefixes(buf, []IPPrefix{{RequestID: 42, Prefix: prefix4}})
+			Expect(WriteCapsule(strIn, capsuleTypeAddressAssign, buf.Bytes())).To(Succeed())
+			var prefixes []IPPrefix
+			Eventually(prefixChan).Should(Receive(&prefixes))
+			Expect(prefixes).To(HaveLen(1))
+			Expect(prefixes[0].RequestID).To(BeEquivalentTo(42))
+		})
+
+		It("receives empty route advertisements", func() {
+			Expect(WriteCapsule(strIn, capsuleTypeRouteAdvertisement, nil)).To(Succeed())
+			routes, err := tunnel.Routes(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(routes).To(BeEmpty())
+		})
+
+		It("skips unknown capsules", func() {
+			Expect(WriteCapsule(strIn, 0x1337, []byte("foobar"))).To(Succeed())
+			Expect(WriteCapsule(strIn, capsuleTypeRouteAdvertisement, nil)).To(Succeed())
+			_, err := tunnel.Routes(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("aborts the tunnel on malformed capsules", func() {
+			done := make(chan struct{})
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorDatagramError))
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(errorDatagramError)).Do(func(quic.StreamErrorCode) { close(done) })
+			Expect(WriteCapsule(strIn, capsuleTypeAddressAssign, []byte{0, 5})).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			_, err := tunnel.ReadPacket(make([]byte, 10))
+			Expect(err).To(MatchError(ErrConnectIPClosed))
+		})
+
+		It("is closed when the peer closes the request stream", func() {
+			strIn.Close()
+			_, err := tunnel.ReadPacket(make([]byte, 10))
+			Expect(err).To(MatchError(ErrConnectIPClosed))
+			Expect(tunnel.WritePacket([]byte("foobar"))).To(MatchError(ErrConnectIPClosed))
+			_, err = tunnel.Routes(context.Background())
+			Expect(err).To(MatchError(ErrConnectIPClosed))
+		})
+
+		It("closes the request stream", func() {
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+			str.EXPECT().Close()
+			Expect(tunnel.Close()).To(Succeed())
+			_, err := tunnel.ReadPacket(make([]byte, 10))
+			Expect(err).To(MatchError(ErrConnectIPClosed))
+		})
+	})
+
+	It("errors when dialing without HTTP datagrams", func() {
+		_, _, err := (&RoundTripper{}).DialConnectIP(context.Background(), "https://proxy.example.org"+DefaultConnectIPTemplate, ConnectIPRequest{})
+		Expect(err).To(MatchError("http3: HTTP datagrams not enabled"))
+	})
+})
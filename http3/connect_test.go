@@ -0,0 +1,109 @@
+package http3
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	"github.com/golang/mock/gomock"
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CONNECT", func() {
+	var (
+		str    *mockquic.MockStream
+		strBuf *bytes.Buffer
+		rw     *responseWriter
+	)
+
+	BeforeEach(func() {
+		strBuf = &bytes.Buffer{}
+		str = mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().Write(gomock.Any()).DoAndReturn(strBuf.Write).AnyTimes()
+		rw = newResponseWriter(str, nil, utils.DefaultLogger)
+	})
+
+	newConnectRequest := func(body io.Reader) *http.Request {
+		req, err := http.NewRequest(http.MethodConnect, "https://proxy.example.org", body)
+		Expect(err).ToNot(HaveOccurred())
+		req.Proto = "HTTP/3"
+		return req
+	}
+
+	It("rejects requests that are not CONNECT requests", func() {
+		req := newConnectRequest(nil)
+		req.Proto = protocolConnectUDP
+		_, err := UpgradeConnect(rw, req)
+		Expect(err).To(MatchError("http3: not a CONNECT request"))
+		_, err = UpgradeConnect(rw, httptest.NewRequest(http.MethodGet, "https://example.org", nil))
+		Expect(err).To(MatchError("http3: not a CONNECT request"))
+	})
+
+	It("requires the http3 ResponseWriter", func() {
+		_, err := UpgradeConnect(httptest.NewRecorder(), newConnectRequest(nil))
+		Expect(err).To(MatchError("http3: CONNECT requires the http3 ResponseWriter"))
+	})
+
+	It("unwraps ResponseWriters added by middlewares", func() {
+		_, err := UpgradeConnect(&wrappedResponseWriter{ResponseWriter: rw}, newConnectRequest(nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rw.usedDataStream()).To(BeTrue())
+	})
+
+	It("sends the response and takes over the stream", func() {
+		rw.Header().Set("foo", "bar")
+		tunnel, err := UpgradeConnect(rw, newConnectRequest(nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rw.usedDataStream()).To(BeTrue())
+		frame, err := parseNextFrame(strBuf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(BeAssignableToTypeOf(&headersFrame{}))
+		data := make([]byte, frame.(*headersFrame).Length)
+		_, err = io.ReadFull(strBuf, data)
+		Expect(err).ToNot(HaveOccurred())
+		hfs, err := qpack.NewDecoder(nil).DecodeFull(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(ContainElement(qpack.HeaderField{Name: ":status", Value: "200"}))
+		Expect(hfs).To(ContainElement(qpack.HeaderField{Name: "foo", Value: "bar"}))
+
+		n, err := tunnel.Write([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(6))
+		frame, err = parseNextFrame(strBuf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(Equal(&dataFrame{Length: 6}))
+		Expect(strBuf.String()).To(Equal("foobar"))
+	})
+
+	It("reads from the request body", func() {
+		tunnel, err := UpgradeConnect(rw, newConnectRequest(bytes.NewReader([]byte("lorem ipsum"))))
+		Expect(err).ToNot(HaveOccurred())
+		data, err := io.ReadAll(tunnel)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("lorem ipsum")))
+	})
+
+	It("closes", func() {
+		tunnel, err := UpgradeConnect(rw, newConnectRequest(nil))
+		Expect(err).ToNot(HaveOccurred())
+		str.EXPECT().Close()
+		Expect(tunnel.CloseWrite()).To(Succeed())
+		str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+		str.EXPECT().Close()
+		Expect(tunnel.Close()).To(Succeed())
+	})
+})
+
+type wrappedResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *wrappedResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,312 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// UDP proxying, as defined in RFC 9298.
+const (
+	// DefaultConnectUDPTemplate is the default URI template path for UDP proxying (RFC 9298, Section 3).
+	DefaultConnectUDPTemplate = "/.well-known/masque/udp/{target_host}/{target_port}/"
+
+	// The :protocol used for UDP proxying requests.
+	protocolConnectUDP = "connect-udp"
+
+	// The context ID of HTTP datagrams carrying UDP payloads.
+	connectUDPContextID = 0
+
+	// the maximum number of UDP payloads waiting to be read
+	maxConnectUDPQueueLen = 128
+	// the maximum size of a UDP payload
+	maxUDPPayloadSize = 1 << 16
+)
+
+// expandConnectUDPTemplate expands a URI template, as used by UDP proxying requests.
+func expandConnectUDPTemplate(template, host, port string) string {
+	return expandURITemplate(template, map[string]string{"target_host": host, "target_port": port})
+}
+
+// matchConnectUDPTemplate matches a request URL against a URI template path,
+// and returns the target host and port.
+func matchConnectUDPTemplate(template string, u *url.URL) (host, port string, _ error) {
+	vars, err := matchURITemplate(template, u)
+	if err != nil {
+		return "", "", err
+	}
+	host, port = vars["target_host"], vars["target_port"]
+	if host == "" {
+		return "", "", errors.New("missing target host")
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return "", "", fmt.Errorf("invalid target port: %q", port)
+	}
+	return host, port, nil
+}
+
+// ConnectUDPProxy is a http.Handler that proxies UDP, as defined in RFC 9298.
+// UDP payloads are sent to the target in HTTP datagrams,
+// so HTTP datagrams need to be enabled on the Server.
+// The handler returns when the client closes the request stream.
+type ConnectUDPProxy struct {
+	// Template is the path (and query) of the URI template used to determine the target.
+	// If empty, DefaultConnectUDPTemplate is used.
+	Template string
+
+	// Dial is used to create the UDP socket used to communicate with the target.
+	// If nil, net.Dialer.DialContext is used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+var _ http.Handler = &ConnectUDPProxy{}
+
+func (p *ConnectUDPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect || r.Proto != protocolConnectUDP {
+		http.Error(w, "expected a connect-udp request", http.StatusBadRequest)
+		return
+	}
+	template := p.Template
+	if template == "" {
+		template = DefaultConnectUDPTemplate
+	}
+	host, port, err := matchConnectUDPTemplate(template, r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rw, ok := findResponseWriter(w)
+	if !ok || rw.datagrams == nil {
+		http.Error(w, "HTTP datagrams not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	dial := p.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(r.Context(), "udp", net.JoinHostPort(host, port))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+
+	rw.Header().Set("Capsule-Protocol", "?1")
+	rw.WriteHeader(http.StatusOK)
+	str := rw.DataStream()
+	id := str.StreamID()
+
+	forward := func(b []byte) {
+		r := bytes.NewReader(b)
+		contextID, err := quicvarint.Read(r)
+		if err != nil || contextID != connectUDPContextID {
+			return
+		}
+		conn.Write(b[len(b)-r.Len():])
+	}
+	rw.datagrams.register(id, forward)
+	defer rw.datagrams.unregister(id)
+
+	go func() {
+		b := make([]byte, maxUDPPayloadSize)
+		for {
+			n, err := conn.Read(b[1:])
+			if err != nil {
+				return
+			}
+			// the first byte encodes the context ID
+			if err := rw.datagrams.send(id, b[:n+1]); err != nil {
+				// The payload might be too large to fit into a QUIC DATAGRAM frame.
+				// Send it in a DATAGRAM capsule on the request stream instead.
+				if err := WriteCapsule(str, CapsuleTypeDatagram, b[:n+1]); err != nil {
+					rw.logger.Debugf("forwarding UDP payload on stream %d failed: %s", id, err)
+				}
+			}
+		}
+	}()
+
+	// UDP payloads can also be sent in DATAGRAM capsules on the request stream.
+	// The tunnel is closed when the client closes the request stream.
+	cr := NewCapsuleReader(str)
+	cr.Handle(CapsuleTypeDatagram, func(v io.Reader) error {
+		b, err := ioutil.ReadAll(io.LimitReader(v, maxUDPPayloadSize))
+		if err != nil {
+			return err
+		}
+		forward(b)
+		return nil
+	})
+	for {
+		if _, err := cr.ReadCapsule(); err != nil {
+			break
+		}
+	}
+	str.Close()
+}
+
+// connectUDPAddr is the address of the target of a UDP proxying request.
+type connectUDPAddr string
+
+func (a connectUDPAddr) Network() string { return "udp" }
+func (a connectUDPAddr) String() string  { return string(a) }
+
+// connectUDPConn is a net.PacketConn that proxies UDP payloads using HTTP datagrams.
+type connectUDPConn struct {
+	str       quic.Stream
+	datagrams *datagramDemuxer
+
+	localAddr  net.Addr
+	remoteAddr net.Addr
+
+	received  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	deadlineMutex   sync.Mutex
+	readDeadline    time.Time
+	deadlineChanged chan struct{}
+}
+
+var _ net.PacketConn = &connectUDPConn{}
+
+func newConnectUDPConn(str quic.Stream, datagrams *datagramDemuxer, localAddr, remoteAddr net.Addr) *connectUDPConn {
+	c := &connectUDPConn{
+		str:             str,
+		datagrams:       datagrams,
+		localAddr:       localAddr,
+		remoteAddr:      remoteAddr,
+		received:        make(chan []byte, maxConnectUDPQueueLen),
+		closed:          make(chan struct{}),
+		deadlineChanged: make(chan struct{}, 1),
+	}
+	datagrams.register(str.StreamID(), c.handleDatagram)
+	go c.readCapsules()
+	return c
+}
+
+func (c *connectUDPConn) handleDatagram(b []byte) {
+	r := bytes.NewReader(b)
+	contextID, err := quicvarint.Read(r)
+	if err != nil || contextID != connectUDPContextID {
+		return
+	}
+	select {
+	case c.received <- b[len(b)-r.Len():]:
+	default: // drop the payload
+	}
+}
+
+// readCapsules reads capsules from the request stream.
+// The proxy closes the tunnel by closing the request stream.
+func (c *connectUDPConn) readCapsules() {
+	defer c.close()
+
+	r := NewCapsuleReader(c.str)
+	r.Handle(CapsuleTypeDatagram, func(v io.Reader) error {
+		b, err := ioutil.ReadAll(io.LimitReader(v, maxUDPPayloadSize))
+		if err != nil {
+			return err
+		}
+		c.handleDatagram(b)
+		return nil
+	})
+	for {
+		if _, err := r.ReadCapsule(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *connectUDPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		c.deadlineMutex.Lock()
+		deadline := c.readDeadline
+		c.deadlineMutex.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timer = time.NewTimer(time.Until(deadline))
+			timeout = timer.C
+		}
+		select {
+		case p := <-c.received:
+			return copy(b, p), c.remoteAddr, nil
+		case <-c.closed:
+			return 0, nil, net.ErrClosed
+		case <-timeout:
+			return 0, nil, os.ErrDeadlineExceeded
+		case <-c.deadlineChanged:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (c *connectUDPConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	buf := &bytes.Buffer{}
+	quicvarint.Write(buf, connectUDPContextID)
+	buf.Write(b)
+	if err := c.datagrams.send(c.str.StreamID(), buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *connectUDPConn) close() {
+	c.closeOnce.Do(func() {
+		c.datagrams.unregister(c.str.StreamID())
+		close(c.closed)
+	})
+}
+
+// Close closes the tunnel, by closing the request stream.
+func (c *connectUDPConn) Close() error {
+	c.str.CancelRead(quic.StreamErrorCode(errorNoError))
+	err := c.str.Close()
+	c.close()
+	return err
+}
+
+func (c *connectUDPConn) LocalAddr() net.Addr { return c.localAddr }
+
+// RemoteAddr returns the address of the target.
+func (c *connectUDPConn) RemoteAddr() net.Addr { return c.remoteAddr }
+
+func (c *connectUDPConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *connectUDPConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMutex.Lock()
+	c.readDeadline = t
+	c.deadlineMutex.Unlock()
+	select {
+	case c.deadlineChanged <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetWriteDeadline is a no-op, since writing never blocks.
+func (c *connectUDPConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,180 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CONNECT-UDP", func() {
+	Context("URI templates", func() {
+		It("expands the default template", func() {
+			Expect(expandConnectUDPTemplate("https://proxy.example.org"+DefaultConnectUDPTemplate, "192.0.2.6", "443")).
+				To(Equal("https://proxy.example.org/.well-known/masque/udp/192.0.2.6/443/"))
+		})
+
+		It("escapes IPv6 addresses", func() {
+			Expect(expandConnectUDPTemplate("https://proxy.example.org"+DefaultConnectUDPTemplate, "2001:db8::42", "443")).
+				To(Equal("https://proxy.example.org/.well-known/masque/udp/2001%3Adb8%3A%3A42/443/"))
+		})
+
+		It("matches the default template", func() {
+			u, err := url.Parse("https://proxy.example.org/.well-known/masque/udp/2001%3Adb8%3A%3A42/443/")
+			Expect(err).ToNot(HaveOccurred())
+			host, port, err := matchConnectUDPTemplate(DefaultConnectUDPTemplate, u)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(host).To(Equal("2001:db8::42"))
+			Expect(port).To(Equal("443"))
+		})
+
+		It("matches templates using query parameters", func() {
+			const template = "/masque?h={target_host}&p={target_port}"
+			u, err := url.Parse(expandConnectUDPTemplate("https://proxy.example.org"+template, "example.com", "53"))
+			Expect(err).ToNot(HaveOccurred())
+			host, port, err := matchConnectUDPTemplate(template, u)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(host).To(Equal("example.com"))
+			Expect(port).To(Equal("53"))
+		})
+
+		It("rejects paths that don't match the template", func() {
+			u, err := url.Parse("https://proxy.example.org/.well-known/masque/ip/192.0.2.6/443/")
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = matchConnectUDPTemplate(DefaultConnectUDPTemplate, u)
+			Expect(err).To(MatchError("path doesn't match the template"))
+		})
+
+		It("rejects invalid ports", func() {
+			u, err := url.Parse("https://proxy.example.org/.well-known/masque/udp/192.0.2.6/99999/")
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = matchConnectUDPTemplate(DefaultConnectUDPTemplate, u)
+			Expect(err).To(MatchError(`invalid target port: "99999"`))
+		})
+	})
+
+	Context("proxy", func() {
+		It("rejects requests that are not UDP proxying requests", func() {
+			req := httptest.NewRequest(http.MethodGet, "https://proxy.example.org/.well-known/masque/udp/192.0.2.6/443/", nil)
+			w := httptest.NewRecorder()
+			(&ConnectUDPProxy{}).ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects requests if HTTP datagrams are disabled", func() {
+			req, err := http.NewRequest(http.MethodConnect, "https://proxy.example.org/.well-known/masque/udp/192.0.2.6/443/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Proto = protocolConnectUDP
+			w := httptest.NewRecorder()
+			(&ConnectUDPProxy{}).ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusNotImplemented))
+		})
+	})
+
+	Context("tunnel", func() {
+		var (
+			conn      *mockquic.MockEarlyConnection
+			str       *mockquic.MockStream
+			strClosed chan struct{}
+			pconn     *connectUDPConn
+		)
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}).AnyTimes()
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(8)).AnyTimes()
+			closed := make(chan struct{})
+			strClosed = closed
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+				<-closed
+				return 0, io.EOF
+			}).AnyTimes()
+			datagrams := newDatagramDemuxer(conn, utils.DefaultLogger)
+			datagrams.setPeerEnabled(true)
+			pconn = newConnectUDPConn(str, datagrams, nil, connectUDPAddr("192.0.2.6:443"))
+		})
+
+		AfterEach(func() {
+			select {
+			case <-strClosed:
+			default:
+				close(strClosed)
+			}
+		})
+
+		It("sends UDP payloads", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, 8/4)
+			quicvarint.Write(buf, connectUDPContextID)
+			buf.WriteString("foobar")
+			conn.EXPECT().SendMessage(buf.Bytes())
+			n, err := pconn.WriteTo([]byte("foobar"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(6))
+		})
+
+		It("receives UDP payloads", func() {
+			pconn.handleDatagram([]byte{0x1, 'f', 'o', 'o'}) // unknown context ID
+			pconn.handleDatagram([]byte{0x0, 'b', 'a', 'r'})
+			b := make([]byte, 10)
+			n, addr, err := pconn.ReadFrom(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(b[:n])).To(Equal("bar"))
+			Expect(addr.String()).To(Equal("192.0.2.6:443"))
+		})
+
+		It("times out reading", func() {
+			Expect(pconn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))).To(Succeed())
+			_, _, err := pconn.ReadFrom(make([]byte, 10))
+			Expect(err).To(MatchError(os.ErrDeadlineExceeded))
+		})
+
+		It("applies deadlines set while reading", func() {
+			errChan := make(chan error, 1)
+			go func() {
+				_, _, err := pconn.ReadFrom(make([]byte, 10))
+				errChan <- err
+			}()
+			Consistently(errChan).ShouldNot(Receive())
+			Expect(pconn.SetDeadline(time.Now().Add(-time.Second))).To(Succeed())
+			Eventually(errChan).Should(Receive(MatchError(os.ErrDeadlineExceeded)))
+		})
+
+		It("is closed when the proxy closes the request stream", func() {
+			close(strClosed)
+			_, _, err := pconn.ReadFrom(make([]byte, 10))
+			Expect(err).To(MatchError(net.ErrClosed))
+			_, err = pconn.WriteTo([]byte("foobar"), nil)
+			Expect(err).To(MatchError(net.ErrClosed))
+		})
+
+		It("closes the request stream", func() {
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+			str.EXPECT().Close()
+			Expect(pconn.Close()).To(Succeed())
+			_, _, err := pconn.ReadFrom(make([]byte, 10))
+			Expect(err).To(MatchError(net.ErrClosed))
+		})
+	})
+
+	It("errors when dialing without HTTP datagrams", func() {
+		_, _, err := (&RoundTripper{}).DialConnectUDP(context.Background(), "https://proxy.example.org"+DefaultConnectUDPTemplate, "192.0.2.6:443")
+		Expect(err).To(MatchError("http3: HTTP datagrams not enabled"))
+	})
+})
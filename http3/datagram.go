@@ -0,0 +1,199 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// The Quarter Stream ID of an HTTP datagram is at most 2^60-1 (RFC 9297, Section 2.1).
+const maxQuarterStreamID = 1<<60 - 1
+
+// ErrDatagramStreamClosed is returned when using a DatagramStream that was closed.
+var ErrDatagramStreamClosed = errors.New("http3: datagram stream closed")
+
+// datagramDemuxer associates HTTP datagrams with request streams.
+// Datagrams are prefixed with the quarter stream ID of the request stream.
+type datagramDemuxer struct {
+	conn   quic.Connection
+	logger utils.Logger
+
+	mutex    sync.Mutex
+	handlers map[quic.StreamID]func([]byte)
+	// set when the peer's SETTINGS were received
+	receivedSettings bool
+	peerEnabled      bool
+
+	receiveOnce sync.Once
+}
+
+func newDatagramDemuxer(conn quic.Connection, logger utils.Logger) *datagramDemuxer {
+	return &datagramDemuxer{
+		conn:     conn,
+		logger:   logger,
+		handlers: make(map[quic.StreamID]func([]byte)),
+	}
+}
+
+// setPeerEnabled is called when the peer's SETTINGS frame is received.
+// HTTP datagrams can only be sent if the peer sent SETTINGS_H3_DATAGRAM.
+func (d *datagramDemuxer) setPeerEnabled(enabled bool) {
+	d.mutex.Lock()
+	d.receivedSettings = true
+	d.peerEnabled = enabled
+	d.mutex.Unlock()
+}
+
+// register registers a handler for datagrams associated with the request stream id.
+// The handler is called on the Go routine that receives datagrams, so it must not block.
+// Datagrams are only received if the QUIC datagram extension was negotiated.
+func (d *datagramDemuxer) register(id quic.StreamID, handler func([]byte)) {
+	d.mutex.Lock()
+	d.handlers[id] = handler
+	d.mutex.Unlock()
+
+	if d.conn.ConnectionState().SupportsDatagrams {
+		d.receiveOnce.Do(func() { go d.receiveDatagrams() })
+	}
+}
+
+func (d *datagramDemuxer) unregister(id quic.StreamID) {
+	d.mutex.Lock()
+	delete(d.handlers, id)
+	d.mutex.Unlock()
+}
+
+// send sends a datagram associated with the request stream id.
+func (d *datagramDemuxer) send(id quic.StreamID, b []byte) error {
+	d.mutex.Lock()
+	receivedSettings, peerEnabled := d.receivedSettings, d.peerEnabled
+	d.mutex.Unlock()
+	if !receivedSettings {
+		return errors.New("http3: can't send HTTP datagrams before receiving the peer's SETTINGS")
+	}
+	if !peerEnabled {
+		return errors.New("http3: peer didn't enable HTTP datagrams")
+	}
+
+	buf := &bytes.Buffer{}
+	quicvarint.Write(buf, uint64(id)/4)
+	buf.Write(b)
+	return d.conn.SendMessage(buf.Bytes())
+}
+
+// receiveDatagrams reads datagrams from the connection and passes them to the handler of the request stream.
+// Datagrams for unknown request streams are dropped.
+func (d *datagramDemuxer) receiveDatagrams() {
+	for {
+		b, err := d.conn.ReceiveMessage()
+		if err != nil {
+			d.logger.Debugf("receiving datagrams failed: %s", err)
+			return
+		}
+		r := bytes.NewReader(b)
+		quarterStreamID, err := quicvarint.Read(r)
+		if err != nil || quarterStreamID > maxQuarterStreamID {
+			d.conn.CloseWithError(quic.ApplicationErrorCode(errorDatagramError), "invalid quarter stream ID")
+			return
+		}
+		d.mutex.Lock()
+		handler, ok := d.handlers[quic.StreamID(quarterStreamID*4)]
+		d.mutex.Unlock()
+		if !ok {
+			continue
+		}
+		handler(b[len(b)-r.Len():])
+	}
+}
+
+// streamDatagrams sends and receives the HTTP datagrams associated with a request stream.
+// It implements the Datagrammer.
+type streamDatagrams struct {
+	id        quic.StreamID
+	datagrams *datagramDemuxer
+	received  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newStreamDatagrams(id quic.StreamID, datagrams *datagramDemuxer) *streamDatagrams {
+	s := &streamDatagrams{
+		id:        id,
+		datagrams: datagrams,
+		received:  make(chan []byte, maxDatagramQueueLen),
+		closed:    make(chan struct{}),
+	}
+	datagrams.register(id, func(b []byte) {
+		select {
+		case s.received <- b:
+		default: // drop the datagram
+		}
+	})
+	return s
+}
+
+func (s *streamDatagrams) SendDatagram(b []byte) error {
+	select {
+	case <-s.closed:
+		return ErrDatagramStreamClosed
+	default:
+	}
+	return s.datagrams.send(s.id, b)
+}
+
+func (s *streamDatagrams) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-s.received:
+		return b, nil
+	case <-s.closed:
+		return nil, ErrDatagramStreamClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *streamDatagrams) close() {
+	s.closeOnce.Do(func() {
+		s.datagrams.unregister(s.id)
+		close(s.closed)
+	})
+}
+
+// A DatagramStream is a request stream, together with the HTTP datagrams associated with it (RFC 9297).
+// Reading from and writing to the stream is used to exchange the request and response body,
+// or capsules when using the Capsule Protocol.
+type DatagramStream struct {
+	quic.Stream
+
+	datagrams *streamDatagrams
+}
+
+func newDatagramStream(str quic.Stream, datagrams *datagramDemuxer) *DatagramStream {
+	return &DatagramStream{
+		Stream:    str,
+		datagrams: newStreamDatagrams(str.StreamID(), datagrams),
+	}
+}
+
+// SendDatagram sends an HTTP datagram associated with the stream.
+// Datagrams are sent unreliably, and are dropped if they are too large to fit into a QUIC packet.
+func (s *DatagramStream) SendDatagram(b []byte) error {
+	return s.datagrams.SendDatagram(b)
+}
+
+// ReceiveDatagram receives an HTTP datagram associated with the stream.
+func (s *DatagramStream) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	return s.datagrams.ReceiveDatagram(ctx)
+}
+
+// Close closes the send direction of the stream.
+// No more datagrams can be sent or received after calling Close.
+func (s *DatagramStream) Close() error {
+	s.datagrams.close()
+	return s.Stream.Close()
+}
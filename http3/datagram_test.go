@@ -0,0 +1,244 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTP Datagrams", func() {
+	var (
+		conn      *mockquic.MockEarlyConnection
+		datagrams *datagramDemuxer
+		received  chan []byte
+	)
+
+	getDatagram := func(quarterStreamID uint64, data string) []byte {
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, quarterStreamID)
+		buf.WriteString(data)
+		return buf.Bytes()
+	}
+
+	BeforeEach(func() {
+		conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		conn.EXPECT().ConnectionState().Return(quic.ConnectionState{SupportsDatagrams: true}).AnyTimes()
+		datagramChan := make(chan []byte, 10)
+		received = datagramChan
+		conn.EXPECT().ReceiveMessage().DoAndReturn(func() ([]byte, error) {
+			b, ok := <-datagramChan
+			if !ok {
+				return nil, io.EOF
+			}
+			return b, nil
+		}).AnyTimes()
+		datagrams = newDatagramDemuxer(conn, utils.DefaultLogger)
+	})
+
+	AfterEach(func() { close(received) })
+
+	Context("sending", func() {
+		It("prefixes datagrams with the quarter stream ID", func() {
+			datagrams.setPeerEnabled(true)
+			conn.EXPECT().SendMessage(getDatagram(1337, "foobar"))
+			Expect(datagrams.send(4*1337, []byte("foobar"))).To(Succeed())
+		})
+
+		It("doesn't send datagrams before receiving the peer's SETTINGS", func() {
+			Expect(datagrams.send(4, []byte("foobar"))).To(MatchError("http3: can't send HTTP datagrams before receiving the peer's SETTINGS"))
+		})
+
+		It("doesn't send datagrams if the peer didn't enable them", func() {
+			datagrams.setPeerEnabled(false)
+			Expect(datagrams.send(4, []byte("foobar"))).To(MatchError("http3: peer didn't enable HTTP datagrams"))
+		})
+	})
+
+	Context("receiving", func() {
+		It("passes datagrams to the handler of the request stream", func() {
+			handled := make(chan []byte, 10)
+			datagrams.register(8, func(b []byte) { handled <- b })
+			received <- getDatagram(1, "foo") // unknown request stream
+			received <- getDatagram(2, "bar")
+			Eventually(handled).Should(Receive(Equal([]byte("bar"))))
+			Consistently(handled).ShouldNot(Receive())
+		})
+
+		It("doesn't pass datagrams to unregistered handlers", func() {
+			handled := make(chan []byte, 10)
+			datagrams.register(8, func(b []byte) { handled <- b })
+			datagrams.unregister(8)
+			received <- getDatagram(2, "bar")
+			Consistently(handled).ShouldNot(Receive())
+		})
+
+		It("closes the connection when receiving a datagram with an invalid quarter stream ID", func() {
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorDatagramError), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) { close(done) })
+			datagrams.register(8, func([]byte) {})
+			received <- getDatagram(maxQuarterStreamID+1, "foobar")
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("datagram streams", func() {
+		var (
+			str *mockquic.MockStream
+			s   *DatagramStream
+		)
+
+		BeforeEach(func() {
+			datagrams.setPeerEnabled(true)
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			s = newDatagramStream(str, datagrams)
+		})
+
+		It("sends datagrams", func() {
+			conn.EXPECT().SendMessage(getDatagram(1, "foobar"))
+			Expect(s.SendDatagram([]byte("foobar"))).To(Succeed())
+		})
+
+		It("receives datagrams", func() {
+			received <- getDatagram(1, "foobar")
+			b, err := s.ReceiveDatagram(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foobar")))
+		})
+
+		It("stops receiving when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := s.ReceiveDatagram(ctx)
+			Expect(err).To(MatchError(context.Canceled))
+		})
+
+		It("closes", func() {
+			str.EXPECT().Close()
+			Expect(s.Close()).To(Succeed())
+			Expect(s.SendDatagram([]byte("foobar"))).To(MatchError(ErrDatagramStreamClosed))
+			_, err := s.ReceiveDatagram(context.Background())
+			Expect(err).To(MatchError(ErrDatagramStreamClosed))
+		})
+	})
+
+	Context("handlers", func() {
+		var (
+			str *mockquic.MockStream
+			rw  *responseWriter
+		)
+
+		BeforeEach(func() {
+			datagrams.setPeerEnabled(true)
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			rw = newResponseWriter(str, conn, utils.DefaultLogger)
+			rw.datagrams = datagrams
+		})
+
+		It("sends and receives datagrams", func() {
+			conn.EXPECT().SendMessage(getDatagram(1, "foo"))
+			Expect(rw.SendDatagram([]byte("foo"))).To(Succeed())
+			received <- getDatagram(1, "bar")
+			b, err := rw.ReceiveDatagram(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("bar")))
+		})
+
+		It("stops when the handler returns", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := rw.ReceiveDatagram(ctx)
+			Expect(err).To(MatchError(context.Canceled))
+			rw.closeDatagrams()
+			Expect(rw.SendDatagram([]byte("foobar"))).To(MatchError(ErrDatagramStreamClosed))
+			_, err = rw.ReceiveDatagram(context.Background())
+			Expect(err).To(MatchError(ErrDatagramStreamClosed))
+		})
+
+		It("hands over received datagrams to the DatagramStream", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := rw.ReceiveDatagram(ctx) // start receiving
+			Expect(err).To(MatchError(context.Canceled))
+			received <- getDatagram(1, "foobar")
+			Eventually(func() int { return len(rw.streamDatagrams.received) }).Should(Equal(1))
+			s, err := rw.DatagramStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rw.SendDatagram([]byte("foobar"))).To(MatchError(ErrDatagramStreamClosed))
+			rw.closeDatagrams() // the handler returns
+			b, err := s.ReceiveDatagram(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foobar")))
+		})
+
+		It("errors if HTTP datagrams are disabled", func() {
+			rw.datagrams = nil
+			Expect(rw.SendDatagram([]byte("foobar"))).To(MatchError("http3: HTTP datagrams not enabled"))
+			_, err := rw.ReceiveDatagram(context.Background())
+			Expect(err).To(MatchError("http3: HTTP datagrams not enabled"))
+		})
+	})
+
+	Context("responses", func() {
+		var (
+			str  *mockquic.MockStream
+			body *hijackableBody
+			rsp  *http.Response
+		)
+
+		BeforeEach(func() {
+			datagrams.setPeerEnabled(true)
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			body = newResponseBody(str, conn, make(chan struct{}), func() {})
+			body.datagrams = datagrams
+			body.onRequestDone = body.closeDatagrams
+			rsp = &http.Response{Body: body}
+		})
+
+		It("sends and receives datagrams", func() {
+			d, err := ResponseDatagrams(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			conn.EXPECT().SendMessage(getDatagram(1, "foo"))
+			Expect(d.SendDatagram([]byte("foo"))).To(Succeed())
+			received <- getDatagram(1, "bar")
+			b, err := d.ReceiveDatagram(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("bar")))
+			// the same Datagrammer is returned every time
+			d2, err := ResponseDatagrams(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d2).To(BeIdenticalTo(d))
+		})
+
+		It("stops when the body is closed", func() {
+			d, err := ResponseDatagrams(rsp)
+			Expect(err).ToNot(HaveOccurred())
+			str.EXPECT().CancelRead(gomock.Any())
+			Expect(rsp.Body.Close()).To(Succeed())
+			Expect(d.SendDatagram([]byte("foobar"))).To(MatchError(ErrDatagramStreamClosed))
+			_, err = ResponseDatagrams(rsp)
+			Expect(err).To(MatchError(ErrDatagramStreamClosed))
+		})
+
+		It("errors if HTTP datagrams are disabled", func() {
+			body.datagrams = nil
+			_, err := ResponseDatagrams(rsp)
+			Expect(err).To(MatchError("http3: HTTP datagrams not enabled"))
+			_, err = ResponseDatagrams(&http.Response{Body: http.NoBody})
+			Expect(err).To(MatchError("http3: response doesn't support HTTP datagrams"))
+		})
+	})
+})
@@ -0,0 +1,167 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// defaultConnectionAttemptDelay is the default value of RoundTripper.ConnectionAttemptDelay (RFC 8305, Section 5).
+const defaultConnectionAttemptDelay = 250 * time.Millisecond
+
+// An AddrError is the error that occurred when dialing one of the addresses of a host.
+type AddrError struct {
+	Addr string // ip:port
+	Err  error
+}
+
+// A DialError is returned when the QUIC connection couldn't be established to any of the addresses of a host.
+type DialError struct {
+	Host string
+	// Errors contains an error for every address that was dialed, in the order the attempts failed.
+	Errors []AddrError
+}
+
+func (e *DialError) Error() string {
+	errs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, fmt.Sprintf("%s: %s", err.Addr, err.Err))
+	}
+	return fmt.Sprintf("http3: dialing %s failed: %s", e.Host, strings.Join(errs, "; "))
+}
+
+// Unwrap returns the error that occurred when dialing the first address.
+func (e *DialError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[0].Err
+}
+
+// dialPacketConn returns a dial function that establishes QUIC connections using pconn.
+func dialPacketConn(pconn net.PacketConn) dialFunc {
+	return func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+		udpAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return quic.DialEarlyContext(ctx, pconn, udpAddr, addr, tlsConf, conf)
+	}
+}
+
+// dialResolved returns a dial function that resolves the host using lookup,
+// and races connection attempts to all of its addresses.
+// A new attempt is started every delay, or as soon as the previous attempt failed.
+func dialResolved(lookup func(context.Context, string) ([]net.IPAddr, error), delay time.Duration, dial dialFunc) dialFunc {
+	if dial == nil {
+		dial = dialAddr
+	}
+	return func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dial(ctx, addr, tlsConf, conf)
+		}
+		ips, err := lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("http3: no addresses found for %s", host)
+		}
+		// The certificate is validated for the host, not for the IP address.
+		if tlsConf.ServerName == "" {
+			tlsConf = tlsConf.Clone()
+			tlsConf.ServerName = host
+		}
+		addrs := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+		}
+		return raceDial(ctx, host, addrs, delay, func(ctx context.Context, addr string) (quic.EarlyConnection, error) {
+			return dial(ctx, addr, tlsConf, conf)
+		})
+	}
+}
+
+// raceDial dials the addresses in order, starting a new attempt every delay,
+// or as soon as the previous attempt failed.
+// It returns the first connection that was established, and cancels all other attempts.
+func raceDial(ctx context.Context, host string, addrs []string, delay time.Duration, dial func(context.Context, string) (quic.EarlyConnection, error)) (quic.EarlyConnection, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		addr string
+		conn quic.EarlyConnection
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	var next, running int
+	startAttempt := func() {
+		addr := addrs[next]
+		next++
+		running++
+		go func() {
+			conn, err := dial(ctx, addr)
+			results <- result{addr: addr, conn: conn, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	resetTimer := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(delay)
+	}
+
+	dialErr := &DialError{Host: host}
+	startAttempt()
+	for running > 0 {
+		select {
+		case <-timer.C:
+			if next < len(addrs) && ctx.Err() == nil {
+				startAttempt()
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			running--
+			if r.err == nil {
+				// Attempts that are still running are canceled.
+				// Close the connections of attempts that succeeded nevertheless.
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if r := <-results; r.conn != nil {
+							r.conn.CloseWithError(0, "")
+						}
+					}
+				}(running)
+				return r.conn, nil
+			}
+			dialErr.Errors = append(dialErr.Errors, AddrError{Addr: r.addr, Err: r.err})
+			if next < len(addrs) && ctx.Err() == nil {
+				startAttempt()
+				resetTimer()
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(dialErr.Errors) == 1 {
+		return nil, dialErr.Errors[0].Err
+	}
+	return nil, dialErr
+}
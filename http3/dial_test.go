@@ -0,0 +1,247 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dialing", func() {
+	type dialAttempt struct {
+		addr       string
+		serverName string
+		ctx        context.Context
+		result     chan error
+	}
+
+	var attempts chan *dialAttempt
+
+	lookup := func(_ context.Context, host string) ([]net.IPAddr, error) {
+		Expect(host).To(Equal("example.org"))
+		return []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}, {IP: net.IPv4(192, 0, 2, 1)}, {IP: net.IPv4(192, 0, 2, 2)}}, nil
+	}
+
+	var (
+		conn *mockquic.MockEarlyConnection
+		dial dialFunc
+	)
+
+	BeforeEach(func() {
+		attempts = make(chan *dialAttempt, 10)
+		conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		attempts := attempts
+		conn := conn
+		// dial blocks until an error (or nil, for success) is sent on the attempt's result channel,
+		// or until the attempt is canceled
+		dial = func(ctx context.Context, addr string, tlsConf *tls.Config, _ *quic.Config) (quic.EarlyConnection, error) {
+			a := &dialAttempt{addr: addr, serverName: tlsConf.ServerName, ctx: ctx, result: make(chan error, 1)}
+			attempts <- a
+			select {
+			case err := <-a.result:
+				if err != nil {
+					return nil, err
+				}
+				return conn, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	})
+
+	dialAsync := func(d dialFunc, addr string) (<-chan quic.EarlyConnection, <-chan error) {
+		connChan := make(chan quic.EarlyConnection, 1)
+		errChan := make(chan error, 1)
+		go func() {
+			c, err := d(context.Background(), addr, &tls.Config{}, nil)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			connChan <- c
+		}()
+		return connChan, errChan
+	}
+
+	It("dials the next address as soon as an attempt fails, and reports all errors", func() {
+		_, errChan := dialAsync(dialResolved(lookup, time.Hour, dial), "example.org:443")
+		for i, addr := range []string{"[2001:db8::1]:443", "192.0.2.1:443", "192.0.2.2:443"} {
+			var a *dialAttempt
+			Eventually(attempts).Should(Receive(&a))
+			Expect(a.addr).To(Equal(addr))
+			Expect(a.serverName).To(Equal("example.org"))
+			Consistently(attempts, scaleDuration(20*time.Millisecond)).ShouldNot(Receive())
+			a.result <- errors.New([]string{"first", "second", "third"}[i])
+		}
+		var err error
+		Eventually(errChan).Should(Receive(&err))
+		var dialErr *DialError
+		Expect(errors.As(err, &dialErr)).To(BeTrue())
+		Expect(dialErr.Host).To(Equal("example.org"))
+		Expect(dialErr.Errors).To(HaveLen(3))
+		Expect(dialErr.Errors[0].Addr).To(Equal("[2001:db8::1]:443"))
+		Expect(dialErr.Errors[0].Err).To(MatchError("first"))
+		Expect(dialErr.Errors[2].Addr).To(Equal("192.0.2.2:443"))
+		Expect(dialErr.Errors[2].Err).To(MatchError("third"))
+		Expect(err).To(MatchError("http3: dialing example.org failed: [2001:db8::1]:443: first; 192.0.2.1:443: second; 192.0.2.2:443: third"))
+	})
+
+	It("starts a new attempt after the delay, and cancels the other attempts", func() {
+		delay := scaleDuration(25 * time.Millisecond)
+		start := time.Now()
+		connChan, _ := dialAsync(dialResolved(lookup, delay, dial), "example.org:443")
+		var a1, a2 *dialAttempt
+		Eventually(attempts).Should(Receive(&a1))
+		Eventually(attempts).Should(Receive(&a2))
+		Expect(time.Since(start)).To(BeNumerically(">=", delay))
+		Expect(a2.addr).To(Equal("192.0.2.1:443"))
+		a2.result <- nil
+		Eventually(connChan).Should(Receive(Equal(conn)))
+		Eventually(a1.ctx.Done()).Should(BeClosed())
+	})
+
+	It("closes connections that are established after the race was decided", func() {
+		results := make(chan error, 2)
+		// this dial function doesn't return when the attempt is canceled
+		dial := func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+			if err := <-results; err != nil {
+				return nil, err
+			}
+			return conn, nil
+		}
+		connChan, _ := dialAsync(dialResolved(lookup, scaleDuration(10*time.Millisecond), dial), "example.org:443")
+		time.Sleep(scaleDuration(15 * time.Millisecond)) // wait for the second attempt to start
+		results <- nil
+		Eventually(connChan).Should(Receive())
+		closed := make(chan struct{})
+		conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) { close(closed) })
+		results <- nil
+		Eventually(closed).Should(BeClosed())
+	})
+
+	It("returns the error directly if there's only a single address", func() {
+		lookup := func(context.Context, string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.IPv4(192, 0, 2, 1)}}, nil
+		}
+		_, errChan := dialAsync(dialResolved(lookup, time.Hour, dial), "example.org:443")
+		var a *dialAttempt
+		Eventually(attempts).Should(Receive(&a))
+		a.result <- errors.New("handshake failed")
+		Eventually(errChan).Should(Receive(MatchError("handshake failed")))
+	})
+
+	It("doesn't resolve IP addresses", func() {
+		lookup := func(context.Context, string) ([]net.IPAddr, error) {
+			Fail("didn't expect a lookup")
+			return nil, nil
+		}
+		_, errChan := dialAsync(dialResolved(lookup, time.Hour, dial), "192.0.2.1:443")
+		var a *dialAttempt
+		Eventually(attempts).Should(Receive(&a))
+		Expect(a.addr).To(Equal("192.0.2.1:443"))
+		a.result <- errors.New("handshake failed")
+		Eventually(errChan).Should(Receive(MatchError("handshake failed")))
+	})
+
+	It("returns lookup errors", func() {
+		lookup := func(context.Context, string) ([]net.IPAddr, error) { return nil, errors.New("no such host") }
+		_, err := dialResolved(lookup, time.Hour, dial)(context.Background(), "example.org:443", &tls.Config{}, nil)
+		Expect(err).To(MatchError("no such host"))
+		lookup = func(context.Context, string) ([]net.IPAddr, error) { return nil, nil }
+		_, err = dialResolved(lookup, time.Hour, dial)(context.Background(), "example.org:443", &tls.Config{}, nil)
+		Expect(err).To(MatchError("http3: no addresses found for example.org"))
+	})
+
+	It("returns when the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error, 1)
+		go func() {
+			_, err := dialResolved(lookup, time.Hour, dial)(ctx, "example.org:443", &tls.Config{}, nil)
+			errChan <- err
+		}()
+		var a *dialAttempt
+		Eventually(attempts).Should(Receive(&a))
+		cancel()
+		Eventually(errChan).Should(Receive(Equal(context.Canceled)))
+		Expect(attempts).To(BeEmpty())
+	})
+
+	It("is used by the RoundTripper", func() {
+		rt := &RoundTripper{Dial: dial, LookupIPAddr: lookup, ConnectionAttemptDelay: time.Hour}
+		defer rt.Close()
+		req, err := http.NewRequest(http.MethodGet, "https://example.org/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		errChan := make(chan error, 1)
+		go func() {
+			_, err := rt.RoundTrip(req)
+			errChan <- err
+		}()
+		for i := 0; i < 3; i++ {
+			var a *dialAttempt
+			Eventually(attempts).Should(Receive(&a))
+			a.result <- errors.New("handshake failed")
+		}
+		var dialErr *DialError
+		Eventually(errChan).Should(Receive(BeAssignableToTypeOf(dialErr)))
+	})
+
+	Context("using a PacketConn", func() {
+		var server, pconn *net.UDPConn
+
+		BeforeEach(func() {
+			var err error
+			server, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			pconn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+			pconn.Close()
+		})
+
+		roundTrip := func(rt *RoundTripper) error {
+			req, err := http.NewRequest(http.MethodGet, "https://"+server.LocalAddr().String()+"/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(50*time.Millisecond))
+			defer cancel()
+			_, err = rt.RoundTrip(req.WithContext(ctx))
+			return err
+		}
+
+		It("sends the packets using the PacketConn", func() {
+			rt := &RoundTripper{PacketConn: pconn}
+			defer rt.Close()
+			Expect(roundTrip(rt)).To(HaveOccurred())
+			b := make([]byte, 2000)
+			server.SetReadDeadline(time.Now().Add(time.Second))
+			_, addr, err := server.ReadFrom(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(addr).To(Equal(pconn.LocalAddr()))
+			// the PacketConn is not closed
+			_, err = pconn.WriteTo([]byte("foobar"), server.LocalAddr())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("prefers the Dial function", func() {
+			rt := &RoundTripper{
+				PacketConn: pconn,
+				Dial: func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+					return nil, errors.New("dial error")
+				},
+			}
+			defer rt.Close()
+			Expect(roundTrip(rt)).To(MatchError("dial error"))
+		})
+	})
+})
@@ -0,0 +1,247 @@
+package doh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/http3"
+)
+
+// A Client sends DNS queries to a DoH server.
+type Client struct {
+	// URL is the URL of the DoH server, e.g. https://dns.example.com/dns-query.
+	URL string
+
+	// RoundTripper is used to send the queries.
+	// If nil, a http3.RoundTripper with the default configuration is used.
+	RoundTripper http.RoundTripper
+
+	once sync.Once
+	rt   http.RoundTripper
+}
+
+// Resolver returns a net.Resolver that sends all queries to the DoH server.
+func (c *Client) Resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial:     c.Dial,
+	}
+}
+
+// Dial can be used as the Dial function of a net.Resolver.
+// The resolver must use the Go resolver, i.e. PreferGo must be set.
+// The address is ignored, all queries are sent to the DoH server.
+func (c *Client) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	var stream bool
+	switch network {
+	case "udp", "udp4", "udp6":
+	case "tcp", "tcp4", "tcp6":
+		stream = true
+	default:
+		return nil, fmt.Errorf("doh: unsupported network: %s", network)
+	}
+	conn := &conn{ctx: ctx, client: c, stream: stream}
+	if stream {
+		return conn, nil
+	}
+	// The Go resolver uses the message framing of datagram-oriented networks only for a net.PacketConn.
+	return &packetConn{conn: conn}, nil
+}
+
+// Exchange sends a DNS query to the DoH server and returns the response.
+func (c *Client) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if len(query) < headerLen {
+		return nil, errMessageTooShort
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+	rsp, err := c.roundTripper().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh: server responded with status %d", rsp.StatusCode)
+	}
+	if ct := rsp.Header.Get("Content-Type"); !strings.HasPrefix(ct, ContentType) {
+		return nil, fmt.Errorf("doh: unexpected content type: %s", ct)
+	}
+	msg, err := io.ReadAll(io.LimitReader(rsp.Body, maxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) > maxMessageSize {
+		return nil, errors.New("doh: response too large")
+	}
+	if len(msg) < headerLen {
+		return nil, errMessageTooShort
+	}
+	return msg, nil
+}
+
+func (c *Client) roundTripper() http.RoundTripper {
+	if c.RoundTripper != nil {
+		return c.RoundTripper
+	}
+	c.once.Do(func() { c.rt = &http3.RoundTripper{} })
+	return c.rt
+}
+
+// conn is the net.Conn returned by Client.Dial.
+// Every query written to the conn is sent to the DoH server, and the response can then be read from the conn.
+// For stream-oriented networks, messages are prefixed with their length (RFC 1035, Section 4.2.2).
+type conn struct {
+	ctx    context.Context
+	client *Client
+	stream bool
+
+	mutex    sync.Mutex
+	deadline time.Time
+	closed   bool
+	query    []byte // the partially written query, for stream-oriented networks
+	response []byte // the response that hasn't been read yet
+}
+
+var _ net.Conn = &conn{}
+
+func (c *conn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return 0, net.ErrClosed
+	}
+	var query []byte
+	if c.stream {
+		c.query = append(c.query, b...)
+		if len(c.query) < 2 {
+			c.mutex.Unlock()
+			return len(b), nil
+		}
+		l := int(binary.BigEndian.Uint16(c.query))
+		if len(c.query) < 2+l {
+			c.mutex.Unlock()
+			return len(b), nil
+		}
+		query = append([]byte{}, c.query[2:2+l]...)
+		c.query = c.query[2+l:]
+	} else {
+		query = append([]byte{}, b...)
+	}
+	ctx := c.ctx
+	deadline := c.deadline
+	c.mutex.Unlock()
+
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	rsp, err := c.client.Exchange(ctx, query)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		return 0, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stream {
+		l := make([]byte, 2)
+		binary.BigEndian.PutUint16(l, uint16(len(rsp)))
+		c.response = append(c.response, l...)
+	}
+	c.response = append(c.response, rsp...)
+	return len(b), nil
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if len(c.response) == 0 {
+		return 0, io.EOF
+	}
+	if c.stream {
+		n := copy(b, c.response)
+		c.response = c.response[n:]
+		return n, nil
+	}
+	// Datagram-oriented networks can't deliver partial messages.
+	// If the response doesn't fit, set the TC bit, so that the resolver retries the query using a stream.
+	rsp := c.response
+	c.response = nil
+	if len(rsp) > len(b) {
+		var err error
+		rsp, err = truncate(rsp)
+		if err != nil {
+			return 0, err
+		}
+		if len(rsp) > len(b) {
+			return 0, io.ErrShortBuffer
+		}
+	}
+	return copy(b, rsp), nil
+}
+
+func (c *conn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr  { return dohAddr{} }
+func (c *conn) RemoteAddr() net.Addr { return dohAddr{url: c.client.URL} }
+
+// SetDeadline sets the deadline for the queries.
+// The deadline needs to be set before writing the query.
+func (c *conn) SetDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *conn) SetReadDeadline(time.Time) error    { return nil }
+func (c *conn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+// packetConn is the conn returned for datagram-oriented networks.
+type packetConn struct {
+	*conn
+}
+
+var _ net.PacketConn = &packetConn{}
+
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.RemoteAddr(), err
+}
+
+func (c *packetConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+type dohAddr struct {
+	url string
+}
+
+func (dohAddr) Network() string  { return "doh" }
+func (a dohAddr) String() string { return a.url }
@@ -0,0 +1,165 @@
+package doh
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// handlerRoundTripper returns a http.RoundTripper that serves all requests using handler
+func handlerRoundTripper(handler http.Handler) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result(), nil
+	})
+}
+
+var _ = Describe("Client", func() {
+	var (
+		client  *Client
+		handler *Handler
+	)
+
+	BeforeEach(func() {
+		handler = &Handler{
+			Exchange: func(_ context.Context, query []byte) ([]byte, error) {
+				return newResponse(query, 2, 120), nil
+			},
+		}
+		client = &Client{
+			URL:          "https://dns.example.com/dns-query",
+			RoundTripper: handlerRoundTripper(handler),
+		}
+	})
+
+	It("sends queries", func() {
+		rsp, err := client.Exchange(context.Background(), newQuery("example.org."))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parseAddrs(rsp)).To(Equal([]net.IP{net.IPv4(10, 0, 0, 0).To4(), net.IPv4(10, 0, 0, 1).To4()}))
+	})
+
+	It("errors when the server responds with an error", func() {
+		client.RoundTripper = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		})
+		_, err := client.Exchange(context.Background(), newQuery("example.org."))
+		Expect(err).To(MatchError("doh: server responded with status 502"))
+	})
+
+	It("errors when the server responds with the wrong content type", func() {
+		client.RoundTripper = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/plain"}},
+				Body:       http.NoBody,
+			}, nil
+		})
+		_, err := client.Exchange(context.Background(), newQuery("example.org."))
+		Expect(err).To(MatchError("doh: unexpected content type: text/plain"))
+	})
+
+	It("rejects unsupported networks", func() {
+		_, err := client.Dial(context.Background(), "unix", "")
+		Expect(err).To(MatchError("doh: unsupported network: unix"))
+	})
+
+	It("sends queries written to a datagram conn", func() {
+		conn, err := client.Dial(context.Background(), "udp", "8.8.8.8:53")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		_, err = conn.Write(newQuery("example.org."))
+		Expect(err).ToNot(HaveOccurred())
+		b := make([]byte, 1500)
+		n, err := conn.Read(b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parseAddrs(b[:n])).To(HaveLen(2))
+	})
+
+	It("sets the TC bit if the response doesn't fit into the read buffer", func() {
+		handler.Exchange = func(_ context.Context, query []byte) ([]byte, error) {
+			return newResponse(query, 100, 120), nil
+		}
+		conn, err := client.Dial(context.Background(), "udp", "8.8.8.8:53")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		_, err = conn.Write(newQuery("example.org."))
+		Expect(err).ToNot(HaveOccurred())
+		b := make([]byte, 512)
+		n, err := conn.Read(b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isTruncated(b[:n])).To(BeTrue())
+		Expect(parseAddrs(b[:n])).To(BeEmpty())
+	})
+
+	It("sends queries written to a stream conn", func() {
+		conn, err := client.Dial(context.Background(), "tcp", "8.8.8.8:53")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		query := newQuery("example.org.")
+		l := make([]byte, 2)
+		binary.BigEndian.PutUint16(l, uint16(len(query)))
+		// write the query in multiple chunks
+		_, err = conn.Write(append(l, query[:5]...))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = conn.Write(query[5:])
+		Expect(err).ToNot(HaveOccurred())
+		_, err = io.ReadFull(conn, l)
+		Expect(err).ToNot(HaveOccurred())
+		rsp := make([]byte, binary.BigEndian.Uint16(l))
+		_, err = io.ReadFull(conn, rsp)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parseAddrs(rsp)).To(HaveLen(2))
+	})
+
+	It("uses the deadline for the query", func() {
+		client.RoundTripper = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		})
+		conn, err := client.Dial(context.Background(), "udp", "8.8.8.8:53")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Expect(conn.SetDeadline(time.Now().Add(50 * time.Millisecond))).To(Succeed())
+		_, err = conn.Write(newQuery("example.org."))
+		Expect(err).To(HaveOccurred())
+		var nerr net.Error
+		Expect(errors.As(err, &nerr)).To(BeTrue())
+		Expect(nerr.Timeout()).To(BeTrue())
+	})
+
+	It("resolves names", func() {
+		handler.Exchange = func(_ context.Context, query []byte) ([]byte, error) {
+			var p dnsmessage.Parser
+			if _, err := p.Start(query); err != nil {
+				return nil, err
+			}
+			q, err := p.Question()
+			if err != nil {
+				return nil, err
+			}
+			if q.Type != dnsmessage.TypeA {
+				return newResponse(query, 0, 0), nil
+			}
+			// make the response large enough to require a retry over TCP
+			return newResponse(query, 200, 120), nil
+		}
+		addrs, err := client.Resolver().LookupIPAddr(context.Background(), "quic-go.example.org.")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(addrs).To(HaveLen(200))
+	})
+})
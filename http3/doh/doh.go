@@ -0,0 +1,96 @@
+// Package doh implements DNS queries over HTTPS (DoH, RFC 8484) using HTTP/3.
+//
+// The Client can be used as the Dial function of a net.Resolver,
+// so that the Go resolver sends its queries to a DoH server.
+// The Handler answers DoH queries by forwarding them to a DNS server.
+package doh
+
+import (
+	"errors"
+	"math"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ContentType is the media type of DNS messages (RFC 8484, Section 6).
+const ContentType = "application/dns-message"
+
+// maxMessageSize is the maximum size of a DNS message.
+const maxMessageSize = math.MaxUint16
+
+// headerLen is the length of the DNS message header.
+const headerLen = 12
+
+var errMessageTooShort = errors.New("doh: DNS message too short")
+
+// isTruncated says if the TC bit is set in a DNS message.
+func isTruncated(msg []byte) bool {
+	return len(msg) >= headerLen && msg[2]&0x2 > 0
+}
+
+// truncate returns a response containing only the header and the question section of msg,
+// with the TC bit set.
+func truncate(msg []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil, err
+	}
+	hdr.Truncated = true
+	b := dnsmessage.NewBuilder(make([]byte, 0, headerLen), hdr)
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		if err := b.Question(q); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// minTTL returns the smallest TTL of the resource records in a DNS message.
+// It returns false if the message doesn't contain any resource records, or if it can't be parsed.
+func minTTL(msg []byte) (uint32, bool) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return 0, false
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return 0, false
+	}
+	var ttl uint32
+	var found bool
+	sections := []struct {
+		header func() (dnsmessage.ResourceHeader, error)
+		skip   func() error
+	}{
+		{header: p.AnswerHeader, skip: p.SkipAnswer},
+		{header: p.AuthorityHeader, skip: p.SkipAuthority},
+		{header: p.AdditionalHeader, skip: p.SkipAdditional},
+	}
+	for _, s := range sections {
+		for {
+			h, err := s.header()
+			if err == dnsmessage.ErrSectionDone {
+				break
+			}
+			if err != nil {
+				return 0, false
+			}
+			// The TTL of the OPT pseudo-record is used for the extended RCODE and flags.
+			if h.Type != dnsmessage.TypeOPT && (!found || h.TTL < ttl) {
+				ttl = h.TTL
+				found = true
+			}
+			if err := s.skip(); err != nil {
+				return 0, false
+			}
+		}
+	}
+	return ttl, found
+}
@@ -0,0 +1,13 @@
+package doh
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDoH(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DoH Suite")
+}
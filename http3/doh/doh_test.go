@@ -0,0 +1,103 @@
+package doh
+
+import (
+	"net"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func newQuery(name string) []byte {
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 1337, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	b, err := msg.Pack()
+	Expect(err).ToNot(HaveOccurred())
+	return b
+}
+
+// newResponse creates a response to query, containing num A records.
+func newResponse(query []byte, num int, ttl uint32) []byte {
+	var q dnsmessage.Message
+	Expect(q.Unpack(query)).To(Succeed())
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true, RecursionDesired: q.RecursionDesired},
+		Questions: q.Questions,
+	}
+	for i := 0; i < num; i++ {
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{
+				Name:  q.Questions[0].Name,
+				Type:  dnsmessage.TypeA,
+				Class: dnsmessage.ClassINET,
+				TTL:   ttl + uint32(i),
+			},
+			Body: &dnsmessage.AResource{A: [4]byte{10, 0, byte(i / 256), byte(i % 256)}},
+		})
+	}
+	b, err := msg.Pack()
+	Expect(err).ToNot(HaveOccurred())
+	return b
+}
+
+var _ = Describe("DNS messages", func() {
+	It("truncates messages", func() {
+		rsp := newResponse(newQuery("example.org."), 10, 60)
+		Expect(isTruncated(rsp)).To(BeFalse())
+		truncated, err := truncate(rsp)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(truncated)).To(BeNumerically("<", len(rsp)))
+		Expect(isTruncated(truncated)).To(BeTrue())
+		var msg dnsmessage.Message
+		Expect(msg.Unpack(truncated)).To(Succeed())
+		Expect(msg.ID).To(BeEquivalentTo(1337))
+		Expect(msg.Questions).To(HaveLen(1))
+		Expect(msg.Questions[0].Name.String()).To(Equal("example.org."))
+		Expect(msg.Answers).To(BeEmpty())
+	})
+
+	It("determines the minimum TTL", func() {
+		ttl, ok := minTTL(newResponse(newQuery("example.org."), 3, 300))
+		Expect(ok).To(BeTrue())
+		Expect(ttl).To(BeEquivalentTo(300))
+	})
+
+	It("doesn't determine the TTL of messages without resource records", func() {
+		_, ok := minTTL(newResponse(newQuery("example.org."), 0, 300))
+		Expect(ok).To(BeFalse())
+		_, ok = minTTL([]byte("foobar"))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("ignores the OPT pseudo-record", func() {
+		query := newQuery("example.org.")
+		var msg dnsmessage.Message
+		Expect(msg.Unpack(newResponse(query, 1, 300))).To(Succeed())
+		var opt dnsmessage.ResourceHeader
+		Expect(opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, false)).To(Succeed())
+		msg.Additionals = append(msg.Additionals, dnsmessage.Resource{Header: opt, Body: &dnsmessage.OPTResource{}})
+		b, err := msg.Pack()
+		Expect(err).ToNot(HaveOccurred())
+		ttl, ok := minTTL(b)
+		Expect(ok).To(BeTrue())
+		Expect(ttl).To(BeEquivalentTo(300))
+	})
+})
+
+// parseAddrs returns the addresses of the A records of a response
+func parseAddrs(rsp []byte) []net.IP {
+	var msg dnsmessage.Message
+	ExpectWithOffset(1, msg.Unpack(rsp)).To(Succeed())
+	var ips []net.IP
+	for _, a := range msg.Answers {
+		ips = append(ips, net.IP(a.Body.(*dnsmessage.AResource).A[:]))
+	}
+	return ips
+}
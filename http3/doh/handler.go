@@ -0,0 +1,144 @@
+package doh
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultUpstreamTimeout is the timeout for queries to the upstream DNS server,
+// if the request context doesn't have a deadline.
+const defaultUpstreamTimeout = 5 * time.Second
+
+// A Handler answers DoH queries (RFC 8484).
+// It supports both GET and POST requests.
+type Handler struct {
+	// Upstream is the address of the DNS server that queries are forwarded to, e.g. "127.0.0.1:53".
+	// Queries are sent over UDP, and retried over TCP if the response is truncated.
+	Upstream string
+
+	// Exchange, if set, is used to answer the queries, instead of forwarding them to the Upstream server.
+	Exchange func(ctx context.Context, query []byte) ([]byte, error)
+}
+
+var _ http.Handler = &Handler{}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var query []byte
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		query, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil {
+			http.Error(w, "invalid dns parameter", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != ContentType {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var err error
+		query, err = io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(query) > maxMessageSize {
+			http.Error(w, "query too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(query) < headerLen {
+		http.Error(w, "invalid DNS query", http.StatusBadRequest)
+		return
+	}
+
+	rsp, err := h.exchange(r.Context(), query)
+	if err != nil {
+		http.Error(w, "upstream DNS server failed", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	if ttl, ok := minTTL(rsp); ok {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", ttl))
+	}
+	w.Write(rsp)
+}
+
+func (h *Handler) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if h.Exchange != nil {
+		return h.Exchange(ctx, query)
+	}
+	if h.Upstream == "" {
+		return nil, errors.New("doh: no upstream DNS server configured")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultUpstreamTimeout)
+		defer cancel()
+	}
+	rsp, err := exchange(ctx, "udp", h.Upstream, query)
+	if err != nil || !isTruncated(rsp) {
+		return rsp, err
+	}
+	return exchange(ctx, "tcp", h.Upstream, query)
+}
+
+// exchange sends a DNS query to a DNS server and returns the response.
+func exchange(ctx context.Context, network, addr string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		b := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(b, uint16(len(query)))
+		copy(b[2:], query)
+		if _, err := c.Write(b); err != nil {
+			return nil, err
+		}
+		l := make([]byte, 2)
+		if _, err := io.ReadFull(c, l); err != nil {
+			return nil, err
+		}
+		rsp := make([]byte, binary.BigEndian.Uint16(l))
+		if _, err := io.ReadFull(c, rsp); err != nil {
+			return nil, err
+		}
+		return rsp, nil
+	}
+
+	if _, err := c.Write(query); err != nil {
+		return nil, err
+	}
+	b := make([]byte, maxMessageSize)
+	for {
+		n, err := c.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		// ignore responses that don't match the ID of the query
+		if n < headerLen || b[0] != query[0] || b[1] != query[1] {
+			continue
+		}
+		return b[:n], nil
+	}
+}
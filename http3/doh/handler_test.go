@@ -0,0 +1,187 @@
+package doh
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		handler *Handler
+		queries chan []byte
+	)
+
+	BeforeEach(func() {
+		q := make(chan []byte, 10)
+		queries = q
+		handler = &Handler{
+			Exchange: func(_ context.Context, query []byte) ([]byte, error) {
+				q <- query
+				return newResponse(query, 2, 120), nil
+			},
+		}
+	})
+
+	It("answers POST requests", func() {
+		query := newQuery("example.org.")
+		req := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(query))
+		req.Header.Set("Content-Type", ContentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(Equal(ContentType))
+		Expect(w.Header().Get("Cache-Control")).To(Equal("max-age=120"))
+		Expect(queries).To(Receive(Equal(query)))
+		Expect(parseAddrs(w.Body.Bytes())).To(HaveLen(2))
+	})
+
+	It("answers GET requests", func() {
+		query := newQuery("example.org.")
+		req := httptest.NewRequest(http.MethodGet, "/dns-query?dns="+base64.RawURLEncoding.EncodeToString(query), nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(queries).To(Receive(Equal(query)))
+		Expect(parseAddrs(w.Body.Bytes())).To(HaveLen(2))
+	})
+
+	It("rejects GET requests with an invalid dns parameter", func() {
+		req := httptest.NewRequest(http.MethodGet, "/dns-query?dns=%21%21", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(queries).ToNot(Receive())
+	})
+
+	It("rejects queries that are too short", func() {
+		req := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader([]byte("foobar")))
+		req.Header.Set("Content-Type", ContentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(queries).ToNot(Receive())
+	})
+
+	It("rejects POST requests with the wrong content type", func() {
+		req := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(newQuery("example.org.")))
+		req.Header.Set("Content-Type", "text/plain")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusUnsupportedMediaType))
+		Expect(queries).ToNot(Receive())
+	})
+
+	It("rejects other methods", func() {
+		req := httptest.NewRequest(http.MethodPut, "/dns-query", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(w.Header().Get("Allow")).To(Equal("GET, POST"))
+	})
+
+	It("responds with 502 if the query fails", func() {
+		handler.Exchange = func(context.Context, []byte) ([]byte, error) { return nil, errors.New("test error") }
+		req := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(newQuery("example.org.")))
+		req.Header.Set("Content-Type", ContentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusBadGateway))
+	})
+
+	Context("forwarding to an upstream server", func() {
+		var (
+			udpConn *net.UDPConn
+			tcpLn   net.Listener
+		)
+
+		BeforeEach(func() {
+			var err error
+			udpConn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			tcpLn, err = net.Listen("tcp", udpConn.LocalAddr().String())
+			Expect(err).ToNot(HaveOccurred())
+			handler = &Handler{Upstream: udpConn.LocalAddr().String()}
+		})
+
+		AfterEach(func() {
+			udpConn.Close()
+			tcpLn.Close()
+		})
+
+		// serveUDP answers a single query over UDP
+		serveUDP := func(conn *net.UDPConn, getResponse func(query []byte) []byte) {
+			defer GinkgoRecover()
+			b := make([]byte, maxMessageSize)
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(getResponse(b[:n]), addr)
+		}
+
+		It("forwards queries over UDP", func() {
+			go serveUDP(udpConn, func(query []byte) []byte { return newResponse(query, 3, 60) })
+			rsp, err := handler.exchange(context.Background(), newQuery("example.org."))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parseAddrs(rsp)).To(HaveLen(3))
+		})
+
+		It("ignores responses with the wrong ID", func() {
+			go serveUDP(udpConn, func(query []byte) []byte {
+				rsp := newResponse(query, 3, 60)
+				rsp[0]++
+				return rsp
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := handler.exchange(ctx, newQuery("example.org."))
+			Expect(err).To(HaveOccurred())
+			var nerr net.Error
+			Expect(errors.As(err, &nerr)).To(BeTrue())
+			Expect(nerr.Timeout()).To(BeTrue())
+		})
+
+		It("retries over TCP if the response is truncated", func() {
+			go serveUDP(udpConn, func(query []byte) []byte {
+				rsp, err := truncate(newResponse(query, 1, 60))
+				if err != nil {
+					return nil
+				}
+				return rsp
+			})
+			go func() {
+				defer GinkgoRecover()
+				c, err := tcpLn.Accept()
+				if err != nil {
+					return
+				}
+				defer c.Close()
+				l := make([]byte, 2)
+				if _, err := io.ReadFull(c, l); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(l))
+				if _, err := io.ReadFull(c, query); err != nil {
+					return
+				}
+				rsp := newResponse(query, 100, 60)
+				binary.BigEndian.PutUint16(l, uint16(len(rsp)))
+				c.Write(append(l, rsp...))
+			}()
+			rsp, err := handler.exchange(context.Background(), newQuery("example.org."))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parseAddrs(rsp)).To(HaveLen(100))
+		})
+	})
+})
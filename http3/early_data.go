@@ -0,0 +1,55 @@
+package http3
+
+import (
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+var receivedEarlyDataContextKey = &contextKey{"received-early-data"}
+
+// RequestEarlyData says if the server received req in 0-RTT data, i.e. before the QUIC handshake completed.
+// An attacker can replay such requests (RFC 8470).
+// It always returns false for requests that were not received by a Server.
+func RequestEarlyData(req *http.Request) bool {
+	early, _ := req.Context().Value(receivedEarlyDataContextKey).(bool)
+	return early
+}
+
+// RejectEarlyData returns a handler that responds with 425 (Too Early) to requests received in 0-RTT data,
+// and passes all other requests to handler.
+// It is intended for handlers that are not safe to be replayed.
+// Clients may retry the request after the handshake completed (RFC 8470, Section 5.2).
+func RejectEarlyData(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RequestEarlyData(r) {
+			w.WriteHeader(http.StatusTooEarly)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// WaitForHandshake returns a handler that delays processing requests received in 0-RTT data
+// until the QUIC handshake completed, and then passes them to handler.
+// Since an attacker replaying 0-RTT data can't complete the handshake, replayed requests are never processed.
+// If the request is canceled or the connection is closed before the handshake completed, handler is not called.
+func WaitForHandshake(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RequestEarlyData(r) {
+			conn, ok := r.Context().Value(ConnectionContextKey).(quic.EarlyConnection)
+			if !ok {
+				w.WriteHeader(http.StatusTooEarly)
+				return
+			}
+			select {
+			case <-conn.HandshakeComplete().Done():
+			case <-conn.Context().Done():
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
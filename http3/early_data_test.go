@@ -0,0 +1,98 @@
+package http3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("0-RTT requests", func() {
+	var (
+		conn              *mockquic.MockEarlyConnection
+		handshakeCtx      context.Context
+		completeHandshake context.CancelFunc
+		handlerCalled     chan struct{}
+		handler           http.Handler
+	)
+
+	BeforeEach(func() {
+		conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		handshakeCtx, completeHandshake = context.WithCancel(context.Background())
+		conn.EXPECT().HandshakeComplete().Return(handshakeCtx).AnyTimes()
+		conn.EXPECT().Context().Return(context.Background()).AnyTimes()
+		handlerCalled = make(chan struct{})
+		handlerCalled := handlerCalled
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(handlerCalled)
+			w.WriteHeader(http.StatusTeapot)
+		})
+	})
+
+	AfterEach(func() { completeHandshake() })
+
+	newRequest := func(ctx context.Context, early bool) *http.Request {
+		ctx = context.WithValue(ctx, ConnectionContextKey, conn)
+		if early {
+			ctx = context.WithValue(ctx, receivedEarlyDataContextKey, true)
+		}
+		return httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+	}
+
+	It("says if a request was received in 0-RTT data", func() {
+		Expect(RequestEarlyData(newRequest(context.Background(), true))).To(BeTrue())
+		Expect(RequestEarlyData(newRequest(context.Background(), false))).To(BeFalse())
+		Expect(RequestEarlyData(httptest.NewRequest(http.MethodGet, "/", nil))).To(BeFalse())
+	})
+
+	Context("rejecting", func() {
+		It("rejects requests received in 0-RTT data", func() {
+			rec := httptest.NewRecorder()
+			RejectEarlyData(handler).ServeHTTP(rec, newRequest(context.Background(), true))
+			Expect(rec.Code).To(Equal(http.StatusTooEarly))
+			Expect(handlerCalled).ToNot(BeClosed())
+		})
+
+		It("passes other requests to the handler", func() {
+			rec := httptest.NewRecorder()
+			RejectEarlyData(handler).ServeHTTP(rec, newRequest(context.Background(), false))
+			Expect(rec.Code).To(Equal(http.StatusTeapot))
+			Expect(handlerCalled).To(BeClosed())
+		})
+	})
+
+	Context("waiting for the handshake", func() {
+		It("delays requests received in 0-RTT data until the handshake completed", func() {
+			rec := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				WaitForHandshake(handler).ServeHTTP(rec, newRequest(context.Background(), true))
+			}()
+			Consistently(handlerCalled).ShouldNot(BeClosed())
+			completeHandshake()
+			Eventually(done).Should(BeClosed())
+			Expect(handlerCalled).To(BeClosed())
+			Expect(rec.Code).To(Equal(http.StatusTeapot))
+		})
+
+		It("doesn't delay other requests", func() {
+			rec := httptest.NewRecorder()
+			WaitForHandshake(handler).ServeHTTP(rec, newRequest(context.Background(), false))
+			Expect(handlerCalled).To(BeClosed())
+		})
+
+		It("doesn't call the handler if the request is canceled", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(20*time.Millisecond))
+			defer cancel()
+			WaitForHandshake(handler).ServeHTTP(httptest.NewRecorder(), newRequest(ctx, true))
+			Expect(handlerCalled).ToNot(BeClosed())
+		})
+	})
+})
@@ -27,6 +27,12 @@ const (
 	errorConnectError         errorCode = 0x10f
 	errorVersionFallback      errorCode = 0x110
 	errorDatagramError        errorCode = 0x4a1268
+
+	errorWebTransportBufferedStreamRejected errorCode = 0x3994bd84
+
+	errorQPACKDecompressionFailed errorCode = 0x200
+	errorQPACKEncoderStreamError  errorCode = 0x201
+	errorQPACKDecoderStreamError  errorCode = 0x202
 )
 
 func (e errorCode) String() string {
@@ -67,6 +73,14 @@ func (e errorCode) String() string {
 		return "H3_VERSION_FALLBACK"
 	case errorDatagramError:
 		return "H3_DATAGRAM_ERROR"
+	case errorWebTransportBufferedStreamRejected:
+		return "H3_WEBTRANSPORT_BUFFERED_STREAM_REJECTED"
+	case errorQPACKDecompressionFailed:
+		return "QPACK_DECOMPRESSION_FAILED"
+	case errorQPACKEncoderStreamError:
+		return "QPACK_ENCODER_STREAM_ERROR"
+	case errorQPACKDecoderStreamError:
+		return "QPACK_DECODER_STREAM_ERROR"
 	default:
 		return fmt.Sprintf("unknown error code: %#x", uint16(e))
 	}
@@ -0,0 +1,82 @@
+package http3
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// A FrameHandler handles the frames of a frame type registered using RegisterFrameType.
+// It is called for every frame of the registered type received on the control stream or on a request stream,
+// with the ID of that stream, and a reader for the frame payload.
+// It is called synchronously while the stream is parsed, and must not retain the payload reader.
+// The part of the payload it doesn't read is skipped.
+// If it returns an error, the connection is closed with H3_FRAME_ERROR.
+type FrameHandler func(conn quic.Connection, streamID quic.StreamID, payload io.Reader) error
+
+// frameHandlers are the handlers registered using RegisterFrameType.
+type frameHandlers struct {
+	mutex    sync.RWMutex
+	handlers map[FrameType]FrameHandler
+}
+
+func (h *frameHandlers) register(t FrameType, handler FrameHandler) error {
+	if handler == nil {
+		return fmt.Errorf("http3: no handler for frame type %#x", uint64(t))
+	}
+	if isReservedExtensionFrameType(t) {
+		return fmt.Errorf("http3: frame type %#x is reserved", uint64(t))
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.handlers[t]; ok {
+		return fmt.Errorf("http3: frame type %#x already registered", uint64(t))
+	}
+	if h.handlers == nil {
+		h.handlers = make(map[FrameType]FrameHandler)
+	}
+	h.handlers[t] = handler
+	return nil
+}
+
+func (h *frameHandlers) get(t FrameType) (FrameHandler, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	handler, ok := h.handlers[t]
+	return handler, ok
+}
+
+// forStream returns the extensionFrameHandlerFunc used when parsing the frames on str.
+// If a handler returns an error, the connection is closed.
+func (h *frameHandlers) forStream(conn quic.Connection, str streamWithID) extensionFrameHandlerFunc {
+	if h == nil {
+		return nil
+	}
+	return func(t FrameType) func(io.Reader) error {
+		handler, ok := h.get(t)
+		if !ok {
+			return nil
+		}
+		return func(payload io.Reader) error {
+			if err := handler(conn, str.StreamID(), payload); err != nil {
+				conn.CloseWithError(quic.ApplicationErrorCode(errorFrameError), err.Error())
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// isReservedExtensionFrameType says if t is used by HTTP/3, the extensions implemented by this package,
+// or if it is reserved for HTTP/2 frame types or for greasing (RFC 9114, Section 7.2.8).
+func isReservedExtensionFrameType(t FrameType) bool {
+	switch {
+	case t <= 0xd:
+		return true
+	case t == frameTypePriorityUpdateRequest, t == frameTypePriorityUpdatePush, t == frameTypeWebTransportStream:
+		return true
+	}
+	return t >= 0x21 && (t-0x21)%0x1f == 0
+}
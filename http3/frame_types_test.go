@@ -0,0 +1,102 @@
+package http3
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Extension frame types", func() {
+	handler := func(quic.Connection, quic.StreamID, io.Reader) error { return nil }
+
+	It("registers frame types", func() {
+		var h frameHandlers
+		_, ok := h.get(0x1337)
+		Expect(ok).To(BeFalse())
+		Expect(h.register(0x1337, handler)).To(Succeed())
+		_, ok = h.get(0x1337)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("refuses to register a frame type twice", func() {
+		var h frameHandlers
+		Expect(h.register(0x1337, handler)).To(Succeed())
+		Expect(h.register(0x1337, handler)).To(MatchError("http3: frame type 0x1337 already registered"))
+	})
+
+	It("refuses to register a frame type without a handler", func() {
+		var h frameHandlers
+		Expect(h.register(0x1337, nil)).To(MatchError("http3: no handler for frame type 0x1337"))
+	})
+
+	It("refuses to register reserved frame types", func() {
+		var h frameHandlers
+		for _, t := range []FrameType{
+			0x0, // DATA
+			0x1, // HEADERS
+			0x2, // reserved for HTTP/2
+			0x4, // SETTINGS
+			0xd, // MAX_PUSH_ID
+			frameTypePriorityUpdateRequest,
+			frameTypePriorityUpdatePush,
+			frameTypeWebTransportStream,
+			0x21,
+			0x1f*42 + 0x21,
+		} {
+			Expect(h.register(t, handler)).To(MatchError(ContainSubstring("is reserved")))
+		}
+		Expect(h.register(0x22, handler)).To(Succeed())
+	})
+
+	Context("handling frames", func() {
+		var conn *mockquic.MockEarlyConnection
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		})
+
+		It("doesn't handle frames if no frame types were registered", func() {
+			var h *frameHandlers
+			Expect(h.forStream(conn, &mockStreamID{id: 4})).To(BeNil())
+		})
+
+		It("passes frames to the handler", func() {
+			var h frameHandlers
+			var streamID quic.StreamID
+			var payload []byte
+			Expect(h.register(0x1337, func(c quic.Connection, id quic.StreamID, r io.Reader) error {
+				Expect(c).To(Equal(conn))
+				streamID = id
+				var err error
+				payload, err = ioutil.ReadAll(r)
+				return err
+			})).To(Succeed())
+			efh := h.forStream(conn, &mockStreamID{id: 4})
+			Expect(efh(0x42)).To(BeNil())
+			handle := efh(0x1337)
+			Expect(handle).ToNot(BeNil())
+			Expect(handle(strings.NewReader("foobar"))).To(Succeed())
+			Expect(streamID).To(Equal(quic.StreamID(4)))
+			Expect(payload).To(Equal([]byte("foobar")))
+		})
+
+		It("closes the connection when the handler returns an error", func() {
+			var h frameHandlers
+			testErr := errors.New("invalid payload")
+			Expect(h.register(0x1337, func(quic.Connection, quic.StreamID, io.Reader) error { return testErr })).To(Succeed())
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorFrameError), "invalid payload")
+			Expect(h.forStream(conn, &mockStreamID{id: 4})(0x1337)(strings.NewReader("foobar"))).To(MatchError(testErr))
+		})
+	})
+})
+
+type mockStreamID struct{ id quic.StreamID }
+
+func (s *mockStreamID) StreamID() quic.StreamID { return s.id }
@@ -16,17 +16,51 @@ type FrameType uint64
 
 type unknownFrameHandlerFunc func(FrameType) (processed bool, err error)
 
+// extensionFrameHandlerFunc returns the handler for the frames of a type registered using RegisterFrameType.
+// It returns nil if the frame type wasn't registered.
+type extensionFrameHandlerFunc func(FrameType) func(payload io.Reader) error
+
 type frame interface{}
 
 var errHijacked = errors.New("hijacked")
 
 func parseNextFrame(r io.Reader, unknownFrameHandler unknownFrameHandlerFunc) (frame, error) {
+	return parseFrame(r, unknownFrameHandler, false)
+}
+
+// parseFrame is like parseNextFrame.
+// If rejectReserved is set, errReservedFrameType is returned for frame types reserved for HTTP/2 frames,
+// instead of skipping them.
+func parseFrame(r io.Reader, unknownFrameHandler unknownFrameHandlerFunc, rejectReserved bool) (frame, error) {
+	return parseFrameWithExtensions(r, unknownFrameHandler, nil, rejectReserved)
+}
+
+// parseFrameWithExtensions is like parseFrame.
+// Frames of the types registered using RegisterFrameType are passed to their handler, and then skipped.
+func parseFrameWithExtensions(r io.Reader, unknownFrameHandler unknownFrameHandlerFunc, extensionFrameHandler extensionFrameHandlerFunc, rejectReserved bool) (frame, error) {
 	qr := quicvarint.NewReader(r)
 	for {
 		t, err := quicvarint.Read(qr)
 		if err != nil {
 			return nil, err
 		}
+		if extensionFrameHandler != nil {
+			if handle := extensionFrameHandler(FrameType(t)); handle != nil {
+				l, err := quicvarint.Read(qr)
+				if err != nil {
+					return nil, err
+				}
+				payload := io.LimitReader(qr, int64(l))
+				if err := handle(payload); err != nil {
+					return nil, err
+				}
+				// skip over the part of the payload the handler didn't read
+				if _, err := io.Copy(ioutil.Discard, payload); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
 		// Call the unknownFrameHandler for frames not defined in the HTTP/3 spec
 		if t > 0xd && unknownFrameHandler != nil {
 			hijacked, err := unknownFrameHandler(FrameType(t))
@@ -39,6 +73,9 @@ func parseNextFrame(r io.Reader, unknownFrameHandler unknownFrameHandlerFunc) (f
 			}
 			continue
 		}
+		if rejectReserved && isReservedFrameType(t) {
+			return nil, errReservedFrameType
+		}
 		l, err := quicvarint.Read(qr)
 		if err != nil {
 			return nil, err
@@ -49,12 +86,30 @@ func parseNextFrame(r io.Reader, unknownFrameHandler unknownFrameHandlerFunc) (f
 			return &dataFrame{Length: l}, nil
 		case 0x1:
 			return &headersFrame{Length: l}, nil
+		case 0x3:
+			pushID, err := parseVarIntPayload(qr, l)
+			if err != nil {
+				return nil, err
+			}
+			return &cancelPushFrame{PushID: pushID}, nil
 		case 0x4:
 			return parseSettingsFrame(r, l)
-		case 0x3: // CANCEL_PUSH
-		case 0x5: // PUSH_PROMISE
-		case 0x7: // GOAWAY
-		case 0xd: // MAX_PUSH_ID
+		case 0x5:
+			return parsePushPromiseFrame(qr, l)
+		case 0x7:
+			id, err := parseVarIntPayload(qr, l)
+			if err != nil {
+				return nil, err
+			}
+			return &goAwayFrame{StreamID: id}, nil
+		case 0xd:
+			pushID, err := parseVarIntPayload(qr, l)
+			if err != nil {
+				return nil, err
+			}
+			return &maxPushIDFrame{PushID: pushID}, nil
+		case frameTypePriorityUpdateRequest, frameTypePriorityUpdatePush:
+			return parsePriorityUpdateFrame(qr, l, t == frameTypePriorityUpdatePush)
 		}
 		// skip over unknown frames
 		if _, err := io.CopyN(ioutil.Discard, qr, int64(l)); err != nil {
@@ -81,11 +136,151 @@ func (f *headersFrame) Write(b *bytes.Buffer) {
 	quicvarint.Write(b, f.Length)
 }
 
-const settingDatagram = 0xffd277
+// parseVarIntPayload parses a frame payload that consists of a single variable-length integer,
+// as used by the CANCEL_PUSH, MAX_PUSH_ID and GOAWAY frames.
+func parseVarIntPayload(r io.Reader, l uint64) (uint64, error) {
+	if l > 8 {
+		return 0, fmt.Errorf("unexpected length for frame: %d", l)
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	b := bytes.NewReader(buf)
+	val, err := quicvarint.Read(b)
+	if err != nil {
+		return 0, err
+	}
+	if b.Len() > 0 {
+		return 0, fmt.Errorf("unexpected length for frame: %d", l)
+	}
+	return val, nil
+}
+
+type cancelPushFrame struct {
+	PushID uint64
+}
+
+func (f *cancelPushFrame) Write(b *bytes.Buffer) {
+	quicvarint.Write(b, 0x3)
+	quicvarint.Write(b, uint64(quicvarint.Len(f.PushID)))
+	quicvarint.Write(b, f.PushID)
+}
+
+type maxPushIDFrame struct {
+	PushID uint64
+}
+
+func (f *maxPushIDFrame) Write(b *bytes.Buffer) {
+	quicvarint.Write(b, 0xd)
+	quicvarint.Write(b, uint64(quicvarint.Len(f.PushID)))
+	quicvarint.Write(b, f.PushID)
+}
+
+// A goAwayFrame is a GOAWAY frame.
+// When sent by the server, it carries a stream ID, when sent by the client, a push ID.
+type goAwayFrame struct {
+	StreamID uint64
+}
+
+func (f *goAwayFrame) Write(b *bytes.Buffer) {
+	quicvarint.Write(b, 0x7)
+	quicvarint.Write(b, uint64(quicvarint.Len(f.StreamID)))
+	quicvarint.Write(b, f.StreamID)
+}
+
+// A pushPromiseFrame is a PUSH_PROMISE frame.
+// Just like for the HEADERS frame, the header block is not parsed,
+// Length is the length of the encoded header block following the push ID.
+type pushPromiseFrame struct {
+	PushID uint64
+	Length uint64
+}
+
+func parsePushPromiseFrame(r io.Reader, l uint64) (*pushPromiseFrame, error) {
+	lr := &io.LimitedReader{R: r, N: int64(l)}
+	pushID, err := quicvarint.Read(quicvarint.NewReader(lr))
+	if err != nil {
+		return nil, err
+	}
+	return &pushPromiseFrame{PushID: pushID, Length: uint64(lr.N)}, nil
+}
+
+func (f *pushPromiseFrame) Write(b *bytes.Buffer) {
+	quicvarint.Write(b, 0x5)
+	quicvarint.Write(b, uint64(quicvarint.Len(f.PushID))+f.Length)
+	quicvarint.Write(b, f.PushID)
+}
+
+// PRIORITY_UPDATE frame types, see RFC 9218, Section 7.
+const (
+	frameTypePriorityUpdateRequest = 0xf0700
+	frameTypePriorityUpdatePush    = 0xf0701
+)
+
+// A priorityUpdateFrame is a PRIORITY_UPDATE frame, sent on the control stream.
+type priorityUpdateFrame struct {
+	// IsPush is set if the frame updates the priority of a pushed response.
+	// ElementID then is a push ID, otherwise it is the stream ID of a request stream.
+	IsPush             bool
+	ElementID          uint64
+	PriorityFieldValue string
+}
+
+// the maximum length of a PRIORITY_UPDATE frame that we accept
+const maxPriorityUpdateFrameLen = 1024
+
+func parsePriorityUpdateFrame(r io.Reader, l uint64, isPush bool) (*priorityUpdateFrame, error) {
+	if l > maxPriorityUpdateFrameLen {
+		return nil, fmt.Errorf("PRIORITY_UPDATE frame too large: %d bytes", l)
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	b := bytes.NewReader(buf)
+	id, err := quicvarint.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	return &priorityUpdateFrame{
+		IsPush:             isPush,
+		ElementID:          id,
+		PriorityFieldValue: string(buf[len(buf)-b.Len():]),
+	}, nil
+}
+
+func (f *priorityUpdateFrame) Write(b *bytes.Buffer) {
+	if f.IsPush {
+		quicvarint.Write(b, frameTypePriorityUpdatePush)
+	} else {
+		quicvarint.Write(b, frameTypePriorityUpdateRequest)
+	}
+	quicvarint.Write(b, uint64(quicvarint.Len(f.ElementID))+uint64(len(f.PriorityFieldValue)))
+	quicvarint.Write(b, f.ElementID)
+	b.WriteString(f.PriorityFieldValue)
+}
+
+const (
+	// SETTINGS_QPACK_MAX_TABLE_CAPACITY, see RFC 9204
+	settingQPACKMaxTableCapacity = 0x1
+	// SETTINGS_MAX_FIELD_SECTION_SIZE, see RFC 9114
+	settingMaxFieldSectionSize = 0x6
+	// SETTINGS_QPACK_BLOCKED_STREAMS, see RFC 9204
+	settingQPACKBlockedStreams = 0x7
+	// SETTINGS_ENABLE_CONNECT_PROTOCOL, see RFC 9220
+	settingExtendedConnect = 0x8
+	// SETTINGS_H3_DATAGRAM, see RFC 9297
+	settingDatagram = 0x33
+)
 
 type settingsFrame struct {
-	Datagram bool
-	Other    map[uint64]uint64 // all settings that we don't explicitly recognize
+	Datagram        bool
+	ExtendedConnect bool
+	Other           map[uint64]uint64 // all settings that we don't explicitly recognize
 }
 
 func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
@@ -101,7 +296,7 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 	}
 	frame := &settingsFrame{}
 	b := bytes.NewReader(buf)
-	var readDatagram bool
+	var readDatagram, readExtendedConnect bool
 	for b.Len() > 0 {
 		id, err := quicvarint.Read(b)
 		if err != nil { // should not happen. We allocated the whole frame already.
@@ -113,6 +308,15 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 		}
 
 		switch id {
+		case settingExtendedConnect:
+			if readExtendedConnect {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readExtendedConnect = true
+			if val != 0 && val != 1 {
+				return nil, fmt.Errorf("invalid value for SETTINGS_ENABLE_CONNECT_PROTOCOL: %d", val)
+			}
+			frame.ExtendedConnect = val == 1
 		case settingDatagram:
 			if readDatagram {
 				return nil, fmt.Errorf("duplicate setting: %d", id)
@@ -144,7 +348,14 @@ func (f *settingsFrame) Write(b *bytes.Buffer) {
 	if f.Datagram {
 		l += quicvarint.Len(settingDatagram) + quicvarint.Len(1)
 	}
+	if f.ExtendedConnect {
+		l += quicvarint.Len(settingExtendedConnect) + quicvarint.Len(1)
+	}
 	quicvarint.Write(b, uint64(l))
+	if f.ExtendedConnect {
+		quicvarint.Write(b, settingExtendedConnect)
+		quicvarint.Write(b, 1)
+	}
 	if f.Datagram {
 		quicvarint.Write(b, settingDatagram)
 		quicvarint.Write(b, 1)
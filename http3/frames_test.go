@@ -72,6 +72,90 @@ var _ = Describe("Frames", func() {
 		})
 	})
 
+	Context("PUSH_PROMISE frames", func() {
+		It("parses", func() {
+			data := appendVarInt(nil, 5) // type byte
+			data = appendVarInt(data, uint64(quicvarint.Len(1337))+6)
+			data = appendVarInt(data, 1337)
+			data = append(data, []byte("foobar")...)
+			r := bytes.NewReader(data)
+			frame, err := parseNextFrame(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&pushPromiseFrame{PushID: 1337, Length: 6}))
+			Expect(r.Len()).To(Equal(6))
+		})
+
+		It("writes", func() {
+			buf := &bytes.Buffer{}
+			(&pushPromiseFrame{PushID: 42, Length: 0x1337}).Write(buf)
+			frame, err := parseNextFrame(buf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&pushPromiseFrame{PushID: 42, Length: 0x1337}))
+		})
+	})
+
+	Context("MAX_PUSH_ID frames", func() {
+		It("writes", func() {
+			buf := &bytes.Buffer{}
+			(&maxPushIDFrame{PushID: 0xdeadbeef}).Write(buf)
+			frame, err := parseNextFrame(buf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&maxPushIDFrame{PushID: 0xdeadbeef}))
+			Expect(buf.Len()).To(BeZero())
+		})
+
+		It("rejects frames with a payload that is too long", func() {
+			data := appendVarInt(nil, 0xd) // type byte
+			data = appendVarInt(data, 2)
+			data = appendVarInt(data, 1)
+			data = append(data, 0)
+			_, err := parseNextFrame(bytes.NewReader(data), nil)
+			Expect(err).To(MatchError("unexpected length for frame: 2"))
+		})
+
+		It("errors on EOF", func() {
+			buf := &bytes.Buffer{}
+			(&maxPushIDFrame{PushID: 0xdeadbeef}).Write(buf)
+			data := buf.Bytes()
+			for i := range data {
+				_, err := parseNextFrame(bytes.NewReader(data[:i]), nil)
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
+	})
+
+	Context("GOAWAY frames", func() {
+		It("writes", func() {
+			buf := &bytes.Buffer{}
+			(&goAwayFrame{StreamID: 1336}).Write(buf)
+			frame, err := parseNextFrame(buf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&goAwayFrame{StreamID: 1336}))
+			Expect(buf.Len()).To(BeZero())
+		})
+
+		It("errors on EOF", func() {
+			buf := &bytes.Buffer{}
+			(&goAwayFrame{StreamID: 0xdeadbeef}).Write(buf)
+			data := buf.Bytes()
+			for i := range data {
+				_, err := parseNextFrame(bytes.NewReader(data[:i]), nil)
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
+	})
+
+	Context("CANCEL_PUSH frames", func() {
+		It("writes", func() {
+			buf := &bytes.Buffer{}
+			(&cancelPushFrame{PushID: 1337}).Write(buf)
+			frame, err := parseNextFrame(buf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&cancelPushFrame{PushID: 1337}))
+			Expect(buf.Len()).To(BeZero())
+		})
+	})
+
 	Context("SETTINGS frames", func() {
 		It("parses", func() {
 			settings := appendVarInt(nil, 13)
@@ -134,6 +218,51 @@ var _ = Describe("Frames", func() {
 			}
 		})
 
+		Context("SETTINGS_ENABLE_CONNECT_PROTOCOL", func() {
+			It("reads the SETTINGS_ENABLE_CONNECT_PROTOCOL value", func() {
+				settings := appendVarInt(nil, settingExtendedConnect)
+				settings = appendVarInt(settings, 1)
+				data := appendVarInt(nil, 4) // type byte
+				data = appendVarInt(data, uint64(len(settings)))
+				data = append(data, settings...)
+				f, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+				Expect(f.(*settingsFrame).ExtendedConnect).To(BeTrue())
+			})
+
+			It("rejects duplicate SETTINGS_ENABLE_CONNECT_PROTOCOL entries", func() {
+				settings := appendVarInt(nil, settingExtendedConnect)
+				settings = appendVarInt(settings, 1)
+				settings = appendVarInt(settings, settingExtendedConnect)
+				settings = appendVarInt(settings, 1)
+				data := appendVarInt(nil, 4) // type byte
+				data = appendVarInt(data, uint64(len(settings)))
+				data = append(data, settings...)
+				_, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).To(MatchError(fmt.Sprintf("duplicate setting: %d", settingExtendedConnect)))
+			})
+
+			It("rejects invalid values for the SETTINGS_ENABLE_CONNECT_PROTOCOL entry", func() {
+				settings := appendVarInt(nil, settingExtendedConnect)
+				settings = appendVarInt(settings, 2)
+				data := appendVarInt(nil, 4) // type byte
+				data = appendVarInt(data, uint64(len(settings)))
+				data = append(data, settings...)
+				_, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).To(MatchError("invalid value for SETTINGS_ENABLE_CONNECT_PROTOCOL: 2"))
+			})
+
+			It("writes the SETTINGS_ENABLE_CONNECT_PROTOCOL setting", func() {
+				sf := &settingsFrame{ExtendedConnect: true, Datagram: true}
+				buf := &bytes.Buffer{}
+				sf.Write(buf)
+				frame, err := parseNextFrame(buf, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(sf))
+			})
+		})
+
 		Context("H3_DATAGRAM", func() {
 			It("reads the H3_DATAGRAM value", func() {
 				settings := appendVarInt(nil, settingDatagram)
@@ -225,4 +354,95 @@ var _ = Describe("Frames", func() {
 			Expect(called).To(BeTrue())
 		})
 	})
+
+	Context("extension frames", func() {
+		writeExtensionFrame := func(buf *bytes.Buffer, t uint64, payload string) {
+			quicvarint.Write(buf, t)
+			quicvarint.Write(buf, uint64(len(payload)))
+			buf.WriteString(payload)
+		}
+
+		// only 0x1337 is registered
+		registered := func(handle func(io.Reader) error) extensionFrameHandlerFunc {
+			return func(ft FrameType) func(io.Reader) error {
+				if ft != 0x1337 {
+					return nil
+				}
+				return handle
+			}
+		}
+
+		It("passes frames of registered types to their handler", func() {
+			buf := &bytes.Buffer{}
+			writeExtensionFrame(buf, 0x1337, "foobar")
+			writeExtensionFrame(buf, 0x42, "raboof")
+			(&dataFrame{Length: 6}).Write(buf)
+			var payloads []string
+			frame, err := parseFrameWithExtensions(buf, nil, registered(func(payload io.Reader) error {
+				b, err := io.ReadAll(payload)
+				Expect(err).ToNot(HaveOccurred())
+				payloads = append(payloads, string(b))
+				return nil
+			}), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&dataFrame{Length: 6}))
+			Expect(payloads).To(Equal([]string{"foobar"}))
+		})
+
+		It("skips the part of the payload the handler didn't read", func() {
+			buf := &bytes.Buffer{}
+			writeExtensionFrame(buf, 0x1337, "foobar")
+			(&dataFrame{Length: 6}).Write(buf)
+			frame, err := parseFrameWithExtensions(buf, nil, registered(func(payload io.Reader) error {
+				b := make([]byte, 3)
+				_, err := io.ReadFull(payload, b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(b)).To(Equal("foo"))
+				return nil
+			}), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&dataFrame{Length: 6}))
+		})
+
+		It("calls the handler before the unknown frame handler", func() {
+			buf := &bytes.Buffer{}
+			writeExtensionFrame(buf, 0x1337, "foobar")
+			(&dataFrame{Length: 6}).Write(buf)
+			var handled bool
+			frame, err := parseFrameWithExtensions(buf, func(FrameType) (bool, error) {
+				Fail("unknown frame handler called")
+				return false, nil
+			}, registered(func(io.Reader) error {
+				handled = true
+				return nil
+			}), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&dataFrame{Length: 6}))
+			Expect(handled).To(BeTrue())
+		})
+
+		It("returns the error returned by the handler", func() {
+			buf := &bytes.Buffer{}
+			writeExtensionFrame(buf, 0x1337, "foobar")
+			testErr := fmt.Errorf("test error")
+			_, err := parseFrameWithExtensions(buf, nil, registered(func(io.Reader) error { return testErr }), false)
+			Expect(err).To(MatchError(testErr))
+		})
+
+		It("doesn't read beyond the end of the payload", func() {
+			buf := &bytes.Buffer{}
+			writeExtensionFrame(buf, 0x1337, "foobar")
+			(&dataFrame{Length: 6}).Write(buf)
+			var read []byte
+			_, err := parseFrameWithExtensions(buf, nil, registered(func(payload io.Reader) error {
+				b := make([]byte, 100)
+				n, err := io.ReadFull(payload, b)
+				Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+				read = b[:n]
+				return nil
+			}), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(read)).To(Equal("foobar"))
+		})
+	})
 })
@@ -6,7 +6,10 @@ package http3
 // call gzip.NewReader on the first call to Read
 import (
 	"compress/gzip"
+	"errors"
 	"io"
+
+	"github.com/lucas-clemente/quic-go"
 )
 
 // call gzip.NewReader on the first call to Read
@@ -37,3 +40,39 @@ func (gz *gzipReader) Read(p []byte) (n int, err error) {
 func (gz *gzipReader) Close() error {
 	return gz.body.Close()
 }
+
+func (gz *gzipReader) updatePriority(p Priority) error {
+	u, ok := gz.body.(priorityUpdater)
+	if !ok {
+		return errors.New("http3: response doesn't support priority updates")
+	}
+	return u.updatePriority(p)
+}
+
+func (gz *gzipReader) earlyDataInfo() EarlyDataInfo {
+	if r, ok := gz.body.(earlyDataReporter); ok {
+		return r.earlyDataInfo()
+	}
+	return EarlyDataInfo{}
+}
+
+func (gz *gzipReader) timingInfo() RequestTiming {
+	if r, ok := gz.body.(timingReporter); ok {
+		return r.timingInfo()
+	}
+	return RequestTiming{}
+}
+
+func (gz *gzipReader) connectionInfo() (quic.Connection, quic.StreamID) {
+	if r, ok := gz.body.(connectionReporter); ok {
+		return r.connectionInfo()
+	}
+	return nil, 0
+}
+
+func (gz *gzipReader) getDatagrams() (Datagrammer, error) {
+	if r, ok := gz.body.(datagramsReporter); ok {
+		return r.getDatagrams()
+	}
+	return nil, errors.New("http3: response doesn't support HTTP datagrams")
+}
@@ -0,0 +1,90 @@
+package http3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Request bodies up to this size are compressed before sending the request,
+// such that the Content-Length of the compressed body can be sent.
+// Larger bodies (and bodies of unknown length) are compressed while they are sent.
+const maxBufferedGzipBodySize = 64 << 10
+
+// shouldGzipRequestBody says if the request body should be compressed.
+// Bodies are not compressed if they are empty, or if the request already has a Content-Encoding.
+func shouldGzipRequestBody(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return false
+	}
+	return req.Method != http.MethodConnect && req.Header.Get("Content-Encoding") == ""
+}
+
+// gzipRequest returns a copy of req, with a gzip-compressed body and the "Content-Encoding: gzip" header set.
+// The original request is not modified.
+func gzipRequest(req *http.Request) (*http.Request, error) {
+	r := *req
+	r.Header = req.Header.Clone()
+	r.Header.Set("Content-Encoding", "gzip")
+	r.Header.Del("Content-Length")
+	if req.ContentLength > 0 && req.ContentLength <= maxBufferedGzipBodySize {
+		defer req.Body.Close()
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		if _, err := io.Copy(zw, req.Body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		r.ContentLength = int64(buf.Len())
+		r.Body = ioutil.NopCloser(buf)
+		return &r, nil
+	}
+	r.ContentLength = -1
+	r.Body = newGzipWriter(req.Body)
+	return &r, nil
+}
+
+// gzipWriter wraps a request body and compresses it while it is read.
+type gzipWriter struct {
+	body io.ReadCloser // underlying Request.Body
+	zw   *gzip.Writer  // writes the compressed data to buf
+	buf  bytes.Buffer  // compressed data that wasn't read yet
+	b    []byte        // buffer for reading from body
+	zerr error         // sticky error, io.EOF once the body was read completely
+}
+
+var _ io.ReadCloser = &gzipWriter{}
+
+func newGzipWriter(body io.ReadCloser) *gzipWriter {
+	gz := &gzipWriter{body: body}
+	gz.zw = gzip.NewWriter(&gz.buf)
+	return gz
+}
+
+func (gz *gzipWriter) Read(p []byte) (int, error) {
+	for gz.buf.Len() == 0 && gz.zerr == nil {
+		if gz.b == nil {
+			gz.b = make([]byte, bodyCopyBufferSize)
+		}
+		n, err := gz.body.Read(gz.b)
+		if n > 0 {
+			gz.zw.Write(gz.b[:n]) // writing to a bytes.Buffer can't fail
+		}
+		if err == io.EOF {
+			gz.zw.Close()
+		}
+		gz.zerr = err
+	}
+	if gz.buf.Len() > 0 {
+		return gz.buf.Read(p)
+	}
+	return 0, gz.zerr
+}
+
+func (gz *gzipWriter) Close() error {
+	return gz.body.Close()
+}
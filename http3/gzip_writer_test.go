@@ -0,0 +1,119 @@
+package http3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing/iotest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request body compression", func() {
+	gunzip := func(r io.Reader) []byte {
+		zr, err := gzip.NewReader(r)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		data, err := ioutil.ReadAll(zr)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		return data
+	}
+
+	It("decides which request bodies to compress", func() {
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io", strings.NewReader("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shouldGzipRequestBody(req)).To(BeTrue())
+		req.Header.Set("Content-Encoding", "br")
+		Expect(shouldGzipRequestBody(req)).To(BeFalse())
+
+		req, err = http.NewRequest(http.MethodPost, "https://quic.clemente.io", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shouldGzipRequestBody(req)).To(BeFalse())
+		req.Body = http.NoBody
+		Expect(shouldGzipRequestBody(req)).To(BeFalse())
+
+		req, err = http.NewRequest(http.MethodConnect, "https://quic.clemente.io", strings.NewReader("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shouldGzipRequestBody(req)).To(BeFalse())
+	})
+
+	It("compresses small bodies before sending the request", func() {
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io", strings.NewReader(strings.Repeat("foobar", 100)))
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Content-Type", "text/plain")
+		r, err := gzipRequest(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(r.Header.Get("Content-Type")).To(Equal("text/plain"))
+		Expect(r.ContentLength).To(BeNumerically(">", 0))
+		Expect(r.ContentLength).To(BeNumerically("<", 600))
+		data, err := ioutil.ReadAll(r.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(HaveLen(int(r.ContentLength)))
+		Expect(gunzip(bytes.NewReader(data))).To(Equal([]byte(strings.Repeat("foobar", 100))))
+		// the original request is not modified
+		Expect(req.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(req.ContentLength).To(BeEquivalentTo(600))
+	})
+
+	It("compresses large bodies while they are read", func() {
+		data := make([]byte, 3*maxBufferedGzipBodySize)
+		for i := range data {
+			data[i] = byte(i % 13)
+		}
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io", bytes.NewReader(data))
+		Expect(err).ToNot(HaveOccurred())
+		r, err := gzipRequest(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(r.ContentLength).To(BeEquivalentTo(-1))
+		Expect(r.Body).To(BeAssignableToTypeOf(&gzipWriter{}))
+		Expect(gunzip(iotest.OneByteReader(r.Body))).To(Equal(data))
+	})
+
+	It("compresses bodies of unknown length while they are read", func() {
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io", iotest.HalfReader(strings.NewReader("foobar")))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(req.ContentLength).To(BeZero())
+		req.ContentLength = -1
+		r, err := gzipRequest(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.ContentLength).To(BeEquivalentTo(-1))
+		Expect(gunzip(r.Body)).To(Equal([]byte("foobar")))
+	})
+
+	It("returns errors when reading the body", func() {
+		testErr := errors.New("test error")
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io", io.MultiReader(strings.NewReader("foo"), iotest.ErrReader(testErr)))
+		Expect(err).ToNot(HaveOccurred())
+		req.ContentLength = 6
+		_, err = gzipRequest(req)
+		Expect(err).To(MatchError(testErr))
+
+		req.Body = ioutil.NopCloser(io.MultiReader(strings.NewReader("foo"), iotest.ErrReader(testErr)))
+		req.ContentLength = -1
+		r, err := gzipRequest(req)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ioutil.ReadAll(r.Body)
+		Expect(err).To(MatchError(testErr))
+	})
+
+	It("closes the underlying body", func() {
+		var closed bool
+		body := &readCloser{Reader: strings.NewReader("foobar"), close: func() error { closed = true; return nil }}
+		gz := newGzipWriter(body)
+		Expect(gz.Close()).To(Succeed())
+		Expect(closed).To(BeTrue())
+	})
+})
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r *readCloser) Close() error { return r.close() }
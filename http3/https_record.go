@@ -0,0 +1,521 @@
+package http3
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsTypeHTTPS is the resource record type of HTTPS records (RFC 9460, Section 14.2).
+const dnsTypeHTTPS dnsmessage.Type = 65
+
+// SvcParamKeys (RFC 9460, Section 14.3.2)
+const (
+	svcParamMandatory     = 0
+	svcParamALPN          = 1
+	svcParamNoDefaultALPN = 2
+	svcParamPort          = 3
+	svcParamIPv4Hint      = 4
+	svcParamECH           = 5
+	svcParamIPv6Hint      = 6
+)
+
+const (
+	// maxHTTPSAliasChain is the maximum number of AliasMode records followed when looking up the endpoints of an origin.
+	maxHTTPSAliasChain = 8
+	// dnsTimeout is the timeout for a DNS query, if the context doesn't have a deadline.
+	dnsTimeout = 5 * time.Second
+	// dnsUDPPayloadSize is the UDP payload size advertised in the EDNS(0) OPT record.
+	dnsUDPPayloadSize = 1232
+)
+
+// An HTTPSRecord is an HTTPS DNS resource record (RFC 9460).
+// It advertises the endpoints of an HTTPS origin, and the protocols they support.
+type HTTPSRecord struct {
+	// Priority is 0 for AliasMode records, which alias the origin to Target.
+	// For ServiceMode records, endpoints with a lower priority are preferred.
+	Priority uint16
+	// Target is the host name of the endpoint, without the trailing dot.
+	// "." refers to the owner name of the record.
+	Target string
+	// Mandatory are the keys of the parameters that a client needs to support in order to use the endpoint.
+	Mandatory []uint16
+	// ALPN are the protocols supported by the endpoint, as advertised in the alpn parameter.
+	ALPN []string
+	// NoDefaultALPN is set if the endpoint doesn't support the default protocol (http/1.1).
+	NoDefaultALPN bool
+	// Port is the port of the endpoint. If 0, the port of the origin is used.
+	Port uint16
+	// IPv4Hint and IPv6Hint are addresses of the endpoint.
+	IPv4Hint []net.IP
+	IPv6Hint []net.IP
+	// ECHConfigList is the Encrypted Client Hello configuration of the endpoint, if any.
+	ECHConfigList []byte
+}
+
+// supportsALPN says if the endpoint supports the protocol.
+func (r *HTTPSRecord) supportsALPN(protocol string) bool {
+	if protocol == "http/1.1" && !r.NoDefaultALPN {
+		return true
+	}
+	for _, p := range r.ALPN {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsMandatory says if all the parameters the endpoint declared mandatory are understood.
+// The TLS stack doesn't implement Encrypted Client Hello,
+// so endpoints that require it can't be used.
+func (r *HTTPSRecord) supportsMandatory() bool {
+	for _, key := range r.Mandatory {
+		switch key {
+		case svcParamALPN, svcParamNoDefaultALPN, svcParamPort, svcParamIPv4Hint, svcParamIPv6Hint:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseHTTPSRecord parses the RDATA of an HTTPS record (RFC 9460, Section 2.2).
+func parseHTTPSRecord(b []byte) (HTTPSRecord, error) {
+	if len(b) < 2 {
+		return HTTPSRecord{}, io.ErrUnexpectedEOF
+	}
+	r := HTTPSRecord{Priority: binary.BigEndian.Uint16(b)}
+	b = b[2:]
+	target, n, err := parseUncompressedName(b)
+	if err != nil {
+		return HTTPSRecord{}, err
+	}
+	r.Target = target
+	b = b[n:]
+	// SvcParams of AliasMode records are ignored.
+	if r.Priority == 0 {
+		return r, nil
+	}
+	lastKey := -1
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return HTTPSRecord{}, io.ErrUnexpectedEOF
+		}
+		key := binary.BigEndian.Uint16(b)
+		l := int(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+		if len(b) < l {
+			return HTTPSRecord{}, io.ErrUnexpectedEOF
+		}
+		if int(key) <= lastKey {
+			return HTTPSRecord{}, errors.New("SvcParamKeys not in strictly increasing order")
+		}
+		lastKey = int(key)
+		if err := r.parseParam(key, b[:l]); err != nil {
+			return HTTPSRecord{}, err
+		}
+		b = b[l:]
+	}
+	return r, nil
+}
+
+func (r *HTTPSRecord) parseParam(key uint16, val []byte) error {
+	switch key {
+	case svcParamMandatory:
+		if len(val) == 0 || len(val)%2 != 0 {
+			return errors.New("invalid mandatory parameter")
+		}
+		for i := 0; i < len(val); i += 2 {
+			r.Mandatory = append(r.Mandatory, binary.BigEndian.Uint16(val[i:]))
+		}
+	case svcParamALPN:
+		if len(val) == 0 {
+			return errors.New("invalid alpn parameter")
+		}
+		for len(val) > 0 {
+			l := int(val[0])
+			if l == 0 || len(val) < 1+l {
+				return errors.New("invalid alpn parameter")
+			}
+			r.ALPN = append(r.ALPN, string(val[1:1+l]))
+			val = val[1+l:]
+		}
+	case svcParamNoDefaultALPN:
+		if len(val) != 0 {
+			return errors.New("invalid no-default-alpn parameter")
+		}
+		r.NoDefaultALPN = true
+	case svcParamPort:
+		if len(val) != 2 {
+			return errors.New("invalid port parameter")
+		}
+		r.Port = binary.BigEndian.Uint16(val)
+	case svcParamIPv4Hint:
+		ips, err := parseIPHint(val, net.IPv4len)
+		if err != nil {
+			return err
+		}
+		r.IPv4Hint = ips
+	case svcParamECH:
+		r.ECHConfigList = append([]byte{}, val...)
+	case svcParamIPv6Hint:
+		ips, err := parseIPHint(val, net.IPv6len)
+		if err != nil {
+			return err
+		}
+		r.IPv6Hint = ips
+	}
+	return nil
+}
+
+func parseIPHint(val []byte, l int) ([]net.IP, error) {
+	if len(val) == 0 || len(val)%l != 0 {
+		return nil, errors.New("invalid IP hint")
+	}
+	ips := make([]net.IP, 0, len(val)/l)
+	for i := 0; i < len(val); i += l {
+		ips = append(ips, net.IP(append([]byte{}, val[i:i+l]...)))
+	}
+	return ips, nil
+}
+
+// parseUncompressedName parses a domain name in wire format.
+// Name compression is not allowed in the RDATA of HTTPS records (RFC 9460, Section 2.2).
+// It returns the number of bytes consumed.
+func parseUncompressedName(b []byte) (string, int, error) {
+	var labels []string
+	var n int
+	for {
+		if n >= len(b) {
+			return "", 0, io.ErrUnexpectedEOF
+		}
+		l := int(b[n])
+		n++
+		if l == 0 {
+			break
+		}
+		if l > 63 {
+			return "", 0, errors.New("invalid label in target name")
+		}
+		if n+l > len(b) {
+			return "", 0, io.ErrUnexpectedEOF
+		}
+		labels = append(labels, string(b[n:n+l]))
+		n += l
+	}
+	if len(labels) == 0 {
+		return ".", n, nil
+	}
+	return strings.Join(labels, "."), n, nil
+}
+
+// LookupHTTPSRecords queries the HTTPS records of name,
+// using the first DNS server configured in /etc/resolv.conf.
+// If no DNS server is configured, a DNS server on localhost is used.
+// Records that can't be parsed are ignored.
+// It can be used as RoundTripper.LookupHTTPS.
+func LookupHTTPSRecords(ctx context.Context, name string) ([]HTTPSRecord, error) {
+	return lookupHTTPS(ctx, systemDNSServer(), name)
+}
+
+func systemDNSServer() string {
+	data, err := os.ReadFile("/etc/resolv.conf")
+	if err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				return net.JoinHostPort(fields[1], "53")
+			}
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+func lookupHTTPS(ctx context.Context, server, name string) ([]HTTPSRecord, error) {
+	id, query, err := newHTTPSQuery(name)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := dnsExchange(ctx, "udp", server, query)
+	if err != nil {
+		return nil, err
+	}
+	records, truncated, err := parseHTTPSResponse(msg, id)
+	if err != nil || !truncated {
+		return records, err
+	}
+	msg, err = dnsExchange(ctx, "tcp", server, query)
+	if err != nil {
+		return nil, err
+	}
+	records, _, err = parseHTTPSResponse(msg, id)
+	return records, err
+}
+
+func newHTTPSQuery(name string) (uint16, []byte, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	var idb [2]byte
+	if _, err := rand.Read(idb[:]); err != nil {
+		return 0, nil, err
+	}
+	id := binary.BigEndian.Uint16(idb[:])
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return 0, nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: n, Type: dnsTypeHTTPS, Class: dnsmessage.ClassINET}); err != nil {
+		return 0, nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return 0, nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(dnsUDPPayloadSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return 0, nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return 0, nil, err
+	}
+	msg, err := b.Finish()
+	return id, msg, err
+}
+
+// dnsExchange sends a DNS query, and returns the response.
+// Over TCP, messages are prefixed with their length (RFC 1035, Section 4.2.2).
+func dnsExchange(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dnsTimeout)
+	}
+	conn.SetDeadline(deadline)
+	// unblock the read when the context is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	if network == "tcp" {
+		msg := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(msg, uint16(len(query)))
+		copy(msg[2:], query)
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return nil, err
+		}
+		rsp := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(conn, rsp); err != nil {
+			return nil, err
+		}
+		return rsp, nil
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	rsp := make([]byte, dnsUDPPayloadSize)
+	n, err := conn.Read(rsp)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return rsp[:n], nil
+}
+
+// parseHTTPSResponse parses the HTTPS records in the answer section of a DNS response.
+// It returns true if the response was truncated.
+func parseHTTPSResponse(msg []byte, id uint16) ([]HTTPSRecord, bool, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(msg)
+	if err != nil {
+		return nil, false, err
+	}
+	if hdr.ID != id || !hdr.Response {
+		return nil, false, errors.New("http3: unexpected DNS response")
+	}
+	if hdr.Truncated {
+		return nil, true, nil
+	}
+	switch hdr.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("http3: DNS query failed: %s", hdr.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, false, err
+	}
+	var records []HTTPSRecord
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if h.Type != dnsTypeHTTPS || h.Class != dnsmessage.ClassINET {
+			if err := p.SkipAnswer(); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		res, err := p.UnknownResource()
+		if err != nil {
+			return nil, false, err
+		}
+		r, err := parseHTTPSRecord(res.Data)
+		if err != nil { // malformed records are ignored
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, false, nil
+}
+
+// resolveHTTPSEndpoints returns the endpoints (host:port) that the HTTPS records of the origin at addr
+// advertise for the protocol, in the order of preference.
+// It returns no endpoints if the origin doesn't have any usable HTTPS records,
+// in which case the origin itself should be dialed.
+func resolveHTTPSEndpoints(ctx context.Context, lookup func(context.Context, string) ([]HTTPSRecord, error), addr, protocol string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return nil, nil
+	}
+	// For non-default ports, the port is prepended to the name (RFC 9460, Section 9.1).
+	name := host
+	if port != "443" {
+		name = "_" + port + "._https." + host
+	}
+	owner := host
+	for i := 0; ; i++ {
+		records, err := lookup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		var alias *HTTPSRecord
+		var services []HTTPSRecord
+		for j := range records {
+			if records[j].Priority == 0 {
+				if alias == nil {
+					alias = &records[j]
+				}
+			} else {
+				services = append(services, records[j])
+			}
+		}
+		// ServiceMode records are ignored if there's an AliasMode record (RFC 9460, Section 2.4.2).
+		if alias == nil {
+			return selectHTTPSEndpoints(services, owner, port, protocol), nil
+		}
+		// An AliasMode record with the target "." says that the service is not available.
+		if alias.Target == "." {
+			return nil, nil
+		}
+		if i >= maxHTTPSAliasChain {
+			return nil, errors.New("http3: too many HTTPS AliasMode records")
+		}
+		name = alias.Target
+		owner = alias.Target
+	}
+}
+
+func selectHTTPSEndpoints(records []HTTPSRecord, owner, port, protocol string) []string {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Priority < records[j].Priority })
+	var endpoints []string
+	for _, r := range records {
+		if !r.supportsALPN(protocol) || !r.supportsMandatory() {
+			continue
+		}
+		target := r.Target
+		if target == "." {
+			target = owner
+		}
+		p := port
+		if r.Port != 0 {
+			p = strconv.Itoa(int(r.Port))
+		}
+		endpoints = append(endpoints, net.JoinHostPort(target, p))
+	}
+	return endpoints
+}
+
+// dialHTTPSEndpoints returns a dial function that dials the endpoints advertised in the HTTPS records of the origin.
+// The endpoints are tried in the order of preference.
+// If the lookup fails, or no endpoint supports HTTP/3, the origin itself is dialed.
+func dialHTTPSEndpoints(lookup func(context.Context, string) ([]HTTPSRecord, error), dial dialFunc) dialFunc {
+	if dial == nil {
+		dial = dialAddr
+	}
+	return func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+		endpoints, err := resolveHTTPSEndpoints(ctx, lookup, addr, tlsConf.NextProtos[0])
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		if len(endpoints) == 0 {
+			return dial(ctx, addr, tlsConf, conf)
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		// The certificate is validated for the origin, not for the endpoint.
+		if tlsConf.ServerName == "" {
+			tlsConf = tlsConf.Clone()
+			tlsConf.ServerName = host
+		}
+		var firstErr error
+		for _, endpoint := range endpoints {
+			conn, err := dial(ctx, endpoint, tlsConf, conf)
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, firstErr
+	}
+}
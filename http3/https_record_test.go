@@ -0,0 +1,408 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// appendHTTPSRecord serializes an HTTPS record, for the SvcParams that are set in r.
+func appendHTTPSRecord(b []byte, r HTTPSRecord) []byte {
+	b = append(b, byte(r.Priority>>8), byte(r.Priority))
+	if r.Target != "." {
+		for _, label := range strings.Split(r.Target, ".") {
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	b = append(b, 0)
+	appendParam := func(key uint16, val []byte) {
+		b = append(b, byte(key>>8), byte(key), byte(len(val)>>8), byte(len(val)))
+		b = append(b, val...)
+	}
+	if len(r.Mandatory) > 0 {
+		var val []byte
+		for _, key := range r.Mandatory {
+			val = append(val, byte(key>>8), byte(key))
+		}
+		appendParam(svcParamMandatory, val)
+	}
+	if len(r.ALPN) > 0 {
+		var val []byte
+		for _, p := range r.ALPN {
+			val = append(val, byte(len(p)))
+			val = append(val, p...)
+		}
+		appendParam(svcParamALPN, val)
+	}
+	if r.NoDefaultALPN {
+		appendParam(svcParamNoDefaultALPN, nil)
+	}
+	if r.Port != 0 {
+		appendParam(svcParamPort, []byte{byte(r.Port >> 8), byte(r.Port)})
+	}
+	if len(r.IPv4Hint) > 0 {
+		var val []byte
+		for _, ip := range r.IPv4Hint {
+			val = append(val, ip.To4()...)
+		}
+		appendParam(svcParamIPv4Hint, val)
+	}
+	if len(r.ECHConfigList) > 0 {
+		appendParam(svcParamECH, r.ECHConfigList)
+	}
+	if len(r.IPv6Hint) > 0 {
+		var val []byte
+		for _, ip := range r.IPv6Hint {
+			val = append(val, ip.To16()...)
+		}
+		appendParam(svcParamIPv6Hint, val)
+	}
+	return b
+}
+
+var _ = Describe("HTTPS records", func() {
+	Context("parsing", func() {
+		It("parses a ServiceMode record", func() {
+			r := HTTPSRecord{
+				Priority:      1,
+				Target:        "svc.example.org",
+				Mandatory:     []uint16{svcParamALPN},
+				ALPN:          []string{"h3", "h2"},
+				NoDefaultALPN: true,
+				Port:          8443,
+				IPv4Hint:      []net.IP{net.IPv4(192, 0, 2, 1).To4(), net.IPv4(192, 0, 2, 2).To4()},
+				ECHConfigList: []byte("ech config"),
+				IPv6Hint:      []net.IP{net.ParseIP("2001:db8::1")},
+			}
+			parsed, err := parseHTTPSRecord(appendHTTPSRecord(nil, r))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(Equal(r))
+		})
+
+		It("parses the root target name", func() {
+			parsed, err := parseHTTPSRecord(appendHTTPSRecord(nil, HTTPSRecord{Priority: 1, Target: ".", ALPN: []string{"h3"}}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed.Target).To(Equal("."))
+			Expect(parsed.ALPN).To(Equal([]string{"h3"}))
+		})
+
+		It("ignores the SvcParams of AliasMode records", func() {
+			b := appendHTTPSRecord(nil, HTTPSRecord{Target: "alias.example.org"})
+			parsed, err := parseHTTPSRecord(append(b, 0xff))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(Equal(HTTPSRecord{Target: "alias.example.org"}))
+		})
+
+		It("ignores unknown SvcParams", func() {
+			b := appendHTTPSRecord(nil, HTTPSRecord{Priority: 1, Target: ".", ALPN: []string{"h3"}})
+			b = append(b, 0x12, 0x34, 0, 3, 'f', 'o', 'o')
+			parsed, err := parseHTTPSRecord(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed.ALPN).To(Equal([]string{"h3"}))
+		})
+
+		It("rejects SvcParams that are not in increasing order", func() {
+			b := appendHTTPSRecord(nil, HTTPSRecord{Priority: 1, Target: ".", Port: 443})
+			b = append(b, 0, svcParamALPN, 0, 3, 2, 'h', '3')
+			_, err := parseHTTPSRecord(b)
+			Expect(err).To(MatchError("SvcParamKeys not in strictly increasing order"))
+		})
+
+		It("rejects invalid SvcParams", func() {
+			b := appendHTTPSRecord(nil, HTTPSRecord{Priority: 1, Target: "."})
+			_, err := parseHTTPSRecord(append(b, 0, svcParamPort, 0, 1, 42))
+			Expect(err).To(MatchError("invalid port parameter"))
+			_, err = parseHTTPSRecord(append(b, 0, svcParamALPN, 0, 3, 3, 'h', '3'))
+			Expect(err).To(MatchError("invalid alpn parameter"))
+			_, err = parseHTTPSRecord(append(b, 0, svcParamIPv4Hint, 0, 3, 1, 2, 3))
+			Expect(err).To(MatchError("invalid IP hint"))
+		})
+
+		It("errors on EOF", func() {
+			b := appendHTTPSRecord(nil, HTTPSRecord{
+				Priority: 1,
+				Target:   "svc.example.org",
+				ALPN:     []string{"h3"},
+				Port:     8443,
+			})
+			_, err := parseHTTPSRecord(b)
+			Expect(err).ToNot(HaveOccurred())
+			// truncating the priority, the target name or the last SvcParam
+			for i := 0; i < len(b); i++ {
+				if i >= 2+17 && i <= len(b)-6 {
+					continue
+				}
+				_, err := parseHTTPSRecord(b[:i])
+				Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+			}
+		})
+
+		It("rejects compressed target names", func() {
+			_, err := parseHTTPSRecord([]byte{0, 1, 0xc0, 0x0c})
+			Expect(err).To(MatchError("invalid label in target name"))
+		})
+	})
+
+	Context("resolving endpoints", func() {
+		lookupFrom := func(records map[string][]HTTPSRecord, queried *[]string) func(context.Context, string) ([]HTTPSRecord, error) {
+			return func(_ context.Context, name string) ([]HTTPSRecord, error) {
+				if queried != nil {
+					*queried = append(*queried, name)
+				}
+				return records[name], nil
+			}
+		}
+
+		It("uses the endpoints supporting the protocol, in the order of their priority", func() {
+			lookup := lookupFrom(map[string][]HTTPSRecord{
+				"example.org": {
+					{Priority: 3, Target: "third.example.org", ALPN: []string{"h3"}},
+					{Priority: 1, Target: "first.example.org", ALPN: []string{"h2", "h3"}, Port: 8443},
+					{Priority: 1, Target: "h2.example.org", ALPN: []string{"h2"}},
+					{Priority: 2, Target: ".", ALPN: []string{"h3"}},
+				},
+			}, nil)
+			endpoints, err := resolveHTTPSEndpoints(context.Background(), lookup, "example.org:443", "h3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoints).To(Equal([]string{"first.example.org:8443", "example.org:443", "third.example.org:443"}))
+		})
+
+		It("prepends the port for non-default ports", func() {
+			var queried []string
+			lookup := lookupFrom(map[string][]HTTPSRecord{
+				"_8443._https.example.org": {{Priority: 1, Target: ".", ALPN: []string{"h3"}}},
+			}, &queried)
+			endpoints, err := resolveHTTPSEndpoints(context.Background(), lookup, "example.org:8443", "h3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(queried).To(Equal([]string{"_8443._https.example.org"}))
+			Expect(endpoints).To(Equal([]string{"example.org:8443"}))
+		})
+
+		It("doesn't look up IP addresses", func() {
+			var queried []string
+			endpoints, err := resolveHTTPSEndpoints(context.Background(), lookupFrom(nil, &queried), "192.0.2.1:443", "h3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoints).To(BeEmpty())
+			Expect(queried).To(BeEmpty())
+		})
+
+		It("follows AliasMode records", func() {
+			lookup := lookupFrom(map[string][]HTTPSRecord{
+				"example.org": {
+					{Priority: 1, Target: "ignored.example.org", ALPN: []string{"h3"}},
+					{Priority: 0, Target: "cdn.example.net"},
+				},
+				"cdn.example.net": {{Priority: 1, Target: ".", ALPN: []string{"h3"}}},
+			}, nil)
+			endpoints, err := resolveHTTPSEndpoints(context.Background(), lookup, "example.org:443", "h3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoints).To(Equal([]string{"cdn.example.net:443"}))
+		})
+
+		It("limits the length of alias chains", func() {
+			lookup := lookupFrom(map[string][]HTTPSRecord{
+				"example.org": {{Priority: 0, Target: "example.org"}},
+			}, nil)
+			_, err := resolveHTTPSEndpoints(context.Background(), lookup, "example.org:443", "h3")
+			Expect(err).To(MatchError("http3: too many HTTPS AliasMode records"))
+		})
+
+		It("skips endpoints with unsupported mandatory parameters", func() {
+			lookup := lookupFrom(map[string][]HTTPSRecord{
+				"example.org": {
+					{Priority: 1, Target: "ech.example.org", ALPN: []string{"h3"}, Mandatory: []uint16{svcParamECH}, ECHConfigList: []byte("foo")},
+					{Priority: 2, Target: "port.example.org", ALPN: []string{"h3"}, Mandatory: []uint16{svcParamPort}, Port: 1234},
+				},
+			}, nil)
+			endpoints, err := resolveHTTPSEndpoints(context.Background(), lookup, "example.org:443", "h3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoints).To(Equal([]string{"port.example.org:1234"}))
+		})
+	})
+
+	Context("dialing", func() {
+		var dialed []string
+
+		dial := func(_ context.Context, addr string, tlsConf *tls.Config, _ *quic.Config) (quic.EarlyConnection, error) {
+			dialed = append(dialed, addr+" "+tlsConf.ServerName)
+			return nil, errors.New("handshake failed")
+		}
+
+		BeforeEach(func() { dialed = nil })
+
+		It("tries all endpoints, validating the certificate for the origin", func() {
+			lookup := func(context.Context, string) ([]HTTPSRecord, error) {
+				return []HTTPSRecord{
+					{Priority: 1, Target: "a.example.net", ALPN: []string{"h3"}},
+					{Priority: 2, Target: "b.example.net", ALPN: []string{"h3"}},
+				}, nil
+			}
+			_, err := dialHTTPSEndpoints(lookup, dial)(context.Background(), "example.org:443", &tls.Config{NextProtos: []string{"h3"}}, nil)
+			Expect(err).To(MatchError("handshake failed"))
+			Expect(dialed).To(Equal([]string{"a.example.net:443 example.org", "b.example.net:443 example.org"}))
+		})
+
+		It("dials the origin if the lookup fails", func() {
+			lookup := func(context.Context, string) ([]HTTPSRecord, error) { return nil, errors.New("DNS error") }
+			_, err := dialHTTPSEndpoints(lookup, dial)(context.Background(), "example.org:443", &tls.Config{NextProtos: []string{"h3"}}, nil)
+			Expect(err).To(MatchError("handshake failed"))
+			Expect(dialed).To(Equal([]string{"example.org:443 "}))
+		})
+
+		It("is used by the RoundTripper", func() {
+			rt := &RoundTripper{
+				Dial: dial,
+				LookupHTTPS: func(_ context.Context, name string) ([]HTTPSRecord, error) {
+					Expect(name).To(Equal("example.org"))
+					return []HTTPSRecord{{Priority: 1, Target: ".", ALPN: []string{"h3"}, Port: 4433}}, nil
+				},
+			}
+			defer rt.Close()
+			req, err := http.NewRequest(http.MethodGet, "https://example.org/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).To(MatchError("handshake failed"))
+			Expect(dialed).To(Equal([]string{"example.org:4433 example.org"}))
+		})
+	})
+
+	Context("querying", func() {
+		var (
+			server    *net.UDPConn
+			questions chan dnsmessage.Question
+			answers   chan []HTTPSRecord
+		)
+
+		BeforeEach(func() {
+			questions = make(chan dnsmessage.Question, 1)
+			answers = make(chan []HTTPSRecord, 1)
+			questions := questions
+			answers := answers
+			var err error
+			server, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			go func() {
+				defer GinkgoRecover()
+				b := make([]byte, 1500)
+				for {
+					n, addr, err := server.ReadFrom(b)
+					if err != nil {
+						return
+					}
+					var p dnsmessage.Parser
+					hdr, err := p.Start(b[:n])
+					Expect(err).ToNot(HaveOccurred())
+					q, err := p.Question()
+					Expect(err).ToNot(HaveOccurred())
+					builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: hdr.ID, Response: true})
+					Expect(builder.StartQuestions()).To(Succeed())
+					Expect(builder.Question(q)).To(Succeed())
+					Expect(builder.StartAnswers()).To(Succeed())
+					Expect(builder.AResource(
+						dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET},
+						dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+					)).To(Succeed())
+					questions <- q
+					for _, r := range <-answers {
+						Expect(builder.UnknownResource(
+							dnsmessage.ResourceHeader{Name: q.Name, Type: dnsTypeHTTPS, Class: dnsmessage.ClassINET},
+							dnsmessage.UnknownResource{Type: dnsTypeHTTPS, Data: appendHTTPSRecord(nil, r)},
+						)).To(Succeed())
+					}
+					msg, err := builder.Finish()
+					Expect(err).ToNot(HaveOccurred())
+					server.WriteTo(msg, addr)
+				}
+			}()
+		})
+
+		AfterEach(func() { server.Close() })
+
+		It("queries the HTTPS records", func() {
+			record := HTTPSRecord{Priority: 1, Target: ".", ALPN: []string{"h3"}, Port: 443}
+			answers <- []HTTPSRecord{record}
+			records, err := lookupHTTPS(context.Background(), server.LocalAddr().String(), "example.org")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(Equal([]HTTPSRecord{record}))
+			var q dnsmessage.Question
+			Expect(questions).To(Receive(&q))
+			Expect(q.Name.String()).To(Equal("example.org."))
+			Expect(q.Type).To(Equal(dnsTypeHTTPS))
+		})
+
+		It("returns when the context is canceled", func() {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			errChan := make(chan error, 1)
+			go func() {
+				_, err := lookupHTTPS(ctx, conn.LocalAddr().String(), "example.org")
+				errChan <- err
+			}()
+			Consistently(errChan).ShouldNot(Receive())
+			cancel()
+			Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+		})
+	})
+
+	It("parses responses", func() {
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1337, Response: true, RCode: dnsmessage.RCodeNameError})
+		msg, err := builder.Finish()
+		Expect(err).ToNot(HaveOccurred())
+		records, truncated, err := parseHTTPSResponse(msg, 1337)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(truncated).To(BeFalse())
+		Expect(records).To(BeEmpty())
+		_, _, err = parseHTTPSResponse(msg, 42)
+		Expect(err).To(MatchError("http3: unexpected DNS response"))
+
+		builder = dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1337, Response: true, Truncated: true})
+		msg, err = builder.Finish()
+		Expect(err).ToNot(HaveOccurred())
+		_, truncated, err = parseHTTPSResponse(msg, 1337)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(truncated).To(BeTrue())
+
+		builder = dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1337, Response: true, RCode: dnsmessage.RCodeServerFailure})
+		msg, err = builder.Finish()
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = parseHTTPSResponse(msg, 1337)
+		Expect(err).To(MatchError("http3: DNS query failed: RCodeServerFailure"))
+	})
+
+	It("sends queries over TCP", func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		go func() {
+			defer GinkgoRecover()
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			b := make([]byte, 1500)
+			n, err := conn.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(int(binary.BigEndian.Uint16(b))).To(Equal(n - 2))
+			conn.Write([]byte{0, 3, 'f', 'o', 'o'})
+		}()
+		rsp, err := dnsExchange(context.Background(), "tcp", ln.Addr().String(), []byte("query"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rsp).To(Equal([]byte("foo")))
+	})
+})
@@ -0,0 +1,133 @@
+package http3
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// defaultKeepAlivePeriod is the default value of RoundTripper.KeepAlivePeriod.
+// It is half the default QUIC idle timeout.
+const defaultKeepAlivePeriod = 15 * time.Second
+
+// frameTypeKeepAlive is the frame type used for keep-alives.
+// It is reserved for greasing (RFC 9114, Section 7.2.8), so the peer ignores the frame.
+const frameTypeKeepAlive = 0x21
+
+// appendKeepAliveFrame appends an empty frame of a reserved type, which is sent on the control stream as a keep-alive.
+func appendKeepAliveFrame(b *bytes.Buffer) {
+	quicvarint.Write(b, frameTypeKeepAlive)
+	quicvarint.Write(b, 0)
+}
+
+// keepAlive periodically sends a keep-alive on a connection while requests are outstanding,
+// and for a grace period after the last request completed,
+// such that the connection doesn't hit the QUIC idle timeout.
+// Once the grace period has passed, no keep-alives are sent until the next request starts.
+type keepAlive struct {
+	period    time.Duration
+	idleGrace time.Duration
+	send      func() error
+
+	mutex     sync.Mutex
+	started   bool
+	closed    bool
+	timer     *time.Timer // nil if no keep-alive is scheduled
+	active    int         // the number of outstanding requests
+	idleSince time.Time
+}
+
+func newKeepAlive(period, idleGrace time.Duration, send func() error) *keepAlive {
+	return &keepAlive{
+		period:    period,
+		idleGrace: idleGrace,
+		send:      send,
+		idleSince: time.Now(),
+	}
+}
+
+// start starts sending keep-alives.
+// It is called once the control stream was opened.
+func (k *keepAlive) start() {
+	if k == nil {
+		return
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.started = true
+	k.maybeSchedule()
+}
+
+// maybeSchedule schedules the next keep-alive, unless one is scheduled already,
+// or unless the connection was idle for longer than the grace period.
+// It must be called with the mutex held.
+func (k *keepAlive) maybeSchedule() {
+	if !k.started || k.closed || k.timer != nil {
+		return
+	}
+	if k.active == 0 && time.Since(k.idleSince) >= k.idleGrace {
+		return
+	}
+	k.timer = time.AfterFunc(k.period, k.fire)
+}
+
+func (k *keepAlive) fire() {
+	k.mutex.Lock()
+	k.timer = nil
+	if k.closed || (k.active == 0 && time.Since(k.idleSince) >= k.idleGrace) {
+		k.mutex.Unlock()
+		return
+	}
+	k.mutex.Unlock()
+
+	if err := k.send(); err != nil {
+		// the connection was closed
+		k.close()
+		return
+	}
+	k.mutex.Lock()
+	k.maybeSchedule()
+	k.mutex.Unlock()
+}
+
+func (k *keepAlive) requestStarted() {
+	if k == nil {
+		return
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.active++
+	k.maybeSchedule()
+}
+
+func (k *keepAlive) requestDone() {
+	if k == nil {
+		return
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.active--
+	if k.active == 0 {
+		k.idleSince = time.Now()
+	}
+}
+
+// close stops sending keep-alives.
+func (k *keepAlive) close() {
+	if k == nil {
+		return
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.closed = true
+	if k.timer != nil {
+		k.timer.Stop()
+		k.timer = nil
+	}
+}
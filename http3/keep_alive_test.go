@@ -0,0 +1,107 @@
+package http3
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keep-Alives", func() {
+	period := scaleDuration(10 * time.Millisecond)
+
+	It("writes keep-alive frames that are skipped by the frame parser", func() {
+		buf := &bytes.Buffer{}
+		appendKeepAliveFrame(buf)
+		(&dataFrame{Length: 6}).Write(buf)
+		t, err := quicvarint.Read(bytes.NewReader(buf.Bytes()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t).To(BeEquivalentTo(frameTypeKeepAlive))
+		f, err := parseNextFrame(buf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).To(Equal(&dataFrame{Length: 6}))
+	})
+
+	It("only sends keep-alives while requests are outstanding", func() {
+		var sent int32
+		k := newKeepAlive(period, 0, func() error {
+			atomic.AddInt32(&sent, 1)
+			return nil
+		})
+		defer k.close()
+		k.start()
+		Consistently(func() int32 { return atomic.LoadInt32(&sent) }, 5*period).Should(BeZero())
+		k.requestStarted()
+		Eventually(func() int32 { return atomic.LoadInt32(&sent) }).Should(BeNumerically(">=", 3))
+		k.requestDone()
+		time.Sleep(2 * period) // wait for the timer that might have been scheduled already
+		n := atomic.LoadInt32(&sent)
+		Consistently(func() int32 { return atomic.LoadInt32(&sent) }, 5*period).Should(Equal(n))
+	})
+
+	It("doesn't send keep-alives before it is started", func() {
+		var sent int32
+		k := newKeepAlive(period, 0, func() error {
+			atomic.AddInt32(&sent, 1)
+			return nil
+		})
+		defer k.close()
+		k.requestStarted()
+		Consistently(func() int32 { return atomic.LoadInt32(&sent) }, 5*period).Should(BeZero())
+		k.start()
+		Eventually(func() int32 { return atomic.LoadInt32(&sent) }).ShouldNot(BeZero())
+	})
+
+	It("continues sending keep-alives during the idle grace period", func() {
+		var sent int32
+		k := newKeepAlive(period, 10*period, func() error {
+			atomic.AddInt32(&sent, 1)
+			return nil
+		})
+		defer k.close()
+		k.start()
+		Eventually(func() int32 { return atomic.LoadInt32(&sent) }).Should(BeNumerically(">=", 3))
+		// the grace period expires
+		time.Sleep(12 * period)
+		n := atomic.LoadInt32(&sent)
+		Consistently(func() int32 { return atomic.LoadInt32(&sent) }, 5*period).Should(Equal(n))
+		Expect(n).To(BeNumerically("<=", 11))
+	})
+
+	It("stops when sending fails", func() {
+		var sent int32
+		k := newKeepAlive(period, 0, func() error {
+			atomic.AddInt32(&sent, 1)
+			return errors.New("connection closed")
+		})
+		k.requestStarted()
+		k.start()
+		Eventually(func() int32 { return atomic.LoadInt32(&sent) }).Should(BeEquivalentTo(1))
+		Consistently(func() int32 { return atomic.LoadInt32(&sent) }, 5*period).Should(BeEquivalentTo(1))
+	})
+
+	It("stops when closed", func() {
+		var sent int32
+		k := newKeepAlive(period, 0, func() error {
+			atomic.AddInt32(&sent, 1)
+			return nil
+		})
+		k.requestStarted()
+		k.start()
+		k.close()
+		Consistently(func() int32 { return atomic.LoadInt32(&sent) }, 5*period).Should(BeZero())
+	})
+
+	It("handles nil values", func() {
+		var k *keepAlive
+		k.start()
+		k.requestStarted()
+		k.requestDone()
+		k.close()
+	})
+})
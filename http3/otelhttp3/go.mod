@@ -0,0 +1,15 @@
+module github.com/lucas-clemente/quic-go/http3/otelhttp3
+
+go 1.16
+
+require (
+	// The version doesn't matter here, as we're replacing it with the currently checked out code anyway.
+	github.com/lucas-clemente/quic-go v0.27.0
+	github.com/onsi/ginkgo v1.16.4
+	github.com/onsi/gomega v1.13.0
+	go.opentelemetry.io/otel v1.11.2
+	go.opentelemetry.io/otel/sdk v1.11.2
+	go.opentelemetry.io/otel/trace v1.11.2
+)
+
+replace github.com/lucas-clemente/quic-go => ../../
@@ -0,0 +1,130 @@
+package otelhttp3
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type handler struct {
+	handler     http.Handler
+	operation   string
+	tracer      trace.Tracer
+	propagators propagation.TextMapPropagator
+}
+
+// NewHandler wraps h, creating a server span named operation for every request.
+// The trace context sent by the client is used as the parent of the span.
+// The http.ResponseWriter passed to h implements Unwrap, such that the http.ResponseController,
+// CONNECT tunnels and WebTransport sessions can be used.
+func NewHandler(h http.Handler, operation string, opts ...Option) http.Handler {
+	c := newConfig(opts)
+	return &handler{
+		handler:     h,
+		operation:   operation,
+		tracer:      c.tracer(),
+		propagators: c.propagators,
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := h.propagators.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	attrs := append(requestAttributes(r), semconv.HTTPTargetKey.String(r.RequestURI))
+	attrs = append(attrs, serverQUICAttributes(r.Context())...)
+	ctx, span := h.tracer.Start(ctx, h.operation,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	rw := &responseWriter{ResponseWriter: w}
+	h.handler.ServeHTTP(rw, r.WithContext(ctx))
+
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+}
+
+func serverQUICAttributes(ctx context.Context) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if id, ok := ctx.Value(http3.StreamIDContextKey).(quic.StreamID); ok {
+		attrs = append(attrs, QUICStreamIDKey.Int64(int64(id)))
+	}
+	conn, ok := ctx.Value(http3.ConnectionContextKey).(quic.EarlyConnection)
+	if !ok {
+		return attrs
+	}
+	select {
+	case <-conn.HandshakeComplete().Done():
+		attrs = append(attrs,
+			versionAttribute(conn),
+			EarlyDataKey.Bool(false),
+			EarlyDataAcceptedKey.Bool(conn.ConnectionState().TLS.Used0RTT),
+		)
+	default:
+		// Requests received before completion of the handshake were sent in 0-RTT data.
+		// Don't call ConnectionState, since it blocks until the handshake completes.
+		attrs = append(attrs, EarlyDataKey.Bool(true), EarlyDataAcceptedKey.Bool(true))
+	}
+	return attrs
+}
+
+// responseWriter records the status code of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+var (
+	_ http.Flusher  = &responseWriter{}
+	_ io.ReaderFrom = &responseWriter{}
+)
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the http.ResponseWriter of the http3 server.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
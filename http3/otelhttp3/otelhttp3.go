@@ -0,0 +1,81 @@
+// Package otelhttp3 provides OpenTelemetry instrumentation for HTTP/3 clients and servers.
+//
+// It creates a span for every request, records QUIC-specific attributes
+// (the QUIC version, the stream ID and the use of 0-RTT),
+// and propagates the trace context in the request headers.
+// quic-go doesn't expose the connection IDs, so they are not recorded.
+package otelhttp3
+
+import (
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/lucas-clemente/quic-go/http3/otelhttp3"
+
+// Keys of the QUIC-specific attributes.
+const (
+	// QUICVersionKey is the QUIC version used on the connection, e.g. "v1".
+	QUICVersionKey = attribute.Key("quic.version")
+	// QUICStreamIDKey is the ID of the request stream.
+	QUICStreamIDKey = attribute.Key("quic.stream_id")
+	// EarlyDataKey says if the request was sent in 0-RTT data.
+	EarlyDataKey = attribute.Key("quic.early_data")
+	// EarlyDataAcceptedKey says if the server accepted 0-RTT data on the connection.
+	EarlyDataAcceptedKey = attribute.Key("quic.early_data.accepted")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	propagators    propagation.TextMapPropagator
+}
+
+// An Option configures the instrumentation.
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider used to create spans.
+// If not set, the global TracerProvider is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tracerProvider = tp }
+}
+
+// WithPropagators sets the propagators used to inject and extract the trace context.
+// If not set, the global propagators are used.
+func WithPropagators(p propagation.TextMapPropagator) Option {
+	return func(c *config) { c.propagators = p }
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		propagators:    otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *config) tracer() trace.Tracer {
+	return c.tracerProvider.Tracer(instrumentationName)
+}
+
+func requestAttributes(req *http.Request) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(req.Method),
+		semconv.HTTPHostKey.String(req.Host),
+		semconv.HTTPFlavorHTTP30,
+		semconv.HTTPSchemeHTTPS,
+	}
+}
+
+func versionAttribute(conn quic.Connection) attribute.KeyValue {
+	return QUICVersionKey.String(conn.ConnectionState().Version.String())
+}
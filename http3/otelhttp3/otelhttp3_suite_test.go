@@ -0,0 +1,13 @@
+package otelhttp3
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestOtelHTTP3(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "otelhttp3 Suite")
+}
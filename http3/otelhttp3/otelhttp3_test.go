@@ -0,0 +1,173 @@
+package otelhttp3
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type errorRoundTripper struct{ err error }
+
+func (rt *errorRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, rt.err }
+
+func attributeMap(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+var _ = Describe("OpenTelemetry instrumentation", func() {
+	var (
+		recorder       *tracetest.SpanRecorder
+		opts           []Option
+		server         *http3.Server
+		stoppedServing chan struct{}
+		port           string
+		rt             *http3.RoundTripper
+	)
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		opts = []Option{
+			WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))),
+			WithPropagators(propagation.TraceContext{}),
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})
+		mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		server = &http3.Server{
+			Server: &http.Server{
+				Handler:   NewHandler(mux, "server", opts...),
+				TLSConfig: testdata.GetTLSConfig(),
+			},
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		port = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+		stoppedServing = make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			server.Serve(conn)
+			close(stoppedServing)
+		}()
+
+		rt = &http3.RoundTripper{TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()}}
+	})
+
+	AfterEach(func() {
+		Expect(rt.Close()).To(Succeed())
+		Expect(server.Close()).To(Succeed())
+		Eventually(stoppedServing).Should(BeClosed())
+	})
+
+	get := func(path string) *http.Response {
+		client := &http.Client{Transport: NewTransport(rt, opts...)}
+		rsp, err := client.Get("https://localhost:" + port + path)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = io.ReadAll(rsp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rsp.Body.Close()).To(Succeed())
+		return rsp
+	}
+
+	spanOfKind := func(kind trace.SpanKind) sdktrace.ReadOnlySpan {
+		var span sdktrace.ReadOnlySpan
+		Eventually(func() bool {
+			for _, s := range recorder.Ended() {
+				if s.SpanKind() == kind {
+					span = s
+					return true
+				}
+			}
+			return false
+		}).Should(BeTrue())
+		return span
+	}
+
+	It("creates client and server spans in the same trace", func() {
+		rsp := get("/hello")
+		Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+		client := spanOfKind(trace.SpanKindClient)
+		server := spanOfKind(trace.SpanKindServer)
+		Expect(client.Name()).To(Equal("HTTP GET"))
+		Expect(server.Name()).To(Equal("server"))
+		Expect(server.SpanContext().TraceID()).To(Equal(client.SpanContext().TraceID()))
+		Expect(server.Parent().SpanID()).To(Equal(client.SpanContext().SpanID()))
+		Expect(server.Parent().IsRemote()).To(BeTrue())
+	})
+
+	It("records HTTP and QUIC attributes on the client span", func() {
+		get("/hello")
+		attrs := attributeMap(spanOfKind(trace.SpanKindClient))
+		Expect(attrs).To(HaveKeyWithValue(semconv.HTTPMethodKey, attribute.StringValue(http.MethodGet)))
+		Expect(attrs).To(HaveKeyWithValue(semconv.HTTPStatusCodeKey, attribute.IntValue(http.StatusOK)))
+		Expect(attrs).To(HaveKeyWithValue(semconv.HTTPFlavorKey, attribute.StringValue("3.0")))
+		Expect(attrs).To(HaveKeyWithValue(QUICVersionKey, attribute.StringValue(quic.Version1.String())))
+		Expect(attrs).To(HaveKeyWithValue(QUICStreamIDKey, attribute.Int64Value(0)))
+		Expect(attrs).To(HaveKeyWithValue(EarlyDataKey, attribute.BoolValue(false)))
+		Expect(attrs).To(HaveKeyWithValue(EarlyDataAcceptedKey, attribute.BoolValue(false)))
+	})
+
+	It("records HTTP and QUIC attributes on the server span", func() {
+		get("/hello")
+		span := spanOfKind(trace.SpanKindServer)
+		attrs := attributeMap(span)
+		Expect(attrs).To(HaveKeyWithValue(semconv.HTTPTargetKey, attribute.StringValue("/hello")))
+		Expect(attrs).To(HaveKeyWithValue(semconv.HTTPStatusCodeKey, attribute.IntValue(http.StatusOK)))
+		Expect(attrs).To(HaveKeyWithValue(QUICVersionKey, attribute.StringValue(quic.Version1.String())))
+		Expect(attrs).To(HaveKeyWithValue(QUICStreamIDKey, attribute.Int64Value(0)))
+		Expect(attrs).To(HaveKeyWithValue(EarlyDataKey, attribute.BoolValue(false)))
+		Expect(span.Status().Code).To(Equal(codes.Unset))
+	})
+
+	It("sets the error status for server errors", func() {
+		rsp := get("/fail")
+		Expect(rsp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(spanOfKind(trace.SpanKindServer).Status().Code).To(Equal(codes.Error))
+		Expect(spanOfKind(trace.SpanKindClient).Status().Code).To(Equal(codes.Error))
+	})
+
+	It("records errors returned by the RoundTripper", func() {
+		testErr := errors.New("test error")
+		client := &http.Client{Transport: NewTransport(&errorRoundTripper{err: testErr}, opts...)}
+		_, err := client.Get("https://localhost:" + port + "/hello")
+		Expect(err).To(MatchError(ContainSubstring("test error")))
+		span := spanOfKind(trace.SpanKindClient)
+		Expect(span.Status().Code).To(Equal(codes.Error))
+		Expect(span.Events()).To(HaveLen(1))
+	})
+
+	It("doesn't modify the request", func() {
+		req, err := http.NewRequest(http.MethodGet, "https://localhost:"+port+"/hello", nil)
+		Expect(err).ToNot(HaveOccurred())
+		rsp, err := NewTransport(rt, opts...).RoundTrip(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rsp.Body.Close()).To(Succeed())
+		Expect(req.Header).To(BeEmpty())
+	})
+})
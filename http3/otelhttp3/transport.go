@@ -0,0 +1,72 @@
+package otelhttp3
+
+import (
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go/http3"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport is an http.RoundTripper that creates a client span for every request.
+// The trace context is sent to the server in the request headers.
+type Transport struct {
+	rt          http.RoundTripper
+	tracer      trace.Tracer
+	propagators propagation.TextMapPropagator
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// NewTransport wraps rt, which usually is a http3.RoundTripper or a http3.ClientConn.
+// The span ends when the response header was received.
+func NewTransport(rt http.RoundTripper, opts ...Option) *Transport {
+	c := newConfig(opts)
+	return &Transport{
+		rt:          rt,
+		tracer:      c.tracer(),
+		propagators: c.propagators,
+	}
+}
+
+// RoundTrip sends the request using the wrapped http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attrs := append(requestAttributes(req), semconv.HTTPURLKey.String(req.URL.Redacted()))
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	// don't modify the caller's request
+	req = req.Clone(ctx)
+	t.propagators.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	rsp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rsp.StatusCode))
+	span.SetAttributes(clientQUICAttributes(rsp)...)
+	if rsp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, "")
+	}
+	return rsp, nil
+}
+
+func clientQUICAttributes(rsp *http.Response) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if info, err := http3.ResponseEarlyData(rsp); err == nil {
+		attrs = append(attrs, EarlyDataKey.Bool(info.Sent), EarlyDataAcceptedKey.Bool(info.Accepted))
+	}
+	if conn, id, err := http3.ResponseConnection(rsp); err == nil && conn != nil {
+		attrs = append(attrs, QUICStreamIDKey.Int64(int64(id)), versionAttribute(conn))
+	}
+	return attrs
+}
@@ -0,0 +1,136 @@
+package http3
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// defaultMaxIdleConnsPerHost is the default value of RoundTripper.MaxIdleConnsPerHost.
+const defaultMaxIdleConnsPerHost = 2
+
+// poolableClient is a client that can be used in a connPool.
+type poolableClient interface {
+	roundTripCloser
+	// connect establishes the connection, unless that already happened.
+	connect(context.Context) (bool, error)
+	// canTakeNewRequest says if new requests can be sent on the connection.
+	canTakeNewRequest() bool
+	// getActiveRequests returns the number of requests in flight,
+	// and the time when the connection became idle.
+	getActiveRequests() (int, time.Time)
+}
+
+var _ poolableClient = &client{}
+
+// pooledClient is a client in a connPool.
+type pooledClient struct {
+	poolableClient
+
+	// The number of requests handed to the client, for which RoundTripOpt hasn't returned yet.
+	// This makes sure that we don't use a client for more concurrent requests than allowed,
+	// even before the client started processing those requests.
+	pending int
+}
+
+func (c *pooledClient) isIdle() bool {
+	n, _ := c.getActiveRequests()
+	return n == 0 && c.pending == 0
+}
+
+// connPool holds the connections to a single host.
+// It is not safe for concurrent use, the RoundTripper's mutex needs to be held when using it.
+type connPool struct {
+	clients   []*pooledClient
+	idleTimer *time.Timer
+	// waiting is closed when the state of the pool changes,
+	// to wake up requests waiting for a client. It is created on first use.
+	waiting chan struct{}
+}
+
+// wait returns a channel that is closed on the next call to notify.
+func (p *connPool) wait() <-chan struct{} {
+	if p.waiting == nil {
+		p.waiting = make(chan struct{})
+	}
+	return p.waiting
+}
+
+// notify wakes up all requests waiting for a client.
+func (p *connPool) notify() {
+	if p.waiting != nil {
+		close(p.waiting)
+		p.waiting = nil
+	}
+}
+
+// get returns a client that can take a new request,
+// and has less than maxConcurrent requests in flight (if maxConcurrent is larger than 0).
+func (p *connPool) get(maxConcurrent int) *pooledClient {
+	for _, c := range p.clients {
+		if !c.canTakeNewRequest() {
+			continue
+		}
+		if n, _ := c.getActiveRequests(); maxConcurrent > 0 && n+c.pending >= maxConcurrent {
+			continue
+		}
+		return c
+	}
+	return nil
+}
+
+// removeIdle removes idle clients that can't be used anymore, that have been idle for longer than idleTimeout,
+// or that exceed the maximum number of idle clients.
+// It returns the clients that were removed, and the time when the next idle client expires (if any).
+func (p *connPool) removeIdle(now time.Time, idleTimeout time.Duration, maxIdle int) ([]*pooledClient, time.Time) {
+	type idleClient struct {
+		client *pooledClient
+		since  time.Time
+	}
+	var removed []*pooledClient
+	var idle []idleClient
+	for _, c := range p.clients {
+		n, since := c.getActiveRequests()
+		if n > 0 || c.pending > 0 {
+			continue
+		}
+		if !c.canTakeNewRequest() || (idleTimeout > 0 && now.Sub(since) >= idleTimeout) {
+			removed = append(removed, c)
+			continue
+		}
+		idle = append(idle, idleClient{client: c, since: since})
+	}
+	if maxIdle < 0 {
+		maxIdle = 0
+	}
+	if len(idle) > maxIdle {
+		// keep the clients that were used most recently
+		sort.Slice(idle, func(i, j int) bool { return idle[i].since.After(idle[j].since) })
+		for _, c := range idle[maxIdle:] {
+			removed = append(removed, c.client)
+		}
+		idle = idle[:maxIdle]
+	}
+	for _, c := range removed {
+		p.remove(c)
+	}
+
+	var nextExpiry time.Time
+	if idleTimeout > 0 {
+		for _, c := range idle {
+			if t := c.since.Add(idleTimeout); nextExpiry.IsZero() || t.Before(nextExpiry) {
+				nextExpiry = t
+			}
+		}
+	}
+	return removed, nextExpiry
+}
+
+func (p *connPool) remove(c *pooledClient) {
+	for i, cl := range p.clients {
+		if cl == c {
+			p.clients = append(p.clients[:i], p.clients[i+1:]...)
+			return
+		}
+	}
+}
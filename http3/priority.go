@@ -0,0 +1,127 @@
+package http3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Extensible Prioritization Scheme for HTTP, as defined in RFC 9218.
+const (
+	// DefaultUrgency is the urgency of requests that don't carry a priority signal.
+	DefaultUrgency = 3
+	// the lowest urgency
+	maxUrgency = 7
+)
+
+// A Priority is the priority of a request.
+type Priority struct {
+	// Urgency ranges from 0 (the highest priority) to 7 (the lowest priority).
+	// Note that the default urgency is DefaultUrgency, not 0.
+	Urgency uint8
+	// Incremental indicates that the response can be processed incrementally,
+	// so the server can interleave it with other responses of the same urgency.
+	Incremental bool
+}
+
+// String returns the Priority Field Value, as sent in the Priority header field and in PRIORITY_UPDATE frames.
+// Parameters that have their default values are omitted.
+func (p Priority) String() string {
+	var params []string
+	if p.Urgency != DefaultUrgency {
+		params = append(params, fmt.Sprintf("u=%d", p.Urgency))
+	}
+	if p.Incremental {
+		params = append(params, "i")
+	}
+	return strings.Join(params, ", ")
+}
+
+func (p Priority) validate() error {
+	if p.Urgency > maxUrgency {
+		return fmt.Errorf("http3: invalid urgency: %d", p.Urgency)
+	}
+	return nil
+}
+
+type priorityUpdater interface {
+	updatePriority(Priority) error
+}
+
+var priorityContextKey = &contextKey{"priority"}
+
+// RequestPriority returns the priority of a request received by a Server.
+// This is the priority sent in the Priority header field, unless the client changed it since
+// using a PRIORITY_UPDATE frame, or the handler changed it using UpdateResponsePriority.
+// For requests that were not received by a Server, the Priority header field is parsed.
+func RequestPriority(req *http.Request) Priority {
+	if priority, ok := req.Context().Value(priorityContextKey).(func() Priority); ok {
+		return priority()
+	}
+	return ParsePriority(req.Header.Get("Priority"))
+}
+
+// UpdateResponsePriority changes the priority used to schedule the response written to w,
+// overriding the priority requested by the client.
+// w must be the http.ResponseWriter passed to the handler by the Server.
+// Pushed responses are not scheduled, and their priority can't be changed.
+func UpdateResponsePriority(w http.ResponseWriter, p Priority) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	u, ok := w.(priorityUpdater)
+	if !ok {
+		return errors.New("http3: response doesn't support priority updates")
+	}
+	return u.updatePriority(p)
+}
+
+// UpdatePriority changes the priority of the request that rsp is the response to,
+// by sending a PRIORITY_UPDATE frame to the server.
+// It can be used while the response body is being read.
+// rsp must be a response returned by the RoundTripper.
+func UpdatePriority(rsp *http.Response, p Priority) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	u, ok := rsp.Body.(priorityUpdater)
+	if !ok {
+		return errors.New("http3: response doesn't support priority updates")
+	}
+	return u.updatePriority(p)
+}
+
+// ParsePriority parses a Priority Field Value, as sent in the Priority header field and in PRIORITY_UPDATE frames.
+// Unknown parameters and parameters with invalid values are ignored (RFC 9218, Section 4),
+// so the default priority is returned for an empty or invalid value.
+func ParsePriority(s string) Priority {
+	p := Priority{Urgency: DefaultUrgency}
+	for _, member := range strings.Split(s, ",") {
+		// parameters of dictionary members are ignored
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i]
+		}
+		key, value := strings.TrimSpace(member), ""
+		if i := strings.IndexByte(key, '='); i >= 0 {
+			key, value = key[:i], key[i+1:]
+		}
+		switch key {
+		case "u":
+			u, err := strconv.ParseUint(value, 10, 8)
+			if err != nil || u > maxUrgency {
+				continue
+			}
+			p.Urgency = uint8(u)
+		case "i":
+			switch value {
+			case "", "?1":
+				p.Incremental = true
+			case "?0":
+				p.Incremental = false
+			}
+		}
+	}
+	return p
+}
@@ -0,0 +1,157 @@
+package http3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Priorities", func() {
+	It("serializes priorities", func() {
+		Expect(Priority{Urgency: DefaultUrgency}.String()).To(BeEmpty())
+		Expect(Priority{Urgency: 1}.String()).To(Equal("u=1"))
+		Expect(Priority{Urgency: DefaultUrgency, Incremental: true}.String()).To(Equal("i"))
+		Expect(Priority{Urgency: 7, Incremental: true}.String()).To(Equal("u=7, i"))
+	})
+
+	It("parses priorities", func() {
+		Expect(ParsePriority("")).To(Equal(Priority{Urgency: DefaultUrgency}))
+		Expect(ParsePriority("u=1")).To(Equal(Priority{Urgency: 1}))
+		Expect(ParsePriority("i")).To(Equal(Priority{Urgency: DefaultUrgency, Incremental: true}))
+		Expect(ParsePriority("u=7, i")).To(Equal(Priority{Urgency: 7, Incremental: true}))
+		Expect(ParsePriority("i=?1,u=0")).To(Equal(Priority{Urgency: 0, Incremental: true}))
+		Expect(ParsePriority("u=2, i=?0")).To(Equal(Priority{Urgency: 2}))
+	})
+
+	It("ignores unknown and invalid parameters when parsing priorities", func() {
+		Expect(ParsePriority("u=8, i")).To(Equal(Priority{Urgency: DefaultUrgency, Incremental: true}))
+		Expect(ParsePriority("u=foo")).To(Equal(Priority{Urgency: DefaultUrgency}))
+		Expect(ParsePriority("foo=bar, u=1;baz")).To(Equal(Priority{Urgency: 1}))
+	})
+
+	It("writes and parses PRIORITY_UPDATE frames", func() {
+		buf := &bytes.Buffer{}
+		(&priorityUpdateFrame{ElementID: 1337, PriorityFieldValue: "u=1, i"}).Write(buf)
+		(&priorityUpdateFrame{IsPush: true, ElementID: 42, PriorityFieldValue: "u=5"}).Write(buf)
+		f, err := parseNextFrame(buf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).To(Equal(&priorityUpdateFrame{ElementID: 1337, PriorityFieldValue: "u=1, i"}))
+		f, err = parseNextFrame(buf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).To(Equal(&priorityUpdateFrame{IsPush: true, ElementID: 42, PriorityFieldValue: "u=5"}))
+	})
+
+	It("rejects PRIORITY_UPDATE frames that are too large", func() {
+		buf := &bytes.Buffer{}
+		(&priorityUpdateFrame{ElementID: 1337, PriorityFieldValue: strings.Repeat("a", maxPriorityUpdateFrameLen)}).Write(buf)
+		_, err := parseNextFrame(buf, nil)
+		Expect(err).To(MatchError(fmt.Sprintf("PRIORITY_UPDATE frame too large: %d bytes", maxPriorityUpdateFrameLen+2)))
+	})
+
+	It("writes PRIORITY_UPDATE frames", func() {
+		buf := &bytes.Buffer{}
+		(&priorityUpdateFrame{ElementID: 1337, PriorityFieldValue: "u=1, i"}).Write(buf)
+		r := quicvarint.NewReader(buf)
+		t, err := quicvarint.Read(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t).To(BeEquivalentTo(frameTypePriorityUpdateRequest))
+		l, err := quicvarint.Read(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(l).To(BeEquivalentTo(buf.Len()))
+		id, err := quicvarint.Read(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(id).To(BeEquivalentTo(1337))
+		Expect(buf.String()).To(Equal("u=1, i"))
+	})
+
+	It("writes PRIORITY_UPDATE frames for pushes", func() {
+		buf := &bytes.Buffer{}
+		(&priorityUpdateFrame{IsPush: true, ElementID: 42}).Write(buf)
+		t, err := quicvarint.Read(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t).To(BeEquivalentTo(frameTypePriorityUpdatePush))
+	})
+
+	Context("updating the priority of a request", func() {
+		var (
+			cl         *client
+			controlBuf *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			var err error
+			cl, err = newClient("quic.clemente.io:443", nil, &roundTripperOpts{}, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			cl.conn = conn
+			controlBuf = &bytes.Buffer{}
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write).AnyTimes()
+			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
+			Expect(cl.setupConn()).To(Succeed())
+			controlBuf.Reset() // skip the stream type and the SETTINGS frame
+		})
+
+		It("sends a PRIORITY_UPDATE frame on the control stream", func() {
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(8)).AnyTimes()
+			body := newResponseBody(str, nil, nil, func() {})
+			body.onPriorityUpdate = func(p Priority) error { return cl.sendPriorityUpdate(str.StreamID(), p) }
+			rsp := &http.Response{Body: newGzipReader(body)}
+			Expect(UpdatePriority(rsp, Priority{Urgency: 5})).To(Succeed())
+			expected := &bytes.Buffer{}
+			(&priorityUpdateFrame{ElementID: 8, PriorityFieldValue: "u=5"}).Write(expected)
+			Expect(controlBuf.Bytes()).To(Equal(expected.Bytes()))
+		})
+
+		It("refuses invalid priorities", func() {
+			rsp := &http.Response{Body: newResponseBody(nil, nil, nil, func() {})}
+			Expect(UpdatePriority(rsp, Priority{Urgency: 8})).To(MatchError("http3: invalid urgency: 8"))
+		})
+	})
+
+	It("doesn't update the priority of responses not returned by the RoundTripper", func() {
+		rsp := &http.Response{Body: io.NopCloser(&bytes.Buffer{})}
+		Expect(UpdatePriority(rsp, Priority{Urgency: 1})).To(MatchError("http3: response doesn't support priority updates"))
+	})
+
+	Context("request priorities on the server side", func() {
+		It("parses the Priority header field of requests not received by a Server", func() {
+			req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(RequestPriority(req)).To(Equal(Priority{Urgency: DefaultUrgency}))
+			req.Header.Set("Priority", "u=1, i")
+			Expect(RequestPriority(req)).To(Equal(Priority{Urgency: 1, Incremental: true}))
+		})
+
+		It("updates the priority of a response", func() {
+			var priority Priority
+			rw := newResponseWriter(nil, nil, nil)
+			rw.setPriority = func(p Priority) { priority = p }
+			Expect(UpdateResponsePriority(rw, Priority{Urgency: 6, Incremental: true})).To(Succeed())
+			Expect(priority).To(Equal(Priority{Urgency: 6, Incremental: true}))
+			Expect(UpdateResponsePriority(rw, Priority{Urgency: 8})).To(MatchError("http3: invalid urgency: 8"))
+		})
+
+		It("doesn't update the priority of pushed responses", func() {
+			rw := newResponseWriter(nil, nil, nil)
+			Expect(UpdateResponsePriority(rw, Priority{Urgency: 1})).To(MatchError("http3: response doesn't support priority updates"))
+		})
+
+		It("doesn't update the priority of responses not written by a Server", func() {
+			Expect(UpdateResponsePriority(httptest.NewRecorder(), Priority{Urgency: 1})).To(MatchError("http3: response doesn't support priority updates"))
+		})
+	})
+})
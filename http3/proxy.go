@@ -0,0 +1,128 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// tunnelCounter is used to assign unique local addresses to tunnels.
+var tunnelCounter uint64
+
+// tunnelPacketConn is a UDP proxying tunnel used for a QUIC connection.
+// quic-go identifies packet conns by their local address, so every tunnel needs to use a different one.
+type tunnelPacketConn struct {
+	net.PacketConn
+	localAddr net.Addr
+}
+
+func (c *tunnelPacketConn) LocalAddr() net.Addr { return c.localAddr }
+
+// proxyTemplate returns the URI template used for UDP proxying requests to proxy.
+func proxyTemplate(proxy *url.URL) string {
+	if !strings.Contains(proxy.Path, "{target_host}") && !strings.Contains(proxy.RawQuery, "{target_host}") {
+		return "https://" + proxy.Host + DefaultConnectUDPTemplate
+	}
+	// Don't use proxy.String(), since that would escape the braces of the variables.
+	template := "https://" + proxy.Host + proxy.Path
+	if proxy.RawQuery != "" {
+		template += "?" + proxy.RawQuery
+	}
+	return template
+}
+
+// newProxyRequest creates the UDP proxying request used to reach target through proxy.
+func newProxyRequest(ctx context.Context, proxy *url.URL, target string) (*http.Request, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(expandConnectUDPTemplate(proxyTemplate(proxy), host, port))
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{"Capsule-Protocol": {"?1"}}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	return (&http.Request{
+		Method: http.MethodConnect,
+		Proto:  protocolConnectUDP,
+		URL:    u,
+		Host:   u.Host,
+		Header: header,
+	}).WithContext(ctx), nil
+}
+
+// getProxyRoundTripper returns the RoundTripper used for the connections to proxies.
+func (r *RoundTripper) getProxyRoundTripper() *RoundTripper {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.proxyRT == nil {
+		var tlsConf *tls.Config
+		if r.TLSClientConfig != nil {
+			// the ServerName is set for the target, not for the proxy
+			tlsConf = r.TLSClientConfig.Clone()
+			tlsConf.ServerName = ""
+		}
+		var quicConf *quic.Config
+		if r.QuicConfig != nil {
+			// HTTP datagrams are enabled on the connection to the proxy, but not necessarily on the connection to the target
+			quicConf = r.QuicConfig.Clone()
+		}
+		r.proxyRT = &RoundTripper{
+			TLSClientConfig:     tlsConf,
+			QuicConfig:          quicConf,
+			EnableDatagrams:     true,
+			Dial:                r.Dial,
+			PacketConn:          r.PacketConn,
+			MaxIdleConnsPerHost: r.MaxIdleConnsPerHost,
+			IdleConnTimeout:     r.IdleConnTimeout,
+		}
+	}
+	return r.proxyRT
+}
+
+// dialProxy establishes a QUIC connection to addr, through a UDP proxying tunnel.
+func (r *RoundTripper) dialProxy(ctx context.Context, proxy *url.URL, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+	req, err := newProxyRequest(ctx, proxy, addr)
+	if err != nil {
+		return nil, err
+	}
+	c, release, err := r.getProxyRoundTripper().getExtendedConnectClient(req)
+	if err != nil {
+		return nil, err
+	}
+	remoteAddr := connectUDPAddr(addr)
+	_, pconn, err := c.dialConnectUDP(req, remoteAddr)
+	release()
+	if err != nil {
+		return nil, err
+	}
+	pconn = &tunnelPacketConn{
+		PacketConn: pconn,
+		localAddr:  connectUDPAddr(fmt.Sprintf("tunnel-%d", atomic.AddUint64(&tunnelCounter, 1))),
+	}
+	conn, err := quic.DialEarlyContext(ctx, pconn, remoteAddr, addr, tlsConf, conf)
+	if err != nil {
+		pconn.Close()
+		return nil, err
+	}
+	// The tunnel is not closed by quic-go, since we created the packet conn.
+	go func() {
+		<-conn.Context().Done()
+		pconn.Close()
+	}()
+	return conn, nil
+}
@@ -0,0 +1,634 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http2/hpack"
+)
+
+// errHeaderListTooLarge is returned when a decoded field section exceeds the maximum header list size.
+var errHeaderListTooLarge = errors.New("header list too large")
+
+var (
+	errIntegerOverflow = errors.New("integer overflow")
+	errStringTooLong   = errors.New("string literal too long")
+)
+
+// isEncodingError says if err is caused by an invalid integer or string literal.
+func isEncodingError(err error) bool {
+	return err == errIntegerOverflow || errors.Is(err, errStringTooLong) || err == hpack.ErrInvalidHuffman
+}
+
+// A qpackError is a QPACK error that requires closing the connection (RFC 9204, Section 6).
+type qpackError struct {
+	code errorCode
+	err  error
+}
+
+func (e *qpackError) Error() string { return e.err.Error() }
+func (e *qpackError) Unwrap() error { return e.err }
+
+func newDecompressionError(format string, a ...interface{}) error {
+	return &qpackError{code: errorQPACKDecompressionFailed, err: fmt.Errorf(format, a...)}
+}
+
+func newEncoderStreamError(format string, a ...interface{}) error {
+	return &qpackError{code: errorQPACKEncoderStreamError, err: fmt.Errorf(format, a...)}
+}
+
+// decodingRequestError converts an error returned by qpackDecoder.decode into a requestError.
+func decodingRequestError(err error) requestError {
+	var qerr *qpackError
+	if errors.As(err, &qerr) {
+		return newConnError(qerr.code, err)
+	}
+	if err == errHeaderListTooLarge {
+		return newStreamError(errorExcessiveLoad, err)
+	}
+	if err == context.Canceled {
+		return newStreamError(errorRequestCanceled, err)
+	}
+	// the decoder was closed, or the deadline for receiving the request headers expired
+	return newStreamError(errorRequestIncomplete, err)
+}
+
+// handleEncoderStream processes the peer's encoder stream, until the stream or the connection is closed.
+// If the encoder stream contains invalid instructions, the connection is closed.
+func handleEncoderStream(conn quic.Connection, str quic.ReceiveStream, decoder *qpackDecoder, strict *strictMode) {
+	err := decoder.readEncoderStream(str)
+	var qerr *qpackError
+	if errors.As(err, &qerr) {
+		strict.connectionError(conn, str, qerr.code, qerr.Error())
+		return
+	}
+	strict.criticalStreamFailed(conn, str, err)
+}
+
+// staticTable is the QPACK static table (RFC 9204, Appendix A).
+// The qpack package doesn't export it, so it is obtained by decoding a field section for every entry.
+var staticTable = func() []qpack.HeaderField {
+	var table []qpack.HeaderField
+	for {
+		// a field section prefix for Required Insert Count 0, followed by an indexed field line referencing the static table
+		b := appendPrefixedInt([]byte{0, 0}, 0xc0, 6, uint64(len(table)))
+		hfs, err := qpack.NewDecoder(nil).DecodeFull(b)
+		if err != nil {
+			return table
+		}
+		table = append(table, hfs[0])
+	}
+}()
+
+// qpackDecoder decodes field sections.
+// In addition to the static table supported by the qpack package, it implements the dynamic table (RFC 9204):
+// It processes the instructions received on the peer's encoder stream, and sends instructions on the decoder stream.
+type qpackDecoder struct {
+	// maxTableCapacity is the maximum capacity of the dynamic table, sent in SETTINGS_QPACK_MAX_TABLE_CAPACITY.
+	// If it is 0, the peer can't use the dynamic table.
+	maxTableCapacity uint64
+	// maxBlockedStreams is the maximum number of streams that can be blocked, sent in SETTINGS_QPACK_BLOCKED_STREAMS.
+	maxBlockedStreams uint64
+	// maxHeaderListSize is the maximum size of a decoded field section (RFC 9114, Section 4.2.2).
+	// If it is 0, the size is not limited.
+	maxHeaderListSize uint64
+
+	// openStream opens the decoder stream.
+	// It is only called once the first decoder instruction is sent.
+	openStream func() (quic.SendStream, error)
+
+	mutex          sync.Mutex
+	capacity       uint64
+	size           uint64
+	entries        []qpack.HeaderField // the dynamic table, the oldest entry first
+	evicted        uint64              // the number of evicted entries, i.e. the absolute index of entries[0]
+	insertCount    uint64
+	ackedInserts   uint64 // the Known Received Count of the encoder
+	blockedStreams uint64
+	inserted       chan struct{} // closed (and replaced) when entries are inserted, or when the decoder is closed
+	closeErr       error         // set when the encoder stream or the connection was closed
+
+	writeMutex sync.Mutex
+	decoderStr quic.SendStream
+}
+
+func newQPACKDecoder(openStream func() (quic.SendStream, error)) *qpackDecoder {
+	return &qpackDecoder{
+		openStream: openStream,
+		inserted:   make(chan struct{}),
+	}
+}
+
+// reset is called when the connection is set up again after 0-RTT was rejected.
+func (d *qpackDecoder) reset() {
+	d.mutex.Lock()
+	d.capacity = 0
+	d.size = 0
+	d.entries = nil
+	d.evicted = 0
+	d.insertCount = 0
+	d.ackedInserts = 0
+	d.closeErr = nil
+	d.mutex.Unlock()
+
+	d.writeMutex.Lock()
+	d.decoderStr = nil
+	d.writeMutex.Unlock()
+}
+
+// readEncoderStream processes the instructions received on the peer's encoder stream (RFC 9204, Section 4.3).
+// It returns a qpackError if the encoder stream contains invalid instructions,
+// and io.EOF if the peer closed the stream.
+func (d *qpackDecoder) readEncoderStream(str io.Reader) error {
+	r := bufio.NewReader(str)
+	for {
+		err := d.handleEncoderInstruction(r)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		} else if isEncodingError(err) {
+			err = newEncoderStreamError("%s", err)
+		}
+		if err != nil {
+			d.close(fmt.Errorf("QPACK encoder stream failed: %w", err))
+			return err
+		}
+		// acknowledge the insertions once all instructions that were received so far were processed
+		if r.Buffered() == 0 {
+			d.sendInsertCountIncrement()
+		}
+	}
+}
+
+// close fails all field sections that are blocked waiting for dynamic table entries,
+// as well as all field sections received later that reference entries not received yet.
+// It is called when the encoder stream or the connection is closed.
+func (d *qpackDecoder) close(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closeErr == nil {
+		d.closeErr = err
+	}
+	close(d.inserted)
+	d.inserted = make(chan struct{})
+}
+
+func (d *qpackDecoder) handleEncoderInstruction(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case b&0x80 != 0: // Insert with Name Reference
+		index, err := readPrefixedInt(r, b, 6)
+		if err != nil {
+			return err
+		}
+		value, err := d.readEncoderString(r)
+		if err != nil {
+			return err
+		}
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		var name string
+		if b&0x40 != 0 {
+			if index >= uint64(len(staticTable)) {
+				return newEncoderStreamError("invalid static table index: %d", index)
+			}
+			name = staticTable[index].Name
+		} else {
+			hf, err := d.relativeEntry(index)
+			if err != nil {
+				return err
+			}
+			name = hf.Name
+		}
+		return d.insert(qpack.HeaderField{Name: name, Value: value})
+	case b&0x40 != 0: // Insert with Literal Name
+		name, err := readString(r, b, 5, d.maxTableCapacity)
+		if err != nil {
+			return err
+		}
+		value, err := d.readEncoderString(r)
+		if err != nil {
+			return err
+		}
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		return d.insert(qpack.HeaderField{Name: name, Value: value})
+	case b&0x20 != 0: // Set Dynamic Table Capacity
+		capacity, err := readPrefixedInt(r, b, 5)
+		if err != nil {
+			return err
+		}
+		if capacity > d.maxTableCapacity {
+			return newEncoderStreamError("dynamic table capacity too large: %d (max: %d)", capacity, d.maxTableCapacity)
+		}
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		d.capacity = capacity
+		d.evict(capacity)
+		return nil
+	default: // Duplicate
+		index, err := readPrefixedInt(r, b, 5)
+		if err != nil {
+			return err
+		}
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		hf, err := d.relativeEntry(index)
+		if err != nil {
+			return err
+		}
+		return d.insert(hf)
+	}
+}
+
+// readEncoderString reads a string literal with a 7-bit prefix.
+func (d *qpackDecoder) readEncoderString(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	// the string can't be longer than the capacity of the dynamic table
+	return readString(r, b, 7, d.maxTableCapacity)
+}
+
+// relativeEntry returns the entry with the relative index used on the encoder stream.
+// The caller must hold the mutex.
+func (d *qpackDecoder) relativeEntry(index uint64) (qpack.HeaderField, error) {
+	if index >= d.insertCount || d.insertCount-index-1 < d.evicted {
+		return qpack.HeaderField{}, newEncoderStreamError("invalid relative index: %d", index)
+	}
+	return d.entries[d.insertCount-index-1-d.evicted], nil
+}
+
+// insert inserts an entry into the dynamic table, evicting entries as necessary.
+// The caller must hold the mutex.
+func (d *qpackDecoder) insert(hf qpack.HeaderField) error {
+	size := entrySize(hf)
+	if size > d.capacity {
+		return newEncoderStreamError("entry too large for the dynamic table: %d bytes (capacity: %d)", size, d.capacity)
+	}
+	d.evict(d.capacity - size)
+	d.entries = append(d.entries, hf)
+	d.size += size
+	d.insertCount++
+	close(d.inserted)
+	d.inserted = make(chan struct{})
+	return nil
+}
+
+// evict evicts the oldest entries until the size of the dynamic table doesn't exceed maxSize.
+// The caller must hold the mutex.
+func (d *qpackDecoder) evict(maxSize uint64) {
+	for d.size > maxSize {
+		d.size -= entrySize(d.entries[0])
+		d.entries = d.entries[1:]
+		d.evicted++
+	}
+}
+
+// entrySize is the size of a dynamic table entry (RFC 9204, Section 3.2.1).
+// The size of a field section is calculated the same way (RFC 9114, Section 4.2.2).
+func entrySize(hf qpack.HeaderField) uint64 {
+	return uint64(len(hf.Name)+len(hf.Value)) + 32
+}
+
+// decode decodes a field section received on the stream str.
+// If the field section references entries of the dynamic table that weren't received yet,
+// it blocks until the encoder stream delivers them, the decoder is closed, or ctx is done.
+// It returns a qpackError if the field section can't be decoded,
+// and errHeaderListTooLarge if the decoded field section is larger than maxHeaderListSize.
+func (d *qpackDecoder) decode(ctx context.Context, str streamWithID, data []byte) ([]qpack.HeaderField, error) {
+	r := bytes.NewReader(data)
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, newDecompressionError("missing field section prefix")
+	}
+	encodedInsertCount, err := readPrefixedInt(r, b, 8)
+	if err != nil {
+		return nil, newDecompressionError("invalid field section prefix: %s", err)
+	}
+	b, err = r.ReadByte()
+	if err != nil {
+		return nil, newDecompressionError("missing field section prefix")
+	}
+	deltaBase, err := readPrefixedInt(r, b, 7)
+	if err != nil {
+		return nil, newDecompressionError("invalid field section prefix: %s", err)
+	}
+
+	d.mutex.Lock()
+	requiredInsertCount, err := d.decodeRequiredInsertCount(encodedInsertCount)
+	if err != nil {
+		d.mutex.Unlock()
+		return nil, err
+	}
+	var base uint64
+	if b&0x80 == 0 {
+		base = requiredInsertCount + deltaBase
+	} else {
+		if deltaBase >= requiredInsertCount {
+			d.mutex.Unlock()
+			return nil, newDecompressionError("invalid delta base: %d", deltaBase)
+		}
+		base = requiredInsertCount - deltaBase - 1
+	}
+	if err := d.waitForInserts(ctx, requiredInsertCount); err != nil {
+		d.mutex.Unlock()
+		if err == ctx.Err() {
+			// the field section was abandoned
+			d.writeInstruction(appendPrefixedInt(nil, 0x40, 6, uint64(str.StreamID())))
+		}
+		return nil, err
+	}
+	hfs, err := d.decodeFieldLines(r, requiredInsertCount, base)
+	d.mutex.Unlock()
+
+	if requiredInsertCount > 0 {
+		if err == errHeaderListTooLarge {
+			// the field section was abandoned
+			d.writeInstruction(appendPrefixedInt(nil, 0x40, 6, uint64(str.StreamID())))
+		} else if err == nil {
+			d.mutex.Lock()
+			if requiredInsertCount > d.ackedInserts {
+				d.ackedInserts = requiredInsertCount
+			}
+			d.mutex.Unlock()
+			d.writeInstruction(appendPrefixedInt(nil, 0x80, 7, uint64(str.StreamID())))
+		}
+	}
+	return hfs, err
+}
+
+// decodeRequiredInsertCount decodes the Required Insert Count (RFC 9204, Section 4.5.1.1).
+// The caller must hold the mutex.
+func (d *qpackDecoder) decodeRequiredInsertCount(encoded uint64) (uint64, error) {
+	if encoded == 0 {
+		return 0, nil
+	}
+	maxEntries := d.maxTableCapacity / 32
+	fullRange := 2 * maxEntries
+	if encoded > fullRange {
+		return 0, newDecompressionError("invalid Required Insert Count: %d", encoded)
+	}
+	maxValue := d.insertCount + maxEntries
+	maxWrapped := (maxValue / fullRange) * fullRange
+	requiredInsertCount := maxWrapped + encoded - 1
+	if requiredInsertCount > maxValue {
+		if requiredInsertCount <= fullRange {
+			return 0, newDecompressionError("invalid Required Insert Count: %d", encoded)
+		}
+		requiredInsertCount -= fullRange
+	}
+	if requiredInsertCount == 0 {
+		return 0, newDecompressionError("invalid Required Insert Count: %d", encoded)
+	}
+	return requiredInsertCount, nil
+}
+
+// waitForInserts blocks until the dynamic table contains requiredInsertCount entries.
+// It returns early if the decoder is closed, or if ctx is done.
+// The caller must hold the mutex.
+func (d *qpackDecoder) waitForInserts(ctx context.Context, requiredInsertCount uint64) error {
+	if requiredInsertCount <= d.insertCount {
+		return nil
+	}
+	if d.blockedStreams >= d.maxBlockedStreams {
+		return newDecompressionError("too many blocked streams (max: %d)", d.maxBlockedStreams)
+	}
+	d.blockedStreams++
+	defer func() { d.blockedStreams-- }()
+	for d.insertCount < requiredInsertCount {
+		if d.closeErr != nil {
+			return d.closeErr
+		}
+		inserted := d.inserted
+		d.mutex.Unlock()
+		select {
+		case <-inserted:
+		case <-ctx.Done():
+			d.mutex.Lock()
+			return ctx.Err()
+		}
+		d.mutex.Lock()
+	}
+	return nil
+}
+
+// decodeFieldLines decodes the field lines following the field section prefix (RFC 9204, Section 4.5).
+// The caller must hold the mutex.
+func (d *qpackDecoder) decodeFieldLines(r *bytes.Reader, requiredInsertCount, base uint64) ([]qpack.HeaderField, error) {
+	var hfs []qpack.HeaderField
+	var size uint64
+	for r.Len() > 0 {
+		b, _ := r.ReadByte()
+		var hf qpack.HeaderField
+		var err error
+		switch {
+		case b&0x80 != 0: // Indexed Field Line
+			var index uint64
+			index, err = readPrefixedInt(r, b, 6)
+			if err == nil {
+				if b&0x40 != 0 {
+					hf, err = staticEntry(index)
+				} else {
+					hf, err = d.baseRelativeEntry(index, requiredInsertCount, base)
+				}
+			}
+		case b&0x40 != 0: // Literal Field Line with Name Reference
+			var index uint64
+			index, err = readPrefixedInt(r, b, 4)
+			if err == nil {
+				if b&0x10 != 0 {
+					hf, err = staticEntry(index)
+				} else {
+					hf, err = d.baseRelativeEntry(index, requiredInsertCount, base)
+				}
+			}
+			if err == nil {
+				hf.Value, err = readFieldLineString(r)
+			}
+		case b&0x20 != 0: // Literal Field Line with Literal Name
+			hf.Name, err = readString(r, b, 3, uint64(r.Len()))
+			if err == nil {
+				hf.Value, err = readFieldLineString(r)
+			}
+		case b&0x10 != 0: // Indexed Field Line with Post-Base Index
+			var index uint64
+			index, err = readPrefixedInt(r, b, 4)
+			if err == nil {
+				hf, err = d.postBaseEntry(index, requiredInsertCount, base)
+			}
+		default: // Literal Field Line with Post-Base Name Reference
+			var index uint64
+			index, err = readPrefixedInt(r, b, 3)
+			if err == nil {
+				hf, err = d.postBaseEntry(index, requiredInsertCount, base)
+			}
+			if err == nil {
+				hf.Value, err = readFieldLineString(r)
+			}
+		}
+		if err != nil {
+			if _, ok := err.(*qpackError); ok {
+				return nil, err
+			}
+			return nil, newDecompressionError("invalid field line: %s", err)
+		}
+		size += entrySize(hf)
+		if d.maxHeaderListSize > 0 && size > d.maxHeaderListSize {
+			return nil, errHeaderListTooLarge
+		}
+		hfs = append(hfs, hf)
+	}
+	return hfs, nil
+}
+
+func staticEntry(index uint64) (qpack.HeaderField, error) {
+	if index >= uint64(len(staticTable)) {
+		return qpack.HeaderField{}, newDecompressionError("invalid static table index: %d", index)
+	}
+	return staticTable[index], nil
+}
+
+// baseRelativeEntry returns the dynamic table entry referenced by a relative index in a field line.
+// The caller must hold the mutex.
+func (d *qpackDecoder) baseRelativeEntry(index, requiredInsertCount, base uint64) (qpack.HeaderField, error) {
+	if index >= base {
+		return qpack.HeaderField{}, newDecompressionError("invalid relative index: %d", index)
+	}
+	return d.absoluteEntry(base-index-1, requiredInsertCount)
+}
+
+// postBaseEntry returns the dynamic table entry referenced by a post-base index in a field line.
+// The caller must hold the mutex.
+func (d *qpackDecoder) postBaseEntry(index, requiredInsertCount, base uint64) (qpack.HeaderField, error) {
+	return d.absoluteEntry(base+index, requiredInsertCount)
+}
+
+// The caller must hold the mutex.
+func (d *qpackDecoder) absoluteEntry(index, requiredInsertCount uint64) (qpack.HeaderField, error) {
+	if index >= requiredInsertCount {
+		return qpack.HeaderField{}, newDecompressionError("reference to entry %d exceeds the Required Insert Count", index)
+	}
+	if index < d.evicted {
+		return qpack.HeaderField{}, newDecompressionError("reference to evicted entry %d", index)
+	}
+	return d.entries[index-d.evicted], nil
+}
+
+// sendInsertCountIncrement acknowledges all insertions that were not acknowledged yet (RFC 9204, Section 4.4.3).
+func (d *qpackDecoder) sendInsertCountIncrement() {
+	d.mutex.Lock()
+	increment := d.insertCount - d.ackedInserts
+	d.ackedInserts = d.insertCount
+	d.mutex.Unlock()
+	if increment > 0 {
+		d.writeInstruction(appendPrefixedInt(nil, 0, 6, increment))
+	}
+}
+
+// writeInstruction writes an instruction to the decoder stream, opening the stream if necessary.
+// Errors are ignored: they only occur when the connection is closed.
+func (d *qpackDecoder) writeInstruction(b []byte) {
+	d.writeMutex.Lock()
+	defer d.writeMutex.Unlock()
+
+	if d.decoderStr == nil {
+		str, err := d.openStream()
+		if err != nil {
+			return
+		}
+		d.decoderStr = str
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, streamTypeQPACKDecoderStream)
+		buf.Write(b)
+		b = buf.Bytes()
+	}
+	d.decoderStr.Write(b)
+}
+
+// readFieldLineString reads a string literal with a 7-bit prefix from a field line.
+func readFieldLineString(r *bytes.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	return readString(r, b, 7, uint64(r.Len()))
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// readString reads a string literal with an n-bit prefix (RFC 9204, Section 4.1.2).
+// The Huffman flag is the bit preceding the prefix.
+// Strings longer than maxLen bytes (before Huffman decoding) are rejected.
+func readString(r byteReader, b byte, n uint8, maxLen uint64) (string, error) {
+	huffman := b&(1<<n) != 0
+	l, err := readPrefixedInt(r, b, n)
+	if err != nil {
+		return "", err
+	}
+	if l > maxLen {
+		return "", fmt.Errorf("%w: %d bytes", errStringTooLong, l)
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	if !huffman {
+		return string(buf), nil
+	}
+	return hpack.HuffmanDecodeToString(buf)
+}
+
+// readPrefixedInt reads an integer with an n-bit prefix (RFC 7541, Section 5.1).
+// b is the first byte of the integer.
+func readPrefixedInt(r io.ByteReader, b byte, n uint8) (uint64, error) {
+	max := uint64(1)<<n - 1
+	v := uint64(b) & max
+	if v < max {
+		return v, nil
+	}
+	for shift := uint(0); ; shift += 7 {
+		if shift > 56 {
+			return 0, errIntegerOverflow
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v += uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+}
+
+// appendPrefixedInt appends an integer with an n-bit prefix (RFC 7541, Section 5.1).
+// flags are the bits of the first byte preceding the prefix.
+func appendPrefixedInt(b []byte, flags byte, n uint8, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(b, flags|byte(v))
+	}
+	b = append(b, flags|byte(max))
+	v -= max
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
@@ -0,0 +1,383 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// appendStringLiteral appends a string literal (without Huffman encoding) with an n-bit prefix.
+func appendStringLiteral(b []byte, flags byte, n uint8, s string) []byte {
+	return append(appendPrefixedInt(b, flags, n, uint64(len(s))), s...)
+}
+
+var _ = Describe("QPACK decoder", func() {
+	var (
+		decoder       *qpackDecoder
+		str           *mockquic.MockStream
+		decoderStrBuf *bytes.Buffer
+		mutex         sync.Mutex
+	)
+
+	readDecoderStream := func() []byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return decoderStrBuf.Bytes()
+	}
+
+	BeforeEach(func() {
+		buf := &bytes.Buffer{}
+		decoderStrBuf = buf
+		decoderStr := mockquic.NewMockStream(mockCtrl)
+		decoderStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return buf.Write(b)
+		}).AnyTimes()
+		decoder = newQPACKDecoder(func() (quic.SendStream, error) { return decoderStr, nil })
+		decoder.maxTableCapacity = 220
+		decoder.maxBlockedStreams = 1
+		str = mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+	})
+
+	It("has the static table", func() {
+		Expect(staticTable).To(HaveLen(99))
+		Expect(staticTable[0]).To(Equal(qpack.HeaderField{Name: ":authority"}))
+		Expect(staticTable[98]).To(Equal(qpack.HeaderField{Name: "x-frame-options", Value: "sameorigin"}))
+	})
+
+	Context("prefixed integers", func() {
+		It("encodes and decodes integers", func() {
+			// examples from RFC 7541, Appendix C.1
+			Expect(appendPrefixedInt(nil, 0, 5, 10)).To(Equal([]byte{0xa}))
+			Expect(appendPrefixedInt(nil, 0, 5, 1337)).To(Equal([]byte{0x1f, 0x9a, 0xa}))
+			Expect(appendPrefixedInt(nil, 0, 8, 42)).To(Equal([]byte{0x2a}))
+			for _, v := range []uint64{0, 30, 31, 127, 128, 1337, 1 << 40} {
+				b := appendPrefixedInt(nil, 0xe0, 5, v)
+				r := bytes.NewReader(b[1:])
+				n, err := readPrefixedInt(r, b[0], 5)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(v))
+				Expect(r.Len()).To(BeZero())
+			}
+		})
+
+		It("errors on overflows", func() {
+			r := bytes.NewReader(bytes.Repeat([]byte{0xff}, 10))
+			_, err := readPrefixedInt(r, 0x1f, 5)
+			Expect(err).To(MatchError(errIntegerOverflow))
+		})
+	})
+
+	It("decodes field sections that only use the static table", func() {
+		buf := &bytes.Buffer{}
+		enc := qpack.NewEncoder(buf)
+		hfs := []qpack.HeaderField{
+			{Name: ":status", Value: "200"},
+			{Name: "content-type", Value: "text/html"},
+			{Name: "foo", Value: "bar"},
+		}
+		for _, hf := range hfs {
+			Expect(enc.WriteField(hf)).To(Succeed())
+		}
+		decoded, err := decoder.decode(context.Background(), str, buf.Bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded).To(Equal(hfs))
+		Expect(readDecoderStream()).To(BeEmpty())
+	})
+
+	Context("using the dynamic table", func() {
+		var encoderStr *bytes.Buffer
+
+		BeforeEach(func() {
+			encoderStr = &bytes.Buffer{}
+		})
+
+		readEncoderStream := func() error {
+			err := decoder.readEncoderStream(encoderStr)
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		// insertEntries sets the capacity of the dynamic table, and inserts three entries:
+		// 0: foo: bar (literal name)
+		// 1: content-type: text/plain (name reference to the static table)
+		// 2: foo: bar (duplicate of entry 0)
+		insertEntries := func() {
+			b := appendPrefixedInt(nil, 0x20, 5, 220)      // Set Dynamic Table Capacity
+			b = appendStringLiteral(b, 0x40, 5, "foo")     // Insert with Literal Name
+			b = appendStringLiteral(b, 0, 7, "bar")        //
+			b = appendPrefixedInt(b, 0xc0, 6, 44)          // Insert with Name Reference, static table entry 44: content-type
+			b = appendStringLiteral(b, 0, 7, "text/plain") //
+			b = appendPrefixedInt(b, 0, 5, 1)              // Duplicate of the second most recent entry
+			encoderStr.Write(b)
+			Expect(readEncoderStream()).To(Succeed())
+		}
+
+		It("processes encoder stream instructions, and acknowledges them", func() {
+			insertEntries()
+			Expect(decoder.insertCount).To(BeEquivalentTo(3))
+			Expect(decoder.entries).To(Equal([]qpack.HeaderField{
+				{Name: "foo", Value: "bar"},
+				{Name: "content-type", Value: "text/plain"},
+				{Name: "foo", Value: "bar"},
+			}))
+			Expect(decoder.size).To(BeEquivalentTo(3*32 + 6 + 22 + 6))
+			r := bytes.NewReader(readDecoderStream())
+			streamType, err := quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(streamType).To(BeEquivalentTo(streamTypeQPACKDecoderStream))
+			b, err := r.ReadByte()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b & 0xc0).To(BeZero()) // Insert Count Increment
+			Expect(readPrefixedInt(r, b, 6)).To(BeEquivalentTo(3))
+			Expect(r.Len()).To(BeZero())
+		})
+
+		It("decodes field sections referencing the dynamic table", func() {
+			insertEntries()
+			decoderStrBuf.Reset()
+			// Required Insert Count 2, Base 1
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 2%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0x80, 7, 0)          // S = 1, Delta Base = Required Insert Count - Base - 1 = 0
+			b = appendPrefixedInt(b, 0x80, 6, 0)          // Indexed Field Line, relative index 0: absolute index 0
+			b = appendPrefixedInt(b, 0x10, 4, 0)          // Indexed Field Line with Post-Base Index 0: absolute index 1
+			b = appendPrefixedInt(b, 0x00, 3, 0)          // Literal Field Line with Post-Base Name Reference: content-type
+			b = appendStringLiteral(b, 0, 7, "text/html") //
+			b = appendPrefixedInt(b, 0x40, 4, 0)          // Literal Field Line with Name Reference, relative index 0: foo
+			b = appendStringLiteral(b, 0, 7, "baz")       //
+			b = appendPrefixedInt(b, 0xc0, 6, 25)         // Indexed Field Line, static table entry 25: :status: 200
+			b = appendStringLiteral(b, 0x20, 3, "lorem")  // Literal Field Line with Literal Name
+			b = appendStringLiteral(b, 0, 7, "ipsum")     //
+			hfs, err := decoder.decode(context.Background(), str, b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hfs).To(Equal([]qpack.HeaderField{
+				{Name: "foo", Value: "bar"},
+				{Name: "content-type", Value: "text/plain"},
+				{Name: "content-type", Value: "text/html"},
+				{Name: "foo", Value: "baz"},
+				{Name: ":status", Value: "200"},
+				{Name: "lorem", Value: "ipsum"},
+			}))
+			// Section Acknowledgment for stream 4
+			Expect(readDecoderStream()).To(Equal(appendPrefixedInt(nil, 0x80, 7, 4)))
+		})
+
+		It("evicts entries", func() {
+			insertEntries()
+			// Reduce the capacity, such that only the most recently inserted entry fits.
+			encoderStr.Write(appendPrefixedInt(nil, 0x20, 5, 50))
+			Expect(readEncoderStream()).To(Succeed())
+			Expect(decoder.entries).To(Equal([]qpack.HeaderField{{Name: "foo", Value: "bar"}}))
+			Expect(decoder.evicted).To(BeEquivalentTo(2))
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 3%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0x80, 7, 2) // Base 0
+			b = appendPrefixedInt(b, 0x10, 4, 0) // Indexed Field Line with Post-Base Index 0: absolute index 0
+			_, err := decoder.decode(context.Background(), str, b)
+			Expect(err).To(MatchError("reference to evicted entry 0"))
+			var qerr *qpackError
+			Expect(errors.As(err, &qerr)).To(BeTrue())
+			Expect(qerr.code).To(Equal(errorQPACKDecompressionFailed))
+		})
+
+		It("rejects references beyond the Required Insert Count", func() {
+			insertEntries()
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 1%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0, 7, 0)    // Base 1
+			b = appendPrefixedInt(b, 0x10, 4, 1) // Indexed Field Line with Post-Base Index 1: absolute index 2
+			_, err := decoder.decode(context.Background(), str, b)
+			Expect(err).To(MatchError("reference to entry 2 exceeds the Required Insert Count"))
+		})
+
+		It("blocks until the referenced entries are received", func() {
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 1%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0, 7, 0)    // Base 1
+			b = appendPrefixedInt(b, 0x80, 6, 0) // Indexed Field Line, relative index 0: absolute index 0
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				hfs, err := decoder.decode(context.Background(), str, b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hfs).To(Equal([]qpack.HeaderField{{Name: "foo", Value: "bar"}}))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			insertEntries()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("fails blocked field sections when the encoder stream is closed", func() {
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 1%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0, 7, 0)
+			b = appendPrefixedInt(b, 0x80, 6, 0)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := decoder.decode(context.Background(), str, b)
+				Expect(err).To(MatchError(io.EOF))
+				Expect(decodingRequestError(err).streamErr).To(Equal(errorRequestIncomplete))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			Expect(decoder.readEncoderStream(&bytes.Buffer{})).To(MatchError(io.EOF))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("fails blocked field sections when the decoder is closed", func() {
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 1%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0, 7, 0)
+			b = appendPrefixedInt(b, 0x80, 6, 0)
+			testErr := errors.New("connection closed")
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := decoder.decode(context.Background(), str, b)
+				Expect(err).To(MatchError(testErr))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			decoder.close(testErr)
+			Eventually(done).Should(BeClosed())
+			// field sections received later fail immediately
+			_, err := decoder.decode(context.Background(), str, b)
+			Expect(err).To(MatchError(testErr))
+			Expect(decoder.blockedStreams).To(BeZero())
+			Expect(readDecoderStream()).To(BeEmpty())
+		})
+
+		It("stops waiting for blocked field sections when the context is canceled", func() {
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 1%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0, 7, 0)
+			b = appendPrefixedInt(b, 0x80, 6, 0)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := decoder.decode(ctx, str, b)
+				Expect(err).To(MatchError(context.Canceled))
+				Expect(decodingRequestError(err).streamErr).To(Equal(errorRequestCanceled))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			cancel()
+			Eventually(done).Should(BeClosed())
+			decoder.mutex.Lock()
+			Expect(decoder.blockedStreams).To(BeZero())
+			decoder.mutex.Unlock()
+			// Stream Cancellation for stream 4
+			r := bytes.NewReader(readDecoderStream())
+			streamType, err := quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(streamType).To(BeEquivalentTo(streamTypeQPACKDecoderStream))
+			Expect(io.ReadAll(r)).To(Equal(appendPrefixedInt(nil, 0x40, 6, 4)))
+		})
+
+		It("limits the number of blocked streams", func() {
+			decoder.maxBlockedStreams = 0
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 1%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0, 7, 0)
+			b = appendPrefixedInt(b, 0x80, 6, 0)
+			_, err := decoder.decode(context.Background(), str, b)
+			Expect(err).To(MatchError("too many blocked streams (max: 0)"))
+		})
+
+		It("rejects invalid Required Insert Counts", func() {
+			maxEntries := decoder.maxTableCapacity / 32
+			_, err := decoder.decode(context.Background(), str, []byte{byte(2*maxEntries + 1), 0})
+			Expect(err).To(MatchError("invalid Required Insert Count: 13"))
+		})
+
+		It("rejects references to the dynamic table if the capacity is 0", func() {
+			decoder.maxTableCapacity = 0
+			_, err := decoder.decode(context.Background(), str, []byte{1, 0})
+			Expect(err).To(MatchError("invalid Required Insert Count: 1"))
+		})
+
+		It("limits the size of the decoded header list, and cancels the stream", func() {
+			insertEntries()
+			decoderStrBuf.Reset()
+			decoder.maxHeaderListSize = 2 * (32 + 6)
+			maxEntries := decoder.maxTableCapacity / 32
+			b := appendPrefixedInt(nil, 0, 8, 1%(2*maxEntries)+1)
+			b = appendPrefixedInt(b, 0, 7, 0)
+			// reference foo: bar 3 times
+			for i := 0; i < 3; i++ {
+				b = appendPrefixedInt(b, 0x80, 6, 0)
+			}
+			_, err := decoder.decode(context.Background(), str, b)
+			Expect(err).To(MatchError(errHeaderListTooLarge))
+			// Stream Cancellation for stream 4
+			Expect(readDecoderStream()).To(Equal(appendPrefixedInt(nil, 0x40, 6, 4)))
+		})
+
+		Context("encoder stream errors", func() {
+			expectEncoderStreamError := func(b []byte, msg string) {
+				encoderStr.Write(b)
+				err := decoder.readEncoderStream(encoderStr)
+				Expect(err).To(MatchError(msg))
+				var qerr *qpackError
+				Expect(errors.As(err, &qerr)).To(BeTrue())
+				Expect(qerr.code).To(Equal(errorQPACKEncoderStreamError))
+			}
+
+			It("rejects a capacity larger than the maximum", func() {
+				expectEncoderStreamError(appendPrefixedInt(nil, 0x20, 5, 221), "dynamic table capacity too large: 221 (max: 220)")
+			})
+
+			It("rejects entries that don't fit into the dynamic table", func() {
+				b := appendPrefixedInt(nil, 0x20, 5, 40)
+				b = appendStringLiteral(b, 0x40, 5, "foo")
+				b = appendStringLiteral(b, 0, 7, "foobar")
+				expectEncoderStreamError(b, "entry too large for the dynamic table: 41 bytes (capacity: 40)")
+			})
+
+			It("rejects invalid relative indices", func() {
+				b := appendPrefixedInt(nil, 0x20, 5, 220)
+				b = appendPrefixedInt(b, 0, 5, 0) // Duplicate, but the table is empty
+				expectEncoderStreamError(b, "invalid relative index: 0")
+			})
+
+			It("rejects invalid static table indices", func() {
+				b := appendPrefixedInt(nil, 0x20, 5, 220)
+				b = appendPrefixedInt(b, 0xc0, 6, 99)
+				b = appendStringLiteral(b, 0, 7, "foo")
+				expectEncoderStreamError(b, "invalid static table index: 99")
+			})
+
+			It("rejects string literals that are longer than the maximum capacity", func() {
+				b := appendPrefixedInt(nil, 0x20, 5, 220)
+				b = appendStringLiteral(b, 0x40, 5, string(make([]byte, 221)))
+				expectEncoderStreamError(b, "string literal too long: 221 bytes")
+			})
+		})
+
+		It("resets the dynamic table", func() {
+			insertEntries()
+			decoder.reset()
+			Expect(decoder.insertCount).To(BeZero())
+			Expect(decoder.entries).To(BeEmpty())
+		})
+	})
+})
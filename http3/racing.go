@@ -0,0 +1,211 @@
+package http3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRaceHeadStart is the default value of RacingRoundTripper.HeadStart.
+	defaultRaceHeadStart = 300 * time.Millisecond
+	// brokenQUICTimeout is the time during which QUIC isn't tried for an origin after the handshake failed.
+	// It is doubled for every consecutive failure.
+	brokenQUICTimeout = 5 * time.Minute
+	// maxBrokenQUICBackoff limits the number of times brokenQUICTimeout is doubled.
+	maxBrokenQUICBackoff = 6
+)
+
+// quicDial is a QUIC handshake in progress.
+type quicDial struct {
+	done chan struct{} // closed when the handshake completed
+	err  error
+}
+
+// raceOutcome is what RacingRoundTripper remembers about an origin.
+type raceOutcome struct {
+	dial        *quicDial // nil if no handshake is in progress
+	failures    int       // the number of consecutive failed handshakes
+	brokenUntil time.Time
+}
+
+// RacingRoundTripper implements the http.RoundTripper interface.
+// It sends requests over HTTP/3 if possible, and over TCP (using HTTP/1.1 or HTTP/2) otherwise.
+// The QUIC connection is dialed on the same host and port as the TCP connection.
+// If the QUIC handshake doesn't complete within the head start,
+// the request is sent over TCP, while the handshake continues in the background.
+// Once the QUIC connection is established, it is used for subsequent requests.
+// If the QUIC handshake fails, QUIC isn't tried for that origin for a while,
+// such that clients on networks that block UDP don't pay the head start on every request.
+type RacingRoundTripper struct {
+	// Fallback is used to send requests over TCP.
+	// If nil, http.DefaultTransport is used.
+	Fallback http.RoundTripper
+
+	// H3 is used to send requests over HTTP/3.
+	// If nil, a RoundTripper with the default configuration is used.
+	H3 *RoundTripper
+
+	// HeadStart is the time the QUIC handshake is given to complete,
+	// before requests are sent over TCP.
+	// If zero, a default of 300ms is used.
+	HeadStart time.Duration
+
+	initOnce sync.Once
+
+	mutex sync.Mutex
+	// ctx is used for the QUIC handshakes.
+	// It is canceled when the RoundTripper is closed, and replaced by a new context.
+	ctx       context.Context
+	cancelCtx context.CancelFunc
+	outcomes  map[string]*raceOutcome
+}
+
+var _ http.RoundTripper = &RacingRoundTripper{}
+
+func (t *RacingRoundTripper) init() {
+	if t.H3 == nil {
+		t.H3 = &RoundTripper{}
+	}
+	t.ctx, t.cancelCtx = context.WithCancel(context.Background())
+}
+
+func (t *RacingRoundTripper) fallback() http.RoundTripper {
+	if t.Fallback != nil {
+		return t.Fallback
+	}
+	return http.DefaultTransport
+}
+
+func (t *RacingRoundTripper) headStart() time.Duration {
+	if t.HeadStart != 0 {
+		return t.HeadStart
+	}
+	return defaultRaceHeadStart
+}
+
+// RoundTrip does a round trip.
+func (t *RacingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.initOnce.Do(t.init)
+
+	if req.URL == nil || req.URL.Scheme != "https" {
+		return t.fallback().RoundTrip(req)
+	}
+	origin := authorityAddr("https", hostnameFromRequest(req))
+	// While a handshake is in progress, the connection pool already contains the connection,
+	// so we can't use it without waiting for the handshake to complete.
+	d := t.getDial(origin)
+	if d == nil {
+		rsp, err := t.H3.RoundTripOpt(req, RoundTripOpt{OnlyCachedConn: true})
+		if err != ErrNoCachedConn {
+			return rsp, err
+		}
+		d = t.startDial(req, origin)
+	}
+	if d == nil { // QUIC is broken for this origin
+		return t.fallback().RoundTrip(req)
+	}
+
+	timer := time.NewTimer(t.headStart())
+	defer timer.Stop()
+	select {
+	case <-d.done:
+		if d.err == nil {
+			return t.H3.RoundTrip(req)
+		}
+	case <-timer.C:
+	case <-req.Context().Done():
+		closeRequestBody(req)
+		return nil, req.Context().Err()
+	}
+	return t.fallback().RoundTrip(req)
+}
+
+func (t *RacingRoundTripper) getDial(origin string) *quicDial {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if o, ok := t.outcomes[origin]; ok {
+		return o.dial
+	}
+	return nil
+}
+
+// startDial starts a QUIC handshake with origin, unless one is already in progress.
+// It returns nil if QUIC is currently considered broken for this origin.
+func (t *RacingRoundTripper) startDial(req *http.Request, origin string) *quicDial {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.outcomes == nil {
+		t.outcomes = make(map[string]*raceOutcome)
+	}
+	o, ok := t.outcomes[origin]
+	if !ok {
+		o = &raceOutcome{}
+		t.outcomes[origin] = o
+	}
+	if o.dial != nil {
+		return o.dial
+	}
+	if time.Now().Before(o.brokenUntil) {
+		return nil
+	}
+	d := &quicDial{done: make(chan struct{})}
+	o.dial = d
+	ctx := t.ctx
+	go func() {
+		// The handshake is not bound to the request's context,
+		// since the connection will be used for subsequent requests.
+		d.err = t.H3.dialConn(ctx, req)
+		t.dialDone(ctx, o, d.err)
+		close(d.done)
+	}()
+	return d
+}
+
+func (t *RacingRoundTripper) dialDone(ctx context.Context, o *raceOutcome, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	o.dial = nil
+	if err == nil {
+		o.failures = 0
+		o.brokenUntil = time.Time{}
+		return
+	}
+	// A handshake that was canceled doesn't tell us anything about the origin.
+	if errors.Is(err, context.Canceled) || (ctx.Err() != nil && errors.Is(err, ctx.Err())) {
+		return
+	}
+	backoff := o.failures
+	if backoff > maxBrokenQUICBackoff {
+		backoff = maxBrokenQUICBackoff
+	}
+	o.failures++
+	o.brokenUntil = time.Now().Add(brokenQUICTimeout << backoff)
+}
+
+// CloseIdleConnections closes the idle connections of both the HTTP/3 and the fallback RoundTripper.
+func (t *RacingRoundTripper) CloseIdleConnections() {
+	t.initOnce.Do(t.init)
+	t.H3.CloseIdleConnections()
+	if c, ok := t.fallback().(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// Close closes the QUIC connections used by the HTTP/3 RoundTripper,
+// and cancels handshakes that are still in progress.
+// Like the RoundTripper, the RacingRoundTripper can still be used after it was closed:
+// subsequent requests dial new QUIC connections.
+func (t *RacingRoundTripper) Close() error {
+	t.initOnce.Do(t.init)
+	t.mutex.Lock()
+	t.cancelCtx()
+	t.ctx, t.cancelCtx = context.WithCancel(context.Background())
+	t.mutex.Unlock()
+	return t.H3.Close()
+}
@@ -0,0 +1,181 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Racing RoundTripper", func() {
+	var (
+		rt           *RacingRoundTripper
+		fallbackReqs chan *http.Request
+		dialed       chan string
+		dialResult   chan error
+	)
+
+	BeforeEach(func() {
+		fallbackReqs = make(chan *http.Request, 10)
+		dialed = make(chan string, 10)
+		dialResult = make(chan error, 10)
+		fallbackReqs := fallbackReqs
+		dialed := dialed
+		dialResult := dialResult
+		rt = &RacingRoundTripper{
+			Fallback: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				fallbackReqs <- req
+				return &http.Response{Request: req}, nil
+			}),
+			H3: &RoundTripper{
+				Dial: func(ctx context.Context, addr string, _ *tls.Config, _ *quic.Config) (quic.EarlyConnection, error) {
+					dialed <- addr
+					return nil, <-dialResult
+				},
+			},
+			HeadStart: scaleDuration(25 * time.Millisecond),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(rt.Close()).To(Succeed())
+	})
+
+	newRequest := func(url string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		Expect(err).ToNot(HaveOccurred())
+		return req
+	}
+
+	It("doesn't use QUIC for http URLs", func() {
+		_, err := rt.RoundTrip(newRequest("http://example.org/foo"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallbackReqs).To(Receive())
+		Consistently(dialed).ShouldNot(Receive())
+	})
+
+	It("falls back to TCP if the QUIC handshake fails, and remembers the failure", func() {
+		rt.HeadStart = time.Hour
+		dialResult <- errors.New("handshake failed")
+		_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dialed).To(Receive(Equal("example.org:443")))
+		var req *http.Request
+		Expect(fallbackReqs).To(Receive(&req))
+		Expect(req.URL.Path).To(Equal("/foo"))
+
+		_, err = rt.RoundTrip(newRequest("https://example.org/bar"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallbackReqs).To(Receive())
+		Expect(dialed).ToNot(Receive())
+	})
+
+	It("backs off exponentially", func() {
+		o := &raceOutcome{}
+		rt.dialDone(context.Background(), o, errors.New("handshake failed"))
+		Expect(o.brokenUntil).To(BeTemporally("~", time.Now().Add(brokenQUICTimeout), time.Second))
+		rt.dialDone(context.Background(), o, errors.New("handshake failed"))
+		Expect(o.brokenUntil).To(BeTemporally("~", time.Now().Add(2*brokenQUICTimeout), time.Second))
+		for i := 0; i < 10; i++ {
+			rt.dialDone(context.Background(), o, errors.New("handshake failed"))
+		}
+		Expect(o.brokenUntil).To(BeTemporally("~", time.Now().Add(brokenQUICTimeout<<maxBrokenQUICBackoff), time.Second))
+		rt.dialDone(context.Background(), o, nil)
+		Expect(o.failures).To(BeZero())
+		Expect(o.brokenUntil).To(BeZero())
+	})
+
+	It("doesn't consider QUIC broken if the handshake was canceled", func() {
+		o := &raceOutcome{}
+		rt.dialDone(context.Background(), o, context.Canceled)
+		Expect(o.failures).To(BeZero())
+		Expect(o.brokenUntil).To(BeZero())
+		ctx, cancel := context.WithTimeout(context.Background(), -1)
+		defer cancel()
+		rt.dialDone(ctx, o, fmt.Errorf("handshake failed: %w", ctx.Err()))
+		Expect(o.failures).To(BeZero())
+		Expect(o.brokenUntil).To(BeZero())
+	})
+
+	It("sends the request over TCP if the handshake doesn't complete within the head start", func() {
+		start := time.Now()
+		_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically(">=", rt.HeadStart))
+		Expect(fallbackReqs).To(Receive())
+		Expect(dialed).To(Receive())
+
+		// the handshake is still in progress, no new handshake is started
+		_, err = rt.RoundTrip(newRequest("https://example.org/bar"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallbackReqs).To(Receive())
+		Consistently(dialed).ShouldNot(Receive())
+		dialResult <- errors.New("handshake failed")
+	})
+
+	It("returns when the request is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		rt.HeadStart = time.Hour
+		_, err := rt.RoundTrip(newRequest("https://example.org/foo").WithContext(ctx))
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(fallbackReqs).ToNot(Receive())
+		dialResult <- errors.New("handshake failed")
+	})
+
+	It("cancels the handshake when closed", func() {
+		canceled := make(chan struct{})
+		rt.H3.Dial = func(ctx context.Context, addr string, _ *tls.Config, _ *quic.Config) (quic.EarlyConnection, error) {
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		}
+		_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallbackReqs).To(Receive())
+		Consistently(canceled).ShouldNot(BeClosed())
+		Expect(rt.Close()).To(Succeed())
+		Eventually(canceled).Should(BeClosed())
+	})
+
+	It("dials new QUIC connections after it was closed", func() {
+		canceled := make(chan struct{}, 1)
+		rt.H3.Dial = func(ctx context.Context, addr string, _ *tls.Config, _ *quic.Config) (quic.EarlyConnection, error) {
+			dialed <- addr
+			if ctx.Err() != nil {
+				canceled <- struct{}{}
+				return nil, ctx.Err()
+			}
+			select {
+			case <-ctx.Done():
+				canceled <- struct{}{}
+				return nil, ctx.Err()
+			case err := <-dialResult:
+				return nil, err
+			}
+		}
+		_, err := rt.RoundTrip(newRequest("https://example.org/foo"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallbackReqs).To(Receive())
+		Expect(dialed).To(Receive())
+		Expect(rt.Close()).To(Succeed())
+		Eventually(canceled).Should(Receive())
+
+		// The canceled handshake didn't mark QUIC as broken for this origin,
+		// and the new handshake uses a context that's not canceled.
+		rt.HeadStart = time.Hour
+		dialResult <- errors.New("handshake failed")
+		_, err = rt.RoundTrip(newRequest("https://example.org/bar"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallbackReqs).To(Receive())
+		Expect(dialed).To(Receive())
+		Expect(canceled).ToNot(Receive())
+	})
+})
@@ -11,6 +11,17 @@ import (
 	"github.com/marten-seemann/qpack"
 )
 
+// hasProtocolPseudoHeader says if the header fields contain the :protocol pseudo-header field,
+// i.e. if they belong to an Extended CONNECT request.
+func hasProtocolPseudoHeader(headers []qpack.HeaderField) bool {
+	for _, h := range headers {
+		if h.Name == ":protocol" {
+			return true
+		}
+	}
+	return false
+}
+
 func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
 	var path, authority, method, protocol, scheme, contentLengthStr string
 
@@ -42,24 +53,36 @@ func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
 	}
 
 	isConnect := method == http.MethodConnect
-	if isConnect {
-		// Extended CONNECT, see https://datatracker.ietf.org/doc/html/rfc8441#section-4
-		if protocol != "" {
-			if scheme == "" || path == "" || authority == "" {
-				return nil, errors.New("extended CONNECT: :scheme, :path and :authority must not be empty")
-			}
-		} else if path != "" || authority == "" { // normal CONNECT
+	// Extended CONNECT, see https://datatracker.ietf.org/doc/html/rfc9220#section-3
+	isExtendedConnect := isConnect && protocol != ""
+	if isExtendedConnect {
+		if scheme == "" || path == "" || authority == "" {
+			return nil, errors.New("extended CONNECT: :scheme, :path and :authority must not be empty")
+		}
+	} else if isConnect {
+		if path != "" || authority == "" { // normal CONNECT
 			return nil, errors.New(":path must be empty and :authority must not be empty")
 		}
 	} else if len(path) == 0 || len(authority) == 0 || len(method) == 0 {
 		return nil, errors.New(":path, :authority and :method must not be empty")
+	} else if protocol != "" {
+		return nil, errors.New(":protocol must only be used with the CONNECT method")
 	}
 
 	var u *url.URL
 	var requestURI string
 	var err error
 
-	if isConnect {
+	if isExtendedConnect {
+		u, err = url.ParseRequestURI(path)
+		if err != nil {
+			return nil, err
+		}
+		u.Scheme = scheme
+		u.Host = authority
+		requestURI = path
+	} else if isConnect {
+		protocol = "HTTP/3"
 		u = &url.URL{
 			Scheme: scheme,
 			Host:   authority,
@@ -117,6 +117,7 @@ var _ = Describe("Request", func() {
 			req, err := requestFromHeaders(headers)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(req.Method).To(Equal(http.MethodConnect))
+			Expect(req.Proto).To(Equal("HTTP/3"))
 			Expect(req.RequestURI).To(Equal("quic.clemente.io"))
 		})
 
@@ -155,6 +156,33 @@ var _ = Describe("Request", func() {
 			Expect(req.URL.String()).To(Equal("ftp://quic.clemente.io/foo"))
 		})
 
+		It("parses the query", func() {
+			headers := []qpack.HeaderField{
+				{Name: ":protocol", Value: "websocket"},
+				{Name: ":scheme", Value: "https"},
+				{Name: ":method", Value: http.MethodConnect},
+				{Name: ":authority", Value: "quic.clemente.io"},
+				{Name: ":path", Value: "/chat?room=42"},
+			}
+			req, err := requestFromHeaders(headers)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(req.URL.Path).To(Equal("/chat"))
+			Expect(req.URL.Query().Get("room")).To(Equal("42"))
+			Expect(req.RequestURI).To(Equal("/chat?room=42"))
+		})
+
+		It("rejects the :protocol pseudo header for methods other than CONNECT", func() {
+			headers := []qpack.HeaderField{
+				{Name: ":protocol", Value: "webtransport"},
+				{Name: ":scheme", Value: "https"},
+				{Name: ":method", Value: http.MethodGet},
+				{Name: ":authority", Value: "quic.clemente.io"},
+				{Name: ":path", Value: "/foo"},
+			}
+			_, err := requestFromHeaders(headers)
+			Expect(err).To(MatchError(":protocol must only be used with the CONNECT method"))
+		})
+
 		It("errors with missing scheme", func() {
 			headers := []qpack.HeaderField{
 				{Name: ":protocol", Value: "webtransport"},
@@ -2,10 +2,12 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,6 +27,13 @@ type requestWriter struct {
 	encoder   *qpack.Encoder
 	headerBuf *bytes.Buffer
 
+	// frameSize is the maximum payload size of the DATA frames carrying the request body.
+	// If zero, bodyCopyBufferSize is used.
+	frameSize int
+	// flushThreshold is the number of body bytes buffered before a DATA frame is sent.
+	// If zero, every chunk read from the body is sent right away.
+	flushThreshold int
+
 	logger utils.Logger
 }
 
@@ -38,67 +47,158 @@ func newRequestWriter(logger utils.Logger) *requestWriter {
 	}
 }
 
-func (w *requestWriter) WriteRequest(str quic.Stream, req *http.Request, gzip bool) error {
+// WriteRequest writes the request to str.
+// The body is sent asynchronously. If continueCh is not nil, the body is only sent
+// after true is received on it. If false is received, the body is discarded.
+// If lowLatency is set, body data is sent as soon as it is read, regardless of the flush threshold.
+func (w *requestWriter) WriteRequest(str quic.Stream, req *http.Request, dontCloseStr, gzip, lowLatency bool, continueCh <-chan bool) error {
+	trace := httptrace.ContextClientTrace(req.Context())
+	trailers, err := commaSeparatedTrailers(req)
+	if err != nil {
+		traceWroteRequest(trace, err)
+		return err
+	}
 	buf := &bytes.Buffer{}
-	if err := w.writeHeaders(buf, req, gzip); err != nil {
+	if err := w.writeHeaders(buf, req, gzip, trailers); err != nil {
+		traceWroteRequest(trace, err)
 		return err
 	}
 	if _, err := str.Write(buf.Bytes()); err != nil {
+		traceWroteRequest(trace, err)
 		return err
 	}
-	// TODO: add support for trailers
+	traceWroteHeaders(trace)
 	if req.Body == nil {
-		str.Close()
+		if trailers != "" {
+			if err := w.writeTrailers(str, req.Trailer); err != nil {
+				traceWroteRequest(trace, err)
+				return err
+			}
+		}
+		if !dontCloseStr {
+			str.Close()
+		}
+		traceWroteRequest(trace, nil)
 		return nil
 	}
 
 	// send the request body asynchronously
 	go func() {
 		defer req.Body.Close()
-		b := make([]byte, bodyCopyBufferSize)
-		for {
-			n, rerr := req.Body.Read(b)
-			if n == 0 {
-				if rerr == nil {
-					continue
-				} else if rerr == io.EOF {
-					break
-				}
-			}
-			buf := &bytes.Buffer{}
-			(&dataFrame{Length: uint64(n)}).Write(buf)
-			if _, err := str.Write(buf.Bytes()); err != nil {
-				w.logger.Errorf("Error writing request: %s", err)
+		if continueCh != nil {
+			traceWait100Continue(trace)
+			if !<-continueCh {
+				// The server sent a final response before asking for the request body.
+				str.CancelWrite(quic.StreamErrorCode(errorNoError))
+				traceWroteRequest(trace, errors.New("http3: request body not sent"))
 				return
 			}
-			if _, err := str.Write(b[:n]); err != nil {
-				w.logger.Errorf("Error writing request: %s", err)
-				return
-			}
-			if rerr != nil {
-				if rerr == io.EOF {
-					break
-				}
-				str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
-				w.logger.Errorf("Error writing request: %s", rerr)
+		}
+		if err := w.writeBody(str, req.Body, lowLatency); err != nil {
+			w.logger.Errorf("Error writing request: %s", err)
+			traceWroteRequest(trace, err)
+			return
+		}
+		// The values of the trailers may be set while the body is being sent.
+		if trailers != "" {
+			if err := w.writeTrailers(str, req.Trailer); err != nil {
+				w.logger.Errorf("Error writing request trailers: %s", err)
+				traceWroteRequest(trace, err)
 				return
 			}
 		}
-		str.Close()
+		if !dontCloseStr {
+			str.Close()
+		}
+		traceWroteRequest(trace, nil)
 	}()
 
 	return nil
 }
 
-func (w *requestWriter) writeHeaders(wr io.Writer, req *http.Request, gzip bool) error {
+func (w *requestWriter) bodyFrameSize() int {
+	if w.frameSize > 0 {
+		return w.frameSize
+	}
+	return bodyCopyBufferSize
+}
+
+// writeBody sends the body in DATA frames.
+// Data read from the body is buffered until the flush threshold is reached, the buffer is full,
+// or the body returns an error (including io.EOF).
+// If reading from the body fails, the stream is reset.
+func (w *requestWriter) writeBody(str quic.Stream, body io.Reader, lowLatency bool) error {
+	frameSize := w.bodyFrameSize()
+	threshold := w.flushThreshold
+	if lowLatency {
+		threshold = 0
+	}
+	// leave room for the frame header, so that it is sent in the same write as the payload
+	const maxHeaderLen = 1 + 8
+	b := make([]byte, maxHeaderLen+frameSize)
+	payload := b[maxHeaderLen:]
+	var n int
+	for {
+		m, rerr := body.Read(payload[n:])
+		n += m
+		if n > 0 && (n >= threshold || n == frameSize || rerr != nil) {
+			hdr := &bytes.Buffer{}
+			(&dataFrame{Length: uint64(n)}).Write(hdr)
+			start := maxHeaderLen - hdr.Len()
+			copy(b[start:], hdr.Bytes())
+			if _, err := str.Write(b[start : maxHeaderLen+n]); err != nil {
+				return err
+			}
+			n = 0
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+			return rerr
+		}
+	}
+}
+
+func (w *requestWriter) writeHeaders(wr io.Writer, req *http.Request, gzip bool, trailers string) error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 	defer w.encoder.Close()
 
-	if err := w.encodeHeaders(req, gzip, "", actualContentLength(req)); err != nil {
+	if err := w.encodeHeaders(req, gzip, trailers, actualContentLength(req)); err != nil {
 		return err
 	}
+	return w.writeHeaderBlock(wr)
+}
+
+// writeTrailers writes the trailers in a HEADERS frame following the request body.
+func (w *requestWriter) writeTrailers(wr io.Writer, trailer http.Header) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	defer w.encoder.Close()
+
+	for k, vv := range trailer {
+		if !httpguts.ValidHeaderFieldName(k) {
+			return fmt.Errorf("invalid HTTP trailer name %q", k)
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return fmt.Errorf("invalid HTTP trailer value %q for trailer %q", v, k)
+			}
+		}
+	}
+	for k, vv := range trailer {
+		name := strings.ToLower(k)
+		for _, v := range vv {
+			w.encoder.WriteField(qpack.HeaderField{Name: name, Value: v})
+		}
+	}
+	return w.writeHeaderBlock(wr)
+}
 
+// writeHeaderBlock writes the encoded header block in a HEADERS frame.
+func (w *requestWriter) writeHeaderBlock(wr io.Writer) error {
 	buf := &bytes.Buffer{}
 	hf := headersFrame{Length: uint64(w.headerBuf.Len())}
 	hf.Write(buf)
@@ -126,8 +226,7 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 		return err
 	}
 
-	// http.NewRequest sets this field to HTTP/1.1
-	isExtendedConnect := req.Method == http.MethodConnect && req.Proto != "" && req.Proto != "HTTP/1.1"
+	isExtendedConnect := isExtendedConnectRequest(req)
 
 	var path string
 	if req.Method != http.MethodConnect || isExtendedConnect {
@@ -192,6 +291,15 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 				// fields. We have already checked if any
 				// are error-worthy so just ignore the rest.
 				continue
+			} else if strings.EqualFold(k, "te") {
+				// The only value allowed for the TE header field is "trailers" (RFC 9114, Section 4.2).
+				for _, v := range vv {
+					if strings.EqualFold(v, "trailers") {
+						f("te", "trailers")
+						break
+					}
+				}
+				continue
 			} else if strings.EqualFold(k, "user-agent") {
 				// Match Go's http1 behavior: at most one
 				// User-Agent. If set to nil or empty string,
@@ -238,21 +346,28 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 	// 	return errRequestHeaderListSize
 	// }
 
-	// trace := httptrace.ContextClientTrace(req.Context())
-	// traceHeaders := traceHasWroteHeaderField(trace)
+	trace := httptrace.ContextClientTrace(req.Context())
+	traceHeaders := traceHasWroteHeaderField(trace)
 
 	// Header list size is ok. Write the headers.
 	enumerateHeaders(func(name, value string) {
 		name = strings.ToLower(name)
 		w.encoder.WriteField(qpack.HeaderField{Name: name, Value: value})
-		// if traceHeaders {
-		// 	traceWroteHeaderField(trace, name, value)
-		// }
+		if traceHeaders {
+			traceWroteHeaderField(trace, name, value)
+		}
 	})
 
 	return nil
 }
 
+// isExtendedConnectRequest says if req is an Extended CONNECT request (RFC 9220).
+// The protocol is taken from the Proto field.
+func isExtendedConnectRequest(req *http.Request) bool {
+	// http.NewRequest sets this field to HTTP/1.1
+	return req.Method == http.MethodConnect && req.Proto != "" && req.Proto != "HTTP/1.1"
+}
+
 // authorityAddr returns a given authority (a host/IP, or host:port / ip:port)
 // and returns a host:port. The port 443 is added if needed.
 func authorityAddr(scheme string, authority string) (addr string) {
@@ -2,10 +2,13 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/lucas-clemente/quic-go"
 	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 
@@ -22,6 +25,28 @@ func (r *foobarReader) Read(b []byte) (int, error) {
 	return copy(b, []byte("foobar")), io.EOF
 }
 
+// chunkReader returns the chunks in separate calls to Read.
+type chunkReader struct {
+	chunks [][]byte
+	err    error // returned after the last chunk, io.EOF if nil
+}
+
+func (r *chunkReader) Read(b []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, io.EOF
+	}
+	n := copy(b, r.chunks[0])
+	if n == len(r.chunks[0]) {
+		r.chunks = r.chunks[1:]
+	} else {
+		r.chunks[0] = r.chunks[0][n:]
+	}
+	return n, nil
+}
+
 var _ = Describe("Request Writer", func() {
 	var (
 		rw     *requestWriter
@@ -56,11 +81,72 @@ var _ = Describe("Request Writer", func() {
 		}).AnyTimes()
 	})
 
+	Context("sending the body", func() {
+		// dataFrames parses the DATA frames written to the stream, and returns their payloads
+		dataFrames := func() []string {
+			var payloads []string
+			for strBuf.Len() > 0 {
+				frame, err := parseNextFrame(strBuf, nil)
+				ExpectWithOffset(1, err).ToNot(HaveOccurred())
+				ExpectWithOffset(1, frame).To(BeAssignableToTypeOf(&dataFrame{}))
+				payload := make([]byte, frame.(*dataFrame).Length)
+				_, err = io.ReadFull(strBuf, payload)
+				ExpectWithOffset(1, err).ToNot(HaveOccurred())
+				payloads = append(payloads, string(payload))
+			}
+			return payloads
+		}
+
+		chunks := func() *chunkReader {
+			return &chunkReader{chunks: [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}}
+		}
+
+		It("sends every chunk right away, by default", func() {
+			Expect(rw.writeBody(str, chunks(), false)).To(Succeed())
+			Expect(dataFrames()).To(Equal([]string{"foo", "bar", "baz"}))
+		})
+
+		It("limits the size of DATA frames", func() {
+			rw.frameSize = 2
+			Expect(rw.writeBody(str, chunks(), false)).To(Succeed())
+			Expect(dataFrames()).To(Equal([]string{"fo", "o", "ba", "r", "ba", "z"}))
+		})
+
+		It("buffers data until the flush threshold is reached", func() {
+			rw.flushThreshold = 5
+			Expect(rw.writeBody(str, chunks(), false)).To(Succeed())
+			Expect(dataFrames()).To(Equal([]string{"foobar", "baz"}))
+		})
+
+		It("sends a full frame before the flush threshold is reached", func() {
+			rw.frameSize = 4
+			rw.flushThreshold = 100
+			Expect(rw.writeBody(str, chunks(), false)).To(Succeed())
+			Expect(dataFrames()).To(Equal([]string{"foob", "arba", "z"}))
+		})
+
+		It("ignores the flush threshold in low-latency mode", func() {
+			rw.flushThreshold = 5
+			Expect(rw.writeBody(str, chunks(), true)).To(Succeed())
+			Expect(dataFrames()).To(Equal([]string{"foo", "bar", "baz"}))
+		})
+
+		It("sends the buffered data and resets the stream when reading fails", func() {
+			rw.flushThreshold = 100
+			testErr := errors.New("read error")
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+			r := chunks()
+			r.err = testErr
+			Expect(rw.writeBody(str, r, false)).To(MatchError(testErr))
+			Expect(dataFrames()).To(Equal([]string{"foobarbaz"}))
+		})
+	})
+
 	It("writes a GET request", func() {
 		str.EXPECT().Close()
 		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/index.html?foo=bar", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
 		Expect(headerFields).To(HaveKeyWithValue(":method", "GET"))
@@ -75,7 +161,7 @@ var _ = Describe("Request Writer", func() {
 		postData := bytes.NewReader([]byte("foobar"))
 		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload.html", postData)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
 
 		Eventually(closed).Should(BeClosed())
 		headerFields := decode(strBuf)
@@ -96,7 +182,7 @@ var _ = Describe("Request Writer", func() {
 		str.EXPECT().Close().Do(func() { close(closed) })
 		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload.html", &foobarReader{})
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
 
 		Eventually(closed).Should(BeClosed())
 		headerFields := decode(strBuf)
@@ -108,6 +194,76 @@ var _ = Describe("Request Writer", func() {
 		Expect(frame.(*dataFrame).Length).To(BeEquivalentTo(6))
 	})
 
+	It("waits before sending the body", func() {
+		closed := make(chan struct{})
+		str.EXPECT().Close().Do(func() { close(closed) })
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload.html", bytes.NewReader([]byte("foobar")))
+		Expect(err).ToNot(HaveOccurred())
+		continueCh := make(chan bool, 1)
+		Expect(rw.WriteRequest(str, req, false, false, false, continueCh)).To(Succeed())
+		Consistently(closed, 50*time.Millisecond).ShouldNot(BeClosed())
+		continueCh <- true
+		Eventually(closed).Should(BeClosed())
+		decode(strBuf)
+		frame, err := parseNextFrame(strBuf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(Equal(&dataFrame{Length: 6}))
+	})
+
+	It("doesn't send the body if told so", func() {
+		canceled := make(chan struct{})
+		str.EXPECT().CancelWrite(quic.StreamErrorCode(errorNoError)).Do(func(quic.StreamErrorCode) { close(canceled) })
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload.html", bytes.NewReader([]byte("foobar")))
+		Expect(err).ToNot(HaveOccurred())
+		continueCh := make(chan bool, 1)
+		Expect(rw.WriteRequest(str, req, false, false, false, continueCh)).To(Succeed())
+		continueCh <- false
+		Eventually(canceled).Should(BeClosed())
+		decode(strBuf)
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("writes trailers after the body", func() {
+		closed := make(chan struct{})
+		str.EXPECT().Close().Do(func() { close(closed) })
+		req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload.html", bytes.NewReader([]byte("foobar")))
+		Expect(err).ToNot(HaveOccurred())
+		req.Trailer = http.Header{"Foo": nil, "bar": []string{"baz"}}
+		req.Trailer.Set("Foo", "bar")
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
+
+		Eventually(closed).Should(BeClosed())
+		headerFields := decode(strBuf)
+		Expect(headerFields).To(HaveKeyWithValue("trailer", "Bar,Foo"))
+		frame, err := parseNextFrame(strBuf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(BeAssignableToTypeOf(&dataFrame{}))
+		data := make([]byte, frame.(*dataFrame).Length)
+		_, err = io.ReadFull(strBuf, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("foobar")))
+		trailers := decode(strBuf)
+		Expect(trailers).To(Equal(map[string]string{"foo": "bar", "bar": "baz"}))
+	})
+
+	It("writes trailers for requests without a body", func() {
+		str.EXPECT().Close()
+		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Trailer = http.Header{"Foo": []string{"bar"}}
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
+		Expect(decode(strBuf)).To(HaveKeyWithValue("trailer", "Foo"))
+		Expect(decode(strBuf)).To(Equal(map[string]string{"foo": "bar"}))
+	})
+
+	It("refuses to announce forbidden trailers", func() {
+		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Trailer = http.Header{"Content-Length": []string{"42"}}
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(MatchError(`invalid Trailer key "Content-Length"`))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
 	It("sends cookies", func() {
 		str.EXPECT().Close()
 		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
@@ -122,16 +278,40 @@ var _ = Describe("Request Writer", func() {
 		}
 		req.AddCookie(cookie1)
 		req.AddCookie(cookie2)
-		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue("cookie", `Cookie #1="Value #1"; Cookie #2="Value #2"`))
 	})
 
+	It("doesn't send connection-specific headers", func() {
+		str.EXPECT().Close()
+		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Connection", "close")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("TE", "gzip")
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
+		headerFields := decode(strBuf)
+		Expect(headerFields).ToNot(HaveKey("connection"))
+		Expect(headerFields).ToNot(HaveKey("upgrade"))
+		Expect(headerFields).ToNot(HaveKey("te"))
+	})
+
+	It("sends the TE header if its value is trailers", func() {
+		str.EXPECT().Close()
+		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("TE", "trailers")
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
+		headerFields := decode(strBuf)
+		Expect(headerFields).To(HaveKeyWithValue("te", "trailers"))
+	})
+
 	It("adds the header for gzip support", func() {
 		str.EXPECT().Close()
 		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequest(str, req, true)).To(Succeed())
+		Expect(rw.WriteRequest(str, req, false, true, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue("accept-encoding", "gzip"))
 	})
@@ -140,7 +320,7 @@ var _ = Describe("Request Writer", func() {
 		str.EXPECT().Close()
 		req, err := http.NewRequest(http.MethodConnect, "https://quic.clemente.io/", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue(":method", "CONNECT"))
 		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
@@ -154,7 +334,7 @@ var _ = Describe("Request Writer", func() {
 		req, err := http.NewRequest(http.MethodConnect, "https://quic.clemente.io/foobar", nil)
 		Expect(err).ToNot(HaveOccurred())
 		req.Proto = "webtransport"
-		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
 		Expect(headerFields).To(HaveKeyWithValue(":method", "CONNECT"))
@@ -3,13 +3,21 @@ package http3
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"net/textproto"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
 	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http/httpguts"
 )
 
 // DataStreamer lets the caller take over the stream. After a call to DataStream
@@ -22,24 +30,103 @@ type DataStreamer interface {
 	DataStream() quic.Stream
 }
 
+// RequestStreamHijacker lets a handler take over the request stream and the connection,
+// similar to the http.Hijacker. This allows running custom protocols on the request stream.
+type RequestStreamHijacker interface {
+	// HijackStream sends the response header, using status 200 unless WriteHeader was called before,
+	// and returns the request stream and the connection.
+	// From then on, the server doesn't send or parse any HTTP/3 frames on the stream,
+	// and the caller is responsible for closing it.
+	// Data sent by the client that wasn't consumed by reading the Request.Body is returned unparsed
+	// when reading from the stream. The Request.Body must not be used after calling HijackStream.
+	HijackStream() (quic.Stream, quic.Connection, error)
+}
+
+// DatagramStreamer lets the caller take over the stream, like DataStreamer,
+// and send and receive the HTTP datagrams associated with it (RFC 9297).
+// HTTP datagrams need to be enabled on the Server.
+type DatagramStreamer interface {
+	DatagramStream() (*DatagramStream, error)
+}
+
+// Datagrammer lets a handler send and receive the HTTP datagrams associated with the request stream (RFC 9297),
+// without taking over the stream: the response is written as usual.
+// HTTP datagrams need to be enabled on the Server, and can only be sent if the client enabled them as well.
+// Datagrams can be exchanged until the handler returns,
+// or, if the handler calls DatagramStream, until the DatagramStream is closed.
+type Datagrammer interface {
+	// SendDatagram sends an HTTP datagram associated with the request stream.
+	// Datagrams are sent unreliably, and are dropped if they are too large to fit into a QUIC packet.
+	SendDatagram(b []byte) error
+	// ReceiveDatagram receives an HTTP datagram associated with the request stream.
+	// Datagrams received before the first call to SendDatagram or ReceiveDatagram are dropped.
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// An AutoFlusher controls the buffering of a response.
+// It is implemented by the http.ResponseWriter passed to handlers.
+// Responses are buffered by default (see Server.WriteBufferSize),
+// and sent when the buffer is full, when the handler calls Flush, and when the handler returns.
+type AutoFlusher interface {
+	// SetAutoFlush configures if HEADERS and DATA frames are sent as soon as they are written,
+	// trading throughput for latency. Data buffered before enabling it is sent with the next write.
+	SetAutoFlush(enabled bool)
+}
+
 type responseWriter struct {
 	conn           quic.Connection
 	stream         quic.Stream // needed for DataStream()
 	bufferedStream *bufio.Writer
 
 	header         http.Header
-	status         int // status code passed to WriteHeader
+	status         int   // status code passed to WriteHeader
+	bytesWritten   int64 // number of bytes of the response body
 	headerWritten  bool
 	dataStreamUsed bool // set when DataSteam() is called
+	clearDeadlines bool // set if the server set deadlines on the stream, which need to be cleared when DataStream() is called
+	autoFlush      bool // set if frames are flushed as soon as they are written
+
+	// trailers are the trailers announced in the Trailer header when the header was written.
+	// Trailers set using http.TrailerPrefix are added when the trailers are written.
+	trailers []string
+
+	// pusher is used to implement http.Pusher.
+	// It is nil if server push is not possible for this response.
+	pusher func(target string, opts *http.PushOptions) error
+	// setPriority changes the priority used to schedule the response.
+	// It is nil for pushed responses, which are not scheduled.
+	setPriority func(Priority)
+	// datagrams is used to send and receive HTTP datagrams.
+	// It is nil if HTTP datagrams are disabled.
+	datagrams *datagramDemuxer
+	// streamDatagrams is used to implement the Datagrammer.
+	// It is created on first use, and is handed over to the DatagramStream when DatagramStream is called.
+	datagramsMutex     sync.Mutex
+	streamDatagrams    *streamDatagrams
+	datagramsTakenOver bool // set when DatagramStream is called
+	// settings gives access to the client's SETTINGS.
+	// It is nil for pushed responses.
+	settings Settingser
+	// webTransport is used to establish WebTransport sessions.
+	// It is nil if WebTransport is disabled.
+	webTransport *webTransportManager
 
 	logger utils.Logger
 }
 
 var (
-	_ http.ResponseWriter = &responseWriter{}
-	_ http.Flusher        = &responseWriter{}
-	_ DataStreamer        = &responseWriter{}
-	_ Hijacker            = &responseWriter{}
+	_ http.ResponseWriter   = &responseWriter{}
+	_ http.Flusher          = &responseWriter{}
+	_ DataStreamer          = &responseWriter{}
+	_ RequestStreamHijacker = &responseWriter{}
+	_ DatagramStreamer      = &responseWriter{}
+	_ Datagrammer           = &responseWriter{}
+	_ Hijacker              = &responseWriter{}
+	_ Settingser            = &responseWriter{}
+	_ http.Pusher           = &responseWriter{}
+	_ io.ReaderFrom         = &responseWriter{}
+	_ AutoFlusher           = &responseWriter{}
+	_ priorityUpdater       = &responseWriter{}
 )
 
 func newResponseWriter(stream quic.Stream, conn quic.Connection, logger utils.Logger) *responseWriter {
@@ -52,6 +139,31 @@ func newResponseWriter(stream quic.Stream, conn quic.Connection, logger utils.Lo
 	}
 }
 
+// findResponseWriter returns the responseWriter that w wraps.
+// Like the http.ResponseController, it follows the Unwrap methods of ResponseWriters added by middlewares.
+func findResponseWriter(w http.ResponseWriter) (*responseWriter, bool) {
+	for {
+		switch rw := w.(type) {
+		case *responseWriter:
+			return rw, true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// newPushResponseWriter creates a response writer for a pushed response.
+// Since push streams are unidirectional, it can't be used to take over the stream.
+func newPushResponseWriter(str quic.SendStream, logger utils.Logger) *responseWriter {
+	return &responseWriter{
+		header:         http.Header{},
+		bufferedStream: bufio.NewWriter(str),
+		logger:         logger,
+	}
+}
+
 func (w *responseWriter) Header() http.Header {
 	return w.header
 }
@@ -63,6 +175,11 @@ func (w *responseWriter) WriteHeader(status int) {
 
 	if status < 100 || status >= 200 {
 		w.headerWritten = true
+		for _, v := range w.header["Trailer"] {
+			for _, k := range strings.Split(v, ",") {
+				w.declareTrailer(textproto.TrimString(k))
+			}
+		}
 	}
 	w.status = status
 
@@ -71,23 +188,83 @@ func (w *responseWriter) WriteHeader(status int) {
 	enc.WriteField(qpack.HeaderField{Name: ":status", Value: strconv.Itoa(status)})
 
 	for k, v := range w.header {
+		// trailers are sent after the body
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		// Connection-specific header fields must not be sent (RFC 9114, Section 4.2).
+		// The TE header field is only meaningful in requests.
+		if strings.EqualFold(k, "connection") || strings.EqualFold(k, "proxy-connection") ||
+			strings.EqualFold(k, "transfer-encoding") || strings.EqualFold(k, "upgrade") ||
+			strings.EqualFold(k, "keep-alive") || strings.EqualFold(k, "te") {
+			continue
+		}
 		for index := range v {
 			enc.WriteField(qpack.HeaderField{Name: strings.ToLower(k), Value: v[index]})
 		}
 	}
 
-	buf := &bytes.Buffer{}
-	(&headersFrame{Length: uint64(headers.Len())}).Write(buf)
 	w.logger.Infof("Responding with %d", status)
+	w.writeHeaderBlock(headers.Bytes())
+	if !w.headerWritten || w.autoFlush {
+		w.Flush()
+	}
+}
+
+func (w *responseWriter) writeHeaderBlock(headerBlock []byte) {
+	buf := &bytes.Buffer{}
+	(&headersFrame{Length: uint64(len(headerBlock))}).Write(buf)
 	if _, err := w.bufferedStream.Write(buf.Bytes()); err != nil {
 		w.logger.Errorf("could not write headers frame: %s", err.Error())
 	}
-	if _, err := w.bufferedStream.Write(headers.Bytes()); err != nil {
+	if _, err := w.bufferedStream.Write(headerBlock); err != nil {
 		w.logger.Errorf("could not write header frame payload: %s", err.Error())
 	}
-	if !w.headerWritten {
-		w.Flush()
+}
+
+func (w *responseWriter) declareTrailer(k string) {
+	k = http.CanonicalHeaderKey(k)
+	if !httpguts.ValidTrailerHeader(k) {
+		w.logger.Debugf("ignoring invalid trailer %q", k)
+		return
+	}
+	for _, t := range w.trailers {
+		if t == k {
+			return
+		}
 	}
+	w.trailers = append(w.trailers, k)
+}
+
+// writeTrailers writes the trailers in a HEADERS frame after the body.
+// Following net/http, these are the values of the header fields announced in the Trailer header,
+// as well as the header fields prefixed with http.TrailerPrefix.
+// It is called after the handler returned.
+func (w *responseWriter) writeTrailers() {
+	for k, vv := range w.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			name := strings.TrimPrefix(k, http.TrailerPrefix)
+			w.declareTrailer(name)
+			w.header[http.CanonicalHeaderKey(name)] = vv
+		}
+	}
+	var headers bytes.Buffer
+	enc := qpack.NewEncoder(&headers)
+	var hasTrailers bool
+	for _, k := range w.trailers {
+		for _, v := range w.header[k] {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				w.logger.Debugf("ignoring invalid value for trailer %q", k)
+				continue
+			}
+			enc.WriteField(qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+			hasTrailers = true
+		}
+	}
+	if !hasTrailers {
+		return
+	}
+	w.writeHeaderBlock(headers.Bytes())
 }
 
 func (w *responseWriter) Write(p []byte) (int, error) {
@@ -103,15 +280,125 @@ func (w *responseWriter) Write(p []byte) (int, error) {
 	if _, err := w.bufferedStream.Write(buf.Bytes()); err != nil {
 		return 0, err
 	}
-	return w.bufferedStream.Write(p)
+	n, err := w.bufferedStream.Write(p)
+	w.bytesWritten += int64(n)
+	if err == nil && w.autoFlush {
+		err = w.bufferedStream.Flush()
+	}
+	return n, err
 }
 
-func (w *responseWriter) Flush() {
+const (
+	// readFromBufferSize is the maximum size of the DATA frames sent by ReadFrom.
+	readFromBufferSize = 64 << 10
+	// maxDataFrameHeaderLen is the maximum length of a DATA frame header:
+	// the frame type (1 byte) and the varint-encoded length (up to 8 bytes).
+	maxDataFrameHeaderLen = 1 + 8
+)
+
+var readFromBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, maxDataFrameHeaderLen+readFromBufferSize)
+		return &b
+	},
+}
+
+// ReadFrom reads data from r until EOF, and sends it in DATA frames.
+// Unlike Write, it reads directly into a large buffer that is passed to the stream,
+// which avoids copying the data when serving large responses (e.g. files).
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.headerWritten {
+		w.WriteHeader(200)
+	}
+	if !bodyAllowedForStatus(w.status) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	// With an empty buffer, the bufio.Writer passes large writes to the stream right away.
 	if err := w.bufferedStream.Flush(); err != nil {
+		return 0, err
+	}
+
+	bufp := readFromBufferPool.Get().(*[]byte)
+	defer readFromBufferPool.Put(bufp)
+	buf := *bufp
+	var written int64
+	for {
+		n, err := r.Read(buf[maxDataFrameHeaderLen:])
+		if n > 0 {
+			// write the frame header right in front of the data
+			hdrLen := 1 + quicvarint.Len(uint64(n))
+			start := maxDataFrameHeaderLen - hdrLen
+			(&dataFrame{Length: uint64(n)}).Write(bytes.NewBuffer(buf[start:start]))
+			if _, err := w.bufferedStream.Write(buf[start : maxDataFrameHeaderLen+n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+			w.bytesWritten += int64(n)
+			if w.autoFlush {
+				if err := w.bufferedStream.Flush(); err != nil {
+					return written, err
+				}
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func (w *responseWriter) Flush() {
+	if err := w.FlushError(); err != nil {
 		w.logger.Errorf("could not flush to stream: %s", err.Error())
 	}
 }
 
+func (w *responseWriter) SetAutoFlush(enabled bool) {
+	w.autoFlush = enabled
+}
+
+// The following methods are used by the http.ResponseController.
+
+// FlushError flushes buffered data to the stream, and returns any error that occurred.
+func (w *responseWriter) FlushError() error {
+	return w.bufferedStream.Flush()
+}
+
+// SetReadDeadline sets the deadline for reading the request body.
+func (w *responseWriter) SetReadDeadline(t time.Time) error {
+	return w.stream.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for writing the response.
+func (w *responseWriter) SetWriteDeadline(t time.Time) error {
+	return w.stream.SetWriteDeadline(t)
+}
+
+// EnableFullDuplex is a no-op: In HTTP/3, handlers can always read the request body
+// while writing the response.
+func (w *responseWriter) EnableFullDuplex() error {
+	return nil
+}
+
+// Push initiates an HTTP/3 server push.
+// It returns http.ErrNotSupported if the client disabled server push.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if w.pusher == nil {
+		return http.ErrNotSupported
+	}
+	return w.pusher(target, opts)
+}
+
+func (w *responseWriter) updatePriority(p Priority) error {
+	if w.setPriority == nil {
+		return errors.New("http3: response doesn't support priority updates")
+	}
+	w.setPriority(p)
+	return nil
+}
+
 func (w *responseWriter) usedDataStream() bool {
 	return w.dataStreamUsed
 }
@@ -119,9 +406,84 @@ func (w *responseWriter) usedDataStream() bool {
 func (w *responseWriter) DataStream() quic.Stream {
 	w.dataStreamUsed = true
 	w.Flush()
+	if w.clearDeadlines {
+		w.stream.SetDeadline(time.Time{})
+	}
 	return w.stream
 }
 
+func (w *responseWriter) HijackStream() (quic.Stream, quic.Connection, error) {
+	if w.dataStreamUsed {
+		return nil, nil, errors.New("http3: stream already taken over")
+	}
+	if !w.headerWritten {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := w.FlushError(); err != nil {
+		return nil, nil, err
+	}
+	return w.DataStream(), w.conn, nil
+}
+
+func (w *responseWriter) DatagramStream() (*DatagramStream, error) {
+	if w.datagrams == nil {
+		return nil, errors.New("http3: HTTP datagrams not enabled")
+	}
+	w.datagramsMutex.Lock()
+	datagrams := w.streamDatagrams
+	w.streamDatagrams = nil
+	w.datagramsTakenOver = true
+	w.datagramsMutex.Unlock()
+	// keep the datagrams that were already received
+	if datagrams != nil {
+		return &DatagramStream{Stream: w.DataStream(), datagrams: datagrams}, nil
+	}
+	return newDatagramStream(w.DataStream(), w.datagrams), nil
+}
+
+func (w *responseWriter) getStreamDatagrams() (*streamDatagrams, error) {
+	if w.datagrams == nil {
+		return nil, errors.New("http3: HTTP datagrams not enabled")
+	}
+	w.datagramsMutex.Lock()
+	defer w.datagramsMutex.Unlock()
+
+	if w.datagramsTakenOver {
+		return nil, ErrDatagramStreamClosed
+	}
+	if w.streamDatagrams == nil {
+		w.streamDatagrams = newStreamDatagrams(w.stream.StreamID(), w.datagrams)
+	}
+	return w.streamDatagrams, nil
+}
+
+func (w *responseWriter) SendDatagram(b []byte) error {
+	d, err := w.getStreamDatagrams()
+	if err != nil {
+		return err
+	}
+	return d.SendDatagram(b)
+}
+
+func (w *responseWriter) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	d, err := w.getStreamDatagrams()
+	if err != nil {
+		return nil, err
+	}
+	return d.ReceiveDatagram(ctx)
+}
+
+// closeDatagrams is called when the handler returns.
+// Datagrams can't be sent or received any more, unless the DatagramStream was taken over.
+func (w *responseWriter) closeDatagrams() {
+	w.datagramsMutex.Lock()
+	defer w.datagramsMutex.Unlock()
+
+	if w.streamDatagrams != nil {
+		w.streamDatagrams.close()
+	}
+}
+
 func (w *responseWriter) StreamID() quic.StreamID {
 	return w.stream.StreamID()
 }
@@ -130,6 +492,14 @@ func (w *responseWriter) StreamCreator() StreamCreator {
 	return w.conn
 }
 
+func (w *responseWriter) ReceivedSettings() <-chan struct{} {
+	return w.settings.ReceivedSettings()
+}
+
+func (w *responseWriter) Settings() *Settings {
+	return w.settings.Settings()
+}
+
 // copied from http2/http2.go
 // bodyAllowedForStatus reports whether a given response status code
 // permits a body. See RFC 2616, section 4.4.
@@ -2,8 +2,12 @@ package http3
 
 import (
 	"bytes"
+	"crypto/rand"
+	"errors"
 	"io"
 	"net/http"
+	"testing/iotest"
+	"time"
 
 	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
 	"github.com/lucas-clemente/quic-go/internal/utils"
@@ -73,6 +77,19 @@ var _ = Describe("Response Writer", func() {
 		Expect(fields).To(HaveKeyWithValue("content-length", []string{"42"}))
 	})
 
+	It("doesn't write connection-specific headers", func() {
+		rw.Header().Set("Connection", "close")
+		rw.Header().Set("Keep-Alive", "timeout=5")
+		rw.Header().Set("Transfer-Encoding", "chunked")
+		rw.Header().Set("TE", "gzip")
+		rw.Header().Set("foo", "bar")
+		rw.WriteHeader(http.StatusOK)
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveLen(2))
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+		Expect(fields).To(HaveKeyWithValue("foo", []string{"bar"}))
+	})
+
 	It("writes multiple headers with the same name", func() {
 		const cookie1 = "test1=1; Max-Age=7200; path=/"
 		const cookie2 = "test2=2; Max-Age=7200; path=/"
@@ -117,6 +134,51 @@ var _ = Describe("Response Writer", func() {
 		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
 	})
 
+	Context("flushing", func() {
+		It("buffers the response by default", func() {
+			rw.WriteHeader(http.StatusOK)
+			rw.Write([]byte("foobar"))
+			Expect(strBuf.Len()).To(BeZero())
+			rw.Flush()
+			Expect(decodeHeader(strBuf)).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		})
+
+		It("sends HEADERS and DATA frames right away, if auto-flushing is enabled", func() {
+			rw.SetAutoFlush(true)
+			rw.WriteHeader(http.StatusOK)
+			Expect(strBuf.Len()).ToNot(BeZero())
+			fields := decodeHeader(strBuf)
+			Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(strBuf.Len()).To(BeZero())
+			n, err := rw.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(6))
+			Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		})
+
+		It("sends DATA frames right away when using ReadFrom, if auto-flushing is enabled", func() {
+			rw.SetAutoFlush(true)
+			n, err := rw.ReadFrom(iotest.OneByteReader(bytes.NewReader([]byte("foo"))))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(3))
+			Expect(decodeHeader(strBuf)).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(getData(strBuf)).To(Equal([]byte("f")))
+			Expect(getData(strBuf)).To(Equal([]byte("o")))
+			Expect(getData(strBuf)).To(Equal([]byte("o")))
+		})
+
+		It("sends previously buffered data when auto-flushing is enabled", func() {
+			rw.Write([]byte("foo"))
+			Expect(strBuf.Len()).To(BeZero())
+			rw.SetAutoFlush(true)
+			rw.Write([]byte("bar"))
+			Expect(decodeHeader(strBuf)).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(getData(strBuf)).To(Equal([]byte("foo")))
+			Expect(getData(strBuf)).To(Equal([]byte("bar")))
+		})
+	})
+
 	It("allows calling WriteHeader() several times when using the 103 status code", func() {
 		rw.Header().Add("Link", "</style.css>; rel=preload; as=style")
 		rw.Header().Add("Link", "</script.js>; rel=preload; as=script")
@@ -147,4 +209,150 @@ var _ = Describe("Response Writer", func() {
 		Expect(n).To(BeZero())
 		Expect(err).To(MatchError(http.ErrBodyNotAllowed))
 	})
+
+	It("writes the announced trailers after the body", func() {
+		rw.Header().Set("Trailer", "Foo, bar")
+		rw.Header().Add("Trailer", "Content-Length") // not allowed in the trailers
+		rw.Write([]byte("foobar"))
+		rw.Header().Set("Foo", "1")
+		rw.Header().Set("Bar", "2")
+		rw.Header().Set("Content-Length", "6")
+		rw.Header().Set("Baz", "3") // not announced
+		rw.writeTrailers()
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+		Expect(fields).To(HaveKeyWithValue("trailer", []string{"Foo, bar", "Content-Length"}))
+		Expect(fields).ToNot(HaveKey("foo"))
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		Expect(decodeHeader(strBuf)).To(Equal(map[string][]string{
+			"foo": {"1"},
+			"bar": {"2"},
+		}))
+	})
+
+	It("writes trailers set using the trailer prefix", func() {
+		rw.Write([]byte("foobar"))
+		rw.Header().Set(http.TrailerPrefix+"Foo", "1")
+		rw.writeTrailers()
+		fields := decodeHeader(strBuf)
+		Expect(fields).ToNot(HaveKey("trailer:foo"))
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		Expect(decodeHeader(strBuf)).To(Equal(map[string][]string{"foo": {"1"}}))
+	})
+
+	It("doesn't write trailers if there are none", func() {
+		rw.Header().Set("Trailer", "Foo")
+		rw.Write([]byte("foobar"))
+		rw.writeTrailers()
+		decodeHeader(strBuf)
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("doesn't return a datagram stream if HTTP datagrams are disabled", func() {
+		_, err := rw.DatagramStream()
+		Expect(err).To(MatchError("http3: HTTP datagrams not enabled"))
+	})
+
+	Context("hijacking the stream", func() {
+		var (
+			str  *mockquic.MockStream
+			conn *mockquic.MockEarlyConnection
+		)
+
+		BeforeEach(func() {
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(strBuf.Write).AnyTimes()
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			rw = newResponseWriter(str, conn, utils.DefaultLogger)
+		})
+
+		It("sends the response header and returns the stream and the connection", func() {
+			rw.Header().Set("foo", "bar")
+			s, c, err := rw.HijackStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s).To(Equal(str))
+			Expect(c).To(Equal(conn))
+			fields := decodeHeader(strBuf)
+			Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(fields).To(HaveKeyWithValue("foo", []string{"bar"}))
+			Expect(rw.usedDataStream()).To(BeTrue())
+		})
+
+		It("uses the status code passed to WriteHeader", func() {
+			rw.WriteHeader(http.StatusAccepted)
+			_, _, err := rw.HijackStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decodeHeader(strBuf)).To(HaveKeyWithValue(":status", []string{"202"}))
+		})
+
+		It("doesn't hijack the stream twice", func() {
+			_, _, err := rw.HijackStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = rw.HijackStream()
+			Expect(err).To(MatchError("http3: stream already taken over"))
+		})
+	})
+
+	Context("reading from an io.Reader", func() {
+		It("sends the data in DATA frames", func() {
+			data := make([]byte, 3*readFromBufferSize/2)
+			rand.Read(data)
+			// hide the io.WriterTo implemented by the bytes.Reader, so that io.Copy uses ReadFrom
+			n, err := io.Copy(rw, struct{ io.Reader }{bytes.NewReader(data)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(len(data)))
+			fields := decodeHeader(strBuf)
+			Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+			first := getData(strBuf)
+			Expect(first).To(HaveLen(readFromBufferSize))
+			Expect(append(first, getData(strBuf)...)).To(Equal(data))
+			Expect(strBuf.Len()).To(BeZero())
+		})
+
+		It("returns errors that occur when reading", func() {
+			testErr := errors.New("test error")
+			n, err := rw.ReadFrom(io.MultiReader(bytes.NewReader([]byte("foobar")), iotest.ErrReader(testErr)))
+			Expect(err).To(MatchError(testErr))
+			Expect(n).To(BeEquivalentTo(6))
+			decodeHeader(strBuf)
+			Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		})
+
+		It("doesn't allow writes if the status code doesn't allow a body", func() {
+			rw.WriteHeader(http.StatusNotModified)
+			_, err := rw.ReadFrom(bytes.NewReader([]byte("foobar")))
+			Expect(err).To(MatchError(http.ErrBodyNotAllowed))
+		})
+	})
+
+	Context("http.ResponseController support", func() {
+		var str *mockquic.MockStream
+
+		BeforeEach(func() {
+			str = mockquic.NewMockStream(mockCtrl)
+			rw = newResponseWriter(str, nil, utils.DefaultLogger)
+		})
+
+		It("sets the deadlines", func() {
+			deadline := time.Now().Add(time.Hour)
+			str.EXPECT().SetReadDeadline(deadline)
+			Expect(rw.SetReadDeadline(deadline)).To(Succeed())
+			testErr := errors.New("test error")
+			str.EXPECT().SetWriteDeadline(deadline).Return(testErr)
+			Expect(rw.SetWriteDeadline(deadline)).To(MatchError(testErr))
+		})
+
+		It("enables full duplex", func() {
+			Expect(rw.EnableFullDuplex()).To(Succeed())
+		})
+
+		It("returns errors that occur when flushing", func() {
+			testErr := errors.New("test error")
+			str.EXPECT().Write(gomock.Any()).Return(0, testErr)
+			_, err := rw.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred()) // the data is buffered
+			Expect(rw.FlushError()).To(MatchError(testErr))
+		})
+	})
 })
@@ -6,17 +6,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/logging"
 
 	"golang.org/x/net/http/httpguts"
 )
 
 type roundTripCloser interface {
-	http.RoundTripper
+	RoundTripOpt(*http.Request, RoundTripOpt) (*http.Response, error)
 	io.Closer
 }
 
@@ -34,6 +38,15 @@ type RoundTripper struct {
 	// uncompressed.
 	DisableCompression bool
 
+	// CompressRequestBodies, if true, compresses request bodies using gzip,
+	// and sets the "Content-Encoding: gzip" request header.
+	// Bodies of requests that already have a Content-Encoding header are sent unmodified.
+	// Bodies up to 64 KB are compressed before sending the request, such that the Content-Length can be sent.
+	// Larger bodies (and bodies of unknown length) are compressed while they are sent,
+	// and are sent without a Content-Length.
+	// The server needs to support gzip-encoded request bodies.
+	CompressRequestBodies bool
+
 	// TLSClientConfig specifies the TLS configuration to use with
 	// tls.Client. If nil, the default configuration is used.
 	TLSClientConfig *tls.Config
@@ -58,17 +71,210 @@ type RoundTripper struct {
 	// Alternatively, callers can take over the QUIC stream (by returning hijacked true).
 	StreamHijacker func(FrameType, quic.Connection, quic.Stream) (hijacked bool, err error)
 
+	// When set, this callback is called for unidirectional streams of an unknown stream type.
+	// It is called right after parsing the stream type.
+	// If the callback doesn't take over the stream (by returning hijacked false),
+	// the stream is reset.
+	// Stream types registered using RegisterUniStreamType are not passed to the callback.
+	//
+	// Deprecated: Use RegisterUniStreamType instead.
+	UniStreamHijacker func(StreamType, quic.Connection, quic.ReceiveStream) (hijacked bool)
+
+	// Enable support for WebTransport (draft-ietf-webtrans-http3-02).
+	// This implies EnableDatagrams.
+	// WebTransport sessions are established using DialWebTransport.
+	EnableWebTransport bool
+
+	// PushHandler enables HTTP/3 server push, if set.
+	// It is called for every response pushed by the server, with the request promised by the server.
+	// The callback is responsible for closing the body of the response.
+	// It is called on a separate Go routine.
+	PushHandler func(promised *http.Request, rsp *http.Response)
+
 	// Dial specifies an optional dial function for creating QUIC
 	// connections for requests.
 	// If Dial is nil, quic.DialAddrEarlyContext will be used.
 	Dial func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error)
 
+	// PacketConn, if set, is used to send and receive the packets of all QUIC connections
+	// (including the connections to proxies), e.g. to use a socket bound to a specific interface, or an in-memory net.PacketConn in tests.
+	// The address of the server is resolved using net.ResolveUDPAddr,
+	// unless LookupIPAddr is set.
+	// The PacketConn is not closed when the RoundTripper is closed.
+	// It is not used if Dial is set.
+	PacketConn net.PacketConn
+
 	// MaxResponseHeaderBytes specifies a limit on how many response bytes are
 	// allowed in the server's response header.
 	// Zero means to use a default limit.
 	MaxResponseHeaderBytes int64
 
-	clients map[string]roundTripCloser
+	// MaxHeaderListSize limits the size of response header sections after QPACK decompression,
+	// calculated as defined in RFC 9114, Section 4.2.2.
+	// Unlike MaxResponseHeaderBytes, which limits the size of the compressed header section,
+	// it protects against header sections that expand when they are decompressed.
+	// It is sent to the server in the SETTINGS_MAX_FIELD_SECTION_SIZE setting.
+	// If zero, the size of decoded header sections is not limited.
+	MaxHeaderListSize uint64
+
+	// QPACKMaxTableCapacity is the maximum capacity of the QPACK dynamic table (RFC 9204)
+	// that servers can use to compress response headers.
+	// It is sent to the server in the SETTINGS_QPACK_MAX_TABLE_CAPACITY setting.
+	// If zero, servers can't use the dynamic table.
+	QPACKMaxTableCapacity uint64
+
+	// QPACKBlockedStreams is the maximum number of streams that can be blocked,
+	// waiting for the entries of the QPACK dynamic table referenced by the response headers.
+	// It is sent to the server in the SETTINGS_QPACK_BLOCKED_STREAMS setting.
+	// It only has an effect if QPACKMaxTableCapacity is set.
+	QPACKBlockedStreams uint64
+
+	// ExpectContinueTimeout, if non-zero, specifies the amount of
+	// time to wait for a server's first response headers after fully
+	// writing the request headers if the request has an
+	// "Expect: 100-continue" header.
+	// Zero means to use a default timeout of 1 second.
+	// The body is sent after receiving a 100 Continue response, or when the timeout expires.
+	ExpectContinueTimeout time.Duration
+
+	// KeepAlivePeriod is the interval at which keep-alives are sent on connections
+	// with outstanding requests, such that requests waiting for the server for a long time
+	// don't cause the connection to hit the QUIC idle timeout.
+	// A keep-alive is an empty frame of a type reserved for greasing, sent on the control stream.
+	// If zero, a default of 15 seconds is used. If negative, no keep-alives are sent.
+	// It should be lower than the idle timeout of the QUIC connection.
+	KeepAlivePeriod time.Duration
+
+	// KeepAliveIdleGrace is the time that keep-alives continue to be sent after the last
+	// outstanding request on a connection completed. Once it has passed, idle connections
+	// are closed by the QUIC idle timeout, unless a new request is sent before.
+	// If zero, keep-alives are only sent while requests are outstanding.
+	KeepAliveIdleGrace time.Duration
+
+	// RequestBodyFrameSize is the maximum size of the payload of the DATA frames used to send request bodies.
+	// If zero, a default of 8 KB is used.
+	RequestBodyFrameSize int
+
+	// RequestBodyFlushThreshold is the number of bytes of a request body that are buffered
+	// before they are sent in a DATA frame. Larger values reduce the framing overhead,
+	// but delay sending data that is read from the body in small chunks.
+	// If zero, every chunk read from the body is sent right away.
+	// It is ignored for requests sent with RoundTripOpt.LowLatencyRequestBody.
+	RequestBodyFlushThreshold int
+
+	// MaxIdleConnsPerHost, if non-zero, controls the maximum number of idle
+	// connections to keep per host. If zero, a default of 2 is used.
+	// If negative, connections are closed as soon as they become idle.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is the maximum amount of time an idle
+	// connection will remain idle before being closed.
+	// Zero means no limit.
+	IdleConnTimeout time.Duration
+
+	// MaxConcurrentRequestsPerConn limits the number of requests sent concurrently on a single connection.
+	// If all connections to a host have reached this limit, a new connection is dialed.
+	// Zero means no limit. Requests then block until the server allows opening a new stream.
+	MaxConcurrentRequestsPerConn int
+
+	// MaxConnsPerHost limits the number of connections per host, including connections that are being dialed.
+	// If the limit is reached and no connection can take a new request (e.g. because all connections
+	// have reached MaxConcurrentRequestsPerConn), requests are queued until a request completes
+	// or the request's context is canceled.
+	// Zero means no limit.
+	MaxConnsPerHost int
+
+	// Proxy specifies a function to return a proxy for a given request.
+	// If the function returns a non-nil error, the request is aborted with the provided error.
+	// QUIC connections are then established through a UDP proxying tunnel (RFC 9298),
+	// so the proxy needs to support HTTP/3 and HTTP datagrams.
+	// The scheme of the proxy URL must be https. If the path of the proxy URL contains
+	// the {target_host} and {target_port} variables, it is used as the URI template,
+	// otherwise DefaultConnectUDPTemplate is used.
+	// http.ProxyFromEnvironment can be used to use the proxy configured in the environment.
+	// If Proxy is nil or returns a nil URL, no proxy is used.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// LookupHTTPS, if set, is used to look up the HTTPS DNS records (RFC 9460) of the origins that requests are sent to.
+	// The QUIC connection is then established to the endpoints advertised for HTTP/3 (using the alpn, port and target name),
+	// instead of to the host and port of the origin.
+	// If the lookup fails, or no endpoint supports HTTP/3, the origin itself is dialed.
+	// LookupHTTPSRecords queries the DNS server configured in /etc/resolv.conf.
+	// It is not used for connections established through a proxy.
+	LookupHTTPS func(ctx context.Context, name string) ([]HTTPSRecord, error)
+
+	// LookupIPAddr, if set, is used to resolve host names, e.g. net.DefaultResolver.LookupIPAddr,
+	// or the LookupIPAddr method of a custom net.Resolver.
+	// Connection attempts are then made to all resolved addresses, in the order returned.
+	// A new attempt is started every ConnectionAttemptDelay, or as soon as the previous attempt failed,
+	// and the first connection that is established is used.
+	// If all attempts fail, a *DialError is returned, which contains the error for every address.
+	// If nil, only the first address returned by the system resolver is dialed.
+	LookupIPAddr func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	// ConnectionAttemptDelay is the time to wait for a connection attempt to complete
+	// before starting the next one, when dialing multiple addresses.
+	// If zero, a default of 250ms is used.
+	ConnectionAttemptDelay time.Duration
+
+	// Strict enables strict mode, which enforces additional requirements of RFC 9114 on the server:
+	// only a single control stream and a single stream of each QPACK stream type may be opened,
+	// these streams must not be closed, frame types reserved for HTTP/2 must not be used,
+	// and the response header fields must be well-formed.
+	// Violations are reported to the ProtocolViolationHandler.
+	// This is useful for interoperability testing, and for debugging misbehaving servers.
+	Strict bool
+
+	// ProtocolViolationHandler, if set, is called for every protocol violation detected in strict mode.
+	// It is called after the connection was closed (or the request failed, for violations on request streams).
+	ProtocolViolationHandler func(quic.Connection, *ProtocolViolation)
+
+	// CircuitBreaker, if set, temporarily stops sending requests to origins that can't be reached over HTTP/3.
+	// See CircuitBreaker for details.
+	CircuitBreaker *CircuitBreaker
+
+	// Logger is used to log the operation of the HTTP/3 connections.
+	// If nil, QuicConfig.Logger is used.
+	Logger logging.Logger
+
+	clients map[connKey]*connPool
+	proxyRT *RoundTripper // used for the connections to proxies
+
+	uniStreamHandlers uniStreamHandlers
+	frameHandlers     frameHandlers
+}
+
+// connKey identifies the connections in a connPool.
+type connKey struct {
+	hostname string
+	proxy    string      // the proxy URL, if a proxy is used
+	conf     *ConnConfig // the configuration override, if any
+}
+
+// A ConnConfig overrides the RoundTripper's configuration for the connections used by a request.
+// It is attached to a request using WithConnConfig.
+// Fields that are nil are taken from the RoundTripper.
+type ConnConfig struct {
+	// TLSClientConfig overrides RoundTripper.TLSClientConfig, e.g. to use a different client certificate.
+	TLSClientConfig *tls.Config
+	// QuicConfig overrides RoundTripper.QuicConfig.
+	QuicConfig *quic.Config
+	// Dial overrides RoundTripper.Dial.
+	Dial func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error)
+}
+
+var connConfigContextKey = &contextKey{"conn-config"}
+
+// WithConnConfig returns a context that makes the RoundTripper use conf for the connections used by requests with this context.
+// Connections are only shared between requests using the same ConnConfig,
+// so the same ConnConfig should be used for all requests to an endpoint.
+func WithConnConfig(ctx context.Context, conf *ConnConfig) context.Context {
+	return context.WithValue(ctx, connConfigContextKey, conf)
+}
+
+func connConfigFromContext(ctx context.Context) *ConnConfig {
+	conf, _ := ctx.Value(connConfigContextKey).(*ConnConfig)
+	return conf
 }
 
 // RoundTripOpt are options for the Transport.RoundTripOpt method.
@@ -76,55 +282,295 @@ type RoundTripOpt struct {
 	// OnlyCachedConn controls whether the RoundTripper may create a new QUIC connection.
 	// If set true and no cached connection is available, RoundTrip will return ErrNoCachedConn.
 	OnlyCachedConn bool
+	// DontCloseRequestStream controls whether the request stream is closed after sending the request.
+	// If set, context cancellations have no effect after the response headers are received.
+	DontCloseRequestStream bool
+	// Priority is the priority of the request (RFC 9218), sent in the Priority header field.
+	// If nil, the Priority header field of the request (if any) is sent unmodified.
+	// The priority can be changed while the response is being received by calling UpdatePriority.
+	Priority *Priority
+	// LowLatencyRequestBody makes the request body be sent as soon as it is read, regardless of the
+	// RoundTripper.RequestBodyFlushThreshold. This is useful for long-lived streaming uploads.
+	LowLatencyRequestBody bool
 }
 
 var _ roundTripCloser = &RoundTripper{}
 
+// maxRequestRetries is the number of times a request that wasn't processed by the server is retried.
+const maxRequestRetries = 3
+
 // ErrNoCachedConn is returned when RoundTripper.OnlyCachedConn is set
 var ErrNoCachedConn = errors.New("http3: no cached connection was available")
 
+// RegisterUniStreamType registers a handler for the unidirectional streams of type t opened by servers.
+// It should be called before the first request is sent.
+// It returns an error if t is used by HTTP/3, QPACK or WebTransport, if it is reserved for greasing,
+// or if a handler was already registered for t.
+// Streams of unregistered types are passed to the UniStreamHijacker, if set, and are reset otherwise.
+func (r *RoundTripper) RegisterUniStreamType(t StreamType, h UniStreamHandler) error {
+	return r.uniStreamHandlers.register(t, h)
+}
+
+// RegisterFrameType registers a handler for the frames of type t sent by servers
+// on the control stream and on request streams.
+// It should be called before the first request is sent.
+// It returns an error if t is used by HTTP/3 or WebTransport, if it is reserved for HTTP/2 frame types or for greasing,
+// or if a handler was already registered for t.
+// Frames of unregistered types are skipped.
+func (r *RoundTripper) RegisterFrameType(t FrameType, h FrameHandler) error {
+	return r.frameHandlers.register(t, h)
+}
+
 // RoundTripOpt is like RoundTrip, but takes options.
 func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Response, error) {
-	if req.URL == nil {
+	if err := validateRequest(req); err != nil {
 		closeRequestBody(req)
-		return nil, errors.New("http3: nil Request.URL")
+		return nil, err
 	}
-	if req.URL.Host == "" {
+
+	key, err := r.connKeyForRequest(req)
+	if err != nil {
 		closeRequestBody(req)
-		return nil, errors.New("http3: no Host in request URL")
+		return nil, err
 	}
-	if req.Header == nil {
-		closeRequestBody(req)
-		return nil, errors.New("http3: nil Request.Header")
+	if b := r.CircuitBreaker; b != nil && key.proxy == "" {
+		probe, err := b.allow(key.hostname, time.Now())
+		if err != nil {
+			if b.Fallback != nil {
+				return b.Fallback.RoundTrip(req)
+			}
+			closeRequestBody(req)
+			return nil, err
+		}
+		rsp, err := r.roundTripWithRetries(req, key, opt)
+		b.report(key.hostname, probe, err, time.Now())
+		return rsp, err
 	}
+	return r.roundTripWithRetries(req, key, opt)
+}
 
-	if req.URL.Scheme == "https" {
-		for k, vv := range req.Header {
-			if !httpguts.ValidHeaderFieldName(k) {
-				return nil, fmt.Errorf("http3: invalid http header field name %q", k)
-			}
-			for _, v := range vv {
-				if !httpguts.ValidHeaderFieldValue(v) {
-					return nil, fmt.Errorf("http3: invalid http header field value %q for key %v", v, k)
-				}
-			}
+// roundTripWithRetries sends a request using the connections identified by key.
+// Requests that were not processed by the server are retried.
+func (r *RoundTripper) roundTripWithRetries(req *http.Request, key connKey, opt RoundTripOpt) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		cl, err := r.getClient(req.Context(), key, opt.OnlyCachedConn)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		closeRequestBody(req)
-		return nil, fmt.Errorf("http3: unsupported protocol scheme: %s", req.URL.Scheme)
+		rsp, err := cl.RoundTripOpt(req, opt)
+		r.releaseClient(key, cl)
+		// Requests that were not processed by the server (e.g. because it is shutting down, or because the network
+		// changed while dialing), and requests that failed because the connection was lost before the response started,
+		// are retried on a new connection.
+		var uerr *unprocessedRequestError
+		var lerr *connLostError
+		switch {
+		case err == nil:
+			return rsp, nil
+		case errors.As(err, &uerr):
+			err = uerr.err
+		case errors.As(err, &lerr):
+			err = lerr.err
+		default:
+			return rsp, err
+		}
+		if retry >= maxRequestRetries {
+			return nil, err
+		}
+		rreq, rerr := rewindRequestBody(req)
+		if rerr != nil {
+			return nil, err
+		}
+		req = rreq
 	}
+}
 
+// validateRequest checks that req can be sent over HTTP/3.
+func validateRequest(req *http.Request) error {
+	if req.URL == nil {
+		return errors.New("http3: nil Request.URL")
+	}
+	if req.URL.Host == "" {
+		return errors.New("http3: no Host in request URL")
+	}
+	if req.Header == nil {
+		return errors.New("http3: nil Request.Header")
+	}
+	if req.URL.Scheme != "https" {
+		return fmt.Errorf("http3: unsupported protocol scheme: %s", req.URL.Scheme)
+	}
+	for k, vv := range req.Header {
+		if !httpguts.ValidHeaderFieldName(k) {
+			return fmt.Errorf("http3: invalid http header field name %q", k)
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return fmt.Errorf("http3: invalid http header field value %q for key %v", v, k)
+			}
+		}
+	}
 	if req.Method != "" && !validMethod(req.Method) {
-		closeRequestBody(req)
-		return nil, fmt.Errorf("http3: invalid method %q", req.Method)
+		return fmt.Errorf("http3: invalid method %q", req.Method)
 	}
+	return nil
+}
 
-	hostname := authorityAddr("https", hostnameFromRequest(req))
-	cl, err := r.getClient(hostname, opt.OnlyCachedConn)
+// rewindRequestBody returns a request with a fresh body, such that it can be sent again.
+func rewindRequestBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("http3: cannot rewind the request body")
+	}
+	body, err := req.GetBody()
 	if err != nil {
 		return nil, err
 	}
-	return cl.RoundTrip(req)
+	r := *req
+	r.Body = body
+	return &r, nil
+}
+
+// DialWebTransport establishes a WebTransport session with the server at urlStr,
+// by sending an Extended CONNECT request.
+// The context is only used for establishing the session.
+// If the server rejects the session, the response is returned together with an error.
+func (r *RoundTripper) DialWebTransport(ctx context.Context, urlStr string, header http.Header) (*http.Response, *WebTransportSession, error) {
+	if !r.EnableWebTransport {
+		return nil, nil, errors.New("http3: WebTransport not enabled")
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, nil, fmt.Errorf("http3: unsupported protocol scheme: %s", u.Scheme)
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	req := (&http.Request{
+		Method: http.MethodConnect,
+		Proto:  protocolWebTransport,
+		URL:    u,
+		Host:   u.Host,
+		Header: header,
+	}).WithContext(ctx)
+
+	c, release, err := r.getExtendedConnectClient(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return c.dialWebTransport(req)
+}
+
+// DialConnectUDP establishes a UDP proxying tunnel (RFC 9298) to target, which is a host:port.
+// The proxy is determined by the URI template, e.g.
+// https://proxy.example.org/.well-known/masque/udp/{target_host}/{target_port}/.
+// UDP payloads are sent in HTTP datagrams, so EnableDatagrams needs to be set.
+// The context is only used for establishing the tunnel.
+// If the proxy rejects the request, the response is returned together with an error.
+func (r *RoundTripper) DialConnectUDP(ctx context.Context, template, target string) (*http.Response, net.PacketConn, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := url.Parse(expandConnectUDPTemplate(template, host, port))
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, nil, fmt.Errorf("http3: unsupported protocol scheme: %s", u.Scheme)
+	}
+	req := (&http.Request{
+		Method: http.MethodConnect,
+		Proto:  protocolConnectUDP,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{"Capsule-Protocol": {"?1"}},
+	}).WithContext(ctx)
+
+	c, release, err := r.getExtendedConnectClient(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return c.dialConnectUDP(req, connectUDPAddr(target))
+}
+
+// DialConnectIP establishes an IP proxying tunnel (RFC 9484).
+// The proxy is determined by the URI template, e.g.
+// https://proxy.example.org/.well-known/masque/ip/{target}/{ipproto}/.
+// IP packets are sent in HTTP datagrams, so EnableDatagrams needs to be set.
+// The context is only used for establishing the tunnel.
+// If the proxy rejects the request, the response is returned together with an error.
+func (r *RoundTripper) DialConnectIP(ctx context.Context, template string, ipReq ConnectIPRequest) (*http.Response, *ConnectIPConn, error) {
+	u, err := url.Parse(expandURITemplate(template, ipReq.templateVars()))
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, nil, fmt.Errorf("http3: unsupported protocol scheme: %s", u.Scheme)
+	}
+	req := (&http.Request{
+		Method: http.MethodConnect,
+		Proto:  protocolConnectIP,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{"Capsule-Protocol": {"?1"}},
+	}).WithContext(ctx)
+
+	c, release, err := r.getExtendedConnectClient(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return c.dialConnectIP(req)
+}
+
+// OpenDatagramStream sends a request, and returns the request stream together with the HTTP datagrams
+// associated with it (RFC 9297). This is usually an Extended CONNECT request for a protocol using HTTP datagrams.
+// EnableDatagrams needs to be set, and the server needs to enable HTTP datagrams as well.
+// The response body is not read, the caller is responsible for reading from and closing the stream.
+// If the server responds with a status code other than 2xx, the response is returned together with an error.
+func (r *RoundTripper) OpenDatagramStream(req *http.Request) (*http.Response, *DatagramStream, error) {
+	if req.URL == nil {
+		closeRequestBody(req)
+		return nil, nil, errors.New("http3: nil Request.URL")
+	}
+	if req.URL.Scheme != "https" {
+		closeRequestBody(req)
+		return nil, nil, fmt.Errorf("http3: unsupported protocol scheme: %s", req.URL.Scheme)
+	}
+	c, release, err := r.getExtendedConnectClient(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return c.openDatagramStream(req)
+}
+
+// getExtendedConnectClient returns the client used for an Extended CONNECT request,
+// which takes over the request stream after the response was received.
+// The release function must be called once the request has been sent.
+func (r *RoundTripper) getExtendedConnectClient(req *http.Request) (*client, func(), error) {
+	key, err := r.connKeyForRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	cl, err := r.getClient(req.Context(), key, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() { r.releaseClient(key, cl) }
+	c, ok := cl.poolableClient.(*client)
+	if !ok {
+		release()
+		return nil, nil, fmt.Errorf("http3: %s not supported by this client", req.Proto)
+	}
+	return c, release, nil
 }
 
 // RoundTrip does a round trip.
@@ -132,50 +578,289 @@ func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return r.RoundTripOpt(req, RoundTripOpt{})
 }
 
-func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTripper, error) {
+// connKeyForRequest returns the key of the connection pool used for req.
+func (r *RoundTripper) connKeyForRequest(req *http.Request) (connKey, error) {
+	key := connKey{
+		hostname: authorityAddr("https", hostnameFromRequest(req)),
+		conf:     connConfigFromContext(req.Context()),
+	}
+	if r.Proxy == nil {
+		return key, nil
+	}
+	proxy, err := r.Proxy(req)
+	if err != nil {
+		return connKey{}, err
+	}
+	if proxy == nil {
+		return key, nil
+	}
+	if proxy.Scheme != "https" {
+		return connKey{}, fmt.Errorf("http3: unsupported proxy scheme: %s", proxy.Scheme)
+	}
+	key.proxy = proxy.String()
+	return key, nil
+}
+
+// getClient returns a client for a new request using the connections identified by key.
+// releaseClient must be called once the client's RoundTripOpt returned.
+// If MaxConnsPerHost is reached, it blocks until a client becomes available, or until ctx is canceled.
+func (r *RoundTripper) getClient(ctx context.Context, key connKey, onlyCached bool) (*pooledClient, error) {
+	for {
+		cl, wait, err := r.tryGetClient(key, onlyCached)
+		if wait == nil {
+			return cl, err
+		}
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryGetClient returns a client for a new request, creating a new client if necessary.
+// If no client is available and MaxConnsPerHost is reached, it returns a channel
+// that is closed when the state of the pool changes.
+func (r *RoundTripper) tryGetClient(key connKey, onlyCached bool) (*pooledClient, <-chan struct{}, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	r.removeIdleClients(key)
 	if r.clients == nil {
-		r.clients = make(map[string]roundTripCloser)
+		r.clients = make(map[connKey]*connPool)
 	}
-
-	client, ok := r.clients[hostname]
+	pool, ok := r.clients[key]
 	if !ok {
-		if onlyCached {
-			return nil, ErrNoCachedConn
-		}
-		var err error
-		client, err = newClient(
-			hostname,
-			r.TLSClientConfig,
-			&roundTripperOpts{
-				EnableDatagram:     r.EnableDatagrams,
-				DisableCompression: r.DisableCompression,
-				MaxHeaderBytes:     r.MaxResponseHeaderBytes,
-				StreamHijacker:     r.StreamHijacker,
-			},
-			r.QuicConfig,
-			r.Dial,
-		)
+		pool = &connPool{}
+		r.clients[key] = pool
+	}
+	if cl := pool.get(r.MaxConcurrentRequestsPerConn); cl != nil {
+		cl.pending++
+		return cl, nil, nil
+	}
+	if onlyCached {
+		return nil, nil, ErrNoCachedConn
+	}
+	if r.MaxConnsPerHost > 0 && len(pool.clients) >= r.MaxConnsPerHost {
+		return nil, pool.wait(), nil
+	}
+	cl, err := r.newClient(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool.clients = append(pool.clients, cl)
+	return cl, nil, nil
+}
+
+// newClient creates a new client for the connections identified by key.
+func (r *RoundTripper) newClient(key connKey) (*pooledClient, error) {
+	tlsConf, quicConf, dial := r.TLSClientConfig, r.QuicConfig, r.Dial
+	if key.conf != nil {
+		if key.conf.TLSClientConfig != nil {
+			tlsConf = key.conf.TLSClientConfig
+		}
+		if key.conf.QuicConfig != nil {
+			quicConf = key.conf.QuicConfig
+		}
+		if key.conf.Dial != nil {
+			dial = key.conf.Dial
+		}
+	}
+	if dial == nil && r.PacketConn != nil {
+		dial = dialPacketConn(r.PacketConn)
+	}
+	if key.proxy != "" {
+		proxy, err := url.Parse(key.proxy)
 		if err != nil {
 			return nil, err
 		}
-		r.clients[hostname] = client
+		dial = func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+			return r.dialProxy(ctx, proxy, addr, tlsConf, conf)
+		}
+	} else {
+		if r.LookupIPAddr != nil {
+			dial = dialResolved(r.LookupIPAddr, r.connectionAttemptDelay(), dial)
+		}
+		if r.LookupHTTPS != nil {
+			dial = dialHTTPSEndpoints(r.LookupHTTPS, dial)
+		}
+	}
+	opts := r.clientOpts(func() { r.handleIdleClient(key) })
+	if r.MaxConnsPerHost > 0 {
+		opts.onRequestDone = func() { r.handleRequestDone(key) }
+	}
+	c, err := newClient(key.hostname, tlsConf, opts, quicConf, dial)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledClient{poolableClient: c, pending: 1}, nil
+}
+
+func (r *RoundTripper) connectionAttemptDelay() time.Duration {
+	if r.ConnectionAttemptDelay != 0 {
+		return r.ConnectionAttemptDelay
+	}
+	return defaultConnectionAttemptDelay
+}
+
+// clientOpts returns the options used for the clients created by this RoundTripper.
+func (r *RoundTripper) clientOpts(onIdle func()) *roundTripperOpts {
+	return &roundTripperOpts{
+		EnableDatagram:            r.EnableDatagrams,
+		AdditionalSettings:        r.AdditionalSettings,
+		DisableCompression:        r.DisableCompression,
+		CompressRequestBodies:     r.CompressRequestBodies,
+		MaxHeaderBytes:            r.MaxResponseHeaderBytes,
+		MaxHeaderListSize:         r.MaxHeaderListSize,
+		QPACKMaxTableCapacity:     r.QPACKMaxTableCapacity,
+		QPACKBlockedStreams:       r.QPACKBlockedStreams,
+		StreamHijacker:            r.StreamHijacker,
+		UniStreamHijacker:         r.UniStreamHijacker,
+		uniStreamHandlers:         &r.uniStreamHandlers,
+		frameHandlers:             &r.frameHandlers,
+		PushHandler:               r.PushHandler,
+		EnableWebTransport:        r.EnableWebTransport,
+		ExpectContinueTimeout:     r.ExpectContinueTimeout,
+		KeepAlivePeriod:           r.KeepAlivePeriod,
+		KeepAliveIdleGrace:        r.KeepAliveIdleGrace,
+		RequestBodyFrameSize:      r.RequestBodyFrameSize,
+		RequestBodyFlushThreshold: r.RequestBodyFlushThreshold,
+		Logger:                    r.Logger,
+		onIdle:                    onIdle,
+
+		Strict:                   r.Strict,
+		ProtocolViolationHandler: r.ProtocolViolationHandler,
+	}
+}
+
+// dialConn establishes a connection that can be used for req, unless a connection is available already.
+// The connection is added to the pool, and can be used by subsequent requests.
+func (r *RoundTripper) dialConn(ctx context.Context, req *http.Request) error {
+	key, err := r.connKeyForRequest(req)
+	if err != nil {
+		return err
+	}
+	cl, err := r.getClient(ctx, key, false)
+	if err != nil {
+		return err
+	}
+	defer r.releaseClient(key, cl)
+	_, err = cl.connect(ctx)
+	return err
+}
+
+func (r *RoundTripper) releaseClient(key connKey, cl *pooledClient) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cl.pending--
+	r.removeIdleClients(key)
+	if pool, ok := r.clients[key]; ok {
+		pool.notify()
+	}
+}
+
+// handleRequestDone is called when a request on a connection in the pool completed.
+// It wakes up the requests waiting for a connection, if any.
+func (r *RoundTripper) handleRequestDone(key connKey) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if pool, ok := r.clients[key]; ok {
+		pool.notify()
+	}
+}
+
+// handleIdleClient is called when the last active request on a connection in the pool completed,
+// and when the idle timer fires.
+func (r *RoundTripper) handleIdleClient(key connKey) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.removeIdleClients(key)
+}
+
+// removeIdleClients closes the idle clients in the pool that are not needed any more,
+// and resets the idle timer to fire when the next idle client expires.
+// The mutex must be held when calling this function.
+func (r *RoundTripper) removeIdleClients(key connKey) {
+	pool, ok := r.clients[key]
+	if !ok {
+		return
+	}
+	maxIdle := r.MaxIdleConnsPerHost
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConnsPerHost
+	}
+	removed, nextExpiry := pool.removeIdle(time.Now(), r.IdleConnTimeout, maxIdle)
+	for _, cl := range removed {
+		cl.Close()
+	}
+	if len(removed) > 0 {
+		pool.notify()
+	}
+	if len(pool.clients) == 0 {
+		if pool.idleTimer != nil {
+			pool.idleTimer.Stop()
+		}
+		delete(r.clients, key)
+		return
+	}
+	if nextExpiry.IsZero() {
+		return
+	}
+	if pool.idleTimer == nil {
+		pool.idleTimer = time.AfterFunc(time.Until(nextExpiry), func() { r.handleIdleClient(key) })
+	} else {
+		pool.idleTimer.Reset(time.Until(nextExpiry))
+	}
+}
+
+// CloseIdleConnections closes all connections that don't have any requests in flight.
+// It does not interrupt any connections currently in use.
+func (r *RoundTripper) CloseIdleConnections() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, pool := range r.clients {
+		for _, cl := range append([]*pooledClient{}, pool.clients...) {
+			if cl.isIdle() {
+				cl.Close()
+				pool.remove(cl)
+				pool.notify()
+			}
+		}
+		if len(pool.clients) == 0 {
+			if pool.idleTimer != nil {
+				pool.idleTimer.Stop()
+			}
+			delete(r.clients, key)
+		}
+	}
+	if r.proxyRT != nil {
+		r.proxyRT.CloseIdleConnections()
 	}
-	return client, nil
 }
 
 // Close closes the QUIC connections that this RoundTripper has used
 func (r *RoundTripper) Close() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	for _, client := range r.clients {
-		if err := client.Close(); err != nil {
-			return err
+	for _, pool := range r.clients {
+		if pool.idleTimer != nil {
+			pool.idleTimer.Stop()
+		}
+		pool.notify()
+		for _, cl := range pool.clients {
+			if err := cl.Close(); err != nil {
+				return err
+			}
 		}
 	}
 	r.clients = nil
+	if r.proxyRT != nil {
+		return r.proxyRT.Close()
+	}
 	return nil
 }
 
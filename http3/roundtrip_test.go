@@ -6,7 +6,11 @@ import (
 	"crypto/tls"
 	"errors"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"syscall"
 	"time"
 
 	"github.com/golang/mock/gomock"
@@ -17,19 +21,47 @@ import (
 )
 
 type mockClient struct {
-	closed bool
+	closed     bool
+	errs       []error // returned by the first calls to RoundTripOpt
+	requests   []*http.Request
+	goingAway  bool
+	connectErr error
+	active     int
+	idleSince  time.Time
 }
 
-func (m *mockClient) RoundTrip(req *http.Request) (*http.Response, error) {
+func (m *mockClient) RoundTripOpt(req *http.Request, _ RoundTripOpt) (*http.Response, error) {
+	m.requests = append(m.requests, req)
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return nil, err
+	}
 	return &http.Response{Request: req}, nil
 }
 
+func (m *mockClient) connect(context.Context) (bool, error) {
+	return false, m.connectErr
+}
+
 func (m *mockClient) Close() error {
 	m.closed = true
 	return nil
 }
 
-var _ roundTripCloser = &mockClient{}
+func (m *mockClient) canTakeNewRequest() bool { return !m.goingAway && !m.closed }
+
+func (m *mockClient) getActiveRequests() (int, time.Time) { return m.active, m.idleSince }
+
+var _ poolableClient = &mockClient{}
+
+func newConnPool(clients ...poolableClient) *connPool {
+	p := &connPool{}
+	for _, c := range clients {
+		p.clients = append(p.clients, &pooledClient{poolableClient: c})
+	}
+	return p
+}
 
 type mockBody struct {
 	reader   bytes.Reader
@@ -93,21 +125,32 @@ var _ = Describe("RoundTripper", func() {
 			dialAddr = origDialAddr
 		})
 
+		// newCanceledRequest creates a request that is canceled when opening the stream,
+		// such that it is not retried on a new connection
+		newCanceledRequest := func(url string) *http.Request {
+			ctx, cancel := context.WithCancel(context.Background())
+			req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+			Expect(err).ToNot(HaveOccurred())
+			conn.EXPECT().OpenStreamSync(ctx).DoAndReturn(func(context.Context) (quic.Stream, error) {
+				cancel()
+				return nil, context.Canceled
+			})
+			return req
+		}
+
 		It("creates new clients", func() {
 			closed := make(chan struct{})
 			testErr := errors.New("test err")
-			req, err := http.NewRequest("GET", "https://quic.clemente.io/foobar.html", nil)
-			Expect(err).ToNot(HaveOccurred())
+			req := newCanceledRequest("https://quic.clemente.io/foobar.html")
 			conn.EXPECT().OpenUniStream().AnyTimes().Return(nil, testErr)
 			conn.EXPECT().HandshakeComplete().Return(handshakeCtx)
-			conn.EXPECT().OpenStreamSync(context.Background()).Return(nil, testErr)
 			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
 				<-closed
 				return nil, errors.New("test done")
 			}).MaxTimes(1)
 			conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) { close(closed) })
-			_, err = rt.RoundTrip(req)
-			Expect(err).To(MatchError(testErr))
+			_, err := rt.RoundTrip(req)
+			Expect(err).To(MatchError(context.Canceled))
 			Expect(rt.clients).To(HaveLen(1))
 			Eventually(closed).Should(BeClosed())
 		})
@@ -125,6 +168,18 @@ var _ = Describe("RoundTripper", func() {
 			Expect(receivedConfig.HandshakeIdleTimeout).To(Equal(config.HandshakeIdleTimeout))
 		})
 
+		It("dials a new connection if the network changed while dialing", func() {
+			netErr := &net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("sendmsg", syscall.EHOSTUNREACH)}
+			var dials int
+			dialAddr = func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+				dials++
+				return nil, netErr
+			}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(netErr))
+			Expect(dials).To(Equal(maxRequestRetries + 1))
+		})
+
 		It("uses the custom dialer, if provided", func() {
 			var dialed bool
 			dialer := func(_ context.Context, _ string, tlsCfgP *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
@@ -137,27 +192,72 @@ var _ = Describe("RoundTripper", func() {
 			Expect(dialed).To(BeTrue())
 		})
 
+		It("uses the configuration attached to the request", func() {
+			rt.TLSClientConfig = &tls.Config{ServerName: "foo"}
+			rt.QuicConfig = &quic.Config{HandshakeIdleTimeout: time.Second}
+			var receivedTLSConf *tls.Config
+			var receivedQuicConf *quic.Config
+			conf := &ConnConfig{
+				TLSClientConfig: &tls.Config{ServerName: "bar"},
+				QuicConfig:      &quic.Config{HandshakeIdleTimeout: time.Millisecond},
+				Dial: func(_ context.Context, _ string, tlsConf *tls.Config, quicConf *quic.Config) (quic.EarlyConnection, error) {
+					receivedTLSConf = tlsConf
+					receivedQuicConf = quicConf
+					return nil, errors.New("handshake error")
+				},
+			}
+			_, err := rt.RoundTrip(req1.WithContext(WithConnConfig(context.Background(), conf)))
+			Expect(err).To(MatchError("handshake error"))
+			Expect(receivedTLSConf.ServerName).To(Equal("bar"))
+			Expect(receivedQuicConf.HandshakeIdleTimeout).To(Equal(time.Millisecond))
+		})
+
+		It("only overrides the fields that are set", func() {
+			rt.QuicConfig = &quic.Config{HandshakeIdleTimeout: time.Millisecond}
+			var receivedQuicConf *quic.Config
+			rt.Dial = func(_ context.Context, _ string, _ *tls.Config, quicConf *quic.Config) (quic.EarlyConnection, error) {
+				receivedQuicConf = quicConf
+				return nil, errors.New("handshake error")
+			}
+			conf := &ConnConfig{TLSClientConfig: &tls.Config{ServerName: "bar"}}
+			_, err := rt.RoundTrip(req1.WithContext(WithConnConfig(context.Background(), conf)))
+			Expect(err).To(MatchError("handshake error"))
+			Expect(receivedQuicConf.HandshakeIdleTimeout).To(Equal(time.Millisecond))
+		})
+
+		It("uses separate connections for every ConnConfig", func() {
+			conf := &ConnConfig{}
+			key := func(ctx context.Context) connKey {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.example.org", nil)
+				Expect(err).ToNot(HaveOccurred())
+				key, err := rt.connKeyForRequest(req)
+				Expect(err).ToNot(HaveOccurred())
+				return key
+			}
+			Expect(key(context.Background())).To(Equal(connKey{hostname: "www.example.org:443"}))
+			Expect(key(WithConnConfig(context.Background(), conf))).To(Equal(connKey{hostname: "www.example.org:443", conf: conf}))
+			// Equal uses reflect.DeepEqual, which doesn't compare pointers
+			Expect(key(WithConnConfig(context.Background(), conf)) == key(WithConnConfig(context.Background(), conf))).To(BeTrue())
+			Expect(key(WithConnConfig(context.Background(), &ConnConfig{})) == key(WithConnConfig(context.Background(), conf))).To(BeFalse())
+		})
+
 		It("reuses existing clients", func() {
 			closed := make(chan struct{})
 			testErr := errors.New("test err")
 			conn.EXPECT().OpenUniStream().AnyTimes().Return(nil, testErr)
 			conn.EXPECT().HandshakeComplete().Return(handshakeCtx).Times(2)
-			conn.EXPECT().OpenStreamSync(context.Background()).Return(nil, testErr).Times(2)
 			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
 				<-closed
 				return nil, errors.New("test done")
 			}).MaxTimes(1)
 			conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) { close(closed) })
-			req, err := http.NewRequest("GET", "https://quic.clemente.io/file1.html", nil)
-			Expect(err).ToNot(HaveOccurred())
-			_, err = rt.RoundTrip(req)
-			Expect(err).To(MatchError(testErr))
+			_, err := rt.RoundTrip(newCanceledRequest("https://quic.clemente.io/file1.html"))
+			Expect(err).To(MatchError(context.Canceled))
 			Expect(rt.clients).To(HaveLen(1))
-			req2, err := http.NewRequest("GET", "https://quic.clemente.io/file2.html", nil)
-			Expect(err).ToNot(HaveOccurred())
-			_, err = rt.RoundTrip(req2)
-			Expect(err).To(MatchError(testErr))
+			_, err = rt.RoundTrip(newCanceledRequest("https://quic.clemente.io/file2.html"))
+			Expect(err).To(MatchError(context.Canceled))
 			Expect(rt.clients).To(HaveLen(1))
+			Expect(rt.clients[connKey{hostname: "quic.clemente.io:443"}].clients).To(HaveLen(1))
 			Eventually(closed).Should(BeClosed())
 		})
 
@@ -231,11 +331,381 @@ var _ = Describe("RoundTripper", func() {
 		})
 	})
 
+	Context("circuit breaking", func() {
+		const hostname = "www.example.org:443"
+
+		handshakeTimeouts := func(n int) []error {
+			errs := make([]error, n)
+			for i := range errs {
+				errs[i] = &quic.HandshakeTimeoutError{}
+			}
+			return errs
+		}
+
+		It("fails fast once an origin is broken", func() {
+			var states []bool
+			rt.CircuitBreaker = &CircuitBreaker{
+				FailureThreshold: 2,
+				OnStateChange: func(origin string, broken bool) {
+					Expect(origin).To(Equal(hostname))
+					states = append(states, broken)
+				},
+			}
+			cl := &mockClient{errs: handshakeTimeouts(2)}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			for i := 0; i < 2; i++ {
+				_, err := rt.RoundTrip(req1)
+				Expect(err).To(MatchError(&quic.HandshakeTimeoutError{}))
+			}
+			Expect(states).To(Equal([]bool{true}))
+			_, err := rt.RoundTrip(req1)
+			var uerr *OriginUnavailableError
+			Expect(errors.As(err, &uerr)).To(BeTrue())
+			Expect(uerr.Origin).To(Equal(hostname))
+			Expect(uerr.RetryAfter).To(BeTemporally("~", time.Now().Add(defaultBreakDuration), time.Second))
+			Expect(uerr.Err).To(MatchError(&quic.HandshakeTimeoutError{}))
+			Expect(cl.requests).To(HaveLen(2))
+		})
+
+		It("uses the fallback for broken origins", func() {
+			var fallbackReqs []*http.Request
+			rt.CircuitBreaker = &CircuitBreaker{
+				FailureThreshold: 1,
+				Fallback: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					fallbackReqs = append(fallbackReqs, req)
+					return &http.Response{StatusCode: http.StatusTeapot, Request: req}, nil
+				}),
+			}
+			cl := &mockClient{errs: handshakeTimeouts(1)}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(&quic.HandshakeTimeoutError{}))
+			rsp, err := rt.RoundTrip(req1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.StatusCode).To(Equal(http.StatusTeapot))
+			Expect(fallbackReqs).To(Equal([]*http.Request{req1}))
+			Expect(cl.requests).To(HaveLen(1))
+		})
+
+		It("doesn't count other errors", func() {
+			rt.CircuitBreaker = &CircuitBreaker{FailureThreshold: 1}
+			cl := &mockClient{errs: []error{errors.New("test error"), context.Canceled}}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			for i := 0; i < 3; i++ {
+				rt.RoundTrip(req1)
+			}
+			Expect(cl.requests).To(HaveLen(3))
+		})
+	})
+
+	Context("retrying requests", func() {
+		const hostname = "www.example.org:443"
+
+		unprocessed := func(n int) []error {
+			errs := make([]error, n)
+			for i := range errs {
+				errs[i] = &unprocessedRequestError{err: errGoAway}
+			}
+			return errs
+		}
+
+		It("retries requests that weren't processed by the server", func() {
+			cl := &mockClient{errs: unprocessed(2)}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			rsp, err := rt.RoundTrip(req1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Request).To(Equal(req1))
+			Expect(cl.requests).To(HaveLen(3))
+		})
+
+		It("gives up after too many retries", func() {
+			cl := &mockClient{errs: unprocessed(maxRequestRetries + 1)}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(Equal(errGoAway))
+			Expect(cl.requests).To(HaveLen(maxRequestRetries + 1))
+		})
+
+		It("doesn't retry other errors", func() {
+			testErr := errors.New("test error")
+			cl := &mockClient{errs: []error{testErr}}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(testErr))
+			Expect(cl.requests).To(HaveLen(1))
+		})
+
+		It("rewinds the request body", func() {
+			cl := &mockClient{errs: unprocessed(1)}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			req, err := http.NewRequest(http.MethodPost, "https://www.example.org/upload", bytes.NewReader([]byte("foobar")))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.ReadAll(req.Body) // the first attempt consumed the body
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cl.requests).To(HaveLen(2))
+			body, err := io.ReadAll(cl.requests[1].Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("foobar"))
+		})
+
+		It("doesn't retry if the request body can't be rewound", func() {
+			cl := &mockClient{errs: unprocessed(1)}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			req1.Body = &mockBody{}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(Equal(errGoAway))
+			Expect(cl.requests).To(HaveLen(1))
+		})
+
+		It("retries requests that failed because the connection was lost", func() {
+			cl := &mockClient{errs: []error{&connLostError{err: &quic.IdleTimeoutError{}}}}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			req, err := http.NewRequest(http.MethodPost, "https://www.example.org/upload", bytes.NewReader([]byte("foobar")))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cl.requests).To(HaveLen(2))
+			body, err := io.ReadAll(cl.requests[1].Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("foobar"))
+		})
+
+		It("doesn't retry requests that failed because the connection was lost, if the body can't be rewound", func() {
+			cl := &mockClient{errs: []error{&connLostError{err: &quic.IdleTimeoutError{}}}}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			req1.Body = &mockBody{}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(&quic.IdleTimeoutError{}))
+			Expect(cl.requests).To(HaveLen(1))
+		})
+
+		It("doesn't use clients that received a GOAWAY frame", func() {
+			c, err := newClient(hostname, nil, &roundTripperOpts{}, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.handleGoAway(0)).To(Succeed())
+			c.requestStarted() // the GOAWAY'd client is still in use
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(c)}
+			cl, err := rt.getClient(context.Background(), connKey{hostname: hostname}, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cl.poolableClient).ToNot(BeIdenticalTo(c))
+			Expect(rt.clients[connKey{hostname: hostname}].clients).To(HaveLen(2))
+		})
+	})
+
+	Context("connection pool", func() {
+		const hostname = "www.example.org:443"
+
+		getClients := func() []poolableClient {
+			rt.mutex.Lock()
+			defer rt.mutex.Unlock()
+			pool, ok := rt.clients[connKey{hostname: hostname}]
+			if !ok {
+				return nil
+			}
+			var clients []poolableClient
+			for _, c := range pool.clients {
+				clients = append(clients, c.poolableClient)
+			}
+			return clients
+		}
+
+		isClosed := func(cl *mockClient) func() bool {
+			return func() bool {
+				rt.mutex.Lock()
+				defer rt.mutex.Unlock()
+				return cl.closed
+			}
+		}
+
+		It("reuses a connection while it's below MaxConcurrentRequestsPerConn", func() {
+			rt.MaxConcurrentRequestsPerConn = 2
+			cl := &mockClient{active: 1}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			c, err := rt.getClient(context.Background(), connKey{hostname: hostname}, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.poolableClient).To(Equal(cl))
+			// The request handed to the client is counted, even if the client didn't start it yet.
+			c2, err := rt.getClient(context.Background(), connKey{hostname: hostname}, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c2.poolableClient).ToNot(Equal(cl))
+			Expect(getClients()).To(HaveLen(2))
+		})
+
+		It("dials a new connection when MaxConcurrentRequestsPerConn is reached", func() {
+			rt.MaxConcurrentRequestsPerConn = 2
+			cl := &mockClient{active: 2}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			_, err := rt.getClient(context.Background(), connKey{hostname: hostname}, true)
+			Expect(err).To(MatchError(ErrNoCachedConn))
+			c, err := rt.getClient(context.Background(), connKey{hostname: hostname}, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.poolableClient).ToNot(Equal(cl))
+			Expect(getClients()).To(HaveLen(2))
+		})
+
+		It("queues requests when MaxConnsPerHost is reached", func() {
+			rt.MaxConcurrentRequestsPerConn = 1
+			rt.MaxConnsPerHost = 1
+			cl := &mockClient{active: 1}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			_, err := rt.getClient(context.Background(), connKey{hostname: hostname}, true)
+			Expect(err).To(MatchError(ErrNoCachedConn))
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				c, err := rt.getClient(context.Background(), connKey{hostname: hostname}, false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(c.poolableClient).To(Equal(cl))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			rt.mutex.Lock()
+			cl.active = 0
+			rt.mutex.Unlock()
+			rt.handleRequestDone(connKey{hostname: hostname})
+			Eventually(done).Should(BeClosed())
+			Expect(getClients()).To(HaveLen(1))
+		})
+
+		It("stops waiting for a connection when the context is canceled", func() {
+			rt.MaxConcurrentRequestsPerConn = 1
+			rt.MaxConnsPerHost = 1
+			cl := &mockClient{active: 1}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			ctx, cancel := context.WithCancel(context.Background())
+			errChan := make(chan error, 1)
+			go func() {
+				_, err := rt.getClient(ctx, connKey{hostname: hostname}, false)
+				errChan <- err
+			}()
+			Consistently(errChan).ShouldNot(Receive())
+			cancel()
+			Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+			Expect(getClients()).To(HaveLen(1))
+		})
+
+		It("dials a new connection when a queued request finds that a connection was closed", func() {
+			rt.MaxConcurrentRequestsPerConn = 1
+			rt.MaxConnsPerHost = 1
+			cl := &mockClient{goingAway: true, active: 1}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				c, err := rt.getClient(context.Background(), connKey{hostname: hostname}, false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(c.poolableClient).ToNot(Equal(cl))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			rt.mutex.Lock()
+			cl.active = 0
+			rt.mutex.Unlock()
+			rt.handleIdleClient(connKey{hostname: hostname})
+			Eventually(done).Should(BeClosed())
+			Eventually(isClosed(cl)).Should(BeTrue())
+			Expect(getClients()).To(HaveLen(1))
+		})
+
+		It("closes connections that can't take new requests once they become idle", func() {
+			cl := &mockClient{goingAway: true, active: 1}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl)}
+			c, err := rt.getClient(context.Background(), connKey{hostname: hostname}, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cl.closed).To(BeFalse())
+			cl.active = 0
+			rt.releaseClient(connKey{hostname: hostname}, c)
+			Expect(cl.closed).To(BeTrue())
+			Expect(getClients()).To(Equal([]poolableClient{c.poolableClient}))
+		})
+
+		It("limits the number of idle connections", func() {
+			rt.MaxIdleConnsPerHost = 1
+			cl1 := &mockClient{idleSince: time.Now().Add(-2 * time.Second)}
+			cl2 := &mockClient{idleSince: time.Now().Add(-time.Second)}
+			cl3 := &mockClient{active: 1}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl1, cl2, cl3)}
+			rt.handleIdleClient(connKey{hostname: hostname})
+			Expect(cl1.closed).To(BeTrue())
+			Expect(cl2.closed).To(BeFalse())
+			Expect(cl3.closed).To(BeFalse())
+			Expect(getClients()).To(Equal([]poolableClient{cl2, cl3}))
+		})
+
+		It("closes connections after the IdleConnTimeout", func() {
+			rt.IdleConnTimeout = scaleDuration(50 * time.Millisecond)
+			cl1 := &mockClient{idleSince: time.Now()}
+			cl2 := &mockClient{idleSince: time.Now().Add(-time.Hour)}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl1, cl2)}
+			rt.handleIdleClient(connKey{hostname: hostname})
+			Expect(cl2.closed).To(BeTrue())
+			Expect(isClosed(cl1)()).To(BeFalse())
+			Eventually(isClosed(cl1)).Should(BeTrue())
+			Expect(getClients()).To(BeEmpty())
+		})
+
+		It("closes idle connections", func() {
+			cl1 := &mockClient{}
+			cl2 := &mockClient{active: 1}
+			rt.clients = map[connKey]*connPool{{hostname: hostname}: newConnPool(cl1, cl2)}
+			rt.CloseIdleConnections()
+			Expect(cl1.closed).To(BeTrue())
+			Expect(cl2.closed).To(BeFalse())
+			Expect(getClients()).To(Equal([]poolableClient{cl2}))
+			cl2.active = 0
+			rt.CloseIdleConnections()
+			Expect(cl2.closed).To(BeTrue())
+			Expect(rt.clients).To(BeEmpty())
+		})
+	})
+
+	Context("proxies", func() {
+		It("uses separate connections for every proxy", func() {
+			rt.Proxy = func(req *http.Request) (*url.URL, error) {
+				if req.URL.Path == "/direct" {
+					return nil, nil
+				}
+				return url.Parse("https://proxy.example.org" + req.URL.Path)
+			}
+			key := func(path string) connKey {
+				req, err := http.NewRequest(http.MethodGet, "https://www.example.org"+path, nil)
+				Expect(err).ToNot(HaveOccurred())
+				key, err := rt.connKeyForRequest(req)
+				Expect(err).ToNot(HaveOccurred())
+				return key
+			}
+			Expect(key("/direct")).To(Equal(connKey{hostname: "www.example.org:443"}))
+			Expect(key("/foo")).To(Equal(connKey{hostname: "www.example.org:443", proxy: "https://proxy.example.org/foo"}))
+			Expect(key("/bar")).To(Equal(connKey{hostname: "www.example.org:443", proxy: "https://proxy.example.org/bar"}))
+		})
+
+		It("rejects proxies that don't use https", func() {
+			rt.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: "proxy.example.org"})
+			body := &mockBody{}
+			req, err := http.NewRequest(http.MethodPost, "https://www.example.org", body)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).To(MatchError("http3: unsupported proxy scheme: http"))
+			Expect(body.closed).To(BeTrue())
+		})
+
+		It("aborts the request if the Proxy function fails", func() {
+			testErr := errors.New("no proxy for you")
+			rt.Proxy = func(*http.Request) (*url.URL, error) { return nil, testErr }
+			req, err := http.NewRequest(http.MethodGet, "https://www.example.org", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).To(MatchError(testErr))
+			Expect(rt.clients).To(BeEmpty())
+		})
+	})
+
 	Context("closing", func() {
 		It("closes", func() {
-			rt.clients = make(map[string]roundTripCloser)
 			cl := &mockClient{}
-			rt.clients["foo.bar"] = cl
+			rt.clients = map[connKey]*connPool{{hostname: "foo.bar"}: newConnPool(cl)}
 			err := rt.Close()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(rt.clients)).To(BeZero())
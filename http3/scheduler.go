@@ -0,0 +1,246 @@
+package http3
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+const (
+	// the maximum number of bytes a response writes before the scheduler lets another response write
+	schedulerChunkSize = 16 << 10
+	// The maximum time a response waits for its turn.
+	// If the response currently writing is blocked (e.g. by flow control), the waiting response writes anyway.
+	maxSchedulerWait = 50 * time.Millisecond
+	// the maximum number of PRIORITY_UPDATE frames buffered for requests that were not received yet
+	maxBufferedPriorityUpdates = 100
+)
+
+type schedulerWaiter struct {
+	id      quic.StreamID
+	ready   chan struct{}
+	granted bool
+}
+
+// priorityScheduler schedules the responses sent on a connection according to their priorities,
+// as described in RFC 9218, Section 10.
+// Responses are sent in chunks. Only one response writes at a time, and the next chunk is written by
+// the waiting response with the lowest urgency. Of responses with the same urgency, non-incremental
+// responses are sent one after the other in the order of their stream IDs, before incremental responses,
+// which are sent in a round-robin fashion.
+type priorityScheduler struct {
+	mutex sync.Mutex
+
+	priorities map[quic.StreamID]Priority
+	active     map[quic.StreamID]struct{}
+	// the highest stream ID of a request that was registered
+	highestRegistered quic.StreamID
+	numBuffered       int
+
+	writing int // the number of responses currently writing
+	waiting []*schedulerWaiter
+	maxWait time.Duration
+}
+
+func newPriorityScheduler() *priorityScheduler {
+	return &priorityScheduler{
+		priorities:        make(map[quic.StreamID]Priority),
+		active:            make(map[quic.StreamID]struct{}),
+		highestRegistered: -1,
+		maxWait:           maxSchedulerWait,
+	}
+}
+
+// register registers a request, using the priority sent in the Priority header field.
+// If a PRIORITY_UPDATE frame was received for this request before, the updated priority is used.
+func (s *priorityScheduler) register(id quic.StreamID, p Priority) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.active[id] = struct{}{}
+	if id > s.highestRegistered {
+		s.highestRegistered = id
+	}
+	if _, ok := s.priorities[id]; ok {
+		s.numBuffered--
+		return
+	}
+	s.priorities[id] = p
+}
+
+// remove is called when the response to a request was sent.
+func (s *priorityScheduler) remove(id quic.StreamID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.active, id)
+	delete(s.priorities, id)
+}
+
+// updatePriority updates the priority of a request, when a PRIORITY_UPDATE frame is received.
+func (s *priorityScheduler) updatePriority(id quic.StreamID, p Priority) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.active[id]; ok {
+		s.priorities[id] = p
+		return
+	}
+	// the response was already sent
+	if id <= s.highestRegistered {
+		return
+	}
+	// the request was not received yet
+	if _, ok := s.priorities[id]; !ok {
+		if s.numBuffered >= maxBufferedPriorityUpdates {
+			return
+		}
+		s.numBuffered++
+	}
+	s.priorities[id] = p
+}
+
+// getPriority returns the current priority of a request.
+func (s *priorityScheduler) getPriority(id quic.StreamID) Priority {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.priority(id)
+}
+
+func (s *priorityScheduler) priority(id quic.StreamID) Priority {
+	if p, ok := s.priorities[id]; ok {
+		return p
+	}
+	return Priority{Urgency: DefaultUrgency}
+}
+
+// before says if the response on stream a should be sent before the response on stream b.
+// For two incremental responses of the same urgency, it returns false.
+func (s *priorityScheduler) before(a, b quic.StreamID) bool {
+	pa, pb := s.priority(a), s.priority(b)
+	if pa.Urgency != pb.Urgency {
+		return pa.Urgency < pb.Urgency
+	}
+	if pa.Incremental != pb.Incremental {
+		return !pa.Incremental
+	}
+	if !pa.Incremental {
+		return a < b
+	}
+	return false
+}
+
+// acquire blocks until the response on stream id may write.
+func (s *priorityScheduler) acquire(id quic.StreamID) {
+	s.mutex.Lock()
+	if s.writing == 0 {
+		s.writing++
+		s.mutex.Unlock()
+		return
+	}
+	w := &schedulerWaiter{id: id, ready: make(chan struct{})}
+	s.waiting = append(s.waiting, w)
+	s.mutex.Unlock()
+
+	timer := time.NewTimer(s.maxWait)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+	case <-timer.C:
+		s.mutex.Lock()
+		if !w.granted {
+			for i, sw := range s.waiting {
+				if sw == w {
+					s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+					break
+				}
+			}
+			s.writing++
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// release is called when a response is done writing.
+func (s *priorityScheduler) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.writing--
+	if s.writing > 0 || len(s.waiting) == 0 {
+		return
+	}
+	next := 0
+	for i := 1; i < len(s.waiting); i++ {
+		if s.before(s.waiting[i].id, s.waiting[next].id) {
+			next = i
+		}
+	}
+	w := s.waiting[next]
+	s.waiting = append(s.waiting[:next], s.waiting[next+1:]...)
+	w.granted = true
+	s.writing++
+	close(w.ready)
+}
+
+// yield lets a waiting response write, if it should be sent before the response on stream id.
+func (s *priorityScheduler) yield(id quic.StreamID) {
+	s.mutex.Lock()
+	var yield bool
+	for _, w := range s.waiting {
+		if !s.before(id, w.id) {
+			yield = true
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	if yield {
+		s.release()
+		s.acquire(id)
+	}
+}
+
+// newWriter returns an io.Writer that writes to the request stream when the scheduler allows it.
+// The urgency of the response is also applied to the QUIC stream,
+// so that the response's data is packed into packets according to its priority.
+func (s *priorityScheduler) newWriter(str quic.Stream) io.Writer {
+	return &scheduledWriter{str: str, id: str.StreamID(), scheduler: s, urgency: DefaultUrgency}
+}
+
+type scheduledWriter struct {
+	str       quic.Stream
+	id        quic.StreamID
+	scheduler *priorityScheduler
+	urgency   uint8 // the urgency set on the stream
+}
+
+func (w *scheduledWriter) Write(b []byte) (int, error) {
+	if u := w.scheduler.getPriority(w.id).Urgency; u != w.urgency {
+		w.str.SetPriority(quic.StreamPriority{Urgency: u})
+		w.urgency = u
+	}
+	w.scheduler.acquire(w.id)
+	defer w.scheduler.release()
+
+	var n int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > schedulerChunkSize {
+			chunk = chunk[:schedulerChunkSize]
+		}
+		m, err := w.str.Write(chunk)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		b = b[m:]
+		if len(b) > 0 {
+			w.scheduler.yield(w.id)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,188 @@
+package http3
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Priority Scheduler", func() {
+	var s *priorityScheduler
+
+	BeforeEach(func() {
+		s = newPriorityScheduler()
+		// make sure that waiting responses only write when it's their turn
+		s.maxWait = time.Hour
+	})
+
+	numWaiting := func() int {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return len(s.waiting)
+	}
+
+	// acquireAll lets the responses on the streams wait for their turn,
+	// and returns a channel that receives the stream IDs in the order that they are allowed to write.
+	acquireAll := func(ids ...quic.StreamID) <-chan quic.StreamID {
+		order := make(chan quic.StreamID, len(ids))
+		for i, id := range ids {
+			go func(id quic.StreamID) {
+				s.acquire(id)
+				order <- id
+			}(id)
+			Eventually(numWaiting).Should(Equal(i + 1))
+		}
+		return order
+	}
+
+	It("lets a single response write immediately", func() {
+		s.register(0, Priority{Urgency: DefaultUrgency})
+		s.acquire(0)
+		s.release()
+		s.acquire(0)
+	})
+
+	It("schedules responses by urgency", func() {
+		s.register(4, Priority{Urgency: 5})
+		s.register(8, Priority{Urgency: 1})
+		s.register(12, Priority{Urgency: 3})
+		s.acquire(0)
+		order := acquireAll(4, 12, 8)
+		var ids []quic.StreamID
+		for i := 0; i < 3; i++ {
+			s.release()
+			var id quic.StreamID
+			Eventually(order).Should(Receive(&id))
+			ids = append(ids, id)
+		}
+		Expect(ids).To(Equal([]quic.StreamID{8, 12, 4}))
+	})
+
+	It("schedules non-incremental responses before incremental responses, in the order of their stream IDs", func() {
+		s.register(4, Priority{Urgency: 1, Incremental: true})
+		s.register(8, Priority{Urgency: 1})
+		s.register(12, Priority{Urgency: 1})
+		s.acquire(0)
+		order := acquireAll(4, 12, 8)
+		var ids []quic.StreamID
+		for i := 0; i < 3; i++ {
+			s.release()
+			var id quic.StreamID
+			Eventually(order).Should(Receive(&id))
+			ids = append(ids, id)
+		}
+		Expect(ids).To(Equal([]quic.StreamID{8, 12, 4}))
+	})
+
+	It("yields to incremental responses of the same urgency", func() {
+		s.register(4, Priority{Urgency: 1, Incremental: true})
+		s.register(8, Priority{Urgency: 1, Incremental: true})
+		s.acquire(4)
+		order := acquireAll(8)
+		yielded := make(chan struct{})
+		go func() {
+			defer close(yielded)
+			s.yield(4)
+		}()
+		Eventually(order).Should(Receive(Equal(quic.StreamID(8))))
+		s.release()
+		Eventually(yielded).Should(BeClosed())
+	})
+
+	It("doesn't yield to responses that should be sent later", func() {
+		s.register(4, Priority{Urgency: 1})
+		s.register(8, Priority{Urgency: 1})
+		s.acquire(4)
+		order := acquireAll(8)
+		s.yield(4)
+		Expect(numWaiting()).To(Equal(1))
+		s.release()
+		Eventually(order).Should(Receive(Equal(quic.StreamID(8))))
+	})
+
+	It("lets responses write if the response that is currently writing is blocked", func() {
+		s.maxWait = maxSchedulerWait
+		s.acquire(4)
+		start := time.Now()
+		s.acquire(8)
+		Expect(time.Since(start)).To(BeNumerically("~", maxSchedulerWait, scaleDuration(20*time.Millisecond)))
+		Expect(numWaiting()).To(BeZero())
+	})
+
+	It("uses priorities sent in PRIORITY_UPDATE frames", func() {
+		s.register(4, Priority{Urgency: 1})
+		s.updatePriority(4, Priority{Urgency: 6})
+		s.updatePriority(8, Priority{Urgency: 0}) // received before the request
+		s.register(8, Priority{Urgency: 5})
+		s.acquire(0)
+		order := acquireAll(4, 8)
+		s.release()
+		Eventually(order).Should(Receive(Equal(quic.StreamID(8))))
+		s.release()
+		Eventually(order).Should(Receive(Equal(quic.StreamID(4))))
+	})
+
+	It("returns the current priority", func() {
+		Expect(s.getPriority(4)).To(Equal(Priority{Urgency: DefaultUrgency}))
+		s.register(4, Priority{Urgency: 1})
+		Expect(s.getPriority(4)).To(Equal(Priority{Urgency: 1}))
+		s.updatePriority(4, Priority{Urgency: 6, Incremental: true})
+		Expect(s.getPriority(4)).To(Equal(Priority{Urgency: 6, Incremental: true}))
+	})
+
+	It("ignores PRIORITY_UPDATE frames for responses that were already sent", func() {
+		s.register(4, Priority{Urgency: 1})
+		s.remove(4)
+		s.updatePriority(4, Priority{Urgency: 6})
+		Expect(s.priorities).To(BeEmpty())
+	})
+
+	It("limits the number of buffered PRIORITY_UPDATE frames", func() {
+		for i := 0; i < maxBufferedPriorityUpdates+10; i++ {
+			s.updatePriority(quic.StreamID(4*i), Priority{Urgency: 1})
+		}
+		Expect(s.priorities).To(HaveLen(maxBufferedPriorityUpdates))
+	})
+
+	It("writes in chunks", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+		buf := &bytes.Buffer{}
+		var writes []int
+		str.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			writes = append(writes, len(b))
+			return buf.Write(b)
+		}).Times(3)
+		data := bytes.Repeat([]byte("a"), 2*schedulerChunkSize+10)
+		n, err := s.newWriter(str).Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(len(data)))
+		Expect(buf.Bytes()).To(Equal(data))
+		Expect(writes).To(Equal([]int{schedulerChunkSize, schedulerChunkSize, 10}))
+	})
+
+	It("sets the urgency on the QUIC stream", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+		str.EXPECT().Write(gomock.Any()).Return(3, nil).Times(3)
+		s.register(4, Priority{Urgency: 1, Incremental: true})
+		w := s.newWriter(str)
+		str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 1})
+		_, err := w.Write([]byte("foo"))
+		Expect(err).ToNot(HaveOccurred())
+		// the priority is only set when it changes
+		_, err = w.Write([]byte("bar"))
+		Expect(err).ToNot(HaveOccurred())
+		s.updatePriority(4, Priority{Urgency: 5})
+		str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 5})
+		_, err = w.Write([]byte("baz"))
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
@@ -1,6 +1,7 @@
 package http3
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -18,8 +19,9 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
 	"github.com/lucas-clemente/quic-go/quicvarint"
-	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http/httpguts"
 )
 
 // allows mocking of quic.Listen and quic.ListenAddr
@@ -33,6 +35,9 @@ const (
 	nextProtoH3        = "h3"
 )
 
+// defaultServerAltSvcMaxAge is the default value of Server.AltSvcMaxAge.
+const defaultServerAltSvcMaxAge = 30 * 24 * time.Hour
+
 const (
 	streamTypeControlStream      = 0
 	streamTypePushStream         = 1
@@ -40,6 +45,9 @@ const (
 	streamTypeQPACKDecoderStream = 3
 )
 
+// StreamType is the stream type of a unidirectional stream.
+type StreamType uint64
+
 func versionToALPN(v protocol.VersionNumber) string {
 	if v == protocol.Version1 {
 		return nextProtoH3
@@ -102,6 +110,18 @@ func (k *contextKey) String() string { return "quic-go/http3 context value " + k
 // type *http3.Server.
 var ServerContextKey = &contextKey{"http3-server"}
 
+// ConnectionContextKey is a context key. It can be used in HTTP
+// handlers with Context.Value to access the QUIC connection
+// that the request was received on. The associated value will be of
+// type quic.Connection.
+var ConnectionContextKey = &contextKey{"http3-connection"}
+
+// StreamIDContextKey is a context key. It can be used in HTTP
+// handlers with Context.Value to access the ID of the request stream.
+// The associated value will be of type quic.StreamID.
+// It is not set for pushed requests.
+var StreamIDContextKey = &contextKey{"http3-stream-id"}
+
 type requestError struct {
 	err       error
 	streamErr errorCode
@@ -116,6 +136,241 @@ func newConnError(code errorCode, err error) requestError {
 	return requestError{err: err, connErr: code}
 }
 
+// serverConn is a QUIC connection accepted by the server,
+// together with the HTTP/3 state associated with it.
+type serverConn struct {
+	quic.EarlyConnection
+
+	push         *pushState
+	scheduler    *priorityScheduler
+	datagrams    *datagramDemuxer     // nil if HTTP datagrams are disabled
+	webTransport *webTransportManager // nil if WebTransport is disabled
+
+	settingsOnce     sync.Once
+	receivedSettings chan struct{} // closed once the client's SETTINGS frame was received
+	settings         *settingsFrame
+
+	strict     *strictMode
+	decoder    *qpackDecoder
+	uniStreams openedUniStreams // the unique streams of registered types opened by the client
+
+	keepAlive *keepAlive // nil if keep-alives are disabled
+
+	// used for graceful shutdown
+	mutex          sync.Mutex
+	controlStr     quic.SendStream
+	nextStreamID   quic.StreamID // the stream ID following the highest request stream accepted so far
+	goAwaySent     bool
+	goAwayID       quic.StreamID
+	activeRequests int
+	maxRequests    int  // the maximum number of active requests, 0 if unlimited
+	wasDrained     bool // protected by the server's mutex
+
+	ctx       context.Context      // nil if the Server doesn't set a ConnContext
+	connState func(http.ConnState) // nil if the Server doesn't set a ConnState callback
+	closed    bool                 // set once http.StateClosed was reported
+
+	// used to close idle connections
+	idleTimeout        time.Duration
+	idleTimer          *time.Timer // nil if idle connections are not closed
+	hasHijackedStreams bool
+
+	earlyDataStreamID quic.StreamID // request streams with a lower ID were received in 0-RTT data
+}
+
+func newServerConn(conn quic.EarlyConnection) *serverConn {
+	return &serverConn{
+		EarlyConnection:  conn,
+		push:             newPushState(),
+		scheduler:        newPriorityScheduler(),
+		receivedSettings: make(chan struct{}),
+		strict:           newStrictMode(false, nil),
+		decoder:          newQPACKDecoder(func() (quic.SendStream, error) { return conn.OpenUniStream() }),
+	}
+}
+
+// ReceivedSettings returns a channel that is closed once the client's SETTINGS frame was received.
+func (c *serverConn) ReceivedSettings() <-chan struct{} {
+	return c.receivedSettings
+}
+
+// Settings returns the settings received from the client.
+func (c *serverConn) Settings() *Settings {
+	return newSettings(c.settings)
+}
+
+// acceptRequest is called when a request stream is accepted.
+// It returns false if the request needs to be rejected, since it was sent after the GOAWAY frame,
+// or since the maximum number of active requests was reached.
+func (c *serverConn) acceptRequest(id quic.StreamID) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.goAwaySent && id >= c.goAwayID {
+		return false
+	}
+	if c.maxRequests > 0 && c.activeRequests >= c.maxRequests {
+		return false
+	}
+	if id >= c.nextStreamID {
+		c.nextStreamID = id + 4
+	}
+	c.activeRequests++
+	c.keepAlive.requestStarted()
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+	if c.activeRequests == 1 && !c.hasHijackedStreams {
+		c.setState(http.StateActive)
+	}
+	return true
+}
+
+// receivedEarlyData is called when a request stream is accepted before the handshake completed,
+// i.e. when the request was received in 0-RTT data.
+// Since streams are accepted in order, all request streams with a lower ID were received in 0-RTT data as well.
+func (c *serverConn) receivedEarlyData(id quic.StreamID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if id >= c.earlyDataStreamID {
+		c.earlyDataStreamID = id + 4
+	}
+}
+
+// isEarlyData says if the request on stream id was received in 0-RTT data.
+func (c *serverConn) isEarlyData(id quic.StreamID) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return id < c.earlyDataStreamID
+}
+
+func (c *serverConn) requestDone() {
+	c.mutex.Lock()
+	c.activeRequests--
+	c.keepAlive.requestDone()
+	if c.activeRequests == 0 && !c.hasHijackedStreams {
+		if c.idleTimer != nil {
+			c.idleTimer.Reset(c.idleTimeout)
+		}
+		c.setState(http.StateIdle)
+	}
+	c.mutex.Unlock()
+}
+
+// setState reports a state change to the ConnState callback.
+// It must be called with the mutex held.
+func (c *serverConn) setState(state http.ConnState) {
+	if c.connState == nil || c.closed {
+		return
+	}
+	if state == http.StateClosed {
+		c.closed = true
+	}
+	c.connState(state)
+}
+
+// reportClosed reports that the connection was closed.
+// Requests that are still being processed don't cause any further state changes.
+func (c *serverConn) reportClosed() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.setState(http.StateClosed)
+}
+
+// streamHijacked is called when a request stream was taken over, e.g. for a WebTransport session.
+// Such connections are never closed for being idle.
+func (c *serverConn) streamHijacked() {
+	c.mutex.Lock()
+	c.hasHijackedStreams = true
+	c.mutex.Unlock()
+}
+
+// startIdleTimer closes the connection when no requests are active for the duration of the timeout.
+func (c *serverConn) startIdleTimer(timeout time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.idleTimeout = timeout
+	c.idleTimer = time.AfterFunc(timeout, c.closeIfIdle)
+}
+
+func (c *serverConn) closeIfIdle() {
+	c.mutex.Lock()
+	idle := c.activeRequests == 0 && !c.hasHijackedStreams
+	c.mutex.Unlock()
+	if idle {
+		c.CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+	}
+}
+
+func (c *serverConn) stopIdleTimer() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+}
+
+// sendKeepAlive sends a keep-alive on the control stream.
+func (c *serverConn) sendKeepAlive() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	buf := &bytes.Buffer{}
+	appendKeepAliveFrame(buf)
+	_, err := c.controlStr.Write(buf.Bytes())
+	return err
+}
+
+// goAway sends a GOAWAY frame (RFC 9114, Section 5.2).
+// Requests sent on streams that weren't accepted before are rejected.
+func (c *serverConn) goAway() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.goAwaySent {
+		return nil
+	}
+	c.goAwaySent = true
+	c.goAwayID = c.nextStreamID
+	buf := &bytes.Buffer{}
+	(&goAwayFrame{StreamID: uint64(c.goAwayID)}).Write(buf)
+	_, err := c.controlStr.Write(buf.Bytes())
+	return err
+}
+
+// isDrained says if the GOAWAY frame was sent, and all requests accepted before have completed.
+func (c *serverConn) isDrained() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.goAwaySent && c.activeRequests == 0
+}
+
+var errHandlerPanicked = errors.New("http3: handler panicked")
+
+// A RequestLog describes a request handled by the Server.
+// It is passed to the LogRequest callback.
+type RequestLog struct {
+	Method     string
+	Host       string
+	RequestURI string
+	// Status is the status code of the response.
+	Status int
+	// BytesWritten is the size of the response body.
+	BytesWritten int64
+	// Duration is the time it took to handle the request,
+	// starting when the request stream was accepted.
+	// If the handler took over the stream, this is the time until the handler returned.
+	Duration time.Duration
+	// StreamID is the ID of the request stream.
+	StreamID quic.StreamID
+	// Connection is the QUIC connection that the request was received on.
+	Connection quic.Connection
+	// Err is set if the handler panicked, or if sending the response failed.
+	Err error
+}
+
 // listenerInfo contains info about specific listener added with addListener
 type listenerInfo struct {
 	port int // 0 means that no info about port is available
@@ -134,16 +389,117 @@ type Server struct {
 	// See https://datatracker.ietf.org/doc/html/draft-ietf-masque-h3-datagram-07.
 	EnableDatagrams bool
 
+	// Enable support for WebTransport (draft-ietf-webtrans-http3-02).
+	// This implies EnableDatagrams.
+	// Handlers establish WebTransport sessions using UpgradeWebTransport.
+	EnableWebTransport bool
+
 	// The port to use in Alt-Svc response headers.
 	// If needed Port can be manually set when the Server is created.
 	// This is useful when a Layer 4 firewall is redirecting UDP traffic and clients must use
 	// a port different from the port the Server is listening on.
 	Port int
 
+	// AdditionalAddrs are UDP addresses that ListenAndServe and ListenAndServeTLS listen on, in addition to Addr.
+	// All listeners share the handler, the settings and the connections of the server,
+	// and are closed when the server is closed or shut down.
+	// The Alt-Svc header announces the ports of all listeners, unless Port is set.
+	AdditionalAddrs []string
+
+	// AltSvcAuthorities, if set, are announced in the Alt-Svc header instead of the ports the server is listening on.
+	// An authority is either a port (":443") or a host and a port ("alt.example.org:443").
+	// They are announced even if the server is not listening on any address,
+	// e.g. when SetQuicHeaders is used by a server that only advertises another HTTP/3 server.
+	AltSvcAuthorities []string
+
+	// AltSvcMaxAge is the value of the ma parameter of the Alt-Svc header,
+	// i.e. the time that clients may use the alternative service for.
+	// If zero, 30 days are announced.
+	AltSvcMaxAge time.Duration
+
+	// AltSvcVersions, if set, are the QUIC versions announced in the Alt-Svc header,
+	// with one entry for every version and authority.
+	// This allows phasing out the announcement of a version that is still supported.
+	// If nil, the versions of the QuicConfig are announced.
+	AltSvcVersions []quic.VersionNumber
+
+	// DisableExtendedConnect disables Extended CONNECT (RFC 9220).
+	// Unless disabled, the server sends SETTINGS_ENABLE_CONNECT_PROTOCOL, and Extended CONNECT requests
+	// are passed to the handler, with the value of the :protocol pseudo-header field in Request.Proto.
+	// If disabled, Extended CONNECT requests are rejected as malformed.
+	// WebTransport uses Extended CONNECT, so it stays enabled if EnableWebTransport is set.
+	DisableExtendedConnect bool
+
 	// Additional HTTP/3 settings.
 	// It is invalid to specify any settings defined by the HTTP/3 draft and the datagram draft.
+	// SETTINGS_ENABLE_CONNECT_PROTOCOL is ignored, it is controlled by DisableExtendedConnect.
 	AdditionalSettings map[uint64]uint64
 
+	// KeepAlivePeriod, if positive, is the interval at which keep-alives are sent on connections
+	// with outstanding requests, such that handlers that take a long time to respond
+	// don't cause the connection to hit the QUIC idle timeout.
+	// A keep-alive is an empty frame of a type reserved for greasing, sent on the control stream.
+	// It should be lower than the idle timeout of the QUIC connection.
+	KeepAlivePeriod time.Duration
+
+	// KeepAliveIdleGrace is the time that keep-alives continue to be sent after the last
+	// outstanding request on a connection completed.
+	// If zero, keep-alives are only sent while requests are outstanding.
+	KeepAliveIdleGrace time.Duration
+
+	// MaxConcurrentRequests limits the number of requests that a client can send concurrently on a connection,
+	// so that a single client can't occupy an arbitrary number of handler Go routines.
+	// It is enforced using the QUIC stream limit (overriding QuicConfig.MaxIncomingStreams),
+	// such that clients queue additional requests until one of their previous requests completed.
+	// Note that streams of WebTransport sessions count towards the stream limit as well.
+	// Requests exceeding the limit, e.g. when serving a QUIC listener using ServeListener,
+	// are rejected with H3_REQUEST_REJECTED, and can be retried by the client.
+	// If zero, the stream limit of the QuicConfig is used.
+	MaxConcurrentRequests int
+
+	// MaxRequestBodySize limits the size of request bodies, in bytes.
+	// Requests announcing a larger Content-Length are rejected before the handler is called.
+	// Once the handler reads more than MaxRequestBodySize bytes, Read returns an error.
+	// In both cases, the stream is reset with H3_REQUEST_REJECTED.
+	// If zero, the size of request bodies is not limited.
+	MaxRequestBodySize int64
+
+	// MaxHeaderListSize limits the size of request header sections after QPACK decompression,
+	// calculated as defined in RFC 9114, Section 4.2.2.
+	// Unlike MaxHeaderBytes, which limits the size of the compressed header section,
+	// it protects against header sections that expand when they are decompressed.
+	// It is sent to the client in the SETTINGS_MAX_FIELD_SECTION_SIZE setting.
+	// Requests exceeding the limit are answered with status 431 (Request Header Fields Too Large).
+	// If zero, the size of decoded header sections is not limited.
+	MaxHeaderListSize uint64
+
+	// QPACKMaxTableCapacity is the maximum capacity of the QPACK dynamic table (RFC 9204)
+	// that clients can use to compress request headers.
+	// It is sent to the client in the SETTINGS_QPACK_MAX_TABLE_CAPACITY setting.
+	// If zero, clients can't use the dynamic table.
+	QPACKMaxTableCapacity uint64
+
+	// QPACKBlockedStreams is the maximum number of request streams that can be blocked,
+	// waiting for the entries of the QPACK dynamic table referenced by the request headers.
+	// It is sent to the client in the SETTINGS_QPACK_BLOCKED_STREAMS setting.
+	// It only has an effect if QPACKMaxTableCapacity is set.
+	QPACKBlockedStreams uint64
+
+	// WriteBufferSize is the size of the buffer used for writing a response.
+	// HEADERS and DATA frames are sent when the buffer is full, when the handler calls Flush,
+	// and when the handler returns. Larger buffers result in fewer, larger STREAM frames.
+	// If zero, a default size of 4 KB is used.
+	// If negative, responses are not buffered: HEADERS and DATA frames are sent as soon as they are written.
+	// Handlers can change this behavior for a single response using the AutoFlusher interface.
+	WriteBufferSize int
+
+	// When set, this callback is called with the SETTINGS received from the client,
+	// before any other frame on the client's control stream is processed.
+	// It allows negotiating extensions that use AdditionalSettings.
+	// If it returns an error, the connection is closed with an H3_SETTINGS_ERROR.
+	// Handlers can access the client's SETTINGS using the Settingser interface.
+	SettingsHandler func(quic.Connection, *Settings) error
+
 	// When set, this callback is called for the first unknown frame parsed on a bidirectional stream.
 	// It is called right after parsing the frame type.
 	// Callers can either process the frame and return control of the stream back to HTTP/3
@@ -151,26 +507,92 @@ type Server struct {
 	// Alternatively, callers can take over the QUIC stream (by returning hijacked true).
 	StreamHijacker func(FrameType, quic.Connection, quic.Stream) (hijacked bool, err error)
 
+	// When set, this callback is called for unidirectional streams of an unknown stream type.
+	// It is called right after parsing the stream type.
+	// If the callback doesn't take over the stream (by returning hijacked false),
+	// the stream is reset.
+	// Stream types registered using RegisterUniStreamType are not passed to the callback.
+	//
+	// Deprecated: Use RegisterUniStreamType instead.
+	UniStreamHijacker func(StreamType, quic.Connection, quic.ReceiveStream) (hijacked bool)
+
+	// ConnContext optionally specifies a function that modifies the context used for a new connection.
+	// The values of the returned context are available in the contexts of all requests on this connection.
+	// The provided ctx contains the ServerContextKey and the http.LocalAddrContextKey.
+	ConnContext func(ctx context.Context, conn quic.Connection) context.Context
+
+	// ConnState specifies an optional callback function that is called when a connection changes state.
+	// A connection is active while requests are being processed, and idle otherwise.
+	// Connections on which a stream was taken over (e.g. for a WebTransport session) don't become idle.
+	// The callback is called synchronously, and must not block.
+	// http.StateHijacked is never used.
+	ConnState func(quic.Connection, http.ConnState)
+
+	// LogRequest, if set, is called when the server is done processing a request,
+	// i.e. after the handler returned and the response was sent.
+	// It can be used to write access logs or to collect metrics.
+	// It is not called for requests that couldn't be parsed.
+	LogRequest func(*RequestLog)
+
+	// Strict enables strict mode, which enforces additional requirements of RFC 9114 on the client:
+	// only a single control stream and a single stream of each QPACK stream type may be opened,
+	// these streams must not be closed, frame types reserved for HTTP/2 must not be used,
+	// and the request header fields must be well-formed.
+	// Violations are reported to the ProtocolViolationHandler.
+	Strict bool
+
+	// ProtocolViolationHandler, if set, is called for every protocol violation detected in strict mode.
+	ProtocolViolationHandler func(quic.Connection, *ProtocolViolation)
+
+	// Logger is used to log the operation of the server.
+	// If nil, QuicConfig.Logger is used.
+	Logger logging.Logger
+
 	mutex     sync.RWMutex
 	listeners map[*quic.EarlyListener]listenerInfo
+	conns     map[*serverConn]struct{}
 
 	closed utils.AtomicBool
 
 	altSvcHeader string
 
+	uniStreamHandlers uniStreamHandlers
+	frameHandlers     frameHandlers
+
 	loggerOnce sync.Once
 	logger     utils.Logger
 }
 
+// RegisterUniStreamType registers a handler for the unidirectional streams of type t opened by clients.
+// It must be called before the server starts serving.
+// It returns an error if t is used by HTTP/3, QPACK or WebTransport, if it is reserved for greasing,
+// or if a handler was already registered for t.
+// Streams of unregistered types are passed to the UniStreamHijacker, if set, and are reset otherwise.
+func (s *Server) RegisterUniStreamType(t StreamType, h UniStreamHandler) error {
+	return s.uniStreamHandlers.register(t, h)
+}
+
+// RegisterFrameType registers a handler for the frames of type t sent by clients
+// on the control stream and on request streams.
+// It must be called before the server starts serving.
+// It returns an error if t is used by HTTP/3 or WebTransport, if it is reserved for HTTP/2 frame types or for greasing,
+// or if a handler was already registered for t.
+// Frames of unregistered types are skipped.
+func (s *Server) RegisterFrameType(t FrameType, h FrameHandler) error {
+	return s.frameHandlers.register(t, h)
+}
+
 // ListenAndServe listens on the UDP address s.Addr and calls s.Handler to handle HTTP/3 requests on incoming connections.
+// If s.AdditionalAddrs is set, it also listens on these addresses.
 func (s *Server) ListenAndServe() error {
 	if s.Server == nil {
 		return errors.New("use of http3.Server without http.Server")
 	}
-	return s.serveConn(s.TLSConfig, nil)
+	return s.listenAndServe(s.TLSConfig)
 }
 
 // ListenAndServeTLS listens on the UDP address s.Addr and calls s.Handler to handle HTTP/3 requests on incoming connections.
+// If s.AdditionalAddrs is set, it also listens on these addresses.
 func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	var err error
 	certs := make([]tls.Certificate, 1)
@@ -183,52 +605,96 @@ func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	config := &tls.Config{
 		Certificates: certs,
 	}
-	return s.serveConn(config, nil)
+	return s.listenAndServe(config)
 }
 
 // Serve an existing UDP connection.
 // It is possible to reuse the same connection for outgoing connections.
 // Closing the server does not close the packet conn.
+// Serve can be called multiple times, also while the server is already serving other connections or listeners.
 func (s *Server) Serve(conn net.PacketConn) error {
-	return s.serveConn(s.TLSConfig, conn)
+	if err := s.init(); err != nil {
+		return err
+	}
+	ln, err := s.listen(s.TLSConfig, conn, "")
+	if err != nil {
+		return err
+	}
+	return s.serveListener(ln)
 }
 
 // ServeListener serves an existing QUIC listener.
 // Make sure you use http3.ConfigureTLSConfig to configure a tls.Config
 // and use it to construct a http3-friendly QUIC listener.
 // Closing the server does close the listener.
+// ServeListener can be called multiple times, also while the server is already serving other connections or listeners.
 func (s *Server) ServeListener(listener quic.EarlyListener) error {
-	return s.serveImpl(func() (quic.EarlyListener, error) { return listener, nil })
+	if err := s.init(); err != nil {
+		return err
+	}
+	return s.serveListener(listener)
 }
 
-func (s *Server) serveConn(tlsConf *tls.Config, conn net.PacketConn) error {
-	return s.serveImpl(func() (quic.EarlyListener, error) {
-		baseConf := ConfigureTLSConfig(tlsConf)
-		quicConf := s.QuicConfig
-		if quicConf == nil {
-			quicConf = &quic.Config{}
-		} else {
-			quicConf = s.QuicConfig.Clone()
-		}
-		if s.EnableDatagrams {
-			quicConf.EnableDatagrams = true
-		}
-
-		var ln quic.EarlyListener
-		var err error
-		if conn == nil {
-			ln, err = quicListenAddr(s.Addr, baseConf, quicConf)
-		} else {
-			ln, err = quicListen(conn, baseConf, quicConf)
-		}
+// listenAndServe listens on s.Addr and s.AdditionalAddrs.
+// If listening on any of the addresses fails, it doesn't serve any of them.
+// It returns as soon as serving one of the listeners fails, closing all other listeners.
+func (s *Server) listenAndServe(tlsConf *tls.Config) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	addrs := append([]string{s.Addr}, s.AdditionalAddrs...)
+	lns := make([]quic.EarlyListener, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, err := s.listen(tlsConf, nil, addr)
 		if err != nil {
-			return nil, err
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return err
 		}
-		return ln, nil
-	})
+		lns = append(lns, ln)
+	}
+	if len(lns) == 1 {
+		return s.serveListener(lns[0])
+	}
+
+	errChan := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func(ln quic.EarlyListener) { errChan <- s.serveListener(ln) }(ln)
+	}
+	err := <-errChan
+	for _, ln := range lns {
+		ln.Close()
+	}
+	for i := 1; i < len(lns); i++ {
+		<-errChan
+	}
+	return err
+}
+
+// listen creates a QUIC listener on conn, or on addr if conn is nil.
+func (s *Server) listen(tlsConf *tls.Config, conn net.PacketConn, addr string) (quic.EarlyListener, error) {
+	baseConf := ConfigureTLSConfig(tlsConf)
+	quicConf := s.QuicConfig
+	if quicConf == nil {
+		quicConf = &quic.Config{}
+	} else {
+		quicConf = s.QuicConfig.Clone()
+	}
+	if s.EnableDatagrams || s.EnableWebTransport {
+		quicConf.EnableDatagrams = true
+	}
+	if s.MaxConcurrentRequests > 0 {
+		quicConf.MaxIncomingStreams = int64(s.MaxConcurrentRequests)
+	}
+
+	if conn == nil {
+		return quicListenAddr(addr, baseConf, quicConf)
+	}
+	return quicListen(conn, baseConf, quicConf)
 }
 
-func (s *Server) serveImpl(startListener func() (quic.EarlyListener, error)) error {
+func (s *Server) init() error {
 	if s.closed.Get() {
 		return http.ErrServerClosed
 	}
@@ -236,13 +702,16 @@ func (s *Server) serveImpl(startListener func() (quic.EarlyListener, error)) err
 		return errors.New("use of http3.Server without http.Server")
 	}
 	s.loggerOnce.Do(func() {
-		s.logger = utils.DefaultLogger.WithPrefix("server")
+		l := s.Logger
+		if l == nil && s.QuicConfig != nil {
+			l = s.QuicConfig.Logger
+		}
+		s.logger = utils.GetLogger(l).WithPrefix("server")
 	})
+	return nil
+}
 
-	ln, err := startListener()
-	if err != nil {
-		return err
-	}
+func (s *Server) serveListener(ln quic.EarlyListener) error {
 	s.addListener(&ln)
 	defer s.removeListener(&ln)
 
@@ -269,7 +738,7 @@ func extractPort(addr string) (int, error) {
 }
 
 func (s *Server) generateAltSvcHeader() {
-	if len(s.listeners) == 0 {
+	if len(s.listeners) == 0 && len(s.AltSvcAuthorities) == 0 {
 		// Don't announce any ports since no one is listening for connections
 		s.altSvcHeader = ""
 		return
@@ -277,7 +746,9 @@ func (s *Server) generateAltSvcHeader() {
 
 	// This code assumes that we will use protocol.SupportedVersions if no quic.Config is passed.
 	supportedVersions := protocol.SupportedVersions
-	if s.QuicConfig != nil && len(s.QuicConfig.Versions) > 0 {
+	if len(s.AltSvcVersions) > 0 {
+		supportedVersions = s.AltSvcVersions
+	} else if s.QuicConfig != nil && len(s.QuicConfig.Versions) > 0 {
 		supportedVersions = s.QuicConfig.Versions
 	}
 	var versionStrings []string
@@ -286,15 +757,24 @@ func (s *Server) generateAltSvcHeader() {
 			versionStrings = append(versionStrings, v)
 		}
 	}
+	maxAge := s.AltSvcMaxAge
+	if maxAge == 0 {
+		maxAge = defaultServerAltSvcMaxAge
+	}
 
 	var altSvc []string
-	addPort := func(port int) {
+	addAuthority := func(authority string) {
 		for _, v := range versionStrings {
-			altSvc = append(altSvc, fmt.Sprintf(`%s=":%d"; ma=2592000`, v, port))
+			altSvc = append(altSvc, fmt.Sprintf(`%s="%s"; ma=%d`, v, authority, int64(maxAge/time.Second)))
 		}
 	}
+	addPort := func(port int) { addAuthority(fmt.Sprintf(":%d", port)) }
 
-	if s.Port != 0 {
+	if len(s.AltSvcAuthorities) > 0 {
+		for _, authority := range s.AltSvcAuthorities {
+			addAuthority(authority)
+		}
+	} else if s.Port != 0 {
 		// if Port is specified, we must use it instead of the
 		// listener addresses since there's a reason it's specified.
 		addPort(s.Port)
@@ -346,8 +826,55 @@ func (s *Server) removeListener(l *quic.EarlyListener) {
 	s.mutex.Unlock()
 }
 
-func (s *Server) handleConn(conn quic.EarlyConnection) {
-	decoder := qpack.NewDecoder(nil)
+// addConn registers a connection, such that it can be shut down gracefully.
+// If the server is already shutting down, a GOAWAY frame is sent right away.
+func (s *Server) addConn(conn *serverConn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conns == nil {
+		s.conns = make(map[*serverConn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	if s.closed.Get() {
+		if err := conn.goAway(); err != nil {
+			s.logger.Debugf("Sending GOAWAY failed: %s", err)
+		}
+	}
+}
+
+func (s *Server) removeConn(conn *serverConn) {
+	s.mutex.Lock()
+	delete(s.conns, conn)
+	s.mutex.Unlock()
+}
+
+func (s *Server) handleConn(qconn quic.EarlyConnection) {
+	conn := newServerConn(qconn)
+	conn.maxRequests = s.MaxConcurrentRequests
+	conn.strict = newStrictMode(s.Strict, s.ProtocolViolationHandler)
+	if s.ConnState != nil {
+		conn.connState = func(state http.ConnState) { s.ConnState(qconn, state) }
+		conn.connState(http.StateNew)
+		defer conn.reportClosed()
+	}
+	if s.ConnContext != nil {
+		ctx := context.WithValue(context.Background(), ServerContextKey, s)
+		ctx = context.WithValue(ctx, http.LocalAddrContextKey, qconn.LocalAddr())
+		conn.ctx = s.ConnContext(ctx, qconn)
+		if conn.ctx == nil {
+			panic("ConnContext returned nil")
+		}
+	}
+	if s.EnableDatagrams || s.EnableWebTransport {
+		conn.datagrams = newDatagramDemuxer(conn, s.logger)
+	}
+	if s.EnableWebTransport {
+		conn.webTransport = newWebTransportManager(conn, conn.datagrams)
+	}
+	conn.decoder.maxTableCapacity = s.QPACKMaxTableCapacity
+	conn.decoder.maxBlockedStreams = s.QPACKBlockedStreams
+	conn.decoder.maxHeaderListSize = s.MaxHeaderListSize
 
 	// send a SETTINGS frame
 	str, err := conn.OpenUniStream()
@@ -357,8 +884,24 @@ func (s *Server) handleConn(conn quic.EarlyConnection) {
 	}
 	buf := &bytes.Buffer{}
 	quicvarint.Write(buf, streamTypeControlStream) // stream type
-	(&settingsFrame{Datagram: s.EnableDatagrams, Other: s.AdditionalSettings}).Write(buf)
+	(&settingsFrame{
+		Datagram:        s.EnableDatagrams || s.EnableWebTransport,
+		ExtendedConnect: s.extendedConnectEnabled(),
+		Other:           s.settings(),
+	}).Write(buf)
 	str.Write(buf.Bytes())
+	conn.controlStr = str
+	if s.KeepAlivePeriod > 0 {
+		conn.keepAlive = newKeepAlive(s.KeepAlivePeriod, s.KeepAliveIdleGrace, conn.sendKeepAlive)
+		conn.keepAlive.start()
+		defer conn.keepAlive.close()
+	}
+	s.addConn(conn)
+	defer s.removeConn(conn)
+	if timeout := s.idleTimeout(); timeout > 0 {
+		conn.startIdleTimer(timeout)
+		defer conn.stopIdleTimer()
+	}
 
 	go s.handleUnidirectionalStreams(conn)
 
@@ -370,11 +913,31 @@ func (s *Server) handleConn(conn quic.EarlyConnection) {
 			s.logger.Debugf("Accepting stream failed: %s", err)
 			return
 		}
+		select {
+		case <-conn.HandshakeComplete().Done():
+		default:
+			conn.receivedEarlyData(str.StreamID())
+		}
+		if !conn.acceptRequest(str.StreamID()) {
+			// The client will retry this request (on a new connection, if the GOAWAY frame was sent).
+			str.CancelRead(quic.StreamErrorCode(errorRequestRejected))
+			str.CancelWrite(quic.StreamErrorCode(errorRequestRejected))
+			if conn.webTransport != nil {
+				conn.webTransport.requestDone(str.StreamID())
+			}
+			continue
+		}
 		go func() {
-			rerr := s.handleRequest(conn, str, decoder, func() {
-				conn.CloseWithError(quic.ApplicationErrorCode(errorFrameUnexpected), "")
+			defer conn.requestDone()
+			rerr := s.handleRequest(conn, str, func() {
+				conn.strict.connectionError(conn, str, errorFrameUnexpected, "unexpected frame on the request stream")
 			})
+			// Streams of WebTransport sessions that weren't established by this request are not buffered any longer.
+			if conn.webTransport != nil {
+				conn.webTransport.requestDone(str.StreamID())
+			}
 			if rerr.err == errHijacked {
+				conn.streamHijacked()
 				return
 			}
 			if rerr.err != nil || rerr.streamErr != 0 || rerr.connErr != 0 {
@@ -396,11 +959,12 @@ func (s *Server) handleConn(conn quic.EarlyConnection) {
 	}
 }
 
-func (s *Server) handleUnidirectionalStreams(conn quic.EarlyConnection) {
+func (s *Server) handleUnidirectionalStreams(conn *serverConn) {
 	for {
 		str, err := conn.AcceptUniStream(context.Background())
 		if err != nil {
 			s.logger.Debugf("accepting unidirectional stream failed: %s", err)
+			conn.decoder.close(err)
 			return
 		}
 
@@ -413,40 +977,143 @@ func (s *Server) handleUnidirectionalStreams(conn quic.EarlyConnection) {
 			// We're only interested in the control stream here.
 			switch streamType {
 			case streamTypeControlStream:
-			case streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream:
-				// Our QPACK implementation doesn't use the dynamic table yet.
-				// TODO: check that only one stream of each type is opened.
+				if !conn.strict.openCriticalStream(conn, str, streamType) {
+					return
+				}
+			case streamTypeQPACKEncoderStream:
+				if conn.strict.openCriticalStream(conn, str, streamType) {
+					handleEncoderStream(conn, str, conn.decoder, conn.strict)
+				}
+				return
+			case streamTypeQPACKDecoderStream:
+				// Our QPACK encoder doesn't use the dynamic table.
+				if conn.strict.openCriticalStream(conn, str, streamType) {
+					conn.strict.readCriticalStream(conn, str)
+				}
 				return
 			case streamTypePushStream: // only the server can push
 				conn.CloseWithError(quic.ApplicationErrorCode(errorStreamCreationError), "")
 				return
+			case streamTypeWebTransportStream:
+				if conn.webTransport != nil {
+					if err := conn.webTransport.handleUniStream(str); err != nil {
+						s.logger.Debugf("reading the session ID on stream %d failed: %s", str.StreamID(), err)
+						str.CancelRead(quic.StreamErrorCode(errorGeneralProtocolError))
+					}
+					return
+				}
+				fallthrough
 			default:
+				if handleRegisteredUniStream(&s.uniStreamHandlers, &conn.uniStreams, conn.EarlyConnection, str, StreamType(streamType)) {
+					return
+				}
+				if s.UniStreamHijacker != nil && s.UniStreamHijacker(StreamType(streamType), conn.EarlyConnection, str) {
+					return
+				}
 				str.CancelRead(quic.StreamErrorCode(errorStreamCreationError))
 				return
 			}
-			f, err := parseNextFrame(str, nil)
+			f, err := conn.strict.parseFrame(str, nil, s.frameHandlers.forStream(conn.EarlyConnection, str))
 			if err != nil {
-				conn.CloseWithError(quic.ApplicationErrorCode(errorFrameError), "")
+				if !conn.strict.criticalStreamFailed(conn, str, err) {
+					conn.CloseWithError(quic.ApplicationErrorCode(errorFrameError), "")
+				}
 				return
 			}
 			sf, ok := f.(*settingsFrame)
 			if !ok {
-				conn.CloseWithError(quic.ApplicationErrorCode(errorMissingSettings), "")
+				conn.strict.connectionError(conn, str, errorMissingSettings, "")
 				return
 			}
-			if !sf.Datagram {
-				return
+			if conn.datagrams != nil {
+				conn.datagrams.setPeerEnabled(sf.Datagram)
 			}
+			conn.settingsOnce.Do(func() {
+				conn.settings = sf
+				close(conn.receivedSettings)
+			})
 			// If datagram support was enabled on our side as well as on the client side,
 			// we can expect it to have been negotiated both on the transport and on the HTTP/3 layer.
 			// Note: ConnectionState() will block until the handshake is complete (relevant when using 0-RTT).
-			if s.EnableDatagrams && !conn.ConnectionState().SupportsDatagrams {
+			if sf.Datagram && (s.EnableDatagrams || s.EnableWebTransport) && !conn.ConnectionState().SupportsDatagrams {
 				conn.CloseWithError(quic.ApplicationErrorCode(errorSettingsError), "missing QUIC Datagram support")
+				return
+			}
+			if s.SettingsHandler != nil {
+				if err := s.SettingsHandler(conn.EarlyConnection, newSettings(sf)); err != nil {
+					conn.CloseWithError(quic.ApplicationErrorCode(errorSettingsError), err.Error())
+					return
+				}
 			}
+			s.handleControlStream(conn, str)
 		}(str)
 	}
 }
 
+// handleControlStream handles the frames sent on the client's control stream after the SETTINGS frame.
+func (s *Server) handleControlStream(conn *serverConn, str quic.ReceiveStream) {
+	extensionFrames := s.frameHandlers.forStream(conn.EarlyConnection, str)
+	for {
+		f, err := conn.strict.parseFrame(str, nil, extensionFrames)
+		if err != nil {
+			if !conn.strict.criticalStreamFailed(conn, str, err) {
+				s.logger.Debugf("reading from the control stream failed: %s", err)
+			}
+			return
+		}
+		switch f := f.(type) {
+		case *maxPushIDFrame:
+			if err := conn.push.handleMaxPushID(f.PushID); err != nil {
+				conn.strict.connectionError(conn, str, errorIDError, err.Error())
+				return
+			}
+		case *cancelPushFrame:
+			if err := conn.push.handleCancelPush(f.PushID); err != nil {
+				conn.strict.connectionError(conn, str, errorIDError, err.Error())
+				return
+			}
+		case *goAwayFrame:
+			if err := conn.push.handleGoAway(f.StreamID); err != nil {
+				conn.strict.connectionError(conn, str, errorIDError, err.Error())
+				return
+			}
+		case *priorityUpdateFrame:
+			// pushed responses are not scheduled
+			if f.IsPush {
+				continue
+			}
+			id := quic.StreamID(f.ElementID)
+			if id.InitiatedBy() != protocol.PerspectiveClient || id.Type() != protocol.StreamTypeBidi {
+				conn.strict.connectionError(conn, str, errorIDError, fmt.Sprintf("PRIORITY_UPDATE for invalid stream %d", id))
+				return
+			}
+			conn.scheduler.updatePriority(id, ParsePriority(f.PriorityFieldValue))
+		default:
+			conn.strict.connectionError(conn, str, errorFrameUnexpected, fmt.Sprintf("unexpected frame on the control stream: %T", f))
+			return
+		}
+	}
+}
+
+// settings returns the additional settings sent in the SETTINGS frame.
+func (s *Server) settings() map[uint64]uint64 {
+	settings := limitSettings(s.AdditionalSettings, s.QPACKMaxTableCapacity, s.QPACKBlockedStreams, s.MaxHeaderListSize)
+	if _, ok := settings[settingExtendedConnect]; !ok && !s.EnableWebTransport {
+		return settings
+	}
+	settings = copySettings(settings)
+	// Sending SETTINGS_ENABLE_CONNECT_PROTOCOL a second time would be a connection error.
+	delete(settings, settingExtendedConnect)
+	if s.EnableWebTransport {
+		settings[settingEnableWebTransport] = 1
+	}
+	return settings
+}
+
+func (s *Server) extendedConnectEnabled() bool {
+	return !s.DisableExtendedConnect || s.EnableWebTransport
+}
+
 func (s *Server) maxHeaderBytes() uint64 {
 	if s.Server.MaxHeaderBytes <= 0 {
 		return http.DefaultMaxHeaderBytes
@@ -454,23 +1121,70 @@ func (s *Server) maxHeaderBytes() uint64 {
 	return uint64(s.Server.MaxHeaderBytes)
 }
 
-func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *qpack.Decoder, onFrameError func()) requestError {
+// readHeaderTimeout returns the timeout for reading the request headers.
+// Like net/http, it falls back to the ReadTimeout.
+func (s *Server) readHeaderTimeout() time.Duration {
+	if s.Server.ReadHeaderTimeout > 0 {
+		return s.Server.ReadHeaderTimeout
+	}
+	return s.Server.ReadTimeout
+}
+
+// idleTimeout returns the time after which an idle connection is closed.
+// Like net/http, it falls back to the ReadTimeout.
+func (s *Server) idleTimeout() time.Duration {
+	if s.Server.IdleTimeout > 0 {
+		return s.Server.IdleTimeout
+	}
+	return s.Server.ReadTimeout
+}
+
+func (s *Server) handleRequest(conn *serverConn, str quic.Stream, onFrameError func()) requestError {
+	start := time.Now()
+	headerTimeout := s.readHeaderTimeout()
+	if headerTimeout > 0 {
+		str.SetReadDeadline(start.Add(headerTimeout))
+	}
 	var ufh unknownFrameHandlerFunc
-	if s.StreamHijacker != nil {
+	if s.StreamHijacker != nil || conn.webTransport != nil {
 		ufh = func(ft FrameType) (processed bool, err error) {
-			return s.StreamHijacker(ft, conn, str)
+			// The stream might be taken over, and the deadline must not apply to it.
+			if headerTimeout > 0 {
+				str.SetReadDeadline(time.Time{})
+				defer func() {
+					if !processed && err == nil {
+						str.SetReadDeadline(start.Add(headerTimeout))
+					}
+				}()
+			}
+			if ft == frameTypeWebTransportStream && conn.webTransport != nil {
+				if err := conn.webTransport.handleBidiStream(str); err != nil {
+					s.logger.Debugf("reading the session ID on stream %d failed: %s", str.StreamID(), err)
+					str.CancelRead(quic.StreamErrorCode(errorGeneralProtocolError))
+					str.CancelWrite(quic.StreamErrorCode(errorGeneralProtocolError))
+				}
+				return true, nil
+			}
+			if s.StreamHijacker == nil {
+				return false, nil
+			}
+			return s.StreamHijacker(ft, conn.EarlyConnection, str)
 		}
 	}
-	frame, err := parseNextFrame(str, ufh)
+	extensionFrames := s.frameHandlers.forStream(conn.EarlyConnection, str)
+	frame, err := conn.strict.parseFrame(str, ufh, extensionFrames)
 	if err != nil {
 		if err == errHijacked {
 			return requestError{err: errHijacked}
 		}
+		if err == errReservedFrameType {
+			return conn.strict.reportRequestError(conn, str, newConnError(errorFrameUnexpected, err))
+		}
 		return newStreamError(errorRequestIncomplete, err)
 	}
 	hf, ok := frame.(*headersFrame)
 	if !ok {
-		return newConnError(errorFrameUnexpected, errors.New("expected first frame to be a HEADERS frame"))
+		return conn.strict.reportRequestError(conn, str, newConnError(errorFrameUnexpected, errors.New("expected first frame to be a HEADERS frame")))
 	}
 	if hf.Length > s.maxHeaderBytes() {
 		return newStreamError(errorFrameError, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, s.maxHeaderBytes()))
@@ -479,19 +1193,85 @@ func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *q
 	if _, err := io.ReadFull(str, headerBlock); err != nil {
 		return newStreamError(errorRequestIncomplete, err)
 	}
-	hfs, err := decoder.DecodeFull(headerBlock)
+	// The stream's context is canceled when the peer stops reading the response, or when the connection is closed.
+	strCtx := str.Context()
+	decodeCtx := strCtx
+	if headerTimeout > 0 {
+		var cancel context.CancelFunc
+		decodeCtx, cancel = context.WithDeadline(decodeCtx, start.Add(headerTimeout))
+		defer cancel()
+	}
+	hfs, err := conn.decoder.decode(decodeCtx, str, headerBlock)
+	if err == errHeaderListTooLarge {
+		// Like net/http, respond with 431 Request Header Fields Too Large.
+		s.logger.Debugf("Request header list too large (max: %d bytes)", s.MaxHeaderListSize)
+		str.CancelRead(quic.StreamErrorCode(errorNoError))
+		rw := newResponseWriter(str, conn, s.logger)
+		rw.WriteHeader(http.StatusRequestHeaderFieldsTooLarge)
+		rw.Flush()
+		return requestError{}
+	}
 	if err != nil {
-		// TODO: use the right error code
-		return newConnError(errorGeneralProtocolError, err)
+		return decodingRequestError(err)
+	}
+	if err := validateHeaderFields(hfs, true, conn.strict.enabled); err != nil {
+		str.CancelRead(quic.StreamErrorCode(errorMessageError))
+		return conn.strict.reportRequestError(conn, str, newStreamError(errorMessageError, err))
+	}
+	if !s.extendedConnectEnabled() && hasProtocolPseudoHeader(hfs) {
+		str.CancelRead(quic.StreamErrorCode(errorMessageError))
+		return conn.strict.reportRequestError(conn, str, newStreamError(errorMessageError, errors.New("received Extended CONNECT request, but Extended CONNECT is disabled")))
 	}
 	req, err := requestFromHeaders(hfs)
 	if err != nil {
-		// TODO: use the right error code
-		return newStreamError(errorGeneralProtocolError, err)
+		str.CancelRead(quic.StreamErrorCode(errorMessageError))
+		return conn.strict.reportRequestError(conn, str, newStreamError(errorMessageError, err))
+	}
+	if s.MaxRequestBodySize > 0 && req.ContentLength > s.MaxRequestBodySize {
+		str.CancelRead(quic.StreamErrorCode(errorRequestRejected))
+		return newStreamError(errorRequestRejected, fmt.Errorf("request body too large: %d bytes (max: %d)", req.ContentLength, s.MaxRequestBodySize))
+	}
+	// Like net/http, the ReadTimeout covers reading the entire request,
+	// and the WriteTimeout starts when the request headers have been read.
+	if s.Server.ReadTimeout > 0 {
+		str.SetReadDeadline(start.Add(s.Server.ReadTimeout))
+	} else if headerTimeout > 0 {
+		str.SetReadDeadline(time.Time{})
+	}
+	if s.Server.WriteTimeout > 0 {
+		str.SetWriteDeadline(time.Now().Add(s.Server.WriteTimeout))
 	}
 
 	req.RemoteAddr = conn.RemoteAddr().String()
-	req.Body = newRequestBody(str, onFrameError)
+	req.Trailer = parseAnnouncedTrailers(req.Header)
+	body := newRequestBody(str, onFrameError)
+	body.rejectReservedFrames = conn.strict.enabled
+	body.extensionFrames = extensionFrames
+	for _, hf := range hfs {
+		if hf.Name == "content-length" {
+			body.contentLength = req.ContentLength
+		}
+	}
+	// Like net/http, only the trailers announced in the Trailer header are made available to the handler.
+	if req.Trailer != nil {
+		trailer := req.Trailer
+		body.onTrailers = func(f *headersFrame) error {
+			received := make(http.Header)
+			if err := readTrailers(strCtx, conn, str, conn.decoder, s.maxHeaderBytes(), f, received); err != nil {
+				return err
+			}
+			for k, vv := range received {
+				if _, ok := trailer[k]; ok {
+					trailer[k] = vv
+				}
+			}
+			return nil
+		}
+	}
+	req.Body = body
+	if s.MaxRequestBodySize > 0 {
+		req.Body = &maxBytesBody{ReadCloser: body, str: str, remaining: s.MaxRequestBodySize}
+	}
 
 	if s.logger.Debug() {
 		s.logger.Infof("%s %s%s, on stream %d", req.Method, req.Host, req.RequestURI, str.StreamID())
@@ -499,46 +1279,172 @@ func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *q
 		s.logger.Infof("%s %s%s", req.Method, req.Host, req.RequestURI)
 	}
 
-	ctx := str.Context()
+	ctx := strCtx
+	if conn.ctx != nil {
+		ctx = &connValuesContext{Context: ctx, values: conn.ctx}
+	}
 	ctx = context.WithValue(ctx, ServerContextKey, s)
 	ctx = context.WithValue(ctx, http.LocalAddrContextKey, conn.LocalAddr())
+	ctx = context.WithValue(ctx, ConnectionContextKey, conn.EarlyConnection)
+	ctx = context.WithValue(ctx, StreamIDContextKey, str.StreamID())
+	if conn.isEarlyData(str.StreamID()) {
+		ctx = context.WithValue(ctx, receivedEarlyDataContextKey, true)
+	}
+	ctx = context.WithValue(ctx, priorityContextKey, func() Priority { return conn.scheduler.getPriority(str.StreamID()) })
 	req = req.WithContext(ctx)
-	r := newResponseWriter(str, conn, s.logger)
+	conn.scheduler.register(str.StreamID(), ParsePriority(req.Header.Get("Priority")))
+	defer conn.scheduler.remove(str.StreamID())
+
+	r := newResponseWriter(str, conn.EarlyConnection, s.logger)
+	// write the response according to its priority
+	if s.WriteBufferSize > 0 {
+		r.bufferedStream = bufio.NewWriterSize(conn.scheduler.newWriter(str), s.WriteBufferSize)
+	} else {
+		r.bufferedStream.Reset(conn.scheduler.newWriter(str))
+	}
+	r.autoFlush = s.WriteBufferSize < 0
+	r.datagrams = conn.datagrams
+	r.webTransport = conn.webTransport
+	r.settings = conn
+	r.clearDeadlines = headerTimeout > 0 || s.Server.WriteTimeout > 0
+	r.pusher = func(target string, opts *http.PushOptions) error {
+		return s.push(conn, r, req, target, opts)
+	}
+	r.setPriority = func(p Priority) { conn.scheduler.updatePriority(str.StreamID(), p) }
+	if httpguts.HeaderValuesContainsToken(req.Header["Expect"], "100-continue") {
+		req.Body = &expectContinueReader{ReadCloser: req.Body, w: r}
+	}
+	var handlerErr error // reported to the LogRequest callback
+	if s.LogRequest != nil {
+		defer func() {
+			s.LogRequest(&RequestLog{
+				Method:       req.Method,
+				Host:         req.Host,
+				RequestURI:   req.RequestURI,
+				Status:       r.status,
+				BytesWritten: r.bytesWritten,
+				Duration:     time.Since(start),
+				StreamID:     str.StreamID(),
+				Connection:   conn.EarlyConnection,
+				Err:          handlerErr,
+			})
+		}()
+	}
 	defer func() {
 		if !r.usedDataStream() {
-			r.Flush()
+			if err := r.FlushError(); err != nil {
+				s.logger.Errorf("could not flush to stream: %s", err.Error())
+				if handlerErr == nil {
+					handlerErr = err
+				}
+			}
 		}
 	}()
+
+	panicked := s.serveHTTP(r, req)
+	r.closeDatagrams()
+
+	// The handler took over the stream (e.g. for a WebTransport session).
+	// It is now responsible for closing it.
+	if r.usedDataStream() {
+		return requestError{err: errHijacked}
+	}
+	if panicked {
+		handlerErr = errHandlerPanicked
+		r.WriteHeader(500)
+	} else {
+		r.WriteHeader(200)
+		r.writeTrailers()
+	}
+	// If the EOF was read by the handler, CancelRead() is a no-op.
+	str.CancelRead(quic.StreamErrorCode(errorNoError))
+	return requestError{}
+}
+
+// expectContinueReader sends a 100 Continue response when the handler starts reading
+// the body of a request with an "Expect: 100-continue" header, like net/http does.
+type expectContinueReader struct {
+	io.ReadCloser
+	w            *responseWriter
+	sentContinue bool
+}
+
+func (r *expectContinueReader) Read(p []byte) (int, error) {
+	if !r.sentContinue {
+		r.sentContinue = true
+		if !r.w.headerWritten {
+			r.w.WriteHeader(http.StatusContinue)
+		}
+	}
+	return r.ReadCloser.Read(p)
+}
+
+// connValuesContext is the context of a request stream,
+// carrying the values of the context returned by the ConnContext callback.
+type connValuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *connValuesContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+var errRequestBodyTooLarge = errors.New("http3: request body too large")
+
+// maxBytesBody limits the size of a request body.
+// When the limit is exceeded, the stream is reset.
+type maxBytesBody struct {
+	io.ReadCloser
+	str       quic.Stream
+	remaining int64
+	err       error
+}
+
+func (b *maxBytesBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	// read one more byte than allowed, to detect if the limit is exceeded
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.err = errRequestBodyTooLarge
+	b.str.CancelRead(quic.StreamErrorCode(errorRequestRejected))
+	b.str.CancelWrite(quic.StreamErrorCode(errorRequestRejected))
+	return n, b.err
+}
+
+// serveHTTP calls the handler, recovering from panics.
+// It reports whether the handler panicked.
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) (panicked bool) {
 	handler := s.Handler
 	if handler == nil {
 		handler = http.DefaultServeMux
 	}
 
-	var panicked bool
-	func() {
-		defer func() {
-			if p := recover(); p != nil {
-				// Copied from net/http/server.go
-				const size = 64 << 10
-				buf := make([]byte, size)
-				buf = buf[:runtime.Stack(buf, false)]
-				s.logger.Errorf("http: panic serving: %v\n%s", p, buf)
-				panicked = true
-			}
-		}()
-		handler.ServeHTTP(r, req)
-	}()
-
-	if !r.usedDataStream() {
-		if panicked {
-			r.WriteHeader(500)
-		} else {
-			r.WriteHeader(200)
+	defer func() {
+		if p := recover(); p != nil {
+			// Copied from net/http/server.go
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			s.logger.Errorf("http: panic serving: %v\n%s", p, buf)
+			panicked = true
 		}
-		// If the EOF was read by the handler, CancelRead() is a no-op.
-		str.CancelRead(quic.StreamErrorCode(errorNoError))
-	}
-	return requestError{}
+	}()
+	handler.ServeHTTP(w, req)
+	return false
 }
 
 // Close the server immediately, aborting requests and sending CONNECTION_CLOSE frames to connected clients.
@@ -558,11 +1464,92 @@ func (s *Server) Close() error {
 	return err
 }
 
+// shutdownPollInterval is how often Shutdown checks if requests have completed.
+var shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown shuts down the server gracefully, without interrupting any active requests.
+// It sends a GOAWAY frame on all connections, rejects requests sent after the GOAWAY frame,
+// and waits for all active requests to complete. Connections are closed as soon as their requests have completed.
+// If the context expires before that, the remaining connections are closed, and the context's error is returned.
+// Shutdown in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closed.Set(true)
+
+	s.mutex.Lock()
+	conns := make([]*serverConn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mutex.Unlock()
+	if len(conns) == 0 {
+		return s.Close()
+	}
+	for _, conn := range conns {
+		if err := conn.goAway(); err != nil {
+			s.logger.Debugf("Sending GOAWAY failed: %s", err)
+		}
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.closeConns()
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if s.closeDrainedConns() {
+			return s.Close()
+		}
+	}
+}
+
+// closeDrainedConns closes all connections that don't have any active requests left.
+// Since closing a connection doesn't flush pending stream data,
+// a connection is only closed if it was already drained when this function was called the last time.
+// It returns true if all connections have been closed.
+func (s *Server) closeDrainedConns() bool {
+	s.mutex.Lock()
+	var drained []*serverConn
+	for conn := range s.conns {
+		if !conn.isDrained() {
+			continue
+		}
+		if conn.wasDrained {
+			drained = append(drained, conn)
+			delete(s.conns, conn)
+		}
+		conn.wasDrained = true
+	}
+	allClosed := len(s.conns) == 0
+	s.mutex.Unlock()
+
+	for _, conn := range drained {
+		conn.CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+	}
+	return allClosed
+}
+
+func (s *Server) closeConns() {
+	s.mutex.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mutex.Unlock()
+
+	for conn := range conns {
+		conn.CloseWithError(quic.ApplicationErrorCode(errorNoError), "")
+	}
+}
+
 // CloseGracefully shuts down the server gracefully. The server sends a GOAWAY frame first, then waits for either timeout to trigger, or for all running requests to complete.
+// It is equivalent to calling Shutdown with a context that expires after timeout.
 // CloseGracefully in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) CloseGracefully(timeout time.Duration) error {
-	// TODO: implement
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.Shutdown(ctx)
 }
 
 // ErrNoAltSvcPort is the error returned by SetQuicHeaders when no port was found
@@ -572,24 +1559,48 @@ var ErrNoAltSvcPort = errors.New("no port can be announced, specify it explicitl
 
 // SetQuicHeaders can be used to set the proper headers that announce that this server supports HTTP/3.
 // The values set by default advertise all of the ports the server is listening on, but can be
-// changed to a specific port by setting Server.Port before launching the serverr.
+// changed to a specific port by setting Server.Port before launching the serverr,
+// or to other authorities by setting Server.AltSvcAuthorities.
+// The announced versions and the ma parameter are configured by Server.AltSvcVersions and Server.AltSvcMaxAge.
 // If no listener's Addr().String() returns an address with a valid port, Server.Addr will be used
 // to extract the port, if specified.
 // For example, a server launched using ListenAndServe on an address with port 443 would set:
 // 	Alt-Svc: h3=":443"; ma=2592000,h3-29=":443"; ma=2592000
 func (s *Server) SetQuicHeaders(hdr http.Header) error {
 	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	if s.altSvcHeader == "" {
+	altSvcHeader := s.altSvcHeader
+	s.mutex.RUnlock()
+
+	if altSvcHeader == "" && len(s.AltSvcAuthorities) > 0 {
+		// The header is only generated when a listener is added.
+		s.mutex.Lock()
+		s.generateAltSvcHeader()
+		altSvcHeader = s.altSvcHeader
+		s.mutex.Unlock()
+	}
+	if altSvcHeader == "" {
 		return ErrNoAltSvcPort
 	}
 	// use the map directly to avoid constant canonicalization
 	// since the key is already canonicalized
-	hdr["Alt-Svc"] = append(hdr["Alt-Svc"], s.altSvcHeader)
+	hdr["Alt-Svc"] = append(hdr["Alt-Svc"], altSvcHeader)
 	return nil
 }
 
+// AltSvcHandler returns a handler that announces this server in the Alt-Svc header (using SetQuicHeaders)
+// of every response, and then calls handler.
+// It is intended to be used as the handler of the HTTP/1.1 and HTTP/2 server running next to this server.
+// If handler is nil, http.DefaultServeMux is used.
+func (s *Server) AltSvcHandler(handler http.Handler) http.Handler {
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.SetQuicHeaders(w.Header())
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // ListenAndServeQUIC listens on the UDP network address addr and calls the
 // handler for HTTP/3 requests on incoming connections. http.DefaultServeMux is
 // used when handler is nil.
@@ -655,13 +1666,7 @@ func ListenAndServe(addr, certFile, keyFile string, handler http.Handler) error
 		Server: httpServer,
 	}
 
-	if handler == nil {
-		handler = http.DefaultServeMux
-	}
-	httpServer.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		quicServer.SetQuicHeaders(w.Header())
-		handler.ServeHTTP(w, r)
-	})
+	httpServer.Handler = quicServer.AltSvcHandler(handler)
 
 	hErr := make(chan error)
 	qErr := make(chan error)
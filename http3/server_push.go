@@ -0,0 +1,290 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http/httpguts"
+)
+
+// ErrPushLimitReached is returned by Push when the client doesn't allow any more pushes.
+// Clients allow more pushes by increasing the maximum push ID using MAX_PUSH_ID frames.
+var ErrPushLimitReached = errors.New("http3: push ID limit reached")
+
+// pushState is the server push state of a single connection.
+type pushState struct {
+	mutex      sync.Mutex
+	maxPushID  int64 // -1 until the client sends the first MAX_PUSH_ID frame
+	nextPushID uint64
+	// push streams that are currently open, used for CANCEL_PUSH handling
+	streams map[uint64]quic.SendStream
+	// push IDs that the client canceled before we opened the push stream
+	canceled map[uint64]struct{}
+	// the push ID sent in the client's GOAWAY frame, if any
+	goAwayID       uint64
+	receivedGoAway bool
+}
+
+func newPushState() *pushState {
+	return &pushState{
+		maxPushID: -1,
+		streams:   make(map[uint64]quic.SendStream),
+		canceled:  make(map[uint64]struct{}),
+	}
+}
+
+func (p *pushState) handleMaxPushID(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if int64(id) < p.maxPushID {
+		return fmt.Errorf("MAX_PUSH_ID reduced the maximum push ID (from %d to %d)", p.maxPushID, id)
+	}
+	p.maxPushID = int64(id)
+	return nil
+}
+
+func (p *pushState) handleCancelPush(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if int64(id) > p.maxPushID {
+		return fmt.Errorf("CANCEL_PUSH for push ID %d exceeds the maximum push ID (%d)", id, p.maxPushID)
+	}
+	if str, ok := p.streams[id]; ok {
+		str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+		delete(p.streams, id)
+		return nil
+	}
+	if id >= p.nextPushID {
+		p.canceled[id] = struct{}{}
+	}
+	return nil
+}
+
+// handleGoAway handles a GOAWAY frame sent by the client.
+// Push IDs greater than or equal to id must not be used any more.
+func (p *pushState) handleGoAway(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.receivedGoAway && id > p.goAwayID {
+		return fmt.Errorf("GOAWAY increased the push ID (from %d to %d)", p.goAwayID, id)
+	}
+	p.goAwayID = id
+	p.receivedGoAway = true
+	return nil
+}
+
+// allocatePushID returns the next push ID.
+// It returns http.ErrNotSupported if the client didn't enable server push.
+func (p *pushState) allocatePushID() (uint64, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.maxPushID < 0 {
+		return 0, http.ErrNotSupported
+	}
+	if int64(p.nextPushID) > p.maxPushID || (p.receivedGoAway && p.nextPushID >= p.goAwayID) {
+		return 0, ErrPushLimitReached
+	}
+	id := p.nextPushID
+	p.nextPushID++
+	return id, nil
+}
+
+// addStream registers the push stream for the given push ID.
+// It returns false if the push was already canceled by the client.
+func (p *pushState) addStream(id uint64, str quic.SendStream) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.canceled[id]; ok {
+		delete(p.canceled, id)
+		return false
+	}
+	p.streams[id] = str
+	return true
+}
+
+func (p *pushState) removeStream(id uint64) {
+	p.mutex.Lock()
+	delete(p.streams, id)
+	p.mutex.Unlock()
+}
+
+// push sends a PUSH_PROMISE frame on the request stream of w,
+// opens a push stream and serves the pushed request on it.
+// It implements http.Pusher.Push for the response writer of req.
+func (s *Server) push(conn *serverConn, w *responseWriter, req *http.Request, target string, opts *http.PushOptions) error {
+	pushReq, err := newPushedRequest(req, target, opts)
+	if err != nil {
+		return err
+	}
+	pushID, err := conn.push.allocatePushID()
+	if err != nil {
+		return err
+	}
+
+	var headers bytes.Buffer
+	enc := qpack.NewEncoder(&headers)
+	enc.WriteField(qpack.HeaderField{Name: ":method", Value: pushReq.Method})
+	enc.WriteField(qpack.HeaderField{Name: ":scheme", Value: pushReq.URL.Scheme})
+	enc.WriteField(qpack.HeaderField{Name: ":authority", Value: pushReq.Host})
+	enc.WriteField(qpack.HeaderField{Name: ":path", Value: pushReq.URL.RequestURI()})
+	for k, vv := range pushReq.Header {
+		for _, v := range vv {
+			enc.WriteField(qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	buf := &bytes.Buffer{}
+	(&pushPromiseFrame{PushID: pushID, Length: uint64(headers.Len())}).Write(buf)
+	buf.Write(headers.Bytes())
+	if _, err := w.bufferedStream.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.bufferedStream.Flush(); err != nil {
+		return err
+	}
+
+	str, err := conn.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	if !conn.push.addStream(pushID, str) {
+		str.CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+		return nil
+	}
+	buf.Reset()
+	quicvarint.Write(buf, streamTypePushStream)
+	quicvarint.Write(buf, pushID)
+	if _, err := str.Write(buf.Bytes()); err != nil {
+		conn.push.removeStream(pushID)
+		return err
+	}
+
+	s.logger.Debugf("Pushing %s (push ID %d)", pushReq.URL.RequestURI(), pushID)
+	go s.handlePush(conn, str, pushID, pushReq)
+	return nil
+}
+
+func (s *Server) handlePush(conn *serverConn, str quic.SendStream, pushID uint64, req *http.Request) {
+	defer conn.push.removeStream(pushID)
+
+	ctx, cancel := context.WithCancel(conn.Context())
+	defer cancel()
+	ctx = context.WithValue(ctx, ServerContextKey, s)
+	ctx = context.WithValue(ctx, http.LocalAddrContextKey, conn.LocalAddr())
+	ctx = context.WithValue(ctx, ConnectionContextKey, conn.EarlyConnection)
+	req = req.WithContext(ctx)
+
+	r := newPushResponseWriter(str, s.logger)
+	panicked := s.serveHTTP(pushedResponseWriter{r}, req)
+	if panicked {
+		r.WriteHeader(500)
+	} else {
+		r.WriteHeader(200)
+		r.writeTrailers()
+	}
+	r.Flush()
+	str.Close()
+}
+
+// newPushedRequest creates the request that is promised in the PUSH_PROMISE frame.
+// The checks follow the ones done by the HTTP/2 server in net/http.
+func newPushedRequest(req *http.Request, target string, opts *http.PushOptions) (*http.Request, error) {
+	if opts == nil {
+		opts = &http.PushOptions{}
+	}
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	// RFC 9114, Section 4.6: promised requests must be safe and cacheable.
+	if method != http.MethodGet && method != http.MethodHead {
+		return nil, fmt.Errorf("method %q must be GET or HEAD", method)
+	}
+
+	scheme := "https"
+	authority := req.Host
+	var u *url.URL
+	if strings.HasPrefix(target, "/") {
+		var err error
+		u, err = url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		u.Scheme = scheme
+		u.Host = authority
+	} else {
+		var err error
+		u, err = url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != scheme {
+			return nil, fmt.Errorf("cannot push URL with scheme %q from request with scheme %q", u.Scheme, scheme)
+		}
+		if u.Host != authority {
+			return nil, fmt.Errorf("cannot push URL with host %q from request with host %q", u.Host, authority)
+		}
+	}
+
+	header := http.Header{}
+	for k, vv := range opts.Header {
+		if !httpguts.ValidHeaderFieldName(k) {
+			return nil, fmt.Errorf("invalid HTTP header name %q", k)
+		}
+		if strings.HasPrefix(k, ":") {
+			return nil, fmt.Errorf("promised request headers cannot include pseudo header %q", k)
+		}
+		switch strings.ToLower(k) {
+		case "content-length", "content-encoding", "trailer", "te", "expect", "host":
+			return nil, fmt.Errorf("promised request headers cannot include %q", k)
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return nil, fmt.Errorf("invalid HTTP header value %q for header %q", v, k)
+			}
+		}
+		header[k] = vv
+	}
+
+	return &http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/3",
+		ProtoMajor: 3,
+		Header:     header,
+		Body:       http.NoBody,
+		Host:       authority,
+		RequestURI: u.RequestURI(),
+		RemoteAddr: req.RemoteAddr,
+		TLS:        req.TLS,
+	}, nil
+}
+
+// pushedResponseWriter is the http.ResponseWriter passed to handlers serving a pushed request.
+// It hides all methods that don't make sense on a push stream (e.g. pushing again, or taking over the stream).
+type pushedResponseWriter struct {
+	w *responseWriter
+}
+
+var (
+	_ http.ResponseWriter = pushedResponseWriter{}
+	_ http.Flusher        = pushedResponseWriter{}
+)
+
+func (w pushedResponseWriter) Header() http.Header         { return w.w.Header() }
+func (w pushedResponseWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+func (w pushedResponseWriter) WriteHeader(status int)      { w.w.WriteHeader(status) }
+func (w pushedResponseWriter) Flush()                      { w.w.Flush() }
@@ -0,0 +1,245 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server Push", func() {
+	Context("push state", func() {
+		var p *pushState
+
+		BeforeEach(func() {
+			p = newPushState()
+		})
+
+		It("doesn't allow pushes before the client sent a MAX_PUSH_ID frame", func() {
+			_, err := p.allocatePushID()
+			Expect(err).To(MatchError(http.ErrNotSupported))
+		})
+
+		It("allocates push IDs up to the maximum push ID", func() {
+			Expect(p.handleMaxPushID(1)).To(Succeed())
+			id, err := p.allocatePushID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(BeZero())
+			id, err = p.allocatePushID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(BeEquivalentTo(1))
+			_, err = p.allocatePushID()
+			Expect(err).To(MatchError(ErrPushLimitReached))
+			Expect(p.handleMaxPushID(2)).To(Succeed())
+			id, err = p.allocatePushID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(BeEquivalentTo(2))
+		})
+
+		It("rejects MAX_PUSH_ID frames that reduce the maximum push ID", func() {
+			Expect(p.handleMaxPushID(10)).To(Succeed())
+			Expect(p.handleMaxPushID(10)).To(Succeed())
+			Expect(p.handleMaxPushID(9)).To(MatchError("MAX_PUSH_ID reduced the maximum push ID (from 10 to 9)"))
+		})
+
+		It("doesn't use push IDs after the client's GOAWAY", func() {
+			Expect(p.handleMaxPushID(10)).To(Succeed())
+			Expect(p.handleGoAway(1)).To(Succeed())
+			id, err := p.allocatePushID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(BeZero())
+			_, err = p.allocatePushID()
+			Expect(err).To(MatchError(ErrPushLimitReached))
+		})
+
+		It("rejects GOAWAY frames that increase the push ID", func() {
+			Expect(p.handleGoAway(5)).To(Succeed())
+			Expect(p.handleGoAway(5)).To(Succeed())
+			Expect(p.handleGoAway(6)).To(MatchError("GOAWAY increased the push ID (from 5 to 6)"))
+		})
+
+		It("rejects CANCEL_PUSH frames for push IDs larger than the maximum push ID", func() {
+			Expect(p.handleMaxPushID(10)).To(Succeed())
+			Expect(p.handleCancelPush(11)).To(MatchError("CANCEL_PUSH for push ID 11 exceeds the maximum push ID (10)"))
+		})
+
+		It("cancels push streams", func() {
+			Expect(p.handleMaxPushID(10)).To(Succeed())
+			id, err := p.allocatePushID()
+			Expect(err).ToNot(HaveOccurred())
+			str := mockquic.NewMockStream(mockCtrl)
+			Expect(p.addStream(id, str)).To(BeTrue())
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(errorRequestCanceled))
+			Expect(p.handleCancelPush(id)).To(Succeed())
+		})
+
+		It("remembers pushes canceled before the push stream was opened", func() {
+			Expect(p.handleMaxPushID(10)).To(Succeed())
+			id, err := p.allocatePushID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.handleCancelPush(id + 1)).To(Succeed())
+			Expect(p.addStream(id, mockquic.NewMockStream(mockCtrl))).To(BeTrue())
+			id, err = p.allocatePushID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.addStream(id, mockquic.NewMockStream(mockCtrl))).To(BeFalse())
+		})
+	})
+
+	Context("promised requests", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest(http.MethodGet, "https://quic.clemente.io/index.html", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("creates a request for a path", func() {
+			r, err := newPushedRequest(req, "/style.css?v=1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.Method).To(Equal(http.MethodGet))
+			Expect(r.Host).To(Equal("quic.clemente.io"))
+			Expect(r.URL.String()).To(Equal("https://quic.clemente.io/style.css?v=1"))
+			Expect(r.RequestURI).To(Equal("/style.css?v=1"))
+		})
+
+		It("creates a request for an absolute URL", func() {
+			r, err := newPushedRequest(req, "https://quic.clemente.io/script.js", &http.PushOptions{
+				Method: http.MethodHead,
+				Header: http.Header{"Accept-Encoding": {"gzip"}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.Method).To(Equal(http.MethodHead))
+			Expect(r.Header.Get("Accept-Encoding")).To(Equal("gzip"))
+		})
+
+		It("rejects unsafe methods", func() {
+			_, err := newPushedRequest(req, "/foo", &http.PushOptions{Method: http.MethodPost})
+			Expect(err).To(MatchError(`method "POST" must be GET or HEAD`))
+		})
+
+		It("rejects pushes for other hosts", func() {
+			_, err := newPushedRequest(req, "https://example.com/foo", nil)
+			Expect(err).To(MatchError(`cannot push URL with host "example.com" from request with host "quic.clemente.io"`))
+		})
+
+		It("rejects forbidden headers", func() {
+			_, err := newPushedRequest(req, "/foo", &http.PushOptions{Header: http.Header{"Content-Length": {"42"}}})
+			Expect(err).To(MatchError(`promised request headers cannot include "Content-Length"`))
+		})
+	})
+
+	Context("pushing", func() {
+		var (
+			s       *Server
+			conn    *mockquic.MockEarlyConnection
+			sconn   *serverConn
+			str     *mockquic.MockStream
+			pushStr *mockquic.MockStream
+			strBuf  *bytes.Buffer
+			pushBuf *bytes.Buffer
+		)
+
+		readHeaders := func(r io.Reader, hf uint64) map[string]string {
+			data := make([]byte, hf)
+			_, err := io.ReadFull(r, data)
+			Expect(err).ToNot(HaveOccurred())
+			hfs, err := qpack.NewDecoder(nil).DecodeFull(data)
+			Expect(err).ToNot(HaveOccurred())
+			fields := make(map[string]string)
+			for _, f := range hfs {
+				fields[f.Name] = f.Value
+			}
+			return fields
+		}
+
+		BeforeEach(func() {
+			s = &Server{
+				Server: &http.Server{TLSConfig: testdata.GetTLSConfig()},
+				logger: utils.DefaultLogger,
+			}
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().RemoteAddr().Return(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}).AnyTimes()
+			conn.EXPECT().LocalAddr().AnyTimes()
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
+			sconn = newServerConn(conn)
+			strBuf = &bytes.Buffer{}
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(strBuf.Write).AnyTimes()
+			pushBuf = &bytes.Buffer{}
+			pushStr = mockquic.NewMockStream(mockCtrl)
+			pushStr.EXPECT().Write(gomock.Any()).DoAndReturn(pushBuf.Write).AnyTimes()
+		})
+
+		It("sends a PUSH_PROMISE and serves the pushed response", func() {
+			Expect(sconn.push.handleMaxPushID(10)).To(Succeed())
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.URL.Path))
+			})
+			req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			rw := newResponseWriter(str, conn, utils.DefaultLogger)
+
+			conn.EXPECT().OpenUniStream().Return(pushStr, nil)
+			pushed := make(chan struct{})
+			pushStr.EXPECT().Close().Do(func() { close(pushed) })
+			Expect(s.push(sconn, rw, req, "/style.css", nil)).To(Succeed())
+			Eventually(pushed).Should(BeClosed())
+
+			// check the PUSH_PROMISE frame on the request stream
+			frame, err := parseNextFrame(strBuf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&pushPromiseFrame{}))
+			ppf := frame.(*pushPromiseFrame)
+			Expect(ppf.PushID).To(BeZero())
+			fields := readHeaders(strBuf, ppf.Length)
+			Expect(fields).To(HaveKeyWithValue(":method", "GET"))
+			Expect(fields).To(HaveKeyWithValue(":scheme", "https"))
+			Expect(fields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
+			Expect(fields).To(HaveKeyWithValue(":path", "/style.css"))
+
+			// check the push stream
+			r := quicvarint.NewReader(pushBuf)
+			st, err := quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(st).To(BeEquivalentTo(streamTypePushStream))
+			pushID, err := quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushID).To(BeZero())
+			frame, err = parseNextFrame(pushBuf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&headersFrame{}))
+			Expect(readHeaders(pushBuf, frame.(*headersFrame).Length)).To(HaveKeyWithValue(":status", "200"))
+			frame, err = parseNextFrame(pushBuf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&dataFrame{Length: uint64(len("/style.css"))}))
+			Expect(pushBuf.String()).To(Equal("/style.css"))
+		})
+
+		It("doesn't push if the client didn't enable server push", func() {
+			req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			rw := newResponseWriter(str, conn, utils.DefaultLogger)
+			Expect(s.push(sconn, rw, req, "/style.css", nil)).To(MatchError(http.ErrNotSupported))
+			Expect(strBuf.Len()).To(BeZero())
+		})
+
+		It("returns http.ErrNotSupported when no pusher is set", func() {
+			rw := newResponseWriter(str, conn, utils.DefaultLogger)
+			Expect(rw.Push("/style.css", nil)).To(MatchError(http.ErrNotSupported))
+		})
+	})
+})
@@ -7,8 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -105,7 +109,6 @@ var _ = Describe("Server", func() {
 
 	Context("handling requests", func() {
 		var (
-			qpackDecoder       *qpack.Decoder
 			str                *mockquic.MockStream
 			conn               *mockquic.MockEarlyConnection
 			exampleGetRequest  *http.Request
@@ -139,7 +142,7 @@ var _ = Describe("Server", func() {
 			closed := make(chan struct{})
 			str.EXPECT().Close().Do(func() { close(closed) })
 			rw := newRequestWriter(utils.DefaultLogger)
-			Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+			Expect(rw.WriteRequest(str, req, false, false, false, nil)).To(Succeed())
 			Eventually(closed).Should(BeClosed())
 			return buf.Bytes()
 		}
@@ -161,8 +164,8 @@ var _ = Describe("Server", func() {
 			examplePostRequest, err = http.NewRequest("POST", "https://www.example.com", bytes.NewReader([]byte("foobar")))
 			Expect(err).ToNot(HaveOccurred())
 
-			qpackDecoder = qpack.NewDecoder(nil)
 			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
 
 			conn = mockquic.NewMockEarlyConnection(mockCtrl)
 			addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
@@ -183,12 +186,305 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			Expect(s.handleRequest(conn, str, qpackDecoder, nil)).To(Equal(requestError{}))
+			Expect(s.handleRequest(newServerConn(conn), str, nil)).To(Equal(requestError{}))
 			var req *http.Request
 			Eventually(requestChan).Should(Receive(&req))
 			Expect(req.Host).To(Equal("www.example.com"))
 			Expect(req.RemoteAddr).To(Equal("127.0.0.1:1337"))
 			Expect(req.Context().Value(ServerContextKey)).To(Equal(s))
+			Expect(req.Context().Value(ConnectionContextKey)).To(Equal(conn))
+			Expect(req.Context().Value(StreamIDContextKey)).To(Equal(quic.StreamID(4)))
+			Expect(RequestEarlyData(req)).To(BeFalse())
+		})
+
+		It("marks requests received in 0-RTT data", func() {
+			requestChan := make(chan *http.Request, 1)
+			s.Handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				requestChan <- r
+			})
+
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				return len(p), nil
+			}).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			sconn := newServerConn(conn)
+			sconn.receivedEarlyData(8)
+			Expect(s.handleRequest(sconn, str, nil)).To(Equal(requestError{}))
+			var req *http.Request
+			Eventually(requestChan).Should(Receive(&req))
+			Expect(RequestEarlyData(req)).To(BeTrue())
+		})
+
+		It("makes the client's SETTINGS available to the handler", func() {
+			settingsChan := make(chan *Settings, 1)
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				defer GinkgoRecover()
+				Expect(w).To(BeAssignableToTypeOf(&responseWriter{}))
+				settingser := w.(Settingser)
+				Expect(settingser.ReceivedSettings()).To(BeClosed())
+				settingsChan <- settingser.Settings()
+			})
+
+			sconn := newServerConn(conn)
+			sconn.settingsOnce.Do(func() {
+				sconn.settings = &settingsFrame{Datagram: true, Other: map[uint64]uint64{0x1337: 42}}
+				close(sconn.receivedSettings)
+			})
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				return len(p), nil
+			}).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			Expect(s.handleRequest(sconn, str, nil)).To(Equal(requestError{}))
+			var settings *Settings
+			Eventually(settingsChan).Should(Receive(&settings))
+			Expect(settings.EnableDatagrams).To(BeTrue())
+			Expect(settings.EnableExtendedConnect).To(BeFalse())
+			Expect(settings.Other).To(Equal(map[uint64]uint64{0x1337: 42}))
+		})
+
+		It("makes the announced request trailers available to the handler", func() {
+			trailerChan := make(chan http.Header, 1)
+			s.Handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				Expect(r.Header).ToNot(HaveKey("Trailer"))
+				Expect(r.Trailer).To(Equal(http.Header{"Foo": nil}))
+				body, err := ioutil.ReadAll(r.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(Equal([]byte("foobar")))
+				trailerChan <- r.Trailer
+			})
+
+			examplePostRequest.Trailer = http.Header{"Foo": []string{"bar"}}
+			setRequest(encodeRequest(examplePostRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				return len(p), nil
+			}).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			Expect(s.handleRequest(newServerConn(conn), str, nil)).To(Equal(requestError{}))
+			Eventually(trailerChan).Should(Receive(Equal(http.Header{"Foo": []string{"bar"}})))
+		})
+
+		It("makes the request priority available to the handler", func() {
+			prioChan := make(chan []Priority, 1)
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				prios := []Priority{RequestPriority(r)}
+				if err := UpdateResponsePriority(w, Priority{Urgency: 6}); err != nil {
+					prioChan <- nil
+					return
+				}
+				prioChan <- append(prios, RequestPriority(r))
+			})
+
+			exampleGetRequest.Header.Set("Priority", "u=1, i")
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				return len(p), nil
+			}).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+			// the response is written with the updated urgency
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 6})
+
+			Expect(s.handleRequest(newServerConn(conn), str, nil)).To(Equal(requestError{}))
+			Eventually(prioChan).Should(Receive(Equal([]Priority{
+				{Urgency: 1, Incremental: true},
+				{Urgency: 6},
+			})))
+		})
+
+		It("passes frames of registered types on the request stream to the handler", func() {
+			var mutex sync.Mutex
+			var payloads []string
+			Expect(s.RegisterFrameType(0x1337, func(c quic.Connection, id quic.StreamID, r io.Reader) error {
+				data, err := ioutil.ReadAll(r)
+				mutex.Lock()
+				payloads = append(payloads, fmt.Sprintf("%d: %s", id, data))
+				mutex.Unlock()
+				return err
+			})).To(Succeed())
+			bodyChan := make(chan []byte, 1)
+			s.Handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				bodyChan <- body
+			})
+
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, 0x1337)
+			quicvarint.Write(buf, 3)
+			buf.WriteString("foo")
+			buf.Write(encodeRequest(examplePostRequest))
+			quicvarint.Write(buf, 0x1337)
+			quicvarint.Write(buf, 3)
+			buf.WriteString("bar")
+			setRequest(buf.Bytes())
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				return len(p), nil
+			}).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			Expect(s.handleRequest(newServerConn(conn), str, nil)).To(Equal(requestError{}))
+			Eventually(bodyChan).Should(Receive(Equal([]byte("foobar"))))
+			mutex.Lock()
+			defer mutex.Unlock()
+			Expect(payloads).To(Equal([]string{"4: foo", "4: bar"}))
+		})
+
+		It("sends a 100 Continue response when the handler reads the body of an Expect: 100-continue request", func() {
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				body, err := ioutil.ReadAll(r.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(Equal([]byte("foobar")))
+			})
+
+			examplePostRequest.Header.Set("Expect", "100-continue")
+			responseBuf := &bytes.Buffer{}
+			setRequest(encodeRequest(examplePostRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			serr := s.handleRequest(newServerConn(conn), str, nil)
+			Expect(serr.err).ToNot(HaveOccurred())
+			Expect(decodeHeader(responseBuf)).To(HaveKeyWithValue(":status", []string{"100"}))
+			Expect(decodeHeader(responseBuf)).To(HaveKeyWithValue(":status", []string{"200"}))
+		})
+
+		It("doesn't send a 100 Continue response if the handler doesn't read the body", func() {
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusExpectationFailed)
+			})
+
+			examplePostRequest.Header.Set("Expect", "100-continue")
+			responseBuf := &bytes.Buffer{}
+			setRequest(encodeRequest(examplePostRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			serr := s.handleRequest(newServerConn(conn), str, nil)
+			Expect(serr.err).ToNot(HaveOccurred())
+			Expect(decodeHeader(responseBuf)).To(HaveKeyWithValue(":status", []string{"417"}))
+		})
+
+		It("responds with 431 if the request header list is too large", func() {
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Fail("Handler should not be called.")
+			})
+			s.MaxHeaderListSize = 100
+			sconn := newServerConn(conn)
+			sconn.decoder.maxHeaderListSize = s.MaxHeaderListSize
+			exampleGetRequest.Header.Set("foo", strings.Repeat("a", 100))
+			responseBuf := &bytes.Buffer{}
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+
+			str.EXPECT().Context().Return(reqContext)
+			serr := s.handleRequest(sconn, str, nil)
+			Expect(serr.err).ToNot(HaveOccurred())
+			Expect(decodeHeader(responseBuf)).To(HaveKeyWithValue(":status", []string{"431"}))
+		})
+
+		Context("header sections blocked on the QPACK encoder stream", func() {
+			var sconn *serverConn
+
+			BeforeEach(func() {
+				sconn = newServerConn(conn)
+				sconn.decoder.maxTableCapacity = 100
+				sconn.decoder.maxBlockedStreams = 1
+				// a header block referencing the first entry of the dynamic table, which is never inserted
+				headerBlock := []byte{2, 0, 0x80}
+				buf := &bytes.Buffer{}
+				(&headersFrame{Length: uint64(len(headerBlock))}).Write(buf)
+				buf.Write(headerBlock)
+				setRequest(buf.Bytes())
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+			})
+
+			It("fails the request when the connection is closed", func() {
+				str.EXPECT().Context().Return(reqContext)
+				errChan := make(chan requestError, 1)
+				go func() { errChan <- s.handleRequest(sconn, str, nil) }()
+				Consistently(errChan).ShouldNot(Receive())
+
+				connErr := &quic.ApplicationError{Remote: true, ErrorCode: quic.ApplicationErrorCode(errorNoError)}
+				conn.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, connErr)
+				s.handleUnidirectionalStreams(sconn)
+				var serr requestError
+				Eventually(errChan).Should(Receive(&serr))
+				Expect(serr.err).To(MatchError(connErr))
+				Expect(serr.streamErr).To(Equal(errorRequestIncomplete))
+			})
+
+			It("stops waiting when the ReadHeaderTimeout expires", func() {
+				s.Server.ReadHeaderTimeout = scaleDuration(50 * time.Millisecond)
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().SetReadDeadline(gomock.Any())
+				decoderStrBuf := &bytes.Buffer{}
+				decoderStr := mockquic.NewMockStream(mockCtrl)
+				decoderStr.EXPECT().Write(gomock.Any()).DoAndReturn(decoderStrBuf.Write)
+				conn.EXPECT().OpenUniStream().Return(decoderStr, nil)
+				start := time.Now()
+				serr := s.handleRequest(sconn, str, nil)
+				Expect(time.Since(start)).To(BeNumerically(">=", s.Server.ReadHeaderTimeout))
+				Expect(serr.err).To(MatchError(context.DeadlineExceeded))
+				Expect(serr.streamErr).To(Equal(errorRequestIncomplete))
+				// Stream Cancellation for stream 4
+				r := bytes.NewReader(decoderStrBuf.Bytes())
+				streamType, err := quicvarint.Read(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(streamType).To(BeEquivalentTo(streamTypeQPACKDecoderStream))
+				b, err := io.ReadAll(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal(appendPrefixedInt(nil, 0x40, 6, 4)))
+			})
+		})
+
+		It("uses the configured write buffer size", func() {
+			var bufSize int
+			var autoFlush bool
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bufSize = w.(*responseWriter).bufferedStream.Size()
+				autoFlush = w.(*responseWriter).autoFlush
+			})
+			s.WriteBufferSize = 1234
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			serr := s.handleRequest(newServerConn(conn), str, nil)
+			Expect(serr.err).ToNot(HaveOccurred())
+			Expect(bufSize).To(Equal(1234))
+			Expect(autoFlush).To(BeFalse())
+		})
+
+		It("doesn't buffer responses if the write buffer size is negative", func() {
+			var autoFlush bool
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				autoFlush = w.(*responseWriter).autoFlush
+			})
+			s.WriteBufferSize = -1
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			serr := s.handleRequest(newServerConn(conn), str, nil)
+			Expect(serr.err).ToNot(HaveOccurred())
+			Expect(autoFlush).To(BeTrue())
 		})
 
 		It("returns 200 with an empty handler", func() {
@@ -200,7 +496,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(newServerConn(conn), str, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -217,12 +513,78 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(newServerConn(conn), str, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"500"}))
 		})
 
+		Context("logging requests", func() {
+			It("logs requests", func() {
+				var log *RequestLog
+				s.LogRequest = func(l *RequestLog) { log = l }
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusTeapot)
+					w.Write([]byte("foobar"))
+				})
+
+				req, err := http.NewRequest(http.MethodGet, "https://www.example.com/foo?bar=baz", nil)
+				Expect(err).ToNot(HaveOccurred())
+				setRequest(encodeRequest(req))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().CancelRead(gomock.Any())
+
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).ToNot(HaveOccurred())
+				Expect(log).ToNot(BeNil())
+				Expect(log.Method).To(Equal(http.MethodGet))
+				Expect(log.Host).To(Equal("www.example.com"))
+				Expect(log.RequestURI).To(Equal("/foo?bar=baz"))
+				Expect(log.Status).To(Equal(http.StatusTeapot))
+				Expect(log.BytesWritten).To(BeEquivalentTo(6))
+				Expect(log.Duration).To(BeNumerically(">", 0))
+				Expect(log.StreamID).To(Equal(quic.StreamID(4)))
+				Expect(log.Connection).To(Equal(conn))
+				Expect(log.Err).ToNot(HaveOccurred())
+			})
+
+			It("logs panicking handlers", func() {
+				var log *RequestLog
+				s.LogRequest = func(l *RequestLog) { log = l }
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					panic("foobar")
+				})
+
+				setRequest(encodeRequest(exampleGetRequest))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().CancelRead(gomock.Any())
+
+				s.handleRequest(newServerConn(conn), str, nil)
+				Expect(log).ToNot(BeNil())
+				Expect(log.Status).To(Equal(http.StatusInternalServerError))
+				Expect(log.Err).To(MatchError(errHandlerPanicked))
+			})
+
+			It("logs errors that occur when sending the response", func() {
+				var log *RequestLog
+				s.LogRequest = func(l *RequestLog) { log = l }
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+				testErr := errors.New("stream reset")
+				setRequest(encodeRequest(exampleGetRequest))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).Return(0, testErr).AnyTimes()
+				str.EXPECT().CancelRead(gomock.Any())
+
+				s.handleRequest(newServerConn(conn), str, nil)
+				Expect(log).ToNot(BeNil())
+				Expect(log.Status).To(Equal(http.StatusOK))
+				Expect(log.Err).To(MatchError(testErr))
+			})
+		})
+
 		It("doesn't close the stream if the handler called DataStream()", func() {
 			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				str := w.(DataStreamer).DataStream()
@@ -234,8 +596,68 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write([]byte("foobar"))
 			// don't EXPECT CancelRead()
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
-			Expect(serr.err).ToNot(HaveOccurred())
+			serr := s.handleRequest(newServerConn(conn), str, nil)
+			Expect(serr.err).To(Equal(errHijacked))
+		})
+
+		Context("timeouts", func() {
+			It("sets the read deadline for reading the request headers", func() {
+				s.Server.ReadHeaderTimeout = 5 * time.Second
+				handlerCalled := make(chan struct{})
+				s.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) { close(handlerCalled) })
+
+				setRequest(encodeRequest(exampleGetRequest))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().CancelRead(gomock.Any())
+				gomock.InOrder(
+					str.EXPECT().SetReadDeadline(gomock.Any()).Do(func(t time.Time) {
+						Expect(t).To(BeTemporally("~", time.Now().Add(5*time.Second), scaleDuration(100*time.Millisecond)))
+					}),
+					str.EXPECT().SetReadDeadline(time.Time{}),
+				)
+
+				Expect(s.handleRequest(newServerConn(conn), str, nil)).To(Equal(requestError{}))
+				Eventually(handlerCalled).Should(BeClosed())
+			})
+
+			It("sets the read and the write deadline", func() {
+				s.Server.ReadTimeout = 5 * time.Second
+				s.Server.WriteTimeout = 3 * time.Second
+				handlerCalled := make(chan struct{})
+				s.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) { close(handlerCalled) })
+
+				setRequest(encodeRequest(exampleGetRequest))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().CancelRead(gomock.Any())
+				str.EXPECT().SetReadDeadline(gomock.Any()).Do(func(t time.Time) {
+					Expect(t).To(BeTemporally("~", time.Now().Add(5*time.Second), scaleDuration(100*time.Millisecond)))
+				}).Times(2)
+				str.EXPECT().SetWriteDeadline(gomock.Any()).Do(func(t time.Time) {
+					Expect(t).To(BeTemporally("~", time.Now().Add(3*time.Second), scaleDuration(100*time.Millisecond)))
+				})
+
+				Expect(s.handleRequest(newServerConn(conn), str, nil)).To(Equal(requestError{}))
+				Eventually(handlerCalled).Should(BeClosed())
+			})
+
+			It("clears the deadlines when the handler takes over the stream", func() {
+				s.Server.WriteTimeout = 3 * time.Second
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.(DataStreamer).DataStream()
+				})
+
+				setRequest(encodeRequest(exampleGetRequest))
+				str.EXPECT().Context().Return(reqContext)
+				gomock.InOrder(
+					str.EXPECT().SetWriteDeadline(gomock.Any()),
+					str.EXPECT().SetDeadline(time.Time{}),
+				)
+
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).To(Equal(errHijacked))
+			})
 		})
 
 		Context("control stream handling", func() {
@@ -271,6 +693,60 @@ var _ = Describe("Server", func() {
 				time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to conn.CloseWithError
 			})
 
+			It("calls the SETTINGS handler", func() {
+				settingsChan := make(chan *Settings, 1)
+				s.SettingsHandler = func(c quic.Connection, settings *Settings) error {
+					defer GinkgoRecover()
+					Expect(c).To(Equal(conn))
+					settingsChan <- settings
+					return nil
+				}
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{Other: map[uint64]uint64{0x1337: 42}}).Write(buf)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				s.handleConn(conn)
+				var settings *Settings
+				Eventually(settingsChan).Should(Receive(&settings))
+				Expect(settings.Other).To(Equal(map[uint64]uint64{0x1337: 42}))
+				time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to conn.CloseWithError
+			})
+
+			It("closes the connection when the SETTINGS handler errors", func() {
+				s.SettingsHandler = func(quic.Connection, *Settings) error {
+					return errors.New("missing extension")
+				}
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				done := make(chan struct{})
+				conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, reason string) {
+					defer GinkgoRecover()
+					Expect(code).To(BeEquivalentTo(errorSettingsError))
+					Expect(reason).To(Equal("missing extension"))
+					close(done)
+				})
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+			})
+
 			for _, t := range []uint64{streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream} {
 				streamType := t
 				name := "encoder"
@@ -317,6 +793,67 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("passes streams of registered types to the handler", func() {
+				handled := make(chan []byte, 1)
+				Expect(s.RegisterUniStreamType(0x1337, UniStreamHandler{
+					HandleStream: func(c quic.Connection, str quic.ReceiveStream) {
+						defer GinkgoRecover()
+						Expect(c).To(Equal(conn))
+						data, err := io.ReadAll(str)
+						Expect(err).ToNot(HaveOccurred())
+						handled <- data
+					},
+				})).To(Succeed())
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, 0x1337)
+				buf.WriteString("foobar")
+				str := mockquic.NewMockStream(mockCtrl)
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				done := make(chan struct{})
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError)).Do(func(quic.StreamErrorCode) { close(done) })
+
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return str, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				s.handleConn(conn)
+				Eventually(handled).Should(Receive(Equal([]byte("foobar"))))
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("closes the connection when a unique stream of a registered type is opened twice", func() {
+				Expect(s.RegisterUniStreamType(0x1337, UniStreamHandler{
+					HandleStream: func(quic.Connection, quic.ReceiveStream) {},
+					Unique:       true,
+				})).To(Succeed())
+				newStream := func() *mockquic.MockStream {
+					buf := &bytes.Buffer{}
+					quicvarint.Write(buf, 0x1337)
+					str := mockquic.NewMockStream(mockCtrl)
+					str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+					// the streams are handled concurrently, so either one of them is the duplicate
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError)).MaxTimes(1)
+					return str
+				}
+				str1 := newStream()
+				str2 := newStream()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).Return(str1, nil)
+				conn.EXPECT().AcceptUniStream(gomock.Any()).Return(str2, nil)
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				done := make(chan struct{})
+				conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorStreamCreationError), "duplicate stream of type 0x1337").Do(func(quic.ApplicationErrorCode, string) {
+					close(done)
+				})
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+			})
+
 			It("errors when the first frame on the control stream is not a SETTINGS frame", func() {
 				buf := &bytes.Buffer{}
 				quicvarint.Write(buf, streamTypeControlStream)
@@ -365,12 +902,285 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("errors when the client reduces the maximum push ID", func() {
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				(&maxPushIDFrame{PushID: 10}).Write(buf)
+				(&maxPushIDFrame{PushID: 5}).Write(buf)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				done := make(chan struct{})
+				conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, _ string) {
+					defer GinkgoRecover()
+					Expect(code).To(BeEquivalentTo(errorIDError))
+					close(done)
+				})
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("errors when the client increases the push ID in a GOAWAY frame", func() {
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				(&goAwayFrame{StreamID: 5}).Write(buf)
+				(&goAwayFrame{StreamID: 10}).Write(buf)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				done := make(chan struct{})
+				conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, _ string) {
+					defer GinkgoRecover()
+					Expect(code).To(BeEquivalentTo(errorIDError))
+					close(done)
+				})
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("errors when the client sends an unexpected frame on the control stream", func() {
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				(&dataFrame{}).Write(buf)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				done := make(chan struct{})
+				conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, _ string) {
+					defer GinkgoRecover()
+					Expect(code).To(BeEquivalentTo(errorFrameUnexpected))
+					close(done)
+				})
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+			})
+
+			Context("strict mode", func() {
+				var violations chan *ProtocolViolation
+
+				BeforeEach(func() {
+					v := make(chan *ProtocolViolation, 1)
+					violations = v
+					s.Strict = true
+					s.ProtocolViolationHandler = func(_ quic.Connection, violation *ProtocolViolation) { v <- violation }
+				})
+
+				// newUniStream returns a stream that returns the data, and then blocks until the test is done
+				newUniStream := func(id quic.StreamID, data []byte) *mockquic.MockStream {
+					buf := bytes.NewBuffer(data)
+					str := mockquic.NewMockStream(mockCtrl)
+					str.EXPECT().StreamID().Return(id).AnyTimes()
+					str.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+						if buf.Len() == 0 {
+							<-testDone
+							return 0, errors.New("test done")
+						}
+						return buf.Read(p)
+					}).AnyTimes()
+					return str
+				}
+
+				controlStreamData := func() []byte {
+					buf := &bytes.Buffer{}
+					quicvarint.Write(buf, streamTypeControlStream)
+					(&settingsFrame{}).Write(buf)
+					return buf.Bytes()
+				}
+
+				It("errors when the client opens a second control stream", func() {
+					str1 := newUniStream(2, controlStreamData())
+					str2 := newUniStream(6, controlStreamData())
+					conn.EXPECT().AcceptUniStream(gomock.Any()).Return(str1, nil)
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						time.Sleep(scaleDuration(10 * time.Millisecond)) // make sure the first control stream is processed first
+						return str2, nil
+					})
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						<-testDone
+						return nil, errors.New("test done")
+					})
+					conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorStreamCreationError), gomock.Any())
+					s.handleConn(conn)
+					var violation *ProtocolViolation
+					Eventually(violations).Should(Receive(&violation))
+					Expect(violation.ErrorCode).To(BeEquivalentTo(errorStreamCreationError))
+					testDone <- struct{}{} // release the blocked Read call
+				})
+
+				It("errors when the client closes the control stream", func() {
+					buf := bytes.NewBuffer(controlStreamData())
+					controlStr := mockquic.NewMockStream(mockCtrl)
+					controlStr.EXPECT().StreamID().Return(quic.StreamID(2)).AnyTimes()
+					controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+					conn.EXPECT().AcceptUniStream(gomock.Any()).Return(controlStr, nil)
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						<-testDone
+						return nil, errors.New("test done")
+					})
+					conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorClosedCriticalStream), gomock.Any())
+					s.handleConn(conn)
+					var violation *ProtocolViolation
+					Eventually(violations).Should(Receive(&violation))
+					Expect(violation.ErrorCode).To(BeEquivalentTo(errorClosedCriticalStream))
+				})
+
+				It("errors when the client closes a QPACK stream", func() {
+					buf := &bytes.Buffer{}
+					quicvarint.Write(buf, streamTypeQPACKEncoderStream)
+					str := mockquic.NewMockStream(mockCtrl)
+					str.EXPECT().StreamID().Return(quic.StreamID(2)).AnyTimes()
+					str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+					conn.EXPECT().AcceptUniStream(gomock.Any()).Return(str, nil)
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						<-testDone
+						return nil, errors.New("test done")
+					})
+					conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorClosedCriticalStream), gomock.Any())
+					s.handleConn(conn)
+					Eventually(violations).Should(Receive())
+				})
+
+				It("errors when the client sends a frame type reserved for HTTP/2 on the control stream", func() {
+					data := controlStreamData()
+					data = append(data, 0x8, 0x1, 0x0) // HTTP/2 WINDOW_UPDATE frame
+					controlStr := newUniStream(2, data)
+					conn.EXPECT().AcceptUniStream(gomock.Any()).Return(controlStr, nil)
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						<-testDone
+						return nil, errors.New("test done")
+					})
+					conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorFrameUnexpected), gomock.Any())
+					s.handleConn(conn)
+					var violation *ProtocolViolation
+					Eventually(violations).Should(Receive(&violation))
+					Expect(violation.ErrorCode).To(BeEquivalentTo(errorFrameUnexpected))
+					Expect(violation.Reason).To(Equal(errReservedFrameType.Error()))
+				})
+
+				It("doesn't report anything for a well-behaved client", func() {
+					controlStr := newUniStream(2, controlStreamData())
+					conn.EXPECT().AcceptUniStream(gomock.Any()).Return(controlStr, nil)
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						<-testDone
+						return nil, errors.New("test done")
+					})
+					s.handleConn(conn)
+					Consistently(violations, scaleDuration(20*time.Millisecond)).ShouldNot(Receive())
+					testDone <- struct{}{} // release the blocked Read call
+				})
+			})
+
 			It("errors when the client opens a push stream", func() {
 				buf := &bytes.Buffer{}
-				quicvarint.Write(buf, streamTypePushStream)
-				(&dataFrame{}).Write(buf)
+				quicvarint.Write(buf, streamTypePushStream)
+				(&dataFrame{}).Write(buf)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				done := make(chan struct{})
+				conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, _ string) {
+					defer GinkgoRecover()
+					Expect(code).To(BeEquivalentTo(errorStreamCreationError))
+					close(done)
+				})
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("errors when receiving a PRIORITY_UPDATE frame for an invalid stream", func() {
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				(&priorityUpdateFrame{ElementID: 3, PriorityFieldValue: "u=1"}).Write(buf) // server-initiated unidirectional stream
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				done := make(chan struct{})
+				conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, _ string) {
+					defer GinkgoRecover()
+					Expect(code).To(BeEquivalentTo(errorIDError))
+					close(done)
+				})
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("passes frames of registered types on the control stream to the handler", func() {
+				handled := make(chan string, 1)
+				Expect(s.RegisterFrameType(0x1337, func(c quic.Connection, id quic.StreamID, r io.Reader) error {
+					defer GinkgoRecover()
+					Expect(c).To(Equal(conn))
+					Expect(id).To(Equal(quic.StreamID(2)))
+					data, err := io.ReadAll(r)
+					Expect(err).ToNot(HaveOccurred())
+					handled <- string(data)
+					return nil
+				})).To(Succeed())
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				quicvarint.Write(buf, 0x1337)
+				quicvarint.Write(buf, 6)
+				buf.WriteString("foobar")
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				controlStr.EXPECT().StreamID().Return(quic.StreamID(2)).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				s.handleConn(conn)
+				Eventually(handled).Should(Receive(Equal("foobar")))
+			})
+
+			It("closes the connection when the handler for a registered frame type errors", func() {
+				Expect(s.RegisterFrameType(0x1337, func(quic.Connection, quic.StreamID, io.Reader) error {
+					return errors.New("invalid frame")
+				})).To(Succeed())
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				quicvarint.Write(buf, 0x1337)
+				quicvarint.Write(buf, 0)
 				controlStr := mockquic.NewMockStream(mockCtrl)
 				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				controlStr.EXPECT().StreamID().Return(quic.StreamID(2)).AnyTimes()
 				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
 					return controlStr, nil
 				})
@@ -379,9 +1189,7 @@ var _ = Describe("Server", func() {
 					return nil, errors.New("test done")
 				})
 				done := make(chan struct{})
-				conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ApplicationErrorCode, _ string) {
-					defer GinkgoRecover()
-					Expect(code).To(BeEquivalentTo(errorStreamCreationError))
+				conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorFrameError), "invalid frame").Do(func(quic.ApplicationErrorCode, string) {
 					close(done)
 				})
 				s.handleConn(conn)
@@ -415,14 +1223,87 @@ var _ = Describe("Server", func() {
 			})
 		})
 
+		Context("sending the SETTINGS frame", func() {
+			var (
+				conn *mockquic.MockEarlyConnection
+				sent chan []byte
+			)
+			testDone := make(chan struct{})
+
+			BeforeEach(func() {
+				conn = mockquic.NewMockEarlyConnection(mockCtrl)
+				sent = make(chan []byte, 1)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					sent <- b
+					return len(b), nil
+				})
+				conn.EXPECT().OpenUniStream().Return(controlStr, nil)
+				conn.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				conn.EXPECT().RemoteAddr().Return(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}).AnyTimes()
+				conn.EXPECT().LocalAddr().AnyTimes()
+			})
+
+			AfterEach(func() { testDone <- struct{}{} })
+
+			getSettings := func() *settingsFrame {
+				s.handleConn(conn)
+				var b []byte
+				Eventually(sent).Should(Receive(&b))
+				r := bytes.NewReader(b)
+				streamType, err := quicvarint.Read(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(streamType).To(BeEquivalentTo(streamTypeControlStream))
+				f, err := parseNextFrame(r, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+				return f.(*settingsFrame)
+			}
+
+			It("enables Extended CONNECT", func() {
+				Expect(getSettings().ExtendedConnect).To(BeTrue())
+			})
+
+			It("disables Extended CONNECT", func() {
+				s.DisableExtendedConnect = true
+				Expect(getSettings().ExtendedConnect).To(BeFalse())
+			})
+
+			It("doesn't disable Extended CONNECT when using WebTransport", func() {
+				s.DisableExtendedConnect = true
+				s.EnableWebTransport = true
+				Expect(getSettings().ExtendedConnect).To(BeTrue())
+			})
+
+			It("ignores SETTINGS_ENABLE_CONNECT_PROTOCOL in the additional settings", func() {
+				s.DisableExtendedConnect = true
+				s.AdditionalSettings = map[uint64]uint64{settingExtendedConnect: 1, 0x1337: 42}
+				settings := getSettings()
+				Expect(settings.ExtendedConnect).To(BeFalse())
+				Expect(settings.Other).To(Equal(map[uint64]uint64{0x1337: 42}))
+				Expect(s.AdditionalSettings).To(HaveKey(uint64(settingExtendedConnect))) // the map is not modified
+			})
+		})
+
 		Context("stream- and connection-level errors", func() {
-			var conn *mockquic.MockEarlyConnection
+			var (
+				conn         *mockquic.MockEarlyConnection
+				handshakeCtx context.Context // returned by conn.HandshakeComplete()
+			)
 			testDone := make(chan struct{})
 
 			BeforeEach(func() {
 				testDone = make(chan struct{})
 				addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
 				conn = mockquic.NewMockEarlyConnection(mockCtrl)
+				var cancel context.CancelFunc
+				handshakeCtx, cancel = context.WithCancel(context.Background())
+				cancel()
+				conn.EXPECT().HandshakeComplete().DoAndReturn(func() context.Context { return handshakeCtx }).AnyTimes()
 				controlStr := mockquic.NewMockStream(mockCtrl)
 				controlStr.EXPECT().Write(gomock.Any())
 				conn.EXPECT().OpenUniStream().Return(controlStr, nil)
@@ -438,6 +1319,67 @@ var _ = Describe("Server", func() {
 
 			AfterEach(func() { testDone <- struct{}{} })
 
+			It("calls the ConnContext and the ConnState callbacks", func() {
+				type ctxKey struct{}
+				s.ConnContext = func(ctx context.Context, c quic.Connection) context.Context {
+					Expect(c).To(Equal(conn))
+					Expect(ctx.Value(ServerContextKey)).To(Equal(s))
+					return context.WithValue(ctx, ctxKey{}, "foobar")
+				}
+				var mutex sync.Mutex
+				var states []http.ConnState
+				s.ConnState = func(c quic.Connection, state http.ConnState) {
+					Expect(c).To(Equal(conn))
+					mutex.Lock()
+					states = append(states, state)
+					mutex.Unlock()
+				}
+				getStates := func() []http.ConnState {
+					mutex.Lock()
+					defer mutex.Unlock()
+					return append([]http.ConnState{}, states...)
+				}
+				valueChan := make(chan interface{}, 1)
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					valueChan <- r.Context().Value(ctxKey{})
+				})
+
+				setRequest(encodeRequest(exampleGetRequest))
+				done := make(chan struct{})
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+				str.EXPECT().Close().Do(func() { close(done) })
+
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+				Eventually(valueChan).Should(Receive(Equal("foobar")))
+				Expect(getStates()[:2]).To(Equal([]http.ConnState{http.StateNew, http.StateActive}))
+				Eventually(func() http.ConnState {
+					states := getStates()
+					return states[len(states)-1]
+				}).Should(Equal(http.StateClosed))
+			})
+
+			It("marks requests received before completion of the handshake", func() {
+				handshakeCtx = context.Background() // the handshake is not complete
+				earlyChan := make(chan bool, 1)
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					earlyChan <- RequestEarlyData(r)
+				})
+
+				setRequest(encodeRequest(exampleGetRequest))
+				done := make(chan struct{})
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+				str.EXPECT().Close().Do(func() { close(done) })
+
+				s.handleConn(conn)
+				Eventually(done).Should(BeClosed())
+				Expect(earlyChan).To(Receive(BeTrue()))
+			})
+
 			It("cancels reading when client sends a body in GET request", func() {
 				handlerCalled := make(chan struct{})
 				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -560,11 +1502,79 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(newServerConn(conn), str, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
 
+		Context("limiting the request body size", func() {
+			It("rejects requests announcing a too large body", func() {
+				s.MaxRequestBodySize = 5
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+
+				setRequest(encodeRequest(examplePostRequest))
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorRequestRejected))
+				str.EXPECT().Context().Return(reqContext)
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).To(MatchError("request body too large: 6 bytes (max: 5)"))
+				Expect(serr.streamErr).To(Equal(errorRequestRejected))
+			})
+
+			It("resets the stream when the body exceeds the limit", func() {
+				s.MaxRequestBodySize = 5
+				handlerCalled := make(chan struct{})
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					defer close(handlerCalled)
+					data, err := io.ReadAll(r.Body)
+					Expect(err).To(MatchError(errRequestBodyTooLarge))
+					Expect(data).To(Equal([]byte("fooba")))
+				})
+
+				req, err := http.NewRequest(http.MethodPost, "https://www.example.com", io.MultiReader(bytes.NewReader([]byte("foobar"))))
+				Expect(err).ToNot(HaveOccurred())
+				setRequest(encodeRequest(req))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					return len(p), nil
+				}).AnyTimes()
+				gomock.InOrder(
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorRequestRejected)),
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError)),
+				)
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(errorRequestRejected))
+
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).ToNot(HaveOccurred())
+				Eventually(handlerCalled).Should(BeClosed())
+			})
+
+			It("allows bodies up to the limit", func() {
+				s.MaxRequestBodySize = 6
+				handlerCalled := make(chan struct{})
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					defer close(handlerCalled)
+					data, err := io.ReadAll(r.Body)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(data).To(Equal([]byte("foobar")))
+				})
+
+				setRequest(encodeRequest(examplePostRequest))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					return len(p), nil
+				}).AnyTimes()
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).ToNot(HaveOccurred())
+				Eventually(handlerCalled).Should(BeClosed())
+			})
+		})
+
 		It("cancels the request context when the stream is closed", func() {
 			handlerCalled := make(chan struct{})
 			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -583,10 +1593,187 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(newServerConn(conn), str, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
+
+		Context("malformed requests", func() {
+			It("rejects requests with connection-specific header fields", func() {
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+				setRequest(encodeHeaderFields([]qpack.HeaderField{
+					{Name: ":method", Value: http.MethodGet},
+					{Name: ":scheme", Value: "https"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: ":path", Value: "/"},
+					{Name: "transfer-encoding", Value: "chunked"},
+				}))
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().Context().Return(reqContext)
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).To(MatchError("connection-specific header field: transfer-encoding"))
+				Expect(serr.streamErr).To(Equal(errorMessageError))
+			})
+
+			It("rejects requests with pseudo-header fields after regular header fields", func() {
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+				setRequest(encodeHeaderFields([]qpack.HeaderField{
+					{Name: ":method", Value: http.MethodGet},
+					{Name: ":scheme", Value: "https"},
+					{Name: "foo", Value: "bar"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: ":path", Value: "/"},
+				}))
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().Context().Return(reqContext)
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).To(MatchError("pseudo-header field :authority after regular header fields"))
+				Expect(serr.streamErr).To(Equal(errorMessageError))
+			})
+
+			It("rejects Extended CONNECT requests, if Extended CONNECT is disabled", func() {
+				s.DisableExtendedConnect = true
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+				setRequest(encodeHeaderFields([]qpack.HeaderField{
+					{Name: ":method", Value: http.MethodConnect},
+					{Name: ":protocol", Value: "websocket"},
+					{Name: ":scheme", Value: "https"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: ":path", Value: "/chat"},
+				}))
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().Context().Return(reqContext)
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).To(MatchError("received Extended CONNECT request, but Extended CONNECT is disabled"))
+				Expect(serr.streamErr).To(Equal(errorMessageError))
+			})
+
+			It("rejects requests that can't be parsed", func() {
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+				setRequest(encodeHeaderFields([]qpack.HeaderField{
+					{Name: ":method", Value: http.MethodGet},
+					{Name: ":scheme", Value: "https"},
+					{Name: ":authority", Value: "www.example.com"},
+				}))
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().Context().Return(reqContext)
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).To(HaveOccurred())
+				Expect(serr.streamErr).To(Equal(errorMessageError))
+			})
+
+			It("resets the stream if the body doesn't match the Content-Length", func() {
+				handlerCalled := make(chan struct{})
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					defer close(handlerCalled)
+					Expect(r.ContentLength).To(BeEquivalentTo(10))
+					data, err := io.ReadAll(r.Body)
+					Expect(err).To(MatchError("http3: body doesn't match the Content-Length of 10 bytes"))
+					Expect(data).To(Equal([]byte("foobar")))
+				})
+				buf := bytes.NewBuffer(encodeHeaderFields([]qpack.HeaderField{
+					{Name: ":method", Value: http.MethodPost},
+					{Name: ":scheme", Value: "https"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: ":path", Value: "/"},
+					{Name: "content-length", Value: "10"},
+				}))
+				(&dataFrame{Length: 6}).Write(buf)
+				buf.WriteString("foobar")
+				setRequest(buf.Bytes())
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					return len(p), nil
+				}).AnyTimes()
+				gomock.InOrder(
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError)),
+					str.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError)),
+				)
+
+				serr := s.handleRequest(newServerConn(conn), str, nil)
+				Expect(serr.err).ToNot(HaveOccurred())
+				Eventually(handlerCalled).Should(BeClosed())
+			})
+		})
+
+		Context("strict mode", func() {
+			var (
+				sconn      *serverConn
+				violations chan *ProtocolViolation
+			)
+
+			BeforeEach(func() {
+				v := make(chan *ProtocolViolation, 1)
+				violations = v
+				sconn = newServerConn(conn)
+				sconn.strict = newStrictMode(true, func(c quic.Connection, violation *ProtocolViolation) {
+					Expect(c).To(Equal(sconn))
+					v <- violation
+				})
+			})
+
+			It("rejects malformed requests", func() {
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+				setRequest(encodeHeaderFields([]qpack.HeaderField{
+					{Name: ":method", Value: http.MethodGet},
+					{Name: ":scheme", Value: "https"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: ":path", Value: "/"},
+					{Name: "connection", Value: "close"},
+				}))
+				str.EXPECT().CancelRead(quic.StreamErrorCode(errorMessageError))
+				str.EXPECT().Context().Return(reqContext)
+				serr := s.handleRequest(sconn, str, nil)
+				Expect(serr.err).To(MatchError("connection-specific header field: connection"))
+				Expect(serr.streamErr).To(Equal(errorMessageError))
+				var violation *ProtocolViolation
+				Expect(violations).To(Receive(&violation))
+				Expect(violation.ErrorCode).To(BeEquivalentTo(errorMessageError))
+				Expect(violation.StreamID).To(Equal(quic.StreamID(4)))
+				Expect(violation.IsStreamError).To(BeTrue())
+			})
+
+			It("rejects frame types reserved for HTTP/2", func() {
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, 0x6) // HTTP/2 PING frame
+				quicvarint.Write(buf, 0)
+				buf.Write(encodeRequest(exampleGetRequest))
+				setRequest(buf.Bytes())
+				serr := s.handleRequest(sconn, str, nil)
+				Expect(serr.err).To(MatchError(errReservedFrameType))
+				Expect(serr.connErr).To(Equal(errorFrameUnexpected))
+				var violation *ProtocolViolation
+				Expect(violations).To(Receive(&violation))
+				Expect(violation.ErrorCode).To(BeEquivalentTo(errorFrameUnexpected))
+				Expect(violation.IsStreamError).To(BeFalse())
+			})
+
+			It("accepts well-formed requests", func() {
+				handlerCalled := make(chan struct{})
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { close(handlerCalled) })
+				setRequest(encodeRequest(exampleGetRequest))
+				str.EXPECT().Context().Return(reqContext)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+				str.EXPECT().CancelRead(gomock.Any())
+				Expect(s.handleRequest(sconn, str, nil)).To(Equal(requestError{}))
+				Eventually(handlerCalled).Should(BeClosed())
+				Expect(violations).ToNot(Receive())
+			})
+		})
 	})
 
 	Context("setting http headers", func() {
@@ -689,6 +1876,58 @@ var _ = Describe("Server", func() {
 			removeListener(&ln2)
 			checkSetHeaderError()
 		})
+
+		It("uses the configured max age", func() {
+			s.AltSvcMaxAge = time.Hour
+			addListener(":443", &ln1)
+			checkSetHeaders(Equal(http.Header{"Alt-Svc": {`h3-29=":443"; ma=3600`}}))
+			removeListener(&ln1)
+		})
+
+		It("only announces the configured versions", func() {
+			s.QuicConfig.Versions = []quic.VersionNumber{quic.Version1, quic.VersionDraft29}
+			s.AltSvcVersions = []quic.VersionNumber{quic.Version1}
+			addListener(":443", &ln1)
+			checkSetHeaders(Equal(http.Header{"Alt-Svc": {`h3=":443"; ma=2592000`}}))
+			removeListener(&ln1)
+		})
+
+		It("announces the configured authorities", func() {
+			s.QuicConfig.Versions = []quic.VersionNumber{quic.Version1, quic.VersionDraft29}
+			s.AltSvcAuthorities = []string{":8443", "alt.example.org:443"}
+			s.Port = 1234 // ignored
+			addListener(":443", &ln1)
+			checkSetHeaders(Equal(http.Header{"Alt-Svc": {
+				`h3=":8443"; ma=2592000,h3-29=":8443"; ma=2592000,h3="alt.example.org:443"; ma=2592000,h3-29="alt.example.org:443"; ma=2592000`,
+			}}))
+			removeListener(&ln1)
+		})
+
+		It("announces the configured authorities if the server is not listening", func() {
+			s.AltSvcAuthorities = []string{"alt.example.org:443"}
+			checkSetHeaders(Equal(http.Header{"Alt-Svc": {`h3-29="alt.example.org:443"; ma=2592000`}}))
+		})
+
+		It("injects the header into responses", func() {
+			addListener(":443", &ln1)
+			var called bool
+			handler := s.AltSvcHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusTeapot)
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			Expect(called).To(BeTrue())
+			Expect(rec.Code).To(Equal(http.StatusTeapot))
+			Expect(rec.Header()).To(Equal(expected))
+			removeListener(&ln1)
+
+			// responses are still sent if the server is not listening
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			Expect(rec.Code).To(Equal(http.StatusTeapot))
+			Expect(rec.Header()).ToNot(HaveKey("Alt-Svc"))
+		})
 	})
 
 	It("errors when ListenAndServe is called with s.Server nil", func() {
@@ -946,6 +2185,20 @@ var _ = Describe("Server", func() {
 			Expect(receivedConf).To(Equal(conf))
 		})
 
+		It("sets the stream limit to the maximum number of concurrent requests", func() {
+			s.QuicConfig = &quic.Config{MaxIncomingStreams: 1000}
+			s.MaxConcurrentRequests = 10
+			var receivedConf *quic.Config
+			quicListenAddr = func(addr string, _ *tls.Config, config *quic.Config) (quic.EarlyListener, error) {
+				receivedConf = config
+				return nil, errors.New("listen err")
+			}
+			Expect(s.ListenAndServe()).To(HaveOccurred())
+			Expect(receivedConf.MaxIncomingStreams).To(BeEquivalentTo(10))
+			// make sure the original quic.Config was not modified
+			Expect(s.QuicConfig.MaxIncomingStreams).To(BeEquivalentTo(1000))
+		})
+
 		It("sets the GetConfigForClient and replaces the ALPN token to the tls.Config, if the GetConfigForClient callback is not set", func() {
 			tlsConf := &tls.Config{
 				ClientAuth: tls.RequireAndVerifyClientCert,
@@ -1045,12 +2298,273 @@ var _ = Describe("Server", func() {
 			Expect(conf).ToNot(BeNil())
 			checkGetConfigForClientVersions(receivedConf)
 		})
+
+		Context("listening on multiple addresses", func() {
+			acceptUntilClosed := func(ln *mockAddrListener) {
+				closed := make(chan struct{})
+				var once sync.Once
+				ln.EXPECT().Accept(gomock.Any()).DoAndReturn(func(context.Context) (quic.Connection, error) {
+					<-closed
+					return nil, errors.New("closed")
+				})
+				ln.EXPECT().Close().Do(func() { once.Do(func() { close(closed) }) }).MinTimes(1)
+			}
+
+			It("listens on all addresses", func() {
+				s.AdditionalAddrs = []string{"localhost:8443"}
+				ln1 := newMockAddrListener(":443")
+				ln2 := newMockAddrListener(":8443")
+				ln1.EXPECT().Addr().AnyTimes() // generate alt-svc headers
+				ln2.EXPECT().Addr().AnyTimes()
+				acceptUntilClosed(ln1)
+				acceptUntilClosed(ln2)
+				var addrs []string
+				quicListenAddr = func(addr string, _ *tls.Config, _ *quic.Config) (quic.EarlyListener, error) {
+					addrs = append(addrs, addr)
+					if addr == "localhost:8443" {
+						return ln2, nil
+					}
+					return ln1, nil
+				}
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					Expect(s.ListenAndServe()).To(MatchError("closed"))
+				}()
+
+				Eventually(func() error {
+					hdr := http.Header{}
+					if err := s.SetQuicHeaders(hdr); err != nil {
+						return err
+					}
+					if altSvc := hdr.Get("Alt-Svc"); !strings.Contains(altSvc, ":443") || !strings.Contains(altSvc, ":8443") {
+						return fmt.Errorf("unexpected Alt-Svc header: %s", altSvc)
+					}
+					return nil
+				}).Should(Succeed())
+				Consistently(done).ShouldNot(BeClosed())
+				Expect(addrs).To(Equal([]string{"localhost:0", "localhost:8443"}))
+				Expect(s.Close()).To(Succeed())
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("closes the listeners if listening on one of the addresses fails", func() {
+				s.AdditionalAddrs = []string{"localhost:8443"}
+				ln := newMockAddrListener(":443")
+				closed := make(chan struct{})
+				ln.EXPECT().Close().Do(func() { close(closed) })
+				quicListenAddr = func(addr string, _ *tls.Config, _ *quic.Config) (quic.EarlyListener, error) {
+					if addr == "localhost:8443" {
+						return nil, errors.New("listen err")
+					}
+					return ln, nil
+				}
+				Expect(s.ListenAndServe()).To(MatchError("listen err"))
+				Expect(closed).To(BeClosed())
+			})
+
+			It("closes the other listeners if serving one of them fails", func() {
+				s.AdditionalAddrs = []string{"localhost:8443"}
+				ln1 := newMockAddrListener(":443")
+				ln2 := newMockAddrListener(":8443")
+				ln1.EXPECT().Addr().AnyTimes()
+				ln2.EXPECT().Addr().AnyTimes()
+				acceptUntilClosed(ln1)
+				ln2.EXPECT().Accept(gomock.Any()).Return(nil, errors.New("accept err"))
+				ln2.EXPECT().Close().AnyTimes()
+				quicListenAddr = func(addr string, _ *tls.Config, _ *quic.Config) (quic.EarlyListener, error) {
+					if addr == "localhost:8443" {
+						return ln2, nil
+					}
+					return ln1, nil
+				}
+				Expect(s.ListenAndServe()).To(MatchError("accept err"))
+			})
+		})
 	})
 
 	It("closes gracefully", func() {
 		Expect(s.CloseGracefully(0)).To(Succeed())
 	})
 
+	Context("closing idle connections", func() {
+		var (
+			conn  *mockquic.MockEarlyConnection
+			sconn *serverConn
+		)
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			sconn = newServerConn(conn)
+		})
+
+		AfterEach(func() { sconn.stopIdleTimer() })
+
+		It("closes the connection when no requests are active", func() {
+			closed := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), "").Do(func(quic.ApplicationErrorCode, string) { close(closed) })
+			sconn.startIdleTimer(scaleDuration(20 * time.Millisecond))
+			Eventually(closed).Should(BeClosed())
+		})
+
+		It("waits for active requests to complete", func() {
+			Expect(sconn.acceptRequest(0)).To(BeTrue())
+			sconn.startIdleTimer(scaleDuration(20 * time.Millisecond))
+			Expect(sconn.acceptRequest(4)).To(BeTrue())
+			time.Sleep(scaleDuration(50 * time.Millisecond))
+			sconn.requestDone()
+			time.Sleep(scaleDuration(50 * time.Millisecond))
+			closed := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), "").Do(func(quic.ApplicationErrorCode, string) { close(closed) })
+			sconn.requestDone()
+			Eventually(closed).Should(BeClosed())
+		})
+
+		It("doesn't close connections with hijacked streams", func() {
+			Expect(sconn.acceptRequest(0)).To(BeTrue())
+			sconn.startIdleTimer(scaleDuration(20 * time.Millisecond))
+			sconn.streamHijacked()
+			sconn.requestDone()
+			// don't EXPECT any calls to CloseWithError
+			time.Sleep(scaleDuration(50 * time.Millisecond))
+		})
+	})
+
+	It("reports when a connection becomes active and idle", func() {
+		var states []http.ConnState
+		sconn := newServerConn(mockquic.NewMockEarlyConnection(mockCtrl))
+		sconn.connState = func(state http.ConnState) { states = append(states, state) }
+		Expect(sconn.acceptRequest(0)).To(BeTrue())
+		Expect(sconn.acceptRequest(4)).To(BeTrue())
+		Expect(states).To(Equal([]http.ConnState{http.StateActive}))
+		sconn.requestDone()
+		Expect(states).To(Equal([]http.ConnState{http.StateActive}))
+		sconn.requestDone()
+		Expect(states).To(Equal([]http.ConnState{http.StateActive, http.StateIdle}))
+		Expect(sconn.acceptRequest(8)).To(BeTrue())
+		sconn.reportClosed()
+		// no state changes are reported after the connection was closed
+		sconn.requestDone()
+		Expect(states).To(Equal([]http.ConnState{http.StateActive, http.StateIdle, http.StateActive, http.StateClosed}))
+	})
+
+	It("sends keep-alives on the control stream while requests are active", func() {
+		sconn := newServerConn(mockquic.NewMockEarlyConnection(mockCtrl))
+		controlStr := mockquic.NewMockStream(mockCtrl)
+		sconn.controlStr = controlStr
+		sent := make(chan []byte, 100)
+		controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			sent <- append([]byte{}, b...)
+			return len(b), nil
+		}).AnyTimes()
+		sconn.keepAlive = newKeepAlive(scaleDuration(10*time.Millisecond), 0, sconn.sendKeepAlive)
+		sconn.keepAlive.start()
+		defer sconn.keepAlive.close()
+		Consistently(sent, scaleDuration(50*time.Millisecond)).ShouldNot(Receive())
+		Expect(sconn.acceptRequest(0)).To(BeTrue())
+		expected := &bytes.Buffer{}
+		appendKeepAliveFrame(expected)
+		Eventually(sent).Should(Receive(Equal(expected.Bytes())))
+		Eventually(sent).Should(Receive(Equal(expected.Bytes())))
+		sconn.requestDone()
+		time.Sleep(scaleDuration(20 * time.Millisecond))
+		for len(sent) > 0 {
+			<-sent
+		}
+		Consistently(sent, scaleDuration(50*time.Millisecond)).ShouldNot(Receive())
+	})
+
+	It("rejects requests exceeding the maximum number of concurrent requests", func() {
+		sconn := newServerConn(mockquic.NewMockEarlyConnection(mockCtrl))
+		sconn.maxRequests = 2
+		Expect(sconn.acceptRequest(0)).To(BeTrue())
+		Expect(sconn.acceptRequest(4)).To(BeTrue())
+		Expect(sconn.acceptRequest(8)).To(BeFalse())
+		sconn.requestDone()
+		Expect(sconn.acceptRequest(12)).To(BeTrue())
+	})
+
+	Context("graceful shutdown", func() {
+		var (
+			conn       *mockquic.MockEarlyConnection
+			controlStr *mockquic.MockStream
+			sconn      *serverConn
+		)
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			controlStr = mockquic.NewMockStream(mockCtrl)
+			sconn = newServerConn(conn)
+			sconn.controlStr = controlStr
+		})
+
+		expectGoAway := func(id quic.StreamID) {
+			buf := &bytes.Buffer{}
+			(&goAwayFrame{StreamID: uint64(id)}).Write(buf)
+			controlStr.EXPECT().Write(buf.Bytes())
+		}
+
+		It("sends a GOAWAY frame with the ID of the next request stream", func() {
+			Expect(sconn.acceptRequest(0)).To(BeTrue())
+			Expect(sconn.acceptRequest(8)).To(BeTrue())
+			Expect(sconn.acceptRequest(4)).To(BeTrue())
+			expectGoAway(12)
+			Expect(sconn.goAway()).To(Succeed())
+			// only a single GOAWAY frame is sent
+			Expect(sconn.goAway()).To(Succeed())
+		})
+
+		It("rejects requests on streams opened after the GOAWAY frame", func() {
+			Expect(sconn.acceptRequest(0)).To(BeTrue())
+			expectGoAway(4)
+			Expect(sconn.goAway()).To(Succeed())
+			Expect(sconn.acceptRequest(4)).To(BeFalse())
+			Expect(sconn.acceptRequest(8)).To(BeFalse())
+		})
+
+		It("is drained once all requests have completed", func() {
+			Expect(sconn.acceptRequest(0)).To(BeTrue())
+			Expect(sconn.isDrained()).To(BeFalse())
+			expectGoAway(4)
+			Expect(sconn.goAway()).To(Succeed())
+			Expect(sconn.isDrained()).To(BeFalse())
+			sconn.requestDone()
+			Expect(sconn.isDrained()).To(BeTrue())
+		})
+
+		It("waits for active requests before closing the connection", func() {
+			s.addConn(sconn)
+			Expect(sconn.acceptRequest(0)).To(BeTrue())
+			expectGoAway(4)
+			closed := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) { close(closed) })
+			errChan := make(chan error, 1)
+			go func() { errChan <- s.Shutdown(context.Background()) }()
+			Consistently(closed, scaleDuration(100*time.Millisecond)).ShouldNot(BeClosed())
+			sconn.requestDone()
+			Eventually(closed).Should(BeClosed())
+			Eventually(errChan).Should(Receive(BeNil()))
+		})
+
+		It("closes the connection when the context expires", func() {
+			s.addConn(sconn)
+			Expect(sconn.acceptRequest(0)).To(BeTrue())
+			expectGoAway(4)
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorNoError), gomock.Any())
+			ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(50*time.Millisecond))
+			defer cancel()
+			Expect(s.Shutdown(ctx)).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("sends a GOAWAY frame right away on connections established during shutdown", func() {
+			s.closed.Set(true)
+			expectGoAway(0)
+			s.addConn(sconn)
+			Expect(sconn.isDrained()).To(BeTrue())
+		})
+	})
+
 	It("errors when listening fails", func() {
 		testErr := errors.New("listen error")
 		quicListenAddr = func(addr string, tlsConf *tls.Config, config *quic.Config) (quic.EarlyListener, error) {
@@ -0,0 +1,62 @@
+package http3
+
+// Settings are the HTTP/3 settings received from the peer.
+type Settings struct {
+	// EnableDatagrams says if the peer supports HTTP datagrams (RFC 9297).
+	EnableDatagrams bool
+	// EnableExtendedConnect says if the peer supports Extended CONNECT (RFC 9220).
+	EnableExtendedConnect bool
+	// Other contains all other settings, including unknown and extension settings.
+	Other map[uint64]uint64
+}
+
+// A Settingser gives access to the HTTP/3 settings sent by the peer.
+// On the server side, it is implemented by the http.ResponseWriter.
+// On the client side, it is implemented by the http.Response.Body.
+type Settingser interface {
+	// ReceivedSettings returns a channel that is closed once the peer's SETTINGS frame was received.
+	// The channel is never closed if the connection is closed before.
+	ReceivedSettings() <-chan struct{}
+	// Settings returns the settings received from the peer.
+	// It must only be called after the channel returned by ReceivedSettings was closed.
+	Settings() *Settings
+}
+
+// limitSettings adds the settings announcing the QPACK limits and the maximum header list size to settings.
+// Settings with a value of zero are omitted, since zero is the default value (or, for the header list size, means unlimited).
+func limitSettings(settings map[uint64]uint64, qpackMaxTableCapacity, qpackBlockedStreams, maxHeaderListSize uint64) map[uint64]uint64 {
+	if qpackMaxTableCapacity == 0 && maxHeaderListSize == 0 {
+		return settings
+	}
+	settings = copySettings(settings)
+	if qpackMaxTableCapacity > 0 {
+		settings[settingQPACKMaxTableCapacity] = qpackMaxTableCapacity
+		if qpackBlockedStreams > 0 {
+			settings[settingQPACKBlockedStreams] = qpackBlockedStreams
+		}
+	}
+	if maxHeaderListSize > 0 {
+		settings[settingMaxFieldSectionSize] = maxHeaderListSize
+	}
+	return settings
+}
+
+func copySettings(settings map[uint64]uint64) map[uint64]uint64 {
+	c := make(map[uint64]uint64, len(settings)+1)
+	for k, v := range settings {
+		c[k] = v
+	}
+	return c
+}
+
+func newSettings(f *settingsFrame) *Settings {
+	s := &Settings{
+		EnableDatagrams:       f.Datagram,
+		EnableExtendedConnect: f.ExtendedConnect,
+		Other:                 make(map[uint64]uint64, len(f.Other)),
+	}
+	for k, v := range f.Other {
+		s.Other[k] = v
+	}
+	return s
+}
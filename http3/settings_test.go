@@ -0,0 +1,32 @@
+package http3
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Settings", func() {
+	Context("limiting the QPACK decoder", func() {
+		It("doesn't add any settings if no limits are set", func() {
+			settings := map[uint64]uint64{0x1337: 42}
+			Expect(limitSettings(settings, 0, 100, 0)).To(Equal(map[uint64]uint64{0x1337: 42}))
+			Expect(limitSettings(nil, 0, 0, 0)).To(BeEmpty())
+		})
+
+		It("adds the settings, without modifying the original map", func() {
+			settings := map[uint64]uint64{0x1337: 42}
+			Expect(limitSettings(settings, 4096, 100, 1<<16)).To(Equal(map[uint64]uint64{
+				0x1337:                       42,
+				settingQPACKMaxTableCapacity: 4096,
+				settingQPACKBlockedStreams:   100,
+				settingMaxFieldSectionSize:   1 << 16,
+			}))
+			Expect(settings).To(Equal(map[uint64]uint64{0x1337: 42}))
+		})
+
+		It("only announces blocked streams if the dynamic table is used", func() {
+			Expect(limitSettings(nil, 0, 100, 1000)).To(Equal(map[uint64]uint64{settingMaxFieldSectionSize: 1000}))
+			Expect(limitSettings(nil, 4096, 0, 0)).To(Equal(map[uint64]uint64{settingQPACKMaxTableCapacity: 4096}))
+		})
+	})
+})
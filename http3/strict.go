@@ -0,0 +1,216 @@
+package http3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http/httpguts"
+)
+
+// A ProtocolViolation is a violation of the HTTP/3 protocol by the peer, detected in strict mode.
+type ProtocolViolation struct {
+	// ErrorCode is the HTTP/3 error code that the connection was closed with,
+	// or that the stream was reset with.
+	ErrorCode quic.ApplicationErrorCode
+	// StreamID is the ID of the stream on which the violation occurred.
+	StreamID quic.StreamID
+	// IsStreamError says if only the stream was reset.
+	// Otherwise, the connection was closed.
+	IsStreamError bool
+	// Reason describes the violation.
+	Reason string
+}
+
+func (v *ProtocolViolation) Error() string {
+	return fmt.Sprintf("http3: protocol violation on stream %d (%s): %s", v.StreamID, errorCode(v.ErrorCode), v.Reason)
+}
+
+// streamWithID is implemented by all stream types.
+type streamWithID interface {
+	StreamID() quic.StreamID
+}
+
+// strictMode enforces additional requirements of RFC 9114 on the peer,
+// and reports violations of these requirements.
+// If it is not enabled, it only closes the connection for the violations passed to connectionError.
+type strictMode struct {
+	enabled bool
+	handler func(quic.Connection, *ProtocolViolation)
+
+	mutex sync.Mutex
+	// the types of the critical unidirectional streams opened by the peer
+	criticalStreams map[uint64]struct{}
+}
+
+func newStrictMode(enabled bool, handler func(quic.Connection, *ProtocolViolation)) *strictMode {
+	return &strictMode{
+		enabled:         enabled,
+		handler:         handler,
+		criticalStreams: make(map[uint64]struct{}),
+	}
+}
+
+// reset is called when the connection is set up again after 0-RTT was rejected.
+func (s *strictMode) reset() {
+	s.mutex.Lock()
+	s.criticalStreams = make(map[uint64]struct{})
+	s.mutex.Unlock()
+}
+
+// connectionError closes the connection.
+// In strict mode, the violation is reported to the handler.
+func (s *strictMode) connectionError(conn quic.Connection, str streamWithID, code errorCode, reason string) {
+	conn.CloseWithError(quic.ApplicationErrorCode(code), reason)
+	s.report(conn, str, &ProtocolViolation{ErrorCode: quic.ApplicationErrorCode(code), Reason: reason})
+}
+
+// reportRequestError reports a violation on a request stream, which is handled by returning rerr.
+func (s *strictMode) reportRequestError(conn quic.Connection, str streamWithID, rerr requestError) requestError {
+	v := &ProtocolViolation{Reason: rerr.err.Error()}
+	if rerr.connErr != 0 {
+		v.ErrorCode = quic.ApplicationErrorCode(rerr.connErr)
+	} else {
+		v.ErrorCode = quic.ApplicationErrorCode(rerr.streamErr)
+		v.IsStreamError = true
+	}
+	s.report(conn, str, v)
+	return rerr
+}
+
+func (s *strictMode) report(conn quic.Connection, str streamWithID, v *ProtocolViolation) {
+	if s.enabled && s.handler != nil {
+		v.StreamID = str.StreamID()
+		s.handler(conn, v)
+	}
+}
+
+// openCriticalStream is called when the peer opens a control stream or a QPACK stream.
+// In strict mode, the connection is closed if the peer opens more than one stream of each type (RFC 9114, Section 6.2).
+func (s *strictMode) openCriticalStream(conn quic.Connection, str quic.ReceiveStream, streamType uint64) bool {
+	if !s.enabled {
+		return true
+	}
+	s.mutex.Lock()
+	_, ok := s.criticalStreams[streamType]
+	s.criticalStreams[streamType] = struct{}{}
+	s.mutex.Unlock()
+	if ok {
+		s.connectionError(conn, str, errorStreamCreationError, fmt.Sprintf("duplicate stream of type %#x", streamType))
+		return false
+	}
+	return true
+}
+
+// readCriticalStream reads a QPACK stream.
+// Our QPACK implementation doesn't use the dynamic table yet, so the instructions are discarded.
+// In strict mode, the connection is closed if the peer closes the stream.
+func (s *strictMode) readCriticalStream(conn quic.Connection, str quic.ReceiveStream) {
+	if !s.enabled {
+		return
+	}
+	_, err := io.Copy(io.Discard, str)
+	if err == nil {
+		err = io.EOF
+	}
+	s.criticalStreamFailed(conn, str, err)
+}
+
+// criticalStreamFailed is called when reading from a critical stream failed.
+// In strict mode, the connection is closed if the peer closed or reset the stream (RFC 9114, Section 6.2.1),
+// or if it sent a frame type reserved for HTTP/2.
+// It returns true if the connection was closed.
+func (s *strictMode) criticalStreamFailed(conn quic.Connection, str streamWithID, err error) bool {
+	if !s.enabled {
+		return false
+	}
+	if err == errReservedFrameType {
+		s.connectionError(conn, str, errorFrameUnexpected, err.Error())
+		return true
+	}
+	var serr *quic.StreamError
+	if err == io.EOF || errors.As(err, &serr) {
+		s.connectionError(conn, str, errorClosedCriticalStream, "critical stream closed")
+		return true
+	}
+	return false
+}
+
+// errReservedFrameType is returned when a frame type reserved for HTTP/2 frames is received in strict mode.
+var errReservedFrameType = errors.New("received a frame type reserved for HTTP/2")
+
+// isReservedFrameType says if a frame type corresponds to a HTTP/2 frame type
+// that doesn't exist in HTTP/3 (RFC 9114, Section 7.2.8).
+func isReservedFrameType(t uint64) bool {
+	switch t {
+	case 0x2, 0x6, 0x8, 0x9:
+		return true
+	}
+	return false
+}
+
+// parseFrame parses the next frame.
+// In strict mode, frame types reserved for HTTP/2 frames are rejected, otherwise they are skipped.
+func (s *strictMode) parseFrame(r io.Reader, unknownFrameHandler unknownFrameHandlerFunc, extensionFrameHandler extensionFrameHandlerFunc) (frame, error) {
+	return parseFrameWithExtensions(r, unknownFrameHandler, extensionFrameHandler, s.enabled)
+}
+
+// requestPseudoHeaders are the pseudo-header fields allowed in requests.
+var requestPseudoHeaders = map[string]struct{}{
+	":method":    {},
+	":scheme":    {},
+	":authority": {},
+	":path":      {},
+	":protocol":  {},
+}
+
+// validateHeaderFields checks the requirements of RFC 9114, Section 4.2 and 4.3.
+// Messages violating these requirements are malformed.
+// The characters used in field names and values are only checked in strict mode.
+func validateHeaderFields(hfs []qpack.HeaderField, isRequest, strict bool) error {
+	seenPseudo := make(map[string]struct{})
+	var seenRegular bool
+	for _, hf := range hfs {
+		if hf.IsPseudo() {
+			if seenRegular {
+				return fmt.Errorf("pseudo-header field %s after regular header fields", hf.Name)
+			}
+			if _, ok := seenPseudo[hf.Name]; ok {
+				return fmt.Errorf("duplicate pseudo-header field %s", hf.Name)
+			}
+			seenPseudo[hf.Name] = struct{}{}
+			if _, ok := requestPseudoHeaders[hf.Name]; isRequest && !ok {
+				return fmt.Errorf("invalid pseudo-header field for a request: %s", hf.Name)
+			}
+			if !isRequest && hf.Name != ":status" {
+				return fmt.Errorf("invalid pseudo-header field for a response: %s", hf.Name)
+			}
+			continue
+		}
+		seenRegular = true
+		if strict && (!httpguts.ValidHeaderFieldName(hf.Name) || strings.ToLower(hf.Name) != hf.Name) {
+			return fmt.Errorf("invalid header field name: %q", hf.Name)
+		}
+		if strict && !httpguts.ValidHeaderFieldValue(hf.Value) {
+			return fmt.Errorf("invalid value for header field %s", hf.Name)
+		}
+		switch hf.Name {
+		case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+			return fmt.Errorf("connection-specific header field: %s", hf.Name)
+		case "te":
+			if hf.Value != "trailers" {
+				return fmt.Errorf("invalid value for the TE header field: %s", hf.Value)
+			}
+		}
+	}
+	if !isRequest {
+		if _, ok := seenPseudo[":status"]; !ok {
+			return errors.New("missing :status pseudo-header field")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package http3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	"github.com/lucas-clemente/quic-go"
+
+	"github.com/golang/mock/gomock"
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// encodeHeaderFields encodes a HEADERS frame containing the header fields hfs
+func encodeHeaderFields(hfs []qpack.HeaderField) []byte {
+	headerBuf := &bytes.Buffer{}
+	enc := qpack.NewEncoder(headerBuf)
+	for _, hf := range hfs {
+		ExpectWithOffset(1, enc.WriteField(hf)).To(Succeed())
+	}
+	buf := &bytes.Buffer{}
+	(&headersFrame{Length: uint64(headerBuf.Len())}).Write(buf)
+	buf.Write(headerBuf.Bytes())
+	return buf.Bytes()
+}
+
+var _ = Describe("Strict mode", func() {
+	Context("validating header fields", func() {
+		requestHeaders := func(additional ...qpack.HeaderField) []qpack.HeaderField {
+			return append([]qpack.HeaderField{
+				{Name: ":method", Value: http.MethodGet},
+				{Name: ":scheme", Value: "https"},
+				{Name: ":authority", Value: "quic.clemente.io"},
+				{Name: ":path", Value: "/"},
+			}, additional...)
+		}
+
+		It("accepts requests written by the request writer", func() {
+			req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/foo?bar", bytes.NewReader([]byte("foobar")))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "text/plain")
+			req.Header.Set("Connection", "close") // removed by the request writer
+			buf := &bytes.Buffer{}
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+			closed := make(chan struct{})
+			str.EXPECT().Close().Do(func() { close(closed) })
+			Expect(newRequestWriter(utils.DefaultLogger).WriteRequest(str, req, false, false, false, nil)).To(Succeed())
+			Eventually(closed).Should(BeClosed())
+
+			f, err := parseNextFrame(buf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(BeAssignableToTypeOf(&headersFrame{}))
+			headerBlock := make([]byte, f.(*headersFrame).Length)
+			_, err = io.ReadFull(buf, headerBlock)
+			Expect(err).ToNot(HaveOccurred())
+			hfs, err := qpack.NewDecoder(nil).DecodeFull(headerBlock)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(validateHeaderFields(hfs, true, true)).To(Succeed())
+		})
+
+		It("accepts valid responses", func() {
+			Expect(validateHeaderFields([]qpack.HeaderField{
+				{Name: ":status", Value: "200"},
+				{Name: "content-type", Value: "text/html"},
+				{Name: "te", Value: "trailers"},
+			}, false, true)).To(Succeed())
+		})
+
+		It("rejects uppercase header field names", func() {
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: "Content-Type", Value: "text/html"}), true, true)).To(MatchError(`invalid header field name: "Content-Type"`))
+		})
+
+		It("only checks the characters of header field names and values in strict mode", func() {
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: "Content-Type", Value: "text/html"}), true, false)).To(Succeed())
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: "foo", Value: "foo\nbar"}), true, false)).To(Succeed())
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: "connection", Value: "close"}), true, false)).To(MatchError("connection-specific header field: connection"))
+		})
+
+		It("rejects invalid header field values", func() {
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: "foo", Value: "foo\nbar"}), true, true)).To(MatchError("invalid value for header field foo"))
+		})
+
+		It("rejects connection-specific header fields", func() {
+			for _, name := range []string{"connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade"} {
+				Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: name, Value: "foo"}), true, true)).To(MatchError("connection-specific header field: " + name))
+			}
+		})
+
+		It("only allows the value trailers for the TE header field", func() {
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: "te", Value: "trailers"}), true, true)).To(Succeed())
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: "te", Value: "gzip"}), true, true)).To(MatchError("invalid value for the TE header field: gzip"))
+		})
+
+		It("rejects pseudo-header fields after regular header fields", func() {
+			hfs := []qpack.HeaderField{{Name: "foo", Value: "bar"}, {Name: ":status", Value: "200"}}
+			Expect(validateHeaderFields(hfs, false, true)).To(MatchError("pseudo-header field :status after regular header fields"))
+		})
+
+		It("rejects duplicate pseudo-header fields", func() {
+			Expect(validateHeaderFields(requestHeaders(qpack.HeaderField{Name: ":path", Value: "/foo"}), true, true)).To(MatchError("duplicate pseudo-header field :path"))
+		})
+
+		It("rejects response pseudo-header fields in requests", func() {
+			hfs := append([]qpack.HeaderField{{Name: ":status", Value: "200"}}, requestHeaders()...)
+			Expect(validateHeaderFields(hfs, true, true)).To(MatchError("invalid pseudo-header field for a request: :status"))
+		})
+
+		It("rejects request pseudo-header fields in responses", func() {
+			hfs := []qpack.HeaderField{{Name: ":status", Value: "200"}, {Name: ":path", Value: "/"}}
+			Expect(validateHeaderFields(hfs, false, true)).To(MatchError("invalid pseudo-header field for a response: :path"))
+		})
+
+		It("rejects responses without a status", func() {
+			Expect(validateHeaderFields([]qpack.HeaderField{{Name: "foo", Value: "bar"}}, false, true)).To(MatchError("missing :status pseudo-header field"))
+		})
+	})
+
+	Context("parsing frames", func() {
+		It("skips frame types reserved for HTTP/2, if not enabled", func() {
+			buf := &bytes.Buffer{}
+			buf.Write([]byte{0x2, 0x1, 0x0}) // HTTP/2 PRIORITY frame
+			(&dataFrame{Length: 6}).Write(buf)
+			f, err := newStrictMode(false, nil).parseFrame(buf, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(&dataFrame{Length: 6}))
+		})
+
+		It("rejects frame types reserved for HTTP/2", func() {
+			for _, t := range []byte{0x2, 0x6, 0x8, 0x9} {
+				buf := bytes.NewReader([]byte{t, 0x0})
+				_, err := newStrictMode(true, nil).parseFrame(buf, nil, nil)
+				Expect(err).To(MatchError(errReservedFrameType))
+			}
+		})
+	})
+
+	Context("critical streams", func() {
+		var (
+			conn       *mockquic.MockEarlyConnection
+			str        *mockquic.MockStream
+			strict     *strictMode
+			violations []*ProtocolViolation
+		)
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(3)).AnyTimes()
+			violations = nil
+			strict = newStrictMode(true, func(c quic.Connection, v *ProtocolViolation) {
+				Expect(c).To(Equal(conn))
+				violations = append(violations, v)
+			})
+		})
+
+		It("allows a single stream of each type", func() {
+			Expect(strict.openCriticalStream(conn, str, streamTypeControlStream)).To(BeTrue())
+			Expect(strict.openCriticalStream(conn, str, streamTypeQPACKEncoderStream)).To(BeTrue())
+			Expect(strict.openCriticalStream(conn, str, streamTypeQPACKDecoderStream)).To(BeTrue())
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorStreamCreationError), "duplicate stream of type 0x2")
+			Expect(strict.openCriticalStream(conn, str, streamTypeQPACKEncoderStream)).To(BeFalse())
+			Expect(violations).To(Equal([]*ProtocolViolation{{
+				ErrorCode: quic.ApplicationErrorCode(errorStreamCreationError),
+				StreamID:  3,
+				Reason:    "duplicate stream of type 0x2",
+			}}))
+		})
+
+		It("allows new streams after a reset", func() {
+			Expect(strict.openCriticalStream(conn, str, streamTypeControlStream)).To(BeTrue())
+			strict.reset()
+			Expect(strict.openCriticalStream(conn, str, streamTypeControlStream)).To(BeTrue())
+		})
+
+		It("doesn't check anything if not enabled", func() {
+			strict = newStrictMode(false, func(quic.Connection, *ProtocolViolation) { Fail("unexpected violation") })
+			Expect(strict.openCriticalStream(conn, str, streamTypeControlStream)).To(BeTrue())
+			Expect(strict.openCriticalStream(conn, str, streamTypeControlStream)).To(BeTrue())
+			Expect(strict.criticalStreamFailed(conn, str, io.EOF)).To(BeFalse())
+		})
+
+		It("closes the connection when a critical stream is closed", func() {
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorClosedCriticalStream), gomock.Any())
+			Expect(strict.criticalStreamFailed(conn, str, io.EOF)).To(BeTrue())
+			Expect(violations).To(HaveLen(1))
+		})
+
+		It("closes the connection when a critical stream is reset", func() {
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(errorClosedCriticalStream), gomock.Any())
+			Expect(strict.criticalStreamFailed(conn, str, &quic.StreamError{StreamID: 3, ErrorCode: 42})).To(BeTrue())
+			Expect(violations).To(HaveLen(1))
+		})
+
+		It("ignores other errors", func() {
+			Expect(strict.criticalStreamFailed(conn, str, errors.New("connection closed"))).To(BeFalse())
+			Expect(violations).To(BeEmpty())
+		})
+	})
+})
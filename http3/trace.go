@@ -0,0 +1,118 @@
+package http3
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"net/textproto"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// The helpers in this file call the hooks of an httptrace.ClientTrace, if set.
+// They are modeled after the ones used by the HTTP/2 client in golang.org/x/net/http2.
+
+func traceGetConn(trace *httptrace.ClientTrace, hostPort string) {
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(hostPort)
+	}
+}
+
+func traceConnectStart(trace *httptrace.ClientTrace, addr string) {
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("udp", addr)
+	}
+}
+
+func traceConnectDone(trace *httptrace.ClientTrace, addr string, err error) {
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("udp", addr, err)
+	}
+}
+
+func traceTLSHandshakeStart(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+}
+
+func traceTLSHandshakeDone(trace *httptrace.ClientTrace, state tls.ConnectionState, err error) {
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(state, err)
+	}
+}
+
+func traceGotConn(trace *httptrace.ClientTrace, conn quic.Connection, reused bool) {
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: &traceConn{conn: conn}, Reused: reused})
+	}
+}
+
+func traceHasWroteHeaderField(trace *httptrace.ClientTrace) bool {
+	return trace != nil && trace.WroteHeaderField != nil
+}
+
+func traceWroteHeaderField(trace *httptrace.ClientTrace, k, v string) {
+	if trace != nil && trace.WroteHeaderField != nil {
+		trace.WroteHeaderField(k, []string{v})
+	}
+}
+
+func traceWroteHeaders(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.WroteHeaders != nil {
+		trace.WroteHeaders()
+	}
+}
+
+func traceWait100Continue(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.Wait100Continue != nil {
+		trace.Wait100Continue()
+	}
+}
+
+func traceWroteRequest(trace *httptrace.ClientTrace, err error) {
+	if trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+	}
+}
+
+func traceFirstResponseByte(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+}
+
+func traceGot100Continue(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.Got100Continue != nil {
+		trace.Got100Continue()
+	}
+}
+
+func traceGot1xxResponse(trace *httptrace.ClientTrace, code int, header textproto.MIMEHeader) error {
+	if trace != nil && trace.Got1xxResponse != nil {
+		return trace.Got1xxResponse(code, header)
+	}
+	return nil
+}
+
+var errTraceConn = errors.New("http3: operation not supported on a traced QUIC connection")
+
+// traceConn is passed to httptrace.ClientTrace.GotConn.
+// A QUIC connection can't be used as a net.Conn,
+// so only the addresses are available.
+type traceConn struct {
+	conn quic.Connection
+}
+
+var _ net.Conn = &traceConn{}
+
+func (c *traceConn) Read([]byte) (int, error)         { return 0, errTraceConn }
+func (c *traceConn) Write([]byte) (int, error)        { return 0, errTraceConn }
+func (c *traceConn) Close() error                     { return errTraceConn }
+func (c *traceConn) LocalAddr() net.Addr              { return c.conn.LocalAddr() }
+func (c *traceConn) RemoteAddr() net.Addr             { return c.conn.RemoteAddr() }
+func (c *traceConn) SetDeadline(time.Time) error      { return errTraceConn }
+func (c *traceConn) SetReadDeadline(time.Time) error  { return errTraceConn }
+func (c *traceConn) SetWriteDeadline(time.Time) error { return errTraceConn }
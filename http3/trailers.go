@@ -0,0 +1,83 @@
+package http3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go"
+	"golang.org/x/net/http/httpguts"
+)
+
+// parseAnnouncedTrailers removes the Trailer header from h.
+// Like net/http, it returns the trailers announced in that header as the keys of an http.Header.
+// Their values are filled in when the trailers are received.
+func parseAnnouncedTrailers(h http.Header) http.Header {
+	vv, ok := h["Trailer"]
+	if !ok {
+		return nil
+	}
+	h.Del("Trailer")
+	trailer := make(http.Header)
+	for _, v := range vv {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(textproto.TrimString(name))
+			if name != "" && httpguts.ValidTrailerHeader(name) {
+				trailer[name] = nil
+			}
+		}
+	}
+	return trailer
+}
+
+// commaSeparatedTrailers returns the value of the Trailer header announcing the trailers of req.
+// copied from net/http2/transport.go
+func commaSeparatedTrailers(req *http.Request) (string, error) {
+	keys := make([]string, 0, len(req.Trailer))
+	for k := range req.Trailer {
+		k = http.CanonicalHeaderKey(k)
+		if !httpguts.ValidTrailerHeader(k) {
+			return "", fmt.Errorf("invalid Trailer key %q", k)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return strings.Join(keys, ","), nil
+	}
+	return "", nil
+}
+
+// readTrailers reads the trailers sent in the HEADERS frame hf, and adds them to trailer.
+func readTrailers(ctx context.Context, conn quic.Connection, str quic.ReceiveStream, decoder *qpackDecoder, maxHeaderBytes uint64, hf *headersFrame, trailer http.Header) error {
+	if hf.Length > maxHeaderBytes {
+		str.CancelRead(quic.StreamErrorCode(errorFrameError))
+		return fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, maxHeaderBytes)
+	}
+	headerBlock := make([]byte, hf.Length)
+	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		return err
+	}
+	hfs, err := decoder.decode(ctx, str, headerBlock)
+	if err != nil {
+		rerr := decodingRequestError(err)
+		if rerr.connErr != 0 {
+			conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), "")
+		} else {
+			str.CancelRead(quic.StreamErrorCode(rerr.streamErr))
+		}
+		return err
+	}
+	for _, hf := range hfs {
+		if strings.HasPrefix(hf.Name, ":") {
+			str.CancelRead(quic.StreamErrorCode(errorMessageError))
+			return fmt.Errorf("invalid pseudo header in trailers: %s", hf.Name)
+		}
+		trailer.Add(hf.Name, hf.Value)
+	}
+	return nil
+}
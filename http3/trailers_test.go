@@ -0,0 +1,27 @@
+package http3
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Trailers", func() {
+	It("parses the announced trailers", func() {
+		h := http.Header{
+			"Content-Type": []string{"text/plain"},
+			"Trailer":      []string{"foo, Bar", "content-length,,grpc-status"},
+		}
+		Expect(parseAnnouncedTrailers(h)).To(Equal(http.Header{
+			"Foo":         nil,
+			"Bar":         nil,
+			"Grpc-Status": nil,
+		}))
+		Expect(h).To(Equal(http.Header{"Content-Type": []string{"text/plain"}}))
+	})
+
+	It("returns nil if no trailers are announced", func() {
+		Expect(parseAnnouncedTrailers(http.Header{"Content-Type": []string{"text/plain"}})).To(BeNil())
+	})
+})
@@ -0,0 +1,102 @@
+package http3
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// A UniStreamHandler handles the unidirectional streams of a stream type registered using RegisterUniStreamType.
+type UniStreamHandler struct {
+	// HandleStream is called for every stream of the registered type opened by the peer,
+	// after the stream type was read. It is called on a separate Go routine.
+	// When it returns, the stream is canceled (using H3_NO_ERROR),
+	// so data that wasn't read yet is discarded.
+	HandleStream func(quic.Connection, quic.ReceiveStream)
+	// Unique, if set, only allows the peer to open a single stream of this type on each connection,
+	// like the control stream and the QPACK streams.
+	// Opening a second stream is treated as a connection error of type H3_STREAM_CREATION_ERROR.
+	Unique bool
+}
+
+// uniStreamHandlers are the handlers registered using RegisterUniStreamType.
+type uniStreamHandlers struct {
+	mutex    sync.RWMutex
+	handlers map[StreamType]UniStreamHandler
+}
+
+func (h *uniStreamHandlers) register(t StreamType, handler UniStreamHandler) error {
+	if handler.HandleStream == nil {
+		return fmt.Errorf("http3: no handler for stream type %#x", uint64(t))
+	}
+	if isReservedStreamType(t) {
+		return fmt.Errorf("http3: stream type %#x is reserved", uint64(t))
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.handlers[t]; ok {
+		return fmt.Errorf("http3: stream type %#x already registered", uint64(t))
+	}
+	if h.handlers == nil {
+		h.handlers = make(map[StreamType]UniStreamHandler)
+	}
+	h.handlers[t] = handler
+	return nil
+}
+
+func (h *uniStreamHandlers) get(t StreamType) (UniStreamHandler, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	handler, ok := h.handlers[t]
+	return handler, ok
+}
+
+// isReservedStreamType says if t is used by HTTP/3, QPACK or WebTransport,
+// or if it is reserved for greasing (RFC 9114, Section 6.2.3).
+func isReservedStreamType(t StreamType) bool {
+	switch t {
+	case streamTypeControlStream, streamTypePushStream, streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream, streamTypeWebTransportStream:
+		return true
+	}
+	return t >= 0x21 && (t-0x21)%0x1f == 0
+}
+
+// openedUniStreams tracks the stream types of the unique streams opened by the peer on a connection.
+type openedUniStreams struct {
+	mutex sync.Mutex
+	types map[StreamType]struct{}
+}
+
+// add adds a stream type. It returns false if a stream of this type was already opened.
+func (o *openedUniStreams) add(t StreamType) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if _, ok := o.types[t]; ok {
+		return false
+	}
+	if o.types == nil {
+		o.types = make(map[StreamType]struct{})
+	}
+	o.types[t] = struct{}{}
+	return true
+}
+
+// handleRegisteredUniStream passes a stream of a registered type to its handler.
+// It returns false if no handler was registered for the stream type.
+func handleRegisteredUniStream(handlers *uniStreamHandlers, opened *openedUniStreams, conn quic.Connection, str quic.ReceiveStream, t StreamType) bool {
+	if handlers == nil {
+		return false
+	}
+	handler, ok := handlers.get(t)
+	if !ok {
+		return false
+	}
+	if handler.Unique && !opened.add(t) {
+		conn.CloseWithError(quic.ApplicationErrorCode(errorStreamCreationError), fmt.Sprintf("duplicate stream of type %#x", uint64(t)))
+		return true
+	}
+	defer str.CancelRead(quic.StreamErrorCode(errorNoError))
+	handler.HandleStream(conn, str)
+	return true
+}
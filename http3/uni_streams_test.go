@@ -0,0 +1,56 @@
+package http3
+
+import (
+	"github.com/lucas-clemente/quic-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unidirectional stream types", func() {
+	handler := UniStreamHandler{HandleStream: func(quic.Connection, quic.ReceiveStream) {}}
+
+	It("registers stream types", func() {
+		var h uniStreamHandlers
+		_, ok := h.get(0x1337)
+		Expect(ok).To(BeFalse())
+		Expect(h.register(0x1337, UniStreamHandler{HandleStream: handler.HandleStream, Unique: true})).To(Succeed())
+		registered, ok := h.get(0x1337)
+		Expect(ok).To(BeTrue())
+		Expect(registered.Unique).To(BeTrue())
+	})
+
+	It("refuses to register a stream type twice", func() {
+		var h uniStreamHandlers
+		Expect(h.register(0x1337, handler)).To(Succeed())
+		Expect(h.register(0x1337, handler)).To(MatchError("http3: stream type 0x1337 already registered"))
+	})
+
+	It("refuses to register a stream type without a handler", func() {
+		var h uniStreamHandlers
+		Expect(h.register(0x1337, UniStreamHandler{})).To(MatchError("http3: no handler for stream type 0x1337"))
+	})
+
+	It("refuses to register reserved stream types", func() {
+		var h uniStreamHandlers
+		for _, t := range []StreamType{
+			streamTypeControlStream,
+			streamTypePushStream,
+			streamTypeQPACKEncoderStream,
+			streamTypeQPACKDecoderStream,
+			streamTypeWebTransportStream,
+			0x21,
+			0x1f*42 + 0x21,
+		} {
+			Expect(h.register(t, handler)).To(MatchError(ContainSubstring("is reserved")))
+		}
+		Expect(h.register(0x22, handler)).To(Succeed())
+	})
+
+	It("tracks unique streams", func() {
+		var o openedUniStreams
+		Expect(o.add(0x1337)).To(BeTrue())
+		Expect(o.add(0x42)).To(BeTrue())
+		Expect(o.add(0x1337)).To(BeFalse())
+	})
+})
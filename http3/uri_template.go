@@ -0,0 +1,67 @@
+package http3
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// expandURITemplate expands the variables in a URI template (RFC 6570), as used by the MASQUE protocols.
+// Only simple string expansion ({var}) is supported.
+func expandURITemplate(template string, vars map[string]string) string {
+	oldnew := make([]string, 0, 2*len(vars))
+	for name, value := range vars {
+		oldnew = append(oldnew, "{"+name+"}", url.QueryEscape(value))
+	}
+	return strings.NewReplacer(oldnew...).Replace(template)
+}
+
+// matchURITemplate matches a request URL against the path (and query) of a URI template,
+// and returns the values of the template variables.
+// Variables can be used as path segments or query values.
+func matchURITemplate(template string, u *url.URL) (map[string]string, error) {
+	vars := make(map[string]string)
+	setVar := func(s, value string) error {
+		if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+			if s != value {
+				return errors.New("path doesn't match the template")
+			}
+			return nil
+		}
+		v, err := url.QueryUnescape(value)
+		if err != nil {
+			return err
+		}
+		vars[s[1:len(s)-1]] = v
+		return nil
+	}
+
+	tmplPath := template
+	var tmplQuery string
+	if i := strings.IndexByte(template, '?'); i >= 0 {
+		tmplPath, tmplQuery = template[:i], template[i+1:]
+	}
+	tmplSegments := strings.Split(tmplPath, "/")
+	segments := strings.Split(u.EscapedPath(), "/")
+	if len(tmplSegments) != len(segments) {
+		return nil, errors.New("path doesn't match the template")
+	}
+	for i, s := range tmplSegments {
+		if err := setVar(s, segments[i]); err != nil {
+			return nil, err
+		}
+	}
+	if tmplQuery != "" {
+		query := u.Query()
+		for _, p := range strings.Split(tmplQuery, "&") {
+			kv := strings.SplitN(p, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if err := setVar(kv[1], query.Get(kv[0])); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return vars, nil
+}
@@ -0,0 +1,431 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// WebTransport, as defined in draft-ietf-webtrans-http3-02.
+const (
+	settingEnableWebTransport = 0x2b603742
+
+	// The frame type sent on bidirectional WebTransport streams, followed by the session ID.
+	frameTypeWebTransportStream FrameType = 0x41
+	// The stream type of unidirectional WebTransport streams, followed by the session ID.
+	streamTypeWebTransportStream = 0x54
+
+	// The :protocol used to establish a WebTransport session.
+	protocolWebTransport = "webtransport"
+)
+
+const (
+	// the maximum number of streams (per direction) buffered for a session that was not established yet
+	maxBufferedWebTransportStreams = 16
+	// the maximum number of streams buffered for all sessions of a connection that were not established yet
+	maxBufferedWebTransportStreamsPerConn = 64
+	// the time that streams are buffered for a session that is not established
+	webTransportStreamBufferTimeout = 5 * time.Second
+	// the maximum number of incoming streams (per direction) waiting to be accepted
+	maxAcceptQueueLen = 32
+	// the maximum number of datagrams waiting to be received
+	maxDatagramQueueLen = 32
+)
+
+// ErrWebTransportSessionClosed is returned when using a WebTransport session that was closed.
+var ErrWebTransportSessionClosed = errors.New("http3: WebTransport session closed")
+
+// A WebTransportSession is a WebTransport session, established by an Extended CONNECT request.
+// Streams and datagrams are associated with the session using the session ID,
+// which is the stream ID of the CONNECT request stream.
+type WebTransportSession struct {
+	conn      quic.Connection
+	datagrams *datagramDemuxer
+	str       quic.Stream
+	sessionID quic.StreamID
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	bidiStreams       chan quic.Stream
+	uniStreams        chan quic.ReceiveStream
+	receivedDatagrams chan []byte
+
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newWebTransportSession(conn quic.Connection, datagrams *datagramDemuxer, str quic.Stream, onClose func()) *WebTransportSession {
+	ctx, cancel := context.WithCancel(conn.Context())
+	s := &WebTransportSession{
+		conn:              conn,
+		datagrams:         datagrams,
+		str:               str,
+		sessionID:         str.StreamID(),
+		ctx:               ctx,
+		cancel:            cancel,
+		bidiStreams:       make(chan quic.Stream, maxAcceptQueueLen),
+		uniStreams:        make(chan quic.ReceiveStream, maxAcceptQueueLen),
+		receivedDatagrams: make(chan []byte, maxDatagramQueueLen),
+		onClose:           onClose,
+	}
+	go s.run()
+	return s
+}
+
+// run waits until the CONNECT stream is closed, which terminates the session.
+func (s *WebTransportSession) run() {
+	io.Copy(ioutil.Discard, s.str)
+	s.close()
+}
+
+func (s *WebTransportSession) close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.onClose()
+	})
+}
+
+// SessionID returns the session ID.
+func (s *WebTransportSession) SessionID() quic.StreamID {
+	return s.sessionID
+}
+
+// Context returns a context that is canceled when the session is closed.
+func (s *WebTransportSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *WebTransportSession) addBidiStream(str quic.Stream) {
+	select {
+	case s.bidiStreams <- str:
+	default:
+		str.CancelRead(quic.StreamErrorCode(errorExcessiveLoad))
+		str.CancelWrite(quic.StreamErrorCode(errorExcessiveLoad))
+	}
+}
+
+func (s *WebTransportSession) addUniStream(str quic.ReceiveStream) {
+	select {
+	case s.uniStreams <- str:
+	default:
+		str.CancelRead(quic.StreamErrorCode(errorExcessiveLoad))
+	}
+}
+
+func (s *WebTransportSession) addDatagram(b []byte) {
+	select {
+	case s.receivedDatagrams <- b:
+	default: // drop the datagram
+	}
+}
+
+// AcceptStream accepts the next bidirectional stream opened by the peer for this session.
+func (s *WebTransportSession) AcceptStream(ctx context.Context) (quic.Stream, error) {
+	select {
+	case str := <-s.bidiStreams:
+		return str, nil
+	case <-s.ctx.Done():
+		return nil, ErrWebTransportSessionClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// AcceptUniStream accepts the next unidirectional stream opened by the peer for this session.
+func (s *WebTransportSession) AcceptUniStream(ctx context.Context) (quic.ReceiveStream, error) {
+	select {
+	case str := <-s.uniStreams:
+		return str, nil
+	case <-s.ctx.Done():
+		return nil, ErrWebTransportSessionClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *WebTransportSession) streamHeader(t uint64) []byte {
+	buf := &bytes.Buffer{}
+	quicvarint.Write(buf, t)
+	quicvarint.Write(buf, uint64(s.sessionID))
+	return buf.Bytes()
+}
+
+// OpenStream opens a new bidirectional stream associated with this session.
+func (s *WebTransportSession) OpenStream() (quic.Stream, error) {
+	if s.ctx.Err() != nil {
+		return nil, ErrWebTransportSessionClosed
+	}
+	str, err := s.conn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return s.initStream(str)
+}
+
+// OpenStreamSync opens a new bidirectional stream associated with this session.
+// It blocks until a new stream can be opened.
+func (s *WebTransportSession) OpenStreamSync(ctx context.Context) (quic.Stream, error) {
+	if s.ctx.Err() != nil {
+		return nil, ErrWebTransportSessionClosed
+	}
+	str, err := s.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.initStream(str)
+}
+
+func (s *WebTransportSession) initStream(str quic.Stream) (quic.Stream, error) {
+	if _, err := str.Write(s.streamHeader(uint64(frameTypeWebTransportStream))); err != nil {
+		str.CancelRead(quic.StreamErrorCode(errorInternalError))
+		str.CancelWrite(quic.StreamErrorCode(errorInternalError))
+		return nil, err
+	}
+	return str, nil
+}
+
+// OpenUniStream opens a new unidirectional stream associated with this session.
+func (s *WebTransportSession) OpenUniStream() (quic.SendStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, ErrWebTransportSessionClosed
+	}
+	str, err := s.conn.OpenUniStream()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := str.Write(s.streamHeader(streamTypeWebTransportStream)); err != nil {
+		str.CancelWrite(quic.StreamErrorCode(errorInternalError))
+		return nil, err
+	}
+	return str, nil
+}
+
+// SendDatagram sends a datagram associated with this session.
+func (s *WebTransportSession) SendDatagram(b []byte) error {
+	if s.ctx.Err() != nil {
+		return ErrWebTransportSessionClosed
+	}
+	return s.datagrams.send(s.sessionID, b)
+}
+
+// ReceiveDatagram receives a datagram associated with this session.
+func (s *WebTransportSession) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-s.receivedDatagrams:
+		return b, nil
+	case <-s.ctx.Done():
+		return nil, ErrWebTransportSessionClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the session, by closing the CONNECT stream.
+func (s *WebTransportSession) Close() error {
+	s.str.CancelRead(quic.StreamErrorCode(errorNoError))
+	err := s.str.Close()
+	s.close()
+	return err
+}
+
+type bufferedWebTransportStreams struct {
+	bidi  []quic.Stream
+	uni   []quic.ReceiveStream
+	timer *time.Timer
+}
+
+func (b *bufferedWebTransportStreams) Len() int { return len(b.bidi) + len(b.uni) }
+
+func (b *bufferedWebTransportStreams) reject() {
+	for _, str := range b.bidi {
+		str.CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+		str.CancelWrite(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+	}
+	for _, str := range b.uni {
+		str.CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+	}
+}
+
+// webTransportManager associates the WebTransport streams and datagrams of a connection to their sessions.
+// Streams might arrive before the session is established. They are buffered until then,
+// but not longer than the bufferTimeout, and only as long as the request on the CONNECT stream is handled.
+type webTransportManager struct {
+	conn          quic.Connection
+	datagrams     *datagramDemuxer
+	bufferTimeout time.Duration
+
+	mutex       sync.Mutex
+	sessions    map[quic.StreamID]*WebTransportSession
+	buffered    map[quic.StreamID]*bufferedWebTransportStreams
+	numBuffered int // the number of streams buffered for all sessions
+}
+
+func newWebTransportManager(conn quic.Connection, datagrams *datagramDemuxer) *webTransportManager {
+	return &webTransportManager{
+		conn:          conn,
+		datagrams:     datagrams,
+		bufferTimeout: webTransportStreamBufferTimeout,
+		sessions:      make(map[quic.StreamID]*WebTransportSession),
+		buffered:      make(map[quic.StreamID]*bufferedWebTransportStreams),
+	}
+}
+
+// addSession creates a new session for the CONNECT stream str.
+func (m *webTransportManager) addSession(str quic.Stream) *WebTransportSession {
+	id := str.StreamID()
+	sess := newWebTransportSession(m.conn, m.datagrams, str, func() {
+		m.datagrams.unregister(id)
+		m.mutex.Lock()
+		delete(m.sessions, id)
+		m.mutex.Unlock()
+	})
+
+	m.mutex.Lock()
+	m.sessions[id] = sess
+	buffered := m.removeBuffered(id)
+	m.mutex.Unlock()
+
+	if buffered != nil {
+		for _, str := range buffered.bidi {
+			sess.addBidiStream(str)
+		}
+		for _, str := range buffered.uni {
+			sess.addUniStream(str)
+		}
+	}
+	m.datagrams.register(id, sess.addDatagram)
+	return sess
+}
+
+// handleBidiStream handles a bidirectional stream that started with a WEBTRANSPORT_STREAM frame.
+// The frame type was already read from the stream.
+func (m *webTransportManager) handleBidiStream(str quic.Stream) error {
+	id, err := quicvarint.Read(quicvarint.NewReader(str))
+	if err != nil {
+		return err
+	}
+	sessionID := quic.StreamID(id)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if sess, ok := m.sessions[sessionID]; ok {
+		sess.addBidiStream(str)
+		return nil
+	}
+	b := m.buffered[sessionID]
+	if m.numBuffered >= maxBufferedWebTransportStreamsPerConn || (b != nil && len(b.bidi) >= maxBufferedWebTransportStreams) {
+		str.CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+		str.CancelWrite(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+		return nil
+	}
+	if b == nil {
+		b = m.newBuffered(sessionID)
+	}
+	b.bidi = append(b.bidi, str)
+	m.numBuffered++
+	return nil
+}
+
+// handleUniStream handles a unidirectional WebTransport stream.
+// The stream type was already read from the stream.
+func (m *webTransportManager) handleUniStream(str quic.ReceiveStream) error {
+	id, err := quicvarint.Read(quicvarint.NewReader(str))
+	if err != nil {
+		return err
+	}
+	sessionID := quic.StreamID(id)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if sess, ok := m.sessions[sessionID]; ok {
+		sess.addUniStream(str)
+		return nil
+	}
+	b := m.buffered[sessionID]
+	if m.numBuffered >= maxBufferedWebTransportStreamsPerConn || (b != nil && len(b.uni) >= maxBufferedWebTransportStreams) {
+		str.CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+		return nil
+	}
+	if b == nil {
+		b = m.newBuffered(sessionID)
+	}
+	b.uni = append(b.uni, str)
+	m.numBuffered++
+	return nil
+}
+
+// newBuffered buffers streams for a session that was not established yet.
+// If the session isn't established before the buffer timeout, the streams are rejected.
+// It must be called with the mutex held.
+func (m *webTransportManager) newBuffered(id quic.StreamID) *bufferedWebTransportStreams {
+	b := &bufferedWebTransportStreams{}
+	b.timer = time.AfterFunc(m.bufferTimeout, func() {
+		m.mutex.Lock()
+		// The session might have been established in the meantime,
+		// and new streams might have been buffered after it was closed.
+		if m.buffered[id] != b {
+			m.mutex.Unlock()
+			return
+		}
+		m.removeBuffered(id)
+		m.mutex.Unlock()
+		b.reject()
+	})
+	m.buffered[id] = b
+	return b
+}
+
+// removeBuffered removes the streams buffered for a session.
+// It must be called with the mutex held.
+func (m *webTransportManager) removeBuffered(id quic.StreamID) *bufferedWebTransportStreams {
+	b, ok := m.buffered[id]
+	if !ok {
+		return nil
+	}
+	b.timer.Stop()
+	delete(m.buffered, id)
+	m.numBuffered -= b.Len()
+	return b
+}
+
+// requestDone is called when the request on stream id was handled.
+// If it didn't establish a session, the streams buffered for that session are rejected.
+func (m *webTransportManager) requestDone(id quic.StreamID) {
+	m.mutex.Lock()
+	if _, ok := m.sessions[id]; ok {
+		m.mutex.Unlock()
+		return
+	}
+	b := m.removeBuffered(id)
+	m.mutex.Unlock()
+	if b != nil {
+		b.reject()
+	}
+}
+
+// UpgradeWebTransport establishes a WebTransport session for an Extended CONNECT request.
+// It sends a 200 response, after which the request stream is owned by the session.
+// The http.ResponseWriter must be the one passed to the handler by a Server with EnableWebTransport set.
+func UpgradeWebTransport(w http.ResponseWriter, r *http.Request) (*WebTransportSession, error) {
+	if r.Method != http.MethodConnect || r.Proto != protocolWebTransport {
+		return nil, errors.New("http3: not a WebTransport request")
+	}
+	rw, ok := findResponseWriter(w)
+	if !ok {
+		return nil, errors.New("http3: WebTransport upgrade requires the http3 ResponseWriter")
+	}
+	if rw.webTransport == nil {
+		return nil, errors.New("http3: WebTransport not enabled")
+	}
+	rw.WriteHeader(http.StatusOK)
+	return rw.webTransport.addSession(rw.DataStream()), nil
+}
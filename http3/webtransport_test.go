@@ -0,0 +1,307 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebTransport", func() {
+	var (
+		conn       *mockquic.MockEarlyConnection
+		m          *webTransportManager
+		connectStr *mockquic.MockStream
+		strClosed  chan struct{}
+	)
+
+	const sessionID = 4
+
+	getStreamData := func(t uint64, id quic.StreamID) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, t)
+		quicvarint.Write(buf, uint64(id))
+		return buf
+	}
+
+	getDatagram := func(quarterStreamID uint64, data string) []byte {
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, quarterStreamID)
+		buf.WriteString(data)
+		return buf.Bytes()
+	}
+
+	BeforeEach(func() {
+		conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		conn.EXPECT().Context().Return(context.Background()).AnyTimes()
+		conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}).AnyTimes()
+		datagrams := newDatagramDemuxer(conn, utils.DefaultLogger)
+		datagrams.setPeerEnabled(true)
+		m = newWebTransportManager(conn, datagrams)
+		m.bufferTimeout = time.Hour // don't reject buffered streams after the test completed
+		closed := make(chan struct{})
+		strClosed = closed
+		connectStr = mockquic.NewMockStream(mockCtrl)
+		connectStr.EXPECT().StreamID().Return(quic.StreamID(sessionID)).AnyTimes()
+		connectStr.EXPECT().WriteTo(gomock.Any()).DoAndReturn(func(io.Writer) (int64, error) {
+			<-closed
+			return 0, nil
+		}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		select {
+		case <-strClosed:
+		default:
+			close(strClosed)
+		}
+	})
+
+	It("passes streams to the session", func() {
+		sess := m.addSession(connectStr)
+		Expect(sess.SessionID()).To(Equal(quic.StreamID(sessionID)))
+
+		str := mockquic.NewMockStream(mockCtrl)
+		buf := getStreamData(uint64(frameTypeWebTransportStream), sessionID)
+		quicvarint.Read(buf) // the frame type is parsed by the HTTP/3 layer
+		str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+		Expect(m.handleBidiStream(str)).To(Succeed())
+		s, err := sess.AcceptStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+
+		ustr := mockquic.NewMockStream(mockCtrl)
+		ubuf := getStreamData(streamTypeWebTransportStream, sessionID)
+		quicvarint.Read(ubuf)
+		ustr.EXPECT().Read(gomock.Any()).DoAndReturn(ubuf.Read).AnyTimes()
+		Expect(m.handleUniStream(ustr)).To(Succeed())
+		us, err := sess.AcceptUniStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(us).To(Equal(ustr))
+	})
+
+	It("buffers streams that arrive before the session is established", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		buf := getStreamData(uint64(frameTypeWebTransportStream), sessionID)
+		quicvarint.Read(buf)
+		str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+		Expect(m.handleBidiStream(str)).To(Succeed())
+
+		sess := m.addSession(connectStr)
+		s, err := sess.AcceptStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+	})
+
+	It("limits the number of buffered streams", func() {
+		for i := 0; i < maxBufferedWebTransportStreams; i++ {
+			str := mockquic.NewMockStream(mockCtrl)
+			buf := getStreamData(streamTypeWebTransportStream, sessionID)
+			quicvarint.Read(buf)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			Expect(m.handleUniStream(str)).To(Succeed())
+		}
+		str := mockquic.NewMockStream(mockCtrl)
+		buf := getStreamData(streamTypeWebTransportStream, sessionID)
+		quicvarint.Read(buf)
+		str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+		str.EXPECT().CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+		Expect(m.handleUniStream(str)).To(Succeed())
+	})
+
+	Context("rejecting buffered streams", func() {
+		newUniStream := func(id quic.StreamID) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			buf := getStreamData(streamTypeWebTransportStream, id)
+			quicvarint.Read(buf)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			return str
+		}
+
+		newBidiStream := func(id quic.StreamID) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			buf := getStreamData(uint64(frameTypeWebTransportStream), id)
+			quicvarint.Read(buf)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			return str
+		}
+
+		It("limits the number of buffered streams for all sessions", func() {
+			for i := 0; i < maxBufferedWebTransportStreamsPerConn; i++ {
+				Expect(m.handleUniStream(newUniStream(quic.StreamID(4 * i)))).To(Succeed())
+			}
+			str := newBidiStream(1000)
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+			Expect(m.handleBidiStream(str)).To(Succeed())
+			Expect(m.buffered).ToNot(HaveKey(quic.StreamID(1000)))
+			// once the streams are passed to the session, new streams can be buffered again
+			m.addSession(connectStr)
+			Expect(m.handleBidiStream(newBidiStream(1000))).To(Succeed())
+			Expect(m.buffered).To(HaveKey(quic.StreamID(1000)))
+		})
+
+		It("rejects buffered streams when the request didn't establish a session", func() {
+			str := newBidiStream(sessionID)
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+			Expect(m.handleBidiStream(str)).To(Succeed())
+			ustr := newUniStream(sessionID)
+			ustr.EXPECT().CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected))
+			Expect(m.handleUniStream(ustr)).To(Succeed())
+			Expect(m.handleUniStream(newUniStream(sessionID + 4))).To(Succeed())
+			m.requestDone(sessionID)
+			Expect(m.buffered).To(HaveLen(1))
+			Expect(m.numBuffered).To(Equal(1))
+		})
+
+		It("doesn't reject streams when the request established a session", func() {
+			sess := m.addSession(connectStr)
+			Expect(m.handleBidiStream(newBidiStream(sessionID))).To(Succeed())
+			m.requestDone(sessionID)
+			_, err := sess.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("removes the buffered streams when the session is established", func() {
+			Expect(m.handleBidiStream(newBidiStream(sessionID))).To(Succeed())
+			Expect(m.handleUniStream(newUniStream(sessionID))).To(Succeed())
+			Expect(m.numBuffered).To(Equal(2))
+			m.addSession(connectStr)
+			Expect(m.buffered).To(BeEmpty())
+			Expect(m.numBuffered).To(BeZero())
+		})
+
+		It("rejects buffered streams after a timeout", func() {
+			m.bufferTimeout = scaleDuration(20 * time.Millisecond)
+			rejected := make(chan struct{})
+			str := newUniStream(sessionID)
+			str.EXPECT().CancelRead(quic.StreamErrorCode(errorWebTransportBufferedStreamRejected)).Do(func(quic.StreamErrorCode) { close(rejected) })
+			Expect(m.handleUniStream(str)).To(Succeed())
+			Consistently(rejected, scaleDuration(10*time.Millisecond)).ShouldNot(BeClosed())
+			Eventually(rejected).Should(BeClosed())
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			Expect(m.buffered).To(BeEmpty())
+			Expect(m.numBuffered).To(BeZero())
+		})
+	})
+
+	It("opens streams", func() {
+		sess := m.addSession(connectStr)
+		str := mockquic.NewMockStream(mockCtrl)
+		buf := &bytes.Buffer{}
+		str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write)
+		conn.EXPECT().OpenStream().Return(str, nil)
+		s, err := sess.OpenStream()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+		Expect(buf.Bytes()).To(Equal(getStreamData(uint64(frameTypeWebTransportStream), sessionID).Bytes()))
+
+		ustr := mockquic.NewMockStream(mockCtrl)
+		buf.Reset()
+		ustr.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write)
+		conn.EXPECT().OpenUniStream().Return(ustr, nil)
+		us, err := sess.OpenUniStream()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(us).To(Equal(ustr))
+		Expect(buf.Bytes()).To(Equal(getStreamData(streamTypeWebTransportStream, sessionID).Bytes()))
+	})
+
+	It("sends datagrams", func() {
+		sess := m.addSession(connectStr)
+		conn.EXPECT().SendMessage(getDatagram(sessionID/4, "foobar"))
+		Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+	})
+
+	It("receives datagrams", func() {
+		conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		conn.EXPECT().Context().Return(context.Background()).AnyTimes()
+		conn.EXPECT().ConnectionState().Return(quic.ConnectionState{SupportsDatagrams: true}).AnyTimes()
+		m = newWebTransportManager(conn, newDatagramDemuxer(conn, utils.DefaultLogger))
+		datagrams := make(chan []byte, 3)
+		// datagram for an unknown session
+		datagrams <- getDatagram(42, "foo")
+		datagrams <- getDatagram(sessionID/4, "bar")
+		conn.EXPECT().ReceiveMessage().DoAndReturn(func() ([]byte, error) {
+			b, ok := <-datagrams
+			if !ok {
+				return nil, io.EOF
+			}
+			return b, nil
+		}).AnyTimes()
+		defer close(datagrams)
+		sess := m.addSession(connectStr)
+		b, err := sess.ReceiveDatagram(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b).To(Equal([]byte("bar")))
+	})
+
+	It("closes the session when the CONNECT stream is closed", func() {
+		sess := m.addSession(connectStr)
+		close(strClosed)
+		Eventually(sess.Context().Done()).Should(BeClosed())
+		_, err := sess.AcceptStream(context.Background())
+		Expect(err).To(MatchError(ErrWebTransportSessionClosed))
+		_, err = sess.OpenStream()
+		Expect(err).To(MatchError(ErrWebTransportSessionClosed))
+		m.mutex.Lock()
+		Expect(m.sessions).To(BeEmpty())
+		m.mutex.Unlock()
+	})
+
+	It("closes the session", func() {
+		sess := m.addSession(connectStr)
+		connectStr.EXPECT().CancelRead(quic.StreamErrorCode(errorNoError))
+		connectStr.EXPECT().Close()
+		Expect(sess.Close()).To(Succeed())
+		Expect(sess.Context().Done()).To(BeClosed())
+	})
+
+	Context("upgrading", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest(http.MethodConnect, "https://quic.clemente.io/wt", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Proto = protocolWebTransport
+		})
+
+		It("upgrades a request", func() {
+			connectStr.EXPECT().Write(gomock.Any()).AnyTimes()
+			rw := newResponseWriter(connectStr, conn, utils.DefaultLogger)
+			rw.webTransport = m
+			sess, err := UpgradeWebTransport(rw, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.SessionID()).To(Equal(quic.StreamID(sessionID)))
+			Expect(rw.usedDataStream()).To(BeTrue())
+			Expect(rw.status).To(Equal(http.StatusOK))
+		})
+
+		It("rejects requests that are not WebTransport requests", func() {
+			req.Proto = "connect-udp"
+			rw := newResponseWriter(connectStr, conn, utils.DefaultLogger)
+			rw.webTransport = m
+			_, err := UpgradeWebTransport(rw, req)
+			Expect(err).To(MatchError("http3: not a WebTransport request"))
+		})
+
+		It("errors when WebTransport is not enabled", func() {
+			rw := newResponseWriter(connectStr, conn, utils.DefaultLogger)
+			_, err := UpgradeWebTransport(rw, req)
+			Expect(err).To(MatchError("http3: WebTransport not enabled"))
+		})
+	})
+})
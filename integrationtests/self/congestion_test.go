@@ -0,0 +1,71 @@
+package self_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	quicproxy "github.com/lucas-clemente/quic-go/integrationtests/tools/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Congestion Control", func() {
+	for _, a := range []struct {
+		name      string
+		algorithm quic.CongestionControlAlgorithm
+	}{
+		{name: "NewReno", algorithm: quic.CongestionControlNewReno},
+		{name: "CUBIC", algorithm: quic.CongestionControlCubic},
+		{name: "BBR", algorithm: quic.CongestionControlBBR},
+	} {
+		algorithm := a.algorithm
+
+		It(fmt.Sprintf("transfers data using %s, on a lossy path", a.name), func() {
+			ln, err := quic.ListenAddr(
+				"localhost:0",
+				getTLSConfig(),
+				getQuicConfig(&quic.Config{CongestionControl: algorithm}),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			defer ln.Close()
+			go func() {
+				defer GinkgoRecover()
+				conn, err := ln.Accept(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				str, err := conn.OpenStream()
+				Expect(err).ToNot(HaveOccurred())
+				_, err = str.Write(PRData)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+			}()
+
+			proxy, err := quicproxy.NewQuicProxy("localhost:0", &quicproxy.Opts{
+				RemoteAddr:  fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+				DelayPacket: func(quicproxy.Direction, []byte) time.Duration { return 10 * time.Millisecond },
+				// drop 2% of the packets
+				DropPacket: func(quicproxy.Direction, []byte) bool { return rand.Intn(50) == 0 },
+			})
+			Expect(err).ToNot(HaveOccurred())
+			defer proxy.Close()
+
+			conn, err := quic.DialAddr(
+				fmt.Sprintf("localhost:%d", proxy.LocalPort()),
+				getTLSClientConfig(),
+				getQuicConfig(&quic.Config{CongestionControl: algorithm}),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.CloseWithError(0, "")
+			str, err := conn.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			data, err := io.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(PRData))
+		})
+	}
+})
@@ -2,10 +2,12 @@ package self_test
 
 import (
 	"context"
+	crand "crypto/rand"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
+	"sync/atomic"
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -14,6 +16,26 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+// A prefixConnIDGenerator generates connection IDs that start with a fixed prefix,
+// as a QUIC-LB load balancer would use to route packets.
+type prefixConnIDGenerator struct {
+	prefix       []byte
+	connIDLen    int
+	numGenerated int32 // accessed atomically
+}
+
+func (g *prefixConnIDGenerator) GenerateConnectionID() (quic.ConnectionID, error) {
+	atomic.AddInt32(&g.numGenerated, 1)
+	b := make([]byte, g.connIDLen)
+	copy(b, g.prefix)
+	if _, err := crand.Read(b[len(g.prefix):]); err != nil {
+		return nil, err
+	}
+	return quic.ConnectionID(b), nil
+}
+
+func (g *prefixConnIDGenerator) ConnectionIDLen() int { return g.connIDLen }
+
 var _ = Describe("Connection ID lengths tests", func() {
 	randomConnIDLen := func() int {
 		return 4 + int(rand.Int31n(15))
@@ -87,4 +109,22 @@ var _ = Describe("Connection ID lengths tests", func() {
 		defer ln.Close()
 		runClient(ln.Addr(), clientConf)
 	})
+
+	It("downloads a file using a custom connection ID generator", func() {
+		gen := &prefixConnIDGenerator{prefix: []byte{0xde, 0xca, 0xfb, 0xad}, connIDLen: 8 + int(rand.Int31n(11))}
+		serverConf := getQuicConfig(&quic.Config{
+			ConnectionIDGenerator: gen,
+			Versions:              []protocol.VersionNumber{protocol.VersionTLS},
+		})
+		clientConf := getQuicConfig(&quic.Config{
+			ConnectionIDLength: randomConnIDLen(),
+			Versions:           []protocol.VersionNumber{protocol.VersionTLS},
+		})
+
+		ln := runServer(serverConf)
+		defer ln.Close()
+		runClient(ln.Addr(), clientConf)
+		// one connection ID for the handshake, and at least one issued in a NEW_CONNECTION_ID frame
+		Expect(atomic.LoadInt32(&gen.numGenerated)).To(BeNumerically(">", 1))
+	})
 })
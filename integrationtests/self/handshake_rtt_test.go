@@ -125,6 +125,32 @@ var _ = Describe("Handshake RTT tests", func() {
 		expectDurationInRTTs(1)
 	})
 
+	It("establishes a connection in 1 RTT when the server doesn't require address validation for the client", func() {
+		serverConfig.RequireAddressValidation = func(addr net.Addr) bool {
+			return !addr.(*net.UDPAddr).IP.IsLoopback()
+		}
+		runServerAndProxy()
+		_, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", proxy.LocalAddr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			clientConfig,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		expectDurationInRTTs(1)
+	})
+
+	It("establishes a connection in 2 RTTs when the server requires address validation for the client", func() {
+		serverConfig.RequireAddressValidation = func(net.Addr) bool { return true }
+		runServerAndProxy()
+		_, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", proxy.LocalAddr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			clientConfig,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		expectDurationInRTTs(2)
+	})
+
 	It("establishes a connection in 2 RTTs if a HelloRetryRequest is performed", func() {
 		serverConfig.AcceptToken = func(_ net.Addr, _ *quic.Token) bool {
 			return true
@@ -138,6 +138,7 @@ var _ = Describe("Handshake tests", func() {
 				)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(conn.(versioner).GetVersion()).To(Equal(expectedVersion))
+				Expect(conn.ConnectionState().Version).To(Equal(expectedVersion))
 				Expect(conn.CloseWithError(0, "")).To(Succeed())
 				Expect(clientTracer.chosen).To(Equal(expectedVersion))
 				Expect(clientTracer.receivedVersionNegotiation).To(BeFalse())
@@ -247,6 +248,18 @@ var _ = Describe("Handshake tests", func() {
 					Expect(err).ToNot(HaveOccurred())
 				})
 
+				It("accepts the certificate when racing connection attempts", func() {
+					runServer(getTLSConfig())
+					conn, err := quic.DialAddrHappyEyeballs(
+						fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+						getTLSClientConfig(),
+						clientConfig,
+					)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(conn.RemoteAddr().(*net.UDPAddr).Port).To(Equal(server.Addr().(*net.UDPAddr).Port))
+					conn.CloseWithError(0, "")
+				})
+
 				It("works with a long certificate chain", func() {
 					runServer(getTLSConfigWithLongCertChain())
 					_, err := quic.DialAddr(
@@ -430,6 +443,37 @@ var _ = Describe("Handshake tests", func() {
 		})
 	})
 
+	It("exports the same keying material on both sides", func() {
+		ln, err := quic.ListenAddr("localhost:0", getTLSConfig(), serverConfig)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+
+		serverKeyingMaterial := make(chan []byte, 1)
+		go func() {
+			defer GinkgoRecover()
+			conn, err := ln.Accept(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			ekm, err := conn.ConnectionState().ExportKeyingMaterial("EXPORTER-quic-go-test", []byte("context"), 32)
+			Expect(err).ToNot(HaveOccurred())
+			serverKeyingMaterial <- ekm
+		}()
+
+		conn, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			nil,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.CloseWithError(0, "")
+		ekm, err := conn.ConnectionState().ExportKeyingMaterial("EXPORTER-quic-go-test", []byte("context"), 32)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ekm).To(HaveLen(32))
+		Eventually(serverKeyingMaterial).Should(Receive(Equal(ekm)))
+		otherEKM, err := conn.ConnectionState().ExportKeyingMaterial("EXPORTER-quic-go-other", []byte("context"), 32)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(otherEKM).ToNot(Equal(ekm))
+	})
+
 	Context("ALPN", func() {
 		It("negotiates an application protocol", func() {
 			ln, err := quic.ListenAddr("localhost:0", getTLSConfig(), serverConfig)
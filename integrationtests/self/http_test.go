@@ -11,13 +11,20 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/lucas-clemente/quic-go/http3/doh"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"golang.org/x/net/dns/dnsmessage"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -29,6 +36,7 @@ var _ = Describe("HTTP tests", func() {
 		mux            *http.ServeMux
 		client         *http.Client
 		server         *http3.Server
+		serverConn     net.PacketConn
 		stoppedServing chan struct{}
 		port           string
 	)
@@ -80,6 +88,7 @@ var _ = Describe("HTTP tests", func() {
 		conn, err := net.ListenUDP("udp", addr)
 		Expect(err).NotTo(HaveOccurred())
 		port = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+		serverConn = conn
 
 		stoppedServing = make(chan struct{})
 
@@ -224,6 +233,168 @@ var _ = Describe("HTTP tests", func() {
 				Expect(body).To(Equal(PRData))
 			})
 
+			It("sends request trailers", func() {
+				mux.HandleFunc("/trailers/request", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					body, err := io.ReadAll(r.Body)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(body).To(Equal([]byte("foobar")))
+					w.Write([]byte(r.Trailer.Get("Checksum")))
+				})
+
+				req, err := http.NewRequest(http.MethodPost, "https://localhost:"+port+"/trailers/request", bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				req.Trailer = http.Header{"Checksum": []string{"3858f62230ac3c91"}}
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 5*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("3858f62230ac3c91"))
+			})
+
+			It("receives response trailers", func() {
+				mux.HandleFunc("/trailers/response", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Trailer", "Grpc-Status")
+					w.Write([]byte("foobar"))
+					w.Header().Set("Grpc-Status", "0")
+					w.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+				})
+
+				resp, err := client.Get("https://localhost:" + port + "/trailers/response")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(resp.Trailer).To(Equal(http.Header{"Grpc-Status": nil}))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 5*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+				Expect(resp.Trailer).To(Equal(http.Header{
+					"Grpc-Status":  []string{"0"},
+					"Grpc-Message": []string{"ok"},
+				}))
+			})
+
+			It("sends the request body after receiving 100 Continue", func() {
+				client.Transport.(*http3.RoundTripper).ExpectContinueTimeout = time.Hour
+				req, err := http.NewRequest(http.MethodPost, "https://localhost:"+port+"/echo", bytes.NewReader([]byte("Hello, world!")))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Expect", "100-continue")
+				got100Continue := make(chan struct{})
+				req = req.WithContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+					Got100Continue: func() { close(got100Continue) },
+				}))
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(got100Continue).To(BeClosed())
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 5*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(Equal([]byte("Hello, world!")))
+			})
+
+			It("receives informational responses", func() {
+				mux.HandleFunc("/early-hints", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+					w.WriteHeader(http.StatusEarlyHints)
+					w.Header().Del("Link")
+					w.Write([]byte("foobar"))
+				})
+
+				var codes []int
+				var links []string
+				req, err := http.NewRequest(http.MethodGet, "https://localhost:"+port+"/early-hints", nil)
+				Expect(err).ToNot(HaveOccurred())
+				req = req.WithContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+					Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+						codes = append(codes, code)
+						links = append(links, header.Get("Link"))
+						return nil
+					},
+				}))
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(resp.Header).ToNot(HaveKey("Link"))
+				Expect(codes).To(Equal([]int{http.StatusEarlyHints}))
+				Expect(links).To(Equal([]string{"</style.css>; rel=preload; as=style"}))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 5*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+			})
+
+			It("sends 103 Early Hints before the final response", func() {
+				mux.HandleFunc("/103", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Add("Link", "</style.css>; rel=preload; as=style")
+					w.Header().Add("Link", "</script.js>; rel=preload; as=script")
+					w.WriteHeader(http.StatusEarlyHints)
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte("foobar"))
+				})
+
+				type earlyHints struct {
+					code   int
+					header textproto.MIMEHeader
+				}
+				var received []earlyHints
+				req, err := http.NewRequest(http.MethodGet, "https://localhost:"+port+"/103", nil)
+				Expect(err).ToNot(HaveOccurred())
+				req = req.WithContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+					Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+						received = append(received, earlyHints{code: code, header: header})
+						return nil
+					},
+				}))
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(received).To(HaveLen(1))
+				Expect(received[0].code).To(Equal(http.StatusEarlyHints))
+				Expect(received[0].header["Link"]).To(Equal([]string{
+					"</style.css>; rel=preload; as=style",
+					"</script.js>; rel=preload; as=script",
+				}))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 5*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+			})
+
+			It("calls the httptrace hooks", func() {
+				var mutex sync.Mutex
+				var events []string
+				addEvent := func(e string) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					events = append(events, e)
+				}
+				trace := &httptrace.ClientTrace{
+					GetConn:              func(string) { addEvent("GetConn") },
+					TLSHandshakeStart:    func() { addEvent("TLSHandshakeStart") },
+					TLSHandshakeDone:     func(tls.ConnectionState, error) { addEvent("TLSHandshakeDone") },
+					GotConn:              func(httptrace.GotConnInfo) { addEvent("GotConn") },
+					WroteHeaders:         func() { addEvent("WroteHeaders") },
+					WroteRequest:         func(httptrace.WroteRequestInfo) { addEvent("WroteRequest") },
+					GotFirstResponseByte: func() { addEvent("GotFirstResponseByte") },
+				}
+				req, err := http.NewRequest(http.MethodPost, "https://localhost:"+port+"/echo", bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(context.Background(), trace)))
+				Expect(err).ToNot(HaveOccurred())
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 5*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+				mutex.Lock()
+				defer mutex.Unlock()
+				Expect(events).To(Equal([]string{
+					"GetConn",
+					"TLSHandshakeStart",
+					"TLSHandshakeDone",
+					"GotConn",
+					"WroteHeaders",
+					"WroteRequest",
+					"GotFirstResponseByte",
+				}))
+			})
+
 			It("uses gzip compression", func() {
 				mux.HandleFunc("/gzipped/hello", func(w http.ResponseWriter, r *http.Request) {
 					defer GinkgoRecover()
@@ -276,6 +447,229 @@ var _ = Describe("HTTP tests", func() {
 				Expect(err).To(HaveOccurred())
 			})
 
+			It("completes active requests when shutting down", func() {
+				handlerCalled := make(chan struct{})
+				unblock := make(chan struct{})
+				mux.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
+					close(handlerCalled)
+					<-unblock
+					w.Write([]byte("foobar"))
+				})
+
+				type result struct {
+					body []byte
+					err  error
+				}
+				resChan := make(chan result, 1)
+				go func() {
+					defer GinkgoRecover()
+					resp, err := client.Get("https://localhost:" + port + "/shutdown")
+					if err != nil {
+						resChan <- result{err: err}
+						return
+					}
+					body, err := io.ReadAll(resp.Body)
+					resChan <- result{body: body, err: err}
+				}()
+				Eventually(handlerCalled).Should(BeClosed())
+
+				shutdownErr := make(chan error, 1)
+				go func() {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					shutdownErr <- server.Shutdown(ctx)
+				}()
+				Consistently(shutdownErr, scaleDuration(50*time.Millisecond)).ShouldNot(Receive())
+				close(unblock)
+				var res result
+				Eventually(resChan).Should(Receive(&res))
+				Expect(res.err).ToNot(HaveOccurred())
+				Expect(string(res.body)).To(Equal("foobar"))
+				Eventually(shutdownErr).Should(Receive(BeNil()))
+			})
+
+			It("retries requests when the server restarts", func() {
+				resp, err := client.Get("https://localhost:" + port + "/hello")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(resp.Body.Close()).To(Succeed())
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				Expect(server.Shutdown(ctx)).To(Succeed())
+				Eventually(stoppedServing).Should(BeClosed())
+
+				// start a new server on the same UDP socket
+				server = &http3.Server{
+					Server:     server.Server,
+					QuicConfig: server.QuicConfig,
+				}
+				stopped := make(chan struct{})
+				stoppedServing = stopped
+				go func() {
+					defer GinkgoRecover()
+					server.Serve(serverConn)
+					close(stopped)
+				}()
+
+				resp, err = client.Get("https://localhost:" + port + "/hello")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("Hello, World!\n"))
+			})
+
+			It("closes idle connections", func() {
+				unblock := make(chan struct{})
+				mux.HandleFunc("/blocking", func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(200)
+					w.(http.Flusher).Flush()
+					<-unblock
+					w.Write([]byte("foobar"))
+				})
+
+				get := func(path string) (*http.Response, bool) {
+					var reused bool
+					ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+						GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+					})
+					req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://localhost:"+port+path, nil)
+					Expect(err).ToNot(HaveOccurred())
+					resp, err := client.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+					return resp, reused
+				}
+
+				resp, reused := get("/hello")
+				Expect(reused).To(BeFalse())
+				Expect(resp.Body.Close()).To(Succeed())
+				resp, reused = get("/hello")
+				Expect(reused).To(BeTrue())
+				Expect(resp.Body.Close()).To(Succeed())
+
+				// connections with active requests are not closed
+				resp, reused = get("/blocking")
+				Expect(reused).To(BeTrue())
+				client.CloseIdleConnections()
+				close(unblock)
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+				Expect(resp.Body.Close()).To(Succeed())
+
+				// give the client some time to notice that the request completed
+				Eventually(func() bool {
+					client.CloseIdleConnections()
+					resp, reused := get("/hello")
+					resp.Body.Close()
+					return reused
+				}).Should(BeFalse())
+			})
+
+			It("upgrades to HTTP/3 using Alt-Svc", func() {
+				mux.HandleFunc("/proto", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					Expect(server.SetQuicHeaders(w.Header())).To(Succeed())
+					io.WriteString(w, r.Proto)
+				})
+				ln, err := tls.Listen("tcp", "localhost:0", testdata.GetTLSConfig())
+				Expect(err).ToNot(HaveOccurred())
+				tcpServer := &http.Server{Handler: mux}
+				go tcpServer.Serve(ln)
+				defer tcpServer.Close()
+
+				rt := &http3.AltSvcRoundTripper{
+					Fallback: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()}},
+					H3:       client.Transport.(*http3.RoundTripper),
+				}
+				defer rt.Close()
+				cl := &http.Client{Transport: rt}
+				get := func() string {
+					resp, err := cl.Get(fmt.Sprintf("https://localhost:%d/proto", ln.Addr().(*net.TCPAddr).Port))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+					body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Body.Close()).To(Succeed())
+					return string(body)
+				}
+				Expect(get()).To(Equal("HTTP/1.1"))
+				Expect(get()).To(Equal("HTTP/3"))
+				Expect(get()).To(Equal("HTTP/3"))
+			})
+
+			It("races HTTP/3 against TCP", func() {
+				mux.HandleFunc("/proto", func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, r.Proto)
+				})
+				// listen on the same port as the HTTP/3 server
+				ln, err := tls.Listen("tcp", "localhost:"+port, testdata.GetTLSConfig())
+				Expect(err).ToNot(HaveOccurred())
+				tcpServer := &http.Server{Handler: mux}
+				go tcpServer.Serve(ln)
+				defer tcpServer.Close()
+				// this port is not used by any HTTP/3 server
+				lnNoQUIC, err := tls.Listen("tcp", "localhost:0", testdata.GetTLSConfig())
+				Expect(err).ToNot(HaveOccurred())
+				tcpServerNoQUIC := &http.Server{Handler: mux}
+				go tcpServerNoQUIC.Serve(lnNoQUIC)
+				defer tcpServerNoQUIC.Close()
+
+				rt := &http3.RacingRoundTripper{
+					Fallback:  &http.Transport{TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()}},
+					H3:        client.Transport.(*http3.RoundTripper),
+					HeadStart: scaleDuration(time.Second),
+				}
+				defer rt.Close()
+				cl := &http.Client{Transport: rt}
+				get := func(port int) string {
+					resp, err := cl.Get(fmt.Sprintf("https://localhost:%d/proto", port))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+					body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Body.Close()).To(Succeed())
+					return string(body)
+				}
+				Expect(get(ln.Addr().(*net.TCPAddr).Port)).To(Equal("HTTP/3"))
+				Expect(get(ln.Addr().(*net.TCPAddr).Port)).To(Equal("HTTP/3"))
+				start := time.Now()
+				Expect(get(lnNoQUIC.Addr().(*net.TCPAddr).Port)).To(Equal("HTTP/1.1"))
+				Expect(time.Since(start)).To(BeNumerically(">=", rt.HeadStart))
+			})
+
+			It("sends requests on a ClientConn", func() {
+				alpn := "h3-29"
+				if version == protocol.Version1 {
+					alpn = "h3"
+				}
+				conn, err := quic.DialAddrEarly(
+					"localhost:"+port,
+					&tls.Config{RootCAs: testdata.GetRootCA(), NextProtos: []string{alpn}},
+					getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				rt := client.Transport.(*http3.RoundTripper)
+				cc, err := rt.NewClientConn(conn)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cc.ReserveNewRequest()).To(BeTrue())
+				req, err := http.NewRequest(http.MethodGet, "https://localhost:"+port+"/hello", nil)
+				Expect(err).ToNot(HaveOccurred())
+				resp, err := cc.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("Hello, World!\n"))
+				Expect(resp.Body.Close()).To(Succeed())
+				Eventually(func() int { return cc.State().ActiveRequests }).Should(BeZero())
+				Expect(cc.Shutdown(context.Background())).To(Succeed())
+				Eventually(conn.Context().Done()).Should(BeClosed())
+				Expect(cc.State().Closed).To(BeTrue())
+			})
+
 			It("allows streamed HTTP requests", func() {
 				done := make(chan struct{})
 				mux.HandleFunc("/echoline", func(w http.ResponseWriter, r *http.Request) {
@@ -313,6 +707,828 @@ var _ = Describe("HTTP tests", func() {
 				Expect(req.Body.Close()).To(Succeed())
 				Eventually(done).Should(BeClosed())
 			})
+
+			It("exposes the peer's SETTINGS", func() {
+				mux.HandleFunc("/settings", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					settingser := w.(http3.Settingser)
+					Eventually(settingser.ReceivedSettings()).Should(BeClosed())
+					fmt.Fprintf(w, "%d", settingser.Settings().Other[0x1337])
+				})
+
+				client.Transport.(*http3.RoundTripper).AdditionalSettings = map[uint64]uint64{0x1337: 42}
+				rsp, err := client.Get("https://localhost:" + port + "/settings")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(200))
+				settingser := rsp.Body.(http3.Settingser)
+				Eventually(settingser.ReceivedSettings()).Should(BeClosed())
+				Expect(settingser.Settings().EnableExtendedConnect).To(BeTrue())
+				body, err := io.ReadAll(gbytes.TimeoutReader(rsp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("42"))
+			})
+
+			It("sends Extended CONNECT requests", func() {
+				mux.HandleFunc("/chat", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					Expect(r.Method).To(Equal(http.MethodConnect))
+					Expect(r.Proto).To(Equal("websocket"))
+					Expect(r.URL.Query().Get("room")).To(Equal("42"))
+					w.WriteHeader(http.StatusOK)
+				})
+
+				req, err := http.NewRequest(http.MethodConnect, "https://localhost:"+port+"/chat?room=42", nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Proto = "websocket"
+				rsp, err := client.Transport.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+			})
+
+			It("tunnels TCP using CONNECT", func() {
+				// a TCP echo server
+				target, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				defer target.Close()
+				go func() {
+					for {
+						c, err := target.Accept()
+						if err != nil {
+							return
+						}
+						go func() {
+							defer c.Close()
+							io.Copy(c, c)
+						}()
+					}
+				}()
+
+				proxy := &http3.Server{
+					Server: &http.Server{
+						Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							defer GinkgoRecover()
+							Expect(r.Host).To(Equal(target.Addr().String()))
+							c, err := net.Dial("tcp", r.Host)
+							Expect(err).ToNot(HaveOccurred())
+							defer c.Close()
+							tunnel, err := http3.UpgradeConnect(w, r)
+							Expect(err).ToNot(HaveOccurred())
+							defer tunnel.Close()
+							go io.Copy(c, tunnel)
+							io.Copy(tunnel, c)
+						}),
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig: getQuicConfig(&quic.Config{Versions: versions}),
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					proxy.Serve(conn)
+				}()
+				defer func() {
+					Expect(proxy.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				r, w := io.Pipe()
+				req, err := http.NewRequest(http.MethodConnect, fmt.Sprintf("https://localhost:%d", conn.LocalAddr().(*net.UDPAddr).Port), r)
+				Expect(err).ToNot(HaveOccurred())
+				req.Host = target.Addr().String()
+				rsp, err := client.Transport.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				defer rsp.Body.Close()
+
+				reader := bufio.NewReader(gbytes.TimeoutReader(rsp.Body, 5*time.Second))
+				for i := 0; i < 5; i++ {
+					msg := fmt.Sprintf("Hello world, %d!\n", i)
+					fmt.Fprint(w, msg)
+					msgRcvd, err := reader.ReadString('\n')
+					Expect(err).ToNot(HaveOccurred())
+					Expect(msgRcvd).To(Equal(msg))
+				}
+				Expect(w.Close()).To(Succeed())
+			})
+
+			It("receives pushed responses", func() {
+				mux.HandleFunc("/push", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					Expect(w.(http.Pusher).Push("/hello", nil)).To(Succeed())
+					io.WriteString(w, "pushed")
+				})
+
+				type pushedResponse struct {
+					req  *http.Request
+					body []byte
+				}
+				pushes := make(chan pushedResponse, 1)
+				client.Transport.(*http3.RoundTripper).PushHandler = func(req *http.Request, rsp *http.Response) {
+					defer GinkgoRecover()
+					defer rsp.Body.Close()
+					Expect(rsp.StatusCode).To(Equal(200))
+					body, err := io.ReadAll(gbytes.TimeoutReader(rsp.Body, 3*time.Second))
+					Expect(err).ToNot(HaveOccurred())
+					pushes <- pushedResponse{req: req, body: body}
+				}
+				resp, err := client.Get("https://localhost:" + port + "/push")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("pushed"))
+				var push pushedResponse
+				Eventually(pushes).Should(Receive(&push))
+				Expect(push.req.Method).To(Equal(http.MethodGet))
+				Expect(push.req.URL.Path).To(Equal("/hello"))
+				Expect(string(push.body)).To(Equal("Hello, World!\n"))
+			})
+
+			It("doesn't push if the client didn't enable server push", func() {
+				pushErr := make(chan error, 1)
+				mux.HandleFunc("/push", func(w http.ResponseWriter, r *http.Request) {
+					pushErr <- w.(http.Pusher).Push("/hello", nil)
+				})
+				resp, err := client.Get("https://localhost:" + port + "/push")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Eventually(pushErr).Should(Receive(MatchError(http.ErrNotSupported)))
+			})
+
+			It("establishes WebTransport sessions", func() {
+				wtMux := http.NewServeMux()
+				wtMux.HandleFunc("/wt", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					sess, err := http3.UpgradeWebTransport(w, r)
+					Expect(err).ToNot(HaveOccurred())
+					go func() {
+						defer GinkgoRecover()
+						// echo datagrams
+						for {
+							b, err := sess.ReceiveDatagram(context.Background())
+							if err != nil {
+								return
+							}
+							Expect(sess.SendDatagram(b)).To(Succeed())
+						}
+					}()
+					// echo the first stream on a unidirectional stream
+					str, err := sess.AcceptStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					data, err := io.ReadAll(str)
+					Expect(err).ToNot(HaveOccurred())
+					ustr, err := sess.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = ustr.Write(data)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ustr.Close()).To(Succeed())
+				})
+				wtServer := &http3.Server{
+					Server: &http.Server{
+						Handler:   wtMux,
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:         getQuicConfig(&quic.Config{Versions: versions}),
+					EnableWebTransport: true,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					wtServer.Serve(conn)
+				}()
+				defer func() {
+					Expect(wtServer.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig:    &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:         getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					EnableWebTransport: true,
+				}
+				defer rt.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				wtPort := conn.LocalAddr().(*net.UDPAddr).Port
+				rsp, sess, err := rt.DialWebTransport(ctx, fmt.Sprintf("https://localhost:%d/wt", wtPort), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				defer sess.Close()
+
+				str, err := sess.OpenStream()
+				Expect(err).ToNot(HaveOccurred())
+				_, err = str.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+				ustr, err := sess.AcceptUniStream(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				data, err := io.ReadAll(ustr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(data)).To(Equal("foobar"))
+
+				Expect(sess.SendDatagram([]byte("datagram"))).To(Succeed())
+				b, err := sess.ReceiveDatagram(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(b)).To(Equal("datagram"))
+			})
+
+			It("sends and receives HTTP datagrams", func() {
+				mux := http.NewServeMux()
+				mux.HandleFunc("/datagrams", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					w.WriteHeader(http.StatusOK)
+					str, err := w.(http3.DatagramStreamer).DatagramStream()
+					Expect(err).ToNot(HaveOccurred())
+					defer str.Close()
+					// echo all datagrams, until the client closes the stream
+					go func() {
+						for {
+							b, err := str.ReceiveDatagram(context.Background())
+							if err != nil {
+								return
+							}
+							str.SendDatagram(b)
+						}
+					}()
+					io.Copy(io.Discard, str)
+				})
+				server := &http3.Server{
+					Server: &http.Server{
+						Handler:   mux,
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: versions}),
+					EnableDatagrams: true,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					server.Serve(conn)
+				}()
+				defer func() {
+					Expect(server.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					EnableDatagrams: true,
+				}
+				defer rt.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				req, err := http.NewRequestWithContext(ctx, http.MethodConnect, fmt.Sprintf("https://localhost:%d/datagrams", conn.LocalAddr().(*net.UDPAddr).Port), nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Proto = "datagram-echo"
+				rsp, str, err := rt.OpenDatagramStream(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				defer str.Close()
+				Expect(str.SendDatagram([]byte("foobar"))).To(Succeed())
+				b, err := str.ReceiveDatagram(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal([]byte("foobar")))
+			})
+
+			It("sends and receives HTTP datagrams in a handler, without taking over the stream", func() {
+				mux := http.NewServeMux()
+				mux.HandleFunc("/datagrams", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					w.WriteHeader(http.StatusOK)
+					w.(http.Flusher).Flush()
+					// echo a single datagram
+					d := w.(http3.Datagrammer)
+					b, err := d.ReceiveDatagram(r.Context())
+					Expect(err).ToNot(HaveOccurred())
+					Expect(d.SendDatagram(b)).To(Succeed())
+					io.Copy(io.Discard, r.Body)
+				})
+				server := &http3.Server{
+					Server: &http.Server{
+						Handler:   mux,
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: versions}),
+					EnableDatagrams: true,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					server.Serve(conn)
+				}()
+				defer func() {
+					Expect(server.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					EnableDatagrams: true,
+				}
+				defer rt.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				req, err := http.NewRequestWithContext(ctx, http.MethodConnect, fmt.Sprintf("https://localhost:%d/datagrams", conn.LocalAddr().(*net.UDPAddr).Port), nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Proto = "datagram-echo"
+				rsp, str, err := rt.OpenDatagramStream(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				defer str.Close()
+				// The handler only starts receiving datagrams after sending the response header.
+				// Datagrams are unreliable, so resend them until the echo is received.
+				echo := make(chan []byte, 1)
+				go func() {
+					b, err := str.ReceiveDatagram(ctx)
+					if err == nil {
+						echo <- b
+					}
+				}()
+				Eventually(func() <-chan []byte {
+					str.SendDatagram([]byte("foobar"))
+					return echo
+				}).Should(Receive(Equal([]byte("foobar"))))
+			})
+
+			It("sends and receives HTTP datagrams associated with a request", func() {
+				mux := http.NewServeMux()
+				mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					w.WriteHeader(http.StatusOK)
+					w.(http.Flusher).Flush()
+					// echo datagrams until the client closes the request body
+					d := w.(http3.Datagrammer)
+					ctx, cancel := context.WithCancel(r.Context())
+					defer cancel()
+					go func() {
+						for {
+							b, err := d.ReceiveDatagram(ctx)
+							if err != nil {
+								return
+							}
+							d.SendDatagram(b)
+						}
+					}()
+					io.Copy(io.Discard, r.Body)
+					w.Write([]byte("done"))
+				})
+				server := &http3.Server{
+					Server: &http.Server{
+						Handler:   mux,
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: versions}),
+					EnableDatagrams: true,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					server.Serve(conn)
+				}()
+				defer func() {
+					Expect(server.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					EnableDatagrams: true,
+				}
+				defer rt.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				pr, pw := io.Pipe()
+				req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://localhost:%d/echo", conn.LocalAddr().(*net.UDPAddr).Port), pr)
+				Expect(err).ToNot(HaveOccurred())
+				rsp, err := rt.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				d, err := http3.ResponseDatagrams(rsp)
+				Expect(err).ToNot(HaveOccurred())
+				echo := make(chan []byte, 1)
+				go func() {
+					b, err := d.ReceiveDatagram(ctx)
+					if err == nil {
+						echo <- b
+					}
+				}()
+				// Datagrams are unreliable, so resend them until the echo is received.
+				Eventually(func() <-chan []byte {
+					d.SendDatagram([]byte("foobar"))
+					return echo
+				}).Should(Receive(Equal([]byte("foobar"))))
+				Expect(pw.Close()).To(Succeed())
+				body, err := io.ReadAll(rsp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("done"))
+				Expect(d.SendDatagram([]byte("foobar"))).To(MatchError(http3.ErrDatagramStreamClosed))
+			})
+
+			It("limits the number of concurrent requests", func() {
+				var running, maxRunning int32
+				unblock := make(chan struct{})
+				mux := http.NewServeMux()
+				mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+					n := atomic.AddInt32(&running, 1)
+					defer atomic.AddInt32(&running, -1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+							break
+						}
+					}
+					<-unblock
+				})
+				server := &http3.Server{
+					Server: &http.Server{
+						Handler:   mux,
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:            getQuicConfig(&quic.Config{Versions: versions}),
+					MaxConcurrentRequests: 2,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					server.Serve(conn)
+				}()
+				defer func() {
+					Expect(server.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+				}
+				defer rt.Close()
+				const num = 5
+				errChan := make(chan error, num)
+				for i := 0; i < num; i++ {
+					go func() {
+						req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/block", conn.LocalAddr().(*net.UDPAddr).Port), nil)
+						if err != nil {
+							errChan <- err
+							return
+						}
+						rsp, err := rt.RoundTrip(req)
+						if err == nil {
+							rsp.Body.Close()
+							if rsp.StatusCode != http.StatusOK {
+								err = fmt.Errorf("unexpected status code: %d", rsp.StatusCode)
+							}
+						}
+						errChan <- err
+					}()
+				}
+				Eventually(func() int32 { return atomic.LoadInt32(&running) }).Should(BeEquivalentTo(2))
+				Consistently(func() int32 { return atomic.LoadInt32(&running) }, scaleDuration(50*time.Millisecond)).Should(BeEquivalentTo(2))
+				close(unblock)
+				for i := 0; i < num; i++ {
+					Eventually(errChan, 5*time.Second).Should(Receive(BeNil()))
+				}
+				Expect(atomic.LoadInt32(&maxRunning)).To(BeEquivalentTo(2))
+			})
+
+			It("queues requests when the client's connection limit is reached", func() {
+				var running, maxRunning int32
+				mux.HandleFunc("/queued", func(w http.ResponseWriter, r *http.Request) {
+					n := atomic.AddInt32(&running, 1)
+					defer atomic.AddInt32(&running, -1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+							break
+						}
+					}
+					time.Sleep(scaleDuration(20 * time.Millisecond))
+				})
+
+				var dials int32
+				rt := &http3.RoundTripper{
+					TLSClientConfig:              &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:                   getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					MaxConcurrentRequestsPerConn: 1,
+					MaxConnsPerHost:              1,
+					Dial: func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+						atomic.AddInt32(&dials, 1)
+						return quic.DialAddrEarlyContext(ctx, addr, tlsConf, conf)
+					},
+				}
+				defer rt.Close()
+				const num = 4
+				errChan := make(chan error, num)
+				for i := 0; i < num; i++ {
+					go func() {
+						req, err := http.NewRequest(http.MethodGet, "https://localhost:"+port+"/queued", nil)
+						if err != nil {
+							errChan <- err
+							return
+						}
+						rsp, err := rt.RoundTrip(req)
+						if err == nil {
+							io.Copy(io.Discard, rsp.Body)
+							rsp.Body.Close()
+						}
+						errChan <- err
+					}()
+				}
+				for i := 0; i < num; i++ {
+					Eventually(errChan, 5*time.Second).Should(Receive(BeNil()))
+				}
+				Expect(atomic.LoadInt32(&maxRunning)).To(BeEquivalentTo(1))
+				Expect(atomic.LoadInt32(&dials)).To(BeEquivalentTo(1))
+			})
+
+			It("doesn't report protocol violations in strict mode", func() {
+				var violations int32
+				onViolation := func(_ quic.Connection, v *http3.ProtocolViolation) {
+					fmt.Fprintf(GinkgoWriter, "protocol violation: %s\n", v)
+					atomic.AddInt32(&violations, 1)
+				}
+				server := &http3.Server{
+					Server: &http.Server{
+						Handler:   mux,
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:               getQuicConfig(&quic.Config{Versions: versions}),
+					Strict:                   true,
+					ProtocolViolationHandler: onViolation,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					server.Serve(conn)
+				}()
+				defer func() {
+					Expect(server.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig:          &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:               getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					Strict:                   true,
+					ProtocolViolationHandler: onViolation,
+				}
+				defer rt.Close()
+				cl := &http.Client{Transport: rt}
+				for i := 0; i < 3; i++ {
+					rsp, err := cl.Post(fmt.Sprintf("https://localhost:%d/echo", conn.LocalAddr().(*net.UDPAddr).Port), "text/plain", bytes.NewReader([]byte("foobar")))
+					Expect(err).ToNot(HaveOccurred())
+					body, err := io.ReadAll(rsp.Body)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(body)).To(Equal("foobar"))
+				}
+				Consistently(func() int32 { return atomic.LoadInt32(&violations) }, scaleDuration(50*time.Millisecond)).Should(BeZero())
+			})
+
+			It("resolves names using DNS over HTTP/3", func() {
+				mux.Handle("/dns-query", &doh.Handler{
+					Exchange: func(_ context.Context, query []byte) ([]byte, error) {
+						var q dnsmessage.Message
+						if err := q.Unpack(query); err != nil {
+							return nil, err
+						}
+						rsp := dnsmessage.Message{
+							Header:    dnsmessage.Header{ID: q.ID, Response: true, RecursionDesired: q.RecursionDesired},
+							Questions: q.Questions,
+						}
+						if q.Questions[0].Type == dnsmessage.TypeA {
+							rsp.Answers = []dnsmessage.Resource{{
+								Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+								Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 42}},
+							}}
+						}
+						return rsp.Pack()
+					},
+				})
+				rt := &http3.RoundTripper{
+					TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+				}
+				defer rt.Close()
+				client := &doh.Client{URL: "https://localhost:" + port + "/dns-query", RoundTripper: rt}
+				addrs, err := client.Resolver().LookupIPAddr(context.Background(), "quic-go.example.org.")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(addrs).To(HaveLen(1))
+				Expect(addrs[0].IP.Equal(net.IPv4(192, 0, 2, 42))).To(BeTrue())
+			})
+
+			It("proxies UDP", func() {
+				// a UDP echo server
+				target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer target.Close()
+				go func() {
+					b := make([]byte, 1500)
+					for {
+						n, addr, err := target.ReadFrom(b)
+						if err != nil {
+							return
+						}
+						target.WriteTo(b[:n], addr)
+					}
+				}()
+
+				proxy := &http3.Server{
+					Server: &http.Server{
+						Handler:   &http3.ConnectUDPProxy{},
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: versions}),
+					EnableDatagrams: true,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					proxy.Serve(conn)
+				}()
+				defer func() {
+					Expect(proxy.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					EnableDatagrams: true,
+				}
+				defer rt.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				template := fmt.Sprintf("https://localhost:%d%s", conn.LocalAddr().(*net.UDPAddr).Port, http3.DefaultConnectUDPTemplate)
+				rsp, pconn, err := rt.DialConnectUDP(ctx, template, target.LocalAddr().String())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				defer pconn.Close()
+
+				Expect(pconn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+				_, err = pconn.WriteTo([]byte("foobar"), nil)
+				Expect(err).ToNot(HaveOccurred())
+				b := make([]byte, 100)
+				n, addr, err := pconn.ReadFrom(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(b[:n])).To(Equal("foobar"))
+				Expect(addr.String()).To(Equal(target.LocalAddr().String()))
+			})
+
+			It("sends requests through a proxy", func() {
+				var proxied bool
+				proxy := &http3.Server{
+					Server: &http.Server{
+						Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							defer GinkgoRecover()
+							Expect(r.Header.Get("Proxy-Authorization")).To(Equal("Basic dXNlcjpwYXNz"))
+							proxied = true
+							(&http3.ConnectUDPProxy{}).ServeHTTP(w, r)
+						}),
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: versions}),
+					EnableDatagrams: true,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					proxy.Serve(conn)
+				}()
+				defer func() {
+					Expect(proxy.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := client.Transport.(*http3.RoundTripper)
+				rt.Proxy = http.ProxyURL(&url.URL{
+					Scheme: "https",
+					User:   url.UserPassword("user", "pass"),
+					Host:   fmt.Sprintf("localhost:%d", conn.LocalAddr().(*net.UDPAddr).Port),
+				})
+				defer rt.Close()
+				resp, err := client.Get("https://localhost:" + port + "/hello")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("Hello, World!\n"))
+				Expect(proxied).To(BeTrue())
+			})
+
+			It("proxies IP", func() {
+				mux := http.NewServeMux()
+				mux.HandleFunc("/.well-known/masque/ip/", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					ipReq, err := http3.ParseConnectIPRequest(r, "")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ipReq.IPProtocol).To(BeEquivalentTo(17))
+					tunnel, err := http3.UpgradeConnectIP(w, r)
+					Expect(err).ToNot(HaveOccurred())
+					_, prefix, err := net.ParseCIDR("192.0.2.1/32")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tunnel.AssignAddresses([]http3.IPPrefix{{Prefix: *prefix}})).To(Succeed())
+					Expect(tunnel.AdvertiseRoutes([]http3.IPRoute{
+						{StartIP: net.IPv4(0, 0, 0, 0), EndIP: net.IPv4(255, 255, 255, 255), IPProtocol: 17},
+					})).To(Succeed())
+					// echo all IP packets
+					b := make([]byte, 1500)
+					for {
+						n, err := tunnel.ReadPacket(b)
+						if err != nil {
+							return
+						}
+						tunnel.WritePacket(b[:n])
+					}
+				})
+				proxy := &http3.Server{
+					Server: &http.Server{
+						Handler:   mux,
+						TLSConfig: testdata.GetTLSConfig(),
+					},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: versions}),
+					EnableDatagrams: true,
+				}
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					proxy.Serve(conn)
+				}()
+				defer func() {
+					Expect(proxy.Close()).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				}()
+
+				rt := &http3.RoundTripper{
+					TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+					QuicConfig:      getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					EnableDatagrams: true,
+				}
+				defer rt.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				template := fmt.Sprintf("https://localhost:%d%s", conn.LocalAddr().(*net.UDPAddr).Port, http3.DefaultConnectIPTemplate)
+				rsp, tunnel, err := rt.DialConnectIP(ctx, template, http3.ConnectIPRequest{IPProtocol: 17})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				defer tunnel.Close()
+
+				prefixes, err := tunnel.AssignedAddresses(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(prefixes).To(HaveLen(1))
+				Expect(prefixes[0].Prefix.String()).To(Equal("192.0.2.1/32"))
+				routes, err := tunnel.Routes(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(routes).To(HaveLen(1))
+				Expect(routes[0].IPProtocol).To(BeEquivalentTo(17))
+
+				Expect(tunnel.WritePacket([]byte("foobar"))).To(Succeed())
+				b := make([]byte, 100)
+				n, err := tunnel.ReadPacket(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(b[:n])).To(Equal("foobar"))
+			})
 		})
 	}
 })
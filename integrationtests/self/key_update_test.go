@@ -100,4 +100,51 @@ var _ = Describe("Key Update tests", func() {
 		Expect(keyPhasesReceived).To(BeNumerically(">", 10))
 		Expect(keyPhasesReceived).To(BeNumerically("~", keyPhasesSent, 2))
 	})
+
+	It("updates keys when requested by the application", func() {
+		ln, err := quic.ListenAddr("localhost:0", getTLSConfig(), getQuicConfig(nil))
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+
+		serverConnChan := make(chan quic.Connection, 1)
+		go func() {
+			defer GinkgoRecover()
+			conn, err := ln.Accept(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			serverConnChan <- conn
+			str, err := conn.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.Copy(str, str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+
+		conn, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			getQuicConfig(nil),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.CloseWithError(0, "")
+		var serverConn quic.Connection
+		Eventually(serverConnChan).Should(Receive(&serverConn))
+		Expect(conn.ConnectionState().KeyPhase).To(BeZero())
+		// wait for the handshake to be confirmed
+		Eventually(func() error { return conn.UpdateKeys() }).Should(Succeed())
+		Eventually(func() uint64 { return conn.ConnectionState().KeyPhase }).Should(BeEquivalentTo(1))
+		Eventually(func() uint64 { return serverConn.ConnectionState().KeyPhase }).Should(BeEquivalentTo(1))
+
+		str, err := conn.OpenStream()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write(PRData)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str.Close()).To(Succeed())
+		data, err := io.ReadAll(str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(PRData))
+		// the client received an acknowledgement for the first key phase, so it can update again
+		Expect(conn.UpdateKeys()).To(Succeed())
+		Eventually(func() uint64 { return conn.ConnectionState().KeyPhase }).Should(BeEquivalentTo(2))
+		Eventually(func() uint64 { return serverConn.ConnectionState().KeyPhase }).Should(BeEquivalentTo(2))
+	})
 })
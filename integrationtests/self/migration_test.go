@@ -0,0 +1,252 @@
+package self_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// A droppingConn drops all packets that are sent on it.
+type droppingConn struct {
+	net.PacketConn
+}
+
+func (c *droppingConn) WriteTo(p []byte, _ net.Addr) (int, error) { return len(p), nil }
+
+// A failingConn fails to send packets once failing is set.
+type failingConn struct {
+	net.PacketConn
+	failing int32 // accessed atomically
+}
+
+func (c *failingConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if atomic.LoadInt32(&c.failing) == 1 {
+		return 0, errors.New("network is unreachable")
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+var _ = Describe("Connection Migration", func() {
+	for _, v := range protocol.SupportedVersions {
+		version := v
+
+		Context(fmt.Sprintf("with QUIC version %s", version), func() {
+			var (
+				server      quic.Listener
+				serverConns chan quic.Connection
+			)
+
+			BeforeEach(func() {
+				var err error
+				server, err = quic.ListenAddr("localhost:0", getTLSConfig(), getQuicConfig(&quic.Config{
+					Versions:              []protocol.VersionNumber{version},
+					EnableActiveMigration: true,
+				}))
+				Expect(err).ToNot(HaveOccurred())
+				serverConns = make(chan quic.Connection, 1)
+				go func() {
+					defer GinkgoRecover()
+					conn, err := server.Accept(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					serverConns <- conn
+					for {
+						str, err := conn.AcceptStream(context.Background())
+						if err != nil {
+							return
+						}
+						go func() {
+							defer GinkgoRecover()
+							_, err := io.Copy(str, str)
+							Expect(err).ToNot(HaveOccurred())
+							str.Close()
+						}()
+					}
+				}()
+			})
+
+			AfterEach(func() {
+				Expect(server.Close()).To(Succeed())
+			})
+
+			echo := func(conn quic.Connection, data []byte) {
+				str, err := conn.OpenStreamSync(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				go func() {
+					defer GinkgoRecover()
+					_, err := str.Write(data)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.Close()).To(Succeed())
+				}()
+				rcvd, err := io.ReadAll(str)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rcvd).To(Equal(data))
+			}
+
+			It("migrates the connection to a new packet conn", func() {
+				addr, err := net.ResolveUDPAddr("udp", "localhost:0")
+				Expect(err).ToNot(HaveOccurred())
+				udpConn1, err := net.ListenUDP("udp", addr)
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn1.Close()
+				udpConn2, err := net.ListenUDP("udp", addr)
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn2.Close()
+
+				conn, err := quic.Dial(
+					udpConn1,
+					server.Addr(),
+					"localhost",
+					getTLSClientConfig(),
+					getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.CloseWithError(0, "")
+				var serverConn quic.Connection
+				Eventually(serverConns).Should(Receive(&serverConn))
+				// make sure the handshake is confirmed, and the server issued new connection IDs
+				echo(conn, []byte("foobar"))
+				Eventually(func() error {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					return conn.Migrate(ctx, udpConn2)
+				}).Should(Succeed())
+				Expect(conn.LocalAddr().String()).To(Equal(udpConn2.LocalAddr().String()))
+
+				// the old path is not used any more
+				Expect(udpConn1.Close()).To(Succeed())
+				echo(conn, PRData)
+				Eventually(func() string { return serverConn.RemoteAddr().String() }).Should(Equal(udpConn2.LocalAddr().String()))
+			})
+
+			It("keeps using the old path if the new path doesn't work", func() {
+				conn, err := quic.DialAddr(
+					fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+					getTLSClientConfig(),
+					getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.CloseWithError(0, "")
+				echo(conn, []byte("foobar"))
+
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				defer cancel()
+				Expect(conn.Migrate(ctx, &droppingConn{PacketConn: udpConn})).To(MatchError(context.DeadlineExceeded))
+				Expect(conn.LocalAddr().String()).ToNot(Equal(udpConn.LocalAddr().String()))
+				// the connection still works on the old path
+				echo(conn, PRData)
+			})
+
+			It("probes a path without migrating the connection", func() {
+				conn, err := quic.DialAddr(
+					fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+					getTLSClientConfig(),
+					getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.CloseWithError(0, "")
+				var serverConn quic.Connection
+				Eventually(serverConns).Should(Receive(&serverConn))
+				echo(conn, []byte("foobar"))
+				localAddr := conn.LocalAddr().String()
+				localPort := conn.LocalAddr().(*net.UDPAddr).Port
+
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				var rtt time.Duration
+				Eventually(func() error {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					var err error
+					rtt, err = conn.ProbePath(ctx, udpConn)
+					return err
+				}).Should(Succeed())
+				Expect(rtt).To(BeNumerically(">", 0))
+				Expect(conn.LocalAddr().String()).To(Equal(localAddr))
+				echo(conn, PRData)
+				Expect(serverConn.RemoteAddr().(*net.UDPAddr).Port).To(Equal(localPort))
+
+				// the probed path can be used for a migration
+				Eventually(func() error {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					return conn.Migrate(ctx, udpConn)
+				}).Should(Succeed())
+				Expect(conn.LocalAddr().String()).To(Equal(udpConn.LocalAddr().String()))
+				echo(conn, PRData)
+			})
+
+			It("reports a failure when probing a path that doesn't work", func() {
+				conn, err := quic.DialAddr(
+					fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+					getTLSClientConfig(),
+					getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.CloseWithError(0, "")
+				echo(conn, []byte("foobar"))
+
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				defer cancel()
+				_, err = conn.ProbePath(ctx, &droppingConn{PacketConn: udpConn})
+				Expect(err).To(MatchError(context.DeadlineExceeded))
+				echo(conn, PRData)
+			})
+
+			It("automatically migrates the connection when sending fails", func() {
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				pconn := &failingConn{PacketConn: udpConn}
+
+				events := make(chan quic.MigrationEvent, 1)
+				conn, err := quic.Dial(
+					pconn,
+					server.Addr(),
+					"localhost",
+					getTLSClientConfig(),
+					getQuicConfig(&quic.Config{
+						Versions:                 []protocol.VersionNumber{version},
+						EnableAutomaticMigration: true,
+						ConnectionMigrated:       func(_ quic.Connection, ev quic.MigrationEvent) { events <- ev },
+					}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.CloseWithError(0, "")
+				// make sure the handshake is confirmed, and the server issued new connection IDs
+				echo(conn, []byte("foobar"))
+
+				atomic.StoreInt32(&pconn.failing, 1)
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					echo(conn, PRData)
+				}()
+				var ev quic.MigrationEvent
+				Eventually(events, 5*time.Second).Should(Receive(&ev))
+				Expect(ev.Err).ToNot(HaveOccurred())
+				Expect(ev.Reason).To(Equal(quic.MigrationReasonSendError))
+				Expect(ev.OldLocalAddr.String()).To(Equal(udpConn.LocalAddr().String()))
+				Expect(conn.LocalAddr()).To(Equal(ev.NewLocalAddr))
+				Eventually(done, 5*time.Second).Should(BeClosed())
+			})
+		})
+	}
+})
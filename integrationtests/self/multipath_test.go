@@ -0,0 +1,215 @@
+package self_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// A countingConn counts the packets sent on it.
+type countingConn struct {
+	net.PacketConn
+	numSent int64 // accessed atomically
+}
+
+func (c *countingConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	atomic.AddInt64(&c.numSent, 1)
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func (c *countingConn) NumSent() int64 { return atomic.LoadInt64(&c.numSent) }
+
+var _ = Describe("Multipath", func() {
+	for _, v := range protocol.SupportedVersions {
+		version := v
+
+		for _, p := range []quic.MultipathSchedulingPolicy{quic.MultipathSchedulingMinRTT, quic.MultipathSchedulingRoundRobin} {
+			policy := p
+
+			Context(fmt.Sprintf("with QUIC version %s, using scheduling policy %d", version, policy), func() {
+				var (
+					server      quic.Listener
+					serverConns chan quic.Connection
+				)
+
+				BeforeEach(func() {
+					var err error
+					server, err = quic.ListenAddr(
+						"localhost:0",
+						getTLSConfig(),
+						getQuicConfig(&quic.Config{
+							Versions:            []protocol.VersionNumber{version},
+							EnableMultipath:     true,
+							MultipathScheduling: policy,
+						}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					serverConns = make(chan quic.Connection, 1)
+					go func() {
+						defer GinkgoRecover()
+						conn, err := server.Accept(context.Background())
+						Expect(err).ToNot(HaveOccurred())
+						serverConns <- conn
+						for {
+							str, err := conn.AcceptStream(context.Background())
+							if err != nil {
+								return
+							}
+							go func() {
+								defer GinkgoRecover()
+								_, err := io.Copy(str, str)
+								Expect(err).ToNot(HaveOccurred())
+								str.Close()
+							}()
+						}
+					}()
+				})
+
+				AfterEach(func() {
+					Expect(server.Close()).To(Succeed())
+				})
+
+				echo := func(conn quic.Connection, data []byte) {
+					str, err := conn.OpenStreamSync(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					go func() {
+						defer GinkgoRecover()
+						_, err := str.Write(data)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(str.Close()).To(Succeed())
+					}()
+					rcvd, err := io.ReadAll(str)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(rcvd).To(Equal(data))
+				}
+
+				addPath := func(conn quic.Connection, pconn net.PacketConn) quic.PathID {
+					var id quic.PathID
+					Eventually(func() error {
+						ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+						defer cancel()
+						var err error
+						id, err = conn.AddPath(ctx, pconn)
+						return err
+					}).Should(Succeed())
+					return id
+				}
+
+				It("sends data on multiple paths", func() {
+					udpConn1, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+					Expect(err).ToNot(HaveOccurred())
+					defer udpConn1.Close()
+					udpConn2, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+					Expect(err).ToNot(HaveOccurred())
+					defer udpConn2.Close()
+					conn1 := &countingConn{PacketConn: udpConn1}
+					conn2 := &countingConn{PacketConn: udpConn2}
+
+					conn, err := quic.Dial(
+						conn1,
+						server.Addr(),
+						"localhost",
+						getTLSClientConfig(),
+						getQuicConfig(&quic.Config{
+							Versions:            []protocol.VersionNumber{version},
+							EnableMultipath:     true,
+							MultipathScheduling: policy,
+						}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer conn.CloseWithError(0, "")
+					// make sure the handshake is confirmed, and the server issued new connection IDs
+					echo(conn, []byte("foobar"))
+
+					Expect(addPath(conn, conn2)).To(Equal(quic.PathID(1)))
+					// The connection keeps using the initial path.
+					Expect(conn.LocalAddr().String()).To(Equal(udpConn1.LocalAddr().String()))
+
+					sentBefore1 := conn1.NumSent()
+					sentBefore2 := conn2.NumSent()
+					echo(conn, PRData)
+					Expect(conn1.NumSent()).To(BeNumerically(">", sentBefore1))
+					Expect(conn2.NumSent()).To(BeNumerically(">", sentBefore2))
+				})
+
+				It("removes paths", func() {
+					udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+					Expect(err).ToNot(HaveOccurred())
+					defer udpConn.Close()
+					pconn := &countingConn{PacketConn: udpConn}
+
+					conn, err := quic.DialAddr(
+						fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+						getTLSClientConfig(),
+						getQuicConfig(&quic.Config{
+							Versions:            []protocol.VersionNumber{version},
+							EnableMultipath:     true,
+							MultipathScheduling: policy,
+						}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer conn.CloseWithError(0, "")
+					echo(conn, []byte("foobar"))
+
+					id := addPath(conn, pconn)
+					echo(conn, PRData)
+					Expect(conn.RemovePath(quic.PathID(0))).To(MatchError("cannot remove the initial path"))
+					Expect(conn.RemovePath(id)).To(Succeed())
+					Expect(conn.RemovePath(id)).To(MatchError(fmt.Sprintf("unknown path: %d", id)))
+
+					// the removed path is not used any more
+					sent := pconn.NumSent()
+					echo(conn, PRData)
+					Expect(pconn.NumSent()).To(Equal(sent))
+
+					// the connection ID used on the removed path was retired, so we can add another path
+					addPath(conn, pconn)
+					echo(conn, PRData)
+				})
+			})
+		}
+
+		It("doesn't use multipath if the server doesn't support it", func() {
+			server, err := quic.ListenAddr("localhost:0", getTLSConfig(), getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}))
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Close()
+			go func() {
+				defer GinkgoRecover()
+				conn, err := server.Accept(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				<-conn.Context().Done()
+			}()
+
+			conn, err := quic.DialAddr(
+				fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+				getTLSClientConfig(),
+				getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}, EnableMultipath: true}),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.CloseWithError(0, "")
+			// wait for the handshake to be confirmed
+			str, err := conn.OpenUniStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+
+			udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+			Expect(err).ToNot(HaveOccurred())
+			defer udpConn.Close()
+			Eventually(func() error {
+				_, err := conn.AddPath(context.Background(), udpConn)
+				return err
+			}).Should(MatchError("peer doesn't support multipath"))
+		})
+	}
+})
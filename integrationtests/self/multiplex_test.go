@@ -2,6 +2,7 @@ package self_test
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -40,13 +41,13 @@ var _ = Describe("Multiplexing", func() {
 				}()
 			}
 
-			dial := func(pconn net.PacketConn, addr net.Addr) {
+			dialWithConfig := func(pconn net.PacketConn, addr net.Addr, conf *quic.Config) {
 				conn, err := quic.Dial(
 					pconn,
 					addr,
 					fmt.Sprintf("localhost:%d", addr.(*net.UDPAddr).Port),
 					getTLSClientConfig(),
-					getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					conf,
 				)
 				Expect(err).ToNot(HaveOccurred())
 				defer conn.CloseWithError(0, "")
@@ -57,6 +58,10 @@ var _ = Describe("Multiplexing", func() {
 				Expect(data).To(Equal(PRData))
 			}
 
+			dial := func(pconn net.PacketConn, addr net.Addr) {
+				dialWithConfig(pconn, addr, getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}))
+			}
+
 			Context("multiplexing clients on the same conn", func() {
 				getListener := func() quic.Listener {
 					ln, err := quic.ListenAddr(
@@ -132,6 +137,44 @@ var _ = Describe("Multiplexing", func() {
 					Eventually(done1, timeout).Should(BeClosed())
 					Eventually(done2, timeout).Should(BeClosed())
 				})
+
+				It("multiplexes connections to different servers, using zero-length connection IDs", func() {
+					server1 := getListener()
+					runServer(server1)
+					defer server1.Close()
+					server2 := getListener()
+					runServer(server2)
+					defer server2.Close()
+
+					addr, err := net.ResolveUDPAddr("udp", "localhost:0")
+					Expect(err).ToNot(HaveOccurred())
+					conn, err := net.ListenUDP("udp", addr)
+					Expect(err).ToNot(HaveOccurred())
+					defer conn.Close()
+
+					conf := getQuicConfig(&quic.Config{
+						Versions:                []protocol.VersionNumber{version},
+						ZeroLengthConnectionIDs: true,
+					})
+					done1 := make(chan struct{})
+					done2 := make(chan struct{})
+					go func() {
+						defer GinkgoRecover()
+						dialWithConfig(conn, server1.Addr(), conf)
+						close(done1)
+					}()
+					go func() {
+						defer GinkgoRecover()
+						dialWithConfig(conn, server2.Addr(), conf)
+						close(done2)
+					}()
+					timeout := 30 * time.Second
+					if debugLog() {
+						timeout = time.Minute
+					}
+					Eventually(done1, timeout).Should(BeClosed())
+					Eventually(done2, timeout).Should(BeClosed())
+				})
 			})
 
 			Context("multiplexing server and client on the same conn", func() {
@@ -216,6 +259,86 @@ var _ = Describe("Multiplexing", func() {
 					Eventually(done2, timeout).Should(BeClosed())
 				})
 			})
+
+			Context("using a Transport", func() {
+				newTransport := func() *quic.Transport {
+					conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+					Expect(err).ToNot(HaveOccurred())
+					return &quic.Transport{Conn: conn}
+				}
+
+				getTLSClientConfigForTransport := func() *tls.Config {
+					conf := getTLSClientConfig()
+					conf.ServerName = "localhost"
+					return conf
+				}
+
+				dialTransport := func(tr *quic.Transport, addr net.Addr) {
+					conn, err := tr.Dial(
+						context.Background(),
+						addr,
+						getTLSClientConfigForTransport(),
+						getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer conn.CloseWithError(0, "")
+					str, err := conn.AcceptStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					data, err := io.ReadAll(str)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(data).To(Equal(PRData))
+				}
+
+				It("dials and accepts connections on the same port", func() {
+					tr1 := newTransport()
+					defer tr1.Close()
+					tr2 := newTransport()
+					defer tr2.Close()
+
+					for _, tr := range []*quic.Transport{tr1, tr2} {
+						ln, err := tr.Listen(getTLSConfig(), getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}))
+						Expect(err).ToNot(HaveOccurred())
+						runServer(ln)
+					}
+
+					done1 := make(chan struct{})
+					done2 := make(chan struct{})
+					go func() {
+						defer GinkgoRecover()
+						dialTransport(tr1, tr2.Conn.LocalAddr())
+						close(done1)
+					}()
+					go func() {
+						defer GinkgoRecover()
+						dialTransport(tr2, tr1.Conn.LocalAddr())
+						close(done2)
+					}()
+					timeout := 30 * time.Second
+					if debugLog() {
+						timeout = time.Minute
+					}
+					Eventually(done1, timeout).Should(BeClosed())
+					Eventually(done2, timeout).Should(BeClosed())
+				})
+
+				It("closes all connections when the Transport is closed", func() {
+					tr := newTransport()
+					ln, err := tr.Listen(getTLSConfig(), getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}))
+					Expect(err).ToNot(HaveOccurred())
+					runServer(ln)
+					conn, err := tr.Dial(
+						context.Background(),
+						tr.Conn.LocalAddr(),
+						getTLSClientConfigForTransport(),
+						getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tr.Close()).To(Succeed())
+					Eventually(conn.Context().Done()).Should(BeClosed())
+					_, err = ln.Accept(context.Background())
+					Expect(err).To(HaveOccurred())
+				})
+			})
 		})
 	}
 })
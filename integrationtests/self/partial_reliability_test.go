@@ -0,0 +1,112 @@
+package self_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	quicproxy "github.com/lucas-clemente/quic-go/integrationtests/tools/proxy"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Partial Reliability", func() {
+	for _, v := range protocol.SupportedVersions {
+		version := v
+
+		Context(fmt.Sprintf("with QUIC version %s", version), func() {
+			It("skips expired data", func() {
+				ln, err := quic.ListenAddr(
+					"localhost:0",
+					getTLSConfig(),
+					getQuicConfig(&quic.Config{
+						EnablePartialReliability: true,
+						Versions:                 []protocol.VersionNumber{version},
+					}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer ln.Close()
+
+				var numDropped int32
+				proxy, err := quicproxy.NewQuicProxy("localhost:0", &quicproxy.Opts{
+					RemoteAddr: fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+					DelayPacket: func(quicproxy.Direction, []byte) time.Duration {
+						return 5 * time.Millisecond
+					},
+					// drop 10% of the Short Header packets sent by the client
+					DropPacket: func(dir quicproxy.Direction, packet []byte) bool {
+						if dir != quicproxy.DirectionIncoming || packet[0]&0x80 > 0 {
+							return false
+						}
+						drop := mrand.Int()%10 == 0
+						if drop {
+							atomic.AddInt32(&numDropped, 1)
+						}
+						return drop
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer proxy.Close()
+
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					conn, err := ln.Accept(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					str, err := conn.AcceptUniStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					var offset, numExpired int
+					b := make([]byte, 1024)
+					for {
+						n, err := str.Read(b)
+						Expect(b[:n]).To(Equal(PRData[offset : offset+n]))
+						offset += n
+						var expiredErr *quic.StreamDataExpiredError
+						if errors.As(err, &expiredErr) {
+							Expect(int(expiredErr.Offset)).To(BeNumerically(">", offset))
+							offset = int(expiredErr.Offset)
+							numExpired++
+							continue
+						}
+						if err == io.EOF {
+							break
+						}
+						Expect(err).ToNot(HaveOccurred())
+					}
+					Expect(offset).To(Equal(len(PRData)))
+					fmt.Fprintf(GinkgoWriter, "Skipped expired data %d times.\n", numExpired)
+					Expect(numExpired).To(BeNumerically(">", 0))
+					conn.CloseWithError(0, "")
+				}()
+
+				conn, err := quic.DialAddr(
+					fmt.Sprintf("localhost:%d", proxy.LocalPort()),
+					getTLSClientConfig(),
+					getQuicConfig(&quic.Config{
+						EnablePartialReliability: true,
+						Versions:                 []protocol.VersionNumber{version},
+					}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				str, err := conn.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				// Don't retransmit any data, except for the last byte.
+				str.SetDataExpiry(0, 1)
+				_, err = str.Write(PRData)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+				Eventually(done, 10*time.Second).Should(BeClosed())
+				fmt.Fprintf(GinkgoWriter, "Dropped %d packets.\n", atomic.LoadInt32(&numDropped))
+			})
+		})
+	}
+})
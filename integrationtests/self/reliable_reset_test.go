@@ -0,0 +1,81 @@
+package self_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	quicproxy "github.com/lucas-clemente/quic-go/integrationtests/tools/proxy"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reliable Stream Resets", func() {
+	for _, v := range protocol.SupportedVersions {
+		version := v
+
+		Context(fmt.Sprintf("with QUIC version %s", version), func() {
+			It("delivers the data up to the reliable size", func() {
+				ln, err := quic.ListenAddr(
+					"localhost:0",
+					getTLSConfig(),
+					getQuicConfig(&quic.Config{
+						EnableReliableStreamReset: true,
+						Versions:                  []protocol.VersionNumber{version},
+					}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer ln.Close()
+
+				proxy, err := quicproxy.NewQuicProxy("localhost:0", &quicproxy.Opts{
+					RemoteAddr: fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+					DelayPacket: func(quicproxy.Direction, []byte) time.Duration {
+						return 5 * time.Millisecond
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer proxy.Close()
+
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					conn, err := ln.Accept(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					str, err := conn.AcceptUniStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					data, err := io.ReadAll(str)
+					var streamErr *quic.StreamError
+					Expect(errors.As(err, &streamErr)).To(BeTrue())
+					Expect(streamErr.ErrorCode).To(BeEquivalentTo(42))
+					Expect(data).To(Equal(PRData))
+					conn.CloseWithError(0, "")
+				}()
+
+				conn, err := quic.DialAddr(
+					fmt.Sprintf("localhost:%d", proxy.LocalPort()),
+					getTLSClientConfig(),
+					getQuicConfig(&quic.Config{
+						EnableReliableStreamReset: true,
+						Versions:                  []protocol.VersionNumber{version},
+					}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				str, err := conn.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				_, err = str.Write(PRData)
+				Expect(err).ToNot(HaveOccurred())
+				str.SetReliableBoundary()
+				// The stream is reset right away, before most of the data has been sent.
+				str.CancelWrite(42)
+				Eventually(done, 10*time.Second).Should(BeClosed())
+			})
+		})
+	}
+})
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/lucas-clemente/quic-go"
@@ -16,6 +17,34 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+// A reroutingConn sends all packets to the current backend, and pretends that all packets were received
+// from the address that was dialed. This simulates a load balancer that routes packets to a different server.
+type reroutingConn struct {
+	net.PacketConn
+	addr net.Addr // the address that was dialed
+
+	mutex   sync.Mutex
+	backend net.Addr
+}
+
+func (c *reroutingConn) SetBackend(addr net.Addr) {
+	c.mutex.Lock()
+	c.backend = addr
+	c.mutex.Unlock()
+}
+
+func (c *reroutingConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	c.mutex.Lock()
+	backend := c.backend
+	c.mutex.Unlock()
+	return c.PacketConn.WriteTo(b, backend)
+}
+
+func (c *reroutingConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, _, err := c.PacketConn.ReadFrom(b)
+	return n, c.addr, err
+}
+
 var _ = Describe("Stateless Resets", func() {
 	connIDLens := []int{0, 10}
 
@@ -106,4 +135,57 @@ var _ = Describe("Stateless Resets", func() {
 			Eventually(acceptStopped).Should(BeClosed())
 		})
 	}
+
+	It("sends stateless resets for connections of a different server using the same key", func() {
+		statelessResetKey := make([]byte, 32)
+		rand.Read(statelessResetKey)
+		serverConfig := getQuicConfig(&quic.Config{StatelessResetKey: statelessResetKey})
+
+		ln1, err := quic.ListenAddr("localhost:0", getTLSConfig(), serverConfig)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln1.Close()
+		ln2, err := quic.ListenAddr("localhost:0", getTLSConfig(), serverConfig)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln2.Close()
+
+		go func() {
+			defer GinkgoRecover()
+			conn, err := ln1.Accept(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			str, err := conn.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+		}()
+
+		udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+		Expect(err).ToNot(HaveOccurred())
+		defer udpConn.Close()
+		pconn := &reroutingConn{PacketConn: udpConn, addr: ln1.Addr(), backend: ln1.Addr()}
+		conn, err := quic.Dial(
+			pconn,
+			ln1.Addr(),
+			"localhost",
+			getTLSClientConfig(),
+			getQuicConfig(&quic.Config{MaxIdleTimeout: 2 * time.Second}),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		str, err := conn.AcceptStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		data := make([]byte, 6)
+		_, err = str.Read(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("foobar")))
+
+		// The load balancer now routes the packets to the second server.
+		// It doesn't know the connection, but it can send a valid stateless reset.
+		pconn.SetBackend(ln2.Addr())
+		_, serr := str.Write([]byte("Lorem ipsum dolor sit amet."))
+		if serr == nil {
+			_, serr = str.Read([]byte{0})
+		}
+		Expect(serr).To(HaveOccurred())
+		statelessResetErr := &quic.StatelessResetError{}
+		Expect(errors.As(serr, &statelessResetErr)).To(BeTrue())
+	})
 })
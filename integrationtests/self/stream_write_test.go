@@ -0,0 +1,110 @@
+package self_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	quicproxy "github.com/lucas-clemente/quic-go/integrationtests/tools/proxy"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Vectored and owned stream writes", func() {
+	// splitData splits the data into chunks of random length, some of them smaller than a packet
+	splitData := func(data []byte) [][]byte {
+		var bufs [][]byte
+		for len(data) > 0 {
+			l := 1 + mrand.Intn(3000)
+			if l > len(data) {
+				l = len(data)
+			}
+			bufs = append(bufs, data[:l])
+			data = data[l:]
+		}
+		return bufs
+	}
+
+	for _, v := range protocol.SupportedVersions {
+		version := v
+
+		Context(fmt.Sprintf("with QUIC version %s", version), func() {
+			for _, o := range []bool{false, true} {
+				owned := o
+
+				It(fmt.Sprintf("transfers data, dropping packets (owned: %t)", owned), func() {
+					ln, err := quic.ListenAddr(
+						"localhost:0",
+						getTLSConfig(),
+						getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer ln.Close()
+
+					// drop 5% of the Short Header packets sent by the client
+					proxy, err := quicproxy.NewQuicProxy("localhost:0", &quicproxy.Opts{
+						RemoteAddr: fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+						DelayPacket: func(quicproxy.Direction, []byte) time.Duration {
+							return 5 * time.Millisecond
+						},
+						DropPacket: func(dir quicproxy.Direction, packet []byte) bool {
+							return dir == quicproxy.DirectionIncoming && packet[0]&0x80 == 0 && mrand.Intn(20) == 0
+						},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					defer proxy.Close()
+
+					done := make(chan struct{})
+					go func() {
+						defer GinkgoRecover()
+						defer close(done)
+						conn, err := ln.Accept(context.Background())
+						Expect(err).ToNot(HaveOccurred())
+						str, err := conn.AcceptUniStream(context.Background())
+						Expect(err).ToNot(HaveOccurred())
+						data, err := io.ReadAll(str)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(data).To(Equal(PRData))
+						conn.CloseWithError(0, "")
+					}()
+
+					conn, err := quic.DialAddr(
+						fmt.Sprintf("localhost:%d", proxy.LocalPort()),
+						getTLSClientConfig(),
+						getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					str, err := conn.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					bufs := splitData(PRData)
+					for len(bufs) > 0 {
+						n := 1 + mrand.Intn(10)
+						if n > len(bufs) {
+							n = len(bufs)
+						}
+						if owned {
+							// copy the data, since the stream takes ownership of the buffers
+							owned := make([][]byte, n)
+							for i, b := range bufs[:n] {
+								owned[i] = append([]byte{}, b...)
+							}
+							_, err = str.WriteOwned(owned...)
+						} else {
+							_, err = str.WriteVectored(bufs[:n])
+						}
+						Expect(err).ToNot(HaveOccurred())
+						bufs = bufs[n:]
+					}
+					Expect(str.Close()).To(Succeed())
+					Eventually(done, 10*time.Second).Should(BeClosed())
+				})
+			}
+		})
+	}
+})
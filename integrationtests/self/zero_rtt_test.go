@@ -243,6 +243,47 @@ var _ = Describe("0-RTT", func() {
 				})
 			}
 
+			It("transfers 0-RTT data after exporting and importing the session state", func() {
+				tlsConf, clientTLSConf := dialAndReceiveSessionTicket(nil)
+
+				// export the session states, and import them into a new session cache, as if the client had been restarted
+				cache := clientTLSConf.ClientSessionCache.(*clientSessionCache)
+				exported := make(map[string][]byte)
+				cache.mutex.Lock()
+				for key, state := range cache.cache {
+					data, err := quic.MarshalClientSessionState(state)
+					Expect(err).ToNot(HaveOccurred())
+					exported[key] = data
+				}
+				cache.mutex.Unlock()
+				Expect(exported).ToNot(BeEmpty())
+				newCache := tls.NewLRUClientSessionCache(10)
+				for key, data := range exported {
+					state, err := quic.UnmarshalClientSessionState(data)
+					Expect(err).ToNot(HaveOccurred())
+					newCache.Put(key, state)
+				}
+				clientTLSConf = getTLSClientConfig()
+				clientTLSConf.ClientSessionCache = newCache
+
+				ln, err := quic.ListenAddrEarly(
+					"localhost:0",
+					tlsConf,
+					getQuicConfig(&quic.Config{
+						Versions:    []protocol.VersionNumber{version},
+						AcceptToken: func(_ net.Addr, _ *quic.Token) bool { return true },
+					}),
+				)
+				Expect(err).ToNot(HaveOccurred())
+				defer ln.Close()
+
+				proxy, num0RTTPackets := runCountingProxy(ln.Addr().(*net.UDPAddr).Port)
+				defer proxy.Close()
+
+				transfer0RTTData(ln, proxy.LocalPort(), clientTLSConf, nil, PRData)
+				Expect(atomic.LoadUint32(num0RTTPackets)).ToNot(BeZero())
+			})
+
 			// Test that data intended to be sent with 1-RTT protection is not sent in 0-RTT packets.
 			It("waits for a connection until the handshake is done", func() {
 				tlsConf, clientConf := dialAndReceiveSessionTicket(nil)
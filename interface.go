@@ -7,6 +7,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/logging"
@@ -25,6 +26,145 @@ const (
 	Version1 = protocol.Version1
 )
 
+// A ConnectionID is a QUIC Connection ID, as defined in RFC 9000.
+type ConnectionID = protocol.ConnectionID
+
+// A ConnectionIDGenerator generates the connection IDs used by an endpoint.
+// This allows encoding information into connection IDs, for example routing information
+// for a QUIC-aware load balancer (see https://datatracker.ietf.org/doc/draft-ietf-quic-load-balancers/).
+type ConnectionIDGenerator interface {
+	// GenerateConnectionID generates a new connection ID.
+	// It is called for the connection ID used during the handshake, and for every NEW_CONNECTION_ID frame.
+	// Connection IDs must be unique, and they must be ConnectionIDLen bytes long.
+	GenerateConnectionID() (ConnectionID, error)
+	// ConnectionIDLen returns the length of the connection IDs generated.
+	// It must always return the same value, between 4 and 18.
+	ConnectionIDLen() int
+}
+
+// A CongestionControlAlgorithm is a congestion control algorithm.
+type CongestionControlAlgorithm = congestion.Algorithm
+
+const (
+	// CongestionControlNewReno is NewReno (RFC 9002, Section 7). It is used by default.
+	CongestionControlNewReno = congestion.AlgorithmNewReno
+	// CongestionControlBBR is BBR (draft-cardwell-iccrg-bbr-congestion-control).
+	// Instead of reacting to packet loss, it paces packets at the measured bandwidth of the path.
+	// It performs better than NewReno on paths with a large bandwidth-delay product and on lossy paths.
+	CongestionControlBBR = congestion.AlgorithmBBR
+	// CongestionControlCubic is CUBIC (RFC 8312).
+	CongestionControlCubic = congestion.AlgorithmCubic
+	// CongestionControlCustom uses the congestion controller returned by Config.NewCongestionController.
+	CongestionControlCustom = congestion.AlgorithmCustom
+)
+
+// A CongestionController implements a custom congestion control algorithm.
+// All methods are called from the connection's run loop, so implementations don't need to be thread-safe.
+type CongestionController = congestion.SendAlgorithmWithDebugInfos
+
+// A BandwidthEstimator is a CongestionController that estimates the bandwidth of the path.
+// The built-in congestion controllers implement this interface.
+// The estimate is reported in the ConnectionStats.
+type BandwidthEstimator = congestion.BandwidthEstimator
+
+// Bandwidth is a bandwidth, in bits per second.
+type Bandwidth = congestion.Bandwidth
+
+// A DatagramDropPolicy determines which datagram is dropped when a datagram queue is full.
+type DatagramDropPolicy uint8
+
+const (
+	// DatagramDropNewest drops a datagram received when the receive queue is full.
+	// SendMessage blocks until the datagram has been dequeued for sending, so datagrams are never dropped from the send queue.
+	DatagramDropNewest DatagramDropPolicy = iota
+	// DatagramDropOldest drops the oldest datagram in the queue to make room for the new one.
+	// SendMessage doesn't wait for the datagram to be sent, and never blocks.
+	// This is useful for real-time data, where a new datagram supersedes the previous ones.
+	DatagramDropOldest
+)
+
+// A DatagramPriority determines how DATAGRAM frames are prioritized against stream data when assembling packets.
+type DatagramPriority uint8
+
+const (
+	// DatagramPriorityAlwaysFirst packs a queued datagram into every packet, before any stream data.
+	DatagramPriorityAlwaysFirst DatagramPriority = iota
+	// DatagramPriorityFairShare alternates between packets starting with a datagram and packets starting with stream data,
+	// as long as both datagrams and stream data are waiting to be sent.
+	DatagramPriorityFairShare
+	// DatagramPriorityBackground only sends datagrams when there's no stream data (and no retransmission) waiting to be sent.
+	// Datagrams might be delayed for a long time when streams are busy.
+	DatagramPriorityBackground
+)
+
+// A MigrationReason is the reason for an automatic connection migration.
+type MigrationReason uint8
+
+const (
+	// MigrationReasonNetworkChange means that the local address used to reach the server changed.
+	MigrationReasonNetworkChange MigrationReason = iota + 1
+	// MigrationReasonSendError means that sending a packet failed.
+	MigrationReasonSendError
+)
+
+func (r MigrationReason) String() string {
+	switch r {
+	case MigrationReasonNetworkChange:
+		return "network change"
+	case MigrationReasonSendError:
+		return "send error"
+	default:
+		return "unknown migration reason"
+	}
+}
+
+// A MigrationEvent describes an automatic connection migration.
+type MigrationEvent struct {
+	Reason MigrationReason
+	// OldLocalAddr is the local address of the connection before the migration.
+	OldLocalAddr net.Addr
+	// NewLocalAddr is the local address of the connection after the migration.
+	// It is nil if the migration failed.
+	NewLocalAddr net.Addr
+	// Err is the reason the migration failed.
+	// In that case, the connection continues to use the old path.
+	Err error
+}
+
+// A PathID identifies a network path of a multipath connection.
+// The path used during the handshake has the ID 0.
+type PathID uint64
+
+// A PathInfo describes a network path of a multipath connection.
+type PathInfo struct {
+	ID          PathID
+	LocalAddr   net.Addr
+	RemoteAddr  net.Addr
+	SmoothedRTT time.Duration
+}
+
+// A MultipathSchedulingPolicy determines which path a packet is sent on, when using multipath.
+type MultipathSchedulingPolicy uint8
+
+const (
+	// MultipathSchedulingMinRTT sends packets on the path with the lowest smoothed RTT,
+	// as long as that path is not limited by congestion control. It is used by default.
+	MultipathSchedulingMinRTT MultipathSchedulingPolicy = iota
+	// MultipathSchedulingRoundRobin sends packets on all paths in turn.
+	MultipathSchedulingRoundRobin
+	// MultipathSchedulingCustom uses the scheduler returned by Config.NewMultipathScheduler.
+	MultipathSchedulingCustom
+)
+
+// A MultipathScheduler selects the path that the next packet is sent on.
+// All methods are called from the connection's run loop, so implementations don't need to be thread-safe.
+type MultipathScheduler interface {
+	// SelectPath is called with all validated paths that are currently allowed to send a packet,
+	// sorted by their ID. It is never called with an empty slice.
+	// If the returned ID doesn't belong to any of these paths, the first path is used.
+	SelectPath([]PathInfo) PathID
+}
+
 // A Token can be used to verify the ownership of the client address.
 type Token struct {
 	// IsRetryToken encodes how the client received the token. There are two ways:
@@ -33,14 +173,21 @@ type Token struct {
 	IsRetryToken bool
 	RemoteAddr   string
 	SentTime     time.Time
+	// only set for Retry tokens
+	OriginalDestConnectionID ConnectionID
+	RetrySrcConnectionID     ConnectionID
 }
 
 // A ClientToken is a token received by the client.
 // It can be used to skip address validation on future connection attempts.
+// Tokens can be persisted using MarshalBinary and UnmarshalBinary.
 type ClientToken struct {
 	data []byte
 }
 
+// A TokenStore stores tokens received by the client.
+// NewLRUTokenStore creates an in-memory TokenStore, NewFileTokenStore creates a TokenStore
+// that persists tokens across restarts of the process.
 type TokenStore interface {
 	// Pop searches for a ClientToken associated with the given key.
 	// Since tokens are not supposed to be reused, it must remove the token from the cache.
@@ -90,6 +237,17 @@ type ReceiveStream interface {
 	// If the connection was closed due to a timeout, the error satisfies
 	// the net.Error interface, and Timeout() will be true.
 	io.Reader
+	// Peek returns the next n bytes without consuming them, e.g. to inspect a stream type prefix.
+	// It blocks until n bytes are available, and respects the read deadline.
+	// If Peek returns fewer than n bytes, it also returns an error explaining why the read is short.
+	// Flow control credit is only granted for data consumed by Read, so n must not exceed the receive window.
+	// The returned slice is a copy. Peek must not be called concurrently with Read.
+	Peek(n int) ([]byte, error)
+	// Buffered returns the number of bytes that can be read without blocking.
+	Buffered() int
+	// WriteTo writes the stream data to w until EOF or an error occurs.
+	// It is used by io.Copy, and avoids allocating a new buffer for every copy.
+	io.WriterTo
 	// CancelRead aborts receiving on this stream.
 	// It will ask the peer to stop transmitting stream data.
 	// Read will unblock immediately, and future Read calls will fail.
@@ -114,6 +272,19 @@ type SendStream interface {
 	// If the connection was closed due to a timeout, the error satisfies
 	// the net.Error interface, and Timeout() will be true.
 	io.Writer
+	// WriteVectored writes the data of all buffers, as if they were concatenated.
+	// It behaves like Write, and returns the total number of bytes written.
+	WriteVectored(bufs [][]byte) (int, error)
+	// WriteOwned writes the data of all buffers, and transfers the ownership of the buffers to the stream.
+	// The data is sent directly from these buffers, without copying it.
+	// The buffers must not be modified after calling WriteOwned, since the data might need to be retransmitted.
+	// Like Write, it blocks until all but the last packet's worth of data has been sent, and respects the write deadline.
+	// It must not be called concurrently with Write.
+	WriteOwned(bufs ...[]byte) (int, error)
+	// ReadFrom reads data from r until EOF or an error occurs, and writes it to the stream.
+	// It is used by io.Copy, and reads the data directly into the packet buffers.
+	// Like Write, it respects the write deadline.
+	io.ReaderFrom
 	// Close closes the write-direction of the stream.
 	// Future calls to Write are not permitted after calling Close.
 	// It must not be called concurrently with Write.
@@ -135,6 +306,37 @@ type SendStream interface {
 	// some data was successfully written.
 	// A zero value for t means Write will not time out.
 	SetWriteDeadline(t time.Time) error
+	// SetPriority sets the priority of the stream.
+	// The priority determines the order in which the data of the streams is packed into packets,
+	// see StreamPriority for details.
+	SetPriority(StreamPriority)
+	// SetDataExpiry makes the stream partially reliable, see Config.EnablePartialReliability.
+	// Lost stream data is not retransmitted if it was sent more than maxAge ago,
+	// or if more than maxBytes of stream data were sent after it.
+	// A zero value disables the respective limit.
+	// The peer is notified about the abandoned data, and skips it when reading, see StreamDataExpiredError.
+	// It has no effect if the peer doesn't support partial reliability.
+	SetDataExpiry(maxAge time.Duration, maxBytes logging.ByteCount)
+	// SetReliableBoundary marks the data written so far as reliable, see Config.EnableReliableStreamReset.
+	// When the stream is canceled using CancelWrite, this data is still delivered to the peer.
+	// It can be called multiple times to move the boundary forward.
+	// It has no effect if the peer doesn't support reliable stream resets.
+	SetReliableBoundary()
+}
+
+// DefaultStreamUrgency is the urgency of streams that don't have a priority set.
+const DefaultStreamUrgency = 3
+
+// A StreamPriority is the priority of a stream.
+type StreamPriority struct {
+	// Urgency is a strict priority: As long as a stream with a lower urgency has data to send,
+	// streams with a higher urgency only get the space that's left in a packet.
+	// Note that the default urgency is DefaultStreamUrgency, not 0.
+	Urgency uint8
+	// Weight determines how streams of the same urgency share the bandwidth.
+	// These streams take turns, and in its turn, every stream fills up to Weight packets.
+	// A weight of 0 is treated like a weight of 1, which is the default.
+	Weight uint8
 }
 
 // A Connection is a QUIC connection between two peers.
@@ -190,10 +392,62 @@ type Connection interface {
 	// It blocks until the handshake completes.
 	// Warning: This API should not be considered stable and might change soon.
 	ConnectionState() ConnectionState
+	// Migrate migrates the connection to a new network path, using the given packet conn.
+	// It validates the new path by sending PATH_CHALLENGE frames, and blocks until validation completes.
+	// Once the path is validated, the connection switches to a new connection ID and sends all packets on the new path.
+	// Only the client can migrate a connection, and only after the handshake has been confirmed.
+	// The packet conn is not closed when the connection is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	Migrate(context.Context, net.PacketConn) error
+	// ProbePath validates a network path using the given packet conn, without migrating the connection to it.
+	// It sends PATH_CHALLENGE frames, blocks until validation completes, and returns the RTT measured on the path.
+	// This allows applications to check if a backup path is usable before calling Migrate.
+	// Only the client can probe paths, and only after the handshake has been confirmed.
+	// The packet conn is not closed when the connection is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	ProbePath(context.Context, net.PacketConn) (time.Duration, error)
+	// AddPath adds a new network path to a multipath connection, using the given packet conn.
+	// It validates the new path by sending PATH_CHALLENGE frames, and blocks until validation completes.
+	// Once the path is validated, packets are sent on all paths, as determined by the multipath scheduler.
+	// Only the client can add paths, and only after the handshake has been confirmed.
+	// Multipath needs to be enabled on both endpoints, see Config.EnableMultipath.
+	// The packet conn is not closed when the path is removed or the connection is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	AddPath(context.Context, net.PacketConn) (PathID, error)
+	// RemovePath abandons a path of a multipath connection.
+	// Packets that were in flight on that path are retransmitted on the remaining paths.
+	// The initial path can't be removed.
+	// Warning: This API should not be considered stable and might change soon.
+	RemovePath(PathID) error
+	// UpdateKeys initiates a key update (see RFC 9001, section 6).
+	// Key updates are performed automatically before the AEAD limits are reached.
+	// This function allows applications to rotate keys more frequently.
+	// Since a new key update can only be initiated after the peer acknowledged the previous one,
+	// the key update might be delayed. The current key phase is reported in the ConnectionState.
+	// Key updates are only possible after the handshake has been confirmed.
+	UpdateKeys() error
+	// Stats returns statistics about the connection, e.g. the RTT and the congestion window.
+	// This allows applications to export metrics without implementing a logging.Tracer.
+	// After the connection is closed, it returns the values at the time of closing.
+	Stats() ConnectionStats
 
 	// SendMessage sends a message as a datagram.
 	// See https://datatracker.ietf.org/doc/draft-pauly-quic-datagram/.
+	// By default, it blocks until the datagram has been dequeued for sending,
+	// such that an application sending faster than the connection can send is slowed down.
+	// If Config.DatagramDropPolicy is DatagramDropOldest, it returns as soon as the datagram is queued,
+	// and drops the oldest queued datagram if the send queue is full.
 	SendMessage([]byte) error
+	// SendMessageWithCallback sends a message as a datagram, like SendMessage.
+	// The callback is called once it is known if the datagram was delivered:
+	// acked is true when the peer acknowledged the DATAGRAM frame, and false if it was declared lost,
+	// or dropped from the send queue (see Config.DatagramDropPolicy).
+	// This allows applications to retransmit important messages.
+	// The callback is called at most once. If the connection is closed before the outcome is known,
+	// it is called with acked set to false, since the datagram might not have been delivered.
+	// It is not called if SendMessageWithCallback returns an error.
+	// It must not block, since it might be called from the connection's run loop.
+	SendMessageWithCallback(msg []byte, onDelivery func(acked bool)) error
 	// ReceiveMessage gets a message received in a datagram.
 	// See https://datatracker.ietf.org/doc/draft-pauly-quic-datagram/.
 	ReceiveMessage() ([]byte, error)
@@ -226,7 +480,33 @@ type Config struct {
 	// If used for dialing an address, a 0 byte connection ID will be used.
 	// If used for a server, or dialing on a packet conn, a 4 byte connection ID will be used.
 	// When dialing on a packet conn, the ConnectionIDLength value must be the same for every Dial call.
+	// It is ignored if a ConnectionIDGenerator is set.
 	ConnectionIDLength int
+	// ConnectionIDGenerator generates the connection IDs issued by this endpoint.
+	// If not set, random connection IDs of ConnectionIDLength bytes are used.
+	// When dialing on a packet conn, the generated connection IDs must have the same length for every Dial call.
+	ConnectionIDGenerator ConnectionIDGenerator
+	// ZeroLengthConnectionIDs makes the client use a zero-length connection ID when dialing on a packet conn.
+	// Since packets then can't be demultiplexed using the connection ID, they are demultiplexed using the
+	// remote address instead. This only works if every Dial call on that packet conn uses zero-length connection IDs,
+	// and if there's at most one connection to every remote address.
+	// It can't be combined with ConnectionIDLength, ConnectionIDGenerator or EnableMultipath.
+	// It is ignored for servers.
+	ZeroLengthConnectionIDs bool
+	// ActiveConnectionIDLimit is the maximum number of connection IDs that the peer is allowed to provide.
+	// It is sent to the peer in the active_connection_id_limit transport parameter.
+	// A higher limit makes it possible for the peer to provide more spare connection IDs,
+	// which are needed when migrating the connection or when using multiple paths.
+	// It must be at least 2. If not set, it will default to 4.
+	ActiveConnectionIDLimit int
+	// PacketsPerConnectionID is the average number of packets sent using a connection ID provided by the peer,
+	// before switching to a new connection ID and retiring the old one.
+	// The actual number is randomized, to hide the packet loss rate from on-path observers.
+	// Values above 2^30 are invalid.
+	// If not set, it will default to 10000.
+	// If set to a negative value, connection IDs are only switched when the connection migrates,
+	// or when the peer requests the retirement of the connection ID in use.
+	PacketsPerConnectionID int
 	// HandshakeIdleTimeout is the idle timeout before completion of the handshake.
 	// Specifically, if we don't receive any packet from the peer within this time, the connection attempt is aborted.
 	// If this value is zero, the timeout is set to 5 seconds.
@@ -245,6 +525,19 @@ type Config struct {
 	//   * else, that it was issued within the last 24 hours.
 	// This option is only valid for the server.
 	AcceptToken func(clientAddr net.Addr, token *Token) bool
+	// RequireAddressValidation determines if a client that didn't present a valid token needs to validate
+	// its address before a connection is created.
+	// If it returns true, AcceptToken is called with a nil token. By default, this causes a Retry to be sent.
+	// If it returns false, the connection is accepted right away, saving the round trip caused by the Retry.
+	// This can be used to only perform address validation for untrusted source addresses.
+	// If not set, AcceptToken is called for every client.
+	// This option is only valid for the server.
+	RequireAddressValidation func(clientAddr net.Addr) bool
+	// TokenGenerator generates and decodes the tokens sent in Retry packets and NEW_TOKEN frames.
+	// Servers using the same TokenGenerator (or the same keys, see NewTokenGenerator) accept each other's tokens.
+	// If not set, tokens are protected using a random key that is generated when the server is started.
+	// This option is only valid for the server.
+	TokenGenerator TokenGenerator
 	// The TokenStore stores tokens received from the server.
 	// Tokens are used to skip address validation on future connection attempts.
 	// The key used to store tokens is the ServerName from the tls.Config, if set
@@ -284,6 +577,10 @@ type Config struct {
 	// If set to a negative value, it doesn't allow any unidirectional streams.
 	MaxIncomingUniStreams int64
 	// The StatelessResetKey is used to generate stateless reset tokens.
+	// Tokens are derived from the connection ID, so all servers using the same key can send stateless resets
+	// for each other's connections. This allows a fleet of servers behind a load balancer to reset connections
+	// that are routed to a server that doesn't have any state for them (e.g. after a restart).
+	// The key must be kept secret, and should be at least 32 bytes long.
 	// If no key is configured, sending of stateless resets is disabled.
 	StatelessResetKey []byte
 	// KeepAlive defines whether this peer will periodically send a packet to keep the connection alive.
@@ -292,6 +589,20 @@ type Config struct {
 	// Packets will then be at most 1252 (IPv4) / 1232 (IPv6) bytes in size.
 	// Note that if Path MTU discovery is causing issues on your system, please open a new issue
 	DisablePathMTUDiscovery bool
+	// CongestionControl is the congestion control algorithm used for sending.
+	// If not set, NewReno is used.
+	CongestionControl CongestionControlAlgorithm
+	// NewCongestionController creates the congestion controller for a connection.
+	// It must be set if (and only if) CongestionControl is CongestionControlCustom.
+	NewCongestionController func(rttStats *logging.RTTStats, initialMaxDatagramSize logging.ByteCount) CongestionController
+	// InitialCongestionWindow is the initial congestion window, in packets.
+	// If not set, it defaults to 32 packets.
+	// It has no effect when using a custom congestion controller.
+	InitialCongestionWindow int
+	// MinCongestionWindow is the minimum congestion window, in packets.
+	// If not set, it defaults to 2 packets for NewReno and CUBIC, and 4 packets for BBR.
+	// It has no effect when using a custom congestion controller.
+	MinCongestionWindow int
 	// DisableVersionNegotiationPackets disables the sending of Version Negotiation packets.
 	// This can be useful if version information is exchanged out-of-band.
 	// It has no effect for a client.
@@ -299,13 +610,126 @@ type Config struct {
 	// See https://datatracker.ietf.org/doc/draft-ietf-quic-datagram/.
 	// Datagrams will only be available when both peers enable datagram support.
 	EnableDatagrams bool
-	Tracer          logging.Tracer
+	// DatagramSendQueueLen is the maximum number of datagrams queued for sending.
+	// If not set, it defaults to 1.
+	// Unless DatagramDropOldest is used, SendMessage waits until its datagram is dequeued,
+	// so the queue only holds more than one datagram if SendMessage is called concurrently.
+	DatagramSendQueueLen int
+	// DatagramReceiveQueueLen is the maximum number of received datagrams queued until they are read by ReceiveMessage.
+	// If not set, it defaults to 128.
+	DatagramReceiveQueueLen int
+	// DatagramDropPolicy determines which datagram is dropped when a datagram queue is full.
+	// If not set, DatagramDropNewest is used.
+	// The number of dropped datagrams is reported in the ConnectionStats.
+	DatagramDropPolicy DatagramDropPolicy
+	// DatagramPriority determines how datagrams are prioritized against stream data.
+	// If not set, DatagramPriorityAlwaysFirst is used.
+	DatagramPriority DatagramPriority
+	// EnableAckFrequency enables the ACK frequency extension.
+	// See https://datatracker.ietf.org/doc/draft-ietf-quic-ack-frequency/.
+	// If both peers enable it, the sender of data asks the receiver to send fewer ACKs,
+	// which reduces the overhead (and the CPU and power usage) of high-bandwidth transfers.
+	EnableAckFrequency bool
+	// EnableAutomaticMigration makes the client migrate the connection to a new UDP socket when the network changes,
+	// i.e. when the local address used to reach the server changes, or when sending a packet fails.
+	// Without it, such a connection would eventually run into the idle timeout.
+	// It only takes effect once the handshake is confirmed, and has no effect for a server.
+	EnableAutomaticMigration bool
+	// ConnectionMigrated is called after every automatic migration attempt.
+	// It is called from a separate Go routine.
+	ConnectionMigrated func(conn Connection, ev MigrationEvent)
+	// EnableActiveMigration allows clients to migrate connections to this server.
+	// If not set, the server sends the disable_active_migration transport parameter,
+	// and clients won't migrate (or probe new paths) after the handshake.
+	// The server validates every new client address before sending more than 3 times the amount of data
+	// received from it, and falls back to the previous address if validation fails.
+	// It has no effect for a client.
+	EnableActiveMigration bool
+	// EnableMultipath enables the multipath extension.
+	// See https://datatracker.ietf.org/doc/draft-ietf-quic-multipath/.
+	// If both peers enable it, the client can use multiple paths at the same time, see Connection.AddPath.
+	// Every path uses its own packet number space and congestion controller.
+	// Since a path is identified by its connection IDs, the client needs to use non-zero-length connection IDs.
+	EnableMultipath bool
+	// MultipathScheduling is the policy used for choosing the path a packet is sent on, when using multipath.
+	// If not set, the path with the lowest RTT is used.
+	MultipathScheduling MultipathSchedulingPolicy
+	// NewMultipathScheduler creates the multipath scheduler for a connection.
+	// It must be set if (and only if) MultipathScheduling is MultipathSchedulingCustom.
+	NewMultipathScheduler func() MultipathScheduler
+	// EnablePartialReliability enables the partial reliability extension.
+	// See https://datatracker.ietf.org/doc/draft-lubashev-quic-partial-reliability/.
+	// If both peers enable it, the sender can abandon stale stream data instead of retransmitting it,
+	// which is useful for live media. This is configured per stream, see SendStream.SetDataExpiry.
+	EnablePartialReliability bool
+	// EnableReliableStreamReset enables the reliable stream reset extension (RESET_STREAM_AT).
+	// See https://datatracker.ietf.org/doc/draft-ietf-quic-reliable-stream-reset/.
+	// If both peers enable it, a stream can be reset while still delivering the data up to a certain offset,
+	// see SendStream.SetReliableBoundary.
+	EnableReliableStreamReset bool
+	// TLSBackend is the TLS 1.3 implementation used for the handshake.
+	// If nil, qtls (a fork of the standard library's crypto/tls) is used.
+	// A custom backend makes it possible to use a different TLS stack, e.g. a FIPS validated one.
+	TLSBackend TLSBackend
+	Tracer     logging.Tracer
+	// Logger is used to log the operation of the connections.
+	// If nil, quic-go logs to the standard library's log package, at the level set by the QUIC_GO_LOG_LEVEL environment variable.
+	Logger logging.Logger
 }
 
 // ConnectionState records basic details about a QUIC connection
 type ConnectionState struct {
 	TLS               handshake.ConnectionState
 	SupportsDatagrams bool
+	// MaxDatagramPayloadSize is the maximum size of a message that can be sent using SendMessage.
+	// It is 0 if datagrams are not supported.
+	MaxDatagramPayloadSize int
+	// Version is the QUIC version used on the connection.
+	Version VersionNumber
+	// KeyPhase is the current 1-RTT key phase, i.e. the number of key updates performed on the connection.
+	KeyPhase uint64
+}
+
+// ExportKeyingMaterial returns length bytes of exported key material as defined in RFC 8446, section 7.5.
+// This allows applications to derive secrets that are bound to the connection.
+// It returns an error if the handshake hasn't completed yet.
+func (s ConnectionState) ExportKeyingMaterial(label string, context []byte, length int) ([]byte, error) {
+	if !s.TLS.HandshakeComplete {
+		return nil, errors.New("quic: handshake not complete")
+	}
+	return s.TLS.ExportKeyingMaterial(label, context, length)
+}
+
+// ConnectionStats contains statistics about a QUIC connection.
+// For multipath connections, the RTT and congestion control values are those of the initial path.
+type ConnectionStats struct {
+	MinRTT      time.Duration
+	LatestRTT   time.Duration
+	SmoothedRTT time.Duration
+	// MeanDeviation is the RTT variation, see RFC 9002, section 5.3.
+	MeanDeviation time.Duration
+
+	CongestionWindow logging.ByteCount
+	BytesInFlight    logging.ByteCount
+	// BandwidthEstimate is the bandwidth of the path, as estimated by the congestion controller.
+	// For NewReno and CUBIC, this is the congestion window divided by the smoothed RTT.
+	// For BBR, this is the maximum bandwidth measured recently.
+	// Applications can use it to adapt the bitrate of media streams.
+	// It is 0 if no estimate is available yet, or if the congestion controller doesn't implement the BandwidthEstimator.
+	BandwidthEstimate Bandwidth
+
+	PacketsSent     uint64
+	PacketsReceived uint64
+	// PacketsLost is the number of packets that were declared lost by the loss detection.
+	PacketsLost uint64
+	// PacketsDropped is the number of packets that were received, but dropped,
+	// e.g. because they couldn't be decrypted or were duplicates.
+	PacketsDropped uint64
+
+	// DroppedSendDatagrams is the number of datagrams dropped because the send queue was full.
+	DroppedSendDatagrams uint64
+	// DroppedReceivedDatagrams is the number of datagrams dropped because the receive queue was full.
+	DroppedReceivedDatagrams uint64
 }
 
 // A Listener for incoming QUIC connections
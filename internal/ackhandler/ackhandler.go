@@ -1,6 +1,7 @@
 package ackhandler
 
 import (
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/logging"
@@ -10,12 +11,37 @@ import (
 func NewAckHandler(
 	initialPacketNumber protocol.PacketNumber,
 	initialMaxDatagramSize protocol.ByteCount,
+	congestionControl congestion.Config,
 	rttStats *utils.RTTStats,
 	pers protocol.Perspective,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
 	version protocol.VersionNumber,
 ) (SentPacketHandler, ReceivedPacketHandler) {
-	sph := newSentPacketHandler(initialPacketNumber, initialMaxDatagramSize, rttStats, pers, tracer, logger)
+	sph := newSentPacketHandler(initialPacketNumber, initialMaxDatagramSize, congestionControl, rttStats, pers, tracer, logger)
 	return sph, newReceivedPacketHandler(sph, rttStats, logger, version)
 }
+
+// NewPathAckHandler creates a new SentPacketHandler and a new ReceivedPacketHandler for an additional path,
+// when using multipath. Every path uses its own packet number space for application data.
+// Additional paths are only used after the handshake is confirmed,
+// so the returned handlers only handle 1-RTT packets.
+func NewPathAckHandler(
+	initialMaxDatagramSize protocol.ByteCount,
+	congestionControl congestion.Config,
+	rttStats *utils.RTTStats,
+	pers protocol.Perspective,
+	logger utils.Logger,
+	version protocol.VersionNumber,
+) (SentPacketHandler, ReceivedPacketHandler) {
+	sph := newSentPacketHandler(0, initialMaxDatagramSize, congestionControl, rttStats, pers, nil, logger)
+	sph.dropPackets(protocol.EncryptionInitial)
+	sph.dropPackets(protocol.EncryptionHandshake)
+	sph.peerAddressValidated = true
+	sph.peerCompletedAddressValidation = true
+	sph.SetHandshakeConfirmed()
+	rph := newReceivedPacketHandler(sph, rttStats, logger, version)
+	rph.DropPackets(protocol.EncryptionInitial)
+	rph.DropPackets(protocol.EncryptionHandshake)
+	return sph, rph
+}
@@ -3,6 +3,7 @@ package ackhandler
 import (
 	"time"
 
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
@@ -17,6 +18,7 @@ type Packet struct {
 	SendTime        time.Time
 
 	IsPathMTUProbePacket bool // We don't report the loss of Path MTU probe packets to the congestion controller.
+	IsPathProbePacket    bool // Packets sent on a path that is being validated are not subject to congestion control.
 
 	includedInBytesInFlight bool
 	declaredLost            bool
@@ -41,6 +43,13 @@ type SentPacketHandler interface {
 	// HasPacingBudget says if the pacer allows sending of a (full size) packet at this moment.
 	HasPacingBudget() bool
 	SetMaxDatagramSize(count protocol.ByteCount)
+	// MigratedPath is called when the connection is migrated to a new network path.
+	MigratedPath(now time.Time, rtt time.Duration, initialMaxDatagramSize protocol.ByteCount)
+
+	// EnableAckFrequency is called when both endpoints support the ACK frequency extension.
+	EnableAckFrequency(peerMinAckDelay time.Duration)
+	// GetAckFrequencyFrame returns an ACK_FREQUENCY frame, if the peer should change how often it sends ACKs.
+	GetAckFrequencyFrame(now time.Time) *wire.AckFrequencyFrame
 
 	// only to be called once the handshake is complete
 	QueueProbePacket(protocol.EncryptionLevel) bool /* was a packet queued */
@@ -50,6 +59,19 @@ type SentPacketHandler interface {
 
 	GetLossDetectionTimeout() time.Time
 	OnLossDetectionTimeout() error
+
+	// GetStats returns statistics about the sent packets and the state of the congestion controller.
+	GetStats() SentPacketStats
+}
+
+// SentPacketStats contains statistics collected by the SentPacketHandler.
+type SentPacketStats struct {
+	CongestionWindow protocol.ByteCount
+	BytesInFlight    protocol.ByteCount
+	// BandwidthEstimate is the bandwidth estimate of the congestion controller.
+	BandwidthEstimate congestion.Bandwidth
+	// PacketsLost is the number of packets that were declared lost.
+	PacketsLost uint64
 }
 
 type sentPacketTracker interface {
@@ -61,6 +83,10 @@ type sentPacketTracker interface {
 type ReceivedPacketHandler interface {
 	IsPotentiallyDuplicate(protocol.PacketNumber, protocol.EncryptionLevel) bool
 	ReceivedPacket(pn protocol.PacketNumber, ecn protocol.ECN, encLevel protocol.EncryptionLevel, rcvTime time.Time, shouldInstigateAck bool) error
+	// ReceivedAckFrequencyFrame applies the ACK frequency requested by the peer.
+	ReceivedAckFrequencyFrame(*wire.AckFrequencyFrame)
+	// ReceivedImmediateAckFrame makes sure that an ACK is sent right away.
+	ReceivedImmediateAckFrame()
 	DropPackets(protocol.EncryptionLevel)
 
 	GetAlarmTimeout() time.Time
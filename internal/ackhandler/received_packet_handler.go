@@ -66,6 +66,16 @@ func (h *receivedPacketHandler) ReceivedPacket(
 	return nil
 }
 
+func (h *receivedPacketHandler) ReceivedAckFrequencyFrame(f *wire.AckFrequencyFrame) {
+	// ACK_FREQUENCY frames only apply to the application data packet number space.
+	h.appDataPackets.ReceivedAckFrequencyFrame(f)
+}
+
+func (h *receivedPacketHandler) ReceivedImmediateAckFrame() {
+	// IMMEDIATE_ACK frames can only be sent in 0-RTT and 1-RTT packets.
+	h.appDataPackets.QueueImmediateAck()
+}
+
 func (h *receivedPacketHandler) DropPackets(encLevel protocol.EncryptionLevel) {
 	//nolint:exhaustive // 1-RTT packet number space is never dropped.
 	switch encLevel {
@@ -96,6 +96,38 @@ var _ = Describe("Received Packet Handler", func() {
 		Expect(handler.ReceivedPacket(11, protocol.ECNNon, protocol.Encryption0RTT, sendTime, true)).To(Succeed())
 	})
 
+	It("applies the ACK frequency to the application data packet number space", func() {
+		sentPackets.EXPECT().ReceivedPacket(gomock.Any()).AnyTimes()
+		sentPackets.EXPECT().GetLowestPacketNotConfirmedAcked().AnyTimes()
+		handler.ReceivedAckFrequencyFrame(&wire.AckFrequencyFrame{PacketTolerance: 3, UpdateMaxAckDelay: 10 * time.Millisecond})
+		sendTime := time.Now()
+		Expect(handler.ReceivedPacket(1, protocol.ECNNon, protocol.EncryptionHandshake, sendTime, true)).To(Succeed())
+		Expect(handler.ReceivedPacket(1, protocol.ECNNon, protocol.Encryption1RTT, sendTime, true)).To(Succeed())
+		Expect(handler.GetAckFrame(protocol.EncryptionHandshake, true)).ToNot(BeNil())
+		Expect(handler.GetAckFrame(protocol.Encryption1RTT, true)).ToNot(BeNil())
+		// the Handshake packet number space still uses the default ACK frequency
+		Expect(handler.ReceivedPacket(2, protocol.ECNNon, protocol.EncryptionHandshake, sendTime, true)).To(Succeed())
+		Expect(handler.ReceivedPacket(3, protocol.ECNNon, protocol.EncryptionHandshake, sendTime, true)).To(Succeed())
+		Expect(handler.GetAckFrame(protocol.EncryptionHandshake, true)).ToNot(BeNil())
+		Expect(handler.ReceivedPacket(2, protocol.ECNNon, protocol.Encryption1RTT, sendTime, true)).To(Succeed())
+		Expect(handler.ReceivedPacket(3, protocol.ECNNon, protocol.Encryption1RTT, sendTime, true)).To(Succeed())
+		Expect(handler.GetAckFrame(protocol.Encryption1RTT, true)).To(BeNil())
+		Expect(handler.GetAlarmTimeout()).To(Equal(sendTime.Add(10 * time.Millisecond)))
+		Expect(handler.ReceivedPacket(4, protocol.ECNNon, protocol.Encryption1RTT, sendTime, true)).To(Succeed())
+		Expect(handler.GetAckFrame(protocol.Encryption1RTT, true)).ToNot(BeNil())
+	})
+
+	It("sends an ACK immediately when receiving an IMMEDIATE_ACK frame", func() {
+		sentPackets.EXPECT().ReceivedPacket(gomock.Any()).AnyTimes()
+		sentPackets.EXPECT().GetLowestPacketNotConfirmedAcked().AnyTimes()
+		sendTime := time.Now()
+		Expect(handler.ReceivedPacket(1, protocol.ECNNon, protocol.Encryption1RTT, sendTime, true)).To(Succeed())
+		Expect(handler.GetAckFrame(protocol.Encryption1RTT, true)).ToNot(BeNil())
+		handler.ReceivedImmediateAckFrame()
+		Expect(handler.ReceivedPacket(2, protocol.ECNNon, protocol.Encryption1RTT, sendTime, true)).To(Succeed())
+		Expect(handler.GetAckFrame(protocol.Encryption1RTT, true)).ToNot(BeNil())
+	})
+
 	It("drops Initial packets", func() {
 		sentPackets.EXPECT().ReceivedPacket(gomock.Any()).Times(2)
 		sendTime := time.Now().Add(-time.Second)
@@ -9,6 +9,7 @@ import (
 )
 
 // number of ack-eliciting packets received before sending an ack.
+// The peer can change this value using an ACK_FREQUENCY frame.
 const packetsBeforeAck = 2
 
 type receivedPacketTracker struct {
@@ -22,8 +23,13 @@ type receivedPacketTracker struct {
 	maxAckDelay time.Duration
 	rttStats    *utils.RTTStats
 
+	// the ACK frequency, as requested by the peer using ACK_FREQUENCY frames
+	packetTolerance                uint64
+	ignoreOrder                    bool
+	nextAckFrequencySequenceNumber uint64
+
 	hasNewAck bool // true as soon as we received an ack-eliciting new packet
-	ackQueued bool // true once we received more than 2 (or later in the connection 10) ack-eliciting packets
+	ackQueued bool // true once we received packetTolerance ack-eliciting packets
 
 	ackElicitingPacketsReceivedSinceLastAck int
 	ackAlarm                                time.Time
@@ -40,11 +46,12 @@ func newReceivedPacketTracker(
 	version protocol.VersionNumber,
 ) *receivedPacketTracker {
 	return &receivedPacketTracker{
-		packetHistory: newReceivedPacketHistory(),
-		maxAckDelay:   protocol.MaxAckDelay,
-		rttStats:      rttStats,
-		logger:        logger,
-		version:       version,
+		packetHistory:   newReceivedPacketHistory(),
+		maxAckDelay:     protocol.MaxAckDelay,
+		packetTolerance: packetsBeforeAck,
+		rttStats:        rttStats,
+		logger:          logger,
+		version:         version,
 	}
 }
 
@@ -89,6 +96,30 @@ func (h *receivedPacketTracker) IgnoreBelow(p protocol.PacketNumber) {
 	}
 }
 
+// ReceivedAckFrequencyFrame applies the ACK frequency requested by the peer.
+// ACK_FREQUENCY frames that were reordered (i.e. that have a smaller sequence number than a frame received before) are ignored.
+func (h *receivedPacketTracker) ReceivedAckFrequencyFrame(f *wire.AckFrequencyFrame) {
+	if f.SequenceNumber < h.nextAckFrequencySequenceNumber {
+		return
+	}
+	h.nextAckFrequencySequenceNumber = f.SequenceNumber + 1
+	h.packetTolerance = f.PacketTolerance
+	h.maxAckDelay = f.UpdateMaxAckDelay
+	h.ignoreOrder = f.IgnoreOrder
+	if h.logger.Debug() {
+		h.logger.Debugf("\tUpdating ACK frequency: packet tolerance %d, max ack delay %s, ignore order: %t", h.packetTolerance, h.maxAckDelay, h.ignoreOrder)
+	}
+}
+
+// QueueImmediateAck queues an ACK, such that it is sent without any delay.
+func (h *receivedPacketTracker) QueueImmediateAck() {
+	if !h.ackQueued {
+		h.logger.Debugf("\tQueueing ACK because an IMMEDIATE_ACK frame was received.")
+	}
+	h.ackQueued = true
+	h.ackAlarm = time.Time{}
+}
+
 // isMissing says if a packet was reported missing in the last ACK.
 func (h *receivedPacketTracker) isMissing(p protocol.PacketNumber) bool {
 	if h.lastAck == nil || p < h.ignoreBelow {
@@ -125,17 +156,18 @@ func (h *receivedPacketTracker) maybeQueueAck(pn protocol.PacketNumber, rcvTime
 	// Send an ACK if this packet was reported missing in an ACK sent before.
 	// Ack decimation with reordering relies on the timer to send an ACK, but if
 	// missing packets we reported in the previous ack, send an ACK immediately.
-	if wasMissing {
+	// The peer might have asked us to ignore reordering.
+	if wasMissing && !h.ignoreOrder {
 		if h.logger.Debug() {
 			h.logger.Debugf("\tQueueing ACK because packet %d was missing before.", pn)
 		}
 		h.ackQueued = true
 	}
 
-	// send an ACK every 2 ack-eliciting packets
-	if h.ackElicitingPacketsReceivedSinceLastAck >= packetsBeforeAck {
+	// send an ACK every packetTolerance ack-eliciting packets
+	if uint64(h.ackElicitingPacketsReceivedSinceLastAck) >= h.packetTolerance {
 		if h.logger.Debug() {
-			h.logger.Debugf("\tQueueing ACK because packet %d packets were received after the last ACK (using initial threshold: %d).", h.ackElicitingPacketsReceivedSinceLastAck, packetsBeforeAck)
+			h.logger.Debugf("\tQueueing ACK because packet %d packets were received after the last ACK (using threshold: %d).", h.ackElicitingPacketsReceivedSinceLastAck, h.packetTolerance)
 		}
 		h.ackQueued = true
 	} else if h.ackAlarm.IsZero() {
@@ -146,7 +178,7 @@ func (h *receivedPacketTracker) maybeQueueAck(pn protocol.PacketNumber, rcvTime
 	}
 
 	// Queue an ACK if there are new missing packets to report.
-	if h.hasNewMissingPackets() {
+	if !h.ignoreOrder && h.hasNewMissingPackets() {
 		h.logger.Debugf("\tQueuing ACK because there's a new missing packet to report.")
 		h.ackQueued = true
 	}
@@ -191,6 +191,95 @@ var _ = Describe("Received Packet Tracker", func() {
 			})
 		})
 
+		Context("ACK frequency", func() {
+			receiveAndAck10Packets := func() {
+				for i := 1; i <= 10; i++ {
+					tracker.ReceivedPacket(protocol.PacketNumber(i), protocol.ECNNon, time.Time{}, true)
+				}
+				Expect(tracker.GetAckFrame(true)).ToNot(BeNil())
+				Expect(tracker.ackQueued).To(BeFalse())
+			}
+
+			It("uses the packet tolerance", func() {
+				receiveAndAck10Packets()
+				tracker.ReceivedAckFrequencyFrame(&wire.AckFrequencyFrame{
+					PacketTolerance:   5,
+					UpdateMaxAckDelay: protocol.MaxAckDelay,
+				})
+				p := protocol.PacketNumber(11)
+				for i := 0; i < 3; i++ {
+					for j := 0; j < 4; j++ {
+						tracker.ReceivedPacket(p, protocol.ECNNon, time.Time{}, true)
+						Expect(tracker.ackQueued).To(BeFalse())
+						p++
+					}
+					tracker.ReceivedPacket(p, protocol.ECNNon, time.Time{}, true)
+					Expect(tracker.ackQueued).To(BeTrue())
+					p++
+					Expect(tracker.GetAckFrame(true)).ToNot(BeNil())
+				}
+			})
+
+			It("uses the max ack delay", func() {
+				receiveAndAck10Packets()
+				tracker.ReceivedAckFrequencyFrame(&wire.AckFrequencyFrame{
+					PacketTolerance:   10,
+					UpdateMaxAckDelay: 5 * time.Millisecond,
+				})
+				rcvTime := time.Now()
+				tracker.ReceivedPacket(11, protocol.ECNNon, rcvTime, true)
+				Expect(tracker.GetAlarmTimeout()).To(Equal(rcvTime.Add(5 * time.Millisecond)))
+			})
+
+			It("ignores reordering, if requested", func() {
+				receiveAndAck10Packets()
+				tracker.ReceivedAckFrequencyFrame(&wire.AckFrequencyFrame{
+					PacketTolerance:   10,
+					UpdateMaxAckDelay: protocol.MaxAckDelay,
+					IgnoreOrder:       true,
+				})
+				// 11 is missing
+				tracker.ReceivedPacket(12, protocol.ECNNon, time.Now(), true)
+				Expect(tracker.ackQueued).To(BeFalse())
+				Expect(tracker.GetAckFrame(false)).ToNot(BeNil()) // ACK: 1-10, 12
+				tracker.ReceivedPacket(11, protocol.ECNNon, time.Now(), true)
+				Expect(tracker.ackQueued).To(BeFalse())
+			})
+
+			It("ignores reordered ACK_FREQUENCY frames", func() {
+				tracker.ReceivedAckFrequencyFrame(&wire.AckFrequencyFrame{
+					SequenceNumber:    1,
+					PacketTolerance:   10,
+					UpdateMaxAckDelay: 10 * time.Millisecond,
+				})
+				tracker.ReceivedAckFrequencyFrame(&wire.AckFrequencyFrame{
+					SequenceNumber:    0,
+					PacketTolerance:   20,
+					UpdateMaxAckDelay: 20 * time.Millisecond,
+				})
+				Expect(tracker.packetTolerance).To(BeEquivalentTo(10))
+				Expect(tracker.maxAckDelay).To(Equal(10 * time.Millisecond))
+				tracker.ReceivedAckFrequencyFrame(&wire.AckFrequencyFrame{
+					SequenceNumber:    2,
+					PacketTolerance:   30,
+					UpdateMaxAckDelay: 30 * time.Millisecond,
+				})
+				Expect(tracker.packetTolerance).To(BeEquivalentTo(30))
+				Expect(tracker.maxAckDelay).To(Equal(30 * time.Millisecond))
+			})
+
+			It("queues an ACK when an IMMEDIATE_ACK frame is received", func() {
+				receiveAndAck10Packets()
+				tracker.ReceivedPacket(11, protocol.ECNNon, time.Now(), true)
+				Expect(tracker.ackQueued).To(BeFalse())
+				Expect(tracker.GetAlarmTimeout()).ToNot(BeZero())
+				tracker.QueueImmediateAck()
+				Expect(tracker.ackQueued).To(BeTrue())
+				Expect(tracker.GetAlarmTimeout()).To(BeZero())
+				Expect(tracker.GetAckFrame(true)).ToNot(BeNil())
+			})
+		})
+
 		Context("ACK generation", func() {
 			It("generates an ACK for an ack-eliciting packet, if no ACK is queued yet", func() {
 				tracker.ReceivedPacket(1, protocol.ECNNon, time.Now(), true)
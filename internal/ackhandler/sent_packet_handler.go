@@ -23,6 +23,11 @@ const (
 	amplificationFactor = 3
 	// We use Retry packets to derive an RTT estimate. Make sure we don't set the RTT to a super low value yet.
 	minRTTAfterRetry = 5 * time.Millisecond
+	// When using the ACK frequency extension, we ask the peer to send (at least) this many ACKs
+	// per congestion window, and per RTT.
+	ackFrequencyAcksPerRTT = 4
+	// The maximum packet tolerance we request in ACK_FREQUENCY frames.
+	maxAckFrequencyPacketTolerance = 64
 )
 
 type packetNumberSpace struct {
@@ -76,9 +81,21 @@ type sentPacketHandler struct {
 	ackedPackets []*Packet // to avoid allocations in detectAndRemoveAckedPackets
 
 	bytesInFlight protocol.ByteCount
-
-	congestion congestion.SendAlgorithmWithDebugInfos
-	rttStats   *utils.RTTStats
+	packetsLost   uint64
+
+	congestion       congestion.SendAlgorithmWithDebugInfos
+	congestionConfig congestion.Config
+	rttStats         *utils.RTTStats
+	maxDatagramSize  protocol.ByteCount
+
+	// ACK frequency extension
+	// Only used if the peer supports it.
+	ackFrequencyEnabled         bool
+	peerMinAckDelay             time.Duration
+	ackFrequencySequenceNumber  uint64
+	lastAckFrequencyUpdate      time.Time
+	ackFrequencyPacketTolerance uint64
+	ackFrequencyMaxAckDelay     time.Duration
 
 	// The number of times a PTO has been sent without receiving an ack.
 	ptoCount uint32
@@ -104,16 +121,17 @@ var (
 func newSentPacketHandler(
 	initialPN protocol.PacketNumber,
 	initialMaxDatagramSize protocol.ByteCount,
+	congestionControl congestion.Config,
 	rttStats *utils.RTTStats,
 	pers protocol.Perspective,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
 ) *sentPacketHandler {
-	congestion := congestion.NewCubicSender(
+	congestion := congestion.NewSendAlgorithm(
+		congestionControl,
 		congestion.DefaultClock{},
 		rttStats,
 		initialMaxDatagramSize,
-		true, // use Reno
 		tracer,
 	)
 
@@ -125,6 +143,8 @@ func newSentPacketHandler(
 		appDataPackets:                 newPacketNumberSpace(0, true, rttStats),
 		rttStats:                       rttStats,
 		congestion:                     congestion,
+		congestionConfig:               congestionControl,
+		maxDatagramSize:                initialMaxDatagramSize,
 		perspective:                    pers,
 		tracer:                         tracer,
 		logger:                         logger,
@@ -599,10 +619,11 @@ func (h *sentPacketHandler) detectLostPackets(now time.Time, encLevel protocol.E
 		}
 		if packetLost {
 			p.declaredLost = true
+			h.packetsLost++
 			// the bytes in flight need to be reduced no matter if the frames in this packet will be retransmitted
 			h.removeFromBytesInFlight(p)
 			h.queueFramesForRetransmission(p)
-			if !p.IsPathMTUProbePacket {
+			if !p.IsPathMTUProbePacket && !p.IsPathProbePacket {
 				h.congestion.OnPacketLost(p.PacketNumber, p.Length, priorInFlight)
 			}
 		}
@@ -678,6 +699,15 @@ func (h *sentPacketHandler) GetLossDetectionTimeout() time.Time {
 	return h.alarm
 }
 
+func (h *sentPacketHandler) GetStats() SentPacketStats {
+	return SentPacketStats{
+		CongestionWindow:  h.congestion.GetCongestionWindow(),
+		BytesInFlight:     h.bytesInFlight,
+		BandwidthEstimate: congestion.GetBandwidthEstimate(h.congestion),
+		PacketsLost:       h.packetsLost,
+	}
+}
+
 func (h *sentPacketHandler) PeekPacketNumber(encLevel protocol.EncryptionLevel) (protocol.PacketNumber, protocol.PacketNumberLen) {
 	pnSpace := h.getPacketNumberSpace(encLevel)
 
@@ -747,9 +777,84 @@ func (h *sentPacketHandler) HasPacingBudget() bool {
 }
 
 func (h *sentPacketHandler) SetMaxDatagramSize(s protocol.ByteCount) {
+	h.maxDatagramSize = s
 	h.congestion.SetMaxDatagramSize(s)
 }
 
+// MigratedPath is called when the connection was migrated to a new network path.
+// All packets sent on the old path are declared lost, without reporting the loss to the congestion controller.
+// The congestion controller is reset, and the RTT estimate is initialized with the RTT measured on the new path.
+func (h *sentPacketHandler) MigratedPath(now time.Time, rtt time.Duration, initialMaxDatagramSize protocol.ByteCount) {
+	h.appDataPackets.history.Iterate(func(p *Packet) (bool, error) {
+		if p.declaredLost || p.skippedPacket {
+			return true, nil
+		}
+		p.declaredLost = true
+		h.removeFromBytesInFlight(p)
+		h.queueFramesForRetransmission(p)
+		return true, nil
+	})
+	h.rttStats.OnConnectionMigration()
+	if rtt > 0 {
+		h.rttStats.UpdateRTT(rtt, 0, now)
+	}
+	h.maxDatagramSize = initialMaxDatagramSize
+	h.congestion = congestion.NewSendAlgorithm(
+		h.congestionConfig,
+		congestion.DefaultClock{},
+		h.rttStats,
+		initialMaxDatagramSize,
+		h.tracer,
+	)
+	h.ptoCount = 0
+	h.numProbesToSend = 0
+	h.lastAckFrequencyUpdate = time.Time{}
+	h.setLossDetectionTimer()
+}
+
+func (h *sentPacketHandler) EnableAckFrequency(peerMinAckDelay time.Duration) {
+	h.ackFrequencyEnabled = true
+	h.peerMinAckDelay = peerMinAckDelay
+}
+
+// GetAckFrequencyFrame returns an ACK_FREQUENCY frame, if the ACK frequency requested from the peer should be updated.
+// We ask the peer to send an ACK every quarter of the congestion window, and at least every quarter of an RTT,
+// such that the ACK clock keeps working while the number of ACKs is reduced for large congestion windows.
+// The ACK frequency is updated at most once per RTT.
+func (h *sentPacketHandler) GetAckFrequencyFrame(now time.Time) *wire.AckFrequencyFrame {
+	if !h.ackFrequencyEnabled || !h.handshakeConfirmed {
+		return nil
+	}
+	rtt := h.rttStats.SmoothedRTT()
+	if rtt == 0 || (!h.lastAckFrequencyUpdate.IsZero() && now.Sub(h.lastAckFrequencyUpdate) < rtt) {
+		return nil
+	}
+	packetTolerance := uint64(h.congestion.GetCongestionWindow() / h.maxDatagramSize / ackFrequencyAcksPerRTT)
+	if packetTolerance < packetsBeforeAck {
+		packetTolerance = packetsBeforeAck
+	}
+	if packetTolerance > maxAckFrequencyPacketTolerance {
+		packetTolerance = maxAckFrequencyPacketTolerance
+	}
+	// We calculate the PTO using the max_ack_delay advertised by the peer.
+	// Never ask for a larger value, so we don't need to adjust the PTO calculation.
+	maxAckDelay := utils.MinDuration(h.rttStats.MaxAckDelay(), (rtt / ackFrequencyAcksPerRTT).Truncate(time.Millisecond))
+	maxAckDelay = utils.MaxDuration(maxAckDelay, h.peerMinAckDelay)
+	if packetTolerance == h.ackFrequencyPacketTolerance && maxAckDelay == h.ackFrequencyMaxAckDelay {
+		return nil
+	}
+	f := &wire.AckFrequencyFrame{
+		SequenceNumber:    h.ackFrequencySequenceNumber,
+		PacketTolerance:   packetTolerance,
+		UpdateMaxAckDelay: maxAckDelay,
+	}
+	h.ackFrequencySequenceNumber++
+	h.lastAckFrequencyUpdate = now
+	h.ackFrequencyPacketTolerance = packetTolerance
+	h.ackFrequencyMaxAckDelay = maxAckDelay
+	return f
+}
+
 func (h *sentPacketHandler) isAmplificationLimited() bool {
 	if h.peerAddressValidated {
 		return false
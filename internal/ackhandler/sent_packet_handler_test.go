@@ -6,6 +6,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
@@ -29,7 +30,7 @@ var _ = Describe("SentPacketHandler", func() {
 	JustBeforeEach(func() {
 		lostPackets = nil
 		rttStats := utils.NewRTTStats()
-		handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, perspective, nil, utils.DefaultLogger)
+		handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, congestion.Config{}, rttStats, perspective, nil, utils.DefaultLogger)
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
 			Data:     []byte{0x13, 0x37},
@@ -563,6 +564,28 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.bytesInFlight).To(BeZero())
 		})
 
+		It("doesn't call OnPacketLost when a path probe packet is lost", func() {
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+			var probePacketDeclaredLost bool
+			handler.SentPacket(ackElicitingPacket(&Packet{
+				PacketNumber:      1,
+				SendTime:          time.Now().Add(-time.Hour),
+				IsPathProbePacket: true,
+				Frames:            []Frame{{Frame: &wire.PathChallengeFrame{}, OnLost: func(wire.Frame) { probePacketDeclaredLost = true }}},
+			}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
+			// lose packet 1, but don't EXPECT any calls to OnPacketLost()
+			gomock.InOrder(
+				cong.EXPECT().MaybeExitSlowStart(),
+				cong.EXPECT().OnPacketAcked(protocol.PacketNumber(2), protocol.ByteCount(1), protocol.ByteCount(2), gomock.Any()),
+			)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			_, err := handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probePacketDeclaredLost).To(BeTrue())
+			Expect(handler.bytesInFlight).To(BeZero())
+		})
+
 		It("calls OnPacketAcked and OnPacketLost with the right bytes_in_flight value", func() {
 			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(4)
 			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: time.Now().Add(-time.Hour)}))
@@ -877,6 +900,14 @@ var _ = Describe("SentPacketHandler", func() {
 			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 5, SendTime: time.Now(), IsPathMTUProbePacket: true}))
 			Expect(handler.GetLossDetectionTimeout()).To(BeZero())
 		})
+
+		It("doesn't set the PTO timer for path probe packets", func() {
+			handler.ReceivedPacket(protocol.EncryptionHandshake)
+			handler.SetHandshakeConfirmed()
+			updateRTT(time.Second)
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 5, SendTime: time.Now(), IsPathProbePacket: true}))
+			Expect(handler.GetLossDetectionTimeout()).To(BeZero())
+		})
 	})
 
 	Context("amplification limit, for the server", func() {
@@ -1057,6 +1088,22 @@ var _ = Describe("SentPacketHandler", func() {
 			expectInPacketHistory([]protocol.PacketNumber{4, 5}, protocol.Encryption1RTT)
 			Expect(lostPackets).To(Equal([]protocol.PacketNumber{1, 2, 3}))
 		})
+
+		It("counts lost packets", func() {
+			for i := protocol.PacketNumber(1); i <= 6; i++ {
+				handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: i, Length: 10}))
+			}
+			stats := handler.GetStats()
+			Expect(stats.PacketsLost).To(BeZero())
+			Expect(stats.BytesInFlight).To(Equal(protocol.ByteCount(60)))
+			Expect(stats.CongestionWindow).To(Equal(handler.congestion.GetCongestionWindow()))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 6, Largest: 6}}}
+			_, err := handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			stats = handler.GetStats()
+			Expect(stats.PacketsLost).To(BeEquivalentTo(3))
+			Expect(stats.BytesInFlight).To(Equal(protocol.ByteCount(20)))
+		})
 	})
 
 	Context("Delay-based loss detection", func() {
@@ -1258,7 +1305,133 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.ptoCount).To(BeEquivalentTo(1))
 			handler.DropPackets(protocol.EncryptionHandshake)
 			Expect(handler.ptoCount).To(BeZero())
-			Expect(handler.SendMode()).To(Equal(SendAny))
+		})
+	})
+
+	Context("ACK frequency", func() {
+		var cong *mocks.MockSendAlgorithmWithDebugInfos
+
+		JustBeforeEach(func() {
+			cong = mocks.NewMockSendAlgorithmWithDebugInfos(mockCtrl)
+			handler.congestion = cong
+			handler.rttStats.SetMaxAckDelay(26 * time.Millisecond)
+			handler.rttStats.UpdateRTT(40*time.Millisecond, 0, time.Now())
+			handler.SetHandshakeConfirmed()
+		})
+
+		It("doesn't send ACK_FREQUENCY frames if the peer doesn't support the extension", func() {
+			Expect(handler.GetAckFrequencyFrame(time.Now())).To(BeNil())
+		})
+
+		It("doesn't send ACK_FREQUENCY frames before the handshake is confirmed", func() {
+			handler.handshakeConfirmed = false
+			handler.EnableAckFrequency(time.Millisecond)
+			Expect(handler.GetAckFrequencyFrame(time.Now())).To(BeNil())
+		})
+
+		It("requests an ACK every quarter of the congestion window and every quarter RTT", func() {
+			handler.EnableAckFrequency(time.Millisecond)
+			cong.EXPECT().GetCongestionWindow().Return(40 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			f := handler.GetAckFrequencyFrame(time.Now())
+			Expect(f).ToNot(BeNil())
+			Expect(f.SequenceNumber).To(BeZero())
+			Expect(f.PacketTolerance).To(BeEquivalentTo(10))
+			Expect(f.UpdateMaxAckDelay).To(Equal(10 * time.Millisecond))
+			Expect(f.IgnoreOrder).To(BeFalse())
+		})
+
+		It("limits the packet tolerance", func() {
+			handler.EnableAckFrequency(time.Millisecond)
+			cong.EXPECT().GetCongestionWindow().Return(4 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			now := time.Now()
+			f := handler.GetAckFrequencyFrame(now)
+			Expect(f).ToNot(BeNil())
+			Expect(f.PacketTolerance).To(BeEquivalentTo(packetsBeforeAck))
+			now = now.Add(time.Second)
+			cong.EXPECT().GetCongestionWindow().Return(10000 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			f = handler.GetAckFrequencyFrame(now)
+			Expect(f).ToNot(BeNil())
+			Expect(f.PacketTolerance).To(BeEquivalentTo(maxAckFrequencyPacketTolerance))
+		})
+
+		It("doesn't request a max ack delay larger than the peer's max_ack_delay, or smaller than its min_ack_delay", func() {
+			handler.EnableAckFrequency(20 * time.Millisecond)
+			cong.EXPECT().GetCongestionWindow().Return(40 * protocol.ByteCount(protocol.InitialPacketSizeIPv4)).Times(2)
+			f := handler.GetAckFrequencyFrame(time.Now())
+			Expect(f).ToNot(BeNil())
+			Expect(f.UpdateMaxAckDelay).To(Equal(20 * time.Millisecond))
+			handler.rttStats.UpdateRTT(time.Second, 0, time.Now())
+			f = handler.GetAckFrequencyFrame(time.Now().Add(time.Second))
+			Expect(f).ToNot(BeNil())
+			Expect(f.UpdateMaxAckDelay).To(Equal(26 * time.Millisecond))
+		})
+
+		It("updates the ACK frequency at most once per RTT, and only if it changed", func() {
+			handler.EnableAckFrequency(time.Millisecond)
+			now := time.Now()
+			cong.EXPECT().GetCongestionWindow().Return(40 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			Expect(handler.GetAckFrequencyFrame(now)).ToNot(BeNil())
+			Expect(handler.GetAckFrequencyFrame(now.Add(39 * time.Millisecond))).To(BeNil())
+			now = now.Add(40 * time.Millisecond)
+			cong.EXPECT().GetCongestionWindow().Return(40 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			Expect(handler.GetAckFrequencyFrame(now)).To(BeNil())
+			cong.EXPECT().GetCongestionWindow().Return(80 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			f := handler.GetAckFrequencyFrame(now)
+			Expect(f).ToNot(BeNil())
+			Expect(f.SequenceNumber).To(BeEquivalentTo(1))
+			Expect(f.PacketTolerance).To(BeEquivalentTo(20))
+		})
+
+		It("uses the current maximum datagram size", func() {
+			handler.EnableAckFrequency(time.Millisecond)
+			cong.EXPECT().SetMaxDatagramSize(2 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			handler.SetMaxDatagramSize(2 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			cong.EXPECT().GetCongestionWindow().Return(40 * protocol.ByteCount(protocol.InitialPacketSizeIPv4))
+			f := handler.GetAckFrequencyFrame(time.Now())
+			Expect(f).ToNot(BeNil())
+			Expect(f.PacketTolerance).To(BeEquivalentTo(5))
+		})
+	})
+
+	Context("path migration", func() {
+		It("declares all outstanding packets lost, without notifying the congestion controller", func() {
+			cong := mocks.NewMockSendAlgorithmWithDebugInfos(mockCtrl)
+			handler.congestion = cong
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 3}))
+			Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(3)))
+			handler.MigratedPath(time.Now(), 0, protocol.InitialPacketSizeIPv4)
+			Expect(lostPackets).To(Equal([]protocol.PacketNumber{1, 2, 3}))
+			Expect(handler.bytesInFlight).To(BeZero())
+			expectInPacketHistory([]protocol.PacketNumber{}, protocol.Encryption1RTT)
+			Expect(handler.congestion).ToNot(Equal(cong))
+		})
+
+		It("resets the congestion controller", func() {
+			initialWindow := handler.congestion.GetCongestionWindow()
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, Length: protocol.InitialPacketSizeIPv4}))
+			handler.congestion.OnPacketLost(1, protocol.InitialPacketSizeIPv4, handler.bytesInFlight)
+			Expect(handler.congestion.GetCongestionWindow()).To(BeNumerically("<", initialWindow))
+			handler.MigratedPath(time.Now(), 0, protocol.InitialPacketSizeIPv4)
+			Expect(handler.congestion.GetCongestionWindow()).To(Equal(initialWindow))
+		})
+
+		It("resets the RTT estimate, using the RTT measured on the new path", func() {
+			updateRTT(time.Second)
+			handler.MigratedPath(time.Now(), 20*time.Millisecond, protocol.InitialPacketSizeIPv4)
+			Expect(handler.rttStats.SmoothedRTT()).To(Equal(20 * time.Millisecond))
+			Expect(handler.rttStats.MinRTT()).To(Equal(20 * time.Millisecond))
+		})
+
+		It("resets the PTO count", func() {
+			handler.SetHandshakeConfirmed()
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: time.Now().Add(-time.Hour)}))
+			Expect(handler.OnLossDetectionTimeout()).To(Succeed())
+			Expect(handler.ptoCount).To(BeEquivalentTo(1))
+			handler.MigratedPath(time.Now(), 0, protocol.InitialPacketSizeIPv4)
+			Expect(handler.ptoCount).To(BeZero())
 		})
 	})
 
@@ -19,6 +19,20 @@ const (
 	BytesPerSecond = 8 * BitsPerSecond
 )
 
+// GetBandwidthEstimate returns the bandwidth estimate of a sender.
+// It returns 0 if the sender doesn't implement the BandwidthEstimator,
+// or if it doesn't have an estimate yet.
+func GetBandwidthEstimate(s SendAlgorithm) Bandwidth {
+	e, ok := s.(BandwidthEstimator)
+	if !ok {
+		return 0
+	}
+	if bw := e.BandwidthEstimate(); bw != infBandwidth {
+		return bw
+	}
+	return 0
+}
+
 // BandwidthFromDelta calculates the bandwidth from a number of bytes and a time delta
 func BandwidthFromDelta(bytes protocol.ByteCount, delta time.Duration) Bandwidth {
 	return Bandwidth(bytes) * Bandwidth(time.Second) / Bandwidth(delta) * BytesPerSecond
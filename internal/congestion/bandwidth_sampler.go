@@ -0,0 +1,125 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// sentPacketState is the state of the connection at the time a packet was sent.
+type sentPacketState struct {
+	sentTime      time.Time
+	size          protocol.ByteCount
+	delivered     protocol.ByteCount
+	deliveredTime time.Time
+	firstSentTime time.Time
+	isAppLimited  bool
+}
+
+// A rateSample is a delivery rate sample, taken when a packet is acknowledged.
+type rateSample struct {
+	bandwidth Bandwidth
+	rtt       time.Duration
+	// isAppLimited is set if the packet was sent while the sender was application-limited.
+	// The sample then might underestimate the bandwidth.
+	isAppLimited bool
+}
+
+// The bandwidthSampler estimates the delivery rate, as described in
+// https://datatracker.ietf.org/doc/html/draft-cheng-iccrg-delivery-rate-estimation.
+// For every packet, it remembers how many bytes had been delivered when the packet was sent.
+// When the packet is acknowledged, the delivery rate is the amount of data delivered since then,
+// divided by the time that passed.
+//
+// The packets are identified by their packet number.
+// Since packet numbers are not unique across packet number spaces, the state of a few
+// Initial and Handshake packets might be lost (or leaked, if the packet number space is dropped).
+// This only affects the first round trip.
+type bandwidthSampler struct {
+	packets map[protocol.PacketNumber]sentPacketState
+
+	// the total number of bytes acknowledged
+	delivered protocol.ByteCount
+	// the time when the last packet was acknowledged
+	deliveredTime time.Time
+	// the send time of the last packet that was acknowledged
+	firstSentTime time.Time
+
+	largestSent protocol.PacketNumber
+	// If the sender is application-limited, packets sent until the packet with the
+	// packet number endOfAppLimitedPhase is acknowledged are considered application-limited.
+	isAppLimited         bool
+	endOfAppLimitedPhase protocol.PacketNumber
+}
+
+func newBandwidthSampler() *bandwidthSampler {
+	return &bandwidthSampler{
+		packets:              make(map[protocol.PacketNumber]sentPacketState),
+		largestSent:          protocol.InvalidPacketNumber,
+		endOfAppLimitedPhase: protocol.InvalidPacketNumber,
+	}
+}
+
+// OnPacketSent is called for every packet that counts towards the bytes in flight.
+// priorInFlight is the number of bytes in flight before the packet was sent.
+func (s *bandwidthSampler) OnPacketSent(sentTime time.Time, priorInFlight protocol.ByteCount, pn protocol.PacketNumber, size protocol.ByteCount) {
+	s.largestSent = pn
+	// If no data is in flight, the send time of the last acknowledged packet doesn't say
+	// anything about the time it takes to send the data.
+	if priorInFlight == 0 {
+		s.firstSentTime = sentTime
+		s.deliveredTime = sentTime
+	}
+	s.packets[pn] = sentPacketState{
+		sentTime:      sentTime,
+		size:          size,
+		delivered:     s.delivered,
+		deliveredTime: s.deliveredTime,
+		firstSentTime: s.firstSentTime,
+		isAppLimited:  s.isAppLimited,
+	}
+}
+
+// OnPacketAcked is called when a packet is acknowledged.
+// It returns false if no rate sample could be taken.
+func (s *bandwidthSampler) OnPacketAcked(pn protocol.PacketNumber, ackTime time.Time) (rateSample, bool) {
+	p, ok := s.packets[pn]
+	if !ok {
+		return rateSample{}, false
+	}
+	delete(s.packets, pn)
+
+	s.delivered += p.size
+	s.deliveredTime = ackTime
+	s.firstSentTime = p.sentTime
+	if s.isAppLimited && pn > s.endOfAppLimitedPhase {
+		s.isAppLimited = false
+	}
+
+	// Use the longer of the send and the ack interval.
+	// This avoids overestimating the bandwidth if ACKs are compressed.
+	interval := p.sentTime.Sub(p.firstSentTime)
+	if ackInterval := ackTime.Sub(p.deliveredTime); ackInterval > interval {
+		interval = ackInterval
+	}
+	if interval <= 0 {
+		return rateSample{}, false
+	}
+	return rateSample{
+		bandwidth:    BandwidthFromDelta(s.delivered-p.delivered, interval),
+		rtt:          ackTime.Sub(p.sentTime),
+		isAppLimited: p.isAppLimited,
+	}, true
+}
+
+// OnPacketLost is called when a packet is declared lost.
+func (s *bandwidthSampler) OnPacketLost(pn protocol.PacketNumber) {
+	delete(s.packets, pn)
+}
+
+// OnAppLimited is called when the sender is application-limited.
+// All packets sent until a packet sent after this call is acknowledged are marked as application-limited.
+func (s *bandwidthSampler) OnAppLimited() {
+	s.isAppLimited = true
+	s.endOfAppLimitedPhase = s.largestSent
+}
@@ -0,0 +1,93 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bandwidth Sampler", func() {
+	const (
+		packetSize = 1000
+		rtt        = 100 * time.Millisecond
+	)
+
+	var (
+		s             *bandwidthSampler
+		start         time.Time
+		bytesInFlight protocol.ByteCount
+	)
+
+	BeforeEach(func() {
+		s = newBandwidthSampler()
+		start = time.Now()
+		bytesInFlight = 0
+	})
+
+	send := func(pn protocol.PacketNumber, t time.Time) {
+		s.OnPacketSent(t, bytesInFlight, pn, packetSize)
+		bytesInFlight += packetSize
+	}
+
+	ack := func(pn protocol.PacketNumber, t time.Time) (rateSample, bool) {
+		bytesInFlight -= packetSize
+		return s.OnPacketAcked(pn, t)
+	}
+
+	It("measures the delivery rate", func() {
+		// send a packet every millisecond, and receive the ACK one RTT later
+		var samples []rateSample
+		for i := 0; i < 300; i++ {
+			now := start.Add(time.Duration(i) * time.Millisecond)
+			if i >= 100 {
+				sample, ok := ack(protocol.PacketNumber(i-100), now)
+				Expect(ok).To(BeTrue())
+				samples = append(samples, sample)
+			}
+			send(protocol.PacketNumber(i), now)
+		}
+		last := samples[len(samples)-1]
+		Expect(last.bandwidth).To(Equal(BandwidthFromDelta(packetSize, time.Millisecond)))
+		Expect(last.rtt).To(Equal(rtt))
+		Expect(last.isAppLimited).To(BeFalse())
+		// The first samples are taken while the connection is still ramping up.
+		Expect(samples[0].bandwidth).To(BeNumerically("<", last.bandwidth))
+	})
+
+	It("doesn't take samples for unknown packets", func() {
+		_, ok := s.OnPacketAcked(42, start)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("doesn't take samples for lost packets", func() {
+		send(1, start)
+		s.OnPacketLost(1)
+		_, ok := s.OnPacketAcked(1, start.Add(rtt))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("marks samples as application-limited", func() {
+		send(1, start)
+		s.OnAppLimited()
+		send(2, start.Add(time.Millisecond))
+		send(3, start.Add(2*time.Millisecond))
+		sample, ok := ack(1, start.Add(rtt))
+		Expect(ok).To(BeTrue())
+		Expect(sample.isAppLimited).To(BeFalse())
+		sample, ok = ack(2, start.Add(rtt+time.Millisecond))
+		Expect(ok).To(BeTrue())
+		Expect(sample.isAppLimited).To(BeTrue())
+		// Packet 2 was sent after the sender became application-limited.
+		// Once it is acknowledged, the application-limited phase ends.
+		send(4, start.Add(rtt+time.Millisecond))
+		sample, ok = ack(3, start.Add(rtt+2*time.Millisecond))
+		Expect(ok).To(BeTrue())
+		Expect(sample.isAppLimited).To(BeTrue())
+		sample, ok = ack(4, start.Add(2*rtt))
+		Expect(ok).To(BeTrue())
+		Expect(sample.isAppLimited).To(BeFalse())
+	})
+})
@@ -3,6 +3,9 @@ package congestion
 import (
 	"time"
 
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -11,4 +14,17 @@ var _ = Describe("Bandwidth", func() {
 	It("converts from time delta", func() {
 		Expect(BandwidthFromDelta(1, time.Millisecond)).To(Equal(1000 * BytesPerSecond))
 	})
+
+	It("gets the bandwidth estimate of a sender", func() {
+		rttStats := utils.NewRTTStats()
+		sender := newCubicSender(DefaultClock{}, rttStats, true, protocol.InitialPacketSizeIPv4, 10*protocol.InitialPacketSizeIPv4, protocol.MaxCongestionWindowPackets*protocol.InitialPacketSizeIPv4, minCongestionWindowPackets, nil)
+		// no RTT measurement yet
+		Expect(GetBandwidthEstimate(sender)).To(BeZero())
+		rttStats.UpdateRTT(10*time.Millisecond, 0, time.Now())
+		Expect(GetBandwidthEstimate(sender)).To(Equal(BandwidthFromDelta(10*protocol.InitialPacketSizeIPv4, 10*time.Millisecond)))
+	})
+
+	It("returns 0 for senders that don't estimate the bandwidth", func() {
+		Expect(GetBandwidthEstimate(struct{ SendAlgorithm }{})).To(BeZero())
+	})
 })
@@ -0,0 +1,485 @@
+package congestion
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+const (
+	// bbrHighGain is the gain used in STARTUP, 2/ln(2).
+	// It is the smallest gain that allows the sending rate to double every round trip.
+	bbrHighGain = 2.885
+	// bbrDrainGain is the pacing gain used in DRAIN, which drains the queue created in STARTUP in a single round trip.
+	bbrDrainGain = 1 / bbrHighGain
+	// bbrCongestionWindowGain is the congestion window gain used in PROBE_BW.
+	bbrCongestionWindowGain = 2
+	// bbrBandwidthWindowRounds is the number of round trips that the maximum bandwidth filter remembers samples for.
+	bbrBandwidthWindowRounds = 10
+	// bbrStartupGrowthTarget is the growth of the bandwidth per round trip required to stay in STARTUP.
+	bbrStartupGrowthTarget = 1.25
+	// bbrStartupRoundsWithoutGrowth is the number of round trips without sufficient growth of the bandwidth
+	// after which the bandwidth is considered to be fully utilized.
+	bbrStartupRoundsWithoutGrowth = 3
+	// bbrMinRTTExpiry is the time after which the min RTT is measured again (in PROBE_RTT).
+	bbrMinRTTExpiry = 10 * time.Second
+	// bbrProbeRTTDuration is the minimum time spent in PROBE_RTT.
+	bbrProbeRTTDuration = 200 * time.Millisecond
+	// bbrMinCongestionWindowPackets is the default minimum congestion window, which is also used in PROBE_RTT.
+	bbrMinCongestionWindowPackets = 4
+	// bbrAckAggregationPackets is added to the congestion window to absorb ACK aggregation.
+	bbrAckAggregationPackets = 3
+)
+
+// bbrPacingGainCycle are the pacing gains used in PROBE_BW.
+// Each phase lasts (roughly) for one min RTT.
+var bbrPacingGainCycle = [...]float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+type bbrMode uint8
+
+const (
+	// bbrModeStartup: exponentially increase the sending rate until the bandwidth is fully utilized.
+	bbrModeStartup bbrMode = iota
+	// bbrModeDrain: drain the queue created during STARTUP.
+	bbrModeDrain
+	// bbrModeProbeBW: cruise at the estimated bandwidth, periodically probing for more.
+	bbrModeProbeBW
+	// bbrModeProbeRTT: reduce the bytes in flight to measure the min RTT.
+	bbrModeProbeRTT
+)
+
+// bbrSender implements BBR (version 1), as described in
+// https://datatracker.ietf.org/doc/html/draft-cardwell-iccrg-bbr-congestion-control-00.
+// Instead of reacting to packet loss, it builds a model of the path from the measured bandwidth and min RTT,
+// and paces packets at the estimated bandwidth.
+// The congestion window limits the bytes in flight to a multiple of the bandwidth-delay product.
+type bbrSender struct {
+	clock    Clock
+	rttStats *utils.RTTStats
+	pacer    *pacer
+	sampler  *bandwidthSampler
+	rand     utils.Rand
+
+	mode bbrMode
+
+	maxBandwidth *maxFilter
+	// lastSampleIsAppLimited is set if the last bandwidth sample was application-limited.
+	lastSampleIsAppLimited bool
+
+	// The round trip count is incremented when a packet sent after the start of the current round is acknowledged.
+	roundCount          uint64
+	currentRoundTripEnd protocol.PacketNumber
+
+	largestSentPacketNumber  protocol.PacketNumber
+	largestAckedPacketNumber protocol.PacketNumber
+
+	minRTT          time.Duration
+	minRTTTimestamp time.Time
+
+	pacingGain float64
+	cwndGain   float64
+
+	congestionWindow        protocol.ByteCount
+	initialCongestionWindow protocol.ByteCount
+	// minCongestionWindowPackets is the minimum congestion window, which is also used in PROBE_RTT
+	minCongestionWindowPackets protocol.ByteCount
+	maxDatagramSize            protocol.ByteCount
+	bytesInFlight              protocol.ByteCount
+
+	// STARTUP
+	isAtFullBandwidth            bool
+	bandwidthAtLastRound         Bandwidth
+	roundsWithoutBandwidthGrowth int
+
+	// PROBE_BW
+	cycleIndex  int
+	cycleStart  time.Time
+	lostInCycle bool
+
+	// PROBE_RTT
+	probeRTTDoneTime    time.Time // zero until the bytes in flight were reduced
+	probeRTTRoundPassed bool
+
+	// Loss recovery: During recovery, the congestion window is limited to the recovery window.
+	// It is reduced by the bytes lost, and grows by the bytes acknowledged (packet conservation).
+	endOfRecovery  protocol.PacketNumber
+	recoveryWindow protocol.ByteCount
+
+	lastState logging.CongestionState
+	tracer    logging.ConnectionTracer
+}
+
+var (
+	_ SendAlgorithm               = &bbrSender{}
+	_ SendAlgorithmWithDebugInfos = &bbrSender{}
+)
+
+// NewBBRSender makes a new BBR sender
+func NewBBRSender(
+	clock Clock,
+	rttStats *utils.RTTStats,
+	initialMaxDatagramSize protocol.ByteCount,
+	tracer logging.ConnectionTracer,
+) *bbrSender {
+	return newBBRSender(
+		clock,
+		rttStats,
+		initialMaxDatagramSize,
+		initialCongestionWindow*initialMaxDatagramSize,
+		bbrMinCongestionWindowPackets,
+		tracer,
+	)
+}
+
+func newBBRSender(
+	clock Clock,
+	rttStats *utils.RTTStats,
+	initialMaxDatagramSize,
+	initialCongestionWindow,
+	minCongestionWindowPackets protocol.ByteCount,
+	tracer logging.ConnectionTracer,
+) *bbrSender {
+	b := &bbrSender{
+		clock:                      clock,
+		rttStats:                   rttStats,
+		sampler:                    newBandwidthSampler(),
+		maxBandwidth:               newMaxFilter(bbrBandwidthWindowRounds),
+		currentRoundTripEnd:        protocol.InvalidPacketNumber,
+		largestSentPacketNumber:    protocol.InvalidPacketNumber,
+		largestAckedPacketNumber:   protocol.InvalidPacketNumber,
+		endOfRecovery:              protocol.InvalidPacketNumber,
+		congestionWindow:           initialCongestionWindow,
+		initialCongestionWindow:    initialCongestionWindow,
+		minCongestionWindowPackets: minCongestionWindowPackets,
+		maxDatagramSize:            initialMaxDatagramSize,
+		tracer:                     tracer,
+	}
+	b.enterStartup()
+	// The pacer sends 25% faster than the bandwidth it is given.
+	// BBR probes for more bandwidth using the pacing gain, so this headroom is removed.
+	b.pacer = newPacer(func() Bandwidth { return b.pacingRate() / 5 * 4 })
+	b.pacer.SetMaxDatagramSize(initialMaxDatagramSize)
+	if b.tracer != nil {
+		b.lastState = logging.CongestionStateSlowStart
+		b.tracer.UpdatedCongestionState(logging.CongestionStateSlowStart)
+	}
+	return b
+}
+
+// TimeUntilSend returns when the next packet should be sent.
+func (b *bbrSender) TimeUntilSend(_ protocol.ByteCount) time.Time {
+	return b.pacer.TimeUntilSend()
+}
+
+func (b *bbrSender) HasPacingBudget() bool {
+	return b.pacer.Budget(b.clock.Now()) >= b.maxDatagramSize
+}
+
+func (b *bbrSender) OnPacketSent(
+	sentTime time.Time,
+	bytesInFlight protocol.ByteCount,
+	packetNumber protocol.PacketNumber,
+	bytes protocol.ByteCount,
+	isRetransmittable bool,
+) {
+	b.pacer.SentPacket(sentTime, bytes)
+	if !isRetransmittable {
+		return
+	}
+	b.largestSentPacketNumber = packetNumber
+	// The bytes in flight are only passed to OnPacketSent.
+	// In between, they are tracked using the acknowledged and lost packets.
+	b.bytesInFlight = bytesInFlight
+	b.sampler.OnPacketSent(sentTime, bytesInFlight-bytes, packetNumber, bytes)
+}
+
+func (b *bbrSender) CanSend(bytesInFlight protocol.ByteCount) bool {
+	return bytesInFlight < b.GetCongestionWindow()
+}
+
+// MaybeExitSlowStart is a no-op, since BBR determines by itself when to leave STARTUP.
+func (b *bbrSender) MaybeExitSlowStart() {}
+
+func (b *bbrSender) OnPacketAcked(
+	ackedPacketNumber protocol.PacketNumber,
+	ackedBytes protocol.ByteCount,
+	priorInFlight protocol.ByteCount,
+	eventTime time.Time,
+) {
+	b.largestAckedPacketNumber = utils.MaxPacketNumber(ackedPacketNumber, b.largestAckedPacketNumber)
+	b.removeFromBytesInFlight(ackedBytes)
+
+	isRoundStart := b.currentRoundTripEnd == protocol.InvalidPacketNumber || ackedPacketNumber > b.currentRoundTripEnd
+	if isRoundStart {
+		b.roundCount++
+		b.currentRoundTripEnd = b.largestSentPacketNumber
+	}
+
+	// If less than the bandwidth-delay product is in flight, the sender is not using the available bandwidth.
+	if bdp := b.targetCongestionWindow(1); b.maxBandwidth.Get() > 0 && priorInFlight < bdp {
+		b.sampler.OnAppLimited()
+	}
+
+	minRTTExpired := !b.minRTTTimestamp.IsZero() && eventTime.Sub(b.minRTTTimestamp) > bbrMinRTTExpiry
+	if sample, ok := b.sampler.OnPacketAcked(ackedPacketNumber, eventTime); ok {
+		b.lastSampleIsAppLimited = sample.isAppLimited
+		// Application-limited samples underestimate the bandwidth, unless they are larger than the current estimate.
+		if !sample.isAppLimited || sample.bandwidth >= b.maxBandwidth.Get() {
+			b.maxBandwidth.Update(sample.bandwidth, b.roundCount)
+		}
+		if sample.rtt > 0 && (b.minRTT == 0 || sample.rtt <= b.minRTT || minRTTExpired) {
+			b.minRTT = sample.rtt
+			b.minRTTTimestamp = eventTime
+		}
+	}
+
+	if b.mode == bbrModeProbeBW {
+		b.updateGainCyclePhase(eventTime, priorInFlight)
+	}
+	if isRoundStart && !b.isAtFullBandwidth {
+		b.checkIfFullBandwidthReached()
+	}
+	b.maybeExitStartupOrDrain(eventTime)
+	b.maybeEnterOrExitProbeRTT(eventTime, isRoundStart, minRTTExpired)
+
+	if b.InRecovery() {
+		b.recoveryWindow += ackedBytes
+		if b.mode == bbrModeStartup {
+			// keep growing the window in STARTUP
+			b.recoveryWindow += ackedBytes
+		}
+	}
+	b.updateCongestionWindow(ackedBytes)
+	b.maybeTraceStateChange()
+}
+
+func (b *bbrSender) OnPacketLost(packetNumber protocol.PacketNumber, lostBytes, _ protocol.ByteCount) {
+	b.removeFromBytesInFlight(lostBytes)
+	b.sampler.OnPacketLost(packetNumber)
+	b.lostInCycle = true
+
+	if packetNumber <= b.endOfRecovery {
+		// This loss belongs to the current loss event.
+		b.recoveryWindow = utils.MaxByteCount(b.recoveryWindow-utils.MinByteCount(b.recoveryWindow, lostBytes), b.minCongestionWindow())
+		return
+	}
+	b.endOfRecovery = b.largestSentPacketNumber
+	b.recoveryWindow = utils.MaxByteCount(b.bytesInFlight, b.minCongestionWindow())
+	b.maybeTraceStateChange()
+}
+
+func (b *bbrSender) removeFromBytesInFlight(bytes protocol.ByteCount) {
+	if bytes > b.bytesInFlight {
+		b.bytesInFlight = 0
+		return
+	}
+	b.bytesInFlight -= bytes
+}
+
+func (b *bbrSender) enterStartup() {
+	b.mode = bbrModeStartup
+	b.pacingGain = bbrHighGain
+	b.cwndGain = bbrHighGain
+}
+
+func (b *bbrSender) enterProbeBW(now time.Time) {
+	b.mode = bbrModeProbeBW
+	b.cwndGain = bbrCongestionWindowGain
+	// Start in a random phase, but not in the phase that drains the queue (0.75),
+	// since there's no queue to drain yet.
+	b.cycleIndex = int(b.rand.Int31n(int32(len(bbrPacingGainCycle) - 1)))
+	if b.cycleIndex >= 1 {
+		b.cycleIndex++
+	}
+	b.cycleStart = now
+	b.lostInCycle = false
+	b.pacingGain = bbrPacingGainCycle[b.cycleIndex]
+}
+
+func (b *bbrSender) updateGainCyclePhase(now time.Time, priorInFlight protocol.ByteCount) {
+	shouldAdvance := now.Sub(b.cycleStart) > b.minRTT
+	// When probing for more bandwidth, stay in the phase until more data is in flight,
+	// unless packets are lost.
+	if b.pacingGain > 1 && !b.lostInCycle && priorInFlight < b.targetCongestionWindow(b.pacingGain) {
+		shouldAdvance = false
+	}
+	// When draining the queue, leave the phase as soon as the queue is drained.
+	if b.pacingGain < 1 && b.bytesInFlight <= b.targetCongestionWindow(1) {
+		shouldAdvance = true
+	}
+	if !shouldAdvance {
+		return
+	}
+	b.cycleIndex = (b.cycleIndex + 1) % len(bbrPacingGainCycle)
+	b.cycleStart = now
+	b.lostInCycle = false
+	b.pacingGain = bbrPacingGainCycle[b.cycleIndex]
+}
+
+func (b *bbrSender) checkIfFullBandwidthReached() {
+	if b.lastSampleIsAppLimited {
+		return
+	}
+	if bw := b.maxBandwidth.Get(); bw >= Bandwidth(float64(b.bandwidthAtLastRound)*bbrStartupGrowthTarget) {
+		b.bandwidthAtLastRound = bw
+		b.roundsWithoutBandwidthGrowth = 0
+		return
+	}
+	b.roundsWithoutBandwidthGrowth++
+	if b.roundsWithoutBandwidthGrowth >= bbrStartupRoundsWithoutGrowth {
+		b.isAtFullBandwidth = true
+	}
+}
+
+func (b *bbrSender) maybeExitStartupOrDrain(now time.Time) {
+	if b.mode == bbrModeStartup && b.isAtFullBandwidth {
+		b.mode = bbrModeDrain
+		b.pacingGain = bbrDrainGain
+		b.cwndGain = bbrHighGain
+	}
+	if b.mode == bbrModeDrain && b.bytesInFlight <= b.targetCongestionWindow(1) {
+		b.enterProbeBW(now)
+	}
+}
+
+func (b *bbrSender) maybeEnterOrExitProbeRTT(now time.Time, isRoundStart, minRTTExpired bool) {
+	if minRTTExpired && b.mode != bbrModeProbeRTT {
+		b.mode = bbrModeProbeRTT
+		b.pacingGain = 1
+		b.probeRTTDoneTime = time.Time{}
+	}
+	if b.mode != bbrModeProbeRTT {
+		return
+	}
+	// Samples taken while the bytes in flight are reduced underestimate the bandwidth.
+	b.sampler.OnAppLimited()
+	if b.probeRTTDoneTime.IsZero() {
+		if b.bytesInFlight <= b.minCongestionWindow() {
+			b.probeRTTDoneTime = now.Add(bbrProbeRTTDuration)
+			b.probeRTTRoundPassed = false
+		}
+		return
+	}
+	if isRoundStart {
+		b.probeRTTRoundPassed = true
+	}
+	if now.Before(b.probeRTTDoneTime) || !b.probeRTTRoundPassed {
+		return
+	}
+	b.minRTTTimestamp = now
+	if b.isAtFullBandwidth {
+		b.enterProbeBW(now)
+	} else {
+		b.enterStartup()
+	}
+}
+
+// targetCongestionWindow returns the bandwidth-delay product, multiplied by gain.
+func (b *bbrSender) targetCongestionWindow(gain float64) protocol.ByteCount {
+	bw := b.maxBandwidth.Get()
+	if bw == 0 || b.minRTT == 0 {
+		return protocol.ByteCount(gain * float64(b.initialCongestionWindow))
+	}
+	bdp := float64(bw/BytesPerSecond) * b.minRTT.Seconds()
+	return utils.MaxByteCount(protocol.ByteCount(gain*bdp), b.minCongestionWindow())
+}
+
+func (b *bbrSender) updateCongestionWindow(ackedBytes protocol.ByteCount) {
+	if b.mode == bbrModeProbeRTT {
+		return
+	}
+	target := b.targetCongestionWindow(b.cwndGain) + bbrAckAggregationPackets*b.maxDatagramSize
+	if b.isAtFullBandwidth {
+		b.congestionWindow = utils.MinByteCount(b.congestionWindow+ackedBytes, target)
+	} else if b.congestionWindow < target || b.sampler.delivered < b.initialCongestionWindow {
+		// In STARTUP, grow the window as fast as data is acknowledged.
+		b.congestionWindow += ackedBytes
+	}
+	b.congestionWindow = utils.MaxByteCount(b.congestionWindow, b.minCongestionWindow())
+	b.congestionWindow = utils.MinByteCount(b.congestionWindow, b.maxCongestionWindow())
+}
+
+// pacingRate returns the rate at which packets are sent.
+func (b *bbrSender) pacingRate() Bandwidth {
+	bw := b.maxBandwidth.Get()
+	if bw == 0 {
+		// No bandwidth was measured yet. Use the initial window and the RTT measured so far.
+		rtt := b.rttStats.MinRTT()
+		if rtt == 0 {
+			return infBandwidth
+		}
+		return Bandwidth(bbrHighGain * float64(BandwidthFromDelta(b.initialCongestionWindow, rtt)))
+	}
+	return Bandwidth(b.pacingGain * float64(bw))
+}
+
+// BandwidthEstimate returns the current bandwidth estimate
+func (b *bbrSender) BandwidthEstimate() Bandwidth {
+	return b.maxBandwidth.Get()
+}
+
+func (b *bbrSender) InRecovery() bool {
+	return b.largestAckedPacketNumber != protocol.InvalidPacketNumber && b.largestAckedPacketNumber <= b.endOfRecovery
+}
+
+func (b *bbrSender) InSlowStart() bool {
+	return b.mode == bbrModeStartup
+}
+
+func (b *bbrSender) GetCongestionWindow() protocol.ByteCount {
+	if b.mode == bbrModeProbeRTT {
+		return b.minCongestionWindow()
+	}
+	if b.InRecovery() {
+		return utils.MinByteCount(b.congestionWindow, b.recoveryWindow)
+	}
+	return b.congestionWindow
+}
+
+func (b *bbrSender) minCongestionWindow() protocol.ByteCount {
+	return b.maxDatagramSize * b.minCongestionWindowPackets
+}
+
+func (b *bbrSender) maxCongestionWindow() protocol.ByteCount {
+	return b.maxDatagramSize * protocol.MaxCongestionWindowPackets
+}
+
+// OnRetransmissionTimeout is called on an retransmission timeout
+func (b *bbrSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
+	b.endOfRecovery = protocol.InvalidPacketNumber
+	if !packetsRetransmitted {
+		return
+	}
+	b.congestionWindow = b.minCongestionWindow()
+}
+
+func (b *bbrSender) maybeTraceStateChange() {
+	if b.tracer == nil {
+		return
+	}
+	var state logging.CongestionState
+	switch {
+	case b.InRecovery():
+		state = logging.CongestionStateRecovery
+	case b.mode == bbrModeStartup:
+		state = logging.CongestionStateSlowStart
+	default:
+		state = logging.CongestionStateCongestionAvoidance
+	}
+	if state == b.lastState {
+		return
+	}
+	b.tracer.UpdatedCongestionState(state)
+	b.lastState = state
+}
+
+func (b *bbrSender) SetMaxDatagramSize(s protocol.ByteCount) {
+	if s < b.maxDatagramSize {
+		panic(fmt.Sprintf("congestion BUG: decreased max datagram size from %d to %d", b.maxDatagramSize, s))
+	}
+	b.maxDatagramSize = s
+	b.pacer.SetMaxDatagramSize(s)
+}
@@ -0,0 +1,247 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/mocks/logging"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type simulatedPacket struct {
+	packetNumber protocol.PacketNumber
+	sentTime     time.Time
+	ackTime      time.Time
+}
+
+var _ = Describe("BBR Sender", func() {
+	const (
+		// a 10 Mbit/s path with an RTT of 50ms
+		bottleneckBandwidth = 10 * 1000 * 1000 * BitsPerSecond
+		rtt                 = 50 * time.Millisecond
+		bdp                 = protocol.ByteCount(bottleneckBandwidth / BytesPerSecond * Bandwidth(rtt) / Bandwidth(time.Second))
+	)
+
+	var (
+		sender        *bbrSender
+		clock         mockClock
+		rttStats      *utils.RTTStats
+		bytesInFlight protocol.ByteCount
+		packetNumber  protocol.PacketNumber
+		inFlight      []simulatedPacket
+		// the time when the bottleneck link is done sending the packets queued
+		bottleneckFree time.Time
+	)
+
+	BeforeEach(func() {
+		clock = mockClock(time.Now())
+		rttStats = utils.NewRTTStats()
+		sender = newBBRSender(&clock, rttStats, maxDatagramSize, initialCongestionWindow*maxDatagramSize, bbrMinCongestionWindowPackets, nil)
+		bytesInFlight = 0
+		packetNumber = 0
+		inFlight = nil
+		bottleneckFree = time.Time{}
+	})
+
+	// send sends as many packets as allowed by the congestion window and the pacer.
+	// The packets are queued at the bottleneck link.
+	send := func() {
+		now := clock.Now()
+		for sender.CanSend(bytesInFlight) && sender.HasPacingBudget() {
+			packetNumber++
+			bytesInFlight += maxDatagramSize
+			sender.OnPacketSent(now, bytesInFlight, packetNumber, maxDatagramSize, true)
+			if bottleneckFree.Before(now) {
+				bottleneckFree = now
+			}
+			bottleneckFree = bottleneckFree.Add(time.Duration(Bandwidth(maxDatagramSize) * BytesPerSecond * Bandwidth(time.Second) / bottleneckBandwidth))
+			inFlight = append(inFlight, simulatedPacket{
+				packetNumber: packetNumber,
+				sentTime:     now,
+				ackTime:      bottleneckFree.Add(rtt),
+			})
+		}
+	}
+
+	// ack acknowledges all packets that arrived at the receiver one RTT ago.
+	ack := func() {
+		now := clock.Now()
+		var n int
+		for n < len(inFlight) && !inFlight[n].ackTime.After(now) {
+			n++
+		}
+		if n == 0 {
+			return
+		}
+		acked := inFlight[:n]
+		inFlight = inFlight[n:]
+		rttStats.UpdateRTT(now.Sub(acked[n-1].sentTime), 0, now)
+		priorInFlight := bytesInFlight
+		for _, p := range acked {
+			sender.OnPacketAcked(p.packetNumber, maxDatagramSize, priorInFlight, now)
+			bytesInFlight -= maxDatagramSize
+		}
+	}
+
+	// run simulates the connection for the duration d, and calls f every millisecond
+	run := func(d time.Duration, f func()) {
+		end := clock.Now().Add(d)
+		for clock.Now().Before(end) {
+			ack()
+			send()
+			if f != nil {
+				f()
+			}
+			clock.Advance(time.Millisecond)
+		}
+	}
+
+	It("has the right values at startup", func() {
+		Expect(sender.GetCongestionWindow()).To(Equal(initialCongestionWindow * maxDatagramSize))
+		Expect(sender.InSlowStart()).To(BeTrue())
+		Expect(sender.InRecovery()).To(BeFalse())
+		Expect(sender.CanSend(0)).To(BeTrue())
+		Expect(sender.TimeUntilSend(0)).To(BeZero())
+		Expect(sender.BandwidthEstimate()).To(BeZero())
+	})
+
+	It("estimates the bandwidth and the min RTT", func() {
+		var leftStartup time.Duration
+		start := clock.Now()
+		run(3*time.Second, func() {
+			if leftStartup == 0 && !sender.InSlowStart() {
+				leftStartup = clock.Now().Sub(start)
+			}
+		})
+		Expect(leftStartup).ToNot(BeZero())
+		Expect(leftStartup).To(BeNumerically("<", time.Second))
+		Expect(sender.mode).To(Equal(bbrModeProbeBW))
+		Expect(sender.BandwidthEstimate()).To(BeNumerically("~", bottleneckBandwidth, bottleneckBandwidth/10))
+		Expect(sender.minRTT).To(BeNumerically("~", rtt, 5*time.Millisecond))
+		// The congestion window is twice the bandwidth-delay product.
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("~", 2*bdp, bdp/2))
+		// BBR doesn't keep a standing queue at the bottleneck.
+		Expect(bottleneckFree.Sub(clock.Now())).To(BeNumerically("<", rtt/2))
+	})
+
+	It("enters PROBE_RTT when the min RTT wasn't measured for 10 seconds", func() {
+		run(2*time.Second, nil)
+		Expect(sender.mode).To(Equal(bbrModeProbeBW))
+		var probedRTT bool
+		run(bbrMinRTTExpiry+time.Second, func() {
+			if sender.mode == bbrModeProbeRTT {
+				probedRTT = true
+				Expect(sender.GetCongestionWindow()).To(Equal(bbrMinCongestionWindowPackets * maxDatagramSize))
+			}
+		})
+		Expect(probedRTT).To(BeTrue())
+		Expect(sender.mode).To(Equal(bbrModeProbeBW))
+	})
+
+	It("limits the congestion window during recovery", func() {
+		run(2*time.Second, nil)
+		cwnd := sender.GetCongestionWindow()
+		// lose the next 10 packets
+		lost := inFlight[:10]
+		inFlight = inFlight[10:]
+		priorInFlight := bytesInFlight
+		for _, p := range lost {
+			sender.OnPacketLost(p.packetNumber, maxDatagramSize, priorInFlight)
+			bytesInFlight -= maxDatagramSize
+		}
+		ack()
+		Expect(sender.InRecovery()).To(BeTrue())
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("<", cwnd))
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("<=", bytesInFlight+10*maxDatagramSize))
+		// Recovery ends when a packet sent after the loss is acknowledged.
+		run(2*rtt, nil)
+		Expect(sender.InRecovery()).To(BeFalse())
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+	})
+
+	It("resets the congestion window on retransmission timeouts", func() {
+		run(time.Second, nil)
+		sender.OnRetransmissionTimeout(false)
+		Expect(sender.GetCongestionWindow()).To(BeNumerically(">", bbrMinCongestionWindowPackets*maxDatagramSize))
+		sender.OnRetransmissionTimeout(true)
+		Expect(sender.GetCongestionWindow()).To(Equal(bbrMinCongestionWindowPackets * maxDatagramSize))
+	})
+
+	It("traces state changes", func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		defer mockCtrl.Finish()
+		tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+		tracer.EXPECT().UpdatedCongestionState(logging.CongestionStateSlowStart)
+		sender = newBBRSender(&clock, rttStats, maxDatagramSize, initialCongestionWindow*maxDatagramSize, bbrMinCongestionWindowPackets, tracer)
+		tracer.EXPECT().UpdatedCongestionState(logging.CongestionStateCongestionAvoidance)
+		run(2*time.Second, nil)
+	})
+
+	It("doesn't allow reductions of the maximum datagram size", func() {
+		sender.SetMaxDatagramSize(maxDatagramSize + 1)
+		Expect(sender.minCongestionWindow()).To(Equal(bbrMinCongestionWindowPackets * (maxDatagramSize + 1)))
+		Expect(func() { sender.SetMaxDatagramSize(maxDatagramSize) }).To(Panic())
+	})
+})
+
+var _ = Describe("Congestion control algorithms", func() {
+	It("creates senders", func() {
+		rttStats := utils.NewRTTStats()
+		reno := NewSendAlgorithm(Config{Algorithm: AlgorithmNewReno}, DefaultClock{}, rttStats, maxDatagramSize, nil)
+		Expect(reno).To(BeAssignableToTypeOf(&cubicSender{}))
+		Expect(reno.(*cubicSender).reno).To(BeTrue())
+		cubic := NewSendAlgorithm(Config{Algorithm: AlgorithmCubic}, DefaultClock{}, rttStats, maxDatagramSize, nil)
+		Expect(cubic).To(BeAssignableToTypeOf(&cubicSender{}))
+		Expect(cubic.(*cubicSender).reno).To(BeFalse())
+		Expect(NewSendAlgorithm(Config{Algorithm: AlgorithmBBR}, DefaultClock{}, rttStats, maxDatagramSize, nil)).To(BeAssignableToTypeOf(&bbrSender{}))
+	})
+
+	It("uses the default congestion windows", func() {
+		rttStats := utils.NewRTTStats()
+		reno := NewSendAlgorithm(Config{}, DefaultClock{}, rttStats, maxDatagramSize, nil).(*cubicSender)
+		Expect(reno.GetCongestionWindow()).To(Equal(initialCongestionWindow * maxDatagramSize))
+		Expect(reno.minCongestionWindow()).To(Equal(minCongestionWindowPackets * maxDatagramSize))
+		bbr := NewSendAlgorithm(Config{Algorithm: AlgorithmBBR}, DefaultClock{}, rttStats, maxDatagramSize, nil).(*bbrSender)
+		Expect(bbr.GetCongestionWindow()).To(Equal(initialCongestionWindow * maxDatagramSize))
+		Expect(bbr.minCongestionWindow()).To(Equal(bbrMinCongestionWindowPackets * maxDatagramSize))
+	})
+
+	It("configures the congestion windows", func() {
+		rttStats := utils.NewRTTStats()
+		conf := Config{InitialWindowPackets: 10, MinWindowPackets: 5}
+		reno := NewSendAlgorithm(conf, DefaultClock{}, rttStats, maxDatagramSize, nil).(*cubicSender)
+		Expect(reno.GetCongestionWindow()).To(Equal(10 * maxDatagramSize))
+		Expect(reno.minCongestionWindow()).To(Equal(5 * maxDatagramSize))
+		conf.Algorithm = AlgorithmBBR
+		bbr := NewSendAlgorithm(conf, DefaultClock{}, rttStats, maxDatagramSize, nil).(*bbrSender)
+		Expect(bbr.GetCongestionWindow()).To(Equal(10 * maxDatagramSize))
+		Expect(bbr.minCongestionWindow()).To(Equal(5 * maxDatagramSize))
+	})
+
+	It("doesn't use an initial congestion window smaller than the minimum", func() {
+		conf := Config{InitialWindowPackets: 3, MinWindowPackets: 6}
+		reno := NewSendAlgorithm(conf, DefaultClock{}, utils.NewRTTStats(), maxDatagramSize, nil)
+		Expect(reno.GetCongestionWindow()).To(Equal(6 * maxDatagramSize))
+	})
+
+	It("creates custom senders", func() {
+		rttStats := utils.NewRTTStats()
+		sender := NewCubicSender(DefaultClock{}, rttStats, maxDatagramSize, true, nil)
+		var calledWith protocol.ByteCount
+		conf := Config{
+			Algorithm: AlgorithmCustom,
+			NewCustom: func(r *utils.RTTStats, initialMaxDatagramSize protocol.ByteCount) SendAlgorithmWithDebugInfos {
+				Expect(r).To(Equal(rttStats))
+				calledWith = initialMaxDatagramSize
+				return sender
+			},
+		}
+		Expect(NewSendAlgorithm(conf, DefaultClock{}, rttStats, 1234, nil)).To(Equal(sender))
+		Expect(calledWith).To(Equal(protocol.ByteCount(1234)))
+	})
+})
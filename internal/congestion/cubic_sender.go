@@ -52,6 +52,7 @@ type cubicSender struct {
 
 	initialCongestionWindow    protocol.ByteCount
 	initialMaxCongestionWindow protocol.ByteCount
+	minCongestionWindowPackets protocol.ByteCount
 
 	maxDatagramSize protocol.ByteCount
 
@@ -79,6 +80,7 @@ func NewCubicSender(
 		initialMaxDatagramSize,
 		initialCongestionWindow*initialMaxDatagramSize,
 		protocol.MaxCongestionWindowPackets*initialMaxDatagramSize,
+		minCongestionWindowPackets,
 		tracer,
 	)
 }
@@ -90,6 +92,7 @@ func newCubicSender(
 	initialMaxDatagramSize,
 	initialCongestionWindow,
 	initialMaxCongestionWindow protocol.ByteCount,
+	minCongestionWindowPackets protocol.ByteCount,
 	tracer logging.ConnectionTracer,
 ) *cubicSender {
 	c := &cubicSender{
@@ -99,6 +102,7 @@ func newCubicSender(
 		largestSentAtLastCutback:   protocol.InvalidPacketNumber,
 		initialCongestionWindow:    initialCongestionWindow,
 		initialMaxCongestionWindow: initialMaxCongestionWindow,
+		minCongestionWindowPackets: minCongestionWindowPackets,
 		congestionWindow:           initialCongestionWindow,
 		slowStartThreshold:         protocol.MaxByteCount,
 		cubic:                      NewCubic(clock),
@@ -129,7 +133,7 @@ func (c *cubicSender) maxCongestionWindow() protocol.ByteCount {
 }
 
 func (c *cubicSender) minCongestionWindow() protocol.ByteCount {
-	return c.maxDatagramSize * minCongestionWindowPackets
+	return c.maxDatagramSize * c.minCongestionWindowPackets
 }
 
 func (c *cubicSender) OnPacketSent(
@@ -4,18 +4,32 @@ import (
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 // A SendAlgorithm performs congestion control
 type SendAlgorithm interface {
+	// TimeUntilSend returns when the next packet may be sent (for pacing).
+	// The zero value means that it may be sent immediately.
 	TimeUntilSend(bytesInFlight protocol.ByteCount) time.Time
+	// HasPacingBudget says if a full-size packet may be sent now.
 	HasPacingBudget() bool
+	// OnPacketSent is called for every packet sent.
+	// bytesInFlight includes the packet, if it is retransmittable.
 	OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool)
+	// CanSend says if the congestion window allows sending a packet.
 	CanSend(bytesInFlight protocol.ByteCount) bool
+	// MaybeExitSlowStart is called when the RTT was updated, before OnPacketAcked is called for the packets acknowledged.
 	MaybeExitSlowStart()
+	// OnPacketAcked is called for every retransmittable packet that was acknowledged.
+	// priorInFlight is the number of bytes in flight before the ACK frame was received.
 	OnPacketAcked(number protocol.PacketNumber, ackedBytes protocol.ByteCount, priorInFlight protocol.ByteCount, eventTime time.Time)
+	// OnPacketLost is called for every retransmittable packet that was declared lost.
 	OnPacketLost(number protocol.PacketNumber, lostBytes protocol.ByteCount, priorInFlight protocol.ByteCount)
+	// OnRetransmissionTimeout is called when the probe timeout fires.
 	OnRetransmissionTimeout(packetsRetransmitted bool)
+	// SetMaxDatagramSize is called when the maximum datagram size increases (due to Path MTU Discovery).
 	SetMaxDatagramSize(protocol.ByteCount)
 }
 
@@ -26,3 +40,89 @@ type SendAlgorithmWithDebugInfos interface {
 	InRecovery() bool
 	GetCongestionWindow() protocol.ByteCount
 }
+
+// A BandwidthEstimator is a SendAlgorithm that estimates the bandwidth of the path.
+type BandwidthEstimator interface {
+	// BandwidthEstimate returns the current bandwidth estimate.
+	BandwidthEstimate() Bandwidth
+}
+
+// An Algorithm is a congestion control algorithm.
+type Algorithm uint8
+
+const (
+	// AlgorithmNewReno is NewReno, as described in RFC 9002, Section 7.
+	AlgorithmNewReno Algorithm = iota
+	// AlgorithmBBR is BBR.
+	AlgorithmBBR
+	// AlgorithmCubic is CUBIC, as described in RFC 8312.
+	AlgorithmCubic
+	// AlgorithmCustom is a congestion control algorithm implemented by the application.
+	AlgorithmCustom
+)
+
+// Config configures the congestion controller.
+type Config struct {
+	Algorithm Algorithm
+	// InitialWindowPackets is the initial congestion window, in packets.
+	// If zero, a default of 32 packets is used.
+	InitialWindowPackets protocol.ByteCount
+	// MinWindowPackets is the minimum congestion window, in packets.
+	// If zero, the default of the algorithm is used (2 packets for NewReno and CUBIC, 4 packets for BBR).
+	MinWindowPackets protocol.ByteCount
+	// NewCustom creates the sender for AlgorithmCustom.
+	NewCustom func(rttStats *utils.RTTStats, initialMaxDatagramSize protocol.ByteCount) SendAlgorithmWithDebugInfos
+}
+
+func (c *Config) minWindowPackets(defaultValue protocol.ByteCount) protocol.ByteCount {
+	if c.MinWindowPackets > 0 {
+		return c.MinWindowPackets
+	}
+	return defaultValue
+}
+
+// initialWindowPackets returns the initial congestion window.
+// It is never smaller than the minimum congestion window.
+func (c *Config) initialWindowPackets(minWindowPackets protocol.ByteCount) protocol.ByteCount {
+	initialWindow := protocol.ByteCount(initialCongestionWindow)
+	if c.InitialWindowPackets > 0 {
+		initialWindow = c.InitialWindowPackets
+	}
+	return utils.MaxByteCount(initialWindow, minWindowPackets)
+}
+
+// NewSendAlgorithm makes a new sender for the congestion control algorithm.
+func NewSendAlgorithm(
+	conf Config,
+	clock Clock,
+	rttStats *utils.RTTStats,
+	initialMaxDatagramSize protocol.ByteCount,
+	tracer logging.ConnectionTracer,
+) SendAlgorithmWithDebugInfos {
+	switch conf.Algorithm {
+	case AlgorithmCustom:
+		return conf.NewCustom(rttStats, initialMaxDatagramSize)
+	case AlgorithmBBR:
+		minWindow := conf.minWindowPackets(bbrMinCongestionWindowPackets)
+		return newBBRSender(
+			clock,
+			rttStats,
+			initialMaxDatagramSize,
+			conf.initialWindowPackets(minWindow)*initialMaxDatagramSize,
+			minWindow,
+			tracer,
+		)
+	default:
+		minWindow := conf.minWindowPackets(minCongestionWindowPackets)
+		return newCubicSender(
+			clock,
+			rttStats,
+			conf.Algorithm != AlgorithmCubic,
+			initialMaxDatagramSize,
+			conf.initialWindowPackets(minWindow)*initialMaxDatagramSize,
+			protocol.MaxCongestionWindowPackets*initialMaxDatagramSize,
+			minWindow,
+			tracer,
+		)
+	}
+}
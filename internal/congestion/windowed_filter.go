@@ -0,0 +1,67 @@
+package congestion
+
+type windowedSample struct {
+	value Bandwidth
+	time  uint64
+}
+
+// maxFilter tracks the maximum of the samples taken within a window.
+// Time is measured in arbitrary units, BBR uses the round trip count.
+// It uses Kathleen Nichols' algorithm (as implemented in the Linux kernel's lib/win_minmax.c):
+// Instead of keeping all samples, it only keeps the best, second best and third best sample,
+// such that the maximum can be replaced by a recent sample when it expires.
+type maxFilter struct {
+	window  uint64
+	samples [3]windowedSample
+}
+
+func newMaxFilter(window uint64) *maxFilter {
+	return &maxFilter{window: window}
+}
+
+// Get returns the maximum of the samples within the window.
+func (f *maxFilter) Get() Bandwidth {
+	return f.samples[0].value
+}
+
+// Reset resets the filter to a single sample.
+func (f *maxFilter) Reset(value Bandwidth, time uint64) {
+	s := windowedSample{value: value, time: time}
+	f.samples = [3]windowedSample{s, s, s}
+}
+
+// Update adds a new sample taken at time. Time must not decrease.
+func (f *maxFilter) Update(value Bandwidth, time uint64) {
+	s := windowedSample{value: value, time: time}
+	// Reset if this sample is the new maximum, or if nothing was sampled within the window.
+	if f.samples[0].value == 0 || value >= f.samples[0].value || time-f.samples[2].time > f.window {
+		f.Reset(value, time)
+		return
+	}
+	if value >= f.samples[1].value {
+		f.samples[1] = s
+		f.samples[2] = s
+	} else if value >= f.samples[2].value {
+		f.samples[2] = s
+	}
+
+	delta := time - f.samples[0].time
+	switch {
+	case delta > f.window:
+		// The maximum expired. Promote the second and third best sample.
+		f.samples[0] = f.samples[1]
+		f.samples[1] = f.samples[2]
+		f.samples[2] = s
+		if time-f.samples[0].time > f.window {
+			f.samples[0] = f.samples[1]
+			f.samples[1] = f.samples[2]
+		}
+	case f.samples[1].time == f.samples[0].time && delta > f.window/4:
+		// A quarter of the window passed without a second best sample. Take one from the second quarter.
+		f.samples[1] = s
+		f.samples[2] = s
+	case f.samples[2].time == f.samples[1].time && delta > f.window/2:
+		// Half the window passed without a third best sample. Take one from the second half.
+		f.samples[2] = s
+	}
+}
@@ -0,0 +1,51 @@
+package congestion
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Windowed max filter", func() {
+	var f *maxFilter
+
+	BeforeEach(func() {
+		f = newMaxFilter(10)
+	})
+
+	It("is zero before the first sample", func() {
+		Expect(f.Get()).To(BeZero())
+	})
+
+	It("returns the maximum", func() {
+		f.Update(100, 1)
+		f.Update(300, 2)
+		f.Update(200, 3)
+		Expect(f.Get()).To(BeEquivalentTo(300))
+	})
+
+	It("replaces the maximum when it expires", func() {
+		f.Update(300, 1)
+		f.Update(200, 4)
+		f.Update(100, 7)
+		Expect(f.Get()).To(BeEquivalentTo(300))
+		f.Update(50, 12)
+		Expect(f.Get()).To(BeEquivalentTo(200))
+		f.Update(50, 15)
+		Expect(f.Get()).To(BeEquivalentTo(100))
+		f.Update(50, 18)
+		Expect(f.Get()).To(BeEquivalentTo(50))
+	})
+
+	It("resets when nothing was sampled for longer than the window", func() {
+		f.Update(300, 1)
+		f.Update(200, 2)
+		f.Update(100, 20)
+		Expect(f.Get()).To(BeEquivalentTo(100))
+	})
+
+	It("resets", func() {
+		f.Update(300, 1)
+		f.Reset(100, 2)
+		Expect(f.Get()).To(BeEquivalentTo(100))
+	})
+})
@@ -5,16 +5,29 @@ import (
 	"encoding/binary"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
-	"github.com/lucas-clemente/quic-go/internal/qtls"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 )
 
-func createAEAD(suite *qtls.CipherSuiteTLS13, trafficSecret []byte) cipher.AEAD {
+func createAEAD(suite *CipherSuite, trafficSecret []byte) cipher.AEAD {
 	key := hkdfExpandLabel(suite.Hash, trafficSecret, []byte{}, "quic key", suite.KeyLen)
 	iv := hkdfExpandLabel(suite.Hash, trafficSecret, []byte{}, "quic iv", suite.IVLen())
 	return suite.AEAD(key, iv)
 }
 
+// createPathAEAD creates the AEAD used on an additional path, when using multipath.
+// The path ID is XORed into the first 32 bits of the IV,
+// such that the nonce is the IV XORed with the concatenation of path ID and packet number.
+func createPathAEAD(suite *CipherSuite, trafficSecret []byte, pathID uint64) cipher.AEAD {
+	key := hkdfExpandLabel(suite.Hash, trafficSecret, []byte{}, "quic key", suite.KeyLen)
+	iv := hkdfExpandLabel(suite.Hash, trafficSecret, []byte{}, "quic iv", suite.IVLen())
+	var id [4]byte
+	binary.BigEndian.PutUint32(id[:], uint32(pathID))
+	for i := range id {
+		iv[len(iv)-12+i] ^= id[i]
+	}
+	return suite.AEAD(key, iv)
+}
+
 type longHeaderSealer struct {
 	aead            cipher.AEAD
 	headerProtector headerProtector
@@ -0,0 +1,34 @@
+package handshake
+
+import (
+	"crypto/rand"
+
+	"github.com/lucas-clemente/quic-go/internal/qtls"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AES-GCM", func() {
+	It("is compatible with the qtls implementation", func() {
+		for _, keyLen := range []int{16, 32} {
+			key := make([]byte, keyLen)
+			rand.Read(key)
+			nonceMask := make([]byte, 12)
+			rand.Read(nonceMask)
+			aead := aeadAESGCMTLS13(key, nonceMask)
+			qaead := qtls.AEADAESGCMTLS13(key, nonceMask)
+			Expect(aead.NonceSize()).To(Equal(qaead.NonceSize()))
+			Expect(aead.Overhead()).To(Equal(qaead.Overhead()))
+
+			nonce := []byte{0, 0, 0, 0, 0, 0, 0x13, 0x37}
+			sealed := aead.Seal(nil, nonce, []byte("foobar"), []byte("ad"))
+			Expect(sealed).To(Equal(qaead.Seal(nil, nonce, []byte("foobar"), []byte("ad"))))
+			opened, err := qaead.Open(nil, nonce, sealed, []byte("ad"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opened).To(Equal([]byte("foobar")))
+			_, err = aead.Open(nil, []byte{0, 0, 0, 0, 0, 0, 0x13, 0x38}, sealed, []byte("ad"))
+			Expect(err).To(HaveOccurred())
+		}
+	})
+})
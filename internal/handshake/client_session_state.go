@@ -0,0 +1,215 @@
+package handshake
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/qtls"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+const clientSessionStateEncodingRevision = 1
+
+// MarshalClientSessionState encodes a tls.ClientSessionState.
+// Since the transport parameters and the RTT are stored in the session state (see marshalDataForSessionState),
+// they are encoded as well.
+func MarshalClientSessionState(s *tls.ClientSessionState) ([]byte, error) {
+	f := qtls.GetClientSessionStateFields(s)
+	if f.Version != tls.VersionTLS13 {
+		return nil, fmt.Errorf("unsupported TLS version: %#x", f.Version)
+	}
+	b := &bytes.Buffer{}
+	quicvarint.Write(b, clientSessionStateEncodingRevision)
+	quicvarint.Write(b, uint64(f.Version))
+	quicvarint.Write(b, uint64(f.CipherSuite))
+	writeBytes(b, f.SessionTicket)
+	writeBytes(b, f.MasterSecret)
+	writeCertificates(b, f.ServerCertificates)
+	quicvarint.Write(b, uint64(len(f.VerifiedChains)))
+	for _, chain := range f.VerifiedChains {
+		writeCertificates(b, chain)
+	}
+	if err := writeTime(b, f.ReceivedAt); err != nil {
+		return nil, err
+	}
+	writeBytes(b, f.OCSPResponse)
+	quicvarint.Write(b, uint64(len(f.SCTs)))
+	for _, sct := range f.SCTs {
+		writeBytes(b, sct)
+	}
+	writeBytes(b, f.Nonce)
+	if err := writeTime(b, f.UseBy); err != nil {
+		return nil, err
+	}
+	quicvarint.Write(b, uint64(f.AgeAdd))
+	return b.Bytes(), nil
+}
+
+// UnmarshalClientSessionState decodes a tls.ClientSessionState encoded by MarshalClientSessionState.
+func UnmarshalClientSessionState(data []byte) (*tls.ClientSessionState, error) {
+	r := bytes.NewReader(data)
+	rev, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, errors.New("failed to read session state revision")
+	}
+	if rev != clientSessionStateEncodingRevision {
+		return nil, fmt.Errorf("unknown session state revision: %d", rev)
+	}
+	f := &qtls.ClientSessionStateFields{}
+	vers, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if vers != tls.VersionTLS13 {
+		return nil, fmt.Errorf("unsupported TLS version: %#x", vers)
+	}
+	f.Version = uint16(vers)
+	cipherSuite, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if cipherSuite > 0xffff {
+		return nil, fmt.Errorf("invalid cipher suite: %#x", cipherSuite)
+	}
+	f.CipherSuite = uint16(cipherSuite)
+	if f.SessionTicket, err = readBytes(r); err != nil {
+		return nil, err
+	}
+	if f.MasterSecret, err = readBytes(r); err != nil {
+		return nil, err
+	}
+	if f.ServerCertificates, err = readCertificates(r); err != nil {
+		return nil, err
+	}
+	numChains, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if numChains > uint64(r.Len()) {
+		return nil, io.EOF
+	}
+	for i := uint64(0); i < numChains; i++ {
+		chain, err := readCertificates(r)
+		if err != nil {
+			return nil, err
+		}
+		f.VerifiedChains = append(f.VerifiedChains, chain)
+	}
+	if f.ReceivedAt, err = readTime(r); err != nil {
+		return nil, err
+	}
+	if f.OCSPResponse, err = readBytes(r); err != nil {
+		return nil, err
+	}
+	numSCTs, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if numSCTs > uint64(r.Len()) {
+		return nil, io.EOF
+	}
+	for i := uint64(0); i < numSCTs; i++ {
+		sct, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		f.SCTs = append(f.SCTs, sct)
+	}
+	if f.Nonce, err = readBytes(r); err != nil {
+		return nil, err
+	}
+	if f.UseBy, err = readTime(r); err != nil {
+		return nil, err
+	}
+	ageAdd, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if ageAdd > 0xffffffff {
+		return nil, fmt.Errorf("invalid ticket age add: %d", ageAdd)
+	}
+	f.AgeAdd = uint32(ageAdd)
+	if r.Len() > 0 {
+		return nil, errors.New("session state has trailing data")
+	}
+	return qtls.NewClientSessionState(f), nil
+}
+
+func writeBytes(b *bytes.Buffer, data []byte) {
+	quicvarint.Write(b, uint64(len(data)))
+	b.Write(data)
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	l, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(r.Len()) {
+		return nil, io.EOF
+	}
+	if l == 0 {
+		return nil, nil
+	}
+	data := make([]byte, l)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func writeCertificates(b *bytes.Buffer, certs []*x509.Certificate) {
+	quicvarint.Write(b, uint64(len(certs)))
+	for _, cert := range certs {
+		writeBytes(b, cert.Raw)
+	}
+}
+
+func readCertificates(r *bytes.Reader) ([]*x509.Certificate, error) {
+	num, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if num > uint64(r.Len()) {
+		return nil, io.EOF
+	}
+	var certs []*x509.Certificate
+	for i := uint64(0); i < num; i++ {
+		raw, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+func writeTime(b *bytes.Buffer, t time.Time) error {
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	writeBytes(b, data)
+	return nil
+}
+
+func readTime(r *bytes.Reader) (time.Time, error) {
+	data, err := readBytes(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var t time.Time
+	if err := t.UnmarshalBinary(data); err != nil {
+		return time.Time{}, err
+	}
+	return t, nil
+}
@@ -0,0 +1,90 @@
+package handshake
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/qtls"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client Session State", func() {
+	var fields *qtls.ClientSessionStateFields
+
+	BeforeEach(func() {
+		cert, err := x509.ParseCertificate(testdata.GetTLSConfig().Certificates[0].Certificate[0])
+		Expect(err).ToNot(HaveOccurred())
+		fields = &qtls.ClientSessionStateFields{
+			SessionTicket:      []byte("ticket"),
+			Version:            tls.VersionTLS13,
+			CipherSuite:        tls.TLS_CHACHA20_POLY1305_SHA256,
+			MasterSecret:       []byte("master secret"),
+			ServerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:     [][]*x509.Certificate{{cert}},
+			ReceivedAt:         time.Now().Round(0),
+			OCSPResponse:       []byte("ocsp"),
+			SCTs:               [][]byte{[]byte("sct1"), []byte("sct2")},
+			Nonce:              []byte("nonce and app data"),
+			UseBy:              time.Now().Add(time.Hour).Round(0),
+			AgeAdd:             0xdeadbeef,
+		}
+	})
+
+	It("marshals and unmarshals a session state", func() {
+		data, err := MarshalClientSessionState(qtls.NewClientSessionState(fields))
+		Expect(err).ToNot(HaveOccurred())
+		s, err := UnmarshalClientSessionState(data)
+		Expect(err).ToNot(HaveOccurred())
+		f := qtls.GetClientSessionStateFields(s)
+		Expect(f.SessionTicket).To(Equal(fields.SessionTicket))
+		Expect(f.Version).To(Equal(fields.Version))
+		Expect(f.CipherSuite).To(Equal(fields.CipherSuite))
+		Expect(f.MasterSecret).To(Equal(fields.MasterSecret))
+		Expect(f.ServerCertificates).To(HaveLen(1))
+		Expect(f.ServerCertificates[0].Equal(fields.ServerCertificates[0])).To(BeTrue())
+		Expect(f.VerifiedChains).To(HaveLen(1))
+		Expect(f.VerifiedChains[0]).To(HaveLen(1))
+		Expect(f.VerifiedChains[0][0].Equal(fields.VerifiedChains[0][0])).To(BeTrue())
+		Expect(f.ReceivedAt.Equal(fields.ReceivedAt)).To(BeTrue())
+		Expect(f.OCSPResponse).To(Equal(fields.OCSPResponse))
+		Expect(f.SCTs).To(Equal(fields.SCTs))
+		Expect(f.Nonce).To(Equal(fields.Nonce))
+		Expect(f.UseBy.Equal(fields.UseBy)).To(BeTrue())
+		Expect(f.AgeAdd).To(Equal(fields.AgeAdd))
+	})
+
+	It("refuses to marshal a TLS 1.2 session state", func() {
+		fields.Version = tls.VersionTLS12
+		_, err := MarshalClientSessionState(qtls.NewClientSessionState(fields))
+		Expect(err).To(MatchError("unsupported TLS version: 0x303"))
+	})
+
+	It("refuses to unmarshal if the revision doesn't match", func() {
+		b := &bytes.Buffer{}
+		quicvarint.Write(b, 1337)
+		_, err := UnmarshalClientSessionState(b.Bytes())
+		Expect(err).To(MatchError("unknown session state revision: 1337"))
+	})
+
+	It("refuses to unmarshal a truncated session state", func() {
+		data, err := MarshalClientSessionState(qtls.NewClientSessionState(fields))
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < len(data); i++ {
+			_, err := UnmarshalClientSessionState(data[:i])
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("refuses to unmarshal a session state with trailing data", func() {
+		data, err := MarshalClientSessionState(qtls.NewClientSessionState(fields))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = UnmarshalClientSessionState(append(data, 0))
+		Expect(err).To(MatchError("session state has trailing data"))
+	})
+})
@@ -12,7 +12,6 @@ import (
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
-	"github.com/lucas-clemente/quic-go/internal/qtls"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	"github.com/lucas-clemente/quic-go/logging"
@@ -61,37 +60,10 @@ func (m messageType) String() string {
 
 const clientSessionStateRevision = 3
 
-type conn struct {
-	localAddr, remoteAddr net.Addr
-	version               protocol.VersionNumber
-}
-
-var _ ConnWithVersion = &conn{}
-
-func newConn(local, remote net.Addr, version protocol.VersionNumber) ConnWithVersion {
-	return &conn{
-		localAddr:  local,
-		remoteAddr: remote,
-		version:    version,
-	}
-}
-
-var _ net.Conn = &conn{}
-
-func (c *conn) Read([]byte) (int, error)               { return 0, nil }
-func (c *conn) Write([]byte) (int, error)              { return 0, nil }
-func (c *conn) Close() error                           { return nil }
-func (c *conn) RemoteAddr() net.Addr                   { return c.remoteAddr }
-func (c *conn) LocalAddr() net.Addr                    { return c.localAddr }
-func (c *conn) SetReadDeadline(time.Time) error        { return nil }
-func (c *conn) SetWriteDeadline(time.Time) error       { return nil }
-func (c *conn) SetDeadline(time.Time) error            { return nil }
-func (c *conn) GetQUICVersion() protocol.VersionNumber { return c.version }
-
 type cryptoSetup struct {
-	tlsConf   *tls.Config
-	extraConf *qtls.ExtraConfig
-	conn      *qtls.Conn
+	tlsConf    *tls.Config
+	enable0RTT bool
+	conn       TLSConn
 
 	version protocol.VersionNumber
 
@@ -106,7 +78,7 @@ type cryptoSetup struct {
 	runner handshakeRunner
 
 	alertChan chan uint8
-	// handshakeDone is closed as soon as the go routine running qtls.Handshake() returns
+	// handshakeDone is closed as soon as the go routine running conn.Handshake() returns
 	handshakeDone chan struct{}
 	// is closed when Close() is called
 	closeChan chan struct{}
@@ -146,11 +118,12 @@ type cryptoSetup struct {
 }
 
 var (
-	_ qtls.RecordLayer = &cryptoSetup{}
-	_ CryptoSetup      = &cryptoSetup{}
+	_ RecordLayer = &cryptoSetup{}
+	_ CryptoSetup = &cryptoSetup{}
 )
 
-// NewCryptoSetupClient creates a new crypto setup for the client
+// NewCryptoSetupClient creates a new crypto setup for the client.
+// If backend is nil, the DefaultTLSBackend is used.
 func NewCryptoSetupClient(
 	initialStream io.Writer,
 	handshakeStream io.Writer,
@@ -160,13 +133,14 @@ func NewCryptoSetupClient(
 	tp *wire.TransportParameters,
 	runner handshakeRunner,
 	tlsConf *tls.Config,
+	backend TLSBackend,
 	enable0RTT bool,
 	rttStats *utils.RTTStats,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
 	version protocol.VersionNumber,
 ) (CryptoSetup, <-chan *wire.TransportParameters /* ClientHello written. Receive nil for non-0-RTT */) {
-	cs, clientHelloWritten := newCryptoSetup(
+	cs, extHandler := newCryptoSetup(
 		initialStream,
 		handshakeStream,
 		connID,
@@ -180,11 +154,15 @@ func NewCryptoSetupClient(
 		protocol.PerspectiveClient,
 		version,
 	)
-	cs.conn = qtls.Client(newConn(localAddr, remoteAddr, version), cs.tlsConf, cs.extraConf)
-	return cs, clientHelloWritten
+	if backend == nil {
+		backend = DefaultTLSBackend
+	}
+	cs.conn = backend.Client(cs.newTLSConnConfig(localAddr, remoteAddr, extHandler))
+	return cs, cs.clientHelloWrittenChan
 }
 
-// NewCryptoSetupServer creates a new crypto setup for the server
+// NewCryptoSetupServer creates a new crypto setup for the server.
+// If backend is nil, the DefaultTLSBackend is used.
 func NewCryptoSetupServer(
 	initialStream io.Writer,
 	handshakeStream io.Writer,
@@ -194,13 +172,14 @@ func NewCryptoSetupServer(
 	tp *wire.TransportParameters,
 	runner handshakeRunner,
 	tlsConf *tls.Config,
+	backend TLSBackend,
 	enable0RTT bool,
 	rttStats *utils.RTTStats,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
 	version protocol.VersionNumber,
 ) CryptoSetup {
-	cs, _ := newCryptoSetup(
+	cs, extHandler := newCryptoSetup(
 		initialStream,
 		handshakeStream,
 		connID,
@@ -214,7 +193,10 @@ func NewCryptoSetupServer(
 		protocol.PerspectiveServer,
 		version,
 	)
-	cs.conn = qtls.Server(newConn(localAddr, remoteAddr, version), cs.tlsConf, cs.extraConf)
+	if backend == nil {
+		backend = DefaultTLSBackend
+	}
+	cs.conn = backend.Server(cs.newTLSConnConfig(localAddr, remoteAddr, extHandler))
 	return cs
 }
 
@@ -231,7 +213,7 @@ func newCryptoSetup(
 	logger utils.Logger,
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
-) (*cryptoSetup, <-chan *wire.TransportParameters /* ClientHello written. Receive nil for non-0-RTT */) {
+) (*cryptoSetup, tlsExtensionHandler) {
 	initialSealer, initialOpener := NewInitialAEAD(connID, perspective, version)
 	if tracer != nil {
 		tracer.UpdatedKeyFromTLS(protocol.EncryptionInitial, protocol.PerspectiveClient)
@@ -240,6 +222,7 @@ func newCryptoSetup(
 	extHandler := newExtensionHandler(tp.Marshal(perspective), perspective, version)
 	cs := &cryptoSetup{
 		tlsConf:                   tlsConf,
+		enable0RTT:                enable0RTT,
 		initialStream:             initialStream,
 		initialSealer:             initialSealer,
 		initialOpener:             initialOpener,
@@ -262,23 +245,24 @@ func newCryptoSetup(
 		closeChan:                 make(chan struct{}),
 		version:                   version,
 	}
-	var maxEarlyData uint32
-	if enable0RTT {
-		maxEarlyData = 0xffffffff
-	}
-	cs.extraConf = &qtls.ExtraConfig{
+	return cs, extHandler
+}
+
+func (h *cryptoSetup) newTLSConnConfig(localAddr, remoteAddr net.Addr, extHandler tlsExtensionHandler) *TLSConnConfig {
+	return &TLSConnConfig{
+		TLSConfig:                  h.tlsConf,
+		LocalAddr:                  localAddr,
+		RemoteAddr:                 remoteAddr,
+		Version:                    h.version,
+		RecordLayer:                h,
 		GetExtensions:              extHandler.GetExtensions,
 		ReceivedExtensions:         extHandler.ReceivedExtensions,
-		AlternativeRecordLayer:     cs,
-		EnforceNextProtoSelection:  true,
-		MaxEarlyData:               maxEarlyData,
-		Accept0RTT:                 cs.accept0RTT,
-		Rejected0RTT:               cs.rejected0RTT,
-		Enable0RTT:                 enable0RTT,
-		GetAppDataForSessionState:  cs.marshalDataForSessionState,
-		SetAppDataFromSessionState: cs.handleDataFromSessionState,
+		Enable0RTT:                 h.enable0RTT,
+		Accept0RTT:                 h.accept0RTT,
+		Rejected0RTT:               h.rejected0RTT,
+		GetAppDataForSessionState:  h.marshalDataForSessionState,
+		SetAppDataFromSessionState: h.handleDataFromSessionState,
 	}
-	return cs, cs.clientHelloWrittenChan
 }
 
 func (h *cryptoSetup) ChangeConnectionID(id protocol.ConnectionID) {
@@ -295,6 +279,14 @@ func (h *cryptoSetup) SetLargest1RTTAcked(pn protocol.PacketNumber) error {
 	return h.aead.SetLargestAcked(pn)
 }
 
+func (h *cryptoSetup) RequestKeyUpdate() {
+	h.aead.RequestKeyUpdate()
+}
+
+func (h *cryptoSetup) CurrentKeyPhase() protocol.KeyPhase {
+	return h.aead.CurrentKeyPhase()
+}
+
 func (h *cryptoSetup) RunHandshake() {
 	// Handle errors that might occur when HandleData() is called.
 	handshakeComplete := make(chan struct{})
@@ -332,7 +324,7 @@ func (h *cryptoSetup) onError(alert uint8, message string) {
 // It must only be called once.
 func (h *cryptoSetup) Close() error {
 	close(h.closeChan)
-	// wait until qtls.Handshake() actually returned
+	// wait until conn.Handshake() actually returned
 	<-h.handshakeDone
 	return nil
 }
@@ -455,7 +447,7 @@ func (h *cryptoSetup) handleDataFromSessionStateImpl(data []byte) (*wire.Transpo
 func (h *cryptoSetup) GetSessionTicket() ([]byte, error) {
 	var appData []byte
 	// Save transport parameters to the session ticket if we're allowing 0-RTT.
-	if h.extraConf.MaxEarlyData > 0 {
+	if h.enable0RTT {
 		appData = (&sessionTicket{
 			Parameters: h.ourParams,
 			RTT:        h.rttStats.SmoothedRTT(),
@@ -546,10 +538,10 @@ func (h *cryptoSetup) ReadHandshakeMessage() ([]byte, error) {
 	}
 }
 
-func (h *cryptoSetup) SetReadKey(encLevel qtls.EncryptionLevel, suite *qtls.CipherSuiteTLS13, trafficSecret []byte) {
+func (h *cryptoSetup) SetReadKey(encLevel protocol.EncryptionLevel, suite *CipherSuite, trafficSecret []byte) {
 	h.mutex.Lock()
 	switch encLevel {
-	case qtls.Encryption0RTT:
+	case protocol.Encryption0RTT:
 		if h.perspective == protocol.PerspectiveClient {
 			panic("Received 0-RTT read key for the client")
 		}
@@ -563,7 +555,7 @@ func (h *cryptoSetup) SetReadKey(encLevel qtls.EncryptionLevel, suite *qtls.Ciph
 			h.tracer.UpdatedKeyFromTLS(protocol.Encryption0RTT, h.perspective.Opposite())
 		}
 		return
-	case qtls.EncryptionHandshake:
+	case protocol.EncryptionHandshake:
 		h.readEncLevel = protocol.EncryptionHandshake
 		h.handshakeOpener = newHandshakeOpener(
 			createAEAD(suite, trafficSecret),
@@ -572,7 +564,7 @@ func (h *cryptoSetup) SetReadKey(encLevel qtls.EncryptionLevel, suite *qtls.Ciph
 			h.perspective,
 		)
 		h.logger.Debugf("Installed Handshake Read keys (using %s)", tls.CipherSuiteName(suite.ID))
-	case qtls.EncryptionApplication:
+	case protocol.Encryption1RTT:
 		h.readEncLevel = protocol.Encryption1RTT
 		h.aead.SetReadKey(suite, trafficSecret)
 		h.has1RTTOpener = true
@@ -586,10 +578,10 @@ func (h *cryptoSetup) SetReadKey(encLevel qtls.EncryptionLevel, suite *qtls.Ciph
 	}
 }
 
-func (h *cryptoSetup) SetWriteKey(encLevel qtls.EncryptionLevel, suite *qtls.CipherSuiteTLS13, trafficSecret []byte) {
+func (h *cryptoSetup) SetWriteKey(encLevel protocol.EncryptionLevel, suite *CipherSuite, trafficSecret []byte) {
 	h.mutex.Lock()
 	switch encLevel {
-	case qtls.Encryption0RTT:
+	case protocol.Encryption0RTT:
 		if h.perspective == protocol.PerspectiveServer {
 			panic("Received 0-RTT write key for the server")
 		}
@@ -603,7 +595,7 @@ func (h *cryptoSetup) SetWriteKey(encLevel qtls.EncryptionLevel, suite *qtls.Cip
 			h.tracer.UpdatedKeyFromTLS(protocol.Encryption0RTT, h.perspective)
 		}
 		return
-	case qtls.EncryptionHandshake:
+	case protocol.EncryptionHandshake:
 		h.writeEncLevel = protocol.EncryptionHandshake
 		h.handshakeSealer = newHandshakeSealer(
 			createAEAD(suite, trafficSecret),
@@ -612,7 +604,7 @@ func (h *cryptoSetup) SetWriteKey(encLevel qtls.EncryptionLevel, suite *qtls.Cip
 			h.perspective,
 		)
 		h.logger.Debugf("Installed Handshake Write keys (using %s)", tls.CipherSuiteName(suite.ID))
-	case qtls.EncryptionApplication:
+	case protocol.Encryption1RTT:
 		h.writeEncLevel = protocol.Encryption1RTT
 		h.aead.SetWriteKey(suite, trafficSecret)
 		h.has1RTTSealer = true
@@ -796,5 +788,6 @@ func (h *cryptoSetup) Get1RTTOpener() (ShortHeaderOpener, error) {
 }
 
 func (h *cryptoSetup) ConnectionState() ConnectionState {
-	return qtls.GetConnectionState(h.conn)
+	cs, used0RTT := h.conn.ConnectionState()
+	return ConnectionState{ConnectionState: cs, Used0RTT: used0RTT}
 }
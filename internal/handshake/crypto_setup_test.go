@@ -8,6 +8,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	mocktls "github.com/lucas-clemente/quic-go/internal/mocks/tls"
@@ -58,6 +59,39 @@ func (s *stream) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+type countingTLSBackend struct {
+	TLSBackend
+	numClients, numServers int
+	numKeys                int32
+}
+
+func (b *countingTLSBackend) Client(conf *TLSConnConfig) TLSConn {
+	b.numClients++
+	conf.RecordLayer = &countingRecordLayer{RecordLayer: conf.RecordLayer, numKeys: &b.numKeys}
+	return b.TLSBackend.Client(conf)
+}
+
+func (b *countingTLSBackend) Server(conf *TLSConnConfig) TLSConn {
+	b.numServers++
+	conf.RecordLayer = &countingRecordLayer{RecordLayer: conf.RecordLayer, numKeys: &b.numKeys}
+	return b.TLSBackend.Server(conf)
+}
+
+type countingRecordLayer struct {
+	RecordLayer
+	numKeys *int32
+}
+
+func (l *countingRecordLayer) SetReadKey(encLevel protocol.EncryptionLevel, suite *CipherSuite, trafficSecret []byte) {
+	atomic.AddInt32(l.numKeys, 1)
+	l.RecordLayer.SetReadKey(encLevel, suite, trafficSecret)
+}
+
+func (l *countingRecordLayer) SetWriteKey(encLevel protocol.EncryptionLevel, suite *CipherSuite, trafficSecret []byte) {
+	atomic.AddInt32(l.numKeys, 1)
+	l.RecordLayer.SetWriteKey(encLevel, suite, trafficSecret)
+}
+
 var _ = Describe("Crypto Setup TLS", func() {
 	var clientConf, serverConf *tls.Config
 
@@ -95,6 +129,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&wire.TransportParameters{StatelessResetToken: &token},
 			runner,
 			testdata.GetTLSConfig(),
+			nil,
 			false,
 			&utils.RTTStats{},
 			nil,
@@ -139,6 +174,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&wire.TransportParameters{StatelessResetToken: &token},
 			runner,
 			testdata.GetTLSConfig(),
+			nil,
 			false,
 			&utils.RTTStats{},
 			nil,
@@ -180,6 +216,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&wire.TransportParameters{StatelessResetToken: &token},
 			runner,
 			serverConf,
+			nil,
 			false,
 			&utils.RTTStats{},
 			nil,
@@ -215,6 +252,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&wire.TransportParameters{StatelessResetToken: &token},
 			NewMockHandshakeRunner(mockCtrl),
 			serverConf,
+			nil,
 			false,
 			&utils.RTTStats{},
 			nil,
@@ -333,6 +371,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				clientTransportParameters,
 				cRunner,
 				clientConf,
+				nil,
 				enable0RTT,
 				clientRTTStats,
 				nil,
@@ -360,6 +399,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				serverTransportParameters,
 				sRunner,
 				serverConf,
+				nil,
 				enable0RTT,
 				serverRTTStats,
 				nil,
@@ -382,6 +422,25 @@ var _ = Describe("Crypto Setup TLS", func() {
 			return clientHelloWrittenChan, client, cErr, server, sErr
 		}
 
+		It("uses the TLS backend", func() {
+			backend := &countingTLSBackend{TLSBackend: DefaultTLSBackend}
+			origBackend := DefaultTLSBackend
+			DefaultTLSBackend = backend
+			defer func() { DefaultTLSBackend = origBackend }()
+			_, _, clientErr, _, serverErr := handshakeWithTLSConf(
+				clientConf, serverConf,
+				&utils.RTTStats{}, &utils.RTTStats{},
+				&wire.TransportParameters{}, &wire.TransportParameters{},
+				false,
+			)
+			Expect(clientErr).ToNot(HaveOccurred())
+			Expect(serverErr).ToNot(HaveOccurred())
+			Expect(backend.numClients).To(Equal(1))
+			Expect(backend.numServers).To(Equal(1))
+			// Handshake and 1-RTT keys, for both directions, for client and server
+			Expect(backend.numKeys).To(BeEquivalentTo(8))
+		})
+
 		It("handshakes", func() {
 			_, _, clientErr, _, serverErr := handshakeWithTLSConf(
 				clientConf, serverConf,
@@ -430,6 +489,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				&wire.TransportParameters{},
 				runner,
 				&tls.Config{InsecureSkipVerify: true},
+				nil,
 				false,
 				&utils.RTTStats{},
 				nil,
@@ -473,6 +533,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				cTransportParameters,
 				cRunner,
 				clientConf,
+				nil,
 				false,
 				&utils.RTTStats{},
 				nil,
@@ -498,6 +559,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				sTransportParameters,
 				sRunner,
 				serverConf,
+				nil,
 				false,
 				&utils.RTTStats{},
 				nil,
@@ -532,6 +594,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					&wire.TransportParameters{},
 					cRunner,
 					clientConf,
+					nil,
 					false,
 					&utils.RTTStats{},
 					nil,
@@ -553,6 +616,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					&wire.TransportParameters{StatelessResetToken: &token},
 					sRunner,
 					serverConf,
+					nil,
 					false,
 					&utils.RTTStats{},
 					nil,
@@ -591,6 +655,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					&wire.TransportParameters{},
 					cRunner,
 					clientConf,
+					nil,
 					false,
 					&utils.RTTStats{},
 					nil,
@@ -612,6 +677,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					&wire.TransportParameters{StatelessResetToken: &token},
 					sRunner,
 					serverConf,
+					nil,
 					false,
 					&utils.RTTStats{},
 					nil,
@@ -771,6 +837,58 @@ var _ = Describe("Crypto Setup TLS", func() {
 				Expect(client.ConnectionState().Used0RTT).To(BeTrue())
 			})
 
+			It("uses 0-RTT with an exported and imported session state", func() {
+				csc := mocktls.NewMockClientSessionCache(mockCtrl)
+				var state *tls.ClientSessionState
+				receivedSessionTicket := make(chan struct{})
+				csc.EXPECT().Get(gomock.Any())
+				csc.EXPECT().Put(gomock.Any(), gomock.Any()).Do(func(_ string, css *tls.ClientSessionState) {
+					state = css
+					close(receivedSessionTicket)
+				})
+				clientConf.ClientSessionCache = csc
+				const clientRTT = 30 * time.Millisecond // RTT as measured by the client. Should be restored.
+				const initialMaxData protocol.ByteCount = 1337
+				_, _, clientErr, _, serverErr := handshakeWithTLSConf(
+					clientConf, serverConf,
+					newRTTStatsWithRTT(clientRTT), &utils.RTTStats{},
+					&wire.TransportParameters{}, &wire.TransportParameters{InitialMaxData: initialMaxData},
+					true,
+				)
+				Expect(clientErr).ToNot(HaveOccurred())
+				Expect(serverErr).ToNot(HaveOccurred())
+				Eventually(receivedSessionTicket).Should(BeClosed())
+
+				data, err := MarshalClientSessionState(state)
+				Expect(err).ToNot(HaveOccurred())
+				imported, err := UnmarshalClientSessionState(data)
+				Expect(err).ToNot(HaveOccurred())
+
+				csc.EXPECT().Get(gomock.Any()).Return(imported, true)
+				csc.EXPECT().Put(gomock.Any(), nil)
+				csc.EXPECT().Put(gomock.Any(), gomock.Any()).MaxTimes(1)
+
+				clientRTTStats := &utils.RTTStats{}
+				clientHelloWrittenChan, client, clientErr, server, serverErr := handshakeWithTLSConf(
+					clientConf, serverConf,
+					clientRTTStats, &utils.RTTStats{},
+					&wire.TransportParameters{}, &wire.TransportParameters{InitialMaxData: initialMaxData},
+					true,
+				)
+				Expect(clientErr).ToNot(HaveOccurred())
+				Expect(serverErr).ToNot(HaveOccurred())
+				Expect(clientRTTStats.SmoothedRTT()).To(Equal(clientRTT))
+
+				var tp *wire.TransportParameters
+				Expect(clientHelloWrittenChan).To(Receive(&tp))
+				Expect(tp.InitialMaxData).To(Equal(initialMaxData))
+
+				Expect(server.ConnectionState().DidResume).To(BeTrue())
+				Expect(client.ConnectionState().DidResume).To(BeTrue())
+				Expect(server.ConnectionState().Used0RTT).To(BeTrue())
+				Expect(client.ConnectionState().Used0RTT).To(BeTrue())
+			})
+
 			It("rejects 0-RTT, when the transport parameters changed", func() {
 				csc := mocktls.NewMockClientSessionCache(mockCtrl)
 				var state *tls.ClientSessionState
@@ -0,0 +1,48 @@
+package handshake
+
+import "crypto/tls"
+
+// fipsCipherSuites are the TLS 1.3 cipher suites that use FIPS-approved algorithms.
+var fipsCipherSuites = []uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384}
+
+// fipsCurvePreferences are the key exchange groups that use FIPS-approved algorithms.
+var fipsCurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+// fipsTLSConfig returns a copy of the tls.Config that only allows FIPS-approved cipher suites and key exchange groups.
+// Cipher suites and curves configured by the application are respected, as long as they are approved.
+func fipsTLSConfig(conf *tls.Config) *tls.Config {
+	c := conf.Clone()
+	var suites []uint16
+	for _, id := range conf.CipherSuites {
+		if isFIPSCipherSuite(id) {
+			suites = append(suites, id)
+		}
+	}
+	if len(suites) == 0 {
+		suites = fipsCipherSuites
+	}
+	c.CipherSuites = suites
+	var curves []tls.CurveID
+	for _, curve := range conf.CurvePreferences {
+		for _, approved := range fipsCurvePreferences {
+			if curve == approved {
+				curves = append(curves, curve)
+				break
+			}
+		}
+	}
+	if len(curves) == 0 {
+		curves = fipsCurvePreferences
+	}
+	c.CurvePreferences = curves
+	return c
+}
+
+func isFIPSCipherSuite(id uint16) bool {
+	for _, s := range fipsCipherSuites {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+//go:build boringcrypto
+// +build boringcrypto
+
+package handshake
+
+// When building with GOEXPERIMENT=boringcrypto, the TLS handshake only uses FIPS-approved algorithms.
+const fipsMode = true
@@ -0,0 +1,39 @@
+package handshake
+
+import (
+	"crypto/tls"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FIPS", func() {
+	It("restricts the cipher suites and curves", func() {
+		conf := &tls.Config{ServerName: "quic.clemente.io"}
+		c := fipsTLSConfig(conf)
+		Expect(c.ServerName).To(Equal("quic.clemente.io"))
+		Expect(c.CipherSuites).To(Equal([]uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384}))
+		Expect(c.CurvePreferences).To(Equal([]tls.CurveID{tls.CurveP256, tls.CurveP384}))
+		// the original config is not modified
+		Expect(conf.CipherSuites).To(BeEmpty())
+		Expect(conf.CurvePreferences).To(BeEmpty())
+	})
+
+	It("respects the configured cipher suites and curves, as long as they're approved", func() {
+		c := fipsTLSConfig(&tls.Config{
+			CipherSuites:     []uint16{tls.TLS_CHACHA20_POLY1305_SHA256, tls.TLS_AES_256_GCM_SHA384},
+			CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP384},
+		})
+		Expect(c.CipherSuites).To(Equal([]uint16{tls.TLS_AES_256_GCM_SHA384}))
+		Expect(c.CurvePreferences).To(Equal([]tls.CurveID{tls.CurveP384}))
+	})
+
+	It("uses the default cipher suites and curves, if none of the configured are approved", func() {
+		c := fipsTLSConfig(&tls.Config{
+			CipherSuites:     []uint16{tls.TLS_CHACHA20_POLY1305_SHA256},
+			CurvePreferences: []tls.CurveID{tls.X25519},
+		})
+		Expect(c.CipherSuites).To(Equal(fipsCipherSuites))
+		Expect(c.CurvePreferences).To(Equal(fipsCurvePreferences))
+	})
+})
@@ -41,8 +41,8 @@ func splitHexString(s string) (slice []byte) {
 	return
 }
 
-var cipherSuites = []*qtls.CipherSuiteTLS13{
-	qtls.CipherSuiteTLS13ByID(tls.TLS_AES_128_GCM_SHA256),
-	qtls.CipherSuiteTLS13ByID(tls.TLS_AES_256_GCM_SHA384),
-	qtls.CipherSuiteTLS13ByID(tls.TLS_CHACHA20_POLY1305_SHA256),
+var cipherSuites = []*CipherSuite{
+	cipherSuiteFromQTLS(qtls.CipherSuiteTLS13ByID(tls.TLS_AES_128_GCM_SHA256)),
+	cipherSuiteFromQTLS(qtls.CipherSuiteTLS13ByID(tls.TLS_AES_256_GCM_SHA384)),
+	cipherSuiteFromQTLS(qtls.CipherSuiteTLS13ByID(tls.TLS_CHACHA20_POLY1305_SHA256)),
 }
@@ -7,7 +7,6 @@ import (
 	"golang.org/x/crypto/hkdf"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
-	"github.com/lucas-clemente/quic-go/internal/qtls"
 )
 
 var (
@@ -22,10 +21,10 @@ func getSalt(v protocol.VersionNumber) []byte {
 	return quicSaltOld
 }
 
-var initialSuite = &qtls.CipherSuiteTLS13{
+var initialSuite = &CipherSuite{
 	ID:     tls.TLS_AES_128_GCM_SHA256,
 	KeyLen: 16,
-	AEAD:   qtls.AEADAESGCMTLS13,
+	AEAD:   aeadAESGCMTLS13,
 	Hash:   crypto.SHA256,
 }
 
@@ -43,8 +42,8 @@ func NewInitialAEAD(connID protocol.ConnectionID, pers protocol.Perspective, v p
 	myKey, myIV := computeInitialKeyAndIV(mySecret)
 	otherKey, otherIV := computeInitialKeyAndIV(otherSecret)
 
-	encrypter := qtls.AEADAESGCMTLS13(myKey, myIV)
-	decrypter := qtls.AEADAESGCMTLS13(otherKey, otherIV)
+	encrypter := aeadAESGCMTLS13(myKey, myIV)
+	decrypter := aeadAESGCMTLS13(otherKey, otherIV)
 
 	return newLongHeaderSealer(encrypter, newHeaderProtector(initialSuite, mySecret, true)),
 		newLongHeaderOpener(decrypter, newAESHeaderProtector(initialSuite, otherSecret, true))
@@ -42,6 +42,8 @@ type ShortHeaderOpener interface {
 	headerDecryptor
 	DecodePacketNumber(wirePN protocol.PacketNumber, wirePNLen protocol.PacketNumberLen) protocol.PacketNumber
 	Open(dst, src []byte, rcvTime time.Time, pn protocol.PacketNumber, kp protocol.KeyPhaseBit, associatedData []byte) ([]byte, error)
+	// OpenOnPath opens a packet received on an additional path, when using multipath.
+	OpenOnPath(dst, src []byte, rcvTime time.Time, pathID uint64, pn protocol.PacketNumber, kp protocol.KeyPhaseBit, associatedData []byte) ([]byte, error)
 }
 
 // LongHeaderSealer seals a long header packet
@@ -55,12 +57,14 @@ type LongHeaderSealer interface {
 type ShortHeaderSealer interface {
 	LongHeaderSealer
 	KeyPhase() protocol.KeyPhaseBit
+	// SealOnPath seals a packet sent on an additional path, when using multipath.
+	SealOnPath(dst, src []byte, pathID uint64, packetNumber protocol.PacketNumber, associatedData []byte) []byte
 }
 
 // A tlsExtensionHandler sends and received the QUIC TLS extension.
 type tlsExtensionHandler interface {
-	GetExtensions(msgType uint8) []qtls.Extension
-	ReceivedExtensions(msgType uint8, exts []qtls.Extension)
+	GetExtensions(msgType uint8) []TLSExtension
+	ReceivedExtensions(msgType uint8, exts []TLSExtension)
 	TransportParameters() <-chan []byte
 }
 
@@ -82,6 +86,10 @@ type CryptoSetup interface {
 	SetLargest1RTTAcked(protocol.PacketNumber) error
 	SetHandshakeConfirmed()
 	ConnectionState() ConnectionState
+	// RequestKeyUpdate requests a 1-RTT key update. It is safe to call from any go routine.
+	RequestKeyUpdate()
+	// CurrentKeyPhase returns the current 1-RTT key phase. It is safe to call from any go routine.
+	CurrentKeyPhase() protocol.KeyPhase
 
 	GetInitialOpener() (LongHeaderOpener, error)
 	GetHandshakeOpener() (LongHeaderOpener, error)
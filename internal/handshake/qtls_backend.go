@@ -0,0 +1,147 @@
+package handshake
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/qtls"
+)
+
+type conn struct {
+	localAddr, remoteAddr net.Addr
+	version               protocol.VersionNumber
+}
+
+var _ ConnWithVersion = &conn{}
+
+func newConn(local, remote net.Addr, version protocol.VersionNumber) ConnWithVersion {
+	return &conn{
+		localAddr:  local,
+		remoteAddr: remote,
+		version:    version,
+	}
+}
+
+var _ net.Conn = &conn{}
+
+func (c *conn) Read([]byte) (int, error)               { return 0, nil }
+func (c *conn) Write([]byte) (int, error)              { return 0, nil }
+func (c *conn) Close() error                           { return nil }
+func (c *conn) RemoteAddr() net.Addr                   { return c.remoteAddr }
+func (c *conn) LocalAddr() net.Addr                    { return c.localAddr }
+func (c *conn) SetReadDeadline(time.Time) error        { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error       { return nil }
+func (c *conn) SetDeadline(time.Time) error            { return nil }
+func (c *conn) GetQUICVersion() protocol.VersionNumber { return c.version }
+
+// qtlsBackend is the TLSBackend using qtls.
+type qtlsBackend struct{}
+
+var _ TLSBackend = &qtlsBackend{}
+
+func (b *qtlsBackend) Client(conf *TLSConnConfig) TLSConn {
+	return &qtlsConn{Conn: qtls.Client(newConn(conf.LocalAddr, conf.RemoteAddr, conf.Version), qtlsTLSConfig(conf), newQTLSExtraConfig(conf))}
+}
+
+func (b *qtlsBackend) Server(conf *TLSConnConfig) TLSConn {
+	return &qtlsConn{Conn: qtls.Server(newConn(conf.LocalAddr, conf.RemoteAddr, conf.Version), qtlsTLSConfig(conf), newQTLSExtraConfig(conf))}
+}
+
+// qtlsTLSConfig returns the tls.Config passed to qtls.
+// In FIPS mode, qtls is restricted to the cipher suites and curves using FIPS-approved algorithms.
+// The AEADs of these cipher suites are then created using aeadAESGCMTLS13.
+func qtlsTLSConfig(conf *TLSConnConfig) *tls.Config {
+	if fipsMode {
+		return fipsTLSConfig(conf.TLSConfig)
+	}
+	return conf.TLSConfig
+}
+
+func newQTLSExtraConfig(conf *TLSConnConfig) *qtls.ExtraConfig {
+	var maxEarlyData uint32
+	if conf.Enable0RTT {
+		maxEarlyData = 0xffffffff
+	}
+	return &qtls.ExtraConfig{
+		GetExtensions: func(msgType uint8) []qtls.Extension {
+			exts := conf.GetExtensions(msgType)
+			if exts == nil {
+				return nil
+			}
+			qexts := make([]qtls.Extension, 0, len(exts))
+			for _, ext := range exts {
+				qexts = append(qexts, qtls.Extension{Type: ext.Type, Data: ext.Data})
+			}
+			return qexts
+		},
+		ReceivedExtensions: func(msgType uint8, qexts []qtls.Extension) {
+			exts := make([]TLSExtension, 0, len(qexts))
+			for _, ext := range qexts {
+				exts = append(exts, TLSExtension{Type: ext.Type, Data: ext.Data})
+			}
+			conf.ReceivedExtensions(msgType, exts)
+		},
+		AlternativeRecordLayer:     &qtlsRecordLayer{RecordLayer: conf.RecordLayer},
+		EnforceNextProtoSelection:  true,
+		MaxEarlyData:               maxEarlyData,
+		Accept0RTT:                 conf.Accept0RTT,
+		Rejected0RTT:               conf.Rejected0RTT,
+		Enable0RTT:                 conf.Enable0RTT,
+		GetAppDataForSessionState:  conf.GetAppDataForSessionState,
+		SetAppDataFromSessionState: conf.SetAppDataFromSessionState,
+	}
+}
+
+type qtlsConn struct {
+	*qtls.Conn
+}
+
+var _ TLSConn = &qtlsConn{}
+
+func (c *qtlsConn) ConnectionState() (tls.ConnectionState, bool) {
+	cs := qtls.GetConnectionState(c.Conn)
+	return qtls.ToTLSConnectionState(cs), cs.Used0RTT
+}
+
+// qtlsRecordLayer adapts a RecordLayer to the record layer interface used by qtls.
+type qtlsRecordLayer struct {
+	RecordLayer
+}
+
+var _ qtls.RecordLayer = &qtlsRecordLayer{}
+
+func (l *qtlsRecordLayer) SetReadKey(encLevel qtls.EncryptionLevel, suite *qtls.CipherSuiteTLS13, trafficSecret []byte) {
+	l.RecordLayer.SetReadKey(encryptionLevelFromQTLS(encLevel), cipherSuiteFromQTLS(suite), trafficSecret)
+}
+
+func (l *qtlsRecordLayer) SetWriteKey(encLevel qtls.EncryptionLevel, suite *qtls.CipherSuiteTLS13, trafficSecret []byte) {
+	l.RecordLayer.SetWriteKey(encryptionLevelFromQTLS(encLevel), cipherSuiteFromQTLS(suite), trafficSecret)
+}
+
+func encryptionLevelFromQTLS(encLevel qtls.EncryptionLevel) protocol.EncryptionLevel {
+	switch encLevel {
+	case qtls.Encryption0RTT:
+		return protocol.Encryption0RTT
+	case qtls.EncryptionHandshake:
+		return protocol.EncryptionHandshake
+	case qtls.EncryptionApplication:
+		return protocol.Encryption1RTT
+	default:
+		panic("unexpected encryption level")
+	}
+}
+
+func cipherSuiteFromQTLS(suite *qtls.CipherSuiteTLS13) *CipherSuite {
+	aead := suite.AEAD
+	if fipsMode && isFIPSCipherSuite(suite.ID) {
+		aead = aeadAESGCMTLS13
+	}
+	return &CipherSuite{
+		ID:     suite.ID,
+		KeyLen: suite.KeyLen,
+		Hash:   suite.Hash,
+		AEAD:   aead,
+	}
+}
@@ -0,0 +1,104 @@
+package handshake
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"crypto/tls"
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A CipherSuite is a TLS 1.3 cipher suite.
+type CipherSuite struct {
+	ID     uint16
+	KeyLen int
+	Hash   crypto.Hash
+	// AEAD creates the AEAD used to protect packets.
+	// The nonce passed to Seal and Open is XORed with the nonceMask, see RFC 8446, section 5.3.
+	AEAD func(key, nonceMask []byte) cipher.AEAD
+}
+
+// IVLen returns the length of the IV.
+func (c *CipherSuite) IVLen() int {
+	return 12 // all TLS 1.3 cipher suites use a 12 byte nonce
+}
+
+// A TLSExtension is a TLS extension.
+type TLSExtension struct {
+	Type uint16
+	Data []byte
+}
+
+// A RecordLayer replaces the TLS record layer when TLS is used for QUIC, see RFC 9001, section 4.
+// It is implemented by quic-go, and used by the TLS stack.
+type RecordLayer interface {
+	// SetReadKey installs the key used to open messages received at the given encryption level.
+	SetReadKey(encLevel protocol.EncryptionLevel, suite *CipherSuite, trafficSecret []byte)
+	// SetWriteKey installs the key used to seal messages sent at the given encryption level.
+	SetWriteKey(encLevel protocol.EncryptionLevel, suite *CipherSuite, trafficSecret []byte)
+	// ReadHandshakeMessage blocks until a handshake message is received.
+	ReadHandshakeMessage() ([]byte, error)
+	// WriteRecord sends a handshake message at the current write encryption level.
+	WriteRecord([]byte) (int, error)
+	// SendAlert is called when the handshake fails. It aborts the handshake.
+	SendAlert(alert uint8)
+}
+
+// A TLSConnConfig contains everything needed to create a TLSConn.
+type TLSConnConfig struct {
+	TLSConfig  *tls.Config
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Version is the QUIC version. It must be exposed to the application in the ClientHelloInfo.
+	Version protocol.VersionNumber
+
+	// RecordLayer is used to send and receive handshake messages, and to install keys.
+	RecordLayer RecordLayer
+
+	// GetExtensions returns the extensions that are sent in the handshake message of the given type,
+	// i.e. the QUIC transport parameters.
+	GetExtensions func(handshakeMessageType uint8) []TLSExtension
+	// ReceivedExtensions is called with the extensions received in a handshake message.
+	ReceivedExtensions func(handshakeMessageType uint8, exts []TLSExtension)
+
+	// Enable0RTT enables 0-RTT. For the server, this also means that session tickets allowing 0-RTT are issued.
+	Enable0RTT bool
+	// Accept0RTT is called by the server when receiving a ClientHello that offers 0-RTT.
+	// appData is the data stored in the session ticket by TLSConn.GetSessionTicket.
+	Accept0RTT func(appData []byte) bool
+	// Rejected0RTT is called by the client when the server rejects 0-RTT.
+	Rejected0RTT func()
+	// GetAppDataForSessionState is called by the client when a session ticket is received.
+	// The data must be saved together with the session state.
+	GetAppDataForSessionState func() []byte
+	// SetAppDataFromSessionState is called by the client when resuming a session.
+	// It restores the data that was saved using GetAppDataForSessionState.
+	SetAppDataFromSessionState func([]byte)
+}
+
+// A TLSConn is a TLS 1.3 connection that uses QUIC as its record layer.
+type TLSConn interface {
+	// Handshake runs the handshake. It blocks until the handshake completes or fails.
+	Handshake() error
+	// HandlePostHandshakeMessage handles a handshake message received after completion of the handshake,
+	// i.e. a NewSessionTicket message. The message is read using RecordLayer.ReadHandshakeMessage.
+	HandlePostHandshakeMessage() error
+	// GetSessionTicket generates a session ticket (only used by the server).
+	// The appData must be passed to TLSConnConfig.Accept0RTT when the ticket is used to resume the session.
+	GetSessionTicket(appData []byte) ([]byte, error)
+	// ConnectionState returns the state of the TLS connection,
+	// and if 0-RTT was used.
+	ConnectionState() (state tls.ConnectionState, used0RTT bool)
+}
+
+// A TLSBackend creates TLS connections for the QUIC handshake.
+// This decouples quic-go from a particular TLS 1.3 implementation.
+type TLSBackend interface {
+	Client(*TLSConnConfig) TLSConn
+	Server(*TLSConnConfig) TLSConn
+}
+
+// DefaultTLSBackend is the TLS backend used if no other backend is configured.
+// It uses qtls.
+var DefaultTLSBackend TLSBackend = &qtlsBackend{}
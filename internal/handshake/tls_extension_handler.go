@@ -1,9 +1,6 @@
 package handshake
 
-import (
-	"github.com/lucas-clemente/quic-go/internal/protocol"
-	"github.com/lucas-clemente/quic-go/internal/qtls"
-)
+import "github.com/lucas-clemente/quic-go/internal/protocol"
 
 const (
 	quicTLSExtensionTypeOldDrafts = 0xffa5
@@ -35,18 +32,18 @@ func newExtensionHandler(params []byte, pers protocol.Perspective, v protocol.Ve
 	}
 }
 
-func (h *extensionHandler) GetExtensions(msgType uint8) []qtls.Extension {
+func (h *extensionHandler) GetExtensions(msgType uint8) []TLSExtension {
 	if (h.perspective == protocol.PerspectiveClient && messageType(msgType) != typeClientHello) ||
 		(h.perspective == protocol.PerspectiveServer && messageType(msgType) != typeEncryptedExtensions) {
 		return nil
 	}
-	return []qtls.Extension{{
+	return []TLSExtension{{
 		Type: h.extensionType,
 		Data: h.ourParams,
 	}}
 }
 
-func (h *extensionHandler) ReceivedExtensions(msgType uint8, exts []qtls.Extension) {
+func (h *extensionHandler) ReceivedExtensions(msgType uint8, exts []TLSExtension) {
 	if (h.perspective == protocol.PerspectiveClient && messageType(msgType) != typeEncryptedExtensions) ||
 		(h.perspective == protocol.PerspectiveServer && messageType(msgType) != typeClientHello) {
 		return
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
-	"github.com/lucas-clemente/quic-go/internal/qtls"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -66,7 +65,7 @@ var _ = Describe("TLS Extension Handler, for the server", func() {
 		}
 
 		Context("receiving", func() {
-			var chExts []qtls.Extension
+			var chExts []TLSExtension
 
 			JustBeforeEach(func() {
 				chExts = handlerClient.GetExtensions(uint8(typeClientHello))
@@ -98,7 +97,7 @@ var _ = Describe("TLS Extension Handler, for the server", func() {
 			It("ignores extensions with different code points", func() {
 				go func() {
 					defer GinkgoRecover()
-					exts := []qtls.Extension{{Type: 0x1337, Data: []byte("invalid")}}
+					exts := []TLSExtension{{Type: 0x1337, Data: []byte("invalid")}}
 					handlerServer.ReceivedExtensions(uint8(typeClientHello), exts)
 				}()
 
@@ -153,7 +152,7 @@ var _ = Describe("TLS Extension Handler, for the server", func() {
 		}
 
 		Context("receiving", func() {
-			var chExts []qtls.Extension
+			var chExts []TLSExtension
 
 			JustBeforeEach(func() {
 				chExts = handlerServer.GetExtensions(uint8(typeEncryptedExtensions))
@@ -185,7 +184,7 @@ var _ = Describe("TLS Extension Handler, for the server", func() {
 			It("ignores extensions with different code points", func() {
 				go func() {
 					defer GinkgoRecover()
-					exts := []qtls.Extension{{Type: 0x1337, Data: []byte("invalid")}}
+					exts := []TLSExtension{{Type: 0x1337, Data: []byte("invalid")}}
 					handlerClient.ReceivedExtensions(uint8(typeEncryptedExtensions), exts)
 				}()
 
@@ -50,6 +50,13 @@ func NewTokenGenerator(rand io.Reader) (*TokenGenerator, error) {
 	}, nil
 }
 
+// NewTokenGeneratorWithKeys initializes a new TokenGenerator using the given keys.
+// New tokens are protected using the first key.
+// Tokens protected using any of the keys can be decoded.
+func NewTokenGeneratorWithKeys(rand io.Reader, keys [][]byte) *TokenGenerator {
+	return &TokenGenerator{tokenProtector: newTokenProtectorWithSecrets(rand, keys)}
+}
+
 // NewRetryToken generates a new token for a Retry for a given source address
 func (g *TokenGenerator) NewRetryToken(
 	raddr net.Addr,
@@ -25,8 +25,8 @@ const (
 
 // tokenProtector is used to create and verify a token
 type tokenProtectorImpl struct {
-	rand   io.Reader
-	secret []byte
+	rand    io.Reader
+	secrets [][]byte // new tokens are protected using the first secret
 }
 
 // newTokenProtector creates a source for source address tokens
@@ -35,10 +35,17 @@ func newTokenProtector(rand io.Reader) (tokenProtector, error) {
 	if _, err := rand.Read(secret); err != nil {
 		return nil, err
 	}
+	return newTokenProtectorWithSecrets(rand, [][]byte{secret}), nil
+}
+
+// newTokenProtectorWithSecrets creates a source for source address tokens using the given secrets.
+// New tokens are protected using the first secret.
+// Tokens protected using any of the secrets can be decoded.
+func newTokenProtectorWithSecrets(rand io.Reader, secrets [][]byte) tokenProtector {
 	return &tokenProtectorImpl{
-		rand:   rand,
-		secret: secret,
-	}, nil
+		rand:    rand,
+		secrets: secrets,
+	}
 }
 
 // NewToken encodes data into a new token.
@@ -47,7 +54,7 @@ func (s *tokenProtectorImpl) NewToken(data []byte) ([]byte, error) {
 	if _, err := s.rand.Read(nonce); err != nil {
 		return nil, err
 	}
-	aead, aeadNonce, err := s.createAEAD(nonce)
+	aead, aeadNonce, err := s.createAEAD(s.secrets[0], nonce)
 	if err != nil {
 		return nil, err
 	}
@@ -60,15 +67,25 @@ func (s *tokenProtectorImpl) DecodeToken(p []byte) ([]byte, error) {
 		return nil, fmt.Errorf("token too short: %d", len(p))
 	}
 	nonce := p[:tokenNonceSize]
-	aead, aeadNonce, err := s.createAEAD(nonce)
-	if err != nil {
-		return nil, err
+	var err error
+	for _, secret := range s.secrets {
+		var aead cipher.AEAD
+		var aeadNonce []byte
+		aead, aeadNonce, err = s.createAEAD(secret, nonce)
+		if err != nil {
+			return nil, err
+		}
+		var data []byte
+		data, err = aead.Open(nil, aeadNonce, p[tokenNonceSize:], nil)
+		if err == nil {
+			return data, nil
+		}
 	}
-	return aead.Open(nil, aeadNonce, p[tokenNonceSize:], nil)
+	return nil, err
 }
 
-func (s *tokenProtectorImpl) createAEAD(nonce []byte) (cipher.AEAD, []byte, error) {
-	h := hkdf.New(sha256.New, s.secret, nonce, []byte("quic-go token source"))
+func (s *tokenProtectorImpl) createAEAD(secret, nonce []byte) (cipher.AEAD, []byte, error) {
+	h := hkdf.New(sha256.New, secret, nonce, []byte("quic-go token source"))
 	key := make([]byte, 32) // use a 32 byte key, in order to select AES-256
 	if _, err := io.ReadFull(h, key); err != nil {
 		return nil, nil, err
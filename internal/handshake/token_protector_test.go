@@ -1,6 +1,7 @@
 package handshake
 
 import (
+	"bytes"
 	"crypto/rand"
 
 	. "github.com/onsi/ginkgo"
@@ -60,6 +61,26 @@ var _ = Describe("Token Protector", func() {
 		Expect(err.Error()).To(ContainSubstring("message authentication failed"))
 	})
 
+	It("decodes tokens protected using any of the secrets", func() {
+		oldSecret := bytes.Repeat([]byte{1}, tokenSecretSize)
+		newSecret := bytes.Repeat([]byte{2}, tokenSecretSize)
+		oldTP := newTokenProtectorWithSecrets(rand.Reader, [][]byte{oldSecret})
+		rotatedTP := newTokenProtectorWithSecrets(rand.Reader, [][]byte{newSecret, oldSecret})
+		token, err := oldTP.NewToken([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		decoded, err := rotatedTP.DecodeToken(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded).To(Equal([]byte("foobar")))
+		// new tokens are protected using the first secret
+		token, err = rotatedTP.NewToken([]byte("raboof"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = oldTP.DecodeToken(token)
+		Expect(err).To(HaveOccurred())
+		decoded, err = newTokenProtectorWithSecrets(rand.Reader, [][]byte{newSecret}).DecodeToken(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded).To(Equal([]byte("raboof")))
+	})
+
 	It("errors when decoding too short tokens", func() {
 		_, err := tp.DecodeToken([]byte("foobar"))
 		Expect(err).To(MatchError("token too short: 6"))
@@ -6,11 +6,11 @@ import (
 	"crypto/tls"
 	"encoding/binary"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
-	"github.com/lucas-clemente/quic-go/internal/qtls"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/logging"
 )
@@ -20,7 +20,7 @@ import (
 var KeyUpdateInterval uint64 = protocol.KeyUpdateInterval
 
 type updatableAEAD struct {
-	suite *qtls.CipherSuiteTLS13
+	suite *CipherSuite
 
 	keyPhase           protocol.KeyPhase
 	largestAcked       protocol.PacketNumber
@@ -31,6 +31,9 @@ type updatableAEAD struct {
 	invalidPacketLimit uint64
 	invalidPacketCount uint64
 
+	keyUpdateRequested uint32 // accessed atomically
+	currentKeyPhase    uint64 // accessed atomically, always equal to keyPhase
+
 	// Time when the keys should be dropped. Keys are dropped on the next call to Open().
 	prevRcvAEADExpiry time.Time
 	prevRcvAEAD       cipher.AEAD
@@ -58,10 +61,22 @@ type updatableAEAD struct {
 	tracer logging.ConnectionTracer
 	logger utils.Logger
 
+	// the traffic secrets are needed to derive the AEADs used on additional paths
+	rcvTrafficSecret     []byte
+	prevRcvTrafficSecret []byte
+	sendTrafficSecret    []byte
+	pathAEADs            map[uint64]*pathAEADs
+
 	// use a single slice to avoid allocations
 	nonceBuf []byte
 }
 
+// pathAEADs are the AEADs used on an additional path, when using multipath.
+type pathAEADs struct {
+	keyPhase                    protocol.KeyPhase
+	send, rcv, prevRcv, nextRcv cipher.AEAD
+}
+
 var (
 	_ ShortHeaderOpener = &updatableAEAD{}
 	_ ShortHeaderSealer = &updatableAEAD{}
@@ -90,6 +105,8 @@ func (a *updatableAEAD) rollKeys() {
 	}
 
 	a.keyPhase++
+	atomic.StoreUint64(&a.currentKeyPhase, uint64(a.keyPhase))
+	atomic.StoreUint32(&a.keyUpdateRequested, 0)
 	a.firstRcvdWithCurrentKey = protocol.InvalidPacketNumber
 	a.firstSentWithCurrentKey = protocol.InvalidPacketNumber
 	a.numRcvdWithCurrentKey = 0
@@ -97,6 +114,9 @@ func (a *updatableAEAD) rollKeys() {
 	a.prevRcvAEAD = a.rcvAEAD
 	a.rcvAEAD = a.nextRcvAEAD
 	a.sendAEAD = a.nextSendAEAD
+	a.prevRcvTrafficSecret = a.rcvTrafficSecret
+	a.rcvTrafficSecret = a.nextRcvTrafficSecret
+	a.sendTrafficSecret = a.nextSendTrafficSecret
 
 	a.nextRcvTrafficSecret = a.getNextTrafficSecret(a.suite.Hash, a.nextRcvTrafficSecret)
 	a.nextSendTrafficSecret = a.getNextTrafficSecret(a.suite.Hash, a.nextSendTrafficSecret)
@@ -116,8 +136,9 @@ func (a *updatableAEAD) getNextTrafficSecret(hash crypto.Hash, ts []byte) []byte
 
 // For the client, this function is called before SetWriteKey.
 // For the server, this function is called after SetWriteKey.
-func (a *updatableAEAD) SetReadKey(suite *qtls.CipherSuiteTLS13, trafficSecret []byte) {
+func (a *updatableAEAD) SetReadKey(suite *CipherSuite, trafficSecret []byte) {
 	a.rcvAEAD = createAEAD(suite, trafficSecret)
+	a.rcvTrafficSecret = trafficSecret
 	a.headerDecrypter = newHeaderProtector(suite, trafficSecret, false)
 	if a.suite == nil {
 		a.setAEADParameters(a.rcvAEAD, suite)
@@ -129,8 +150,9 @@ func (a *updatableAEAD) SetReadKey(suite *qtls.CipherSuiteTLS13, trafficSecret [
 
 // For the client, this function is called after SetReadKey.
 // For the server, this function is called before SetWriteKey.
-func (a *updatableAEAD) SetWriteKey(suite *qtls.CipherSuiteTLS13, trafficSecret []byte) {
+func (a *updatableAEAD) SetWriteKey(suite *CipherSuite, trafficSecret []byte) {
 	a.sendAEAD = createAEAD(suite, trafficSecret)
+	a.sendTrafficSecret = trafficSecret
 	a.headerEncrypter = newHeaderProtector(suite, trafficSecret, false)
 	if a.suite == nil {
 		a.setAEADParameters(a.sendAEAD, suite)
@@ -140,7 +162,7 @@ func (a *updatableAEAD) SetWriteKey(suite *qtls.CipherSuiteTLS13, trafficSecret
 	a.nextSendAEAD = createAEAD(suite, a.nextSendTrafficSecret)
 }
 
-func (a *updatableAEAD) setAEADParameters(aead cipher.AEAD, suite *qtls.CipherSuiteTLS13) {
+func (a *updatableAEAD) setAEADParameters(aead cipher.AEAD, suite *CipherSuite) {
 	a.nonceBuf = make([]byte, aead.NonceSize())
 	a.aeadOverhead = aead.Overhead()
 	a.suite = suite
@@ -250,6 +272,89 @@ func (a *updatableAEAD) Seal(dst, src []byte, pn protocol.PacketNumber, ad []byt
 	return a.sendAEAD.Seal(dst, a.nonceBuf, src, ad)
 }
 
+// SealOnPath seals a packet sent on an additional path, when using multipath.
+// Every path uses its own packet number space. To guarantee that nonces are unique,
+// the path ID is encoded in the first 32 bits of the nonce.
+// Packets sent on additional paths are not taken into account for key update bookkeeping,
+// since their packet numbers can't be compared to the packet numbers on the initial path.
+func (a *updatableAEAD) SealOnPath(dst, src []byte, pathID uint64, pn protocol.PacketNumber, ad []byte) []byte {
+	a.numSentWithCurrentKey++
+	binary.BigEndian.PutUint64(a.nonceBuf[len(a.nonceBuf)-8:], uint64(pn))
+	return a.getPathAEADs(pathID).send.Seal(dst, a.nonceBuf, src, ad)
+}
+
+// OpenOnPath opens a packet received on an additional path, when using multipath.
+// See SealOnPath for details.
+func (a *updatableAEAD) OpenOnPath(dst, src []byte, rcvTime time.Time, pathID uint64, pn protocol.PacketNumber, kp protocol.KeyPhaseBit, ad []byte) ([]byte, error) {
+	dec, err := a.openOnPath(dst, src, rcvTime, pathID, pn, kp, ad)
+	if err == ErrDecryptionFailed {
+		a.invalidPacketCount++
+		if a.invalidPacketCount >= a.invalidPacketLimit {
+			return nil, &qerr.TransportError{ErrorCode: qerr.AEADLimitReached}
+		}
+	}
+	return dec, err
+}
+
+func (a *updatableAEAD) openOnPath(dst, src []byte, rcvTime time.Time, pathID uint64, pn protocol.PacketNumber, kp protocol.KeyPhaseBit, ad []byte) ([]byte, error) {
+	binary.BigEndian.PutUint64(a.nonceBuf[len(a.nonceBuf)-8:], uint64(pn))
+	aeads := a.getPathAEADs(pathID)
+	if kp == a.keyPhase.Bit() {
+		dec, err := aeads.rcv.Open(dst, a.nonceBuf, src, ad)
+		if err != nil {
+			return nil, ErrDecryptionFailed
+		}
+		a.numRcvdWithCurrentKey++
+		return dec, nil
+	}
+	// The packet was either sent with the previous key phase (and reordered),
+	// or the peer initiated a key update.
+	if a.prevRcvAEAD != nil && aeads.prevRcv != nil {
+		if dec, err := aeads.prevRcv.Open(dst, a.nonceBuf, src, ad); err == nil {
+			return dec, nil
+		}
+	}
+	dec, err := aeads.nextRcv.Open(dst, a.nonceBuf, src, ad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	if a.keyPhase > 0 && a.firstSentWithCurrentKey == protocol.InvalidPacketNumber {
+		return nil, &qerr.TransportError{
+			ErrorCode:    qerr.KeyUpdateError,
+			ErrorMessage: "keys updated too quickly",
+		}
+	}
+	a.rollKeys()
+	a.logger.Debugf("Peer updated keys to %d", a.keyPhase)
+	a.startKeyDropTimer(rcvTime)
+	if a.tracer != nil {
+		a.tracer.UpdatedKey(a.keyPhase, true)
+	}
+	return dec, nil
+}
+
+// getPathAEADs returns the AEADs used on a path.
+// They are derived from the traffic secrets of the current key phase.
+func (a *updatableAEAD) getPathAEADs(pathID uint64) *pathAEADs {
+	if aeads, ok := a.pathAEADs[pathID]; ok && aeads.keyPhase == a.keyPhase {
+		return aeads
+	}
+	if a.pathAEADs == nil {
+		a.pathAEADs = make(map[uint64]*pathAEADs)
+	}
+	aeads := &pathAEADs{
+		keyPhase: a.keyPhase,
+		send:     createPathAEAD(a.suite, a.sendTrafficSecret, pathID),
+		rcv:      createPathAEAD(a.suite, a.rcvTrafficSecret, pathID),
+		nextRcv:  createPathAEAD(a.suite, a.nextRcvTrafficSecret, pathID),
+	}
+	if a.prevRcvTrafficSecret != nil {
+		aeads.prevRcv = createPathAEAD(a.suite, a.prevRcvTrafficSecret, pathID)
+	}
+	a.pathAEADs[pathID] = aeads
+	return aeads
+}
+
 func (a *updatableAEAD) SetLargestAcked(pn protocol.PacketNumber) error {
 	if a.firstSentWithCurrentKey != protocol.InvalidPacketNumber &&
 		pn >= a.firstSentWithCurrentKey && a.numRcvdWithCurrentKey == 0 {
@@ -282,6 +387,10 @@ func (a *updatableAEAD) shouldInitiateKeyUpdate() bool {
 	if !a.updateAllowed() {
 		return false
 	}
+	if atomic.LoadUint32(&a.keyUpdateRequested) == 1 {
+		a.logger.Debugf("Key update requested. Initiating key update to the next key phase: %d", a.keyPhase+1)
+		return true
+	}
 	if a.numRcvdWithCurrentKey >= a.keyUpdateInterval {
 		a.logger.Debugf("Received %d packets with current key phase. Initiating key update to the next key phase: %d", a.numRcvdWithCurrentKey, a.keyPhase+1)
 		return true
@@ -293,6 +402,19 @@ func (a *updatableAEAD) shouldInitiateKeyUpdate() bool {
 	return false
 }
 
+// RequestKeyUpdate requests a key update.
+// The key update is initiated when the next packet is sent, as soon as a key update is allowed.
+// It is safe to call this function from any go routine.
+func (a *updatableAEAD) RequestKeyUpdate() {
+	atomic.StoreUint32(&a.keyUpdateRequested, 1)
+}
+
+// CurrentKeyPhase returns the current key phase.
+// It is safe to call this function from any go routine.
+func (a *updatableAEAD) CurrentKeyPhase() protocol.KeyPhase {
+	return protocol.KeyPhase(atomic.LoadUint64(&a.currentKeyPhase))
+}
+
 func (a *updatableAEAD) KeyPhase() protocol.KeyPhaseBit {
 	if a.shouldInitiateKeyUpdate() {
 		a.rollKeys()
@@ -142,6 +142,63 @@ var _ = Describe("Updatable AEAD", func() {
 					Expect(err.(*qerr.TransportError).ErrorCode).To(Equal(qerr.AEADLimitReached))
 				})
 
+				Context("multipath", func() {
+					It("encrypts and decrypts a message on a path", func() {
+						encrypted := server.SealOnPath(nil, msg, 3, 0x1337, ad)
+						opened, err := client.OpenOnPath(nil, encrypted, time.Now(), 3, 0x1337, protocol.KeyPhaseZero, ad)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(opened).To(Equal(msg))
+					})
+
+					It("uses different nonces on different paths", func() {
+						encrypted := server.SealOnPath(nil, msg, 3, 0x1337, ad)
+						Expect(encrypted).ToNot(Equal(server.Seal(nil, msg, 0x1337, ad)))
+						_, err := client.OpenOnPath(nil, encrypted, time.Now(), 4, 0x1337, protocol.KeyPhaseZero, ad)
+						Expect(err).To(MatchError(ErrDecryptionFailed))
+						_, err = client.Open(nil, encrypted, time.Now(), 0x1337, protocol.KeyPhaseZero, ad)
+						Expect(err).To(MatchError(ErrDecryptionFailed))
+					})
+
+					It("uses the same nonce as Seal for path 0", func() {
+						encrypted := server.SealOnPath(nil, msg, 0, 0x1337, ad)
+						Expect(encrypted).To(Equal(server.Seal(nil, msg, 0x1337, ad)))
+					})
+
+					It("doesn't use packets received on other paths for packet number derivation", func() {
+						encrypted := server.SealOnPath(nil, msg, 1, 0x1337, ad)
+						_, err := client.OpenOnPath(nil, encrypted, time.Now(), 1, 0x1337, protocol.KeyPhaseZero, ad)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.DecodePacketNumber(0x38, protocol.PacketNumberLen1)).To(BeEquivalentTo(0x38))
+					})
+
+					It("updates the keys when receiving a packet with the next key phase on a path", func() {
+						now := time.Now()
+						_ = server.Seal(nil, msg, 0x1, ad)
+						client.rollKeys()
+						encrypted1 := client.SealOnPath(nil, msg, 1, 0x43, ad)
+						serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(1), true)
+						decrypted, err := server.OpenOnPath(nil, encrypted1, now, 1, 0x43, protocol.KeyPhaseOne, ad)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(decrypted).To(Equal(msg))
+						Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+					})
+
+					It("opens a reordered packet on a path with the old keys after an update", func() {
+						now := time.Now()
+						encrypted0 := client.SealOnPath(nil, msg, 1, 0x42, ad)
+						_ = server.Seal(nil, msg, 0x1, ad)
+						client.rollKeys()
+						encrypted1 := client.Seal(nil, msg, 0x44, ad)
+						serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(1), true)
+						_, err := server.Open(nil, encrypted1, now, 0x44, protocol.KeyPhaseOne, ad)
+						Expect(err).ToNot(HaveOccurred())
+						decrypted, err := server.OpenOnPath(nil, encrypted0, now, 1, 0x42, protocol.KeyPhaseZero, ad)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(decrypted).To(Equal(msg))
+						Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+					})
+				})
+
 				Context("key updates", func() {
 					Context("receiving key updates", func() {
 						It("updates keys", func() {
@@ -285,6 +342,34 @@ var _ = Describe("Updatable AEAD", func() {
 							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
 						})
 
+						It("initiates a key update when requested", func() {
+							Expect(server.CurrentKeyPhase()).To(BeZero())
+							server.RequestKeyUpdate()
+							serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(1), false)
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+							Expect(server.CurrentKeyPhase()).To(Equal(protocol.KeyPhase(1)))
+							// the request was fulfilled, so the next call doesn't update the keys again
+							server.Seal(nil, msg, 0, ad)
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+						})
+
+						It("delays a requested key update until the previous update was acknowledged", func() {
+							server.rollKeys()
+							client.rollKeys()
+							server.Seal(nil, msg, 0, ad)
+							server.RequestKeyUpdate()
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+							// receive an ACK for the packet sent in key phase 1
+							b := client.Seal(nil, []byte("foobar"), 1, []byte("ad"))
+							_, err := server.Open(nil, b, time.Now(), 1, protocol.KeyPhaseOne, []byte("ad"))
+							Expect(err).ToNot(HaveOccurred())
+							Expect(server.SetLargestAcked(0)).To(Succeed())
+							serverTracer.EXPECT().DroppedKey(protocol.KeyPhase(0))
+							serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(2), false)
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseZero))
+							Expect(server.CurrentKeyPhase()).To(Equal(protocol.KeyPhase(2)))
+						})
+
 						It("initiates a key update after sealing the maximum number of packets, for subsequent updates", func() {
 							server.rollKeys()
 							client.rollKeys()
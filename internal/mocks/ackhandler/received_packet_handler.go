@@ -90,6 +90,30 @@ func (mr *MockReceivedPacketHandlerMockRecorder) IsPotentiallyDuplicate(arg0, ar
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsPotentiallyDuplicate", reflect.TypeOf((*MockReceivedPacketHandler)(nil).IsPotentiallyDuplicate), arg0, arg1)
 }
 
+// ReceivedAckFrequencyFrame mocks base method.
+func (m *MockReceivedPacketHandler) ReceivedAckFrequencyFrame(arg0 *wire.AckFrequencyFrame) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReceivedAckFrequencyFrame", arg0)
+}
+
+// ReceivedAckFrequencyFrame indicates an expected call of ReceivedAckFrequencyFrame.
+func (mr *MockReceivedPacketHandlerMockRecorder) ReceivedAckFrequencyFrame(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceivedAckFrequencyFrame", reflect.TypeOf((*MockReceivedPacketHandler)(nil).ReceivedAckFrequencyFrame), arg0)
+}
+
+// ReceivedImmediateAckFrame mocks base method.
+func (m *MockReceivedPacketHandler) ReceivedImmediateAckFrame() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReceivedImmediateAckFrame")
+}
+
+// ReceivedImmediateAckFrame indicates an expected call of ReceivedImmediateAckFrame.
+func (mr *MockReceivedPacketHandlerMockRecorder) ReceivedImmediateAckFrame() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceivedImmediateAckFrame", reflect.TypeOf((*MockReceivedPacketHandler)(nil).ReceivedImmediateAckFrame))
+}
+
 // ReceivedPacket mocks base method.
 func (m *MockReceivedPacketHandler) ReceivedPacket(arg0 protocol.PacketNumber, arg1 protocol.ECN, arg2 protocol.EncryptionLevel, arg3 time.Time, arg4 bool) error {
 	m.ctrl.T.Helper()
@@ -49,6 +49,32 @@ func (mr *MockSentPacketHandlerMockRecorder) DropPackets(arg0 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropPackets", reflect.TypeOf((*MockSentPacketHandler)(nil).DropPackets), arg0)
 }
 
+// EnableAckFrequency mocks base method.
+func (m *MockSentPacketHandler) EnableAckFrequency(arg0 time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "EnableAckFrequency", arg0)
+}
+
+// EnableAckFrequency indicates an expected call of EnableAckFrequency.
+func (mr *MockSentPacketHandlerMockRecorder) EnableAckFrequency(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableAckFrequency", reflect.TypeOf((*MockSentPacketHandler)(nil).EnableAckFrequency), arg0)
+}
+
+// GetAckFrequencyFrame mocks base method.
+func (m *MockSentPacketHandler) GetAckFrequencyFrame(arg0 time.Time) *wire.AckFrequencyFrame {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAckFrequencyFrame", arg0)
+	ret0, _ := ret[0].(*wire.AckFrequencyFrame)
+	return ret0
+}
+
+// GetAckFrequencyFrame indicates an expected call of GetAckFrequencyFrame.
+func (mr *MockSentPacketHandlerMockRecorder) GetAckFrequencyFrame(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAckFrequencyFrame", reflect.TypeOf((*MockSentPacketHandler)(nil).GetAckFrequencyFrame), arg0)
+}
+
 // GetLossDetectionTimeout mocks base method.
 func (m *MockSentPacketHandler) GetLossDetectionTimeout() time.Time {
 	m.ctrl.T.Helper()
@@ -63,6 +89,20 @@ func (mr *MockSentPacketHandlerMockRecorder) GetLossDetectionTimeout() *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLossDetectionTimeout", reflect.TypeOf((*MockSentPacketHandler)(nil).GetLossDetectionTimeout))
 }
 
+// GetStats mocks base method.
+func (m *MockSentPacketHandler) GetStats() ackhandler.SentPacketStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStats")
+	ret0, _ := ret[0].(ackhandler.SentPacketStats)
+	return ret0
+}
+
+// GetStats indicates an expected call of GetStats.
+func (mr *MockSentPacketHandlerMockRecorder) GetStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockSentPacketHandler)(nil).GetStats))
+}
+
 // HasPacingBudget mocks base method.
 func (m *MockSentPacketHandler) HasPacingBudget() bool {
 	m.ctrl.T.Helper()
@@ -77,6 +117,18 @@ func (mr *MockSentPacketHandlerMockRecorder) HasPacingBudget() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasPacingBudget", reflect.TypeOf((*MockSentPacketHandler)(nil).HasPacingBudget))
 }
 
+// MigratedPath mocks base method.
+func (m *MockSentPacketHandler) MigratedPath(arg0 time.Time, arg1 time.Duration, arg2 protocol.ByteCount) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "MigratedPath", arg0, arg1, arg2)
+}
+
+// MigratedPath indicates an expected call of MigratedPath.
+func (mr *MockSentPacketHandlerMockRecorder) MigratedPath(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigratedPath", reflect.TypeOf((*MockSentPacketHandler)(nil).MigratedPath), arg0, arg1, arg2)
+}
+
 // OnLossDetectionTimeout mocks base method.
 func (m *MockSentPacketHandler) OnLossDetectionTimeout() error {
 	m.ctrl.T.Helper()
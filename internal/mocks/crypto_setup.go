@@ -76,6 +76,20 @@ func (mr *MockCryptoSetupMockRecorder) ConnectionState() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnectionState", reflect.TypeOf((*MockCryptoSetup)(nil).ConnectionState))
 }
 
+// CurrentKeyPhase mocks base method.
+func (m *MockCryptoSetup) CurrentKeyPhase() protocol.KeyPhase {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentKeyPhase")
+	ret0, _ := ret[0].(protocol.KeyPhase)
+	return ret0
+}
+
+// CurrentKeyPhase indicates an expected call of CurrentKeyPhase.
+func (mr *MockCryptoSetupMockRecorder) CurrentKeyPhase() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentKeyPhase", reflect.TypeOf((*MockCryptoSetup)(nil).CurrentKeyPhase))
+}
+
 // Get0RTTOpener mocks base method.
 func (m *MockCryptoSetup) Get0RTTOpener() (handshake.LongHeaderOpener, error) {
 	m.ctrl.T.Helper()
@@ -225,6 +239,18 @@ func (mr *MockCryptoSetupMockRecorder) HandleMessage(arg0, arg1 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleMessage", reflect.TypeOf((*MockCryptoSetup)(nil).HandleMessage), arg0, arg1)
 }
 
+// RequestKeyUpdate mocks base method.
+func (m *MockCryptoSetup) RequestKeyUpdate() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RequestKeyUpdate")
+}
+
+// RequestKeyUpdate indicates an expected call of RequestKeyUpdate.
+func (mr *MockCryptoSetupMockRecorder) RequestKeyUpdate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestKeyUpdate", reflect.TypeOf((*MockCryptoSetup)(nil).RequestKeyUpdate))
+}
+
 // RunHandshake mocks base method.
 func (m *MockCryptoSetup) RunHandshake() {
 	m.ctrl.T.Helper()
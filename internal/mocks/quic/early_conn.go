@@ -8,6 +8,7 @@ import (
 	context "context"
 	net "net"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	quic "github.com/lucas-clemente/quic-go"
@@ -67,6 +68,21 @@ func (mr *MockEarlyConnectionMockRecorder) AcceptUniStream(arg0 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptUniStream", reflect.TypeOf((*MockEarlyConnection)(nil).AcceptUniStream), arg0)
 }
 
+// AddPath mocks base method.
+func (m *MockEarlyConnection) AddPath(arg0 context.Context, arg1 net.PacketConn) (quic.PathID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPath", arg0, arg1)
+	ret0, _ := ret[0].(quic.PathID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPath indicates an expected call of AddPath.
+func (mr *MockEarlyConnectionMockRecorder) AddPath(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPath", reflect.TypeOf((*MockEarlyConnection)(nil).AddPath), arg0, arg1)
+}
+
 // CloseWithError mocks base method.
 func (m *MockEarlyConnection) CloseWithError(arg0 qerr.ApplicationErrorCode, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -137,6 +153,20 @@ func (mr *MockEarlyConnectionMockRecorder) LocalAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LocalAddr", reflect.TypeOf((*MockEarlyConnection)(nil).LocalAddr))
 }
 
+// Migrate mocks base method.
+func (m *MockEarlyConnection) Migrate(arg0 context.Context, arg1 net.PacketConn) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Migrate", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Migrate indicates an expected call of Migrate.
+func (mr *MockEarlyConnectionMockRecorder) Migrate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Migrate", reflect.TypeOf((*MockEarlyConnection)(nil).Migrate), arg0, arg1)
+}
+
 // NextConnection mocks base method.
 func (m *MockEarlyConnection) NextConnection() quic.Connection {
 	m.ctrl.T.Helper()
@@ -211,6 +241,21 @@ func (mr *MockEarlyConnectionMockRecorder) OpenUniStreamSync(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenUniStreamSync", reflect.TypeOf((*MockEarlyConnection)(nil).OpenUniStreamSync), arg0)
 }
 
+// ProbePath mocks base method.
+func (m *MockEarlyConnection) ProbePath(arg0 context.Context, arg1 net.PacketConn) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProbePath", arg0, arg1)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProbePath indicates an expected call of ProbePath.
+func (mr *MockEarlyConnectionMockRecorder) ProbePath(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProbePath", reflect.TypeOf((*MockEarlyConnection)(nil).ProbePath), arg0, arg1)
+}
+
 // ReceiveMessage mocks base method.
 func (m *MockEarlyConnection) ReceiveMessage() ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -240,6 +285,20 @@ func (mr *MockEarlyConnectionMockRecorder) RemoteAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteAddr", reflect.TypeOf((*MockEarlyConnection)(nil).RemoteAddr))
 }
 
+// RemovePath mocks base method.
+func (m *MockEarlyConnection) RemovePath(arg0 quic.PathID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePath", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemovePath indicates an expected call of RemovePath.
+func (mr *MockEarlyConnectionMockRecorder) RemovePath(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePath", reflect.TypeOf((*MockEarlyConnection)(nil).RemovePath), arg0)
+}
+
 // SendMessage mocks base method.
 func (m *MockEarlyConnection) SendMessage(arg0 []byte) error {
 	m.ctrl.T.Helper()
@@ -253,3 +312,45 @@ func (mr *MockEarlyConnectionMockRecorder) SendMessage(arg0 interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockEarlyConnection)(nil).SendMessage), arg0)
 }
+
+// SendMessageWithCallback mocks base method.
+func (m *MockEarlyConnection) SendMessageWithCallback(arg0 []byte, arg1 func(bool)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessageWithCallback", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMessageWithCallback indicates an expected call of SendMessageWithCallback.
+func (mr *MockEarlyConnectionMockRecorder) SendMessageWithCallback(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessageWithCallback", reflect.TypeOf((*MockEarlyConnection)(nil).SendMessageWithCallback), arg0, arg1)
+}
+
+// Stats mocks base method.
+func (m *MockEarlyConnection) Stats() quic.ConnectionStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(quic.ConnectionStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockEarlyConnectionMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockEarlyConnection)(nil).Stats))
+}
+
+// UpdateKeys mocks base method.
+func (m *MockEarlyConnection) UpdateKeys() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateKeys")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateKeys indicates an expected call of UpdateKeys.
+func (mr *MockEarlyConnectionMockRecorder) UpdateKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKeys", reflect.TypeOf((*MockEarlyConnection)(nil).UpdateKeys))
+}
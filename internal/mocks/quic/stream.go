@@ -6,10 +6,12 @@ package mockquic
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	quic "github.com/lucas-clemente/quic-go"
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
 	qerr "github.com/lucas-clemente/quic-go/internal/qerr"
 )
@@ -37,6 +39,20 @@ func (m *MockStream) EXPECT() *MockStreamMockRecorder {
 	return m.recorder
 }
 
+// Buffered mocks base method.
+func (m *MockStream) Buffered() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Buffered")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Buffered indicates an expected call of Buffered.
+func (mr *MockStreamMockRecorder) Buffered() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Buffered", reflect.TypeOf((*MockStream)(nil).Buffered))
+}
+
 // CancelRead mocks base method.
 func (m *MockStream) CancelRead(arg0 qerr.StreamErrorCode) {
 	m.ctrl.T.Helper()
@@ -89,6 +105,21 @@ func (mr *MockStreamMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockStream)(nil).Context))
 }
 
+// Peek mocks base method.
+func (m *MockStream) Peek(arg0 int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockStreamMockRecorder) Peek(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockStream)(nil).Peek), arg0)
+}
+
 // Read mocks base method.
 func (m *MockStream) Read(arg0 []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -104,6 +135,33 @@ func (mr *MockStreamMockRecorder) Read(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockStream)(nil).Read), arg0)
 }
 
+// ReadFrom mocks base method.
+func (m *MockStream) ReadFrom(arg0 io.Reader) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadFrom", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadFrom indicates an expected call of ReadFrom.
+func (mr *MockStreamMockRecorder) ReadFrom(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFrom", reflect.TypeOf((*MockStream)(nil).ReadFrom), arg0)
+}
+
+// SetDataExpiry mocks base method.
+func (m *MockStream) SetDataExpiry(arg0 time.Duration, arg1 protocol.ByteCount) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetDataExpiry", arg0, arg1)
+}
+
+// SetDataExpiry indicates an expected call of SetDataExpiry.
+func (mr *MockStreamMockRecorder) SetDataExpiry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDataExpiry", reflect.TypeOf((*MockStream)(nil).SetDataExpiry), arg0, arg1)
+}
+
 // SetDeadline mocks base method.
 func (m *MockStream) SetDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -118,6 +176,18 @@ func (mr *MockStreamMockRecorder) SetDeadline(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDeadline", reflect.TypeOf((*MockStream)(nil).SetDeadline), arg0)
 }
 
+// SetPriority mocks base method.
+func (m *MockStream) SetPriority(arg0 quic.StreamPriority) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPriority", arg0)
+}
+
+// SetPriority indicates an expected call of SetPriority.
+func (mr *MockStreamMockRecorder) SetPriority(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPriority", reflect.TypeOf((*MockStream)(nil).SetPriority), arg0)
+}
+
 // SetReadDeadline mocks base method.
 func (m *MockStream) SetReadDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -132,6 +202,18 @@ func (mr *MockStreamMockRecorder) SetReadDeadline(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReadDeadline", reflect.TypeOf((*MockStream)(nil).SetReadDeadline), arg0)
 }
 
+// SetReliableBoundary mocks base method.
+func (m *MockStream) SetReliableBoundary() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetReliableBoundary")
+}
+
+// SetReliableBoundary indicates an expected call of SetReliableBoundary.
+func (mr *MockStreamMockRecorder) SetReliableBoundary() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReliableBoundary", reflect.TypeOf((*MockStream)(nil).SetReliableBoundary))
+}
+
 // SetWriteDeadline mocks base method.
 func (m *MockStream) SetWriteDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -174,3 +256,52 @@ func (mr *MockStreamMockRecorder) Write(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockStream)(nil).Write), arg0)
 }
+
+// WriteOwned mocks base method.
+func (m *MockStream) WriteOwned(arg0 ...[]byte) (int, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range arg0 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WriteOwned", varargs...)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteOwned indicates an expected call of WriteOwned.
+func (mr *MockStreamMockRecorder) WriteOwned(arg0 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOwned", reflect.TypeOf((*MockStream)(nil).WriteOwned), arg0...)
+}
+
+// WriteTo mocks base method.
+func (m *MockStream) WriteTo(arg0 io.Writer) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteTo", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteTo indicates an expected call of WriteTo.
+func (mr *MockStreamMockRecorder) WriteTo(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTo", reflect.TypeOf((*MockStream)(nil).WriteTo), arg0)
+}
+
+// WriteVectored mocks base method.
+func (m *MockStream) WriteVectored(arg0 [][]byte) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteVectored", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteVectored indicates an expected call of WriteVectored.
+func (mr *MockStreamMockRecorder) WriteVectored(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteVectored", reflect.TypeOf((*MockStream)(nil).WriteVectored), arg0)
+}
@@ -137,10 +137,13 @@ const MaxAckFrameSize ByteCount = 1000
 // The size is chosen such that a DATAGRAM frame fits into a QUIC packet.
 const MaxDatagramFrameSize ByteCount = 1220
 
-// DatagramRcvQueueLen is the length of the receive queue for DATAGRAM frames.
+// DatagramRcvQueueLen is the default length of the receive queue for DATAGRAM frames.
 // See https://datatracker.ietf.org/doc/draft-pauly-quic-datagram/.
 const DatagramRcvQueueLen = 128
 
+// DatagramSendQueueLen is the default length of the send queue for DATAGRAM frames.
+const DatagramSendQueueLen = 1
+
 // MaxNumAckRanges is the maximum number of ACK ranges that we send in an ACK frame.
 // It also serves as a limit for the packet history.
 // If at any point we keep track of more ranges, old ranges are discarded.
@@ -155,15 +158,17 @@ const MinPacingDelay = time.Millisecond
 // if no other value is configured.
 const DefaultConnectionIDLength = 4
 
-// MaxActiveConnectionIDs is the number of connection IDs that we're storing.
-const MaxActiveConnectionIDs = 4
+// DefaultActiveConnectionIDLimit is the number of connection IDs that we're storing,
+// if no other value is configured.
+const DefaultActiveConnectionIDLimit = 4
 
 // MaxIssuedConnectionIDs is the maximum number of connection IDs that we're issuing at the same time.
 const MaxIssuedConnectionIDs = 6
 
-// PacketsPerConnectionID is the number of packets we send using one connection ID.
+// DefaultPacketsPerConnectionID is the number of packets we send using one connection ID,
+// if no other value is configured.
 // If the peer provices us with enough new connection IDs, we switch to a new connection ID.
-const PacketsPerConnectionID = 10000
+const DefaultPacketsPerConnectionID = 10000
 
 // AckDelayExponent is the ack delay exponent used when sending ACKs.
 const AckDelayExponent = 3
@@ -175,6 +180,10 @@ const TimerGranularity = time.Millisecond
 // MaxAckDelay is the maximum time by which we delay sending ACKs.
 const MaxAckDelay = 25 * time.Millisecond
 
+// MinAckDelay is the minimum time by which we delay sending ACKs.
+// It is advertised in the min_ack_delay transport parameter, if the ACK frequency extension is enabled.
+const MinAckDelay = TimerGranularity
+
 // MaxAckDelayInclGranularity is the max_ack_delay including the timer granularity.
 // This is the value that should be advertised to the peer.
 const MaxAckDelayInclGranularity = MaxAckDelay + TimerGranularity
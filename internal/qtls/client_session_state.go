@@ -0,0 +1,80 @@
+package qtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+	"unsafe"
+)
+
+// clientSessionState has the same memory layout as qtls' clientSessionState,
+// which qtls converts to and from a tls.ClientSessionState using unsafe.
+type clientSessionState struct {
+	sessionTicket      []uint8
+	vers               uint16
+	cipherSuite        uint16
+	masterSecret       []byte
+	serverCertificates []*x509.Certificate
+	verifiedChains     [][]*x509.Certificate
+	receivedAt         time.Time
+	ocspResponse       []byte
+	scts               [][]byte
+	nonce              []byte
+	useBy              time.Time
+	ageAdd             uint32
+}
+
+// ClientSessionStateFields contains the fields of a tls.ClientSessionState.
+// For TLS 1.3 sessions, the Nonce also contains the data stored by the ExtraConfig.GetAppDataForSessionState callback.
+type ClientSessionStateFields struct {
+	SessionTicket      []byte
+	Version            uint16
+	CipherSuite        uint16
+	MasterSecret       []byte
+	ServerCertificates []*x509.Certificate
+	VerifiedChains     [][]*x509.Certificate
+	ReceivedAt         time.Time
+	OCSPResponse       []byte
+	SCTs               [][]byte
+	Nonce              []byte
+	UseBy              time.Time
+	AgeAdd             uint32
+}
+
+// GetClientSessionStateFields reads the (unexported) fields of a tls.ClientSessionState.
+func GetClientSessionStateFields(s *tls.ClientSessionState) *ClientSessionStateFields {
+	state := (*clientSessionState)(unsafe.Pointer(s))
+	return &ClientSessionStateFields{
+		SessionTicket:      state.sessionTicket,
+		Version:            state.vers,
+		CipherSuite:        state.cipherSuite,
+		MasterSecret:       state.masterSecret,
+		ServerCertificates: state.serverCertificates,
+		VerifiedChains:     state.verifiedChains,
+		ReceivedAt:         state.receivedAt,
+		OCSPResponse:       state.ocspResponse,
+		SCTs:               state.scts,
+		Nonce:              state.nonce,
+		UseBy:              state.useBy,
+		AgeAdd:             state.ageAdd,
+	}
+}
+
+// NewClientSessionState creates a tls.ClientSessionState from its fields.
+func NewClientSessionState(f *ClientSessionStateFields) *tls.ClientSessionState {
+	state := &clientSessionState{
+		sessionTicket:      f.SessionTicket,
+		vers:               f.Version,
+		cipherSuite:        f.CipherSuite,
+		masterSecret:       f.MasterSecret,
+		serverCertificates: f.ServerCertificates,
+		verifiedChains:     f.VerifiedChains,
+		receivedAt:         f.ReceivedAt,
+		ocspResponse:       f.OCSPResponse,
+		scts:               f.SCTs,
+		nonce:              f.Nonce,
+		useBy:              f.UseBy,
+		ageAdd:             f.AgeAdd,
+	}
+	return (*tls.ClientSessionState)(unsafe.Pointer(state))
+}
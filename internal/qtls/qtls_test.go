@@ -2,6 +2,8 @@ package qtls
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -14,4 +16,23 @@ var _ = Describe("qtls wrapper", func() {
 			Expect(cs.ID).To(Equal(id))
 		}
 	})
+
+	It("gets and sets the fields of a tls.ClientSessionState", func() {
+		cert := &x509.Certificate{Raw: []byte("cert")}
+		fields := &ClientSessionStateFields{
+			SessionTicket:      []byte("ticket"),
+			Version:            tls.VersionTLS13,
+			CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+			MasterSecret:       []byte("secret"),
+			ServerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:     [][]*x509.Certificate{{cert}},
+			ReceivedAt:         time.Now(),
+			OCSPResponse:       []byte("ocsp"),
+			SCTs:               [][]byte{[]byte("sct")},
+			Nonce:              []byte("nonce"),
+			UseBy:              time.Now().Add(time.Hour),
+			AgeAdd:             1337,
+		}
+		Expect(GetClientSessionStateFields(NewClientSessionState(fields))).To(Equal(fields))
+	})
 })
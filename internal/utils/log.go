@@ -29,6 +29,8 @@ type Logger interface {
 	SetLogLevel(LogLevel)
 	SetLogTimeFormat(format string)
 	WithPrefix(prefix string) Logger
+	// With returns a Logger that adds the key-value pairs to every message.
+	With(keyvals ...interface{}) Logger
 	Debug() bool
 
 	Errorf(format string, args ...interface{})
@@ -104,6 +106,18 @@ func (l *defaultLogger) WithPrefix(prefix string) Logger {
 	}
 }
 
+// With adds the key-value pairs to the prefix
+func (l *defaultLogger) With(keyvals ...interface{}) Logger {
+	var prefix strings.Builder
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if i > 0 {
+			prefix.WriteByte(' ')
+		}
+		fmt.Fprintf(&prefix, "%v=%v", keyvals[i], keyvals[i+1])
+	}
+	return l.WithPrefix(prefix.String())
+}
+
 // Debug returns true if the log level is LogLevelDebug
 func (l *defaultLogger) Debug() bool {
 	return l.logLevel == LogLevelDebug
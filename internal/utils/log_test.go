@@ -108,6 +108,12 @@ var _ = Describe("Log", func() {
 		Expect(b.String()).To(ContainSubstring("debug"))
 	})
 
+	It("adds key-value pairs", func() {
+		DefaultLogger.SetLogLevel(LogLevelDebug)
+		DefaultLogger.WithPrefix("prefix").With("foo", "bar", "num", 42).Debugf("debug")
+		Expect(b.String()).To(HaveSuffix("prefix foo=bar num=42 debug\n"))
+	})
+
 	Context("reading from env", func() {
 		BeforeEach(func() {
 			Expect(DefaultLogger.(*defaultLogger).logLevel).To(Equal(LogLevelNothing))
@@ -111,7 +111,9 @@ func (r *RTTStats) SetInitialRTT(t time.Duration) {
 }
 
 // OnConnectionMigration is called when connection migrates and rtt measurement needs to be reset.
+// The next RTT sample is then treated like the first sample on the connection.
 func (r *RTTStats) OnConnectionMigration() {
+	r.hasMeasurement = false
 	r.latestRTT = 0
 	r.minRTT = 0
 	r.smoothedRTT = 0
@@ -141,6 +141,10 @@ var _ = Describe("RTT stats", func() {
 		Expect(rttStats.LatestRTT()).To(Equal(time.Duration(0)))
 		Expect(rttStats.SmoothedRTT()).To(Equal(time.Duration(0)))
 		Expect(rttStats.MinRTT()).To(Equal(time.Duration(0)))
+		// the next sample is used like the first sample
+		rttStats.UpdateRTT(50*time.Millisecond, 0, time.Time{})
+		Expect(rttStats.SmoothedRTT()).To(Equal(50 * time.Millisecond))
+		Expect(rttStats.MeanDeviation()).To(Equal(25 * time.Millisecond))
 	})
 
 	It("restores the RTT", func() {
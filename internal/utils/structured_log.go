@@ -0,0 +1,74 @@
+package utils
+
+import "fmt"
+
+// A StructuredLogger is a leveled logger that logs messages with key-value pairs.
+// It is exposed to users as logging.Logger.
+type StructuredLogger interface {
+	// Enabled says if messages at the given level are logged.
+	Enabled(LogLevel) bool
+	// Log logs a message. keyvals is a list of alternating keys and values.
+	Log(level LogLevel, msg string, keyvals ...interface{})
+	// With returns a logger that adds the key-value pairs to every message.
+	With(keyvals ...interface{}) StructuredLogger
+}
+
+type structuredLogger struct {
+	logger StructuredLogger
+}
+
+var _ Logger = &structuredLogger{}
+
+// NewStructuredLogger creates a Logger that logs to a StructuredLogger.
+// The level of the logger is controlled by the StructuredLogger,
+// and prefixes are added as the value of the "scope" key.
+func NewStructuredLogger(l StructuredLogger) Logger {
+	return &structuredLogger{logger: l}
+}
+
+// GetLogger returns a Logger that logs to l.
+// If l is nil, the DefaultLogger is returned.
+func GetLogger(l StructuredLogger) Logger {
+	if l == nil {
+		return DefaultLogger
+	}
+	return NewStructuredLogger(l)
+}
+
+// SetLogLevel does nothing, the level is controlled by the StructuredLogger
+func (l *structuredLogger) SetLogLevel(LogLevel) {}
+
+// SetLogTimeFormat does nothing, timestamps are added by the StructuredLogger
+func (l *structuredLogger) SetLogTimeFormat(string) {}
+
+func (l *structuredLogger) WithPrefix(prefix string) Logger {
+	return l.With("scope", prefix)
+}
+
+func (l *structuredLogger) With(keyvals ...interface{}) Logger {
+	return &structuredLogger{logger: l.logger.With(keyvals...)}
+}
+
+func (l *structuredLogger) Debug() bool {
+	return l.logger.Enabled(LogLevelDebug)
+}
+
+func (l *structuredLogger) Errorf(format string, args ...interface{}) {
+	l.logMessage(LogLevelError, format, args...)
+}
+
+func (l *structuredLogger) Infof(format string, args ...interface{}) {
+	l.logMessage(LogLevelInfo, format, args...)
+}
+
+func (l *structuredLogger) Debugf(format string, args ...interface{}) {
+	l.logMessage(LogLevelDebug, format, args...)
+}
+
+func (l *structuredLogger) logMessage(level LogLevel, format string, args ...interface{}) {
+	// avoid formatting the message if it is not logged
+	if !l.logger.Enabled(level) {
+		return
+	}
+	l.logger.Log(level, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type logEntry struct {
+	level   LogLevel
+	msg     string
+	keyvals []interface{}
+}
+
+type recordingLogger struct {
+	level   LogLevel
+	keyvals []interface{}
+	entries *[]logEntry
+}
+
+func (l *recordingLogger) Enabled(level LogLevel) bool { return level <= l.level }
+
+func (l *recordingLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	*l.entries = append(*l.entries, logEntry{level: level, msg: msg, keyvals: append(l.keyvals, keyvals...)})
+}
+
+func (l *recordingLogger) With(keyvals ...interface{}) StructuredLogger {
+	return &recordingLogger{
+		level:   l.level,
+		keyvals: append(append([]interface{}{}, l.keyvals...), keyvals...),
+		entries: l.entries,
+	}
+}
+
+var _ = Describe("Structured Logger", func() {
+	var (
+		entries []logEntry
+		rl      *recordingLogger
+		logger  Logger
+	)
+
+	BeforeEach(func() {
+		entries = nil
+		rl = &recordingLogger{level: LogLevelInfo, entries: &entries}
+		logger = NewStructuredLogger(rl)
+	})
+
+	It("logs messages at the enabled levels", func() {
+		logger.Errorf("error %d", 1)
+		logger.Infof("info %d", 2)
+		logger.Debugf("debug %d", 3)
+		Expect(entries).To(Equal([]logEntry{
+			{level: LogLevelError, msg: "error 1"},
+			{level: LogLevelInfo, msg: "info 2"},
+		}))
+	})
+
+	It("says whether debug is enabled", func() {
+		Expect(logger.Debug()).To(BeFalse())
+		rl.level = LogLevelDebug
+		Expect(logger.Debug()).To(BeTrue())
+	})
+
+	It("doesn't change the level", func() {
+		logger.SetLogLevel(LogLevelDebug)
+		Expect(logger.Debug()).To(BeFalse())
+	})
+
+	It("adds prefixes and key-value pairs", func() {
+		logger.WithPrefix("server").With("odcid", "deadbeef").Infof("info")
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].keyvals).To(Equal([]interface{}{"scope", "server", "odcid", "deadbeef"}))
+	})
+})
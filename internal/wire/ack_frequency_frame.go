@@ -0,0 +1,90 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+const ackFrequencyFrameType = 0xaf
+
+// An AckFrequencyFrame is an ACK_FREQUENCY frame,
+// see https://datatracker.ietf.org/doc/draft-ietf-quic-ack-frequency/.
+type AckFrequencyFrame struct {
+	SequenceNumber uint64
+	// PacketTolerance is the number of ack-eliciting packets received before an ACK is sent.
+	PacketTolerance   uint64
+	UpdateMaxAckDelay time.Duration
+	// IgnoreOrder disables sending of ACKs when reordered packets are received.
+	IgnoreOrder bool
+	// IgnoreCE disables sending of ACKs when CE-marked packets are received.
+	IgnoreCE bool
+}
+
+func parseAckFrequencyFrame(r *bytes.Reader, _ protocol.VersionNumber) (*AckFrequencyFrame, error) {
+	if _, err := quicvarint.Read(r); err != nil {
+		return nil, err
+	}
+
+	seq, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	tolerance, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if tolerance == 0 {
+		return nil, errors.New("invalid packet tolerance: 0")
+	}
+	delay, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if flags&0xfc != 0 {
+		return nil, errors.New("reserved bits set")
+	}
+	// prevent overflows
+	if delay > uint64(protocol.MaxMaxAckDelay/time.Microsecond) {
+		delay = uint64(protocol.MaxMaxAckDelay / time.Microsecond)
+	}
+	return &AckFrequencyFrame{
+		SequenceNumber:    seq,
+		PacketTolerance:   tolerance,
+		UpdateMaxAckDelay: time.Duration(delay) * time.Microsecond,
+		IgnoreCE:          flags&0x2 > 0,
+		IgnoreOrder:       flags&0x1 > 0,
+	}, nil
+}
+
+func (f *AckFrequencyFrame) Write(b *bytes.Buffer, _ protocol.VersionNumber) error {
+	quicvarint.Write(b, ackFrequencyFrameType)
+	quicvarint.Write(b, f.SequenceNumber)
+	quicvarint.Write(b, f.PacketTolerance)
+	quicvarint.Write(b, uint64(f.UpdateMaxAckDelay/time.Microsecond))
+	var flags byte
+	if f.IgnoreCE {
+		flags |= 0x2
+	}
+	if f.IgnoreOrder {
+		flags |= 0x1
+	}
+	b.WriteByte(flags)
+	return nil
+}
+
+// Length of a written frame
+func (f *AckFrequencyFrame) Length(_ protocol.VersionNumber) protocol.ByteCount {
+	return quicvarint.Len(ackFrequencyFrameType) +
+		quicvarint.Len(f.SequenceNumber) +
+		quicvarint.Len(f.PacketTolerance) +
+		quicvarint.Len(uint64(f.UpdateMaxAckDelay/time.Microsecond)) +
+		1
+}
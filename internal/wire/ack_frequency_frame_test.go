@@ -0,0 +1,121 @@
+package wire
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ACK_FREQUENCY frame", func() {
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			data := encodeVarInt(0xaf)
+			data = append(data, encodeVarInt(0xdeadbeef)...) // sequence number
+			data = append(data, encodeVarInt(0xcafe)...)     // packet tolerance
+			data = append(data, encodeVarInt(1337)...)       // update max ack delay
+			data = append(data, 0x1)                         // ignore order
+			b := bytes.NewReader(data)
+			frame, err := parseAckFrequencyFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.SequenceNumber).To(Equal(uint64(0xdeadbeef)))
+			Expect(frame.PacketTolerance).To(Equal(uint64(0xcafe)))
+			Expect(frame.UpdateMaxAckDelay).To(Equal(1337 * time.Microsecond))
+			Expect(frame.IgnoreOrder).To(BeTrue())
+			Expect(frame.IgnoreCE).To(BeFalse())
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("parses the IgnoreCE flag", func() {
+			data := encodeVarInt(0xaf)
+			data = append(data, encodeVarInt(1)...) // sequence number
+			data = append(data, encodeVarInt(2)...) // packet tolerance
+			data = append(data, encodeVarInt(3)...) // update max ack delay
+			data = append(data, 0x2)                // ignore CE
+			frame, err := parseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.IgnoreOrder).To(BeFalse())
+			Expect(frame.IgnoreCE).To(BeTrue())
+		})
+
+		It("errors when the reserved bits are set", func() {
+			data := encodeVarInt(0xaf)
+			data = append(data, encodeVarInt(1)...) // sequence number
+			data = append(data, encodeVarInt(2)...) // packet tolerance
+			data = append(data, encodeVarInt(3)...) // update max ack delay
+			data = append(data, 0x4)
+			_, err := parseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).To(MatchError("reserved bits set"))
+		})
+
+		It("errors on a packet tolerance of 0", func() {
+			data := encodeVarInt(0xaf)
+			data = append(data, encodeVarInt(1)...) // sequence number
+			data = append(data, encodeVarInt(0)...) // packet tolerance
+			data = append(data, encodeVarInt(3)...) // update max ack delay
+			data = append(data, 0x0)
+			_, err := parseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).To(MatchError("invalid packet tolerance: 0"))
+		})
+
+		It("limits the max ack delay", func() {
+			data := encodeVarInt(0xaf)
+			data = append(data, encodeVarInt(1)...)              // sequence number
+			data = append(data, encodeVarInt(2)...)              // packet tolerance
+			data = append(data, encodeVarInt(quicvarint.Max)...) // update max ack delay
+			data = append(data, 0x0)
+			frame, err := parseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.UpdateMaxAckDelay).To(Equal(protocol.MaxMaxAckDelay))
+		})
+
+		It("errors on EOFs", func() {
+			data := encodeVarInt(0xaf)
+			data = append(data, encodeVarInt(0xdeadbeef)...) // sequence number
+			data = append(data, encodeVarInt(0xcafe)...)     // packet tolerance
+			data = append(data, encodeVarInt(1337)...)       // update max ack delay
+			data = append(data, 0x1)                         // ignore order
+			_, err := parseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := parseAckFrequencyFrame(bytes.NewReader(data[0:i]), versionIETFFrames)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := &AckFrequencyFrame{
+				SequenceNumber:    0xdecafbad,
+				PacketTolerance:   0xcafe,
+				UpdateMaxAckDelay: 12345 * time.Microsecond,
+				IgnoreOrder:       true,
+				IgnoreCE:          true,
+			}
+			Expect(frame.Write(b, versionIETFFrames)).To(Succeed())
+			expected := encodeVarInt(0xaf)
+			expected = append(expected, encodeVarInt(0xdecafbad)...)
+			expected = append(expected, encodeVarInt(0xcafe)...)
+			expected = append(expected, encodeVarInt(12345)...)
+			expected = append(expected, 0x3)
+			Expect(b.Bytes()).To(Equal(expected))
+		})
+
+		It("has the correct length", func() {
+			b := &bytes.Buffer{}
+			frame := &AckFrequencyFrame{
+				SequenceNumber:    0xdecafbad,
+				PacketTolerance:   0xcafe,
+				UpdateMaxAckDelay: 12345 * time.Microsecond,
+			}
+			Expect(frame.Write(b, versionIETFFrames)).To(Succeed())
+			Expect(frame.Length(versionIETFFrames)).To(BeEquivalentTo(b.Len()))
+		})
+	})
+})
@@ -0,0 +1,50 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+const expiredStreamDataFrameType = 0x3e8a51c7
+
+// An ExpiredStreamDataFrame is an EXPIRED_STREAM_DATA frame.
+// It is based on https://datatracker.ietf.org/doc/draft-lubashev-quic-partial-reliability/,
+// but uses quic-go's own codepoint.
+type ExpiredStreamDataFrame struct {
+	StreamID protocol.StreamID
+	// The sender won't (re)transmit any stream data below this offset.
+	Offset protocol.ByteCount
+}
+
+func parseExpiredStreamDataFrame(r *bytes.Reader, _ protocol.VersionNumber) (*ExpiredStreamDataFrame, error) {
+	if _, err := quicvarint.Read(r); err != nil {
+		return nil, err
+	}
+
+	streamID, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpiredStreamDataFrame{
+		StreamID: protocol.StreamID(streamID),
+		Offset:   protocol.ByteCount(offset),
+	}, nil
+}
+
+func (f *ExpiredStreamDataFrame) Write(b *bytes.Buffer, _ protocol.VersionNumber) error {
+	quicvarint.Write(b, expiredStreamDataFrameType)
+	quicvarint.Write(b, uint64(f.StreamID))
+	quicvarint.Write(b, uint64(f.Offset))
+	return nil
+}
+
+// Length of a written frame
+func (f *ExpiredStreamDataFrame) Length(_ protocol.VersionNumber) protocol.ByteCount {
+	return quicvarint.Len(expiredStreamDataFrameType) + quicvarint.Len(uint64(f.StreamID)) + quicvarint.Len(uint64(f.Offset))
+}
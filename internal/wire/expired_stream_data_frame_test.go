@@ -0,0 +1,55 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EXPIRED_STREAM_DATA frame", func() {
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			data := encodeVarInt(0x3e8a51c7)
+			data = append(data, encodeVarInt(0xdeadbeef)...) // stream ID
+			data = append(data, encodeVarInt(0x1337)...)     // offset
+			b := bytes.NewReader(data)
+			frame, err := parseExpiredStreamDataFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.StreamID).To(Equal(protocol.StreamID(0xdeadbeef)))
+			Expect(frame.Offset).To(Equal(protocol.ByteCount(0x1337)))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors on EOFs", func() {
+			data := encodeVarInt(0x3e8a51c7)
+			data = append(data, encodeVarInt(0xdeadbeef)...) // stream ID
+			data = append(data, encodeVarInt(0x1337)...)     // offset
+			_, err := parseExpiredStreamDataFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := parseExpiredStreamDataFrame(bytes.NewReader(data[:i]), versionIETFFrames)
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := &ExpiredStreamDataFrame{
+				StreamID: 0xdecafbad,
+				Offset:   0x1337,
+			}
+			Expect(frame.Write(b, versionIETFFrames)).To(Succeed())
+			expected := encodeVarInt(0x3e8a51c7)
+			expected = append(expected, encodeVarInt(0xdecafbad)...)
+			expected = append(expected, encodeVarInt(0x1337)...)
+			Expect(b.Bytes()).To(Equal(expected))
+			Expect(frame.Length(versionIETFFrames)).To(BeEquivalentTo(len(expected)))
+		})
+	})
+})
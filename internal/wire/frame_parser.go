@@ -4,25 +4,35 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
+	"github.com/lucas-clemente/quic-go/quicvarint"
 )
 
 type frameParser struct {
 	ackDelayExponent uint8
 
-	supportsDatagrams bool
+	supportsDatagrams          bool
+	supportsAckFrequency       bool
+	supportsMultipath          bool
+	supportsPartialReliability bool
+	supportsResetStreamAt      bool
 
 	version protocol.VersionNumber
 }
 
 // NewFrameParser creates a new frame parser.
-func NewFrameParser(supportsDatagrams bool, v protocol.VersionNumber) FrameParser {
+func NewFrameParser(supportsDatagrams, supportsAckFrequency, supportsMultipath, supportsPartialReliability, supportsResetStreamAt bool, v protocol.VersionNumber) FrameParser {
 	return &frameParser{
-		supportsDatagrams: supportsDatagrams,
-		version:           v,
+		supportsDatagrams:          supportsDatagrams,
+		supportsAckFrequency:       supportsAckFrequency,
+		supportsMultipath:          supportsMultipath,
+		supportsPartialReliability: supportsPartialReliability,
+		supportsResetStreamAt:      supportsResetStreamAt,
+		version:                    v,
 	}
 }
 
@@ -36,10 +46,19 @@ func (p *frameParser) ParseNext(r *bytes.Reader, encLevel protocol.EncryptionLev
 		}
 		r.UnreadByte()
 
-		f, err := p.parseFrame(r, typeByte, encLevel)
+		frameType := uint64(typeByte)
+		if typeByte&0xc0 != 0 { // the frame type is encoded using more than one byte
+			startLen := r.Len()
+			if t, err := quicvarint.Read(r); err == nil {
+				frameType = t
+			}
+			r.Seek(int64(r.Len()-startLen), io.SeekCurrent)
+		}
+
+		f, err := p.parseFrame(r, frameType, encLevel)
 		if err != nil {
 			return nil, &qerr.TransportError{
-				FrameType:    uint64(typeByte),
+				FrameType:    frameType,
 				ErrorCode:    qerr.FrameEncodingError,
 				ErrorMessage: err.Error(),
 			}
@@ -49,13 +68,13 @@ func (p *frameParser) ParseNext(r *bytes.Reader, encLevel protocol.EncryptionLev
 	return nil, nil
 }
 
-func (p *frameParser) parseFrame(r *bytes.Reader, typeByte byte, encLevel protocol.EncryptionLevel) (Frame, error) {
+func (p *frameParser) parseFrame(r *bytes.Reader, frameType uint64, encLevel protocol.EncryptionLevel) (Frame, error) {
 	var frame Frame
 	var err error
-	if typeByte&0xf8 == 0x8 {
+	if frameType&0xf8 == 0x8 {
 		frame, err = parseStreamFrame(r, p.version)
 	} else {
-		switch typeByte {
+		switch frameType {
 		case 0x1:
 			frame, err = parsePingFrame(r, p.version)
 		case 0x2, 0x3:
@@ -96,11 +115,41 @@ func (p *frameParser) parseFrame(r *bytes.Reader, typeByte byte, encLevel protoc
 			frame, err = parseConnectionCloseFrame(r, p.version)
 		case 0x1e:
 			frame, err = parseHandshakeDoneFrame(r, p.version)
+		case resetStreamAtFrameType:
+			if p.supportsResetStreamAt {
+				frame, err = parseResetStreamFrame(r, p.version)
+				break
+			}
+			err = errors.New("unknown frame type")
 		case 0x30, 0x31:
 			if p.supportsDatagrams {
 				frame, err = parseDatagramFrame(r, p.version)
 				break
 			}
+			err = errors.New("unknown frame type")
+		case ackFrequencyFrameType:
+			if p.supportsAckFrequency {
+				frame, err = parseAckFrequencyFrame(r, p.version)
+				break
+			}
+			err = errors.New("unknown frame type")
+		case immediateAckFrameType:
+			if p.supportsAckFrequency {
+				frame, err = parseImmediateAckFrame(r, p.version)
+				break
+			}
+			err = errors.New("unknown frame type")
+		case pathAbandonFrameType:
+			if p.supportsMultipath {
+				frame, err = parsePathAbandonFrame(r, p.version)
+				break
+			}
+			err = errors.New("unknown frame type")
+		case expiredStreamDataFrameType:
+			if p.supportsPartialReliability {
+				frame, err = parseExpiredStreamDataFrame(r, p.version)
+				break
+			}
 			fallthrough
 		default:
 			err = errors.New("unknown frame type")
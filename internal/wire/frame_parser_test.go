@@ -18,7 +18,7 @@ var _ = Describe("Frame parsing", func() {
 
 	BeforeEach(func() {
 		buf = &bytes.Buffer{}
-		parser = NewFrameParser(true, versionIETFFrames)
+		parser = NewFrameParser(true, true, true, true, true, versionIETFFrames)
 	})
 
 	It("returns nil if there's nothing more to read", func() {
@@ -290,7 +290,7 @@ var _ = Describe("Frame parsing", func() {
 	})
 
 	It("errors when DATAGRAM frames are not supported", func() {
-		parser = NewFrameParser(false, versionIETFFrames)
+		parser = NewFrameParser(false, false, false, false, false, versionIETFFrames)
 		f := &DatagramFrame{Data: []byte("foobar")}
 		buf := &bytes.Buffer{}
 		Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
@@ -302,6 +302,123 @@ var _ = Describe("Frame parsing", func() {
 		}))
 	})
 
+	It("unpacks ACK_FREQUENCY frames", func() {
+		f := &AckFrequencyFrame{
+			SequenceNumber:    1337,
+			PacketTolerance:   10,
+			UpdateMaxAckDelay: 5 * time.Millisecond,
+			IgnoreOrder:       true,
+		}
+		buf := &bytes.Buffer{}
+		Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+		frame, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(Equal(f))
+	})
+
+	It("unpacks IMMEDIATE_ACK frames", func() {
+		buf := &bytes.Buffer{}
+		Expect((&ImmediateAckFrame{}).Write(buf, versionIETFFrames)).To(Succeed())
+		frame, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(Equal(&ImmediateAckFrame{}))
+	})
+
+	It("errors when ACK_FREQUENCY and IMMEDIATE_ACK frames are not supported", func() {
+		parser = NewFrameParser(false, false, false, false, false, versionIETFFrames)
+		buf := &bytes.Buffer{}
+		Expect((&AckFrequencyFrame{PacketTolerance: 2}).Write(buf, versionIETFFrames)).To(Succeed())
+		_, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.FrameEncodingError,
+			FrameType:    0xaf,
+			ErrorMessage: "unknown frame type",
+		}))
+		buf.Reset()
+		Expect((&ImmediateAckFrame{}).Write(buf, versionIETFFrames)).To(Succeed())
+		_, err = parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.FrameEncodingError,
+			FrameType:    0xac,
+			ErrorMessage: "unknown frame type",
+		}))
+	})
+
+	It("unpacks PATH_ABANDON frames", func() {
+		f := &PathAbandonFrame{
+			PathIdentifier: 42,
+			ErrorCode:      0x1337,
+			ReasonPhrase:   "foobar",
+		}
+		buf := &bytes.Buffer{}
+		Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+		frame, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(Equal(f))
+	})
+
+	It("errors when PATH_ABANDON frames are not supported", func() {
+		parser = NewFrameParser(false, false, false, false, false, versionIETFFrames)
+		buf := &bytes.Buffer{}
+		Expect((&PathAbandonFrame{}).Write(buf, versionIETFFrames)).To(Succeed())
+		_, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.FrameEncodingError,
+			FrameType:    0x15228c05,
+			ErrorMessage: "unknown frame type",
+		}))
+	})
+
+	It("unpacks EXPIRED_STREAM_DATA frames", func() {
+		f := &ExpiredStreamDataFrame{
+			StreamID: 0x1337,
+			Offset:   0xdeadbeef,
+		}
+		buf := &bytes.Buffer{}
+		Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+		frame, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(Equal(f))
+	})
+
+	It("errors when EXPIRED_STREAM_DATA frames are not supported", func() {
+		parser = NewFrameParser(false, false, false, false, false, versionIETFFrames)
+		buf := &bytes.Buffer{}
+		Expect((&ExpiredStreamDataFrame{}).Write(buf, versionIETFFrames)).To(Succeed())
+		_, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.FrameEncodingError,
+			FrameType:    0x3e8a51c7,
+			ErrorMessage: "unknown frame type",
+		}))
+	})
+
+	It("unpacks RESET_STREAM_AT frames", func() {
+		f := &ResetStreamFrame{
+			StreamID:     0x1337,
+			ErrorCode:    0x42,
+			FinalSize:    0xdeadbeef,
+			ReliableSize: 0xdecaf,
+		}
+		buf := &bytes.Buffer{}
+		Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+		frame, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(Equal(f))
+	})
+
+	It("errors when RESET_STREAM_AT frames are not supported", func() {
+		parser = NewFrameParser(false, false, false, false, false, versionIETFFrames)
+		buf := &bytes.Buffer{}
+		Expect((&ResetStreamFrame{FinalSize: 10, ReliableSize: 5}).Write(buf, versionIETFFrames)).To(Succeed())
+		_, err := parser.ParseNext(bytes.NewReader(buf.Bytes()), protocol.Encryption1RTT)
+		Expect(err).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.FrameEncodingError,
+			FrameType:    0x24,
+			ErrorMessage: "unknown frame type",
+		}))
+	})
+
 	It("errors on invalid type", func() {
 		_, err := parser.ParseNext(bytes.NewReader([]byte{0x42}), protocol.Encryption1RTT)
 		Expect(err).To(MatchError(&qerr.TransportError{
@@ -345,6 +462,10 @@ var _ = Describe("Frame parsing", func() {
 			&ConnectionCloseFrame{},
 			&HandshakeDoneFrame{},
 			&DatagramFrame{},
+			&AckFrequencyFrame{PacketTolerance: 1},
+			&ImmediateAckFrame{},
+			&PathAbandonFrame{ReasonPhrase: "foobar"},
+			&ExpiredStreamDataFrame{},
 		}
 
 		var framesSerialized [][]byte
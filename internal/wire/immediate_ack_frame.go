@@ -0,0 +1,31 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+const immediateAckFrameType = 0xac
+
+// An ImmediateAckFrame is an IMMEDIATE_ACK frame,
+// see https://datatracker.ietf.org/doc/draft-ietf-quic-ack-frequency/.
+type ImmediateAckFrame struct{}
+
+func parseImmediateAckFrame(r *bytes.Reader, _ protocol.VersionNumber) (*ImmediateAckFrame, error) {
+	if _, err := quicvarint.Read(r); err != nil {
+		return nil, err
+	}
+	return &ImmediateAckFrame{}, nil
+}
+
+func (f *ImmediateAckFrame) Write(b *bytes.Buffer, _ protocol.VersionNumber) error {
+	quicvarint.Write(b, immediateAckFrameType)
+	return nil
+}
+
+// Length of a written frame
+func (f *ImmediateAckFrame) Length(_ protocol.VersionNumber) protocol.ByteCount {
+	return quicvarint.Len(immediateAckFrameType)
+}
@@ -0,0 +1,35 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IMMEDIATE_ACK frame", func() {
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			b := bytes.NewReader(encodeVarInt(0xac))
+			_, err := parseImmediateAckFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors on EOFs", func() {
+			_, err := parseImmediateAckFrame(bytes.NewReader(nil), versionIETFFrames)
+			Expect(err).To(MatchError(io.EOF))
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := &ImmediateAckFrame{}
+			Expect(frame.Write(b, versionIETFFrames)).To(Succeed())
+			Expect(b.Bytes()).To(Equal([]byte{0x40, 0xac}))
+			Expect(frame.Length(versionIETFFrames)).To(BeEquivalentTo(2))
+		})
+	})
+})
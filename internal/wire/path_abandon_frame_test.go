@@ -0,0 +1,70 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PATH_ABANDON frame", func() {
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			data := encodeVarInt(0x15228c05)
+			data = append(data, encodeVarInt(0x42)...)   // path identifier
+			data = append(data, encodeVarInt(0x1337)...) // error code
+			data = append(data, encodeVarInt(6)...)      // reason phrase length
+			data = append(data, []byte("foobar")...)
+			b := bytes.NewReader(data)
+			frame, err := parsePathAbandonFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.PathIdentifier).To(Equal(uint64(0x42)))
+			Expect(frame.ErrorCode).To(Equal(uint64(0x1337)))
+			Expect(frame.ReasonPhrase).To(Equal("foobar"))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("rejects long reason phrases", func() {
+			data := encodeVarInt(0x15228c05)
+			data = append(data, encodeVarInt(0x42)...)   // path identifier
+			data = append(data, encodeVarInt(0x1337)...) // error code
+			data = append(data, encodeVarInt(0xffff)...) // reason phrase length
+			_, err := parsePathAbandonFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).To(MatchError(io.EOF))
+		})
+
+		It("errors on EOFs", func() {
+			data := encodeVarInt(0x15228c05)
+			data = append(data, encodeVarInt(0x42)...)   // path identifier
+			data = append(data, encodeVarInt(0x1337)...) // error code
+			data = append(data, encodeVarInt(6)...)      // reason phrase length
+			data = append(data, []byte("foobar")...)
+			_, err := parsePathAbandonFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := parsePathAbandonFrame(bytes.NewReader(data[:i]), versionIETFFrames)
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := &PathAbandonFrame{
+				PathIdentifier: 3,
+				ErrorCode:      0x1337,
+				ReasonPhrase:   "foobar",
+			}
+			Expect(frame.Write(b, versionIETFFrames)).To(Succeed())
+			expected := encodeVarInt(0x15228c05)
+			expected = append(expected, encodeVarInt(3)...)
+			expected = append(expected, encodeVarInt(0x1337)...)
+			expected = append(expected, encodeVarInt(6)...)
+			expected = append(expected, []byte("foobar")...)
+			Expect(b.Bytes()).To(Equal(expected))
+			Expect(frame.Length(versionIETFFrames)).To(BeEquivalentTo(len(expected)))
+		})
+	})
+})
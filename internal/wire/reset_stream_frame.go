@@ -2,21 +2,28 @@ package wire
 
 import (
 	"bytes"
+	"errors"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/quicvarint"
 )
 
-// A ResetStreamFrame is a RESET_STREAM frame in QUIC
+// Based on https://datatracker.ietf.org/doc/draft-ietf-quic-reliable-stream-reset/.
+const resetStreamAtFrameType = 0x24
+
+// A ResetStreamFrame is a RESET_STREAM frame in QUIC.
+// If the ReliableSize is set, it is sent as a RESET_STREAM_AT frame.
 type ResetStreamFrame struct {
-	StreamID  protocol.StreamID
-	ErrorCode qerr.StreamErrorCode
-	FinalSize protocol.ByteCount
+	StreamID     protocol.StreamID
+	ErrorCode    qerr.StreamErrorCode
+	FinalSize    protocol.ByteCount
+	ReliableSize protocol.ByteCount
 }
 
 func parseResetStreamFrame(r *bytes.Reader, _ protocol.VersionNumber) (*ResetStreamFrame, error) {
-	if _, err := r.ReadByte(); err != nil { // read the TypeByte
+	typeByte, err := r.ReadByte()
+	if err != nil {
 		return nil, err
 	}
 
@@ -36,23 +43,46 @@ func parseResetStreamFrame(r *bytes.Reader, _ protocol.VersionNumber) (*ResetStr
 		return nil, err
 	}
 	byteOffset = protocol.ByteCount(bo)
+	var reliableSize protocol.ByteCount
+	if typeByte == resetStreamAtFrameType {
+		rs, err := quicvarint.Read(r)
+		if err != nil {
+			return nil, err
+		}
+		reliableSize = protocol.ByteCount(rs)
+		if reliableSize > byteOffset {
+			return nil, errors.New("RESET_STREAM_AT: reliable size can't be larger than the final size")
+		}
+	}
 
 	return &ResetStreamFrame{
-		StreamID:  streamID,
-		ErrorCode: qerr.StreamErrorCode(errorCode),
-		FinalSize: byteOffset,
+		StreamID:     streamID,
+		ErrorCode:    qerr.StreamErrorCode(errorCode),
+		FinalSize:    byteOffset,
+		ReliableSize: reliableSize,
 	}, nil
 }
 
 func (f *ResetStreamFrame) Write(b *bytes.Buffer, _ protocol.VersionNumber) error {
-	b.WriteByte(0x4)
+	if f.ReliableSize > 0 {
+		b.WriteByte(resetStreamAtFrameType)
+	} else {
+		b.WriteByte(0x4)
+	}
 	quicvarint.Write(b, uint64(f.StreamID))
 	quicvarint.Write(b, uint64(f.ErrorCode))
 	quicvarint.Write(b, uint64(f.FinalSize))
+	if f.ReliableSize > 0 {
+		quicvarint.Write(b, uint64(f.ReliableSize))
+	}
 	return nil
 }
 
 // Length of a written frame
 func (f *ResetStreamFrame) Length(version protocol.VersionNumber) protocol.ByteCount {
-	return 1 + quicvarint.Len(uint64(f.StreamID)) + quicvarint.Len(uint64(f.ErrorCode)) + quicvarint.Len(uint64(f.FinalSize))
+	length := 1 + quicvarint.Len(uint64(f.StreamID)) + quicvarint.Len(uint64(f.ErrorCode)) + quicvarint.Len(uint64(f.FinalSize))
+	if f.ReliableSize > 0 {
+		length += quicvarint.Len(uint64(f.ReliableSize))
+	}
+	return length
 }
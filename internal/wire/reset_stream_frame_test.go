@@ -26,6 +26,32 @@ var _ = Describe("RESET_STREAM frame", func() {
 			Expect(frame.ErrorCode).To(Equal(qerr.StreamErrorCode(0x1337)))
 		})
 
+		It("accepts a RESET_STREAM_AT frame", func() {
+			data := []byte{0x24}
+			data = append(data, encodeVarInt(0xdeadbeef)...) // stream ID
+			data = append(data, encodeVarInt(0x1337)...)     // error code
+			data = append(data, encodeVarInt(0x987654)...)   // byte offset
+			data = append(data, encodeVarInt(0x123456)...)   // reliable size
+			b := bytes.NewReader(data)
+			frame, err := parseResetStreamFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.StreamID).To(Equal(protocol.StreamID(0xdeadbeef)))
+			Expect(frame.FinalSize).To(Equal(protocol.ByteCount(0x987654)))
+			Expect(frame.ReliableSize).To(Equal(protocol.ByteCount(0x123456)))
+			Expect(frame.ErrorCode).To(Equal(qerr.StreamErrorCode(0x1337)))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors if the reliable size is larger than the final size", func() {
+			data := []byte{0x24}
+			data = append(data, encodeVarInt(0xdeadbeef)...) // stream ID
+			data = append(data, encodeVarInt(0x1337)...)     // error code
+			data = append(data, encodeVarInt(0x1000)...)     // byte offset
+			data = append(data, encodeVarInt(0x1001)...)     // reliable size
+			_, err := parseResetStreamFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).To(MatchError("RESET_STREAM_AT: reliable size can't be larger than the final size"))
+		})
+
 		It("errors on EOFs", func() {
 			data := []byte{0x4}
 			data = append(data, encodeVarInt(0xdeadbeef)...)  // stream ID
@@ -57,6 +83,24 @@ var _ = Describe("RESET_STREAM frame", func() {
 			Expect(b.Bytes()).To(Equal(expected))
 		})
 
+		It("writes a RESET_STREAM_AT frame", func() {
+			frame := ResetStreamFrame{
+				StreamID:     0x1337,
+				FinalSize:    0x11223344decafbad,
+				ErrorCode:    0xcafe,
+				ReliableSize: 0xdecafbad,
+			}
+			b := &bytes.Buffer{}
+			Expect(frame.Write(b, versionIETFFrames)).To(Succeed())
+			expected := []byte{0x24}
+			expected = append(expected, encodeVarInt(0x1337)...)
+			expected = append(expected, encodeVarInt(0xcafe)...)
+			expected = append(expected, encodeVarInt(0x11223344decafbad)...)
+			expected = append(expected, encodeVarInt(0xdecafbad)...)
+			Expect(b.Bytes()).To(Equal(expected))
+			Expect(frame.Length(versionIETFFrames)).To(BeEquivalentTo(b.Len()))
+		})
+
 		It("has the correct min length", func() {
 			rst := ResetStreamFrame{
 				StreamID:  0x1337,
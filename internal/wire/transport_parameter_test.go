@@ -41,6 +41,7 @@ var _ = Describe("Transport Parameters", func() {
 	}
 
 	It("has a string representation", func() {
+		minAckDelay := 1500 * time.Microsecond
 		p := &TransportParameters{
 			InitialMaxStreamDataBidiLocal:   1234,
 			InitialMaxStreamDataBidiRemote:  2345,
@@ -57,8 +58,12 @@ var _ = Describe("Transport Parameters", func() {
 			StatelessResetToken:             &protocol.StatelessResetToken{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00},
 			ActiveConnectionIDLimit:         123,
 			MaxDatagramFrameSize:            876,
+			MinAckDelay:                     &minAckDelay,
+			EnableMultipath:                 true,
+			EnablePartialReliability:        true,
+			EnableReliableStreamReset:       true,
 		}
-		Expect(p.String()).To(Equal("&wire.TransportParameters{OriginalDestinationConnectionID: deadbeef, InitialSourceConnectionID: decafbad, RetrySourceConnectionID: deadc0de, InitialMaxStreamDataBidiLocal: 1234, InitialMaxStreamDataBidiRemote: 2345, InitialMaxStreamDataUni: 3456, InitialMaxData: 4567, MaxBidiStreamNum: 1337, MaxUniStreamNum: 7331, MaxIdleTimeout: 42s, AckDelayExponent: 14, MaxAckDelay: 37ms, ActiveConnectionIDLimit: 123, StatelessResetToken: 0x112233445566778899aabbccddeeff00, MaxDatagramFrameSize: 876}"))
+		Expect(p.String()).To(Equal("&wire.TransportParameters{OriginalDestinationConnectionID: deadbeef, InitialSourceConnectionID: decafbad, RetrySourceConnectionID: deadc0de, InitialMaxStreamDataBidiLocal: 1234, InitialMaxStreamDataBidiRemote: 2345, InitialMaxStreamDataUni: 3456, InitialMaxData: 4567, MaxBidiStreamNum: 1337, MaxUniStreamNum: 7331, MaxIdleTimeout: 42s, AckDelayExponent: 14, MaxAckDelay: 37ms, ActiveConnectionIDLimit: 123, StatelessResetToken: 0x112233445566778899aabbccddeeff00, MaxDatagramFrameSize: 876, MinAckDelay: 1.5ms, EnableMultipath: true, EnablePartialReliability: true, EnableReliableStreamReset: true}"))
 	})
 
 	It("has a string representation, if there's no stateless reset token, no Retry source connection id and no datagram support", func() {
@@ -81,6 +86,7 @@ var _ = Describe("Transport Parameters", func() {
 	})
 
 	It("marshals and unmarshals", func() {
+		minAckDelay := 1234 * time.Microsecond
 		var token protocol.StatelessResetToken
 		rand.Read(token[:])
 		params := &TransportParameters{
@@ -100,6 +106,10 @@ var _ = Describe("Transport Parameters", func() {
 			MaxAckDelay:                     42 * time.Millisecond,
 			ActiveConnectionIDLimit:         getRandomValue(),
 			MaxDatagramFrameSize:            protocol.ByteCount(getRandomValue()),
+			MinAckDelay:                     &minAckDelay,
+			EnableMultipath:                 true,
+			EnablePartialReliability:        true,
+			EnableReliableStreamReset:       true,
 		}
 		data := params.Marshal(protocol.PerspectiveServer)
 
@@ -121,6 +131,47 @@ var _ = Describe("Transport Parameters", func() {
 		Expect(p.MaxAckDelay).To(Equal(42 * time.Millisecond))
 		Expect(p.ActiveConnectionIDLimit).To(Equal(params.ActiveConnectionIDLimit))
 		Expect(p.MaxDatagramFrameSize).To(Equal(params.MaxDatagramFrameSize))
+		Expect(p.MinAckDelay).To(Equal(&minAckDelay))
+		Expect(p.EnableMultipath).To(BeTrue())
+		Expect(p.EnablePartialReliability).To(BeTrue())
+		Expect(p.EnableReliableStreamReset).To(BeTrue())
+	})
+
+	It("doesn't marshal the min_ack_delay, if the ACK frequency extension is not supported", func() {
+		data := (&TransportParameters{
+			StatelessResetToken: &protocol.StatelessResetToken{},
+			MaxAckDelay:         protocol.DefaultMaxAckDelay,
+		}).Marshal(protocol.PerspectiveServer)
+		p := &TransportParameters{}
+		Expect(p.Unmarshal(data, protocol.PerspectiveServer)).To(Succeed())
+		Expect(p.MinAckDelay).To(BeNil())
+	})
+
+	It("errors when the min_ack_delay is larger than the max_ack_delay", func() {
+		minAckDelay := 26 * time.Millisecond
+		data := (&TransportParameters{
+			StatelessResetToken: &protocol.StatelessResetToken{},
+			MaxAckDelay:         25 * time.Millisecond,
+			MinAckDelay:         &minAckDelay,
+		}).Marshal(protocol.PerspectiveServer)
+		p := &TransportParameters{}
+		Expect(p.Unmarshal(data, protocol.PerspectiveServer)).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.TransportParameterError,
+			ErrorMessage: "min_ack_delay (26ms) larger than max_ack_delay (25ms)",
+		}))
+	})
+
+	It("errors when the min_ack_delay is too large", func() {
+		minAckDelay := 1 << 14 * time.Millisecond
+		data := (&TransportParameters{
+			StatelessResetToken: &protocol.StatelessResetToken{},
+			MinAckDelay:         &minAckDelay,
+		}).Marshal(protocol.PerspectiveServer)
+		p := &TransportParameters{}
+		Expect(p.Unmarshal(data, protocol.PerspectiveServer)).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.TransportParameterError,
+			ErrorMessage: "invalid value for min_ack_delay: 16384000us (maximum 16383000us)",
+		}))
 	})
 
 	It("doesn't marshal a retry_source_connection_id, if no Retry was performed", func() {
@@ -176,6 +227,39 @@ var _ = Describe("Transport Parameters", func() {
 		}))
 	})
 
+	It("errors when enable_multipath has content", func() {
+		b := &bytes.Buffer{}
+		quicvarint.Write(b, uint64(enableMultipathParameterID))
+		quicvarint.Write(b, 6)
+		b.Write([]byte("foobar"))
+		Expect((&TransportParameters{}).Unmarshal(b.Bytes(), protocol.PerspectiveServer)).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.TransportParameterError,
+			ErrorMessage: "wrong length for enable_multipath: 6 (expected empty)",
+		}))
+	})
+
+	It("errors when enable_partial_reliability has content", func() {
+		b := &bytes.Buffer{}
+		quicvarint.Write(b, uint64(enablePartialReliabilityParameterID))
+		quicvarint.Write(b, 6)
+		b.Write([]byte("foobar"))
+		Expect((&TransportParameters{}).Unmarshal(b.Bytes(), protocol.PerspectiveServer)).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.TransportParameterError,
+			ErrorMessage: "wrong length for enable_partial_reliability: 6 (expected empty)",
+		}))
+	})
+
+	It("errors when reset_stream_at has content", func() {
+		b := &bytes.Buffer{}
+		quicvarint.Write(b, uint64(resetStreamAtParameterID))
+		quicvarint.Write(b, 6)
+		b.Write([]byte("foobar"))
+		Expect((&TransportParameters{}).Unmarshal(b.Bytes(), protocol.PerspectiveServer)).To(MatchError(&qerr.TransportError{
+			ErrorCode:    qerr.TransportParameterError,
+			ErrorMessage: "wrong length for reset_stream_at: 6 (expected empty)",
+		}))
+	})
+
 	It("errors when the server doesn't set the original_destination_connection_id", func() {
 		b := &bytes.Buffer{}
 		quicvarint.Write(b, uint64(statelessResetTokenParameterID))
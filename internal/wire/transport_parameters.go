@@ -44,6 +44,15 @@ const (
 	retrySourceConnectionIDParameterID         transportParameterID = 0x10
 	// https://datatracker.ietf.org/doc/draft-ietf-quic-datagram/
 	maxDatagramFrameSizeParameterID transportParameterID = 0x20
+	// https://datatracker.ietf.org/doc/draft-ietf-quic-ack-frequency/
+	minAckDelayParameterID transportParameterID = 0xff03de1a
+	// Based on https://datatracker.ietf.org/doc/draft-ietf-quic-multipath/.
+	// quic-go uses its own codepoint, since it doesn't implement the ACK_MP frame.
+	enableMultipathParameterID transportParameterID = 0x71c6e5a1
+	// Based on https://datatracker.ietf.org/doc/draft-lubashev-quic-partial-reliability/.
+	enablePartialReliabilityParameterID transportParameterID = 0x3e8a51c6
+	// https://datatracker.ietf.org/doc/draft-ietf-quic-reliable-stream-reset/
+	resetStreamAtParameterID transportParameterID = 0x17f7586d2cb571
 )
 
 // PreferredAddress is the value encoding in the preferred_address transport parameter
@@ -85,6 +94,14 @@ type TransportParameters struct {
 	ActiveConnectionIDLimit uint64
 
 	MaxDatagramFrameSize protocol.ByteCount
+
+	MinAckDelay *time.Duration // nil if the ACK frequency extension is not supported
+
+	EnableMultipath bool
+
+	EnablePartialReliability bool
+
+	EnableReliableStreamReset bool
 }
 
 // Unmarshal the transport parameters
@@ -137,6 +154,7 @@ func (p *TransportParameters) unmarshal(r *bytes.Reader, sentBy protocol.Perspec
 			maxAckDelayParameterID,
 			activeConnectionIDLimitParameterID,
 			maxDatagramFrameSizeParameterID,
+			minAckDelayParameterID,
 			ackDelayExponentParameterID:
 			if err := p.readNumericTransportParameter(r, paramID, int(paramLen)); err != nil {
 				return err
@@ -153,6 +171,21 @@ func (p *TransportParameters) unmarshal(r *bytes.Reader, sentBy protocol.Perspec
 				return fmt.Errorf("wrong length for disable_active_migration: %d (expected empty)", paramLen)
 			}
 			p.DisableActiveMigration = true
+		case enableMultipathParameterID:
+			if paramLen != 0 {
+				return fmt.Errorf("wrong length for enable_multipath: %d (expected empty)", paramLen)
+			}
+			p.EnableMultipath = true
+		case enablePartialReliabilityParameterID:
+			if paramLen != 0 {
+				return fmt.Errorf("wrong length for enable_partial_reliability: %d (expected empty)", paramLen)
+			}
+			p.EnablePartialReliability = true
+		case resetStreamAtParameterID:
+			if paramLen != 0 {
+				return fmt.Errorf("wrong length for reset_stream_at: %d (expected empty)", paramLen)
+			}
+			p.EnableReliableStreamReset = true
 		case statelessResetTokenParameterID:
 			if sentBy == protocol.PerspectiveClient {
 				return errors.New("client sent a stateless_reset_token")
@@ -194,6 +227,9 @@ func (p *TransportParameters) unmarshal(r *bytes.Reader, sentBy protocol.Perspec
 			return errors.New("missing initial_source_connection_id")
 		}
 	}
+	if p.MinAckDelay != nil && *p.MinAckDelay > p.MaxAckDelay {
+		return fmt.Errorf("min_ack_delay (%s) larger than max_ack_delay (%s)", *p.MinAckDelay, p.MaxAckDelay)
+	}
 
 	// check that every transport parameter was sent at most once
 	sort.Slice(parameterIDs, func(i, j int) bool { return parameterIDs[i] < parameterIDs[j] })
@@ -305,6 +341,12 @@ func (p *TransportParameters) readNumericTransportParameter(
 		p.ActiveConnectionIDLimit = val
 	case maxDatagramFrameSizeParameterID:
 		p.MaxDatagramFrameSize = protocol.ByteCount(val)
+	case minAckDelayParameterID:
+		if val > uint64(protocol.MaxMaxAckDelay/time.Microsecond) {
+			return fmt.Errorf("invalid value for min_ack_delay: %dus (maximum %dus)", val, protocol.MaxMaxAckDelay/time.Microsecond)
+		}
+		minAckDelay := time.Duration(val) * time.Microsecond
+		p.MinAckDelay = &minAckDelay
 	default:
 		return fmt.Errorf("TransportParameter BUG: transport parameter %d not found", paramID)
 	}
@@ -394,6 +436,21 @@ func (p *TransportParameters) Marshal(pers protocol.Perspective) []byte {
 	if p.MaxDatagramFrameSize != protocol.InvalidByteCount {
 		p.marshalVarintParam(b, maxDatagramFrameSizeParameterID, uint64(p.MaxDatagramFrameSize))
 	}
+	if p.MinAckDelay != nil {
+		p.marshalVarintParam(b, minAckDelayParameterID, uint64(*p.MinAckDelay/time.Microsecond))
+	}
+	if p.EnableMultipath {
+		quicvarint.Write(b, uint64(enableMultipathParameterID))
+		quicvarint.Write(b, 0)
+	}
+	if p.EnablePartialReliability {
+		quicvarint.Write(b, uint64(enablePartialReliabilityParameterID))
+		quicvarint.Write(b, 0)
+	}
+	if p.EnableReliableStreamReset {
+		quicvarint.Write(b, uint64(resetStreamAtParameterID))
+		quicvarint.Write(b, 0)
+	}
 	return b.Bytes()
 }
 
@@ -471,6 +528,19 @@ func (p *TransportParameters) String() string {
 		logString += ", MaxDatagramFrameSize: %d"
 		logParams = append(logParams, p.MaxDatagramFrameSize)
 	}
+	if p.MinAckDelay != nil {
+		logString += ", MinAckDelay: %s"
+		logParams = append(logParams, *p.MinAckDelay)
+	}
+	if p.EnableMultipath {
+		logString += ", EnableMultipath: true"
+	}
+	if p.EnablePartialReliability {
+		logString += ", EnablePartialReliability: true"
+	}
+	if p.EnableReliableStreamReset {
+		logString += ", EnableReliableStreamReset: true"
+	}
 	logString += "}"
 	return fmt.Sprintf(logString, logParams...)
 }
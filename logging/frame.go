@@ -12,12 +12,18 @@ type AckRange = wire.AckRange
 type (
 	// An AckFrame is an ACK frame.
 	AckFrame = wire.AckFrame
+	// An AckFrequencyFrame is an ACK_FREQUENCY frame.
+	AckFrequencyFrame = wire.AckFrequencyFrame
 	// A ConnectionCloseFrame is a CONNECTION_CLOSE frame.
 	ConnectionCloseFrame = wire.ConnectionCloseFrame
 	// A DataBlockedFrame is a DATA_BLOCKED frame.
 	DataBlockedFrame = wire.DataBlockedFrame
+	// An ExpiredStreamDataFrame is an EXPIRED_STREAM_DATA frame.
+	ExpiredStreamDataFrame = wire.ExpiredStreamDataFrame
 	// A HandshakeDoneFrame is a HANDSHAKE_DONE frame.
 	HandshakeDoneFrame = wire.HandshakeDoneFrame
+	// An ImmediateAckFrame is an IMMEDIATE_ACK frame.
+	ImmediateAckFrame = wire.ImmediateAckFrame
 	// A MaxDataFrame is a MAX_DATA frame.
 	MaxDataFrame = wire.MaxDataFrame
 	// A MaxStreamDataFrame is a MAX_STREAM_DATA frame.
@@ -28,6 +34,8 @@ type (
 	NewConnectionIDFrame = wire.NewConnectionIDFrame
 	// A NewTokenFrame is a NEW_TOKEN frame.
 	NewTokenFrame = wire.NewTokenFrame
+	// A PathAbandonFrame is a PATH_ABANDON frame.
+	PathAbandonFrame = wire.PathAbandonFrame
 	// A PathChallengeFrame is a PATH_CHALLENGE frame.
 	PathChallengeFrame = wire.PathChallengeFrame
 	// A PathResponseFrame is a PATH_RESPONSE frame.
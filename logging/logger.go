@@ -0,0 +1,37 @@
+package logging
+
+import "github.com/lucas-clemente/quic-go/internal/utils"
+
+// A LogLevel is the level of a log message.
+type LogLevel = utils.LogLevel
+
+const (
+	// LogLevelError is used for errors
+	LogLevelError LogLevel = utils.LogLevelError
+	// LogLevelInfo is used for informational messages, e.g. connections being established and closed
+	LogLevelInfo LogLevel = utils.LogLevelInfo
+	// LogLevelDebug is used for debug messages, e.g. for every packet sent and received
+	LogLevelDebug LogLevel = utils.LogLevelDebug
+)
+
+// A Logger is a structured logger.
+// It can be set on the quic.Config, as well as on the http3.Server and http3.RoundTripper,
+// replacing the logger configured by the QUIC_GO_LOG_LEVEL environment variable.
+// quic-go adds key-value pairs to the loggers it creates, e.g. "scope" for the component that logs,
+// and "odcid" for the original destination connection ID of the connection.
+//
+// Adapters for other logging libraries only need a few lines of code.
+// For example, a log/slog adapter could look like this:
+//
+//	type slogLogger struct{ l *slog.Logger }
+//
+//	func (l slogLogger) Enabled(level logging.LogLevel) bool {
+//		return l.l.Enabled(context.Background(), toSlogLevel(level))
+//	}
+//	func (l slogLogger) Log(level logging.LogLevel, msg string, keyvals ...interface{}) {
+//		l.l.Log(context.Background(), toSlogLevel(level), msg, keyvals...)
+//	}
+//	func (l slogLogger) With(keyvals ...interface{}) logging.Logger {
+//		return slogLogger{l.l.With(keyvals...)}
+//	}
+type Logger = utils.StructuredLogger
@@ -0,0 +1,13 @@
+module github.com/lucas-clemente/quic-go/metrics
+
+go 1.16
+
+require (
+	// The version doesn't matter here, as we're replacing it with the currently checked out code anyway.
+	github.com/lucas-clemente/quic-go v0.27.0
+	github.com/onsi/ginkgo v1.16.4
+	github.com/onsi/gomega v1.13.0
+	github.com/prometheus/client_golang v1.14.0
+)
+
+replace github.com/lucas-clemente/quic-go => ../
@@ -0,0 +1,143 @@
+// Package metrics provides Prometheus metrics for quic-go.
+//
+// The metrics are collected by a logging.Tracer, which is set on the quic.Config.
+// HTTP/3 requests are recorded by the LogRequest callback of the http3.Server:
+//
+//	c := metrics.NewCollector()
+//	prometheus.MustRegister(c)
+//	quicConf := &quic.Config{Tracer: c.NewTracer()}
+//	server := &http3.Server{QuicConfig: quicConf, LogRequest: c.LogRequest}
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/lucas-clemente/quic-go/logging"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "quicgo"
+
+// A Collector collects metrics about QUIC connections and HTTP/3 requests.
+// It implements the prometheus.Collector interface.
+type Collector struct {
+	connsStarted      *prometheus.CounterVec
+	connsClosed       *prometheus.CounterVec
+	handshakes        *prometheus.CounterVec
+	handshakeDuration *prometheus.HistogramVec
+	packetsLost       *prometheus.CounterVec
+	packetsDropped    *prometheus.CounterVec
+	streamErrors      *prometheus.CounterVec
+	datagrams         *prometheus.CounterVec
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+}
+
+var _ prometheus.Collector = &Collector{}
+
+// NewCollector creates a new Collector.
+// It must be registered with a prometheus.Registerer to be exported.
+func NewCollector() *Collector {
+	return &Collector{
+		connsStarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connections_started_total",
+			Help:      "Connections dialed (dir=outgoing) and accepted (dir=incoming)",
+		}, []string{"dir"}),
+		connsClosed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connections_closed_total",
+			Help:      "Connections closed, by reason",
+		}, []string{"dir", "reason"}),
+		handshakes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handshakes_total",
+			Help:      "Handshakes, by result",
+		}, []string{"dir", "result"}),
+		handshakeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handshake_duration_seconds",
+			Help:      "Duration of successful handshakes",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"dir"}),
+		packetsLost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_lost_total",
+			Help:      "Packets declared lost, whose frames are retransmitted",
+		}, []string{"dir", "encryption_level", "reason"}),
+		packetsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_dropped_total",
+			Help:      "Received packets that were dropped",
+		}, []string{"reason"}),
+		streamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_errors_total",
+			Help:      "RESET_STREAM and STOP_SENDING frames sent and received",
+		}, []string{"dir", "direction", "frame"}),
+		datagrams: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "datagrams_total",
+			Help:      "DATAGRAM frames sent and received",
+		}, []string{"dir", "direction"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http3_requests_total",
+			Help:      "HTTP/3 requests handled by the server",
+		}, []string{"method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http3_request_duration_seconds",
+			Help:      "Duration of HTTP/3 requests handled by the server",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.connsStarted,
+		c.connsClosed,
+		c.handshakes,
+		c.handshakeDuration,
+		c.packetsLost,
+		c.packetsDropped,
+		c.streamErrors,
+		c.datagrams,
+		c.requests,
+		c.requestDuration,
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, col := range c.collectors() {
+		col.Describe(ch)
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, col := range c.collectors() {
+		col.Collect(ch)
+	}
+}
+
+// NewTracer creates a logging.Tracer that records metrics for all connections it is used for.
+// It can be combined with other tracers using logging.NewMultiplexedTracer.
+func (c *Collector) NewTracer() logging.Tracer {
+	return &tracer{collector: c}
+}
+
+// LogRequest records a request handled by the http3.Server.
+// It is meant to be used as the http3.Server's LogRequest callback.
+func (c *Collector) LogRequest(l *http3.RequestLog) {
+	code := "none" // the handler panicked before writing the header
+	if l.Status != 0 {
+		code = strconv.Itoa(l.Status)
+	}
+	c.requests.WithLabelValues(l.Method, code).Inc()
+	c.requestDuration.WithLabelValues(l.Method).Observe(l.Duration.Seconds())
+}
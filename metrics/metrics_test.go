@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/http3"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Collector", func() {
+	It("registers", func() {
+		reg := prometheus.NewPedanticRegistry()
+		Expect(reg.Register(NewCollector())).To(Succeed())
+	})
+
+	It("records requests", func() {
+		c := NewCollector()
+		c.LogRequest(&http3.RequestLog{Method: http.MethodGet, Status: http.StatusOK, Duration: time.Second})
+		c.LogRequest(&http3.RequestLog{Method: http.MethodGet, Status: http.StatusOK, Duration: time.Second})
+		c.LogRequest(&http3.RequestLog{Method: http.MethodPost, Status: http.StatusNotFound})
+		Expect(testutil.ToFloat64(c.requests.WithLabelValues(http.MethodGet, "200"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(c.requests.WithLabelValues(http.MethodPost, "404"))).To(Equal(1.0))
+		Expect(testutil.CollectAndCount(c.requestDuration)).To(Equal(2))
+	})
+
+	It("records requests whose handler panicked before writing the header", func() {
+		c := NewCollector()
+		c.LogRequest(&http3.RequestLog{Method: http.MethodGet})
+		Expect(testutil.ToFloat64(c.requests.WithLabelValues(http.MethodGet, "none"))).To(Equal(1.0))
+	})
+
+	It("exports the metrics", func() {
+		c := NewCollector()
+		c.LogRequest(&http3.RequestLog{Method: http.MethodGet, Status: http.StatusOK})
+		Expect(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP quicgo_http3_requests_total HTTP/3 requests handled by the server
+# TYPE quicgo_http3_requests_total counter
+quicgo_http3_requests_total{code="200",method="GET"} 1
+`), "quicgo_http3_requests_total")).To(Succeed())
+	})
+})
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+type tracer struct {
+	collector *Collector
+}
+
+var _ logging.Tracer = &tracer{}
+
+func (t *tracer) TracerForConnection(_ context.Context, p logging.Perspective, _ logging.ConnectionID) logging.ConnectionTracer {
+	return &connectionTracer{collector: t.collector, dir: dirLabel(p)}
+}
+
+func (t *tracer) SentPacket(net.Addr, *logging.Header, logging.ByteCount, []logging.Frame) {}
+
+func (t *tracer) DroppedPacket(_ net.Addr, _ logging.PacketType, _ logging.ByteCount, reason logging.PacketDropReason) {
+	t.collector.packetsDropped.WithLabelValues(dropReasonLabel(reason)).Inc()
+}
+
+// connectionTracer records metrics for a single connection.
+// All methods are called from the connection's run loop, so no synchronization is needed.
+type connectionTracer struct {
+	collector *Collector
+	dir       string
+
+	startTime         time.Time
+	handshakeComplete bool
+}
+
+var _ logging.ConnectionTracer = &connectionTracer{}
+
+func (t *connectionTracer) StartedConnection(net.Addr, net.Addr, logging.ConnectionID, logging.ConnectionID) {
+	t.startTime = time.Now()
+	t.collector.connsStarted.WithLabelValues(t.dir).Inc()
+}
+
+func (t *connectionTracer) NegotiatedVersion(logging.VersionNumber, []logging.VersionNumber, []logging.VersionNumber) {
+}
+
+func (t *connectionTracer) ClosedConnection(e error) {
+	if !t.handshakeComplete {
+		t.collector.handshakes.WithLabelValues(t.dir, "failure").Inc()
+	}
+	t.collector.connsClosed.WithLabelValues(t.dir, closeReasonLabel(e)).Inc()
+}
+
+func (t *connectionTracer) SentTransportParameters(*logging.TransportParameters)     {}
+func (t *connectionTracer) ReceivedTransportParameters(*logging.TransportParameters) {}
+func (t *connectionTracer) RestoredTransportParameters(*logging.TransportParameters) {}
+
+func (t *connectionTracer) SentPacket(_ *logging.ExtendedHeader, _ logging.ByteCount, _ *logging.AckFrame, frames []logging.Frame) {
+	t.recordFrames("sent", frames)
+}
+
+func (t *connectionTracer) ReceivedVersionNegotiationPacket(*logging.Header, []logging.VersionNumber) {
+}
+func (t *connectionTracer) ReceivedRetry(*logging.Header) {}
+
+func (t *connectionTracer) ReceivedPacket(_ *logging.ExtendedHeader, _ logging.ByteCount, frames []logging.Frame) {
+	t.recordFrames("received", frames)
+}
+
+func (t *connectionTracer) recordFrames(direction string, frames []logging.Frame) {
+	for _, f := range frames {
+		switch f.(type) {
+		case *logging.HandshakeDoneFrame:
+			// The server sends the HANDSHAKE_DONE frame when the handshake completes,
+			// and the client considers the handshake confirmed when it receives it.
+			t.completeHandshake()
+		case *logging.ResetStreamFrame:
+			t.collector.streamErrors.WithLabelValues(t.dir, direction, "reset_stream").Inc()
+		case *logging.StopSendingFrame:
+			t.collector.streamErrors.WithLabelValues(t.dir, direction, "stop_sending").Inc()
+		case *logging.DatagramFrame:
+			t.collector.datagrams.WithLabelValues(t.dir, direction).Inc()
+		}
+	}
+}
+
+func (t *connectionTracer) completeHandshake() {
+	if t.handshakeComplete {
+		return
+	}
+	t.handshakeComplete = true
+	t.collector.handshakes.WithLabelValues(t.dir, "success").Inc()
+	t.collector.handshakeDuration.WithLabelValues(t.dir).Observe(time.Since(t.startTime).Seconds())
+}
+
+func (t *connectionTracer) BufferedPacket(logging.PacketType) {}
+func (t *connectionTracer) DroppedPacket(_ logging.PacketType, _ logging.ByteCount, reason logging.PacketDropReason) {
+	t.collector.packetsDropped.WithLabelValues(dropReasonLabel(reason)).Inc()
+}
+
+func (t *connectionTracer) UpdatedMetrics(*logging.RTTStats, logging.ByteCount, logging.ByteCount, int) {
+}
+func (t *connectionTracer) AcknowledgedPacket(logging.EncryptionLevel, logging.PacketNumber) {}
+
+func (t *connectionTracer) LostPacket(encLevel logging.EncryptionLevel, _ logging.PacketNumber, reason logging.PacketLossReason) {
+	t.collector.packetsLost.WithLabelValues(t.dir, encLevel.String(), lossReasonLabel(reason)).Inc()
+}
+
+func (t *connectionTracer) UpdatedCongestionState(logging.CongestionState)                     {}
+func (t *connectionTracer) UpdatedPTOCount(uint32)                                             {}
+func (t *connectionTracer) UpdatedKeyFromTLS(logging.EncryptionLevel, logging.Perspective)     {}
+func (t *connectionTracer) UpdatedKey(logging.KeyPhase, bool)                                  {}
+func (t *connectionTracer) DroppedEncryptionLevel(logging.EncryptionLevel)                     {}
+func (t *connectionTracer) DroppedKey(logging.KeyPhase)                                        {}
+func (t *connectionTracer) SetLossTimer(logging.TimerType, logging.EncryptionLevel, time.Time) {}
+func (t *connectionTracer) LossTimerExpired(logging.TimerType, logging.EncryptionLevel)        {}
+func (t *connectionTracer) LossTimerCanceled()                                                 {}
+func (t *connectionTracer) Close()                                                             {}
+func (t *connectionTracer) Debug(string, string)                                               {}
+
+func dirLabel(p logging.Perspective) string {
+	if p == logging.PerspectiveServer {
+		return "incoming"
+	}
+	return "outgoing"
+}
+
+func closeReasonLabel(e error) string {
+	var (
+		idleTimeoutErr        *quic.IdleTimeoutError
+		handshakeTimeoutErr   *quic.HandshakeTimeoutError
+		statelessResetErr     *quic.StatelessResetError
+		versionNegotiationErr *quic.VersionNegotiationError
+		transportErr          *quic.TransportError
+		applicationErr        *quic.ApplicationError
+	)
+	switch {
+	case errors.As(e, &idleTimeoutErr):
+		return "idle_timeout"
+	case errors.As(e, &handshakeTimeoutErr):
+		return "handshake_timeout"
+	case errors.As(e, &statelessResetErr):
+		return "stateless_reset"
+	case errors.As(e, &versionNegotiationErr):
+		return "version_negotiation"
+	case errors.As(e, &transportErr):
+		return "transport_error"
+	case errors.As(e, &applicationErr):
+		return "application_error"
+	default:
+		return "other"
+	}
+}
+
+func lossReasonLabel(r logging.PacketLossReason) string {
+	switch r {
+	case logging.PacketLossReorderingThreshold:
+		return "reordering_threshold"
+	case logging.PacketLossTimeThreshold:
+		return "time_threshold"
+	default:
+		return "unknown"
+	}
+}
+
+func dropReasonLabel(r logging.PacketDropReason) string {
+	switch r {
+	case logging.PacketDropKeyUnavailable:
+		return "key_unavailable"
+	case logging.PacketDropUnknownConnectionID:
+		return "unknown_connection_id"
+	case logging.PacketDropHeaderParseError:
+		return "header_parse_error"
+	case logging.PacketDropPayloadDecryptError:
+		return "payload_decrypt_error"
+	case logging.PacketDropProtocolViolation:
+		return "protocol_violation"
+	case logging.PacketDropDOSPrevention:
+		return "dos_prevention"
+	case logging.PacketDropUnsupportedVersion:
+		return "unsupported_version"
+	case logging.PacketDropUnexpectedPacket:
+		return "unexpected_packet"
+	case logging.PacketDropUnexpectedSourceConnectionID:
+		return "unexpected_source_connection_id"
+	case logging.PacketDropUnexpectedVersion:
+		return "unexpected_version"
+	case logging.PacketDropDuplicate:
+		return "duplicate"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/logging"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		c      *Collector
+		tracer logging.ConnectionTracer
+	)
+
+	BeforeEach(func() {
+		c = NewCollector()
+		tracer = c.NewTracer().TracerForConnection(context.Background(), logging.PerspectiveServer, nil)
+		tracer.StartedConnection(nil, nil, nil, nil)
+	})
+
+	It("counts connections", func() {
+		Expect(testutil.ToFloat64(c.connsStarted.WithLabelValues("incoming"))).To(Equal(1.0))
+		c.NewTracer().TracerForConnection(context.Background(), logging.PerspectiveClient, nil).StartedConnection(nil, nil, nil, nil)
+		Expect(testutil.ToFloat64(c.connsStarted.WithLabelValues("outgoing"))).To(Equal(1.0))
+	})
+
+	It("records successful handshakes", func() {
+		tracer.SentPacket(&logging.ExtendedHeader{}, 1000, nil, []logging.Frame{&logging.HandshakeDoneFrame{}})
+		tracer.SentPacket(&logging.ExtendedHeader{}, 1000, nil, []logging.Frame{&logging.HandshakeDoneFrame{}}) // retransmission
+		Expect(testutil.ToFloat64(c.handshakes.WithLabelValues("incoming", "success"))).To(Equal(1.0))
+		Expect(testutil.CollectAndCount(c.handshakeDuration)).To(Equal(1))
+		tracer.ClosedConnection(&quic.IdleTimeoutError{})
+		Expect(testutil.ToFloat64(c.handshakes.WithLabelValues("incoming", "failure"))).To(BeZero())
+		Expect(testutil.ToFloat64(c.connsClosed.WithLabelValues("incoming", "idle_timeout"))).To(Equal(1.0))
+	})
+
+	It("records failed handshakes", func() {
+		tracer.ClosedConnection(&quic.HandshakeTimeoutError{})
+		Expect(testutil.ToFloat64(c.handshakes.WithLabelValues("incoming", "failure"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(c.connsClosed.WithLabelValues("incoming", "handshake_timeout"))).To(Equal(1.0))
+	})
+
+	It("labels the close reason", func() {
+		Expect(closeReasonLabel(&quic.ApplicationError{Remote: true})).To(Equal("application_error"))
+		Expect(closeReasonLabel(&quic.TransportError{ErrorCode: quic.ProtocolViolation})).To(Equal("transport_error"))
+		Expect(closeReasonLabel(&quic.StatelessResetError{})).To(Equal("stateless_reset"))
+		Expect(closeReasonLabel(&quic.VersionNegotiationError{})).To(Equal("version_negotiation"))
+		Expect(closeReasonLabel(errors.New("foobar"))).To(Equal("other"))
+	})
+
+	It("counts stream errors", func() {
+		tracer.SentPacket(&logging.ExtendedHeader{}, 1000, nil, []logging.Frame{&logging.ResetStreamFrame{}, &logging.StopSendingFrame{}})
+		tracer.ReceivedPacket(&logging.ExtendedHeader{}, 1000, []logging.Frame{&logging.ResetStreamFrame{}})
+		Expect(testutil.ToFloat64(c.streamErrors.WithLabelValues("incoming", "sent", "reset_stream"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(c.streamErrors.WithLabelValues("incoming", "sent", "stop_sending"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(c.streamErrors.WithLabelValues("incoming", "received", "reset_stream"))).To(Equal(1.0))
+	})
+
+	It("counts datagrams", func() {
+		tracer.SentPacket(&logging.ExtendedHeader{}, 1000, nil, []logging.Frame{&logging.DatagramFrame{}})
+		tracer.ReceivedPacket(&logging.ExtendedHeader{}, 1000, []logging.Frame{&logging.DatagramFrame{}, &logging.DatagramFrame{}})
+		Expect(testutil.ToFloat64(c.datagrams.WithLabelValues("incoming", "sent"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(c.datagrams.WithLabelValues("incoming", "received"))).To(Equal(2.0))
+	})
+
+	It("counts lost packets", func() {
+		tracer.LostPacket(logging.Encryption1RTT, 42, logging.PacketLossTimeThreshold)
+		Expect(testutil.ToFloat64(c.packetsLost.WithLabelValues("incoming", "1-RTT", "time_threshold"))).To(Equal(1.0))
+	})
+
+	It("counts dropped packets", func() {
+		tracer.DroppedPacket(logging.PacketType1RTT, 1000, logging.PacketDropDuplicate)
+		c.NewTracer().DroppedPacket(nil, logging.PacketTypeNotDetermined, 1000, logging.PacketDropUnknownConnectionID)
+		Expect(testutil.ToFloat64(c.packetsDropped.WithLabelValues("duplicate"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(c.packetsDropped.WithLabelValues("unknown_connection_id"))).To(Equal(1.0))
+	})
+
+	It("records handshakes of real connections", func() {
+		c := NewCollector()
+		tlsConf := testdata.GetTLSConfig()
+		tlsConf.NextProtos = []string{"quic-test"}
+		ln, err := quic.ListenAddr("localhost:0", tlsConf, &quic.Config{Tracer: c.NewTracer()})
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		conn, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+			&tls.Config{RootCAs: testdata.GetRootCA(), NextProtos: []string{"quic-test"}},
+			&quic.Config{Tracer: c.NewTracer()},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.CloseWithError(0, "")
+		Eventually(func() float64 {
+			return testutil.ToFloat64(c.handshakes.WithLabelValues("incoming", "success"))
+		}).Should(Equal(1.0))
+		Eventually(func() float64 {
+			return testutil.ToFloat64(c.handshakes.WithLabelValues("outgoing", "success"))
+		}).Should(Equal(1.0))
+		Expect(testutil.ToFloat64(c.connsStarted.WithLabelValues("incoming"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(c.connsStarted.WithLabelValues("outgoing"))).To(Equal(1.0))
+	})
+})
@@ -86,6 +86,18 @@ func (mr *MockConnRunnerMockRecorder) Remove(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockConnRunner)(nil).Remove), arg0)
 }
 
+// RemoveHandler mocks base method.
+func (m *MockConnRunner) RemoveHandler(arg0 packetHandler) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RemoveHandler", arg0)
+}
+
+// RemoveHandler indicates an expected call of RemoveHandler.
+func (mr *MockConnRunnerMockRecorder) RemoveHandler(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveHandler", reflect.TypeOf((*MockConnRunner)(nil).RemoveHandler), arg0)
+}
+
 // RemoveResetToken mocks base method.
 func (m *MockConnRunner) RemoveResetToken(arg0 protocol.StatelessResetToken) {
 	m.ctrl.T.Helper()
@@ -154,6 +154,21 @@ func (mr *MockPackerMockRecorder) PackPacket() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PackPacket", reflect.TypeOf((*MockPacker)(nil).PackPacket))
 }
 
+// PackPathProbePacket mocks base method.
+func (m *MockPacker) PackPathProbePacket(connID protocol.ConnectionID, f ackhandler.Frame, size protocol.ByteCount) (*packedPacket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PackPathProbePacket", connID, f, size)
+	ret0, _ := ret[0].(*packedPacket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PackPathProbePacket indicates an expected call of PackPathProbePacket.
+func (mr *MockPackerMockRecorder) PackPathProbePacket(connID, f, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PackPathProbePacket", reflect.TypeOf((*MockPacker)(nil).PackPathProbePacket), connID, f, size)
+}
+
 // SetMaxPacketSize mocks base method.
 func (m *MockPacker) SetMaxPacketSize(arg0 protocol.ByteCount) {
 	m.ctrl.T.Helper()
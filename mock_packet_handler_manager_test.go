@@ -5,6 +5,7 @@
 package quic
 
 import (
+	net "net"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -74,6 +75,20 @@ func (mr *MockPacketHandlerManagerMockRecorder) AddWithConnID(arg0, arg1, arg2 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithConnID", reflect.TypeOf((*MockPacketHandlerManager)(nil).AddWithConnID), arg0, arg1, arg2)
 }
 
+// AddWithRemoteAddr mocks base method.
+func (m *MockPacketHandlerManager) AddWithRemoteAddr(arg0 net.Addr, arg1 packetHandler) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWithRemoteAddr", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AddWithRemoteAddr indicates an expected call of AddWithRemoteAddr.
+func (mr *MockPacketHandlerManagerMockRecorder) AddWithRemoteAddr(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithRemoteAddr", reflect.TypeOf((*MockPacketHandlerManager)(nil).AddWithRemoteAddr), arg0, arg1)
+}
+
 // CloseServer mocks base method.
 func (m *MockPacketHandlerManager) CloseServer() {
 	m.ctrl.T.Helper()
@@ -126,6 +141,30 @@ func (mr *MockPacketHandlerManagerMockRecorder) Remove(arg0 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockPacketHandlerManager)(nil).Remove), arg0)
 }
 
+// RemoveHandler mocks base method.
+func (m *MockPacketHandlerManager) RemoveHandler(arg0 packetHandler) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RemoveHandler", arg0)
+}
+
+// RemoveHandler indicates an expected call of RemoveHandler.
+func (mr *MockPacketHandlerManagerMockRecorder) RemoveHandler(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveHandler", reflect.TypeOf((*MockPacketHandlerManager)(nil).RemoveHandler), arg0)
+}
+
+// RemoveRemoteAddr mocks base method.
+func (m *MockPacketHandlerManager) RemoveRemoteAddr(arg0 net.Addr) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RemoveRemoteAddr", arg0)
+}
+
+// RemoveRemoteAddr indicates an expected call of RemoveRemoteAddr.
+func (mr *MockPacketHandlerManagerMockRecorder) RemoveRemoteAddr(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveRemoteAddr", reflect.TypeOf((*MockPacketHandlerManager)(nil).RemoveRemoteAddr), arg0)
+}
+
 // RemoveResetToken mocks base method.
 func (m *MockPacketHandlerManager) RemoveResetToken(arg0 protocol.StatelessResetToken) {
 	m.ctrl.T.Helper()
@@ -138,6 +177,18 @@ func (mr *MockPacketHandlerManagerMockRecorder) RemoveResetToken(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveResetToken", reflect.TypeOf((*MockPacketHandlerManager)(nil).RemoveResetToken), arg0)
 }
 
+// ReplaceRemoteAddrWithClosed mocks base method.
+func (m *MockPacketHandlerManager) ReplaceRemoteAddrWithClosed(arg0 net.Addr, arg1 packetHandler) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReplaceRemoteAddrWithClosed", arg0, arg1)
+}
+
+// ReplaceRemoteAddrWithClosed indicates an expected call of ReplaceRemoteAddrWithClosed.
+func (mr *MockPacketHandlerManagerMockRecorder) ReplaceRemoteAddrWithClosed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceRemoteAddrWithClosed", reflect.TypeOf((*MockPacketHandlerManager)(nil).ReplaceRemoteAddrWithClosed), arg0, arg1)
+}
+
 // ReplaceWithClosed mocks base method.
 func (m *MockPacketHandlerManager) ReplaceWithClosed(arg0 protocol.ConnectionID, arg1 packetHandler) {
 	m.ctrl.T.Helper()
@@ -8,6 +8,7 @@ import (
 	context "context"
 	net "net"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
@@ -66,6 +67,21 @@ func (mr *MockQuicConnMockRecorder) AcceptUniStream(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptUniStream", reflect.TypeOf((*MockQuicConn)(nil).AcceptUniStream), arg0)
 }
 
+// AddPath mocks base method.
+func (m *MockQuicConn) AddPath(arg0 context.Context, arg1 net.PacketConn) (PathID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPath", arg0, arg1)
+	ret0, _ := ret[0].(PathID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPath indicates an expected call of AddPath.
+func (mr *MockQuicConnMockRecorder) AddPath(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPath", reflect.TypeOf((*MockQuicConn)(nil).AddPath), arg0, arg1)
+}
+
 // CloseWithError mocks base method.
 func (m *MockQuicConn) CloseWithError(arg0 ApplicationErrorCode, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -150,6 +166,20 @@ func (mr *MockQuicConnMockRecorder) LocalAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LocalAddr", reflect.TypeOf((*MockQuicConn)(nil).LocalAddr))
 }
 
+// Migrate mocks base method.
+func (m *MockQuicConn) Migrate(arg0 context.Context, arg1 net.PacketConn) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Migrate", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Migrate indicates an expected call of Migrate.
+func (mr *MockQuicConnMockRecorder) Migrate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Migrate", reflect.TypeOf((*MockQuicConn)(nil).Migrate), arg0, arg1)
+}
+
 // NextConnection mocks base method.
 func (m *MockQuicConn) NextConnection() Connection {
 	m.ctrl.T.Helper()
@@ -224,6 +254,21 @@ func (mr *MockQuicConnMockRecorder) OpenUniStreamSync(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenUniStreamSync", reflect.TypeOf((*MockQuicConn)(nil).OpenUniStreamSync), arg0)
 }
 
+// ProbePath mocks base method.
+func (m *MockQuicConn) ProbePath(arg0 context.Context, arg1 net.PacketConn) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProbePath", arg0, arg1)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProbePath indicates an expected call of ProbePath.
+func (mr *MockQuicConnMockRecorder) ProbePath(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProbePath", reflect.TypeOf((*MockQuicConn)(nil).ProbePath), arg0, arg1)
+}
+
 // ReceiveMessage mocks base method.
 func (m *MockQuicConn) ReceiveMessage() ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -253,6 +298,20 @@ func (mr *MockQuicConnMockRecorder) RemoteAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteAddr", reflect.TypeOf((*MockQuicConn)(nil).RemoteAddr))
 }
 
+// RemovePath mocks base method.
+func (m *MockQuicConn) RemovePath(arg0 PathID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePath", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemovePath indicates an expected call of RemovePath.
+func (mr *MockQuicConnMockRecorder) RemovePath(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePath", reflect.TypeOf((*MockQuicConn)(nil).RemovePath), arg0)
+}
+
 // SendMessage mocks base method.
 func (m *MockQuicConn) SendMessage(arg0 []byte) error {
 	m.ctrl.T.Helper()
@@ -267,6 +326,48 @@ func (mr *MockQuicConnMockRecorder) SendMessage(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockQuicConn)(nil).SendMessage), arg0)
 }
 
+// SendMessageWithCallback mocks base method.
+func (m *MockQuicConn) SendMessageWithCallback(msg []byte, onDelivery func(bool)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessageWithCallback", msg, onDelivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMessageWithCallback indicates an expected call of SendMessageWithCallback.
+func (mr *MockQuicConnMockRecorder) SendMessageWithCallback(msg, onDelivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessageWithCallback", reflect.TypeOf((*MockQuicConn)(nil).SendMessageWithCallback), msg, onDelivery)
+}
+
+// Stats mocks base method.
+func (m *MockQuicConn) Stats() ConnectionStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(ConnectionStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockQuicConnMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockQuicConn)(nil).Stats))
+}
+
+// UpdateKeys mocks base method.
+func (m *MockQuicConn) UpdateKeys() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateKeys")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateKeys indicates an expected call of UpdateKeys.
+func (mr *MockQuicConnMockRecorder) UpdateKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKeys", reflect.TypeOf((*MockQuicConn)(nil).UpdateKeys))
+}
+
 // destroy mocks base method.
 func (m *MockQuicConn) destroy(arg0 error) {
 	m.ctrl.T.Helper()
@@ -5,6 +5,7 @@
 package quic
 
 import (
+	io "io"
 	reflect "reflect"
 	time "time"
 
@@ -36,6 +37,20 @@ func (m *MockReceiveStreamI) EXPECT() *MockReceiveStreamIMockRecorder {
 	return m.recorder
 }
 
+// Buffered mocks base method.
+func (m *MockReceiveStreamI) Buffered() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Buffered")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Buffered indicates an expected call of Buffered.
+func (mr *MockReceiveStreamIMockRecorder) Buffered() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Buffered", reflect.TypeOf((*MockReceiveStreamI)(nil).Buffered))
+}
+
 // CancelRead mocks base method.
 func (m *MockReceiveStreamI) CancelRead(arg0 StreamErrorCode) {
 	m.ctrl.T.Helper()
@@ -48,6 +63,21 @@ func (mr *MockReceiveStreamIMockRecorder) CancelRead(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelRead", reflect.TypeOf((*MockReceiveStreamI)(nil).CancelRead), arg0)
 }
 
+// Peek mocks base method.
+func (m *MockReceiveStreamI) Peek(n int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek", n)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockReceiveStreamIMockRecorder) Peek(n interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockReceiveStreamI)(nil).Peek), n)
+}
+
 // Read mocks base method.
 func (m *MockReceiveStreamI) Read(p []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -91,6 +121,21 @@ func (mr *MockReceiveStreamIMockRecorder) StreamID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamID", reflect.TypeOf((*MockReceiveStreamI)(nil).StreamID))
 }
 
+// WriteTo mocks base method.
+func (m *MockReceiveStreamI) WriteTo(w io.Writer) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteTo", w)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteTo indicates an expected call of WriteTo.
+func (mr *MockReceiveStreamIMockRecorder) WriteTo(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTo", reflect.TypeOf((*MockReceiveStreamI)(nil).WriteTo), w)
+}
+
 // closeForShutdown mocks base method.
 func (m *MockReceiveStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.T.Helper()
@@ -117,6 +162,20 @@ func (mr *MockReceiveStreamIMockRecorder) getWindowUpdate() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getWindowUpdate", reflect.TypeOf((*MockReceiveStreamI)(nil).getWindowUpdate))
 }
 
+// handleExpiredStreamDataFrame mocks base method.
+func (m *MockReceiveStreamI) handleExpiredStreamDataFrame(arg0 *wire.ExpiredStreamDataFrame) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "handleExpiredStreamDataFrame", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// handleExpiredStreamDataFrame indicates an expected call of handleExpiredStreamDataFrame.
+func (mr *MockReceiveStreamIMockRecorder) handleExpiredStreamDataFrame(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "handleExpiredStreamDataFrame", reflect.TypeOf((*MockReceiveStreamI)(nil).handleExpiredStreamDataFrame), arg0)
+}
+
 // handleResetStreamFrame mocks base method.
 func (m *MockReceiveStreamI) handleResetStreamFrame(arg0 *wire.ResetStreamFrame) error {
 	m.ctrl.T.Helper()
@@ -6,6 +6,7 @@ package quic
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	time "time"
 
@@ -13,6 +14,7 @@ import (
 	ackhandler "github.com/lucas-clemente/quic-go/internal/ackhandler"
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
 	wire "github.com/lucas-clemente/quic-go/internal/wire"
+	logging "github.com/lucas-clemente/quic-go/logging"
 )
 
 // MockSendStreamI is a mock of SendStreamI interface.
@@ -78,6 +80,57 @@ func (mr *MockSendStreamIMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockSendStreamI)(nil).Context))
 }
 
+// ReadFrom mocks base method.
+func (m *MockSendStreamI) ReadFrom(r io.Reader) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadFrom", r)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadFrom indicates an expected call of ReadFrom.
+func (mr *MockSendStreamIMockRecorder) ReadFrom(r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFrom", reflect.TypeOf((*MockSendStreamI)(nil).ReadFrom), r)
+}
+
+// SetDataExpiry mocks base method.
+func (m *MockSendStreamI) SetDataExpiry(maxAge time.Duration, maxBytes logging.ByteCount) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetDataExpiry", maxAge, maxBytes)
+}
+
+// SetDataExpiry indicates an expected call of SetDataExpiry.
+func (mr *MockSendStreamIMockRecorder) SetDataExpiry(maxAge, maxBytes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDataExpiry", reflect.TypeOf((*MockSendStreamI)(nil).SetDataExpiry), maxAge, maxBytes)
+}
+
+// SetPriority mocks base method.
+func (m *MockSendStreamI) SetPriority(arg0 StreamPriority) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPriority", arg0)
+}
+
+// SetPriority indicates an expected call of SetPriority.
+func (mr *MockSendStreamIMockRecorder) SetPriority(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPriority", reflect.TypeOf((*MockSendStreamI)(nil).SetPriority), arg0)
+}
+
+// SetReliableBoundary mocks base method.
+func (m *MockSendStreamI) SetReliableBoundary() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetReliableBoundary")
+}
+
+// SetReliableBoundary indicates an expected call of SetReliableBoundary.
+func (mr *MockSendStreamIMockRecorder) SetReliableBoundary() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReliableBoundary", reflect.TypeOf((*MockSendStreamI)(nil).SetReliableBoundary))
+}
+
 // SetWriteDeadline mocks base method.
 func (m *MockSendStreamI) SetWriteDeadline(t time.Time) error {
 	m.ctrl.T.Helper()
@@ -121,6 +174,40 @@ func (mr *MockSendStreamIMockRecorder) Write(p interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockSendStreamI)(nil).Write), p)
 }
 
+// WriteOwned mocks base method.
+func (m *MockSendStreamI) WriteOwned(bufs ...[]byte) (int, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range bufs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WriteOwned", varargs...)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteOwned indicates an expected call of WriteOwned.
+func (mr *MockSendStreamIMockRecorder) WriteOwned(bufs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOwned", reflect.TypeOf((*MockSendStreamI)(nil).WriteOwned), bufs...)
+}
+
+// WriteVectored mocks base method.
+func (m *MockSendStreamI) WriteVectored(bufs [][]byte) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteVectored", bufs)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteVectored indicates an expected call of WriteVectored.
+func (mr *MockSendStreamIMockRecorder) WriteVectored(bufs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteVectored", reflect.TypeOf((*MockSendStreamI)(nil).WriteVectored), bufs)
+}
+
 // closeForShutdown mocks base method.
 func (m *MockSendStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.T.Helper()
@@ -6,6 +6,7 @@ package quic
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	time "time"
 
@@ -13,6 +14,7 @@ import (
 	ackhandler "github.com/lucas-clemente/quic-go/internal/ackhandler"
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
 	wire "github.com/lucas-clemente/quic-go/internal/wire"
+	logging "github.com/lucas-clemente/quic-go/logging"
 )
 
 // MockStreamI is a mock of StreamI interface.
@@ -38,6 +40,20 @@ func (m *MockStreamI) EXPECT() *MockStreamIMockRecorder {
 	return m.recorder
 }
 
+// Buffered mocks base method.
+func (m *MockStreamI) Buffered() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Buffered")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Buffered indicates an expected call of Buffered.
+func (mr *MockStreamIMockRecorder) Buffered() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Buffered", reflect.TypeOf((*MockStreamI)(nil).Buffered))
+}
+
 // CancelRead mocks base method.
 func (m *MockStreamI) CancelRead(arg0 StreamErrorCode) {
 	m.ctrl.T.Helper()
@@ -90,6 +106,21 @@ func (mr *MockStreamIMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockStreamI)(nil).Context))
 }
 
+// Peek mocks base method.
+func (m *MockStreamI) Peek(n int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek", n)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockStreamIMockRecorder) Peek(n interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockStreamI)(nil).Peek), n)
+}
+
 // Read mocks base method.
 func (m *MockStreamI) Read(p []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -105,6 +136,33 @@ func (mr *MockStreamIMockRecorder) Read(p interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockStreamI)(nil).Read), p)
 }
 
+// ReadFrom mocks base method.
+func (m *MockStreamI) ReadFrom(r io.Reader) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadFrom", r)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadFrom indicates an expected call of ReadFrom.
+func (mr *MockStreamIMockRecorder) ReadFrom(r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFrom", reflect.TypeOf((*MockStreamI)(nil).ReadFrom), r)
+}
+
+// SetDataExpiry mocks base method.
+func (m *MockStreamI) SetDataExpiry(maxAge time.Duration, maxBytes logging.ByteCount) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetDataExpiry", maxAge, maxBytes)
+}
+
+// SetDataExpiry indicates an expected call of SetDataExpiry.
+func (mr *MockStreamIMockRecorder) SetDataExpiry(maxAge, maxBytes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDataExpiry", reflect.TypeOf((*MockStreamI)(nil).SetDataExpiry), maxAge, maxBytes)
+}
+
 // SetDeadline mocks base method.
 func (m *MockStreamI) SetDeadline(t time.Time) error {
 	m.ctrl.T.Helper()
@@ -119,6 +177,18 @@ func (mr *MockStreamIMockRecorder) SetDeadline(t interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDeadline", reflect.TypeOf((*MockStreamI)(nil).SetDeadline), t)
 }
 
+// SetPriority mocks base method.
+func (m *MockStreamI) SetPriority(arg0 StreamPriority) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPriority", arg0)
+}
+
+// SetPriority indicates an expected call of SetPriority.
+func (mr *MockStreamIMockRecorder) SetPriority(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPriority", reflect.TypeOf((*MockStreamI)(nil).SetPriority), arg0)
+}
+
 // SetReadDeadline mocks base method.
 func (m *MockStreamI) SetReadDeadline(t time.Time) error {
 	m.ctrl.T.Helper()
@@ -133,6 +203,18 @@ func (mr *MockStreamIMockRecorder) SetReadDeadline(t interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReadDeadline", reflect.TypeOf((*MockStreamI)(nil).SetReadDeadline), t)
 }
 
+// SetReliableBoundary mocks base method.
+func (m *MockStreamI) SetReliableBoundary() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetReliableBoundary")
+}
+
+// SetReliableBoundary indicates an expected call of SetReliableBoundary.
+func (mr *MockStreamIMockRecorder) SetReliableBoundary() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReliableBoundary", reflect.TypeOf((*MockStreamI)(nil).SetReliableBoundary))
+}
+
 // SetWriteDeadline mocks base method.
 func (m *MockStreamI) SetWriteDeadline(t time.Time) error {
 	m.ctrl.T.Helper()
@@ -176,6 +258,55 @@ func (mr *MockStreamIMockRecorder) Write(p interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockStreamI)(nil).Write), p)
 }
 
+// WriteOwned mocks base method.
+func (m *MockStreamI) WriteOwned(bufs ...[]byte) (int, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range bufs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WriteOwned", varargs...)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteOwned indicates an expected call of WriteOwned.
+func (mr *MockStreamIMockRecorder) WriteOwned(bufs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOwned", reflect.TypeOf((*MockStreamI)(nil).WriteOwned), bufs...)
+}
+
+// WriteTo mocks base method.
+func (m *MockStreamI) WriteTo(w io.Writer) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteTo", w)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteTo indicates an expected call of WriteTo.
+func (mr *MockStreamIMockRecorder) WriteTo(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTo", reflect.TypeOf((*MockStreamI)(nil).WriteTo), w)
+}
+
+// WriteVectored mocks base method.
+func (m *MockStreamI) WriteVectored(bufs [][]byte) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteVectored", bufs)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteVectored indicates an expected call of WriteVectored.
+func (mr *MockStreamIMockRecorder) WriteVectored(bufs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteVectored", reflect.TypeOf((*MockStreamI)(nil).WriteVectored), bufs)
+}
+
 // closeForShutdown mocks base method.
 func (m *MockStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.T.Helper()
@@ -202,6 +333,20 @@ func (mr *MockStreamIMockRecorder) getWindowUpdate() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getWindowUpdate", reflect.TypeOf((*MockStreamI)(nil).getWindowUpdate))
 }
 
+// handleExpiredStreamDataFrame mocks base method.
+func (m *MockStreamI) handleExpiredStreamDataFrame(arg0 *wire.ExpiredStreamDataFrame) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "handleExpiredStreamDataFrame", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// handleExpiredStreamDataFrame indicates an expected call of handleExpiredStreamDataFrame.
+func (mr *MockStreamIMockRecorder) handleExpiredStreamDataFrame(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "handleExpiredStreamDataFrame", reflect.TypeOf((*MockStreamI)(nil).handleExpiredStreamDataFrame), arg0)
+}
+
 // handleResetStreamFrame mocks base method.
 func (m *MockStreamI) handleResetStreamFrame(arg0 *wire.ResetStreamFrame) error {
 	m.ctrl.T.Helper()
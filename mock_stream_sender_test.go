@@ -70,3 +70,43 @@ func (mr *MockStreamSenderMockRecorder) queueControlFrame(arg0 interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "queueControlFrame", reflect.TypeOf((*MockStreamSender)(nil).queueControlFrame), arg0)
 }
+
+// setStreamPriority mocks base method.
+func (m *MockStreamSender) setStreamPriority(arg0 protocol.StreamID, arg1 StreamPriority) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "setStreamPriority", arg0, arg1)
+}
+
+// setStreamPriority indicates an expected call of setStreamPriority.
+func (mr *MockStreamSenderMockRecorder) setStreamPriority(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "setStreamPriority", reflect.TypeOf((*MockStreamSender)(nil).setStreamPriority), arg0, arg1)
+}
+
+// supportsPartialReliability mocks base method.
+func (m *MockStreamSender) supportsPartialReliability() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "supportsPartialReliability")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// supportsPartialReliability indicates an expected call of supportsPartialReliability.
+func (mr *MockStreamSenderMockRecorder) supportsPartialReliability() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "supportsPartialReliability", reflect.TypeOf((*MockStreamSender)(nil).supportsPartialReliability))
+}
+
+// supportsReliableStreamReset mocks base method.
+func (m *MockStreamSender) supportsReliableStreamReset() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "supportsReliableStreamReset")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// supportsReliableStreamReset indicates an expected call of supportsReliableStreamReset.
+func (mr *MockStreamSenderMockRecorder) supportsReliableStreamReset() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "supportsReliableStreamReset", reflect.TypeOf((*MockStreamSender)(nil).supportsReliableStreamReset))
+}
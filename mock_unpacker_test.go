@@ -9,6 +9,7 @@ import (
 	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
 	wire "github.com/lucas-clemente/quic-go/internal/wire"
 )
 
@@ -49,3 +50,18 @@ func (mr *MockUnpackerMockRecorder) Unpack(hdr, rcvTime, data interface{}) *gomo
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unpack", reflect.TypeOf((*MockUnpacker)(nil).Unpack), hdr, rcvTime, data)
 }
+
+// UnpackOnPath mocks base method.
+func (m *MockUnpacker) UnpackOnPath(hdr *wire.Header, rcvTime time.Time, data []byte, pathID uint64, largestRcvd protocol.PacketNumber) (*unpackedPacket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnpackOnPath", hdr, rcvTime, data, pathID, largestRcvd)
+	ret0, _ := ret[0].(*unpackedPacket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnpackOnPath indicates an expected call of UnpackOnPath.
+func (mr *MockUnpackerMockRecorder) UnpackOnPath(hdr, rcvTime, data, pathID, largestRcvd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpackOnPath", reflect.TypeOf((*MockUnpacker)(nil).UnpackOnPath), hdr, rcvTime, data, pathID, largestRcvd)
+}
@@ -0,0 +1,87 @@
+package quic
+
+import (
+	"errors"
+
+	"github.com/lucas-clemente/quic-go/internal/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+const initialPathID PathID = 0
+
+var (
+	errPathAbandoned = errors.New("path abandoned by peer")
+	errPathRemoved   = errors.New("path removed")
+)
+
+// A path is a network path of a multipath connection.
+// Every path uses its own packet number space, RTT estimate and congestion controller.
+// For the initial path, these are the ones of the connection.
+type path struct {
+	id     PathID
+	conn   sendConn
+	runner connRunner
+
+	// The connection ID we send to on this path, and its sequence number.
+	// The sequence number is used as the path identifier in the AEAD nonce for packets sent on this path.
+	destConnID protocol.ConnectionID
+	destSeq    uint64
+	// The sequence number of the connection ID the peer sends to on this path.
+	// The client learns it when it receives the first PATH_RESPONSE on a new path.
+	rcvSeq      uint64
+	rcvSeqKnown bool
+
+	rttStats              *utils.RTTStats
+	sentPacketHandler     ackhandler.SentPacketHandler
+	receivedPacketHandler ackhandler.ReceivedPacketHandler
+	packer                packer
+
+	largestRcvdPacketNumber protocol.PacketNumber
+
+	// set while the path is being validated
+	probe     *pathProbe
+	validated bool
+}
+
+func (p *path) Info() PathInfo {
+	return PathInfo{
+		ID:          p.id,
+		LocalAddr:   p.conn.LocalAddr(),
+		RemoteAddr:  p.conn.RemoteAddr(),
+		SmoothedRTT: p.rttStats.SmoothedRTT(),
+	}
+}
+
+type pathRemovalRequest struct {
+	id      PathID
+	errChan chan error
+}
+
+// The pathSealingManager returns 1-RTT sealers that protect packets sent on an additional path.
+type pathSealingManager struct {
+	sealingManager
+
+	pathID uint64
+}
+
+var _ sealingManager = &pathSealingManager{}
+
+func (m *pathSealingManager) Get1RTTSealer() (handshake.ShortHeaderSealer, error) {
+	sealer, err := m.sealingManager.Get1RTTSealer()
+	if err != nil {
+		return nil, err
+	}
+	return &pathSealer{ShortHeaderSealer: sealer, pathID: m.pathID}, nil
+}
+
+type pathSealer struct {
+	handshake.ShortHeaderSealer
+
+	pathID uint64
+}
+
+func (s *pathSealer) Seal(dst, src []byte, pn protocol.PacketNumber, ad []byte) []byte {
+	return s.SealOnPath(dst, src, s.pathID, pn, ad)
+}
@@ -0,0 +1,54 @@
+package quic
+
+func newMultipathScheduler(config *Config) MultipathScheduler {
+	switch config.MultipathScheduling {
+	case MultipathSchedulingRoundRobin:
+		return &roundRobinScheduler{}
+	case MultipathSchedulingCustom:
+		return config.NewMultipathScheduler()
+	default:
+		return &minRTTScheduler{}
+	}
+}
+
+// The minRTTScheduler sends on the path with the lowest smoothed RTT.
+// Paths that don't have an RTT estimate yet are only used if no other path is available.
+type minRTTScheduler struct{}
+
+var _ MultipathScheduler = &minRTTScheduler{}
+
+func (s *minRTTScheduler) SelectPath(paths []PathInfo) PathID {
+	best := paths[0]
+	for _, p := range paths[1:] {
+		if p.SmoothedRTT == 0 {
+			continue
+		}
+		if best.SmoothedRTT == 0 || p.SmoothedRTT < best.SmoothedRTT {
+			best = p
+		}
+	}
+	return best.ID
+}
+
+// The roundRobinScheduler sends on all paths in turn.
+type roundRobinScheduler struct {
+	lastPathID PathID
+	sentPacket bool
+}
+
+var _ MultipathScheduler = &roundRobinScheduler{}
+
+func (s *roundRobinScheduler) SelectPath(paths []PathInfo) PathID {
+	id := paths[0].ID
+	if s.sentPacket {
+		for _, p := range paths {
+			if p.ID > s.lastPathID {
+				id = p.ID
+				break
+			}
+		}
+	}
+	s.lastPathID = id
+	s.sentPacket = true
+	return id
+}
@@ -0,0 +1,80 @@
+package quic
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// The mockMultipathScheduler records the paths it is offered.
+// It selects the path with ID selected, if set, and the last path otherwise.
+type mockMultipathScheduler struct {
+	offered  [][]PathInfo
+	selected PathID
+}
+
+func (s *mockMultipathScheduler) SelectPath(paths []PathInfo) PathID {
+	s.offered = append(s.offered, paths)
+	if s.selected != 0 {
+		return s.selected
+	}
+	return paths[len(paths)-1].ID
+}
+
+var _ = Describe("Multipath Scheduler", func() {
+	It("creates the scheduler", func() {
+		Expect(newMultipathScheduler(&Config{})).To(BeAssignableToTypeOf(&minRTTScheduler{}))
+		Expect(newMultipathScheduler(&Config{MultipathScheduling: MultipathSchedulingRoundRobin})).To(BeAssignableToTypeOf(&roundRobinScheduler{}))
+		s := newMultipathScheduler(&Config{
+			MultipathScheduling:   MultipathSchedulingCustom,
+			NewMultipathScheduler: func() MultipathScheduler { return &mockMultipathScheduler{} },
+		})
+		Expect(s).To(BeAssignableToTypeOf(&mockMultipathScheduler{}))
+	})
+
+	Context("minimum RTT", func() {
+		It("selects the path with the lowest RTT", func() {
+			s := &minRTTScheduler{}
+			Expect(s.SelectPath([]PathInfo{
+				{ID: 0, SmoothedRTT: 20 * time.Millisecond},
+				{ID: 1, SmoothedRTT: 10 * time.Millisecond},
+				{ID: 2, SmoothedRTT: 30 * time.Millisecond},
+			})).To(Equal(PathID(1)))
+		})
+
+		It("selects the first path if multiple paths have the same RTT", func() {
+			s := &minRTTScheduler{}
+			Expect(s.SelectPath([]PathInfo{
+				{ID: 3, SmoothedRTT: 10 * time.Millisecond},
+				{ID: 5, SmoothedRTT: 10 * time.Millisecond},
+			})).To(Equal(PathID(3)))
+		})
+
+		It("only selects paths without an RTT estimate if there's no other path", func() {
+			s := &minRTTScheduler{}
+			Expect(s.SelectPath([]PathInfo{{ID: 0}, {ID: 1, SmoothedRTT: time.Second}})).To(Equal(PathID(1)))
+			Expect(s.SelectPath([]PathInfo{{ID: 0, SmoothedRTT: time.Second}, {ID: 1}})).To(Equal(PathID(0)))
+			Expect(s.SelectPath([]PathInfo{{ID: 0}, {ID: 1}})).To(Equal(PathID(0)))
+		})
+	})
+
+	Context("round robin", func() {
+		It("selects all paths in turn", func() {
+			s := &roundRobinScheduler{}
+			paths := []PathInfo{{ID: 0}, {ID: 2}, {ID: 3}}
+			Expect(s.SelectPath(paths)).To(Equal(PathID(0)))
+			Expect(s.SelectPath(paths)).To(Equal(PathID(2)))
+			Expect(s.SelectPath(paths)).To(Equal(PathID(3)))
+			Expect(s.SelectPath(paths)).To(Equal(PathID(0)))
+		})
+
+		It("skips paths that can't send", func() {
+			s := &roundRobinScheduler{}
+			Expect(s.SelectPath([]PathInfo{{ID: 0}, {ID: 1}, {ID: 2}})).To(Equal(PathID(0)))
+			Expect(s.SelectPath([]PathInfo{{ID: 0}, {ID: 2}})).To(Equal(PathID(2)))
+			Expect(s.SelectPath([]PathInfo{{ID: 0}, {ID: 1}})).To(Equal(PathID(0)))
+			Expect(s.SelectPath([]PathInfo{{ID: 1}, {ID: 2}})).To(Equal(PathID(1)))
+		})
+	})
+})
@@ -66,16 +66,18 @@ type packetHandlerMapEntry struct {
 // It is used:
 // * by the server to store connections
 // * when multiplexing outgoing connections to store clients
+// Clients using zero-length connection IDs on a shared packet conn are identified by their remote address.
 type packetHandlerMap struct {
 	mutex sync.Mutex
 
 	conn      rawConn
 	connIDLen int
 
-	handlers          map[string] /* string(ConnectionID)*/ packetHandlerMapEntry
-	resetTokens       map[protocol.StatelessResetToken] /* stateless reset token */ packetHandler
-	server            unknownPacketHandler
-	numZeroRTTEntries int
+	handlers           map[string] /* string(ConnectionID)*/ packetHandlerMapEntry
+	remoteAddrHandlers map[string] /* net.Addr.String() */ packetHandler
+	resetTokens        map[protocol.StatelessResetToken] /* stateless reset token */ packetHandler
+	server             unknownPacketHandler
+	numZeroRTTEntries  int
 
 	listening chan struct{} // is closed when listen returns
 	closed    bool
@@ -151,6 +153,7 @@ func newPacketHandlerMap(
 		connIDLen:               connIDLen,
 		listening:               make(chan struct{}),
 		handlers:                make(map[string]packetHandlerMapEntry),
+		remoteAddrHandlers:      make(map[string]packetHandler),
 		resetTokens:             make(map[protocol.StatelessResetToken]packetHandler),
 		deleteRetiredConnsAfter: protocol.RetiredConnectionIDDeleteTimeout,
 		zeroRTTQueueDuration:    protocol.Max0RTTQueueingDuration,
@@ -178,7 +181,7 @@ func (h *packetHandlerMap) logUsage() {
 		}
 
 		h.mutex.Lock()
-		numHandlers := len(h.handlers)
+		numHandlers := len(h.handlers) + len(h.remoteAddrHandlers)
 		numTokens := len(h.resetTokens)
 		h.mutex.Unlock()
 		// If the number tracked handlers and tokens is zero, only print it a single time.
@@ -265,6 +268,75 @@ func (h *packetHandlerMap) ReplaceWithClosed(id protocol.ConnectionID, handler p
 	})
 }
 
+// AddWithRemoteAddr adds a handler that is identified by the remote address.
+// It is used for connections that use a zero-length connection ID on a shared packet conn.
+// A closed connection to the same remote address is replaced.
+func (h *packetHandlerMap) AddWithRemoteAddr(addr net.Addr, handler packetHandler) bool /* was added */ {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if existing, ok := h.remoteAddrHandlers[addr.String()]; ok {
+		switch existing.(type) {
+		case *closedLocalConn, *closedRemoteConn:
+		default:
+			h.logger.Debugf("Not adding remote address %s, as it already exists.", addr)
+			return false
+		}
+	}
+	h.remoteAddrHandlers[addr.String()] = handler
+	h.logger.Debugf("Adding remote address %s.", addr)
+	return true
+}
+
+func (h *packetHandlerMap) RemoveRemoteAddr(addr net.Addr) {
+	h.mutex.Lock()
+	delete(h.remoteAddrHandlers, addr.String())
+	h.mutex.Unlock()
+	h.logger.Debugf("Removing remote address %s.", addr)
+}
+
+func (h *packetHandlerMap) ReplaceRemoteAddrWithClosed(addr net.Addr, handler packetHandler) {
+	h.mutex.Lock()
+	h.remoteAddrHandlers[addr.String()] = handler
+	h.mutex.Unlock()
+	h.logger.Debugf("Replacing connection for remote address %s with a closed connection.", addr)
+
+	time.AfterFunc(h.deleteRetiredConnsAfter, func() {
+		h.mutex.Lock()
+		handler.shutdown()
+		// The closed connection might already have been replaced by a new connection.
+		if h.remoteAddrHandlers[addr.String()] == handler {
+			delete(h.remoteAddrHandlers, addr.String())
+		}
+		h.mutex.Unlock()
+		h.logger.Debugf("Removing remote address %s for a closed connection after it has been retired.", addr)
+	})
+}
+
+// RemoveHandler removes all connection IDs and stateless reset tokens that belong to a handler.
+// This includes connection IDs that were retired, but not yet deleted.
+// It is used when a connection migrates to a different packet conn.
+func (h *packetHandlerMap) RemoveHandler(handler packetHandler) {
+	h.mutex.Lock()
+	for id, entry := range h.handlers {
+		if entry.packetHandler == handler {
+			delete(h.handlers, id)
+		}
+	}
+	for addr, hdlr := range h.remoteAddrHandlers {
+		if hdlr == handler {
+			delete(h.remoteAddrHandlers, addr)
+		}
+	}
+	for token, t := range h.resetTokens {
+		if t == handler {
+			delete(h.resetTokens, token)
+		}
+	}
+	h.mutex.Unlock()
+	h.logger.Debugf("Removing all connection IDs and stateless reset tokens for a connection.")
+}
+
 func (h *packetHandlerMap) AddResetToken(token protocol.StatelessResetToken, handler packetHandler) {
 	h.mutex.Lock()
 	h.resetTokens[token] = handler
@@ -330,6 +402,13 @@ func (h *packetHandlerMap) close(e error) error {
 			wg.Done()
 		}(entry.packetHandler)
 	}
+	for _, handler := range h.remoteAddrHandlers {
+		wg.Add(1)
+		go func(handler packetHandler) {
+			handler.destroy(e)
+			wg.Done()
+		}(handler)
+	}
 
 	if h.server != nil {
 		h.server.setCloseError(e)
@@ -378,6 +457,14 @@ func (h *packetHandlerMap) handlePacket(p *receivedPacket) {
 		return
 	}
 
+	// Packets for connections using zero-length connection IDs can only be demultiplexed by their remote address.
+	if h.connIDLen == 0 && len(h.remoteAddrHandlers) > 0 {
+		if handler, ok := h.remoteAddrHandlers[p.remoteAddr.String()]; ok {
+			handler.handlePacket(p)
+			return
+		}
+	}
+
 	if entry, ok := h.handlers[string(connID)]; ok {
 		if entry.is0RTTQueue { // only enqueue 0-RTT packets in the 0-RTT queue
 			if wire.Is0RTTPacket(p.data) {
@@ -430,6 +517,50 @@ func (h *packetHandlerMap) handlePacket(p *receivedPacket) {
 	h.server.handlePacket(p)
 }
 
+// A remoteAddrConnRunner is used by clients that use a zero-length connection ID on a shared packet conn.
+// It registers the zero-length connection ID using the remote address of the connection.
+type remoteAddrConnRunner struct {
+	packetHandlerManager
+	remoteAddr net.Addr
+}
+
+var _ packetHandlerManager = &remoteAddrConnRunner{}
+
+func newRemoteAddrConnRunner(m packetHandlerManager, remoteAddr net.Addr) *remoteAddrConnRunner {
+	return &remoteAddrConnRunner{packetHandlerManager: m, remoteAddr: remoteAddr}
+}
+
+func (r *remoteAddrConnRunner) Add(id protocol.ConnectionID, handler packetHandler) bool {
+	if id.Len() == 0 {
+		return r.AddWithRemoteAddr(r.remoteAddr, handler)
+	}
+	return r.packetHandlerManager.Add(id, handler)
+}
+
+func (r *remoteAddrConnRunner) Remove(id protocol.ConnectionID) {
+	if id.Len() == 0 {
+		r.RemoveRemoteAddr(r.remoteAddr)
+		return
+	}
+	r.packetHandlerManager.Remove(id)
+}
+
+func (r *remoteAddrConnRunner) Retire(id protocol.ConnectionID) {
+	if id.Len() == 0 {
+		r.RemoveRemoteAddr(r.remoteAddr)
+		return
+	}
+	r.packetHandlerManager.Retire(id)
+}
+
+func (r *remoteAddrConnRunner) ReplaceWithClosed(id protocol.ConnectionID, handler packetHandler) {
+	if id.Len() == 0 {
+		r.ReplaceRemoteAddrWithClosed(r.remoteAddr, handler)
+		return
+	}
+	r.packetHandlerManager.ReplaceWithClosed(id, handler)
+}
+
 func (h *packetHandlerMap) maybeHandleStatelessReset(data []byte) bool {
 	// stateless resets are always short header packets
 	if data[0]&0x80 != 0 {
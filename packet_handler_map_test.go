@@ -109,6 +109,9 @@ var _ = Describe("Packet Handler Map", func() {
 			for connID := range handler.handlers {
 				delete(handler.handlers, connID)
 			}
+			for addr := range handler.remoteAddrHandlers {
+				delete(handler.remoteAddrHandlers, addr)
+			}
 			handler.server = nil
 			handler.mutex.Unlock()
 			conn.EXPECT().Close().MaxTimes(1)
@@ -180,6 +183,24 @@ var _ = Describe("Packet Handler Map", func() {
 				// don't EXPECT any calls to handlePacket of the MockPacketHandler
 			})
 
+			It("removes all connection IDs and reset tokens of a handler", func() {
+				handler.deleteRetiredConnsAfter = time.Hour
+				connID1 := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+				connID2 := protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1}
+				connID3 := protocol.ConnectionID{1, 1, 1, 1, 1, 1, 1, 1}
+				conn := NewMockPacketHandler(mockCtrl)
+				otherConn := NewMockPacketHandler(mockCtrl)
+				handler.Add(connID1, conn)
+				handler.Add(connID2, conn)
+				handler.Retire(connID2)
+				handler.Add(connID3, otherConn)
+				handler.AddResetToken(protocol.StatelessResetToken{1, 2, 3}, conn)
+				handler.RemoveHandler(conn)
+				Expect(handler.handlers).To(HaveLen(1))
+				Expect(handler.handlers).To(HaveKey(string(connID3)))
+				Expect(handler.resetTokens).To(BeEmpty())
+			})
+
 			It("passes packets arriving late for closed connections to that connection", func() {
 				handler.deleteRetiredConnsAfter = time.Hour
 				connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
@@ -236,6 +257,96 @@ var _ = Describe("Packet Handler Map", func() {
 			})
 		})
 
+		Context("zero-length connection IDs", func() {
+			addr1 := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234}
+			addr2 := &net.UDPAddr{IP: net.IPv4(4, 3, 2, 1), Port: 4321}
+
+			It("demultiplexes packets using the remote address", func() {
+				packetHandler1 := NewMockPacketHandler(mockCtrl)
+				packetHandler2 := NewMockPacketHandler(mockCtrl)
+				handledPacket1 := make(chan struct{})
+				handledPacket2 := make(chan struct{})
+				packetHandler1.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) {
+					Expect(p.remoteAddr).To(Equal(addr1))
+					close(handledPacket1)
+				})
+				packetHandler2.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) {
+					Expect(p.remoteAddr).To(Equal(addr2))
+					close(handledPacket2)
+				})
+				Expect(handler.AddWithRemoteAddr(addr1, packetHandler1)).To(BeTrue())
+				Expect(handler.AddWithRemoteAddr(addr2, packetHandler2)).To(BeTrue())
+				packetChan <- packetToRead{addr: addr1, data: getPacket(protocol.ConnectionID{})}
+				packetChan <- packetToRead{addr: addr2, data: getPacket(protocol.ConnectionID{})}
+
+				Eventually(handledPacket1).Should(BeClosed())
+				Eventually(handledPacket2).Should(BeClosed())
+			})
+
+			It("says if a remote address is already taken", func() {
+				Expect(handler.AddWithRemoteAddr(addr1, NewMockPacketHandler(mockCtrl))).To(BeTrue())
+				Expect(handler.AddWithRemoteAddr(addr1, NewMockPacketHandler(mockCtrl))).To(BeFalse())
+			})
+
+			It("replaces closed connections", func() {
+				handler.deleteRetiredConnsAfter = time.Hour
+				Expect(handler.AddWithRemoteAddr(addr1, NewMockPacketHandler(mockCtrl))).To(BeTrue())
+				handler.ReplaceRemoteAddrWithClosed(addr1, newClosedRemoteConn(protocol.PerspectiveClient))
+				packetHandler := NewMockPacketHandler(mockCtrl)
+				Expect(handler.AddWithRemoteAddr(addr1, packetHandler)).To(BeTrue())
+				packetHandler.EXPECT().handlePacket(gomock.Any())
+				handler.handlePacket(&receivedPacket{remoteAddr: addr1, data: getPacket(protocol.ConnectionID{})})
+			})
+
+			It("deletes closed connections after a wait time", func() {
+				handler.deleteRetiredConnsAfter = scaleDuration(10 * time.Millisecond)
+				closedConn := NewMockPacketHandler(mockCtrl)
+				closed := make(chan struct{})
+				closedConn.EXPECT().shutdown().Do(func() { close(closed) })
+				handler.ReplaceRemoteAddrWithClosed(addr1, closedConn)
+				Eventually(closed).Should(BeClosed())
+				Eventually(func() int {
+					handler.mutex.Lock()
+					defer handler.mutex.Unlock()
+					return len(handler.remoteAddrHandlers)
+				}).Should(BeZero())
+			})
+
+			It("removes remote addresses", func() {
+				handler.AddWithRemoteAddr(addr1, NewMockPacketHandler(mockCtrl))
+				handler.RemoveRemoteAddr(addr1)
+				handler.handlePacket(&receivedPacket{remoteAddr: addr1, data: getPacket(protocol.ConnectionID{})})
+				// don't EXPECT any calls to handlePacket of the MockPacketHandler
+			})
+
+			It("removes the remote address of a handler", func() {
+				conn := NewMockPacketHandler(mockCtrl)
+				otherConn := NewMockPacketHandler(mockCtrl)
+				handler.AddWithRemoteAddr(addr1, conn)
+				handler.AddWithRemoteAddr(addr2, otherConn)
+				handler.RemoveHandler(conn)
+				Expect(handler.remoteAddrHandlers).To(HaveLen(1))
+				Expect(handler.remoteAddrHandlers).To(HaveKey(addr2.String()))
+			})
+
+			It("uses the remote address for zero-length connection IDs, when used as a connection runner", func() {
+				handler.deleteRetiredConnsAfter = time.Hour
+				runner := newRemoteAddrConnRunner(handler, addr1)
+				conn := NewMockPacketHandler(mockCtrl)
+				Expect(runner.Add(protocol.ConnectionID{}, conn)).To(BeTrue())
+				Expect(handler.remoteAddrHandlers).To(HaveKeyWithValue(addr1.String(), conn))
+				Expect(handler.handlers).To(BeEmpty())
+				closedConn := newClosedRemoteConn(protocol.PerspectiveClient)
+				runner.ReplaceWithClosed(protocol.ConnectionID{}, closedConn)
+				Expect(handler.remoteAddrHandlers).To(HaveKeyWithValue(addr1.String(), closedConn))
+				runner.Remove(protocol.ConnectionID{})
+				Expect(handler.remoteAddrHandlers).To(BeEmpty())
+				// non-zero-length connection IDs are added to the map
+				Expect(runner.Add(protocol.ConnectionID{1, 2, 3, 4}, conn)).To(BeTrue())
+				Expect(handler.handlers).To(HaveKey(string(protocol.ConnectionID{1, 2, 3, 4})))
+			})
+		})
+
 		Context("running a server", func() {
 			It("adds a server", func() {
 				connID := protocol.ConnectionID{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}
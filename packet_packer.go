@@ -25,6 +25,7 @@ type packer interface {
 
 	SetMaxPacketSize(protocol.ByteCount)
 	PackMTUProbePacket(ping ackhandler.Frame, size protocol.ByteCount) (*packedPacket, error)
+	PackPathProbePacket(connID protocol.ConnectionID, f ackhandler.Frame, size protocol.ByteCount) (*packedPacket, error)
 
 	HandleTransportParameters(*wire.TransportParameters)
 	SetToken([]byte)
@@ -52,7 +53,8 @@ type packetContents struct {
 
 	length protocol.ByteCount
 
-	isMTUProbePacket bool
+	isMTUProbePacket  bool
+	isPathProbePacket bool
 }
 
 type coalescedPacket struct {
@@ -108,6 +110,7 @@ func (p *packetContents) ToAckHandlerPacket(now time.Time, q *retransmissionQueu
 		EncryptionLevel:      encLevel,
 		SendTime:             now,
 		IsPathMTUProbePacket: p.isMTUProbePacket,
+		IsPathProbePacket:    p.isPathProbePacket,
 	}
 }
 
@@ -166,6 +169,10 @@ type packetPacker struct {
 	datagramQueue       *datagramQueue
 	retransmissionQueue *retransmissionQueue
 
+	datagramPriority DatagramPriority
+	// only used for DatagramPriorityFairShare
+	lastPacketHadDatagram bool
+
 	maxPacketSize          protocol.ByteCount
 	numNonAckElicitingAcks int
 }
@@ -184,6 +191,7 @@ func newPacketPacker(
 	framer frameSource,
 	acks ackFrameSource,
 	datagramQueue *datagramQueue,
+	datagramPriority DatagramPriority,
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
 ) *packetPacker {
@@ -195,6 +203,7 @@ func newPacketPacker(
 		handshakeStream:     handshakeStream,
 		retransmissionQueue: retransmissionQueue,
 		datagramQueue:       datagramQueue,
+		datagramPriority:    datagramPriority,
 		perspective:         perspective,
 		version:             version,
 		framer:              framer,
@@ -594,22 +603,29 @@ func (p *packetPacker) maybeGetAppDataPacketWithEncLevel(maxPayloadSize protocol
 func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, ackAllowed bool) *payload {
 	payload := &payload{frames: make([]ackhandler.Frame, 0, 1)}
 
+	hasData := p.framer.HasData()
+	hasRetransmission := p.retransmissionQueue.HasAppData()
+
 	var hasDatagram bool
-	if p.datagramQueue != nil {
-		if datagram := p.datagramQueue.Get(); datagram != nil {
-			payload.frames = append(payload.frames, ackhandler.Frame{
+	if p.datagramQueue != nil && p.shouldPackDatagram(hasData || hasRetransmission) {
+		if datagram, onDelivery := p.datagramQueue.Get(); datagram != nil {
+			frame := ackhandler.Frame{
 				Frame: datagram,
 				// set it to a no-op. Then we won't set the default callback, which would retransmit the frame.
 				OnLost: func(wire.Frame) {},
-			})
+			}
+			if onDelivery != nil {
+				frame.OnLost = func(wire.Frame) { onDelivery(false) }
+				frame.OnAcked = func(wire.Frame) { onDelivery(true) }
+			}
+			payload.frames = append(payload.frames, frame)
 			payload.length += datagram.Length(p.version)
 			hasDatagram = true
 		}
 	}
+	p.lastPacketHadDatagram = hasDatagram
 
 	var ack *wire.AckFrame
-	hasData := p.framer.HasData()
-	hasRetransmission := p.retransmissionQueue.HasAppData()
 	// TODO: make sure ACKs are sent when a lot of DATAGRAMs are queued
 	if !hasDatagram && ackAllowed {
 		ack = p.acks.GetAckFrame(protocol.Encryption1RTT, !hasRetransmission && !hasData)
@@ -649,6 +665,19 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, ackAll
 	return payload
 }
 
+// shouldPackDatagram decides if a DATAGRAM frame is packed into the next packet,
+// depending on the datagram priority and on whether other data is waiting to be sent.
+func (p *packetPacker) shouldPackDatagram(hasOtherData bool) bool {
+	switch p.datagramPriority {
+	case DatagramPriorityFairShare:
+		return !hasOtherData || !p.lastPacketHadDatagram
+	case DatagramPriorityBackground:
+		return !hasOtherData
+	default:
+		return true
+	}
+}
+
 func (p *packetPacker) MaybePackProbePacket(encLevel protocol.EncryptionLevel) (*packedPacket, error) {
 	var hdr *wire.ExtendedHeader
 	var payload *payload
@@ -722,6 +751,35 @@ func (p *packetPacker) PackMTUProbePacket(ping ackhandler.Frame, size protocol.B
 	}, nil
 }
 
+// PackPathProbePacket packs a packet containing a PATH_CHALLENGE or a PATH_RESPONSE frame,
+// to be sent on a path other than the one currently used.
+// The packet uses the connection ID connID, and it is padded to size bytes.
+// Paths are usually validated using packets of at least 1200 bytes. Smaller packets are only used
+// when the amount of data that can be sent to an unvalidated address is limited.
+func (p *packetPacker) PackPathProbePacket(connID protocol.ConnectionID, f ackhandler.Frame, size protocol.ByteCount) (*packedPacket, error) {
+	payload := &payload{
+		frames: []ackhandler.Frame{f},
+		length: f.Length(p.version),
+	}
+	buffer := getPacketBuffer()
+	sealer, err := p.cryptoSetup.Get1RTTSealer()
+	if err != nil {
+		return nil, err
+	}
+	hdr := p.getShortHeader(sealer.KeyPhase())
+	hdr.DestConnectionID = connID
+	padding := utils.MaxByteCount(size-p.packetLength(hdr, payload)-protocol.ByteCount(sealer.Overhead()), 0)
+	contents, err := p.appendPacket(buffer, hdr, payload, padding, protocol.Encryption1RTT, sealer, false)
+	if err != nil {
+		return nil, err
+	}
+	contents.isPathProbePacket = true
+	return &packedPacket{
+		buffer:         buffer,
+		packetContents: contents,
+	}, nil
+}
+
 func (p *packetPacker) getSealerAndHeader(encLevel protocol.EncryptionLevel) (sealer, *wire.ExtendedHeader, error) {
 	switch encLevel {
 	case protocol.EncryptionInitial:
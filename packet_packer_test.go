@@ -91,7 +91,7 @@ var _ = Describe("Packet packer", func() {
 		ackFramer = NewMockAckFrameSource(mockCtrl)
 		sealingManager = NewMockSealingManager(mockCtrl)
 		pnManager = mockackhandler.NewMockSentPacketHandler(mockCtrl)
-		datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+		datagramQueue = newDatagramQueue(func() {}, 1, 1, DatagramDropNewest, utils.DefaultLogger)
 
 		packer = newPacketPacker(
 			protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
@@ -105,6 +105,7 @@ var _ = Describe("Packet packer", func() {
 			framer,
 			ackFramer,
 			datagramQueue,
+			DatagramPriorityAlwaysFirst,
 			protocol.PerspectiveServer,
 			version,
 		)
@@ -561,14 +562,7 @@ var _ = Describe("Packet packer", func() {
 					DataLenPresent: true,
 					Data:           []byte("foobar"),
 				}
-				done := make(chan struct{})
-				go func() {
-					defer GinkgoRecover()
-					defer close(done)
-					datagramQueue.AddAndWait(f)
-				}()
-				// make sure the DATAGRAM has actually been queued
-				time.Sleep(scaleDuration(20 * time.Millisecond))
+				Expect(datagramQueue.Add(f, nil)).To(Succeed())
 
 				framer.EXPECT().HasData()
 				p, err := packer.PackPacket()
@@ -577,7 +571,137 @@ var _ = Describe("Packet packer", func() {
 				Expect(p.frames).To(HaveLen(1))
 				Expect(p.frames[0].Frame).To(Equal(f))
 				Expect(p.buffer.Data).ToNot(BeEmpty())
-				Eventually(done).Should(BeClosed())
+				// lost DATAGRAM frames are not retransmitted
+				p.frames[0].OnLost(p.frames[0].Frame)
+				Expect(p.frames[0].OnAcked).To(BeNil())
+			})
+
+			It("reports if DATAGRAM frames are acknowledged or lost", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				f := &wire.DatagramFrame{
+					DataLenPresent: true,
+					Data:           []byte("foobar"),
+				}
+				var acked []bool
+				Expect(datagramQueue.Add(f, func(a bool) { acked = append(acked, a) })).To(Succeed())
+
+				framer.EXPECT().HasData()
+				p, err := packer.PackPacket()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.frames).To(HaveLen(1))
+				p.frames[0].OnAcked(p.frames[0].Frame)
+				Expect(acked).To(Equal([]bool{true}))
+				// the callback is called at most once
+				p.frames[0].OnLost(p.frames[0].Frame)
+				Expect(acked).To(Equal([]bool{true}))
+
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43))
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				Expect(datagramQueue.Add(f, func(a bool) { acked = append(acked, a) })).To(Succeed())
+				framer.EXPECT().HasData()
+				p, err = packer.PackPacket()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.frames).To(HaveLen(1))
+				p.frames[0].OnLost(p.frames[0].Frame)
+				Expect(acked).To(Equal([]bool{true, false}))
+			})
+
+			Context("datagram priority", func() {
+				streamFrame := ackhandler.Frame{Frame: &wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}}
+
+				BeforeEach(func() {
+					datagramQueue = newDatagramQueue(func() {}, 2, 1, DatagramDropNewest, utils.DefaultLogger)
+					packer.datagramQueue = datagramQueue
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2).AnyTimes()
+					pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42)).AnyTimes()
+					sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil).AnyTimes()
+				})
+
+				queueDatagrams := func() (*wire.DatagramFrame, *wire.DatagramFrame) {
+					f1 := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("foo")}
+					f2 := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("bar")}
+					Expect(datagramQueue.Add(f1, nil)).To(Succeed())
+					Expect(datagramQueue.Add(f2, nil)).To(Succeed())
+					return f1, f2
+				}
+
+				It("packs a datagram into every packet, by default", func() {
+					f1, f2 := queueDatagrams()
+					framer.EXPECT().HasData().Return(true).Times(2)
+					expectAppendControlFrames()
+					expectAppendStreamFrames(streamFrame)
+					p, err := packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(2))
+					Expect(p.frames[0].Frame).To(Equal(f1))
+					expectAppendControlFrames()
+					expectAppendStreamFrames(streamFrame)
+					p, err = packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(2))
+					Expect(p.frames[0].Frame).To(Equal(f2))
+				})
+
+				It("alternates between datagrams and stream data, when using fair share", func() {
+					packer.datagramPriority = DatagramPriorityFairShare
+					f1, f2 := queueDatagrams()
+					framer.EXPECT().HasData().Return(true).Times(3)
+					expectAppendControlFrames()
+					expectAppendStreamFrames(streamFrame)
+					p, err := packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(2))
+					Expect(p.frames[0].Frame).To(Equal(f1))
+					// the next packet doesn't contain a datagram, so it can contain an ACK
+					ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, false)
+					expectAppendControlFrames()
+					expectAppendStreamFrames(streamFrame)
+					p, err = packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(Equal([]ackhandler.Frame{streamFrame}))
+					expectAppendControlFrames()
+					expectAppendStreamFrames(streamFrame)
+					p, err = packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(2))
+					Expect(p.frames[0].Frame).To(Equal(f2))
+				})
+
+				It("packs datagrams into consecutive packets when using fair share, if there's no stream data", func() {
+					packer.datagramPriority = DatagramPriorityFairShare
+					f1, f2 := queueDatagrams()
+					framer.EXPECT().HasData().Times(2)
+					p, err := packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(1))
+					Expect(p.frames[0].Frame).To(Equal(f1))
+					p, err = packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(1))
+					Expect(p.frames[0].Frame).To(Equal(f2))
+				})
+
+				It("only packs datagrams when there's no stream data, when using background priority", func() {
+					packer.datagramPriority = DatagramPriorityBackground
+					f1, _ := queueDatagrams()
+					framer.EXPECT().HasData().Return(true).Times(2)
+					ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, false).Times(2)
+					for i := 0; i < 2; i++ {
+						expectAppendControlFrames()
+						expectAppendStreamFrames(streamFrame)
+						p, err := packer.PackPacket()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(p.frames).To(Equal([]ackhandler.Frame{streamFrame}))
+					}
+					framer.EXPECT().HasData()
+					p, err := packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(1))
+					Expect(p.frames[0].Frame).To(Equal(f1))
+				})
 			})
 
 			It("accounts for the space consumed by control frames", func() {
@@ -631,7 +755,7 @@ var _ = Describe("Packet packer", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(secondPayloadByte).To(Equal(byte(0)))
 				// ... followed by the PING
-				frameParser := wire.NewFrameParser(false, packer.version)
+				frameParser := wire.NewFrameParser(false, false, false, false, false, packer.version)
 				frame, err := frameParser.ParseNext(r, protocol.Encryption1RTT)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(frame).To(BeAssignableToTypeOf(&wire.PingFrame{}))
@@ -668,7 +792,7 @@ var _ = Describe("Packet packer", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(firstPayloadByte).To(Equal(byte(0)))
 				// ... followed by the STREAM frame
-				frameParser := wire.NewFrameParser(true, packer.version)
+				frameParser := wire.NewFrameParser(true, false, false, false, false, packer.version)
 				frame, err := frameParser.ParseNext(r, protocol.Encryption1RTT)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(frame).To(BeAssignableToTypeOf(&wire.StreamFrame{}))
@@ -1221,7 +1345,7 @@ var _ = Describe("Packet packer", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(secondPayloadByte).To(Equal(byte(0)))
 				// ... followed by the PING
-				frameParser := wire.NewFrameParser(false, packer.version)
+				frameParser := wire.NewFrameParser(false, false, false, false, false, packer.version)
 				frame, err := frameParser.ParseNext(r, protocol.Encryption1RTT)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(frame).To(BeAssignableToTypeOf(&wire.PingFrame{}))
@@ -1494,6 +1618,36 @@ var _ = Describe("Packet packer", func() {
 				Expect(p.buffer.Data).To(HaveLen(int(probePacketSize)))
 				Expect(p.packetContents.isMTUProbePacket).To(BeTrue())
 			})
+
+			It("packs a path probe packet", func() {
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43))
+				connID := protocol.ConnectionID{0xde, 0xca, 0xfb, 0xad}
+				f := ackhandler.Frame{Frame: &wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}}
+				p, err := packer.PackPathProbePacket(connID, f, protocol.MinInitialPacketSize)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.length).To(BeEquivalentTo(protocol.MinInitialPacketSize))
+				Expect(p.header.IsLongHeader).To(BeFalse())
+				Expect(p.header.DestConnectionID).To(Equal(connID))
+				Expect(p.header.PacketNumber).To(Equal(protocol.PacketNumber(0x43)))
+				Expect(p.frames).To(Equal([]ackhandler.Frame{f}))
+				Expect(p.buffer.Data).To(HaveLen(protocol.MinInitialPacketSize))
+				Expect(p.packetContents.isPathProbePacket).To(BeTrue())
+				Expect(p.packetContents.isMTUProbePacket).To(BeFalse())
+			})
+
+			It("doesn't pad path probe packets beyond the requested size", func() {
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43))
+				f := ackhandler.Frame{Frame: &wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}}
+				p, err := packer.PackPathProbePacket(protocol.ConnectionID{0xde, 0xca, 0xfb, 0xad}, f, 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.length).To(BeNumerically("<", 50))
+				Expect(p.buffer.Data).To(HaveLen(int(p.length)))
+				Expect(p.frames).To(Equal([]ackhandler.Frame{f}))
+			})
 		})
 	})
 })
@@ -111,6 +111,41 @@ func (u *packetUnpacker) Unpack(hdr *wire.Header, rcvTime time.Time, data []byte
 	}, nil
 }
 
+// UnpackOnPath unpacks a 1-RTT packet received on an additional path, when using multipath.
+// Every path uses its own packet number space, so the packet number is decoded
+// using the largest packet number received on that path.
+func (u *packetUnpacker) UnpackOnPath(hdr *wire.Header, rcvTime time.Time, data []byte, pathID uint64, largestRcvd protocol.PacketNumber) (*unpackedPacket, error) {
+	if hdr.IsLongHeader {
+		return nil, fmt.Errorf("unexpected long header packet on path %d", pathID)
+	}
+	opener, err := u.cs.Get1RTTOpener()
+	if err != nil {
+		return nil, err
+	}
+	extHdr, parseErr := u.unpackHeader(opener, hdr, data)
+	// If the reserved bits are set incorrectly, we still need to continue unpacking.
+	// This avoids a timing side-channel, which otherwise might allow an attacker
+	// to gain information about the header encryption.
+	if parseErr != nil && parseErr != wire.ErrInvalidReservedBits {
+		return nil, parseErr
+	}
+	extHdr.PacketNumber = protocol.DecodePacketNumber(extHdr.PacketNumberLen, largestRcvd, extHdr.PacketNumber)
+	extHdrLen := extHdr.ParsedLen()
+	decrypted, err := opener.OpenOnPath(data[extHdrLen:extHdrLen], data[extHdrLen:], rcvTime, pathID, extHdr.PacketNumber, extHdr.KeyPhase, data[:extHdrLen])
+	if err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return &unpackedPacket{
+		hdr:             extHdr,
+		packetNumber:    extHdr.PacketNumber,
+		encryptionLevel: protocol.Encryption1RTT,
+		data:            decrypted,
+	}, nil
+}
+
 func (u *packetUnpacker) unpackLongHeaderPacket(opener handshake.LongHeaderOpener, hdr *wire.Header, data []byte) (*wire.ExtendedHeader, []byte, error) {
 	extHdr, parseErr := u.unpackHeader(opener, hdr, data)
 	// If the reserved bits are set incorrectly, we still need to continue unpacking.
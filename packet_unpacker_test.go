@@ -155,6 +155,45 @@ var _ = Describe("Packet Unpacker", func() {
 		Expect(packet.data).To(Equal([]byte("decrypted")))
 	})
 
+	It("opens short header packets received on additional paths", func() {
+		extHdr := &wire.ExtendedHeader{
+			Header:          wire.Header{DestConnectionID: connID},
+			KeyPhase:        protocol.KeyPhaseZero,
+			PacketNumber:    0x38,
+			PacketNumberLen: protocol.PacketNumberLen1,
+		}
+		hdr, hdrRaw := getHeader(extHdr)
+		opener := mocks.NewMockShortHeaderOpener(mockCtrl)
+		now := time.Now()
+		gomock.InOrder(
+			cs.EXPECT().Get1RTTOpener().Return(opener, nil),
+			opener.EXPECT().DecryptHeader(gomock.Any(), gomock.Any(), gomock.Any()),
+			opener.EXPECT().OpenOnPath(gomock.Any(), payload, now, uint64(3), protocol.PacketNumber(0x1338), protocol.KeyPhaseZero, hdrRaw).Return([]byte("decrypted"), nil),
+		)
+		packet, err := unpacker.UnpackOnPath(hdr, now, append(hdrRaw, payload...), 3, 0x1337)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.encryptionLevel).To(Equal(protocol.Encryption1RTT))
+		Expect(packet.packetNumber).To(Equal(protocol.PacketNumber(0x1338)))
+		Expect(packet.data).To(Equal([]byte("decrypted")))
+	})
+
+	It("rejects long header packets received on additional paths", func() {
+		extHdr := &wire.ExtendedHeader{
+			Header: wire.Header{
+				IsLongHeader:     true,
+				Type:             protocol.PacketTypeHandshake,
+				Length:           3 + 6, // packet number len + payload
+				DestConnectionID: connID,
+				Version:          version,
+			},
+			PacketNumber:    2,
+			PacketNumberLen: 3,
+		}
+		hdr, hdrRaw := getHeader(extHdr)
+		_, err := unpacker.UnpackOnPath(hdr, time.Now(), append(hdrRaw, payload...), 3, 0x1337)
+		Expect(err).To(MatchError("unexpected long header packet on path 3"))
+	})
+
 	It("returns the error when getting the sealer fails", func() {
 		extHdr := &wire.ExtendedHeader{
 			Header:          wire.Header{DestConnectionID: connID},
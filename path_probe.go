@@ -0,0 +1,158 @@
+package quic
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+const (
+	// The maximum number of PATH_CHALLENGE frames sent when validating a path.
+	maxPathChallenges = 3
+	// The minimum time to wait for a PATH_RESPONSE before sending another PATH_CHALLENGE.
+	// RFC 9000 recommends using at least 2*kInitialRtt, with kInitialRtt = 333ms.
+	minPathChallengeInterval = 2 * 333 * time.Millisecond
+)
+
+var errPathValidationFailed = errors.New("path validation failed")
+
+// A pathProbe validates a new network path using PATH_CHALLENGE frames.
+// Except for abort, Done and Err, all methods must only be called from the connection's run loop.
+type pathProbe struct {
+	conn   sendConn
+	runner connRunner
+
+	// the connection IDs that were added to the runner when the probe was started
+	connIDs []protocol.ConnectionID
+	// the ID of the path, only set when adding a path to a multipath connection
+	pathID PathID
+	// set if the path is only probed, and the connection doesn't migrate to it after validation
+	probeOnly bool
+
+	challenges   map[[8]byte]time.Time // maps the PATH_CHALLENGE data to the time it was sent
+	numSent      int
+	nextSendTime time.Time
+
+	abortOnce sync.Once
+	aborted   chan struct{}
+	done      chan struct{}
+	rtt       time.Duration
+	err       error
+}
+
+func newPathProbe(conn sendConn, runner connRunner) *pathProbe {
+	return &pathProbe{
+		conn:       conn,
+		runner:     runner,
+		challenges: make(map[[8]byte]time.Time, maxPathChallenges),
+		aborted:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// ShouldSendChallenge says if it's time to send the next PATH_CHALLENGE.
+func (p *pathProbe) ShouldSendChallenge(now time.Time) bool {
+	return p.numSent < maxPathChallenges && !now.Before(p.nextSendTime)
+}
+
+// NewChallenge generates the data for a new PATH_CHALLENGE frame.
+func (p *pathProbe) NewChallenge(now time.Time, rttStats *utils.RTTStats) ([8]byte, error) {
+	var data [8]byte
+	if _, err := rand.Read(data[:]); err != nil {
+		return data, err
+	}
+	p.challenges[data] = now
+	p.numSent++
+	p.nextSendTime = now.Add(utils.MaxDuration(rttStats.PTO(true), minPathChallengeInterval))
+	return data, nil
+}
+
+// ReceivedResponse processes the data of a PATH_RESPONSE frame.
+// If it matches one of the PATH_CHALLENGEs sent, it returns the RTT of the path.
+func (p *pathProbe) ReceivedResponse(data [8]byte, now time.Time) (time.Duration, bool) {
+	sendTime, ok := p.challenges[data]
+	if !ok {
+		return 0, false
+	}
+	return now.Sub(sendTime), true
+}
+
+// TimedOut says if validation failed, because no PATH_RESPONSE was received in time.
+func (p *pathProbe) TimedOut(now time.Time) bool {
+	return p.numSent >= maxPathChallenges && !now.Before(p.nextSendTime)
+}
+
+// NextTimeout returns the time when the next PATH_CHALLENGE is sent, or when validation fails.
+func (p *pathProbe) NextTimeout() time.Time {
+	return p.nextSendTime
+}
+
+func (p *pathProbe) complete(err error) {
+	p.err = err
+	close(p.done)
+}
+
+func (p *pathProbe) abort() {
+	p.abortOnce.Do(func() { close(p.aborted) })
+}
+
+func (p *pathProbe) isAborted() bool {
+	select {
+	case <-p.aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done is closed when path validation completes.
+func (p *pathProbe) Done() <-chan struct{} { return p.done }
+
+// RTT returns the RTT measured during path validation.
+// It must only be called after Done is closed, and only if path validation succeeded.
+func (p *pathProbe) RTT() time.Duration { return p.rtt }
+
+// Err returns the error that occurred during path validation.
+// It must only be called after Done is closed.
+func (p *pathProbe) Err() error { return p.err }
+
+// The server only sends 3 times the amount of data received from a new address of the client,
+// until that address has been validated, see section 9.3.1 of RFC 9000.
+const migrationAmplificationFactor = 3
+
+// A peerMigration validates the new address of a client that migrated the connection.
+// Only used by the server.
+type peerMigration struct {
+	probe *pathProbe
+	// the last validated path, used again if validation of the new address fails
+	lastValidated sendConn
+
+	bytesReceived protocol.ByteCount
+	bytesSent     protocol.ByteCount
+}
+
+func newPeerMigration(path, lastValidated sendConn, runner connRunner, bytesReceived protocol.ByteCount) *peerMigration {
+	return &peerMigration{
+		probe:         newPathProbe(path, runner),
+		lastValidated: lastValidated,
+		bytesReceived: bytesReceived,
+	}
+}
+
+// SendBudget returns the number of bytes that can be sent to the new address.
+func (m *peerMigration) SendBudget() protocol.ByteCount {
+	if limit := migrationAmplificationFactor * m.bytesReceived; limit > m.bytesSent {
+		return limit - m.bytesSent
+	}
+	return 0
+}
+
+// ReceivedBytes is called for every packet received from the new address.
+func (m *peerMigration) ReceivedBytes(n protocol.ByteCount) { m.bytesReceived += n }
+
+// SentBytes is called for every packet sent to the new address.
+func (m *peerMigration) SentBytes(n protocol.ByteCount) { m.bytesSent += n }
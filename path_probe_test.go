@@ -0,0 +1,96 @@
+package quic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path Probe", func() {
+	var (
+		p        *pathProbe
+		rttStats *utils.RTTStats
+		now      time.Time
+	)
+
+	BeforeEach(func() {
+		rttStats = &utils.RTTStats{}
+		p = newPathProbe(nil, nil)
+		now = time.Now()
+	})
+
+	It("sends PATH_CHALLENGEs", func() {
+		rttStats.UpdateRTT(time.Second, 0, now)
+		Expect(p.ShouldSendChallenge(now)).To(BeTrue())
+		data, err := p.NewChallenge(now, rttStats)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.NextTimeout()).To(Equal(now.Add(rttStats.PTO(true))))
+		Expect(p.ShouldSendChallenge(now.Add(rttStats.PTO(true) - time.Nanosecond))).To(BeFalse())
+		Expect(p.ShouldSendChallenge(now.Add(rttStats.PTO(true)))).To(BeTrue())
+		data2, err := p.NewChallenge(now, rttStats)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data2).ToNot(Equal(data))
+	})
+
+	It("waits at least the minimum interval between PATH_CHALLENGEs", func() {
+		rttStats.UpdateRTT(time.Millisecond, 0, now)
+		_, err := p.NewChallenge(now, rttStats)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.NextTimeout()).To(Equal(now.Add(minPathChallengeInterval)))
+	})
+
+	It("times out", func() {
+		for i := 0; i < maxPathChallenges; i++ {
+			Expect(p.TimedOut(now)).To(BeFalse())
+			Expect(p.ShouldSendChallenge(now)).To(BeTrue())
+			_, err := p.NewChallenge(now, rttStats)
+			Expect(err).ToNot(HaveOccurred())
+			now = p.NextTimeout()
+		}
+		Expect(p.ShouldSendChallenge(now)).To(BeFalse())
+		Expect(p.TimedOut(now.Add(-time.Nanosecond))).To(BeFalse())
+		Expect(p.TimedOut(now)).To(BeTrue())
+	})
+
+	It("matches PATH_RESPONSEs and measures the RTT", func() {
+		data, err := p.NewChallenge(now, rttStats)
+		Expect(err).ToNot(HaveOccurred())
+		_, ok := p.ReceivedResponse([8]byte{1, 2, 3, 4, 5, 6, 7, 8}, now.Add(time.Second))
+		Expect(ok).To(BeFalse())
+		rtt, ok := p.ReceivedResponse(data, now.Add(time.Second))
+		Expect(ok).To(BeTrue())
+		Expect(rtt).To(Equal(time.Second))
+	})
+
+	It("completes", func() {
+		Expect(p.Done()).ToNot(BeClosed())
+		testErr := errors.New("test error")
+		p.complete(testErr)
+		Expect(p.Done()).To(BeClosed())
+		Expect(p.Err()).To(MatchError(testErr))
+	})
+
+	It("aborts", func() {
+		Expect(p.isAborted()).To(BeFalse())
+		p.abort()
+		p.abort() // can be called multiple times
+		Expect(p.isAborted()).To(BeTrue())
+	})
+})
+
+var _ = Describe("Peer Migration", func() {
+	It("limits the amount of data sent to 3 times the amount received", func() {
+		m := newPeerMigration(nil, nil, nil, 100)
+		Expect(m.SendBudget()).To(BeEquivalentTo(300))
+		m.SentBytes(250)
+		Expect(m.SendBudget()).To(BeEquivalentTo(50))
+		m.SentBytes(100)
+		Expect(m.SendBudget()).To(BeZero())
+		m.ReceivedBytes(100)
+		Expect(m.SendBudget()).To(BeEquivalentTo(250))
+	})
+})
@@ -395,6 +395,14 @@ type eventTransportParameters struct {
 	PreferredAddress *preferredAddress
 
 	MaxDatagramFrameSize protocol.ByteCount
+
+	MinAckDelay *time.Duration
+
+	EnableMultipath bool
+
+	EnablePartialReliability bool
+
+	EnableReliableStreamReset bool
 }
 
 func (e eventTransportParameters) Category() category { return categoryTransport }
@@ -440,6 +448,18 @@ func (e eventTransportParameters) MarshalJSONObject(enc *gojay.Encoder) {
 	if e.MaxDatagramFrameSize != protocol.InvalidByteCount {
 		enc.Int64Key("max_datagram_frame_size", int64(e.MaxDatagramFrameSize))
 	}
+	if e.MinAckDelay != nil {
+		enc.FloatKey("min_ack_delay", milliseconds(*e.MinAckDelay))
+	}
+	if e.EnableMultipath {
+		enc.BoolKey("enable_multipath", true)
+	}
+	if e.EnablePartialReliability {
+		enc.BoolKey("enable_partial_reliability", true)
+	}
+	if e.EnableReliableStreamReset {
+		enc.BoolKey("reset_stream_at", true)
+	}
 }
 
 type preferredAddress struct {
@@ -59,6 +59,14 @@ func (f frame) MarshalJSONObject(enc *gojay.Encoder) {
 		marshalHandshakeDoneFrame(enc, frame)
 	case *logging.DatagramFrame:
 		marshalDatagramFrame(enc, frame)
+	case *logging.AckFrequencyFrame:
+		marshalAckFrequencyFrame(enc, frame)
+	case *logging.ImmediateAckFrame:
+		marshalImmediateAckFrame(enc, frame)
+	case *logging.PathAbandonFrame:
+		marshalPathAbandonFrame(enc, frame)
+	case *logging.ExpiredStreamDataFrame:
+		marshalExpiredStreamDataFrame(enc, frame)
 	default:
 		panic("unknown frame type")
 	}
@@ -112,10 +120,17 @@ func marshalAckFrame(enc *gojay.Encoder, f *logging.AckFrame) {
 }
 
 func marshalResetStreamFrame(enc *gojay.Encoder, f *logging.ResetStreamFrame) {
-	enc.StringKey("frame_type", "reset_stream")
+	if f.ReliableSize > 0 {
+		enc.StringKey("frame_type", "reset_stream_at")
+	} else {
+		enc.StringKey("frame_type", "reset_stream")
+	}
 	enc.Int64Key("stream_id", int64(f.StreamID))
 	enc.Int64Key("error_code", int64(f.ErrorCode))
 	enc.Int64Key("final_size", int64(f.FinalSize))
+	if f.ReliableSize > 0 {
+		enc.Int64Key("reliable_size", int64(f.ReliableSize))
+	}
 }
 
 func marshalStopSendingFrame(enc *gojay.Encoder, f *logging.StopSendingFrame) {
@@ -225,3 +240,29 @@ func marshalDatagramFrame(enc *gojay.Encoder, f *logging.DatagramFrame) {
 	enc.StringKey("frame_type", "datagram")
 	enc.Int64Key("length", int64(f.Length))
 }
+
+func marshalAckFrequencyFrame(enc *gojay.Encoder, f *logging.AckFrequencyFrame) {
+	enc.StringKey("frame_type", "ack_frequency")
+	enc.Uint64Key("sequence_number", f.SequenceNumber)
+	enc.Uint64Key("packet_tolerance", f.PacketTolerance)
+	enc.FloatKey("update_max_ack_delay", milliseconds(f.UpdateMaxAckDelay))
+	enc.BoolKey("ignore_order", f.IgnoreOrder)
+	enc.BoolKey("ignore_ce", f.IgnoreCE)
+}
+
+func marshalImmediateAckFrame(enc *gojay.Encoder, _ *logging.ImmediateAckFrame) {
+	enc.StringKey("frame_type", "immediate_ack")
+}
+
+func marshalPathAbandonFrame(enc *gojay.Encoder, f *logging.PathAbandonFrame) {
+	enc.StringKey("frame_type", "path_abandon")
+	enc.Uint64Key("path_identifier", f.PathIdentifier)
+	enc.Uint64Key("error_code", f.ErrorCode)
+	enc.StringKey("reason", f.ReasonPhrase)
+}
+
+func marshalExpiredStreamDataFrame(enc *gojay.Encoder, f *logging.ExpiredStreamDataFrame) {
+	enc.StringKey("frame_type", "expired_stream_data")
+	enc.Int64Key("stream_id", int64(f.StreamID))
+	enc.Int64Key("offset", int64(f.Offset))
+}
@@ -114,6 +114,24 @@ var _ = Describe("Frames", func() {
 		)
 	})
 
+	It("marshals RESET_STREAM_AT frames", func() {
+		check(
+			&logging.ResetStreamFrame{
+				StreamID:     987,
+				FinalSize:    1234,
+				ErrorCode:    42,
+				ReliableSize: 1000,
+			},
+			map[string]interface{}{
+				"frame_type":    "reset_stream_at",
+				"stream_id":     987,
+				"error_code":    42,
+				"final_size":    1234,
+				"reliable_size": 1000,
+			},
+		)
+	})
+
 	It("marshals STOP_SENDING frames", func() {
 		check(
 			&logging.StopSendingFrame{
@@ -374,4 +392,62 @@ var _ = Describe("Frames", func() {
 			},
 		)
 	})
+
+	It("marshals ACK_FREQUENCY frames", func() {
+		check(
+			&logging.AckFrequencyFrame{
+				SequenceNumber:    42,
+				PacketTolerance:   10,
+				UpdateMaxAckDelay: 5 * time.Millisecond,
+				IgnoreOrder:       true,
+			},
+			map[string]interface{}{
+				"frame_type":           "ack_frequency",
+				"sequence_number":      42,
+				"packet_tolerance":     10,
+				"update_max_ack_delay": 5,
+				"ignore_order":         true,
+				"ignore_ce":            false,
+			},
+		)
+	})
+
+	It("marshals IMMEDIATE_ACK frames", func() {
+		check(
+			&logging.ImmediateAckFrame{},
+			map[string]interface{}{
+				"frame_type": "immediate_ack",
+			},
+		)
+	})
+
+	It("marshals PATH_ABANDON frames", func() {
+		check(
+			&logging.PathAbandonFrame{
+				PathIdentifier: 3,
+				ErrorCode:      0x1337,
+				ReasonPhrase:   "foobar",
+			},
+			map[string]interface{}{
+				"frame_type":      "path_abandon",
+				"path_identifier": 3,
+				"error_code":      0x1337,
+				"reason":          "foobar",
+			},
+		)
+	})
+
+	It("marshals EXPIRED_STREAM_DATA frames", func() {
+		check(
+			&logging.ExpiredStreamDataFrame{
+				StreamID: 42,
+				Offset:   1337,
+			},
+			map[string]interface{}{
+				"frame_type": "expired_stream_data",
+				"stream_id":  42,
+				"offset":     1337,
+			},
+		)
+	})
 })
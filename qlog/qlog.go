@@ -273,6 +273,10 @@ func (t *connectionTracer) toTransportParameters(tp *wire.TransportParameters) *
 		InitialMaxStreamsUni:            int64(tp.MaxUniStreamNum),
 		PreferredAddress:                pa,
 		MaxDatagramFrameSize:            tp.MaxDatagramFrameSize,
+		MinAckDelay:                     tp.MinAckDelay,
+		EnableMultipath:                 tp.EnableMultipath,
+		EnablePartialReliability:        tp.EnablePartialReliability,
+		EnableReliableStreamReset:       tp.EnableReliableStreamReset,
 	}
 }
 
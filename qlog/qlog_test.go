@@ -377,6 +377,47 @@ var _ = Describe("Tracing", func() {
 				Expect(ev).To(HaveKeyWithValue("max_datagram_frame_size", float64(1337)))
 			})
 
+			It("records transport parameters that enable the ACK frequency extension", func() {
+				minAckDelay := 1500 * time.Microsecond
+				tracer.SentTransportParameters(&logging.TransportParameters{
+					MinAckDelay: &minAckDelay,
+				})
+				entry := exportAndParseSingle()
+				Expect(entry.Name).To(Equal("transport:parameters_set"))
+				ev := entry.Event
+				Expect(ev).To(HaveKeyWithValue("min_ack_delay", 1.5))
+			})
+
+			It("records transport parameters that enable multipath", func() {
+				tracer.SentTransportParameters(&logging.TransportParameters{
+					EnableMultipath: true,
+				})
+				entry := exportAndParseSingle()
+				Expect(entry.Name).To(Equal("transport:parameters_set"))
+				ev := entry.Event
+				Expect(ev).To(HaveKeyWithValue("enable_multipath", true))
+			})
+
+			It("records transport parameters that enable partial reliability", func() {
+				tracer.SentTransportParameters(&logging.TransportParameters{
+					EnablePartialReliability: true,
+				})
+				entry := exportAndParseSingle()
+				Expect(entry.Name).To(Equal("transport:parameters_set"))
+				ev := entry.Event
+				Expect(ev).To(HaveKeyWithValue("enable_partial_reliability", true))
+			})
+
+			It("records transport parameters that enable reliable stream resets", func() {
+				tracer.SentTransportParameters(&logging.TransportParameters{
+					EnableReliableStreamReset: true,
+				})
+				entry := exportAndParseSingle()
+				Expect(entry.Name).To(Equal("transport:parameters_set"))
+				ev := entry.Event
+				Expect(ev).To(HaveKeyWithValue("reset_stream_at", true))
+			})
+
 			It("records received transport parameters", func() {
 				tracer.ReceivedTransportParameters(&logging.TransportParameters{})
 				entry := exportAndParseSingle()
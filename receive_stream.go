@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -18,6 +19,7 @@ type receiveStreamI interface {
 
 	handleStreamFrame(*wire.StreamFrame) error
 	handleResetStreamFrame(*wire.ResetStreamFrame) error
+	handleExpiredStreamDataFrame(*wire.ExpiredStreamDataFrame) error
 	closeForShutdown(error)
 	getWindowUpdate() protocol.ByteCount
 }
@@ -40,11 +42,16 @@ type receiveStream struct {
 	closeForShutdownErr error
 	cancelReadErr       error
 	resetRemotelyErr    *StreamError
+	// set when a RESET_STREAM_AT frame is received, the data up to this offset is delivered before the reset
+	reliableSize protocol.ByteCount
+	// set when the peer abandoned stream data, reset once it has been returned from Read
+	dataExpiredErr *StreamDataExpiredError
 
 	closedForShutdown bool // set when CloseForShutdown() is called
 	finRead           bool // set once we read a frame with a Fin
 	canceledRead      bool // set when CancelRead() is called
 	resetRemotely     bool // set when HandleResetStreamFrame() is called
+	resetPending      bool // set when a RESET_STREAM_AT frame is received, until the data up to the reliableSize has been read
 
 	readChan chan struct{}
 	deadline time.Time
@@ -53,6 +60,8 @@ type receiveStream struct {
 	version        protocol.VersionNumber
 }
 
+var errNegativePeekCount = errors.New("quic: negative Peek count")
+
 var (
 	_ ReceiveStream  = &receiveStream{}
 	_ receiveStreamI = &receiveStream{}
@@ -91,6 +100,135 @@ func (s *receiveStream) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// WriteTo writes the stream data to w until EOF or an error occurs.
+// It uses a pooled buffer, instead of allocating a new buffer like io.Copy does.
+func (s *receiveStream) WriteTo(w io.Writer) (int64, error) {
+	buf := getPacketBuffer()
+	defer buf.Release()
+	b := buf.Data[:cap(buf.Data)]
+
+	var written int64
+	for {
+		n, rerr := s.Read(b)
+		if n > 0 {
+			nw, werr := w.Write(b[:n])
+			written += int64(nw)
+			if werr != nil {
+				return written, werr
+			}
+			if nw != n {
+				return written, io.ErrShortWrite
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
+
+// Peek returns the next n bytes without advancing the reader.
+func (s *receiveStream) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errNegativePeekCount
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finRead {
+		return nil, io.EOF
+	}
+	var deadlineTimer *utils.Timer
+	for {
+		buffered := s.buffered()
+		if buffered >= n {
+			return s.peek(n), nil
+		}
+		if s.closedForShutdown {
+			return s.peek(buffered), s.closeForShutdownErr
+		}
+		if s.canceledRead {
+			return s.peek(buffered), s.cancelReadErr
+		}
+		if s.resetRemotely {
+			return s.peek(buffered), s.resetRemotelyErr
+		}
+		if s.dataExpiredErr != nil {
+			// Read returns the error before returning any of the data after the expired offset.
+			return nil, s.dataExpiredErr
+		}
+		readOffset := s.readOffset()
+		if s.resetPending && readOffset+protocol.ByteCount(buffered) >= s.reliableSize {
+			return s.peek(buffered), s.resetRemotelyErr
+		}
+		if readOffset+protocol.ByteCount(buffered) >= s.finalOffset {
+			return s.peek(buffered), io.EOF
+		}
+
+		deadline := s.deadline
+		if !deadline.IsZero() {
+			if !time.Now().Before(deadline) {
+				return s.peek(buffered), errDeadline
+			}
+			if deadlineTimer == nil {
+				deadlineTimer = utils.NewTimer()
+				defer deadlineTimer.Stop()
+			}
+			deadlineTimer.Reset(deadline)
+		}
+
+		s.mutex.Unlock()
+		if deadline.IsZero() {
+			<-s.readChan
+		} else {
+			select {
+			case <-s.readChan:
+			case <-deadlineTimer.Chan():
+				deadlineTimer.SetRead()
+			}
+		}
+		s.mutex.Lock()
+	}
+}
+
+// Buffered returns the number of bytes that can be read without blocking.
+func (s *receiveStream) Buffered() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finRead || s.canceledRead || s.resetRemotely || s.closedForShutdown {
+		return 0
+	}
+	return s.buffered()
+}
+
+// buffered returns the number of bytes that were received in order, but not yet read.
+func (s *receiveStream) buffered() int {
+	n := s.frameQueue.ContiguousLen()
+	if s.currentFrame != nil {
+		n += protocol.ByteCount(len(s.currentFrame) - s.readPosInFrame)
+	}
+	if s.resetPending {
+		n = utils.MinByteCount(n, s.reliableSize-s.readOffset())
+	}
+	return int(n)
+}
+
+// peek copies the next n bytes. There must be at least n buffered bytes.
+func (s *receiveStream) peek(n int) []byte {
+	b := make([]byte, 0, n)
+	if s.currentFrame != nil {
+		data := s.currentFrame[s.readPosInFrame:]
+		if len(data) > n {
+			data = data[:n]
+		}
+		b = append(b, data...)
+	}
+	return s.frameQueue.Peek(b, n-len(b))
+}
+
 func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, error) {
 	if s.finRead {
 		return false, 0, io.EOF
@@ -126,6 +264,11 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 			if s.resetRemotely {
 				return false, bytesRead, s.resetRemotelyErr
 			}
+			if s.dataExpiredErr != nil {
+				err := s.dataExpiredErr
+				s.dataExpiredErr = nil
+				return false, bytesRead, err
+			}
 
 			deadline := s.deadline
 			if !deadline.IsZero() {
@@ -166,7 +309,14 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 			return false, bytesRead, fmt.Errorf("BUG: readPosInFrame (%d) > frame.DataLen (%d) in stream.Read", s.readPosInFrame, len(s.currentFrame))
 		}
 
-		m := copy(p[bytesRead:], s.currentFrame[s.readPosInFrame:])
+		data := s.currentFrame[s.readPosInFrame:]
+		if s.resetPending {
+			// don't return any data beyond the reliable size
+			if maxLen := s.reliableSize - s.readOffset(); protocol.ByteCount(len(data)) > maxLen {
+				data = data[:maxLen]
+			}
+		}
+		m := copy(p[bytesRead:], data)
 		s.readPosInFrame += m
 		bytesRead += m
 
@@ -175,6 +325,13 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 			s.flowController.AddBytesRead(protocol.ByteCount(m))
 		}
 
+		if s.resetPending && s.readOffset() >= s.reliableSize {
+			s.resetPending = false
+			s.resetRemotely = true
+			s.flowController.Abandon()
+			return true, bytesRead, s.resetRemotelyErr
+		}
+
 		if s.readPosInFrame >= len(s.currentFrame) && s.currentFrameIsLast {
 			s.finRead = true
 			return true, bytesRead, io.EOF
@@ -183,6 +340,15 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 	return false, bytesRead, nil
 }
 
+// readOffset is the stream offset of the next byte returned by Read.
+func (s *receiveStream) readOffset() protocol.ByteCount {
+	offset := s.frameQueue.readPos
+	if s.currentFrame != nil {
+		offset -= protocol.ByteCount(len(s.currentFrame) - s.readPosInFrame)
+	}
+	return offset
+}
+
 func (s *receiveStream) dequeueNextFrame() {
 	var offset protocol.ByteCount
 	// We're done with the last frame. Release the buffer.
@@ -278,13 +444,58 @@ func (s *receiveStream) handleResetStreamFrameImpl(frame *wire.ResetStreamFrame)
 	if s.resetRemotely {
 		return false, nil
 	}
-	s.resetRemotely = true
+	// the reliable size can only be reduced by subsequent RESET_STREAM_AT frames
+	if s.resetPending && frame.ReliableSize >= s.reliableSize {
+		return false, nil
+	}
 	s.resetRemotelyErr = &StreamError{
 		StreamID:  s.streamID,
 		ErrorCode: frame.ErrorCode,
 	}
+	if !s.canceledRead && frame.ReliableSize > s.readOffset() {
+		// The data up to the reliable size needs to be read before the stream is reset.
+		// The stream is completed once that has happened.
+		s.resetPending = true
+		s.reliableSize = frame.ReliableSize
+		return false, nil
+	}
+	wasPending := s.resetPending
+	s.resetPending = false
+	s.resetRemotely = true
 	s.signalRead()
-	return newlyRcvdFinalOffset, nil
+	return newlyRcvdFinalOffset || (wasPending && !s.canceledRead), nil
+}
+
+func (s *receiveStream) handleExpiredStreamDataFrame(frame *wire.ExpiredStreamDataFrame) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.flowController.UpdateHighestReceived(frame.Offset, false); err != nil {
+		return err
+	}
+	if s.closedForShutdown || s.canceledRead || s.resetRemotely || s.resetPending || s.finRead {
+		return nil
+	}
+	readOffset := s.readOffset()
+	if frame.Offset <= readOffset {
+		return nil
+	}
+	if frame.Offset <= s.frameQueue.readPos {
+		// the current frame contains data beyond the expired offset
+		s.readPosInFrame += int(frame.Offset - readOffset)
+	} else {
+		if s.currentFrameDone != nil {
+			s.currentFrameDone()
+		}
+		s.currentFrame = nil
+		s.currentFrameDone = nil
+		s.readPosInFrame = 0
+		s.frameQueue.Skip(frame.Offset)
+	}
+	s.flowController.AddBytesRead(frame.Offset - readOffset)
+	s.dataExpiredErr = &StreamDataExpiredError{StreamID: s.streamID, Offset: frame.Offset}
+	s.signalRead()
+	return nil
 }
 
 func (s *receiveStream) CloseRemote(offset protocol.ByteCount) {
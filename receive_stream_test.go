@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"runtime"
@@ -445,6 +446,164 @@ var _ = Describe("Receive Stream", func() {
 		})
 	})
 
+	Context("writing to an io.Writer", func() {
+		It("writes all data", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), true)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 3, Data: []byte("bar"), Fin: true})).To(Succeed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3)).Times(2)
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			buf := &bytes.Buffer{}
+			n, err := str.WriteTo(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(6))
+			Expect(buf.Bytes()).To(Equal([]byte("foobar")))
+		})
+
+		It("returns the error when the stream is reset", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+			done := make(chan struct{})
+			buf := &bytes.Buffer{}
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				n, err := str.WriteTo(buf)
+				Expect(err).To(MatchError(&StreamError{StreamID: streamID, ErrorCode: 1234}))
+				Expect(n).To(BeEquivalentTo(3))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true)
+			mockFC.EXPECT().Abandon()
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			Expect(str.handleResetStreamFrame(&wire.ResetStreamFrame{StreamID: streamID, FinalSize: 42, ErrorCode: 1234})).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(buf.Bytes()).To(Equal([]byte("foo")))
+		})
+
+		It("returns the error of the writer", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+			r, w := io.Pipe()
+			testErr := errors.New("test error")
+			r.CloseWithError(testErr)
+			_, err := str.WriteTo(w)
+			Expect(err).To(MatchError(testErr))
+		})
+	})
+
+	Context("peeking", func() {
+		It("peeks at data without consuming it", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 3, Data: []byte("bar")})).To(Succeed())
+			b, err := str.Peek(4)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foob")))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2))
+			b = make([]byte, 2)
+			_, err = strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("fo")))
+			b, err = str.Peek(4)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("obar")))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(1))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+			b = make([]byte, 4)
+			_, err = strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("obar")))
+		})
+
+		It("says how many bytes are buffered", func() {
+			Expect(str.Buffered()).To(BeZero())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(10), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 6, Data: []byte("baz!")})).To(Succeed())
+			Expect(str.Buffered()).To(Equal(3))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(1))
+			_, err := strWithTimeout.Read(make([]byte, 1))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Buffered()).To(Equal(2))
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 3, Data: []byte("bar")})).To(Succeed())
+			Expect(str.Buffered()).To(Equal(9))
+		})
+
+		It("blocks until enough data is available", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(2), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("fo")})).To(Succeed())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				b, err := str.Peek(4)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal([]byte("foob")))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 2, Data: []byte("obar")})).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("returns io.EOF when the stream ends before enough data is available", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), true)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo"), Fin: true})).To(Succeed())
+			b, err := str.Peek(4)
+			Expect(err).To(MatchError(io.EOF))
+			Expect(b).To(Equal([]byte("foo")))
+			b, err = str.Peek(3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foo")))
+		})
+
+		It("returns the error when the stream is reset", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true)
+			mockFC.EXPECT().Abandon()
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			Expect(str.handleResetStreamFrame(&wire.ResetStreamFrame{StreamID: streamID, FinalSize: 42, ErrorCode: 1234})).To(Succeed())
+			_, err := str.Peek(4)
+			Expect(err).To(MatchError(&StreamError{StreamID: streamID, ErrorCode: 1234}))
+			Expect(str.Buffered()).To(BeZero())
+		})
+
+		It("doesn't return data beyond the reliable size", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true)
+			Expect(str.handleResetStreamFrame(&wire.ResetStreamFrame{
+				StreamID:     streamID,
+				FinalSize:    42,
+				ErrorCode:    1234,
+				ReliableSize: 4,
+			})).To(Succeed())
+			Expect(str.Buffered()).To(Equal(4))
+			b, err := str.Peek(6)
+			Expect(err).To(MatchError(&StreamError{StreamID: streamID, ErrorCode: 1234}))
+			Expect(b).To(Equal([]byte("foob")))
+		})
+
+		It("respects the read deadline", func() {
+			str.SetReadDeadline(time.Now().Add(scaleDuration(20 * time.Millisecond)))
+			_, err := str.Peek(1)
+			Expect(err).To(MatchError(errDeadline))
+		})
+
+		It("errors for negative counts", func() {
+			_, err := str.Peek(-1)
+			Expect(err).To(MatchError(errNegativePeekCount))
+		})
+	})
+
 	Context("stream cancelations", func() {
 		Context("canceling read", func() {
 			It("unblocks Read", func() {
@@ -635,6 +794,203 @@ var _ = Describe("Receive Stream", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 		})
+
+		Context("receiving RESET_STREAM_AT frames", func() {
+			rst := &wire.ResetStreamFrame{
+				StreamID:     streamID,
+				FinalSize:    42,
+				ErrorCode:    1234,
+				ReliableSize: 6,
+			}
+			rstErr := &StreamError{StreamID: streamID, ErrorCode: 1234}
+
+			It("returns the data up to the reliable size before returning the error", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(10), false)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobarbaz!")})).To(Succeed())
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true)
+				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(4))
+				b := make([]byte, 4)
+				n, err := strWithTimeout.Read(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b[:n]).To(Equal([]byte("foob")))
+				gomock.InOrder(
+					mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2)),
+					mockFC.EXPECT().Abandon(),
+					mockSender.EXPECT().onStreamCompleted(streamID),
+				)
+				n, err = strWithTimeout.Read(b)
+				Expect(err).To(MatchError(rstErr))
+				Expect(b[:n]).To(Equal([]byte("ar")))
+				_, err = strWithTimeout.Read(b)
+				Expect(err).To(MatchError(rstErr))
+			})
+
+			It("waits for the data up to the reliable size", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true)
+				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					b := make([]byte, 10)
+					n, err := strWithTimeout.Read(b)
+					Expect(err).To(MatchError(rstErr))
+					Expect(b[:n]).To(Equal([]byte("foobar")))
+				}()
+				Consistently(done).ShouldNot(BeClosed())
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(8), false)
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+				mockFC.EXPECT().Abandon()
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar42")})).To(Succeed())
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("resets the stream right away, if the data up to the reliable size was already read", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+				_, err := strWithTimeout.Read(make([]byte, 6))
+				Expect(err).ToNot(HaveOccurred())
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true)
+				mockFC.EXPECT().Abandon()
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
+				_, err = strWithTimeout.Read(make([]byte, 6))
+				Expect(err).To(MatchError(rstErr))
+			})
+
+			It("only allows reducing the reliable size", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true).Times(3)
+				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
+				Expect(str.handleResetStreamFrame(&wire.ResetStreamFrame{
+					StreamID:     streamID,
+					FinalSize:    42,
+					ErrorCode:    1234,
+					ReliableSize: 10,
+				})).To(Succeed())
+				Expect(str.reliableSize).To(Equal(protocol.ByteCount(6)))
+				mockFC.EXPECT().Abandon()
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				Expect(str.handleResetStreamFrame(&wire.ResetStreamFrame{
+					StreamID:  streamID,
+					FinalSize: 42,
+					ErrorCode: 4321,
+				})).To(Succeed())
+				_, err := strWithTimeout.Read(make([]byte, 6))
+				Expect(err).To(MatchError(&StreamError{StreamID: streamID, ErrorCode: 4321}))
+			})
+
+			It("completes the stream when reading is canceled", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true)
+				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
+				mockSender.EXPECT().queueControlFrame(gomock.Any())
+				mockFC.EXPECT().Abandon()
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				str.CancelRead(1234)
+			})
+		})
+	})
+
+	Context("receiving EXPIRED_STREAM_DATA frames", func() {
+		expiredErr := func(offset protocol.ByteCount) error {
+			return &StreamDataExpiredError{StreamID: streamID, Offset: offset}
+		}
+
+		It("skips the expired data", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(9), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 6, Data: []byte("bar")})).To(Succeed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+			b := make([]byte, 3)
+			n, err := strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b[:n]).To(Equal([]byte("foo")))
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+			Expect(str.handleExpiredStreamDataFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 6})).To(Succeed())
+			n, err = strWithTimeout.Read(b)
+			Expect(err).To(MatchError(expiredErr(6)))
+			Expect(n).To(BeZero())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+			n, err = strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b[:n]).To(Equal([]byte("bar")))
+		})
+
+		It("unblocks Read", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(7), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 4, Data: []byte("bar")})).To(Succeed())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := strWithTimeout.Read(make([]byte, 10))
+				Expect(err).To(MatchError(expiredErr(4)))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(4), false)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(4))
+			Expect(str.handleExpiredStreamDataFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 4})).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+			b := make([]byte, 10)
+			n, err := strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b[:n]).To(Equal([]byte("bar")))
+		})
+
+		It("skips data in the frame that is currently being read", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2))
+			b := make([]byte, 2)
+			_, err := strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("fo")))
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(4), false)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2))
+			Expect(str.handleExpiredStreamDataFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 4})).To(Succeed())
+			_, err = strWithTimeout.Read(b)
+			Expect(err).To(MatchError(expiredErr(4)))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2))
+			_, err = strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("ar")))
+		})
+
+		It("ignores offsets that were already read", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+			_, err := strWithTimeout.Read(make([]byte, 6))
+			Expect(err).ToNot(HaveOccurred())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(4), false)
+			Expect(str.handleExpiredStreamDataFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 4})).To(Succeed())
+			Expect(str.dataExpiredErr).To(BeNil())
+		})
+
+		It("returns an EOF if all data up to the final offset expired", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), true)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 6, Fin: true})).To(Succeed())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+			Expect(str.handleExpiredStreamDataFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 6})).To(Succeed())
+			_, err := strWithTimeout.Read(make([]byte, 10))
+			Expect(err).To(MatchError(expiredErr(6)))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(0))
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			_, err = strWithTimeout.Read(make([]byte, 10))
+			Expect(err).To(MatchError(io.EOF))
+		})
+
+		It("errors when the offset violates flow control", func() {
+			testErr := errors.New("flow control violation")
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(1000), false).Return(testErr)
+			Expect(str.handleExpiredStreamDataFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 1000})).To(MatchError(testErr))
+		})
 	})
 
 	Context("flow control", func() {
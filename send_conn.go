@@ -2,6 +2,7 @@ package quic
 
 import (
 	"net"
+	"sync"
 )
 
 // A sendConn allows sending using a simple Write() on a non-connected packet conn.
@@ -72,3 +73,62 @@ func (c *spconn) Write(p []byte) error {
 func (c *spconn) RemoteAddr() net.Addr {
 	return c.remoteAddr
 }
+
+// A switchableConn is a sendConn that can be switched to a different path,
+// when the connection is migrated.
+type switchableConn struct {
+	mutex sync.RWMutex
+	conn  sendConn
+}
+
+var _ sendConn = &switchableConn{}
+
+func newSwitchableConn(c sendConn) *switchableConn {
+	return &switchableConn{conn: c}
+}
+
+func (c *switchableConn) get() sendConn {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conn
+}
+
+// Switch switches to a new path.
+// All subsequent packets are sent on that path.
+func (c *switchableConn) Switch(conn sendConn) {
+	c.mutex.Lock()
+	c.conn = conn
+	c.mutex.Unlock()
+}
+
+func (c *switchableConn) Write(p []byte) error { return c.get().Write(p) }
+func (c *switchableConn) Close() error         { return c.get().Close() }
+func (c *switchableConn) LocalAddr() net.Addr  { return c.get().LocalAddr() }
+func (c *switchableConn) RemoteAddr() net.Addr { return c.get().RemoteAddr() }
+
+// sendConnWithRemoteAddr returns a sendConn that uses the same packet conn as c,
+// but sends packets to a different remote address.
+// It returns nil if the type of c is unknown.
+func sendConnWithRemoteAddr(c sendConn, remote net.Addr, info *packetInfo) sendConn {
+	switch c := c.(type) {
+	case *switchableConn:
+		return sendConnWithRemoteAddr(c.get(), remote, info)
+	case *sconn:
+		return newSendConn(c.rawConn, remote, info)
+	case *spconn:
+		return newSendPconn(c.PacketConn, remote)
+	default:
+		return nil
+	}
+}
+
+// isSameAddr says if two addresses are equal.
+// If ignorePort is set, only the IP addresses of UDP addresses are compared.
+func isSameAddr(a, b net.Addr, ignorePort bool) bool {
+	ua, ok1 := a.(*net.UDPAddr)
+	ub, ok2 := b.(*net.UDPAddr)
+	if ok1 && ok2 {
+		return ua.IP.Equal(ub.IP) && (ignorePort || ua.Port == ub.Port)
+	}
+	return a.Network() == b.Network() && a.String() == b.String()
+}
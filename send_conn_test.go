@@ -43,3 +43,39 @@ var _ = Describe("Connection (for sending packets)", func() {
 		Expect(c.Close()).To(Succeed())
 	})
 })
+
+var _ = Describe("Switchable connection", func() {
+	It("switches to a new path", func() {
+		addr1 := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1337}
+		addr2 := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 201), Port: 1338}
+		packetConn1 := NewMockPacketConn(mockCtrl)
+		packetConn2 := NewMockPacketConn(mockCtrl)
+		c := newSwitchableConn(newSendPconn(packetConn1, addr1))
+		packetConn1.EXPECT().WriteTo([]byte("foo"), addr1)
+		Expect(c.Write([]byte("foo"))).To(Succeed())
+		Expect(c.RemoteAddr()).To(Equal(addr1))
+		c.Switch(newSendPconn(packetConn2, addr2))
+		packetConn2.EXPECT().WriteTo([]byte("bar"), addr2)
+		Expect(c.Write([]byte("bar"))).To(Succeed())
+		Expect(c.RemoteAddr()).To(Equal(addr2))
+	})
+
+	It("creates a send conn for a different remote address", func() {
+		addr1 := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1337}
+		addr2 := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 201), Port: 1338}
+		packetConn := NewMockPacketConn(mockCtrl)
+		c := sendConnWithRemoteAddr(newSwitchableConn(newSendPconn(packetConn, addr1)), addr2, nil)
+		Expect(c).ToNot(BeNil())
+		Expect(c.RemoteAddr()).To(Equal(addr2))
+		packetConn.EXPECT().WriteTo([]byte("foobar"), addr2)
+		Expect(c.Write([]byte("foobar"))).To(Succeed())
+	})
+
+	It("compares addresses", func() {
+		addr := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1337}
+		Expect(isSameAddr(addr, &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1337}, false)).To(BeTrue())
+		Expect(isSameAddr(addr, &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1338}, false)).To(BeFalse())
+		Expect(isSameAddr(addr, &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1338}, true)).To(BeTrue())
+		Expect(isSameAddr(addr, &net.UDPAddr{IP: net.IPv4(192, 168, 100, 201), Port: 1337}, true)).To(BeFalse())
+	})
+})
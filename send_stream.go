@@ -3,6 +3,7 @@ package quic
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -23,6 +24,11 @@ type sendStreamI interface {
 	updateSendWindow(protocol.ByteCount)
 }
 
+type sentStreamData struct {
+	offset protocol.ByteCount // the offset of the end of the data
+	time   time.Time
+}
+
 type sendStream struct {
 	mutex sync.Mutex
 
@@ -48,10 +54,24 @@ type sendStream struct {
 
 	dataForWriting []byte // during a Write() call, this slice is the part of p that still needs to be sent out
 	nextFrame      *wire.StreamFrame
+	ownedData      [][]byte // buffers handed over using WriteOwned, they are sent without copying them
+	// the maximum data length of the last STREAM frame popped from nextFrame, used to size the reads in ReadFrom
+	frameSizeHint protocol.ByteCount
 
 	writeChan chan struct{}
 	deadline  time.Time
 
+	// partial reliability, see SetDataExpiry
+	expiryMaxAge   time.Duration
+	expiryMaxBytes protocol.ByteCount
+	sentTimes      []sentStreamData // only used if expiryMaxAge is set
+	expiredOffset  protocol.ByteCount
+	// the highest offset sent in an EXPIRED_STREAM_DATA frame
+	expiredOffsetSent protocol.ByteCount
+
+	// data up to this offset is still delivered when the stream is canceled, see SetReliableBoundary
+	reliableSize protocol.ByteCount
+
 	flowController flowcontrol.StreamFlowController
 
 	version protocol.VersionNumber
@@ -186,7 +206,231 @@ func (s *sendStream) Write(p []byte) (int, error) {
 	return bytesWritten, nil
 }
 
+// WriteVectored writes the data of all buffers, as if they were concatenated.
+func (s *sendStream) WriteVectored(bufs [][]byte) (int, error) {
+	var bytesWritten int
+	for _, b := range bufs {
+		n, err := s.Write(b)
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+	return bytesWritten, nil
+}
+
+// WriteOwned writes the data of all buffers, taking ownership of them.
+// The data is sent directly from these buffers.
+func (s *sendStream) WriteOwned(bufs ...[]byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finishedWriting {
+		return 0, fmt.Errorf("write on closed stream %d", s.streamID)
+	}
+	if s.canceledWrite {
+		return 0, s.cancelWriteErr
+	}
+	if s.closeForShutdownErr != nil {
+		return 0, s.closeForShutdownErr
+	}
+	if !s.deadline.IsZero() && !time.Now().Before(s.deadline) {
+		return 0, errDeadline
+	}
+
+	var total protocol.ByteCount
+	for _, b := range bufs {
+		if len(b) > 0 {
+			s.ownedData = append(s.ownedData, b)
+			total += protocol.ByteCount(len(b))
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	s.mutex.Unlock()
+	s.sender.onHasStreamData(s.streamID) // must be called without holding the mutex
+	s.mutex.Lock()
+
+	// Just like Write, we return as soon as all but the last packet's worth of data has been sent out.
+	var deadlineTimer *utils.Timer
+	for s.ownedDataLen() > protocol.MaxPacketBufferSize && !s.canceledWrite && !s.closedForShutdown {
+		deadline := s.deadline
+		if !deadline.IsZero() {
+			if !time.Now().Before(deadline) {
+				// Drop the data of this call that hasn't been sent out yet.
+				queued := s.ownedDataLen()
+				unsent := utils.MinByteCount(queued, total)
+				s.truncateOwnedData(queued - unsent)
+				return int(total - unsent), errDeadline
+			}
+			if deadlineTimer == nil {
+				deadlineTimer = utils.NewTimer()
+				defer deadlineTimer.Stop()
+			}
+			deadlineTimer.Reset(deadline)
+		}
+
+		s.mutex.Unlock()
+		if deadline.IsZero() {
+			<-s.writeChan
+		} else {
+			select {
+			case <-s.writeChan:
+			case <-deadlineTimer.Chan():
+				deadlineTimer.SetRead()
+			}
+		}
+		s.mutex.Lock()
+	}
+
+	if s.closeForShutdownErr != nil {
+		return int(total - utils.MinByteCount(s.ownedDataLen(), total)), s.closeForShutdownErr
+	} else if s.cancelWriteErr != nil {
+		return int(total - utils.MinByteCount(s.ownedDataLen(), total)), s.cancelWriteErr
+	}
+	return int(total), nil
+}
+
+// ReadFrom reads data from r until EOF or an error occurs, and writes it to the stream.
+// The data is read directly into the buffers of the STREAM frames, avoiding an additional copy.
+func (s *sendStream) ReadFrom(r io.Reader) (int64, error) {
+	var written int64
+	for {
+		s.mutex.Lock()
+		size := s.frameSizeHint
+		s.mutex.Unlock()
+		if size == 0 {
+			size = protocol.MaxPacketBufferSize
+		}
+
+		f := wire.GetStreamFrame()
+		n, rerr := r.Read(f.Data[:size])
+		if n > 0 {
+			f.Data = f.Data[:n]
+			if err := s.queueFrameForWriting(f); err != nil {
+				f.PutBack()
+				return written, err
+			}
+			written += int64(n)
+		} else {
+			f.PutBack()
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
+
+// queueFrameForWriting queues a frame filled by ReadFrom.
+// It blocks until the previously queued data has been sent out, unless the data fits into the same frame.
+func (s *sendStream) queueFrameForWriting(f *wire.StreamFrame) error {
+	s.mutex.Lock()
+	if s.finishedWriting {
+		s.mutex.Unlock()
+		return fmt.Errorf("write on closed stream %d", s.streamID)
+	}
+
+	var deadlineTimer *utils.Timer
+	for {
+		if s.canceledWrite {
+			s.mutex.Unlock()
+			return s.cancelWriteErr
+		}
+		if s.closeForShutdownErr != nil {
+			s.mutex.Unlock()
+			return s.closeForShutdownErr
+		}
+		// Data handed over using WriteOwned is sent before the nextFrame.
+		if len(s.ownedData) == 0 {
+			if s.nextFrame == nil {
+				f.StreamID = s.streamID
+				f.Offset = s.writeOffset
+				f.DataLenPresent = true
+				f.Fin = false
+				s.nextFrame = f
+				break
+			}
+			if l := len(s.nextFrame.Data); protocol.ByteCount(l+len(f.Data)) <= protocol.MaxPacketBufferSize {
+				s.nextFrame.Data = s.nextFrame.Data[:l+len(f.Data)]
+				copy(s.nextFrame.Data[l:], f.Data)
+				f.PutBack()
+				break
+			}
+		}
+
+		deadline := s.deadline
+		if !deadline.IsZero() {
+			if !time.Now().Before(deadline) {
+				s.mutex.Unlock()
+				return errDeadline
+			}
+			if deadlineTimer == nil {
+				deadlineTimer = utils.NewTimer()
+				defer deadlineTimer.Stop()
+			}
+			deadlineTimer.Reset(deadline)
+		}
+		s.mutex.Unlock()
+		if deadline.IsZero() {
+			<-s.writeChan
+		} else {
+			select {
+			case <-s.writeChan:
+			case <-deadlineTimer.Chan():
+				deadlineTimer.SetRead()
+			}
+		}
+		s.mutex.Lock()
+	}
+	s.mutex.Unlock()
+
+	s.sender.onHasStreamData(s.streamID) // must be called without holding the mutex
+	return nil
+}
+
+func (s *sendStream) ownedDataLen() protocol.ByteCount {
+	var l protocol.ByteCount
+	for _, b := range s.ownedData {
+		l += protocol.ByteCount(len(b))
+	}
+	return l
+}
+
+// truncateOwnedData drops all but the first n bytes of the data handed over using WriteOwned.
+func (s *sendStream) truncateOwnedData(n protocol.ByteCount) {
+	for i, b := range s.ownedData {
+		if protocol.ByteCount(len(b)) >= n {
+			if n > 0 {
+				s.ownedData[i] = b[:n]
+				i++
+			}
+			for j := i; j < len(s.ownedData); j++ {
+				s.ownedData[j] = nil
+			}
+			s.ownedData = s.ownedData[:i]
+			break
+		}
+		n -= protocol.ByteCount(len(b))
+	}
+	if len(s.ownedData) == 0 {
+		s.ownedData = nil
+	}
+}
+
+// hasPendingData says if there's data that was written, but hasn't been popped yet.
+func (s *sendStream) hasPendingData() bool {
+	return s.dataForWriting != nil || s.nextFrame != nil || len(s.ownedData) > 0
+}
+
 func (s *sendStream) canBufferStreamFrame() bool {
+	// Data passed to Write must not be sent before data handed over using WriteOwned.
+	if len(s.ownedData) > 0 {
+		return false
+	}
 	var l protocol.ByteCount
 	if s.nextFrame != nil {
 		l = s.nextFrame.DataLen()
@@ -211,7 +455,7 @@ func (s *sendStream) popStreamFrame(maxBytes protocol.ByteCount) (*ackhandler.Fr
 }
 
 func (s *sendStream) popNewOrRetransmittedStreamFrame(maxBytes protocol.ByteCount) (*wire.StreamFrame, bool /* has more data to send */) {
-	if s.canceledWrite || s.closeForShutdownErr != nil {
+	if (s.canceledWrite && s.reliableSize == 0) || s.closeForShutdownErr != nil {
 		return nil, false
 	}
 
@@ -227,7 +471,11 @@ func (s *sendStream) popNewOrRetransmittedStreamFrame(maxBytes protocol.ByteCoun
 		}
 	}
 
-	if len(s.dataForWriting) == 0 && s.nextFrame == nil {
+	if s.canceledWrite && s.writeOffset >= s.reliableSize {
+		return nil, false
+	}
+
+	if !s.hasPendingData() {
 		if s.finishedWriting && !s.finSent {
 			s.finSent = true
 			return &wire.StreamFrame{
@@ -251,13 +499,22 @@ func (s *sendStream) popNewOrRetransmittedStreamFrame(maxBytes protocol.ByteCoun
 		}
 		return nil, true
 	}
+	if s.canceledWrite {
+		// After a reliable reset, we only send the data up to the reliable size.
+		sendWindow = utils.MinByteCount(sendWindow, s.reliableSize-s.writeOffset)
+	}
 
 	f, hasMoreData := s.popNewStreamFrame(maxBytes, sendWindow)
 	if dataLen := f.DataLen(); dataLen > 0 {
 		s.writeOffset += f.DataLen()
 		s.flowController.AddBytesSent(f.DataLen())
+		if s.expiryMaxAge > 0 {
+			now := time.Now()
+			s.updateExpiredOffset(now)
+			s.sentTimes = append(s.sentTimes, sentStreamData{offset: s.writeOffset, time: now})
+		}
 	}
-	f.Fin = s.finishedWriting && s.dataForWriting == nil && s.nextFrame == nil && !s.finSent
+	f.Fin = s.finishedWriting && !s.hasPendingData() && !s.finSent
 	if f.Fin {
 		s.finSent = true
 	}
@@ -269,7 +526,11 @@ func (s *sendStream) popNewStreamFrame(maxBytes, sendWindow protocol.ByteCount)
 		nextFrame := s.nextFrame
 		s.nextFrame = nil
 
-		maxDataLen := utils.MinByteCount(sendWindow, nextFrame.MaxDataLen(maxBytes, s.version))
+		maxFrameDataLen := nextFrame.MaxDataLen(maxBytes, s.version)
+		if maxFrameDataLen > 0 {
+			s.frameSizeHint = utils.MinByteCount(maxFrameDataLen, protocol.MaxPacketBufferSize)
+		}
+		maxDataLen := utils.MinByteCount(sendWindow, maxFrameDataLen)
 		if nextFrame.DataLen() > maxDataLen {
 			s.nextFrame = wire.GetStreamFrame()
 			s.nextFrame.StreamID = s.streamID
@@ -279,9 +540,17 @@ func (s *sendStream) popNewStreamFrame(maxBytes, sendWindow protocol.ByteCount)
 			copy(s.nextFrame.Data, nextFrame.Data[maxDataLen:])
 			nextFrame.Data = nextFrame.Data[:maxDataLen]
 		} else {
+			// fill up the frame with data from the current Write call
+			if l := nextFrame.DataLen(); l < maxDataLen && s.dataForWriting != nil && len(s.ownedData) == 0 {
+				s.getDataForWriting(nextFrame, maxDataLen-l)
+			}
 			s.signalWrite()
 		}
-		return nextFrame, s.nextFrame != nil || s.dataForWriting != nil
+		return nextFrame, s.hasPendingData()
+	}
+
+	if len(s.ownedData) > 0 {
+		return s.popOwnedStreamFrame(maxBytes, sendWindow)
 	}
 
 	f := wire.GetStreamFrame()
@@ -299,14 +568,61 @@ func (s *sendStream) popNewStreamFrame(maxBytes, sendWindow protocol.ByteCount)
 	return f, hasMoreData
 }
 
+// popOwnedStreamFrame pops a STREAM frame containing data handed over using WriteOwned.
+// Large buffers are sent without copying them, small buffers are coalesced into a single frame.
+func (s *sendStream) popOwnedStreamFrame(maxBytes, sendWindow protocol.ByteCount) (*wire.StreamFrame, bool) {
+	f := &wire.StreamFrame{
+		StreamID:       s.streamID,
+		Offset:         s.writeOffset,
+		DataLenPresent: true,
+	}
+	maxDataLen := utils.MinByteCount(sendWindow, f.MaxDataLen(maxBytes, s.version))
+	if maxDataLen == 0 { // a STREAM frame must have at least one byte of data
+		return nil, true
+	}
+	if b := s.ownedData[0]; len(s.ownedData) == 1 || protocol.ByteCount(len(b)) >= maxDataLen {
+		if protocol.ByteCount(len(b)) > maxDataLen {
+			f.Data = b[:maxDataLen:maxDataLen]
+			s.ownedData[0] = b[maxDataLen:]
+		} else {
+			f.Data = b
+			s.ownedData[0] = nil
+			s.ownedData = s.ownedData[1:]
+		}
+	} else {
+		f = wire.GetStreamFrame()
+		f.Fin = false
+		f.StreamID = s.streamID
+		f.Offset = s.writeOffset
+		f.DataLenPresent = true
+		f.Data = f.Data[:0]
+		for len(s.ownedData) > 0 && f.DataLen() < maxDataLen {
+			b := s.ownedData[0]
+			n := utils.MinByteCount(protocol.ByteCount(len(b)), maxDataLen-f.DataLen())
+			f.Data = append(f.Data, b[:n]...)
+			if n < protocol.ByteCount(len(b)) {
+				s.ownedData[0] = b[n:]
+			} else {
+				s.ownedData[0] = nil
+				s.ownedData = s.ownedData[1:]
+			}
+		}
+	}
+	if len(s.ownedData) == 0 {
+		s.ownedData = nil
+	}
+	s.signalWrite()
+	return f, s.hasPendingData()
+}
+
 func (s *sendStream) popNewStreamFrameWithoutBuffer(f *wire.StreamFrame, maxBytes, sendWindow protocol.ByteCount) bool {
 	maxDataLen := f.MaxDataLen(maxBytes, s.version)
 	if maxDataLen == 0 { // a STREAM frame must have at least one byte of data
-		return s.dataForWriting != nil || s.nextFrame != nil || s.finishedWriting
+		return s.hasPendingData() || s.finishedWriting
 	}
 	s.getDataForWriting(f, utils.MinByteCount(maxDataLen, sendWindow))
 
-	return s.dataForWriting != nil || s.nextFrame != nil || s.finishedWriting
+	return s.hasPendingData() || s.finishedWriting
 }
 
 func (s *sendStream) maybeGetRetransmission(maxBytes protocol.ByteCount) (*wire.StreamFrame, bool /* has more retransmissions */) {
@@ -319,6 +635,84 @@ func (s *sendStream) maybeGetRetransmission(maxBytes protocol.ByteCount) (*wire.
 	return f, len(s.retransmissionQueue) > 0
 }
 
+// dropExpiredRetransmissions drops lost data that expired, instead of retransmitting it.
+// It informs the peer by sending an EXPIRED_STREAM_DATA frame.
+// It returns true if any data was dropped.
+func (s *sendStream) dropExpiredRetransmissions() bool {
+	if len(s.retransmissionQueue) == 0 || (s.expiryMaxAge <= 0 && s.expiryMaxBytes <= 0) {
+		return false
+	}
+	if !s.sender.supportsPartialReliability() {
+		return false
+	}
+	s.updateExpiredOffset(time.Now())
+
+	var dropped bool
+	queue := s.retransmissionQueue[:0]
+	for _, f := range s.retransmissionQueue {
+		if f.Offset >= s.expiredOffset {
+			queue = append(queue, f)
+			continue
+		}
+		dropped = true
+		if f.Offset+f.DataLen() <= s.expiredOffset {
+			// The FIN still needs to be retransmitted.
+			if !f.Fin {
+				f.PutBack()
+				continue
+			}
+			f.Data = f.Data[:0]
+		} else {
+			n := copy(f.Data, f.Data[s.expiredOffset-f.Offset:])
+			f.Data = f.Data[:n]
+		}
+		f.Offset = s.expiredOffset
+		queue = append(queue, f)
+	}
+	s.retransmissionQueue = queue
+	if dropped && s.expiredOffset > s.expiredOffsetSent {
+		s.expiredOffsetSent = s.expiredOffset
+		s.sender.queueControlFrame(&wire.ExpiredStreamDataFrame{
+			StreamID: s.streamID,
+			Offset:   s.expiredOffset,
+		})
+	}
+	return dropped
+}
+
+// dropUnreliableRetransmissions drops lost data beyond the reliable size, after the stream was reset.
+// It returns true if any data was dropped.
+func (s *sendStream) dropUnreliableRetransmissions() bool {
+	var dropped bool
+	queue := s.retransmissionQueue[:0]
+	for _, f := range s.retransmissionQueue {
+		if f.Offset >= s.reliableSize {
+			dropped = true
+			f.PutBack()
+			continue
+		}
+		if f.Offset+f.DataLen() > s.reliableSize {
+			dropped = true
+			f.Data = f.Data[:s.reliableSize-f.Offset]
+			f.Fin = false
+		}
+		queue = append(queue, f)
+	}
+	s.retransmissionQueue = queue
+	return dropped
+}
+
+func (s *sendStream) updateExpiredOffset(now time.Time) {
+	if s.expiryMaxBytes > 0 && s.writeOffset > s.expiryMaxBytes {
+		s.expiredOffset = utils.MaxByteCount(s.expiredOffset, s.writeOffset-s.expiryMaxBytes)
+	}
+	var i int
+	for ; i < len(s.sentTimes) && now.Sub(s.sentTimes[i].time) >= s.expiryMaxAge; i++ {
+		s.expiredOffset = utils.MaxByteCount(s.expiredOffset, s.sentTimes[i].offset)
+	}
+	s.sentTimes = s.sentTimes[i:]
+}
+
 func (s *sendStream) hasData() bool {
 	s.mutex.Lock()
 	hasData := len(s.dataForWriting) > 0
@@ -326,16 +720,18 @@ func (s *sendStream) hasData() bool {
 	return hasData
 }
 
+// getDataForWriting appends up to maxBytes of the data of the current Write call to the frame.
 func (s *sendStream) getDataForWriting(f *wire.StreamFrame, maxBytes protocol.ByteCount) {
+	l := len(f.Data)
 	if protocol.ByteCount(len(s.dataForWriting)) <= maxBytes {
-		f.Data = f.Data[:len(s.dataForWriting)]
-		copy(f.Data, s.dataForWriting)
+		f.Data = f.Data[:l+len(s.dataForWriting)]
+		copy(f.Data[l:], s.dataForWriting)
 		s.dataForWriting = nil
 		s.signalWrite()
 		return
 	}
-	f.Data = f.Data[:maxBytes]
-	copy(f.Data, s.dataForWriting)
+	f.Data = f.Data[:protocol.ByteCount(l)+maxBytes]
+	copy(f.Data[l:], s.dataForWriting)
 	s.dataForWriting = s.dataForWriting[maxBytes:]
 	if s.canBufferStreamFrame() {
 		s.signalWrite()
@@ -346,7 +742,7 @@ func (s *sendStream) frameAcked(f wire.Frame) {
 	f.(*wire.StreamFrame).PutBack()
 
 	s.mutex.Lock()
-	if s.canceledWrite {
+	if s.canceledWrite && s.reliableSize == 0 {
 		s.mutex.Unlock()
 		return
 	}
@@ -363,7 +759,7 @@ func (s *sendStream) frameAcked(f wire.Frame) {
 }
 
 func (s *sendStream) isNewlyCompleted() bool {
-	completed := (s.finSent || s.canceledWrite) && s.numOutstandingFrames == 0 && len(s.retransmissionQueue) == 0
+	completed := (s.finSent || (s.canceledWrite && s.writeOffset >= s.reliableSize)) && s.numOutstandingFrames == 0 && len(s.retransmissionQueue) == 0
 	if completed && !s.completed {
 		s.completed = true
 		return true
@@ -375,7 +771,7 @@ func (s *sendStream) queueRetransmission(f wire.Frame) {
 	sf := f.(*wire.StreamFrame)
 	sf.DataLenPresent = true
 	s.mutex.Lock()
-	if s.canceledWrite {
+	if s.canceledWrite && s.reliableSize == 0 {
 		s.mutex.Unlock()
 		return
 	}
@@ -384,9 +780,23 @@ func (s *sendStream) queueRetransmission(f wire.Frame) {
 	if s.numOutstandingFrames < 0 {
 		panic("numOutStandingFrames negative")
 	}
+	var dropped bool
+	if s.canceledWrite {
+		dropped = s.dropUnreliableRetransmissions()
+	} else {
+		dropped = s.dropExpiredRetransmissions()
+	}
+	if dropped && s.isNewlyCompleted() {
+		s.mutex.Unlock()
+		s.sender.onStreamCompleted(s.streamID)
+		return
+	}
+	hasRetransmission := len(s.retransmissionQueue) > 0
 	s.mutex.Unlock()
 
-	s.sender.onHasStreamData(s.streamID)
+	if hasRetransmission {
+		s.sender.onHasStreamData(s.streamID)
+	}
 }
 
 func (s *sendStream) Close() error {
@@ -408,11 +818,11 @@ func (s *sendStream) Close() error {
 }
 
 func (s *sendStream) CancelWrite(errorCode StreamErrorCode) {
-	s.cancelWriteImpl(errorCode, fmt.Errorf("Write on stream %d canceled with error code %d", s.streamID, errorCode))
+	s.cancelWriteImpl(errorCode, true, fmt.Errorf("Write on stream %d canceled with error code %d", s.streamID, errorCode))
 }
 
 // must be called after locking the mutex
-func (s *sendStream) cancelWriteImpl(errorCode qerr.StreamErrorCode, writeErr error) {
+func (s *sendStream) cancelWriteImpl(errorCode qerr.StreamErrorCode, allowReliable bool, writeErr error) {
 	s.mutex.Lock()
 	if s.canceledWrite {
 		s.mutex.Unlock()
@@ -421,25 +831,39 @@ func (s *sendStream) cancelWriteImpl(errorCode qerr.StreamErrorCode, writeErr er
 	s.ctxCancel()
 	s.canceledWrite = true
 	s.cancelWriteErr = writeErr
-	s.numOutstandingFrames = 0
-	s.retransmissionQueue = nil
+	if s.reliableSize > 0 && (!allowReliable || !s.sender.supportsReliableStreamReset()) {
+		s.reliableSize = 0
+	}
+	if s.reliableSize > 0 {
+		s.dropUnreliableRetransmissions()
+	} else {
+		s.numOutstandingFrames = 0
+		s.retransmissionQueue = nil
+	}
+	reliableSize := s.reliableSize
+	finalSize := utils.MaxByteCount(s.writeOffset, reliableSize)
+	hasData := len(s.retransmissionQueue) > 0 || s.writeOffset < reliableSize
 	newlyCompleted := s.isNewlyCompleted()
 	s.mutex.Unlock()
 
 	s.signalWrite()
 	s.sender.queueControlFrame(&wire.ResetStreamFrame{
-		StreamID:  s.streamID,
-		FinalSize: s.writeOffset,
-		ErrorCode: errorCode,
+		StreamID:     s.streamID,
+		FinalSize:    finalSize,
+		ErrorCode:    errorCode,
+		ReliableSize: reliableSize,
 	})
 	if newlyCompleted {
 		s.sender.onStreamCompleted(s.streamID)
 	}
+	if hasData {
+		s.sender.onHasStreamData(s.streamID)
+	}
 }
 
 func (s *sendStream) updateSendWindow(limit protocol.ByteCount) {
 	s.mutex.Lock()
-	hasStreamData := s.dataForWriting != nil || s.nextFrame != nil
+	hasStreamData := s.hasPendingData()
 	s.mutex.Unlock()
 
 	s.flowController.UpdateSendWindow(limit)
@@ -449,7 +873,8 @@ func (s *sendStream) updateSendWindow(limit protocol.ByteCount) {
 }
 
 func (s *sendStream) handleStopSendingFrame(frame *wire.StopSendingFrame) {
-	s.cancelWriteImpl(frame.ErrorCode, &StreamError{
+	// The peer isn't interested in the data any more, so there's no need to deliver it reliably.
+	s.cancelWriteImpl(frame.ErrorCode, false, &StreamError{
 		StreamID:  s.streamID,
 		ErrorCode: frame.ErrorCode,
 	})
@@ -467,6 +892,39 @@ func (s *sendStream) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+func (s *sendStream) SetDataExpiry(maxAge time.Duration, maxBytes protocol.ByteCount) {
+	s.mutex.Lock()
+	s.expiryMaxAge = maxAge
+	s.expiryMaxBytes = maxBytes
+	if maxAge <= 0 {
+		s.sentTimes = nil
+	}
+	s.mutex.Unlock()
+}
+
+func (s *sendStream) SetReliableBoundary() {
+	s.mutex.Lock()
+	if !s.canceledWrite {
+		s.reliableSize = s.writeOffset
+		if s.nextFrame != nil {
+			s.reliableSize += s.nextFrame.DataLen()
+		}
+		s.reliableSize += s.ownedDataLen()
+	}
+	s.mutex.Unlock()
+}
+
+func (s *sendStream) SetPriority(p StreamPriority) {
+	s.mutex.Lock()
+	completed := s.completed
+	s.mutex.Unlock()
+	// The priority of a completed stream doesn't matter any more.
+	if completed {
+		return
+	}
+	s.sender.setStreamPriority(s.streamID, p)
+}
+
 // CloseForShutdown closes a stream abruptly.
 // It makes Write unblock (and return the error) immediately.
 // The peer will NOT be informed about this: the stream is closed without sending a FIN or RST.
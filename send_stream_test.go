@@ -6,6 +6,8 @@ import (
 	"io"
 	mrand "math/rand"
 	"runtime"
+	"sync"
+	"testing/iotest"
 	"time"
 
 	"github.com/golang/mock/gomock"
@@ -19,6 +21,20 @@ import (
 	"github.com/onsi/gomega/gbytes"
 )
 
+type readSizeRecorder struct {
+	io.Reader
+
+	mutex sync.Mutex
+	sizes []int
+}
+
+func (r *readSizeRecorder) Read(b []byte) (int, error) {
+	r.mutex.Lock()
+	r.sizes = append(r.sizes, len(b))
+	r.mutex.Unlock()
+	return r.Reader.Read(b)
+}
+
 var _ = Describe("Send Stream", func() {
 	const streamID protocol.StreamID = 1337
 
@@ -299,6 +315,269 @@ var _ = Describe("Send Stream", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("fills up a buffered STREAM frame with data from the next Write", func() {
+			mockSender.EXPECT().onHasStreamData(streamID).Times(2)
+			_, err := strWithTimeout.Write([]byte("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := str.Write(getDataAtOffset(3, 1800))
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			Eventually(func() bool {
+				str.mutex.Lock()
+				defer str.mutex.Unlock()
+				return str.dataForWriting != nil
+			}).Should(BeTrue())
+			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount)
+			mockFC.EXPECT().AddBytesSent(gomock.Any())
+			frame, hasMoreData := str.popStreamFrame(500)
+			Expect(hasMoreData).To(BeTrue())
+			f := frame.Frame.(*wire.StreamFrame)
+			Expect(f.Length(protocol.VersionWhatever)).To(Equal(protocol.ByteCount(500)))
+			Expect(f.Data[:3]).To(Equal([]byte("foo")))
+			Expect(f.Data[3:]).To(Equal(getDataAtOffset(3, f.DataLen()-3)))
+			Eventually(done).Should(BeClosed())
+		})
+
+		Context("vectored writes", func() {
+			It("writes multiple buffers", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).Times(2)
+				n, err := str.WriteVectored([][]byte{[]byte("foo"), []byte("bar")})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(6))
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount)
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+				frame, hasMoreData := str.popStreamFrame(protocol.MaxByteCount)
+				Expect(hasMoreData).To(BeFalse())
+				Expect(frame.Frame.(*wire.StreamFrame).Data).To(Equal([]byte("foobar")))
+			})
+
+			It("returns the number of bytes written when an error occurs", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).Times(2)
+				str.SetWriteDeadline(time.Now().Add(scaleDuration(20 * time.Millisecond)))
+				n, err := str.WriteVectored([][]byte{[]byte("foo"), getData(5000), []byte("bar")})
+				Expect(err).To(MatchError(errDeadline))
+				Expect(n).To(Equal(3))
+			})
+		})
+
+		Context("owned writes", func() {
+			It("sends the data without copying it", func() {
+				mockSender.EXPECT().onHasStreamData(streamID)
+				b := []byte("foobar")
+				n, err := str.WriteOwned(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(6))
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).Times(2)
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(4))
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(2))
+				frame, hasMoreData := str.popStreamFrame(expectedFrameHeaderLen(0) + 4)
+				Expect(hasMoreData).To(BeTrue())
+				f := frame.Frame.(*wire.StreamFrame)
+				Expect(f.Data).To(Equal([]byte("foob")))
+				Expect(&f.Data[0]).To(BeIdenticalTo(&b[0]))
+				frame, hasMoreData = str.popStreamFrame(protocol.MaxByteCount)
+				Expect(hasMoreData).To(BeFalse())
+				f = frame.Frame.(*wire.StreamFrame)
+				Expect(f.Data).To(Equal([]byte("ar")))
+				Expect(f.Offset).To(Equal(protocol.ByteCount(4)))
+				Expect(&f.Data[0]).To(BeIdenticalTo(&b[4]))
+				Expect(str.ownedData).To(BeNil())
+			})
+
+			It("coalesces small buffers", func() {
+				mockSender.EXPECT().onHasStreamData(streamID)
+				n, err := str.WriteOwned([]byte("foo"), nil, []byte("bar"), []byte("baz"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(9))
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).Times(2)
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(4))
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(5))
+				frame, hasMoreData := str.popStreamFrame(expectedFrameHeaderLen(0) + 4)
+				Expect(hasMoreData).To(BeTrue())
+				Expect(frame.Frame.(*wire.StreamFrame).Data).To(Equal([]byte("foob")))
+				frame, hasMoreData = str.popStreamFrame(protocol.MaxByteCount)
+				Expect(hasMoreData).To(BeFalse())
+				Expect(frame.Frame.(*wire.StreamFrame).Data).To(Equal([]byte("arbaz")))
+			})
+
+			It("blocks until most of the data has been sent", func() {
+				mockSender.EXPECT().onHasStreamData(streamID)
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					n, err := str.WriteOwned(getData(3000))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(n).To(Equal(3000))
+				}()
+				Consistently(done).ShouldNot(BeClosed())
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).Times(2)
+				mockFC.EXPECT().AddBytesSent(gomock.Any()).Times(2)
+				frame, _ := str.popStreamFrame(1000)
+				Expect(frame).ToNot(BeNil())
+				Consistently(done).ShouldNot(BeClosed())
+				frame, _ = str.popStreamFrame(1000)
+				Expect(frame).ToNot(BeNil())
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("sends data passed to Write after the owned data", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).Times(2)
+				_, err := str.WriteOwned([]byte("foo"))
+				Expect(err).ToNot(HaveOccurred())
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					_, err := strWithTimeout.Write([]byte("bar"))
+					Expect(err).ToNot(HaveOccurred())
+				}()
+				waitForWrite()
+				Consistently(done).ShouldNot(BeClosed())
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).Times(2)
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(3)).Times(2)
+				frame, hasMoreData := str.popStreamFrame(protocol.MaxByteCount)
+				Expect(hasMoreData).To(BeTrue())
+				Expect(frame.Frame.(*wire.StreamFrame).Data).To(Equal([]byte("foo")))
+				frame, _ = str.popStreamFrame(protocol.MaxByteCount)
+				Expect(frame.Frame.(*wire.StreamFrame).Data).To(Equal([]byte("bar")))
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("sets the FIN bit on the last frame", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).Times(2)
+				_, err := str.WriteOwned([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount)
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+				frame, hasMoreData := str.popStreamFrame(protocol.MaxByteCount)
+				Expect(hasMoreData).To(BeFalse())
+				f := frame.Frame.(*wire.StreamFrame)
+				Expect(f.Data).To(Equal([]byte("foobar")))
+				Expect(f.Fin).To(BeTrue())
+			})
+
+			It("drops the unsent data when the deadline expires", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).Times(2)
+				_, err := str.WriteOwned([]byte("foo"))
+				Expect(err).ToNot(HaveOccurred())
+				str.SetWriteDeadline(time.Now().Add(scaleDuration(20 * time.Millisecond)))
+				n, err := str.WriteOwned(getData(1000), getData(1000))
+				Expect(err).To(MatchError(errDeadline))
+				Expect(n).To(BeZero())
+				Expect(str.ownedData).To(Equal([][]byte{[]byte("foo")}))
+			})
+
+			It("doesn't allow writes after it has been closed", func() {
+				mockSender.EXPECT().onHasStreamData(streamID)
+				Expect(str.Close()).To(Succeed())
+				_, err := str.WriteOwned([]byte("foobar"))
+				Expect(err).To(MatchError("write on closed stream 1337"))
+			})
+		})
+
+		Context("reading from an io.Reader", func() {
+			It("reads all data", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).AnyTimes()
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
+				mockFC.EXPECT().AddBytesSent(gomock.Any()).AnyTimes()
+				data := getData(5000)
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					n, err := str.ReadFrom(bytes.NewReader(data))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(n).To(BeEquivalentTo(5000))
+				}()
+				var received []byte
+				Eventually(func() []byte {
+					if frame, _ := str.popStreamFrame(1000); frame != nil {
+						received = append(received, frame.Frame.(*wire.StreamFrame).Data...)
+					}
+					return received
+				}).Should(Equal(data))
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("sizes the reads according to the size of the STREAM frames", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).AnyTimes()
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
+				mockFC.EXPECT().AddBytesSent(gomock.Any()).AnyTimes()
+				r := &readSizeRecorder{Reader: bytes.NewReader(getData(5000))}
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					str.ReadFrom(r)
+				}()
+				Eventually(func() *ackhandler.Frame {
+					frame, _ := str.popStreamFrame(500)
+					return frame
+				}).ShouldNot(BeNil())
+				Expect(str.frameSizeHint).To(BeNumerically("<", 500))
+				for i := 0; i < 5; i++ {
+					Eventually(func() *ackhandler.Frame {
+						frame, _ := str.popStreamFrame(500)
+						return frame
+					}).ShouldNot(BeNil())
+				}
+				r.mutex.Lock()
+				Expect(r.sizes[0]).To(BeEquivalentTo(protocol.MaxPacketBufferSize))
+				Expect(r.sizes[len(r.sizes)-1]).To(BeNumerically("<", 500))
+				r.mutex.Unlock()
+				mockSender.EXPECT().queueControlFrame(gomock.Any())
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				str.CancelWrite(1234)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("bundles small reads into a single STREAM frame", func() {
+				mockSender.EXPECT().onHasStreamData(streamID).Times(6)
+				n, err := str.ReadFrom(iotest.OneByteReader(bytes.NewReader([]byte("foobar"))))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(BeEquivalentTo(6))
+				mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount)
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+				frame, hasMoreData := str.popStreamFrame(protocol.MaxByteCount)
+				Expect(hasMoreData).To(BeFalse())
+				f := frame.Frame.(*wire.StreamFrame)
+				Expect(f.Data).To(Equal([]byte("foobar")))
+				Expect(f.Offset).To(BeZero())
+			})
+
+			It("returns the error of the reader", func() {
+				mockSender.EXPECT().onHasStreamData(streamID)
+				testErr := errors.New("test error")
+				n, err := str.ReadFrom(iotest.DataErrReader(iotest.ErrReader(testErr)))
+				Expect(err).To(MatchError(testErr))
+				Expect(n).To(BeZero())
+				n, err = str.ReadFrom(io.MultiReader(bytes.NewReader([]byte("foo")), iotest.ErrReader(testErr)))
+				Expect(err).To(MatchError(testErr))
+				Expect(n).To(BeEquivalentTo(3))
+			})
+
+			It("returns when the deadline expires", func() {
+				mockSender.EXPECT().onHasStreamData(streamID)
+				str.SetWriteDeadline(time.Now().Add(scaleDuration(20 * time.Millisecond)))
+				n, err := str.ReadFrom(bytes.NewReader(getData(5000)))
+				Expect(err).To(MatchError(errDeadline))
+				Expect(n).To(BeEquivalentTo(protocol.MaxPacketBufferSize))
+			})
+
+			It("doesn't allow reading after the stream was closed", func() {
+				mockSender.EXPECT().onHasStreamData(streamID)
+				Expect(str.Close()).To(Succeed())
+				_, err := str.ReadFrom(bytes.NewReader([]byte("foobar")))
+				Expect(err).To(MatchError("write on closed stream 1337"))
+			})
+		})
+
 		It("cancels the context when Close is called", func() {
 			mockSender.EXPECT().onHasStreamData(streamID)
 			Expect(str.Context().Done()).ToNot(BeClosed())
@@ -649,6 +928,20 @@ var _ = Describe("Send Stream", func() {
 		})
 	})
 
+	Context("priorities", func() {
+		It("sets the priority", func() {
+			mockSender.EXPECT().setStreamPriority(streamID, StreamPriority{Urgency: 1, Weight: 2})
+			str.SetPriority(StreamPriority{Urgency: 1, Weight: 2})
+		})
+
+		It("doesn't set the priority after the stream was completed", func() {
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			str.CancelWrite(1234)
+			str.SetPriority(StreamPriority{Urgency: 1})
+		})
+	})
+
 	Context("handling MAX_STREAM_DATA frames", func() {
 		It("informs the flow controller", func() {
 			mockFC.EXPECT().UpdateSendWindow(protocol.ByteCount(0x1337))
@@ -998,6 +1291,188 @@ var _ = Describe("Send Stream", func() {
 		})
 	})
 
+	Context("partial reliability", func() {
+		It("drops lost data if too much data was sent after it", func() {
+			str.SetDataExpiry(0, 4)
+			str.writeOffset = 10
+			str.numOutstandingFrames = 2
+			mockSender.EXPECT().supportsPartialReliability().Return(true).Times(2)
+			mockSender.EXPECT().queueControlFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 6})
+			str.queueRetransmission(&wire.StreamFrame{Offset: 0, Data: []byte("foob")})
+			Expect(str.retransmissionQueue).To(BeEmpty())
+			mockSender.EXPECT().onHasStreamData(streamID)
+			str.queueRetransmission(&wire.StreamFrame{Offset: 4, Data: []byte("arbaz!")})
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			f := frame.Frame.(*wire.StreamFrame)
+			Expect(f.Offset).To(Equal(protocol.ByteCount(6)))
+			Expect(f.Data).To(Equal([]byte("baz!")))
+		})
+
+		It("drops lost data that was sent too long ago", func() {
+			str.SetDataExpiry(time.Hour, 0)
+			mockSender.EXPECT().onHasStreamData(streamID)
+			mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(9999))
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := strWithTimeout.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				close(done)
+			}()
+			waitForWrite()
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Eventually(done).Should(BeClosed())
+			Expect(frame).ToNot(BeNil())
+			Expect(str.sentTimes).To(HaveLen(1))
+			str.sentTimes[0].time = time.Now().Add(-2 * time.Hour)
+
+			mockSender.EXPECT().supportsPartialReliability().Return(true)
+			mockSender.EXPECT().queueControlFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 6})
+			frame.OnLost(frame.Frame)
+			frame, hasMoreData := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).To(BeNil())
+			Expect(hasMoreData).To(BeFalse())
+		})
+
+		It("retransmits the data if the peer doesn't support partial reliability", func() {
+			str.SetDataExpiry(0, 4)
+			str.writeOffset = 10
+			str.numOutstandingFrames = 1
+			mockSender.EXPECT().supportsPartialReliability().Return(false)
+			mockSender.EXPECT().onHasStreamData(streamID)
+			str.queueRetransmission(&wire.StreamFrame{Offset: 0, Data: []byte("foobar")})
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			f := frame.Frame.(*wire.StreamFrame)
+			Expect(f.Offset).To(BeZero())
+			Expect(f.Data).To(Equal([]byte("foobar")))
+		})
+
+		It("retransmits the FIN", func() {
+			str.SetDataExpiry(time.Hour, 0)
+			str.writeOffset = 6
+			str.finishedWriting = true
+			str.finSent = true
+			str.numOutstandingFrames = 1
+			str.sentTimes = []sentStreamData{{offset: 6, time: time.Now().Add(-2 * time.Hour)}}
+			mockSender.EXPECT().supportsPartialReliability().Return(true)
+			mockSender.EXPECT().queueControlFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 6})
+			mockSender.EXPECT().onHasStreamData(streamID)
+			str.queueRetransmission(&wire.StreamFrame{Offset: 0, Data: []byte("foobar"), Fin: true})
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			f := frame.Frame.(*wire.StreamFrame)
+			Expect(f.Offset).To(Equal(protocol.ByteCount(6)))
+			Expect(f.Data).To(BeEmpty())
+			Expect(f.Fin).To(BeTrue())
+		})
+
+		It("only informs the peer when the expired offset increases", func() {
+			str.SetDataExpiry(0, 4)
+			str.writeOffset = 10
+			str.numOutstandingFrames = 2
+			mockSender.EXPECT().supportsPartialReliability().Return(true).Times(2)
+			mockSender.EXPECT().queueControlFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 6})
+			str.queueRetransmission(&wire.StreamFrame{Offset: 2, Data: []byte("foo")})
+			str.queueRetransmission(&wire.StreamFrame{Offset: 0, Data: []byte("fo")})
+			Expect(str.retransmissionQueue).To(BeEmpty())
+		})
+
+		It("completes the stream when all lost data expired", func() {
+			str.SetDataExpiry(0, 1)
+			str.writeOffset = 6
+			str.finishedWriting = true
+			str.finSent = true
+			str.numOutstandingFrames = 1
+			mockSender.EXPECT().supportsPartialReliability().Return(true)
+			mockSender.EXPECT().queueControlFrame(&wire.ExpiredStreamDataFrame{StreamID: streamID, Offset: 5})
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			str.queueRetransmission(&wire.StreamFrame{Offset: 0, Data: []byte("foo")})
+		})
+	})
+
+	Context("reliable stream resets", func() {
+		It("sends a RESET_STREAM_AT frame and delivers the data up to the reliable size", func() {
+			mockSender.EXPECT().onHasStreamData(streamID)
+			_, err := strWithTimeout.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			str.SetReliableBoundary()
+			mockSender.EXPECT().supportsReliableStreamReset().Return(true)
+			mockSender.EXPECT().queueControlFrame(&wire.ResetStreamFrame{
+				StreamID:     streamID,
+				FinalSize:    6,
+				ErrorCode:    1234,
+				ReliableSize: 6,
+			})
+			mockSender.EXPECT().onHasStreamData(streamID)
+			str.CancelWrite(1234)
+			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount)
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+			frame, hasMoreData := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			Expect(hasMoreData).To(BeFalse())
+			f := frame.Frame.(*wire.StreamFrame)
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			Expect(f.Fin).To(BeFalse())
+			next, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(next).To(BeNil())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			frame.OnAcked(f)
+		})
+
+		It("only retransmits data up to the reliable size", func() {
+			str.writeOffset = 10
+			str.numOutstandingFrames = 2
+			str.reliableSize = 4
+			mockSender.EXPECT().supportsReliableStreamReset().Return(true)
+			mockSender.EXPECT().queueControlFrame(&wire.ResetStreamFrame{
+				StreamID:     streamID,
+				FinalSize:    10,
+				ErrorCode:    1234,
+				ReliableSize: 4,
+			})
+			str.CancelWrite(1234)
+			str.queueRetransmission(&wire.StreamFrame{Offset: 6, Data: []byte("baz!")})
+			Expect(str.retransmissionQueue).To(BeEmpty())
+			mockSender.EXPECT().onHasStreamData(streamID)
+			str.queueRetransmission(&wire.StreamFrame{Offset: 0, Data: []byte("foobar")})
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			f := frame.Frame.(*wire.StreamFrame)
+			Expect(f.Offset).To(BeZero())
+			Expect(f.Data).To(Equal([]byte("foob")))
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			frame.OnAcked(f)
+		})
+
+		It("sends a RESET_STREAM frame if the peer doesn't support reliable resets", func() {
+			str.writeOffset = 10
+			str.reliableSize = 4
+			mockSender.EXPECT().supportsReliableStreamReset().Return(false)
+			mockSender.EXPECT().queueControlFrame(&wire.ResetStreamFrame{
+				StreamID:  streamID,
+				FinalSize: 10,
+				ErrorCode: 1234,
+			})
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			str.CancelWrite(1234)
+		})
+
+		It("sends a RESET_STREAM frame when receiving a STOP_SENDING frame", func() {
+			str.writeOffset = 10
+			str.reliableSize = 4
+			mockSender.EXPECT().queueControlFrame(&wire.ResetStreamFrame{
+				StreamID:  streamID,
+				FinalSize: 10,
+				ErrorCode: 1234,
+			})
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			str.handleStopSendingFrame(&wire.StopSendingFrame{StreamID: streamID, ErrorCode: 1234})
+		})
+	})
+
 	Context("determining when a stream is completed", func() {
 		BeforeEach(func() {
 			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
@@ -3,7 +3,6 @@ package quic
 import (
 	"bytes"
 	"context"
-	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -35,6 +34,9 @@ type unknownPacketHandler interface {
 
 type packetHandlerManager interface {
 	AddWithConnID(protocol.ConnectionID, protocol.ConnectionID, func() packetHandler) bool
+	AddWithRemoteAddr(net.Addr, packetHandler) bool
+	RemoveRemoteAddr(net.Addr)
+	ReplaceRemoteAddrWithClosed(net.Addr, packetHandler)
 	Destroy() error
 	connRunner
 	SetServer(unknownPacketHandler)
@@ -66,7 +68,7 @@ type baseServer struct {
 	// If it is started with Listen, we take a packet conn as a parameter.
 	createdPacketConn bool
 
-	tokenGenerator *handshake.TokenGenerator
+	tokenGenerator TokenGenerator
 
 	connHandler packetHandlerManager
 
@@ -84,7 +86,7 @@ type baseServer struct {
 		protocol.StatelessResetToken,
 		*Config,
 		*tls.Config,
-		*handshake.TokenGenerator,
+		TokenGenerator,
 		bool, /* enable 0-RTT */
 		logging.ConnectionTracer,
 		uint64,
@@ -191,9 +193,12 @@ func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config, acceptEarl
 	if err != nil {
 		return nil, err
 	}
-	tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
-	if err != nil {
-		return nil, err
+	tokenGenerator := config.TokenGenerator
+	if tokenGenerator == nil {
+		tokenGenerator, err = newRandomTokenGenerator()
+		if err != nil {
+			return nil, err
+		}
 	}
 	c, err := wrapConn(conn)
 	if err != nil {
@@ -210,7 +215,7 @@ func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config, acceptEarl
 		running:          make(chan struct{}),
 		receivedPackets:  make(chan *receivedPacket, protocol.MaxServerUnprocessedPackets),
 		newConn:          newConnection,
-		logger:           utils.DefaultLogger.WithPrefix("server"),
+		logger:           utils.GetLogger(config.Logger).WithPrefix("server"),
 		acceptEarlyConns: acceptEarly,
 	}
 	go s.run()
@@ -301,6 +306,12 @@ func (s *baseServer) Close() error {
 	return nil
 }
 
+func (s *baseServer) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
 func (s *baseServer) setCloseError(e error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -407,20 +418,15 @@ func (s *baseServer) handleInitialImpl(p *receivedPacket, hdr *wire.Header) erro
 	)
 	origDestConnID := hdr.DestConnectionID
 	if len(hdr.Token) > 0 {
-		c, err := s.tokenGenerator.DecodeToken(hdr.Token)
-		if err == nil {
-			token = &Token{
-				IsRetryToken: c.IsRetryToken,
-				RemoteAddr:   c.RemoteAddr,
-				SentTime:     c.SentTime,
-			}
+		if t, err := s.tokenGenerator.DecodeToken(hdr.Token); err == nil {
+			token = t
 			if token.IsRetryToken {
-				origDestConnID = c.OriginalDestConnectionID
-				retrySrcConnID = &c.RetrySrcConnectionID
+				origDestConnID = token.OriginalDestConnectionID
+				retrySrcConnID = &token.RetrySrcConnectionID
 			}
 		}
 	}
-	if !s.config.AcceptToken(p.remoteAddr, token) {
+	if !s.isAddressValidated(p.remoteAddr, token) {
 		go func() {
 			defer p.buffer.Release()
 			if token != nil && token.IsRetryToken {
@@ -447,7 +453,7 @@ func (s *baseServer) handleInitialImpl(p *receivedPacket, hdr *wire.Header) erro
 		return nil
 	}
 
-	connID, err := protocol.GenerateConnectionID(s.config.ConnectionIDLength)
+	connID, err := s.config.ConnectionIDGenerator.GenerateConnectionID()
 	if err != nil {
 		return err
 	}
@@ -528,11 +534,20 @@ func (s *baseServer) handleNewConn(conn quicConn) {
 	}
 }
 
+// isAddressValidated determines if a new connection can be created for a client.
+// If the client didn't present a valid token, RequireAddressValidation can be used to skip address validation.
+func (s *baseServer) isAddressValidated(remoteAddr net.Addr, token *Token) bool {
+	if token == nil && s.config.RequireAddressValidation != nil && !s.config.RequireAddressValidation(remoteAddr) {
+		return true
+	}
+	return s.config.AcceptToken(remoteAddr, token)
+}
+
 func (s *baseServer) sendRetry(remoteAddr net.Addr, hdr *wire.Header, info *packetInfo) error {
 	// Log the Initial packet now.
 	// If no Retry is sent, the packet will be logged by the connection.
 	(&wire.ExtendedHeader{Header: *hdr}).Log(s.logger)
-	srcConnID, err := protocol.GenerateConnectionID(s.config.ConnectionIDLength)
+	srcConnID, err := s.config.ConnectionIDGenerator.GenerateConnectionID()
 	if err != nil {
 		return err
 	}
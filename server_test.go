@@ -157,6 +157,15 @@ var _ = Describe("Server", func() {
 		Expect(ln.Close()).To(Succeed())
 	})
 
+	It("uses the configured token generator", func() {
+		tokenGenerator, err := NewTokenGenerator(TokenKey{1, 2, 3})
+		Expect(err).ToNot(HaveOccurred())
+		ln, err := Listen(conn, tlsConf, &Config{TokenGenerator: tokenGenerator})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ln.(*baseServer).tokenGenerator).To(Equal(tokenGenerator))
+		Expect(ln.Close()).To(Succeed())
+	})
+
 	It("listens on a given address", func() {
 		addr := "127.0.0.1:13579"
 		ln, err := ListenAddr(addr, tlsConf, &Config{})
@@ -335,7 +344,7 @@ var _ = Describe("Server", func() {
 					tokenP protocol.StatelessResetToken,
 					_ *Config,
 					_ *tls.Config,
-					_ *handshake.TokenGenerator,
+					_ TokenGenerator,
 					enable0RTT bool,
 					_ logging.ConnectionTracer,
 					_ uint64,
@@ -503,6 +512,17 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("only requires address validation for clients that RequireAddressValidation selects", func() {
+				serv.config.AcceptToken = func(_ net.Addr, _ *Token) bool { return false }
+				serv.config.RequireAddressValidation = func(addr net.Addr) bool {
+					return !addr.(*net.UDPAddr).IP.IsLoopback()
+				}
+				Expect(serv.isAddressValidated(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}, nil)).To(BeTrue())
+				Expect(serv.isAddressValidated(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}, nil)).To(BeFalse())
+				// tokens presented by the client are still checked using AcceptToken
+				Expect(serv.isAddressValidated(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}, &Token{})).To(BeFalse())
+			})
+
 			It("sends an INVALID_TOKEN error, if an invalid retry token is received", func() {
 				serv.config.AcceptToken = func(_ net.Addr, _ *Token) bool { return false }
 				token, err := serv.tokenGenerator.NewRetryToken(&net.UDPAddr{}, nil, nil)
@@ -541,7 +561,7 @@ var _ = Describe("Server", func() {
 					Expect(err).ToNot(HaveOccurred())
 					data, err := opener.Open(nil, b[extHdr.ParsedLen():], extHdr.PacketNumber, b[:extHdr.ParsedLen()])
 					Expect(err).ToNot(HaveOccurred())
-					f, err := wire.NewFrameParser(false, hdr.Version).ParseNext(bytes.NewReader(data), protocol.EncryptionInitial)
+					f, err := wire.NewFrameParser(false, false, false, false, false, hdr.Version).ParseNext(bytes.NewReader(data), protocol.EncryptionInitial)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(f).To(BeAssignableToTypeOf(&wire.ConnectionCloseFrame{}))
 					ccf := f.(*wire.ConnectionCloseFrame)
@@ -614,7 +634,7 @@ var _ = Describe("Server", func() {
 					tokenP protocol.StatelessResetToken,
 					_ *Config,
 					_ *tls.Config,
-					_ *handshake.TokenGenerator,
+					_ TokenGenerator,
 					enable0RTT bool,
 					_ logging.ConnectionTracer,
 					_ uint64,
@@ -674,7 +694,7 @@ var _ = Describe("Server", func() {
 					_ protocol.StatelessResetToken,
 					_ *Config,
 					_ *tls.Config,
-					_ *handshake.TokenGenerator,
+					_ TokenGenerator,
 					_ bool,
 					_ logging.ConnectionTracer,
 					_ uint64,
@@ -728,7 +748,7 @@ var _ = Describe("Server", func() {
 					_ protocol.StatelessResetToken,
 					_ *Config,
 					_ *tls.Config,
-					_ *handshake.TokenGenerator,
+					_ TokenGenerator,
 					_ bool,
 					_ logging.ConnectionTracer,
 					_ uint64,
@@ -759,7 +779,7 @@ var _ = Describe("Server", func() {
 					_ protocol.StatelessResetToken,
 					_ *Config,
 					_ *tls.Config,
-					_ *handshake.TokenGenerator,
+					_ TokenGenerator,
 					_ bool,
 					_ logging.ConnectionTracer,
 					_ uint64,
@@ -831,7 +851,7 @@ var _ = Describe("Server", func() {
 					_ protocol.StatelessResetToken,
 					_ *Config,
 					_ *tls.Config,
-					_ *handshake.TokenGenerator,
+					_ TokenGenerator,
 					_ bool,
 					_ logging.ConnectionTracer,
 					_ uint64,
@@ -943,7 +963,7 @@ var _ = Describe("Server", func() {
 					_ protocol.StatelessResetToken,
 					_ *Config,
 					_ *tls.Config,
-					_ *handshake.TokenGenerator,
+					_ TokenGenerator,
 					_ bool,
 					_ logging.ConnectionTracer,
 					_ uint64,
@@ -1017,7 +1037,7 @@ var _ = Describe("Server", func() {
 				_ protocol.StatelessResetToken,
 				_ *Config,
 				_ *tls.Config,
-				_ *handshake.TokenGenerator,
+				_ TokenGenerator,
 				enable0RTT bool,
 				_ logging.ConnectionTracer,
 				_ uint64,
@@ -1060,7 +1080,7 @@ var _ = Describe("Server", func() {
 				_ protocol.StatelessResetToken,
 				_ *Config,
 				_ *tls.Config,
-				_ *handshake.TokenGenerator,
+				_ TokenGenerator,
 				_ bool,
 				_ logging.ConnectionTracer,
 				_ uint64,
@@ -1124,7 +1144,7 @@ var _ = Describe("Server", func() {
 				_ protocol.StatelessResetToken,
 				_ *Config,
 				_ *tls.Config,
-				_ *handshake.TokenGenerator,
+				_ TokenGenerator,
 				_ bool,
 				_ logging.ConnectionTracer,
 				_ uint64,
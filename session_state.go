@@ -0,0 +1,27 @@
+package quic
+
+import (
+	"crypto/tls"
+	"errors"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+)
+
+// MarshalClientSessionState encodes a session state that was stored in the tls.Config.ClientSessionCache
+// by a QUIC connection. Besides the TLS session ticket, the encoding contains the server's transport parameters
+// and the RTT, both of which are required to use 0-RTT when resuming the session.
+// This allows clients to persist session states, e.g. by wrapping their ClientSessionCache,
+// and to use 0-RTT on the first connection after a restart.
+// The encoding contains the secret used to resume the session, and must therefore be stored securely.
+func MarshalClientSessionState(s *tls.ClientSessionState) ([]byte, error) {
+	if s == nil {
+		return nil, errors.New("quic: no session state")
+	}
+	return handshake.MarshalClientSessionState(s)
+}
+
+// UnmarshalClientSessionState decodes a session state encoded by MarshalClientSessionState.
+// The session state can then be added to the tls.Config.ClientSessionCache.
+func UnmarshalClientSessionState(data []byte) (*tls.ClientSessionState, error) {
+	return handshake.UnmarshalClientSessionState(data)
+}
@@ -25,6 +25,11 @@ var errDeadline net.Error = &deadlineError{}
 type streamSender interface {
 	queueControlFrame(wire.Frame)
 	onHasStreamData(protocol.StreamID)
+	setStreamPriority(protocol.StreamID, StreamPriority)
+	// supportsPartialReliability says if both endpoints support partial reliability
+	supportsPartialReliability() bool
+	// supportsReliableStreamReset says if both endpoints support the RESET_STREAM_AT frame
+	supportsReliableStreamReset() bool
 	// must be called without holding the mutex that is acquired by closeForShutdown
 	onStreamCompleted(protocol.StreamID)
 }
@@ -56,6 +61,7 @@ type streamI interface {
 	// for receiving
 	handleStreamFrame(*wire.StreamFrame) error
 	handleResetStreamFrame(*wire.ResetStreamFrame) error
+	handleExpiredStreamDataFrame(*wire.ExpiredStreamDataFrame) error
 	getWindowUpdate() protocol.ByteCount
 	// for sending
 	hasData() bool
@@ -0,0 +1,25 @@
+package quic
+
+import "github.com/lucas-clemente/quic-go/internal/handshake"
+
+type (
+	// A TLSBackend creates the TLS connections used for the QUIC handshake.
+	// It allows using a TLS 1.3 implementation other than qtls, see Config.TLSBackend.
+	TLSBackend = handshake.TLSBackend
+	// A TLSConn is a TLS 1.3 connection that uses QUIC (instead of TLS records) to transport handshake messages.
+	TLSConn = handshake.TLSConn
+	// A TLSConnConfig is passed to the TLSBackend when creating a TLSConn.
+	// It contains the callbacks that the TLS stack must invoke during the handshake.
+	TLSConnConfig = handshake.TLSConnConfig
+	// A TLSRecordLayer is implemented by quic-go. The TLS stack uses it to send and receive handshake messages,
+	// and to install the keys derived during the handshake.
+	TLSRecordLayer = handshake.RecordLayer
+	// A TLSCipherSuite is a TLS 1.3 cipher suite.
+	TLSCipherSuite = handshake.CipherSuite
+	// A TLSExtension is a TLS extension.
+	TLSExtension = handshake.TLSExtension
+)
+
+// DefaultTLSBackend is the TLS backend used if Config.TLSBackend is not set.
+// It uses qtls.
+var DefaultTLSBackend TLSBackend = handshake.DefaultTLSBackend
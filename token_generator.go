@@ -0,0 +1,79 @@
+package quic
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+)
+
+// A TokenGenerator generates the tokens that the server sends in Retry packets and NEW_TOKEN frames,
+// and decodes the tokens that clients send in their Initial packets.
+// Tokens need to be protected, such that clients can neither read nor forge them.
+type TokenGenerator interface {
+	// NewRetryToken generates a token for a Retry packet.
+	// The original destination connection ID and the retry source connection ID must be encoded in the token,
+	// and returned when the token is decoded.
+	NewRetryToken(remoteAddr net.Addr, origDestConnID, retrySrcConnID ConnectionID) ([]byte, error)
+	// NewToken generates a token for a NEW_TOKEN frame.
+	NewToken(remoteAddr net.Addr) ([]byte, error)
+	// DecodeToken decodes a token.
+	// It returns an error if the token is invalid, for example if it was not generated by this TokenGenerator.
+	// Tokens that fail to decode are treated as if the client didn't send a token.
+	DecodeToken(token []byte) (*Token, error)
+}
+
+// A TokenKey is a key used to protect tokens, see NewTokenGenerator.
+type TokenKey [32]byte
+
+type tokenGenerator struct {
+	*handshake.TokenGenerator
+}
+
+var _ TokenGenerator = &tokenGenerator{}
+
+// NewTokenGenerator creates a TokenGenerator that uses quic-go's token format.
+// Tokens are encrypted and authenticated using the first key.
+// Tokens protected using any of the keys are accepted. This allows rotating keys without rejecting
+// tokens that were issued shortly before the rotation: the new key is added as the first key,
+// and the old key is removed once all tokens issued with it have expired.
+// Using the same keys on multiple servers allows them to accept each other's tokens.
+func NewTokenGenerator(keys ...TokenKey) (TokenGenerator, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("quic: no token key provided")
+	}
+	secrets := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		k := key
+		secrets = append(secrets, k[:])
+	}
+	return &tokenGenerator{TokenGenerator: handshake.NewTokenGeneratorWithKeys(rand.Reader, secrets)}, nil
+}
+
+// newRandomTokenGenerator creates a TokenGenerator using a random key.
+// It is used if no Config.TokenGenerator is set.
+func newRandomTokenGenerator() (TokenGenerator, error) {
+	g, err := handshake.NewTokenGenerator(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenGenerator{TokenGenerator: g}, nil
+}
+
+func (g *tokenGenerator) DecodeToken(data []byte) (*Token, error) {
+	t, err := g.TokenGenerator.DecodeToken(data)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, errors.New("empty token")
+	}
+	return &Token{
+		IsRetryToken:             t.IsRetryToken,
+		RemoteAddr:               t.RemoteAddr,
+		SentTime:                 t.SentTime,
+		OriginalDestConnectionID: t.OriginalDestConnectionID,
+		RetrySrcConnectionID:     t.RetrySrcConnectionID,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token Generator", func() {
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
+
+	It("errors if no key is provided", func() {
+		_, err := NewTokenGenerator()
+		Expect(err).To(MatchError("quic: no token key provided"))
+	})
+
+	It("generates and decodes Retry tokens", func() {
+		g, err := NewTokenGenerator(TokenKey{1, 2, 3})
+		Expect(err).ToNot(HaveOccurred())
+		data, err := g.NewRetryToken(addr, protocol.ConnectionID{1, 2, 3, 4}, protocol.ConnectionID{5, 6, 7, 8})
+		Expect(err).ToNot(HaveOccurred())
+		token, err := g.DecodeToken(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.IsRetryToken).To(BeTrue())
+		Expect(token.RemoteAddr).To(Equal("192.168.0.1"))
+		Expect(token.OriginalDestConnectionID).To(Equal(protocol.ConnectionID{1, 2, 3, 4}))
+		Expect(token.RetrySrcConnectionID).To(Equal(protocol.ConnectionID{5, 6, 7, 8}))
+	})
+
+	It("generates and decodes tokens for NEW_TOKEN frames", func() {
+		g, err := NewTokenGenerator(TokenKey{1, 2, 3})
+		Expect(err).ToNot(HaveOccurred())
+		data, err := g.NewToken(addr)
+		Expect(err).ToNot(HaveOccurred())
+		token, err := g.DecodeToken(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.IsRetryToken).To(BeFalse())
+		Expect(token.RemoteAddr).To(Equal("192.168.0.1"))
+	})
+
+	It("errors when decoding an empty token", func() {
+		g, err := NewTokenGenerator(TokenKey{1, 2, 3})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = g.DecodeToken(nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("accepts tokens generated with a different generator using the same key", func() {
+		g1, err := NewTokenGenerator(TokenKey{1, 2, 3})
+		Expect(err).ToNot(HaveOccurred())
+		g2, err := NewTokenGenerator(TokenKey{1, 2, 3})
+		Expect(err).ToNot(HaveOccurred())
+		data, err := g1.NewToken(addr)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = g2.DecodeToken(data)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rotates keys", func() {
+		oldKey := TokenKey{1, 2, 3}
+		newKey := TokenKey{3, 2, 1}
+		g1, err := NewTokenGenerator(oldKey)
+		Expect(err).ToNot(HaveOccurred())
+		data, err := g1.NewToken(addr)
+		Expect(err).ToNot(HaveOccurred())
+		// after the rotation, tokens issued with the old key are still accepted
+		g2, err := NewTokenGenerator(newKey, oldKey)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = g2.DecodeToken(data)
+		Expect(err).ToNot(HaveOccurred())
+		// once the old key is removed, they are rejected
+		g3, err := NewTokenGenerator(newKey)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = g3.DecodeToken(data)
+		Expect(err).To(HaveOccurred())
+		// new tokens are protected using the new key
+		data, err = g2.NewToken(addr)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = g3.DecodeToken(data)
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
@@ -2,11 +2,28 @@ package quic
 
 import (
 	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
 	"sync"
 
 	"github.com/lucas-clemente/quic-go/internal/utils"
 )
 
+// MarshalBinary encodes the token, such that it can be persisted by a TokenStore.
+func (t *ClientToken) MarshalBinary() ([]byte, error) {
+	return append([]byte{}, t.data...), nil
+}
+
+// UnmarshalBinary decodes a token encoded by MarshalBinary.
+func (t *ClientToken) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty token")
+	}
+	t.data = append([]byte{}, data...)
+	return nil
+}
+
 type singleOriginTokenStore struct {
 	tokens []*ClientToken
 	len    int
@@ -35,6 +52,15 @@ func (s *singleOriginTokenStore) Len() int {
 	return s.len
 }
 
+// All returns all tokens, starting with the token that was added first.
+func (s *singleOriginTokenStore) All() []*ClientToken {
+	tokens := make([]*ClientToken, 0, s.len)
+	for i := 0; i < s.len; i++ {
+		tokens = append(tokens, s.tokens[s.index(s.p-s.len+i)])
+	}
+	return tokens
+}
+
 func (s *singleOriginTokenStore) index(i int) int {
 	mod := len(s.tokens)
 	return (i + mod) % mod
@@ -115,3 +141,112 @@ func (s *lruTokenStore) Pop(key string) *ClientToken {
 	}
 	return token
 }
+
+// persistedOrigin is used for JSON serialization of the tokens of a single origin
+type persistedOrigin struct {
+	Key    string   `json:"key"`
+	Tokens [][]byte `json:"tokens"` // the token that was added first comes first
+}
+
+// persistedOrigins returns the tokens of all origins, starting with the least recently used origin.
+// Adding them to an empty lruTokenStore in this order restores the state of the store.
+func (s *lruTokenStore) persistedOrigins() []persistedOrigin {
+	origins := make([]persistedOrigin, 0, s.q.Len())
+	for el := s.q.Back(); el != nil; el = el.Prev() {
+		entry := el.Value.(*lruTokenStoreEntry)
+		o := persistedOrigin{Key: entry.key}
+		for _, t := range entry.cache.All() {
+			o.Tokens = append(o.Tokens, t.data)
+		}
+		origins = append(origins, o)
+	}
+	return origins
+}
+
+type fileTokenStore struct {
+	mutex sync.Mutex
+
+	path  string
+	store *lruTokenStore
+
+	logger utils.Logger
+}
+
+var _ TokenStore = &fileTokenStore{}
+
+// NewFileTokenStore creates a new LRU cache for tokens received by the client, which is persisted to a file.
+// This allows clients to skip address validation on the first connection attempt after a restart.
+// Tokens stored in the file are loaded when the cache is created. If the file doesn't exist, it is created
+// when the first token is added. The file is updated every time a token is added or removed,
+// and must not be used by multiple TokenStores at the same time.
+// maxOrigins specifies how many origins this cache is saving tokens for.
+// tokensPerOrigin specifies the maximum number of tokens per origin.
+func NewFileTokenStore(path string, maxOrigins, tokensPerOrigin int) (TokenStore, error) {
+	s := &fileTokenStore{
+		path:   path,
+		store:  NewLRUTokenStore(maxOrigins, tokensPerOrigin).(*lruTokenStore),
+		logger: utils.DefaultLogger.WithPrefix("token store"),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	var origins []persistedOrigin
+	if err := json.Unmarshal(data, &origins); err != nil {
+		return nil, err
+	}
+	for _, o := range origins {
+		for _, t := range o.Tokens {
+			token := &ClientToken{}
+			if err := token.UnmarshalBinary(t); err != nil {
+				return nil, err
+			}
+			s.store.Put(o.Key, token)
+		}
+	}
+	return s, nil
+}
+
+func (s *fileTokenStore) Put(key string, token *ClientToken) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.store.Put(key, token)
+	s.persist()
+}
+
+func (s *fileTokenStore) Pop(key string) *ClientToken {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token := s.store.Pop(key)
+	if token != nil {
+		s.persist()
+	}
+	return token
+}
+
+// persist writes the tokens to a temporary file, and then renames it,
+// such that the file is never left in an inconsistent state.
+func (s *fileTokenStore) persist() {
+	s.store.mutex.Lock()
+	origins := s.store.persistedOrigins()
+	s.store.mutex.Unlock()
+
+	data, err := json.Marshal(origins)
+	if err != nil {
+		s.logger.Errorf("Failed to encode tokens: %s", err)
+		return
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		s.logger.Errorf("Failed to write tokens: %s", err)
+		return
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		s.logger.Errorf("Failed to write tokens: %s", err)
+	}
+}
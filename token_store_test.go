@@ -2,6 +2,8 @@ package quic
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -105,4 +107,85 @@ var _ = Describe("Token Cache", func() {
 			Expect(s.Pop("host4")).To(Equal(mockToken(4)))
 		})
 	})
+
+	It("marshals and unmarshals tokens", func() {
+		data, err := mockToken(42).MarshalBinary()
+		Expect(err).ToNot(HaveOccurred())
+		token := &ClientToken{}
+		Expect(token.UnmarshalBinary(data)).To(Succeed())
+		Expect(token).To(Equal(mockToken(42)))
+		Expect((&ClientToken{}).UnmarshalBinary(nil)).To(MatchError("empty token"))
+	})
+
+	Context("persisting tokens to a file", func() {
+		var dir, path string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "quic-go-token-store")
+			Expect(err).ToNot(HaveOccurred())
+			path = filepath.Join(dir, "tokens.json")
+			s, err = NewFileTokenStore(path, 3, 4)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("starts with an empty store if the file doesn't exist", func() {
+			Expect(s.Pop("localhost")).To(BeNil())
+			_, err := os.Stat(path)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("restores tokens", func() {
+			s.Put("host1", mockToken(1))
+			s.Put("host1", mockToken(2))
+			s.Put("host2", mockToken(3))
+			info, err := os.Stat(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+
+			s2, err := NewFileTokenStore(path, 3, 4)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s2.Pop("host1")).To(Equal(mockToken(2)))
+			Expect(s2.Pop("host1")).To(Equal(mockToken(1)))
+			Expect(s2.Pop("host1")).To(BeNil())
+			Expect(s2.Pop("host2")).To(Equal(mockToken(3)))
+		})
+
+		It("persists removals", func() {
+			s.Put("host1", mockToken(1))
+			s.Put("host1", mockToken(2))
+			Expect(s.Pop("host1")).To(Equal(mockToken(2)))
+
+			s2, err := NewFileTokenStore(path, 3, 4)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s2.Pop("host1")).To(Equal(mockToken(1)))
+			Expect(s2.Pop("host1")).To(BeNil())
+		})
+
+		It("preserves the LRU order", func() {
+			s.Put("host1", mockToken(1))
+			s.Put("host2", mockToken(2))
+			s.Put("host3", mockToken(3))
+			s.Put("host1", mockToken(11)) // host2 is now the least recently used origin
+
+			s2, err := NewFileTokenStore(path, 3, 4)
+			Expect(err).ToNot(HaveOccurred())
+			s2.Put("host4", mockToken(4))
+			Expect(s2.Pop("host2")).To(BeNil())
+			Expect(s2.Pop("host1")).To(Equal(mockToken(11)))
+			Expect(s2.Pop("host1")).To(Equal(mockToken(1)))
+			Expect(s2.Pop("host3")).To(Equal(mockToken(3)))
+			Expect(s2.Pop("host4")).To(Equal(mockToken(4)))
+		})
+
+		It("errors when the file is corrupted", func() {
+			Expect(os.WriteFile(path, []byte("foobar"), 0o600)).To(Succeed())
+			_, err := NewFileTokenStore(path, 3, 4)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -0,0 +1,167 @@
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+var errTransportClosed = errors.New("transport closed")
+
+// A Transport uses a single net.PacketConn to both dial QUIC connections and accept incoming QUIC connections.
+// Incoming packets are demultiplexed using their connection IDs.
+// This allows applications (e.g. peer-to-peer applications) to act as a client and as a server on the same port.
+// The fields of the Transport must not be modified after the first call to one of its methods.
+type Transport struct {
+	// Conn is the packet conn used to send and receive packets.
+	// It is closed when the Transport is closed.
+	Conn net.PacketConn
+
+	// ConnectionIDLength is the length of the connection IDs used by all connections on this Transport.
+	// Since connection IDs are used to demultiplex packets, zero-length connection IDs can't be used.
+	// If not set, a length of 4 bytes is used.
+	// It is ignored if a ConnectionIDGenerator is set.
+	ConnectionIDLength int
+	// ConnectionIDGenerator generates the connection IDs used by all connections on this Transport,
+	// see Config.ConnectionIDGenerator.
+	ConnectionIDGenerator ConnectionIDGenerator
+	// StatelessResetKey is the key used to generate stateless reset tokens, see Config.StatelessResetKey.
+	StatelessResetKey []byte
+	// Tracer is used to trace all connections on this Transport, see Config.Tracer.
+	Tracer logging.Tracer
+
+	initOnce sync.Once
+	initErr  error
+
+	connHandler packetHandlerManager
+
+	mutex  sync.Mutex
+	server *baseServer
+	closed bool
+}
+
+func (t *Transport) init() error {
+	t.initOnce.Do(func() {
+		if t.Conn == nil {
+			t.initErr = errors.New("quic: Transport.Conn not set")
+			return
+		}
+		if t.ConnectionIDGenerator != nil {
+			t.ConnectionIDLength = t.ConnectionIDGenerator.ConnectionIDLen()
+		}
+		if t.ConnectionIDLength == 0 {
+			t.ConnectionIDLength = protocol.DefaultConnectionIDLength
+		}
+		if t.ConnectionIDLength < 0 || t.ConnectionIDLength > protocol.MaxConnIDLen {
+			t.initErr = errors.New("quic: invalid value for Transport.ConnectionIDLength")
+			return
+		}
+		t.connHandler, t.initErr = getMultiplexer().AddConn(t.Conn, t.ConnectionIDLength, t.StatelessResetKey, t.Tracer)
+	})
+	return t.initErr
+}
+
+// populateConfig applies the Transport's settings to the config of a new listener or connection.
+func (t *Transport) populateConfig(config *Config) *Config {
+	if config == nil {
+		config = &Config{}
+	} else {
+		config = config.Clone()
+	}
+	config.ConnectionIDLength = t.ConnectionIDLength
+	config.ConnectionIDGenerator = t.ConnectionIDGenerator
+	config.ZeroLengthConnectionIDs = false
+	config.StatelessResetKey = t.StatelessResetKey
+	config.Tracer = t.Tracer
+	return config
+}
+
+// Listen starts listening for incoming QUIC connections.
+// There can only be a single listener on a Transport at any given time.
+// The ConnectionIDLength, ConnectionIDGenerator, ZeroLengthConnectionIDs, StatelessResetKey and Tracer
+// of the quic.Config are ignored, the values configured on the Transport are used instead.
+func (t *Transport) Listen(tlsConf *tls.Config, config *Config) (Listener, error) {
+	return t.listen(tlsConf, config, false)
+}
+
+// ListenEarly works like Listen, but it returns connections before the handshake completes.
+func (t *Transport) ListenEarly(tlsConf *tls.Config, config *Config) (EarlyListener, error) {
+	s, err := t.listen(tlsConf, config, true)
+	if err != nil {
+		return nil, err
+	}
+	return &earlyServer{s}, nil
+}
+
+func (t *Transport) listen(tlsConf *tls.Config, config *Config, acceptEarly bool) (*baseServer, error) {
+	if err := t.init(); err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed {
+		return nil, errTransportClosed
+	}
+	if t.server != nil && !t.server.isClosed() {
+		return nil, errors.New("quic: transport already listening")
+	}
+	s, err := listen(t.Conn, tlsConf, t.populateConfig(config), acceptEarly)
+	if err != nil {
+		return nil, err
+	}
+	t.server = s
+	return s, nil
+}
+
+// Dial establishes a new QUIC connection to the server at addr.
+// If tls.Config.ServerName is not set, the host of addr is used for SNI.
+// The ConnectionIDLength, ConnectionIDGenerator, ZeroLengthConnectionIDs, StatelessResetKey and Tracer
+// of the quic.Config are ignored, the values configured on the Transport are used instead.
+func (t *Transport) Dial(ctx context.Context, addr net.Addr, tlsConf *tls.Config, config *Config) (Connection, error) {
+	return t.dial(ctx, addr, tlsConf, config, false)
+}
+
+// DialEarly establishes a new 0-RTT QUIC connection to the server at addr.
+// See Dial for details.
+func (t *Transport) DialEarly(ctx context.Context, addr net.Addr, tlsConf *tls.Config, config *Config) (EarlyConnection, error) {
+	return t.dial(ctx, addr, tlsConf, config, true)
+}
+
+func (t *Transport) dial(ctx context.Context, addr net.Addr, tlsConf *tls.Config, config *Config, use0RTT bool) (quicConn, error) {
+	if err := t.init(); err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	closed := t.closed
+	t.mutex.Unlock()
+	if closed {
+		return nil, errTransportClosed
+	}
+	return dialContext(ctx, t.Conn, addr, addr.String(), tlsConf, t.populateConfig(config), use0RTT, false)
+}
+
+// Close closes the listener (if any), all connections and the underlying packet conn.
+func (t *Transport) Close() error {
+	if err := t.init(); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.closed = true
+	server := t.server
+	t.mutex.Unlock()
+
+	if server != nil {
+		server.Close()
+	}
+	return t.connHandler.Destroy()
+}
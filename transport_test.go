@@ -0,0 +1,84 @@
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	var (
+		tr   *Transport
+		conn *net.UDPConn
+	)
+
+	BeforeEach(func() {
+		var err error
+		conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		tr = &Transport{Conn: conn}
+	})
+
+	AfterEach(func() {
+		tr.Close()
+	})
+
+	It("errors if the packet conn is not set", func() {
+		tr := &Transport{}
+		_, err := tr.Listen(&tls.Config{}, nil)
+		Expect(err).To(MatchError("quic: Transport.Conn not set"))
+		_, err = tr.Dial(context.Background(), conn.LocalAddr(), &tls.Config{}, nil)
+		Expect(err).To(MatchError("quic: Transport.Conn not set"))
+	})
+
+	It("errors if the connection ID length is invalid", func() {
+		tr.ConnectionIDLength = protocol.MaxConnIDLen + 1
+		_, err := tr.Listen(&tls.Config{}, nil)
+		Expect(err).To(MatchError("quic: invalid value for Transport.ConnectionIDLength"))
+	})
+
+	It("uses the settings of the transport", func() {
+		tr.StatelessResetKey = []byte("foobar")
+		ln, err := tr.Listen(&tls.Config{}, &Config{ConnectionIDLength: 8, StatelessResetKey: []byte("raboof")})
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		config := ln.(*baseServer).config
+		Expect(config.ConnectionIDLength).To(Equal(protocol.DefaultConnectionIDLength))
+		Expect(config.StatelessResetKey).To(Equal([]byte("foobar")))
+	})
+
+	It("only allows a single listener at a time", func() {
+		ln, err := tr.Listen(&tls.Config{}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = tr.ListenEarly(&tls.Config{}, nil)
+		Expect(err).To(MatchError("quic: transport already listening"))
+		Expect(ln.Close()).To(Succeed())
+		eln, err := tr.ListenEarly(&tls.Config{}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(eln.Close()).To(Succeed())
+	})
+
+	It("closes the listener and the packet conn", func() {
+		ln, err := tr.Listen(&tls.Config{}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tr.Close()).To(Succeed())
+		_, err = ln.Accept(context.Background())
+		Expect(err).To(MatchError("server closed"))
+		_, err = conn.WriteTo([]byte("foobar"), conn.LocalAddr())
+		Expect(err).To(HaveOccurred())
+		Expect(tr.Close()).To(Succeed()) // closing multiple times is ok
+	})
+
+	It("refuses to dial and listen after it was closed", func() {
+		Expect(tr.Close()).To(Succeed())
+		_, err := tr.Listen(&tls.Config{}, nil)
+		Expect(err).To(MatchError(errTransportClosed))
+		_, err = tr.Dial(context.Background(), conn.LocalAddr(), &tls.Config{}, nil)
+		Expect(err).To(MatchError(errTransportClosed))
+	})
+})